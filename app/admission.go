@@ -0,0 +1,132 @@
+package app
+
+import (
+	"encoding/binary"
+	stdjson "encoding/json"
+
+	"github.com/chainmint/crypto/provider"
+	"github.com/chainmint/encoding/json"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/math/checked"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/strategies"
+	abciTypes "github.com/tendermint/abci/types"
+)
+
+// Default admission rules, for a network whose genesis sets none.
+// The default bond asset is the zero asset ID.
+const (
+	defaultMinBond       = 0
+	defaultMaxValidators = 0     // no limit
+	defaultMaxCommission = 10000 // basis points
+)
+
+// Errors returned when a validator registration is rejected.
+// Their messages are part of the DeliverTx result, so they must
+// not change between releases.
+var (
+	ErrBadRegistration   = errors.New("malformed validator registration")
+	ErrBondTooLow        = errors.New("bond below minimum")
+	ErrTooManyValidators = errors.New("validator set is full")
+	ErrCommissionTooHigh = errors.New("commission above maximum")
+)
+
+// validatorRegistration is the reference data of a transaction
+// that asks to admit a new validator, or to add to the bond of one
+// already admitted. The validator's bond is the amount of the bond
+// asset the transaction retires. The registration is signed by the
+// validator's key; see registrationMessage.
+type validatorRegistration struct {
+	PubKey     json.HexBytes `json:"pub_key"`
+	Commission uint64        `json:"commission"` // basis points
+	Signature  json.HexBytes `json:"signature"`
+}
+
+// admissionRules are the criteria a new validator must meet. Like
+// the voting rules, they are the same on every node: they come from
+// genesis and accepted proposals, not from the node's configuration.
+type admissionRules struct {
+	BondAsset     bc.AssetID
+	MinBond       uint64
+	MaxValidators int    // 0 means no limit
+	MaxCommission uint64 // basis points
+}
+
+// defaultAdmissionRules returns the admission rules of a new
+// network; see policyChanges.apply.
+func defaultAdmissionRules() admissionRules {
+	return admissionRules{
+		MinBond:       defaultMinBond,
+		MaxValidators: defaultMaxValidators,
+		MaxCommission: defaultMaxCommission,
+	}
+}
+
+// parseRegistration returns the validator registration carried by
+// tx, or nil if tx is not a registration.
+func parseRegistration(tx *legacy.Tx) (*validatorRegistration, error) {
+	var ref struct {
+		Validator *validatorRegistration `json:"register_validator"`
+	}
+	if len(tx.ReferenceData) == 0 || stdjson.Unmarshal(tx.ReferenceData, &ref) != nil {
+		return nil, nil // ordinary transaction
+	}
+	if ref.Validator == nil {
+		return nil, nil
+	}
+	if len(ref.Validator.PubKey) == 0 {
+		return nil, errors.WithDetail(ErrBadRegistration, "missing pub_key")
+	}
+	return ref.Validator, nil
+}
+
+// registrationMessage returns the message a validator signs to
+// register with reg in tx. It covers the outputs tx spends, which no
+// other tx can spend, so that the signature can't be replayed in
+// another registration.
+func registrationMessage(tx *legacy.Tx, reg *validatorRegistration) []byte {
+	msg := append([]byte("chainmint-register:"), reg.PubKey...)
+	var commission [8]byte
+	binary.BigEndian.PutUint64(commission[:], reg.Commission)
+	msg = append(msg, commission[:]...)
+	for _, o := range tx.SpentOutputIDs {
+		msg = append(msg, o.Bytes()...)
+	}
+	return msg
+}
+
+// admit checks reg against the rules, given the current validator
+// set and the bond posted by tx, and returns the validator to add,
+// or, if reg.PubKey is already a validator, the validator with the
+// bond added to its power. The checks depend only on their inputs,
+// so every node rejects the same registrations for the same
+// reasons.
+func (r admissionRules) admit(tx *legacy.Tx, reg *validatorRegistration, vals []*abciTypes.Validator, crypto provider.Provider) (*abciTypes.Validator, error) {
+	if len(tx.SpentOutputIDs) == 0 {
+		return nil, errors.WithDetail(ErrBadRegistration, "spends no outputs")
+	}
+	if !crypto.Verify(reg.PubKey, registrationMessage(tx, reg), reg.Signature) {
+		return nil, errors.WithDetail(ErrBadRegistration, "invalid signature")
+	}
+	bond := strategies.Fee(tx, r.BondAsset)
+	if bond == 0 || bond < r.MinBond {
+		return nil, errors.WithDetailf(ErrBondTooLow, "bond %d, minimum %d", bond, r.MinBond)
+	}
+	if reg.Commission > r.MaxCommission {
+		return nil, errors.WithDetailf(ErrCommissionTooHigh, "commission %d, maximum %d", reg.Commission, r.MaxCommission)
+	}
+	for _, v := range vals {
+		if string(v.PubKey) == string(reg.PubKey) {
+			power, ok := checked.AddUint64(v.Power, bond)
+			if !ok {
+				return nil, errors.WithDetail(ErrBadRegistration, "power overflows")
+			}
+			return &abciTypes.Validator{PubKey: reg.PubKey, Power: power}, nil
+		}
+	}
+	if r.MaxValidators > 0 && len(vals) >= r.MaxValidators {
+		return nil, errors.WithDetailf(ErrTooManyValidators, "maximum %d validators", r.MaxValidators)
+	}
+	return &abciTypes.Validator{PubKey: reg.PubKey, Power: bond}, nil
+}
@@ -0,0 +1,93 @@
+package app
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/chainmint/crypto/ed25519"
+	"github.com/chainmint/crypto/provider"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/vm"
+	abciTypes "github.com/tendermint/abci/types"
+)
+
+func TestAdmit(t *testing.T) {
+	bondAsset := bc.NewAssetID([32]byte{1})
+	rules := admissionRules{
+		BondAsset:     bondAsset,
+		MinBond:       100,
+		MaxValidators: 2,
+		MaxCommission: 500,
+	}
+	crypto, err := provider.Lookup(provider.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubA, _, _ := ed25519.GenerateKey(nil)
+	pubB, privB, _ := ed25519.GenerateKey(nil)
+	pubC, privC, _ := ed25519.GenerateKey(nil)
+	registration := func(pubkey ed25519.PublicKey, signer ed25519.PrivateKey, bond, commission uint64) (*legacy.Tx, *validatorRegistration) {
+		tx := &legacy.Tx{
+			TxData: legacy.TxData{
+				Outputs: []*legacy.TxOutput{
+					legacy.NewTxOutput(bondAsset, bond, []byte{byte(vm.OP_FAIL)}, nil),
+				},
+				ReferenceData: []byte(`{"register_validator": {"pub_key": "` + hex.EncodeToString(pubkey) + `", "commission": 1}}`),
+			},
+			Tx: &bc.Tx{SpentOutputIDs: []bc.Hash{bc.NewHash([32]byte{2})}},
+		}
+		reg, err := parseRegistration(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reg.Commission = commission
+		reg.Signature = ed25519.Sign(signer, registrationMessage(tx, reg))
+		return tx, reg
+	}
+	vals := []*abciTypes.Validator{{PubKey: pubB, Power: 1}}
+
+	cases := []struct {
+		pubkey     ed25519.PublicKey
+		signer     ed25519.PrivateKey
+		bond       uint64
+		commission uint64
+		vals       []*abciTypes.Validator
+		want       error
+		wantPower  uint64
+	}{
+		{pubC, privC, 100, 500, vals, nil, 100},
+		{pubC, privC, 99, 500, vals, ErrBondTooLow, 0},
+		{pubC, privC, 100, 501, vals, ErrCommissionTooHigh, 0},
+		{pubC, privB, 100, 500, vals, ErrBadRegistration, 0}, // signed by another validator
+		{pubC, privC, 100, 0, append(vals, &abciTypes.Validator{PubKey: pubA, Power: 1}), ErrTooManyValidators, 0},
+		{pubB, privB, 100, 0, append(vals, &abciTypes.Validator{PubKey: pubA, Power: 1}), nil, 101}, // rebond
+	}
+	for i, c := range cases {
+		tx, reg := registration(c.pubkey, c.signer, c.bond, c.commission)
+		v, err := rules.admit(tx, reg, c.vals, crypto)
+		if errors.Root(err) != c.want {
+			t.Errorf("case %d: got error %v, want %v", i, err, c.want)
+		}
+		if err == nil && v.Power != c.wantPower {
+			t.Errorf("case %d: got power %d, want %d", i, v.Power, c.wantPower)
+		}
+	}
+
+	// A signature can't be replayed in a tx spending other outputs.
+	tx, reg := registration(pubC, privC, 100, 0)
+	tx.Tx = &bc.Tx{SpentOutputIDs: []bc.Hash{bc.NewHash([32]byte{3})}}
+	_, err = rules.admit(tx, reg, vals, crypto)
+	if errors.Root(err) != ErrBadRegistration {
+		t.Errorf("replayed registration: got error %v, want %v", err, ErrBadRegistration)
+	}
+}
+
+func TestParseRegistrationOrdinaryTx(t *testing.T) {
+	tx := &legacy.Tx{TxData: legacy.TxData{ReferenceData: []byte(`{"memo": "hi"}`)}}
+	reg, err := parseRegistration(tx)
+	if reg != nil || err != nil {
+		t.Errorf("parseRegistration(ordinary tx) = %v, %v, want nil, nil", reg, err)
+	}
+}
@@ -1,23 +1,53 @@
 package app
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"context"
+	"time"
 
 	"github.com/chainmint/protocol/state"
 	"github.com/chainmint/errors"
 	"github.com/chainmint/env"
 	"github.com/chainmint/core"
+	"github.com/chainmint/core/generator"
+	"github.com/chainmint/core/pubsub"
+	"github.com/chainmint/database/pg"
+	"github.com/chainmint/database/sql"
+	"github.com/chainmint/crypto/provider"
+	"github.com/chainmint/protocol/bc"
 	"github.com/chainmint/protocol/bc/legacy"
 	"github.com/chainmint/log"
-	"github.com/chainmint/core/rpc"
+	"github.com/chainmint/strategies"
+	"github.com/chainmint/trace"
+	"github.com/chainmint/txpolicy"
+	"github.com/chainmint/sync/workqueue"
 	abciTypes "github.com/tendermint/abci/types"
 
 	cmtTypes "github.com/chainmint/types"
 )
 
 var (
-	coreURL = env.String("CORE_URL", "http://localhost:1999")
+	// generatorTimeout bounds each call into the generator, so a
+	// hung database or signer can't stall the consensus connection.
+	// A call that runs out of time halts the node, for Tendermint
+	// to replay the block when it restarts.
+	generatorTimeout = env.Duration("GENERATOR_TIMEOUT", 30*time.Second)
+
+	// sideEffectQueueSize bounds the best-effort work waiting to
+	// run off the consensus connection.
+	sideEffectQueueSize = env.Int("SIDE_EFFECT_QUEUE_SIZE", 1024)
+
+	// sideEffectQueuePolicy is what happens when the side-effect
+	// queue is full: "drop" drops the oldest low-priority work,
+	// which the indexers catch up on when they next run, and
+	// "block" holds up Commit until there is room.
+	sideEffectQueuePolicy = env.String("SIDE_EFFECT_QUEUE_POLICY", "drop")
+
+	// appLog logs the ABCI requests made by Tendermint. CheckTx,
+	// called for every tx gossiped to the node, is sampled.
+	appLog     = log.Module("app")
+	checkTxLog = appLog.Sampled(10, 100)
 )
 // ChainmintApplication implements an ABCI application
 type ChainmintApplication struct {
@@ -29,27 +59,245 @@ type ChainmintApplication struct {
 	// a closure to return the latest current state from the chain
 	currentState func() (*legacy.Block, *state.Snapshot)
 
-	// strategy for validator compensation
-	strategy *cmtTypes.Strategy
+	// strategy for validator compensation, and its name in the
+	// strategies registry, empty if given to NewChainmintApplication
+	strategy     cmtTypes.Strategy
+	strategyName string
 	BlockTime uint64
+
+	// height of the Tendermint block being processed
+	height uint64
+
+	// IDs of the txs last passed to CheckTx and DeliverTx, for
+	// crash dumps
+	lastCheckTx   bc.Hash
+	lastDeliverTx bc.Hash
+
+	// current validator set, kept up to date with the
+	// diffs returned from EndBlock
+	validators []*abciTypes.Validator
+
+	// handlers for the paths answered by Query
+	queries map[string]queryHandler
+
+	// criteria for admitting new validators, and the
+	// validators admitted in the block being processed
+	admission admissionRules
+	admitted  []*abciTypes.Validator
+
+	// minimum fee required for admission to the mempool
+	fees feePolicy
+
+	// schema the reference data of transactions must match for
+	// admission to the mempool, or nil
+	memoSchema *memoSchema
+
+	// per-sender limits on the rate of admission to the mempool
+	senders *senderLimiter
+
+	// local policies on admission to the mempool
+	policies *txpolicy.Set
+
+	// txs admitted to the mempool, by the outputs they spend, for
+	// replacement by fee
+	pending *pendingSet
+
+	// stake delegated and undelegated in the block being
+	// processed, by validator public key
+	delegations map[string]*powerDelta
+
+	// on-chain governance, and the unbonding period as set in
+	// genesis and changed by accepted proposals
+	governance *governance
+	unbonding  time.Duration
+
+	// delegations, commission rates and delegator accruals
+	stake *stakeBook
+
+	// validator diffs held back until the end of the epoch
+	epoch epochBuffer
+
+	// hash and signature algorithms of the chain, fixed
+	// at genesis
+	crypto provider.Provider
+
+	// best-effort work, such as indexing and event delivery,
+	// queued by the ABCI methods so that it never holds up
+	// block processing, unless its policy is workqueue.Block
+	sideEffects *workqueue.Queue
+
+	// responses to recent queries, cleared when the state they
+	// read changes
+	queryCache *queryCache
+
+	// snapshots served to and restored from peers by state sync
+	sync stateSync
+
+	// trusted block to state sync from, or nil to accept any
+	// snapshot matching the light client's app hash
+	checkpoint *checkpoint
+
+	// authenticated app state committed to by the app hash, or
+	// nil if the chain commits to its block hash
+	appState *appStateTree
+
+	// app hash format the chain started with; see appHashBlock
+	hashFormat string
+
+	// journal of the txs in Tendermint's mempool, or nil if
+	// they are not journaled
+	txJournal *txJournal
+
+	// txs refused by DeliverTx for want of room in the block
+	// being processed, to broadcast again after Commit
+	blockFull [][]byte
+
+	// record of the block being processed, written ahead of
+	// Commit so that a commit cut short can be recovered
+	wal commitRecord
+
+	// when Commit waits for its writes to be flushed to disk
+	durability *durability
+
+	// files the app reads and writes; see UseFiles
+	files Files
+}
+
+// Files names the files an app reads and writes: its tx journal, as
+// TX_JOURNAL does, the genesis document it starts from, as
+// GENESIS_FILE does, and the staking state it imports, as
+// IMPORT_STAKING_STATE does. An empty name is no file.
+type Files struct {
+	TxJournal          string
+	Genesis            string
+	ImportStakingState string
+}
+
+// ConfiguredFiles returns the files named by the environment, which
+// only the default chain of a process uses.
+func ConfiguredFiles() Files {
+	return Files{
+		TxJournal:          *txJournalPath,
+		Genesis:            *genesisPath,
+		ImportStakingState: *importStatePath,
+	}
 }
 
-// NewChainmintApplication creates the abci application for Chainmint
-func NewChainmintApplication(strategy *cmtTypes.Strategy) *ChainmintApplication {
+// NewChainmintApplication creates the abci application for Chainmint.
+// If strategy is nil, Init selects the one the chain was created
+// with; see startStrategy.
+func NewChainmintApplication(strategy cmtTypes.Strategy) *ChainmintApplication {
 	app := &ChainmintApplication{
 		strategy:     strategy,
+		files:        ConfiguredFiles(),
 	}
 	return app
 }
 
+// UseFiles sets the files app reads and writes, in place of those
+// named by the environment, for a chain other than the default one
+// of its process. It must be called before Init.
+func (app *ChainmintApplication) UseFiles(f Files) {
+	app.files = f
+}
+
 func (app *ChainmintApplication) Init(backend *core.API/*, client *rpc.Client*/) {
 	app.backend = backend
 	app.currentState = backend.Chain().State
+
+	// A chain not yet started uses the default until its genesis
+	// names its provider; see loadGenesisFile.
+	cryptoName, err := loadCryptoProvider(context.Background(), backend.DB())
+	if err != nil {
+		log.Fatalkv(context.Background(), log.KeyError, err)
+	}
+	err = app.useCryptoProvider(cryptoName)
+	if err != nil {
+		log.Fatalkv(context.Background(), log.KeyError, err)
+	}
+
+	if app.strategy == nil {
+		// A chain not yet started uses the default until its
+		// genesis names its strategy; see loadGenesisFile.
+		name, err := loadStrategyName(context.Background(), backend.DB())
+		if err != nil {
+			log.Fatalkv(context.Background(), log.KeyError, err)
+		}
+		err = app.useStrategy(name)
+		if err != nil {
+			log.Fatalkv(context.Background(), log.KeyError, err)
+		}
+	}
+
+	app.admission = defaultAdmissionRules()
+	app.fees, err = configuredFeePolicy()
+	if err != nil {
+		log.Fatalkv(context.Background(), log.KeyError, err)
+	}
+	app.memoSchema, err = loadMemoSchema(*memoSchemaPath)
+	if err != nil {
+		log.Fatalkv(context.Background(), log.KeyError, err)
+	}
+	app.senders = newSenderLimiter(*senderTxRate, *senderTxBurst)
+	app.policies, err = configuredPolicies()
+	if err != nil {
+		log.Fatalkv(context.Background(), log.KeyError, err)
+	}
+	app.pending = newPendingSet(uint64(*replaceFeeBump))
+	app.checkpoint, err = configuredCheckpoint()
+	if err != nil {
+		log.Fatalkv(context.Background(), log.KeyError, err)
+	}
+	app.unbonding = defaultUnbondingPeriod
+	app.governance = newGovernance()
+	app.stake = newStakeBook()
+	app.queries = app.queryRoutes()
+	app.queryCache = newQueryCache(*queryCacheSize)
+	app.durability, err = configuredDurability()
+	if err != nil {
+		log.Fatalkv(context.Background(), log.KeyError, err)
+	}
+	policy, err := configuredSideEffectPolicy()
+	if err != nil {
+		log.Fatalkv(context.Background(), log.KeyError, err)
+	}
+	app.sideEffects = workqueue.New("side_effects", *sideEffectQueueSize, policy)
+	go app.sideEffects.Run(context.Background())
+
+	err = app.restoreStrategyState(context.Background())
+	if err != nil {
+		log.Fatalkv(context.Background(), log.KeyError, err)
+	}
+	err = app.recoverCommit(context.Background())
+	if err != nil {
+		log.Fatalkv(context.Background(), log.KeyError, err)
+	}
+	err = app.initAppState(context.Background())
+	if err != nil {
+		log.Fatalkv(context.Background(), log.KeyError, err)
+	}
+	app.checkUpgrades(context.Background())
+	backend.SetStateExporter(app.ExportState)
+
+	// Refuse writes until Tendermint, the chain, its indexes and
+	// the strategy checkpoint agree.
+	backend.StartReconciliation(context.Background(), func(ctx context.Context) (uint64, error) {
+		return strategyCheckpointHeight(ctx, backend.DB())
+	})
+
+	if app.files.TxJournal != "" {
+		app.txJournal, err = openTxJournal(app.files.TxJournal)
+		if err != nil {
+			log.Fatalkv(context.Background(), log.KeyError, err)
+		}
+		go app.rebroadcast(context.Background(), app.txJournal.pending())
+	}
 }
 
 // Info returns information about the last height and app_hash to the tendermint engine
-func (app *ChainmintApplication) Info() abciTypes.ResponseInfo {
-	log.Printf(context.Background(), "Info")
+func (app *ChainmintApplication) Info() (resp abciTypes.ResponseInfo) {
+	defer app.recoverABCI("Info", nil)
+	appLog.Debugkv(context.Background(), "request", "Info")
 	currentBlock, _ := app.currentState()
 	if currentBlock == nil {
 		return abciTypes.ResponseInfo{
@@ -58,8 +306,15 @@ func (app *ChainmintApplication) Info() abciTypes.ResponseInfo {
 			LastBlockAppHash: []byte{},
 		}
 	}
-	height := currentBlock.BlockHeight()
-	hash := currentBlock.Hash().Bytes()
+	// Tendermint's handshake compares the height with its own, which
+	// runs ahead of the chain's once it has made blocks with no txs;
+	// so it is the Tendermint height of the last commit, as restored
+	// from the strategy state, not the chain height.
+	height := app.height
+	hash := app.appHash(currentBlock.Hash().Bytes())
+	if app.appState != nil {
+		hash = app.appState.root().Bytes()
+	}
 
 	// This check determines whether it is the first time chainmint gets started.
 	// If it is the first time, then we have to respond with an empty hash, since
@@ -79,97 +334,580 @@ func (app *ChainmintApplication) Info() abciTypes.ResponseInfo {
 	}
 }
 
-// SetOption sets a configuration option
+// SetOption sets a configuration option. The options are those of
+// the chain state's snapshots and caches; see core.API.SetStateOption.
+// It returns a description of the error, if any.
 func (app *ChainmintApplication) SetOption(key string, value string) (log string) {
-	//log.Info("SetOption")
+	defer app.recoverABCI("SetOption", nil)
+	ok, err := app.setRateLimitOption(key, value)
+	if !ok {
+		err = app.backend.SetStateOption(key, value)
+	}
+	if err != nil {
+		return errors.Detail(err)
+	}
 	return ""
 }
 
 // InitChain initializes the validator set
 func (app *ChainmintApplication) InitChain(validators []*abciTypes.Validator) {
-	log.Printf(context.Background(), "InitChain")
+	defer app.recoverABCI("InitChain", nil)
+	appLog.Infokv(context.Background(), "request", "InitChain", "validators", len(validators))
 	//app.setvalidators(validators)
-	app.SetValidators(validators)
+	app.validators = validators
+	if app.files.Genesis != "" {
+		err := app.loadGenesisFile(context.Background(), app.files.Genesis, validators)
+		if err != nil {
+			log.Fatalkv(context.Background(), log.KeyError, err)
+		}
+	}
+	if app.files.ImportStakingState != "" {
+		err := app.importStateFile(app.files.ImportStakingState, validators)
+		if err != nil {
+			log.Fatalkv(context.Background(), log.KeyError, err)
+		}
+	}
+	app.SetValidators(app.validators)
 }
 
 // CheckTx checks a transaction is valid but does not mutate the state
-func (app *ChainmintApplication) CheckTx(txBytes []byte) abciTypes.Result {
+func (app *ChainmintApplication) CheckTx(txBytes []byte) (res abciTypes.Result) {
+	defer func(t0 time.Time) { checkTxSeconds.ObserveSince(t0, resultCode(res)) }(time.Now())
+	ctx, span := trace.Start(context.Background(), "abci.CheckTx")
+	defer func() { endABCISpan(span, res) }()
+	defer app.recoverABCI("CheckTx", func(err error) { res = panicResult(err) })
 	tx, err := decodeTx(txBytes)
-	log.Printf(context.Background(), "Received CheckTx", "tx", tx)
 	if err != nil {
+		checkTxLog.Debugkv(ctx, "request", "CheckTx", log.KeyError, err)
 		return abciTypes.ErrEncodingError.AppendLog(err.Error())
 	}
+	app.lastCheckTx = tx.ID
+	checkTxLog.Debugkv(ctx, "request", "CheckTx", "tx_id", tx.ID.HexString(), "size", len(txBytes))
+	span.SetAttributes("tx_id", tx.ID.HexString())
+	res = app.checkTx(ctx, tx)
+	app.journalCheckTx(tx, txBytes, res)
+	return res
+}
+
+func (app *ChainmintApplication) checkTx(ctx context.Context, tx *legacy.Tx) abciTypes.Result {
+	if !app.backend.Reconciled() {
+		return abciTypes.ErrInternalError.AppendLog("node state not reconciled; see /info")
+	}
+	// Tendermint rechecks its mempool after each block, which
+	// evicts the txs that have expired since they were admitted.
+	err := checkExpiry(tx, app.height+1, bc.Millis(time.Now()))
+	if err != nil {
+		return rejectionResult(err)
+	}
+	err = app.senders.allow(tx)
+	if err != nil {
+		return rateLimitedResult(err)
+	}
+	err = app.policies.Check(tx)
+	if err != nil {
+		return policyResult(err)
+	}
+	err = checkMemo(tx, app.backend.FeeAsset(), *maxMemoBytes, memoPrice())
+	if err != nil {
+		return rejectionResult(err)
+	}
+	err = checkMemoSchema(tx, app.memoSchema)
+	if err != nil {
+		return rejectionResult(err)
+	}
 
-	return app.validateTx(tx)
+	res := app.validateTx(ctx, tx)
+	if res.IsErr() {
+		return res
+	}
+	_, err = app.admitValidator(tx)
+	if err != nil {
+		return rejectionResult(err)
+	}
+	_, err = app.delegationDeltas(tx, bc.Millis(time.Now()))
+	if err != nil {
+		return rejectionResult(err)
+	}
+	err = app.checkConfidential(tx)
+	if err != nil {
+		return rejectionResult(err)
+	}
+	_, err = app.governanceAction(tx)
+	if err != nil {
+		return rejectionResult(err)
+	}
+
+	replaced, err := app.pending.add(tx, strategies.Fee(tx, app.backend.FeeAsset()))
+	if errors.Root(err) == ErrConflict {
+		return conflictResult(err)
+	} else if err != nil {
+		return rejectionResult(err)
+	}
+	if app.txJournal != nil {
+		for _, id := range replaced {
+			app.txJournal.remove(id)
+		}
+	}
+	return res
 }
 
 // DeliverTx executes a transaction against the latest state
-func (app *ChainmintApplication) DeliverTx(txBytes []byte) abciTypes.Result {
+func (app *ChainmintApplication) DeliverTx(txBytes []byte) (res abciTypes.Result) {
+	defer func(t0 time.Time) { deliverTxSeconds.ObserveSince(t0, resultCode(res)) }(time.Now())
+	ctx, span := trace.Start(context.Background(), "abci.DeliverTx", "height", app.height)
+	defer func() { endABCISpan(span, res) }()
+	defer app.recoverABCI("DeliverTx", nil)
 	tx, err := decodeTx(txBytes)
 	if err != nil {
 		return abciTypes.ErrEncodingError.AppendLog(err.Error())
 	}
+	app.lastDeliverTx = tx.ID
+	span.SetAttributes("tx_id", tx.ID.HexString())
+	app.senders.forget(tx.ID)
+
+	appLog.Debugkv(ctx, "request", "DeliverTx", "height", app.height, "tx_id", tx.ID.HexString())
+	maxTxs, maxBytes := app.backend.Chain().BlockLimits()
+	err = checkBlockRoom(app.wal.Txs, int64(len(txBytes)/2), maxTxs, maxBytes)
+	if err != nil {
+		// Tendermint drops the tx from its mempool all the same, so
+		// it stays journaled and is broadcast again after Commit.
+		app.blockFull = append(app.blockFull, txBytes)
+		return blockFullResult(err)
+	}
+	if app.txJournal != nil {
+		app.txJournal.remove(tx.ID) // out of the mempool, whatever the result
+	}
+	res = app.deliverTx(ctx, tx, true)
+	if res.IsOK() {
+		app.wal.Txs = append(app.wal.Txs, txBytes)
+	}
+	app.pending.remove(tx, res.IsOK())
+	return res
+}
 
-	log.Printf(context.Background(), "Got DeliverTx", "tx", tx)
-	app.backend.Generator().Submit(context.Background(), tx)
-	app.CollectTx(tx)
+// deliverTx applies tx to the validator, stake and governance state
+// of the block being processed and, if submit is true, delivers it
+// to the generator for inclusion in the chain block.
+func (app *ChainmintApplication) deliverTx(ctx context.Context, tx *legacy.Tx, submit bool) abciTypes.Result {
+	err := checkExpiry(tx, app.height, app.BlockTime)
+	if err != nil {
+		return rejectionResult(err)
+	}
+	v, err := app.admitValidator(tx)
+	if err != nil {
+		return rejectionResult(err)
+	}
+	deltas, err := app.delegationDeltas(tx, app.BlockTime)
+	if err != nil {
+		return rejectionResult(err)
+	}
+	err = app.checkConfidential(tx)
+	if err != nil {
+		return rejectionResult(err)
+	}
+	record, err := app.governanceAction(tx)
+	if err != nil {
+		return rejectionResult(err)
+	}
+	if submit {
+		deliverCtx, cancel := context.WithTimeout(ctx, *generatorTimeout)
+		err = app.backend.Generator().Deliver(deliverCtx, tx, app.BlockTime)
+		cancel()
+		if errors.Root(err) == generator.ErrUndeliverable {
+			return rejectionResult(err)
+		} else if err != nil {
+			// An error here, such as a timeout, is this node's and
+			// not the tx's, so any result would differ from other
+			// nodes'. Halt, and have Tendermint replay the block.
+			log.Fatalkv(ctx, log.KeyError, errors.Wrap(err, "delivering tx"))
+		}
+	}
+	app.CollectFee(tx)
+	if v != nil {
+		app.admitted = append(app.admitted, v)
+		reg, _ := parseRegistration(tx)
+		app.stake.Commissions[hex.EncodeToString(v.PubKey)] = reg.Commission
+		app.SetCommission(v.PubKey, reg.Commission)
+	}
+	if app.delegations == nil {
+		app.delegations = make(map[string]*powerDelta)
+	}
+	addDeltas(app.delegations, deltas)
+	app.stake.applyTx(tx, app.admission.BondAsset)
+	if record != nil {
+		record()
+	}
 
 	return abciTypes.OK
 }
 
 // BeginBlock starts a new chain block
 func (app *ChainmintApplication) BeginBlock(hash []byte, tmHeader *abciTypes.Header) {
-	log.Printf(context.Background(), "BeginBlock")
+	defer app.recoverABCI("BeginBlock", nil)
+	appLog.Debugkv(context.Background(), "request", "BeginBlock", "height", tmHeader.Height)
 	app.BlockTime = tmHeader.Time
+	app.height = tmHeader.Height
+	app.checkUpgrades(context.Background())
+	app.wal = commitRecord{
+		Height: app.height,
+		Time:   app.BlockTime,
+	}
 }
 
 // EndBlock accumulates rewards for the validators and updates them
 func (app *ChainmintApplication) EndBlock(height uint64) abciTypes.ResponseEndBlock {
-	log.Printf(context.Background(), "EndBlock")
-	return app.GetUpdatedValidators()
+	defer app.recoverABCI("EndBlock", nil)
+	appLog.Debugkv(context.Background(), "request", "EndBlock", "height", height)
+	app.SetDelegations(app.stake.delegations())
+	rewards := app.Distribute(cmtTypes.BlockInfo{Height: height})
+	for _, r := range rewards {
+		log.Printkv(context.Background(), "at", "validator reward", "height", height, "pubkey", hex.EncodeToString(r.PubKey), "amount", r.Amount, "delegators", len(r.Delegators))
+	}
+	app.stake.accrue(rewards)
+	resp := app.GetUpdatedValidators()
+	resp.Diffs = mergeDiffs(resp.Diffs, app.admitted)
+	resp.Diffs = mergeDiffs(resp.Diffs, delegationDiffs(app.pendingValidators(resp.Diffs), app.delegations))
+	app.admitted = nil
+	app.delegations = nil
+	for _, p := range app.governance.endBlock(height, app.validators) {
+		app.activateProposal(p)
+	}
+	resp.Diffs = app.epoch.release(height, resp.Diffs)
+	app.validators = applyValidatorDiffs(app.validators, resp.Diffs)
+	validatorUpdates.Add(float64(len(resp.Diffs)))
+	if len(resp.Diffs) > 0 {
+		app.SetValidators(app.validators)
+		event := &pubsub.Event{
+			Type:   pubsub.TypeValidatorUpdate,
+			Height: height,
+			Data:   exportValidators(resp.Diffs),
+		}
+		app.sideEffects.Add(&workqueue.Job{
+			Name:     "publish validator update",
+			Priority: workqueue.Low,
+			Run: func(context.Context) error {
+				app.backend.Events().Publish(event)
+				return nil
+			},
+		})
+	}
+	return resp
 }
 
 // Commit commits the block and returns a hash of the current state
-func (app *ChainmintApplication) Commit() abciTypes.Result {
-	log.Printf(context.Background(), "Commit")
-	err, blockHash := app.backend.Generator().MakeBlock(context.Background(), app.BlockTime)
+func (app *ChainmintApplication) Commit() (res abciTypes.Result) {
+	appLog.Debugkv(context.Background(), "request", "Commit", "height", app.height)
+	defer commitSeconds.ObserveSince(time.Now())
+	ctx, timings := trace.WithTimings(context.Background())
+	defer logSlowCommit(ctx, app.height, len(app.wal.Txs), timings)
+	ctx, span := trace.Start(ctx, "abci.Commit", "height", app.height)
+	defer func() { endABCISpan(span, res) }()
+	defer app.recoverABCI("Commit", nil)
+
+	// The writes before finishCommit's don't wait to be flushed to
+	// disk; finishCommit's flushes them all; see durability.
+	asyncCtx := sql.WithAsyncCommit(ctx)
+	app.wal.ChainHeight = app.backend.Chain().Height()
+	walCtx, walSpan := trace.Start(asyncCtx, "app.saveCommitRecord")
+	err := saveCommitRecord(walCtx, app.backend.DB(), &app.wal)
+	walSpan.SetError(err)
+	walSpan.End()
+	// A block that could not be committed in full must not be
+	// answered with an app hash, which would be a hash of whatever
+	// state this node was left with. The node halts instead, and
+	// Tendermint replays the block when it restarts.
+	if err != nil {
+		log.Fatalkv(ctx, log.KeyError, errors.Wrap(err, "saving commit record"))
+	}
+	// GENERATOR_TIMEOUT bounds making the block, not a pause of
+	// block production, which holds Commit until resumed.
+	err = app.backend.Generator().WaitResumed(ctx)
 	if err != nil {
-		log.Error(context.Background(), err)
+		log.Fatalkv(ctx, log.KeyError, errors.Wrap(err, "waiting for block production"))
 	}
-	return abciTypes.NewResultOK(blockHash[:], "")
+	makeCtx, cancel := context.WithTimeout(asyncCtx, *generatorTimeout)
+	err, blockHash := app.backend.Generator().MakeBlock(makeCtx, app.BlockTime)
+	cancel()
+	if err != nil {
+		log.Fatalkv(ctx, log.KeyError, errors.Wrap(err, "making block"))
+	}
+	finishCtx, finishSpan := trace.Start(ctx, "app.finishCommit")
+	st, err := app.finishCommit(finishCtx)
+	finishSpan.SetError(err)
+	finishSpan.End()
+	if err != nil {
+		log.Fatalkv(ctx, log.KeyError, errors.Wrap(err, "finishing commit"))
+	}
+	b, _ := app.currentState()
+	_, hashSpan := trace.Start(ctx, "app.commitHash")
+	appHash, err := app.commitHash(b, st, blockHash)
+	hashSpan.SetError(err)
+	hashSpan.End()
+	if err != nil {
+		log.Fatalkv(ctx, log.KeyError, errors.Wrap(err, "computing app hash"))
+	}
+	app.queryCache.clear()
+	// The holder index catches up on every block committed
+	// since it last ran, so a queued run covers this block too.
+	app.sideEffects.Add(&workqueue.Job{
+		Name:     "index asset holders",
+		Key:      "index asset holders",
+		Priority: workqueue.High,
+		Run: slowIndexLogged("index asset holders", app.height, func(ctx context.Context) error {
+			height, err := app.indexHolders(ctx)
+			app.queryCache.clear()
+			if err != nil {
+				return err
+			}
+			return app.checkpointBalances(ctx, height)
+		}),
+	})
+	app.sideEffects.Add(&workqueue.Job{
+		Name:     "index metadata",
+		Key:      "index metadata",
+		Priority: workqueue.High,
+		Run:      slowIndexLogged("index metadata", app.height, app.clearingQueryCache(app.indexMetadata)),
+	})
+	app.sideEffects.Add(&workqueue.Job{
+		Name:     "index asset supply",
+		Key:      "index asset supply",
+		Priority: workqueue.High,
+		Run:      slowIndexLogged("index asset supply", app.height, app.clearingQueryCache(app.indexSupply)),
+	})
+	if len(app.blockFull) > 0 {
+		// Tendermint holds its mempool until Commit returns.
+		go app.rebroadcast(context.Background(), app.blockFull)
+		app.blockFull = nil
+	}
+	return abciTypes.NewResultOK(appHash, "")
 }
 
-// Query queries the state of ChainmintApplication
-func (app *ChainmintApplication) Query(query abciTypes.RequestQuery) abciTypes.ResponseQuery {
-	log.Printf(context.Background(), "Query")
-	client := &rpc.Client{
-						BaseURL: *coreURL,
-						Client: app.backend.HttpClient(),
-						}
-	var in jsonRequest
-	if err := json.Unmarshal(query.Data, &in); err != nil {
-		return abciTypes.ResponseQuery{Code: abciTypes.ErrEncodingError.Code, Log: err.Error()}
+// Query queries the state of ChainmintApplication. Each path is
+// answered in-process by a handler registered in queryRoutes, or,
+// for /batch, by answering each query in the batch.
+func (app *ChainmintApplication) Query(query abciTypes.RequestQuery) (resp abciTypes.ResponseQuery) {
+	defer func(t0 time.Time) { logSlowQuery(query, resp, time.Since(t0)) }(time.Now())
+	defer app.recoverABCI("Query", func(err error) {
+		resp = abciTypes.ResponseQuery{Code: abciTypes.CodeType_InternalError, Log: err.Error()}
+	})
+	appLog.Debugkv(context.Background(), "request", "Query", "path", query.Path)
+	if query.Path == batchQueryPath {
+		return app.routeBatch(query)
 	}
-	var result map[string]interface{}
-	if err := client.Call(context.Background(), query.Path, in, &result); err != nil {
-		return abciTypes.ResponseQuery{Code: abciTypes.ErrInternalError.Code, Log: err.Error()}
+	return app.route(query)
+}
+
+// endABCISpan ends span, the span of an ABCI request, which
+// returned res.
+func endABCISpan(span *trace.Span, res abciTypes.Result) {
+	span.SetAttributes("code", resultCode(res))
+	if res.IsErr() {
+		span.SetError(errors.New(res.Log))
 	}
+	span.End()
+}
+
+//-------------------------------------------------------
 
-	bytes, err := json.Marshal(result)
+// persistStrategyState saves the validator set and strategy
+// bookkeeping for the current height, so that they survive
+// a restart. It returns the state it saved, even if saving
+// it failed, unless it could not be marshaled.
+func (app *ChainmintApplication) persistStrategyState(ctx context.Context, db pg.DB) (*strategyState, error) {
+	st := &strategyState{
+		Height:      app.height,
+		Validators:  app.validators,
+		ChainHeight: app.backend.Chain().Height(),
+	}
+	governance, err := json.Marshal(app.governance)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshaling governance state")
+	}
+	st.Governance = governance
+	st.Stake, err = json.Marshal(app.stake)
 	if err != nil {
-		return abciTypes.ResponseQuery{Code: abciTypes.ErrInternalError.Code, Log: err.Error()}
+		return nil, errors.Wrap(err, "marshaling stake state")
 	}
-	return abciTypes.ResponseQuery{Code: abciTypes.OK.Code, Value: bytes}
+	st.PendingDiffs = app.epoch.pending
+	if ps, ok := app.strategy.(cmtTypes.PersistentStrategy); ok {
+		st.Data, err = ps.MarshalState()
+		if err != nil {
+			return nil, errors.Wrap(err, "marshaling strategy state")
+		}
+	}
+	return st, saveStrategyState(ctx, db, st)
 }
 
-//-------------------------------------------------------
+// restoreStrategyState reloads the validator set and strategy
+// bookkeeping saved by the last Commit before a restart.
+func (app *ChainmintApplication) restoreStrategyState(ctx context.Context) error {
+	st, err := loadStrategyState(ctx, app.backend.DB())
+	if err != nil {
+		return errors.Wrap(err, "loading strategy state")
+	}
+	if st == nil {
+		return nil // fresh chain
+	}
+	app.height = st.Height
+	app.validators = st.Validators
+	app.epoch.pending = st.PendingDiffs
+	app.SetValidators(st.Validators)
+	if ps, ok := app.strategy.(cmtTypes.PersistentStrategy); ok && len(st.Data) > 0 {
+		err = ps.UnmarshalState(st.Data)
+		if err != nil {
+			return errors.Wrap(err, "restoring strategy state")
+		}
+	}
+	if len(st.Governance) > 0 {
+		err = json.Unmarshal(st.Governance, app.governance)
+		if err != nil {
+			return errors.Wrap(err, "restoring governance state")
+		}
+		app.applyChanges(&app.governance.Parameters, &app.governance.Policy)
+		for _, u := range app.governance.Upgrades {
+			err = app.scheduleUpgrade(ctx, u.Name, u.Height)
+			if err != nil {
+				return errors.Wrap(err, "restoring upgrade schedule")
+			}
+		}
+	}
+	if len(st.Stake) > 0 {
+		err = json.Unmarshal(st.Stake, app.stake)
+		if err != nil {
+			return errors.Wrap(err, "restoring stake state")
+		}
+		for v, rate := range app.stake.Commissions {
+			pubkey, _ := hex.DecodeString(v)
+			app.SetCommission(pubkey, rate)
+		}
+	}
+	log.Printkv(ctx, "at", "restored strategy state", "height", st.Height, "validators", len(st.Validators))
+	return nil
+}
+
+// admitValidator checks a validator registration carried by tx
+// against the admission rules. It returns the validator to add,
+// or nil if tx is not a registration.
+func (app *ChainmintApplication) admitValidator(tx *legacy.Tx) (*abciTypes.Validator, error) {
+	reg, err := parseRegistration(tx)
+	if err != nil || reg == nil {
+		return nil, err
+	}
+	return app.admission.admit(tx, reg, app.pendingValidators(app.admitted), app.crypto)
+}
+
+// delegationDeltas returns the validator power changes made by
+// tx, checking undelegations against the given time.
+func (app *ChainmintApplication) delegationDeltas(tx *legacy.Tx, timeMS uint64) (map[string]*powerDelta, error) {
+	unbondingMS := bc.DurationMillis(app.unbonding)
+	return delegationDeltas(tx, app.admission.BondAsset, app.pendingValidators(app.admitted), timeMS, unbondingMS)
+}
+
+// governanceAction checks the governance proposal or vote carried
+// by tx, and returns a function that records it in the block being
+// processed. Both are nil if tx is an ordinary transaction.
+func (app *ChainmintApplication) governanceAction(tx *legacy.Tx) (func(), error) {
+	req, v, err := parseGovernance(tx)
+	if err != nil {
+		return nil, err
+	}
+	height := app.height
+	switch {
+	case req != nil:
+		// An upgrade must activate after the proposal could, which
+		// is at most this many chain blocks away.
+		if u := req.Upgrade; u != nil && u.Height <= app.backend.Chain().Height()+app.governance.VotingPeriod+app.governance.ActivationDelay {
+			return nil, errors.WithDetailf(ErrBadProposal, "upgrade height %d is before the proposal could activate", u.Height)
+		}
+		return func() {
+			p := app.governance.propose(tx.ID, req, height)
+			log.Printkv(context.Background(), "at", "governance proposal", "id", p.ID, "kind", req.Kind, "deadline", p.Deadline, "activation", p.Activation)
+		}, nil
+	case v != nil:
+		p, err := app.governance.checkVote(tx, v, app.backend.Chain().InitialBlockHash, height, app.validators, app.crypto)
+		if err != nil {
+			return nil, err
+		}
+		return func() { p.recordVote(v) }, nil
+	}
+	return nil, nil
+}
+
+// activateProposal makes the changes of an accepted proposal at
+// its activation height. All of a proposal's changes take effect
+// in the same block.
+func (app *ChainmintApplication) activateProposal(p *proposal) {
+	ctx := context.Background()
+	req := p.Request
+	switch req.Kind {
+	case proposeSoftwareUpgrade:
+		log.Printkv(ctx, "at", "software upgrade accepted", "id", p.ID, "name", req.Upgrade.Name, "height", req.Upgrade.Height)
+		err := app.scheduleUpgrade(ctx, req.Upgrade.Name, req.Upgrade.Height)
+		if err != nil {
+			log.Error(ctx, err, "at", "scheduling upgrade", "id", p.ID)
+		}
+	default:
+		params, policy := req.Parameters, req.Policy
+		if params == nil {
+			params = new(paramChanges)
+		}
+		if policy == nil {
+			policy = new(policyChanges)
+		}
+		app.applyChanges(params, policy)
+		log.Printkv(ctx, "at", "governance proposal activated", "id", p.ID, "kind", req.Kind)
+	}
+	app.governance.accept(p)
+}
+
+// applyChanges applies changes to the chain parameters and
+// validator admission rules.
+func (app *ChainmintApplication) applyChanges(params *paramChanges, policy *policyChanges) {
+	if params.UnbondingPeriodMS != nil {
+		app.unbonding = bc.MillisDuration(*params.UnbondingPeriodMS)
+	}
+	if params.EpochLength != nil {
+		app.epoch.length = *params.EpochLength
+	}
+	app.applyBlockLimits(params)
+	app.admission = policy.apply(app.admission)
+}
+
+// pendingValidators returns the validator set that results from
+// applying the diffs held back for the epoch and then diffs to the
+// current set, leaving the current set unchanged.
+func (app *ChainmintApplication) pendingValidators(diffs []*abciTypes.Validator) []*abciTypes.Validator {
+	vals := make([]*abciTypes.Validator, len(app.validators))
+	copy(vals, app.validators)
+	vals = applyValidatorDiffs(vals, app.epoch.pending)
+	return applyValidatorDiffs(vals, diffs)
+}
 
 // validateTx checks the validity of a tx against the blockchain's current state.
 // it duplicates the logic in chain's tx_pool
-func (app *ChainmintApplication) validateTx(tx *legacy.Tx) abciTypes.Result {
+func (app *ChainmintApplication) validateTx(ctx context.Context, tx *legacy.Tx) abciTypes.Result {
+	_, span := trace.Start(ctx, "app.validateTx")
+	defer span.End()
 	err := app.backend.Chain().ValidateTx(tx.Tx)
 	if err != nil {
-		return abciTypes.ErrUnknownRequest.AppendLog(errors.Detail(err))
+		span.SetError(err)
+		return rejectionResult(err)
+	}
+	err = app.fees.check(tx, app.backend.FeeAsset())
+	if err != nil {
+		return rejectionResult(err)
 	}
 	return abciTypes.OK
 }
+
+// configuredSideEffectPolicy returns the side-effect queue's policy
+// set by the environment.
+func configuredSideEffectPolicy() (workqueue.Policy, error) {
+	switch *sideEffectQueuePolicy {
+	case "drop":
+		return workqueue.DropOldest, nil
+	case "block":
+		return workqueue.Block, nil
+	}
+	return 0, errors.New("invalid SIDE_EFFECT_QUEUE_POLICY " + *sideEffectQueuePolicy + ", want drop or block")
+}
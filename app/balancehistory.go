@@ -0,0 +1,203 @@
+package app
+
+import (
+	"context"
+	"math"
+	"strconv"
+
+	"github.com/chainmint/database/pg"
+	"github.com/chainmint/database/sql"
+	"github.com/chainmint/env"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+)
+
+// checkpointInterval is how many blocks apart account balances are
+// checkpointed for the balance history query. 0 disables
+// checkpoints.
+var checkpointInterval = env.Int("BALANCE_CHECKPOINT_INTERVAL", 100)
+
+// checkpointsProcessor names the block processor that records the
+// height of the latest balance checkpoint.
+const checkpointsProcessor = "balance_checkpoints"
+
+// maxHistoryPoints bounds the checkpoints returned by one balance
+// history query.
+const maxHistoryPoints = 1000
+
+// checkpointDue reports whether balances should be checkpointed at
+// height, given the height of the last checkpoint.
+func checkpointDue(last, height, interval uint64) bool {
+	return interval > 0 && height > 0 && height >= last+interval
+}
+
+// checkpointBalances records the balance of each asset in each
+// account at height, if a checkpoint is due. It reads the asset
+// holder index, so height must be the one returned by indexHolders.
+func (app *ChainmintApplication) checkpointBalances(ctx context.Context, height uint64) error {
+	if *checkpointInterval <= 0 {
+		return nil
+	}
+	db := app.backend.DB()
+	const lastQ = `
+		INSERT INTO block_processors (name, height) VALUES ($1, 0)
+		ON CONFLICT (name) DO UPDATE SET name = excluded.name
+		RETURNING height
+	`
+	var last uint64
+	err := db.QueryRow(ctx, lastQ, checkpointsProcessor).Scan(&last)
+	if err != nil {
+		return errors.Wrap(err, "reading balance checkpoint height")
+	}
+	if !checkpointDue(last, height, uint64(*checkpointInterval)) {
+		return nil
+	}
+	b, err := app.backend.Chain().GetBlock(ctx, height)
+	if err != nil {
+		return errors.Wrapf(err, "loading block %d", height)
+	}
+	return saveCheckpoint(ctx, db, height, b.TimestampMS)
+}
+
+// saveCheckpoint copies the account balances in the asset holder
+// index into a checkpoint at height, in a single database
+// transaction when the database supports it.
+func saveCheckpoint(ctx context.Context, db pg.DB, height, timestampMS uint64) (err error) {
+	if beginner, ok := db.(interface {
+		Begin(context.Context) (*sql.Tx, error)
+	}); ok {
+		var dbtx *sql.Tx
+		dbtx, err = beginner.Begin(ctx)
+		if err != nil {
+			return errors.Wrap(err, "begin balance checkpoint transaction")
+		}
+		defer func() {
+			if err != nil {
+				dbtx.Rollback(ctx)
+				return
+			}
+			err = errors.Wrap(dbtx.Commit(ctx), "commit balance checkpoint transaction")
+		}()
+		db = dbtx
+	}
+
+	const heightQ = `UPDATE block_processors SET height = $1 WHERE height < $1 AND name = $2`
+	res, err := db.Exec(ctx, heightQ, height, checkpointsProcessor)
+	if err != nil {
+		return errors.Wrap(err, "advancing balance checkpoint height")
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return errors.Wrap(err)
+	}
+
+	const blockQ = `INSERT INTO balance_checkpoint_blocks (height, timestamp) VALUES ($1, $2)`
+	_, err = db.Exec(ctx, blockQ, height, timestampMS)
+	if err != nil {
+		return errors.Wrap(err, "balance_checkpoint_blocks insert query")
+	}
+	const balancesQ = `
+		INSERT INTO balance_checkpoints (height, account_id, asset_id, amount)
+		SELECT $1, acp.signer_id, h.asset_id, SUM(h.amount)
+		FROM asset_holders AS h
+		JOIN account_control_programs AS acp ON acp.control_program = h.control_program
+		GROUP BY acp.signer_id, h.asset_id
+	`
+	_, err = db.Exec(ctx, balancesQ, height)
+	return errors.Wrap(err, "balance_checkpoints insert query")
+}
+
+// balancePoint is an account's balances at one checkpoint. Assets
+// the account held none of are left out.
+type balancePoint struct {
+	Height      uint64          `json:"height"`
+	TimestampMS uint64          `json:"timestamp"`
+	Balances    []*assetBalance `json:"balances"`
+}
+
+type assetBalance struct {
+	AssetID bc.AssetID `json:"asset_id"`
+	Amount  uint64     `json:"amount"`
+}
+
+// balanceHistory returns the balances of an account at up to limit
+// checkpoints from start through end, oldest first. It also returns
+// the height to start the next page from, or 0 if there is none.
+func balanceHistory(ctx context.Context, db pg.DB, accountID string, start, end uint64, limit int) ([]*balancePoint, uint64, error) {
+	const q = `
+		WITH blocks AS (
+			SELECT height, timestamp FROM balance_checkpoint_blocks
+			WHERE height >= $2 AND height <= $3
+			ORDER BY height LIMIT $4
+		)
+		SELECT b.height, b.timestamp, c.asset_id, COALESCE(c.amount, 0)
+		FROM blocks AS b
+		LEFT JOIN balance_checkpoints AS c ON c.height = b.height AND c.account_id = $1
+		ORDER BY b.height, c.asset_id
+	`
+	var points []*balancePoint
+	err := pg.ForQueryRows(ctx, db, q, accountID, start, end, limit+1, func(height, timestampMS uint64, assetID []byte, amount uint64) {
+		if len(points) == 0 || points[len(points)-1].Height != height {
+			points = append(points, &balancePoint{Height: height, TimestampMS: timestampMS, Balances: []*assetBalance{}})
+		}
+		if assetID == nil {
+			return // no balances at this checkpoint
+		}
+		var id [32]byte
+		copy(id[:], assetID)
+		p := points[len(points)-1]
+		p.Balances = append(p.Balances, &assetBalance{AssetID: bc.AssetID(bc.NewHash(id)), Amount: amount})
+	})
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "balance history query")
+	}
+	var next uint64
+	if len(points) > limit {
+		next = points[limit].Height
+		points = points[:limit]
+	}
+	return points, next, nil
+}
+
+// queryBalanceHistory answers a query for the balance history of
+// the account named in the path, given as {"start_height": <h>,
+// "end_height": <h>, "page_size": <n>, "after": <cursor>}. Balances
+// are checkpointed every BALANCE_CHECKPOINT_INTERVAL blocks; the
+// history has a point for each checkpoint in the range.
+func (app *ChainmintApplication) queryBalanceHistory(ctx context.Context, data []byte) (interface{}, error) {
+	var in struct {
+		StartHeight uint64 `json:"start_height"`
+		EndHeight   uint64 `json:"end_height"`
+		PageSize    int    `json:"page_size"`
+		After       string `json:"after"`
+	}
+	err := decodeQuery(data, &in)
+	if err != nil {
+		return nil, err
+	}
+	if in.EndHeight == 0 {
+		in.EndHeight = math.MaxInt64
+	}
+	if in.EndHeight > math.MaxInt64 || in.StartHeight > in.EndHeight {
+		return nil, errors.WithDetail(errBadQuery, "invalid height range")
+	}
+	if in.After != "" {
+		in.StartHeight, err = strconv.ParseUint(in.After, 10, 64)
+		if err != nil {
+			return nil, errors.WithDetail(errBadQuery, "invalid after")
+		}
+	}
+	if in.PageSize <= 0 || in.PageSize > maxHistoryPoints {
+		in.PageSize = maxHistoryPoints
+	}
+
+	points, next, err := balanceHistory(ctx, app.backend.DB(), pathParam(ctx, "id"), in.StartHeight, in.EndHeight, in.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	page := queryPage{Items: points, LastPage: next == 0}
+	if next != 0 {
+		page.Next = strconv.FormatUint(next, 10)
+	}
+	return page, nil
+}
@@ -0,0 +1,23 @@
+package app
+
+import "testing"
+
+func TestCheckpointDue(t *testing.T) {
+	cases := []struct {
+		last, height, interval uint64
+		want                   bool
+	}{
+		{0, 0, 100, false},
+		{0, 99, 100, false},
+		{0, 100, 100, true},
+		{100, 150, 100, false},
+		{100, 250, 100, true}, // catching up after missed commits
+		{0, 100, 0, false},    // disabled
+	}
+	for _, c := range cases {
+		got := checkpointDue(c.last, c.height, c.interval)
+		if got != c.want {
+			t.Errorf("checkpointDue(%d, %d, %d) = %v, want %v", c.last, c.height, c.interval, got, c.want)
+		}
+	}
+}
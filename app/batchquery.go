@@ -0,0 +1,65 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/chainmint/env"
+	abciTypes "github.com/tendermint/abci/types"
+)
+
+// batchQueryPath is the path of a query whose data is an array of
+// queries, answered together to save clients a round trip for each.
+const batchQueryPath = "/batch"
+
+// maxBatchQueries bounds the queries in one batch.
+var maxBatchQueries = env.Int("MAX_BATCH_QUERIES", 100)
+
+// batchQuery is a query in a batch, as {"path": <path>, "data":
+// <query data>}. Data is the JSON request data the query would be
+// sent alone.
+type batchQuery struct {
+	Path string          `json:"path"`
+	Data json.RawMessage `json:"data"`
+}
+
+// batchQueryResult is the response to a query in a batch. Code and
+// Log are as for the query's ABCI response, and Value, if the query
+// succeeded, is its response.
+type batchQueryResult struct {
+	Code  abciTypes.CodeType `json:"code"`
+	Log   string             `json:"log,omitempty"`
+	Value json.RawMessage    `json:"value,omitempty"`
+}
+
+// routeBatch answers a batch query, whose data is a JSON array of
+// batchQuery, with the results of its queries in the same order.
+// Each query is answered as route would answer it alone, using and
+// filling the query cache; one failing does not fail the others.
+func (app *ChainmintApplication) routeBatch(query abciTypes.RequestQuery) abciTypes.ResponseQuery {
+	var queries []batchQuery
+	err := json.Unmarshal(query.Data, &queries)
+	if err != nil {
+		return abciTypes.ResponseQuery{Code: abciTypes.ErrEncodingError.Code, Log: err.Error()}
+	}
+	if *maxBatchQueries > 0 && len(queries) > *maxBatchQueries {
+		log := fmt.Sprintf("%d queries in batch, limit %d", len(queries), *maxBatchQueries)
+		return abciTypes.ResponseQuery{Code: abciTypes.ErrEncodingError.Code, Log: log}
+	}
+
+	results := make([]batchQueryResult, len(queries))
+	for i, q := range queries {
+		if q.Path == batchQueryPath {
+			results[i] = batchQueryResult{Code: abciTypes.ErrEncodingError.Code, Log: "batch queries cannot be nested"}
+			continue
+		}
+		resp := app.route(abciTypes.RequestQuery{Path: q.Path, Data: q.Data})
+		results[i] = batchQueryResult{Code: resp.Code, Log: resp.Log, Value: resp.Value}
+	}
+
+	bytes, err := json.Marshal(queryResponse{Version: queryVersion, Path: query.Path, Data: results})
+	if err != nil {
+		return abciTypes.ResponseQuery{Code: abciTypes.ErrInternalError.Code, Log: err.Error()}
+	}
+	return abciTypes.ResponseQuery{Code: abciTypes.OK.Code, Value: bytes}
+}
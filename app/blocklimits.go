@@ -0,0 +1,60 @@
+package app
+
+import (
+	"github.com/chainmint/errors"
+	"github.com/chainmint/errors/errcode"
+	abciTypes "github.com/tendermint/abci/types"
+)
+
+// CodeBlockFull is the DeliverTx result code of a transaction that
+// would take its block past the block limits. Unlike the codes of
+// other rejections it says nothing against the transaction, which
+// the node broadcasts again after Commit, for a later block.
+const CodeBlockFull abciTypes.CodeType = 1000
+
+// ErrBlockFull is returned for a transaction that does not fit in
+// the block being processed.
+var ErrBlockFull = errors.New("block is full")
+
+// checkBlockRoom checks that a transaction of size serialized bytes
+// fits in the block being processed after the txs, hex-encoded, that
+// the block already holds. The limits are the chain's, set by its
+// genesis and by governance and otherwise the protocol defaults, and
+// they also bound the chain blocks the generator makes from them.
+func checkBlockRoom(txs [][]byte, size int64, maxTxs int, maxBytes int64) error {
+	if len(txs) >= maxTxs {
+		return errors.WithDetailf(ErrBlockFull, "block has %d transactions, the most allowed", len(txs))
+	}
+	if maxBytes <= 0 {
+		return nil
+	}
+	var used int64
+	for _, tx := range txs {
+		used += int64(len(tx) / 2)
+	}
+	if used+size > maxBytes {
+		return errors.WithDetailf(ErrBlockFull, "block has %d of %d bytes, transaction has %d", used, maxBytes, size)
+	}
+	return nil
+}
+
+// blockFullResult is the DeliverTx result for err, an ErrBlockFull.
+func blockFullResult(err error) abciTypes.Result {
+	return abciTypes.NewError(CodeBlockFull, resultLog(err, errcode.BlockFull))
+}
+
+// applyBlockLimits changes the chain's block limits set in params.
+func (app *ChainmintApplication) applyBlockLimits(params *paramChanges) {
+	if params.MaxBlockTxs == nil && params.MaxBlockBytes == nil {
+		return
+	}
+	c := app.backend.Chain()
+	maxTxs, maxBytes := c.BlockLimits()
+	if params.MaxBlockTxs != nil {
+		maxTxs = int(*params.MaxBlockTxs)
+	}
+	if params.MaxBlockBytes != nil {
+		maxBytes = int64(*params.MaxBlockBytes)
+	}
+	c.SetBlockLimits(maxTxs, maxBytes)
+}
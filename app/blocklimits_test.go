@@ -0,0 +1,28 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/chainmint/errors"
+)
+
+func TestCheckBlockRoom(t *testing.T) {
+	txs := [][]byte{[]byte("0011"), []byte("223344")} // 2 and 3 bytes
+	cases := []struct {
+		size     int64
+		maxTxs   int
+		maxBytes int64
+		full     bool
+	}{
+		{size: 10, maxTxs: 3, maxBytes: 0, full: false},
+		{size: 1, maxTxs: 2, maxBytes: 0, full: true},
+		{size: 5, maxTxs: 3, maxBytes: 10, full: false},
+		{size: 6, maxTxs: 3, maxBytes: 10, full: true},
+	}
+	for i, c := range cases {
+		err := checkBlockRoom(txs, c.size, c.maxTxs, c.maxBytes)
+		if full := errors.Root(err) == ErrBlockFull; full != c.full || (err != nil && !full) {
+			t.Errorf("%d: checkBlockRoom = %v, want full %v", i, err, c.full)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package app
+
+import (
+	"encoding/hex"
+
+	"github.com/chainmint/env"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+var (
+	checkpointHeight    = env.Int("CHECKPOINT_HEIGHT", 0) // 0 syncs from any snapshot the light client trusts
+	checkpointBlockHash = env.String("CHECKPOINT_BLOCK_HASH", "")
+	checkpointStateRoot = env.String("CHECKPOINT_STATE_ROOT", "")
+	checkpointAppHash   = env.String("CHECKPOINT_APP_HASH", "") // hex; empty leaves the strategy state unchecked by SyncFrom
+)
+
+// ErrCheckpointMismatch is returned when a snapshot received for
+// state sync does not match the trusted checkpoint.
+var ErrCheckpointMismatch = errors.New("snapshot does not match the trusted checkpoint")
+
+// checkpoint is a block the operator trusts, with the state root
+// after it. A node syncing with a checkpoint restores only the state
+// snapshot at its height, and checks the snapshot's block and state
+// against it. The blocks before it are checked by their headers
+// alone, which must chain from the initial block to the checkpoint;
+// the blocks after it are validated in full as they are replayed.
+//
+// AppHash, if set, is the app hash committed after the checkpoint
+// block, which SyncFrom checks the snapshot against.
+type checkpoint struct {
+	Height    uint64
+	BlockHash bc.Hash
+	StateRoot bc.Hash
+	AppHash   []byte
+}
+
+// configuredCheckpoint returns the checkpoint set by the
+// environment, or nil if there is none.
+func configuredCheckpoint() (*checkpoint, error) {
+	if *checkpointHeight <= 0 {
+		return nil, nil
+	}
+	cp := &checkpoint{Height: uint64(*checkpointHeight)}
+	err := cp.BlockHash.UnmarshalText([]byte(*checkpointBlockHash))
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing CHECKPOINT_BLOCK_HASH")
+	}
+	err = cp.StateRoot.UnmarshalText([]byte(*checkpointStateRoot))
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing CHECKPOINT_STATE_ROOT")
+	}
+	cp.AppHash, err = hex.DecodeString(*checkpointAppHash)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing CHECKPOINT_APP_HASH")
+	}
+	return cp, nil
+}
+
+// verify checks that the snapshot block b, with initial block
+// initial and the headers of the blocks between them, is the
+// checkpoint block, and that its header chains from initial.
+func (cp *checkpoint) verify(initial, b *legacy.Block, headers []*legacy.BlockHeader) error {
+	if b.Height != cp.Height {
+		return errors.WithDetailf(ErrCheckpointMismatch, "snapshot height %d, checkpoint height %d", b.Height, cp.Height)
+	}
+	if h := b.Hash(); h != cp.BlockHash {
+		return errors.WithDetailf(ErrCheckpointMismatch, "snapshot block %x, checkpoint block %x", h.Bytes(), cp.BlockHash.Bytes())
+	}
+	if b.AssetsMerkleRoot != cp.StateRoot {
+		return errors.WithDetailf(ErrCheckpointMismatch, "snapshot state root %x, checkpoint state root %x", b.AssetsMerkleRoot.Bytes(), cp.StateRoot.Bytes())
+	}
+	if b.Height == 1 {
+		return nil
+	}
+	if uint64(len(headers)) != b.Height-2 {
+		return errors.WithDetailf(ErrCheckpointMismatch, "snapshot has %d headers, want %d", len(headers), b.Height-2)
+	}
+	chain := make([]*legacy.BlockHeader, 0, b.Height)
+	chain = append(chain, &initial.BlockHeader)
+	chain = append(chain, headers...)
+	chain = append(chain, &b.BlockHeader)
+	err := protocol.ValidateHeaderChain(chain)
+	return errors.Sub(ErrCheckpointMismatch, err)
+}
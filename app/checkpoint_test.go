@@ -0,0 +1,56 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+func TestCheckpointVerify(t *testing.T) {
+	initial, err := protocol.NewInitialBlock(nil, 0, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var headers []*legacy.BlockHeader
+	prev := initial.BlockHeader
+	for h := uint64(2); h <= 5; h++ {
+		header := prev
+		header.Height = h
+		header.PreviousBlockHash = prev.Hash()
+		header.TimestampMS = prev.TimestampMS + 1000
+		header.AssetsMerkleRoot = bc.NewHash([32]byte{byte(h)})
+		headers = append(headers, &header)
+		prev = header
+	}
+	tip := &legacy.Block{BlockHeader: *headers[len(headers)-1]}
+	between := headers[:len(headers)-1]
+	cp := &checkpoint{Height: tip.Height, BlockHash: tip.Hash(), StateRoot: tip.AssetsMerkleRoot}
+
+	err = cp.verify(initial, tip, between)
+	if err != nil {
+		t.Fatalf("verify of the checkpoint's chain = %v", err)
+	}
+
+	tampered := *between[1]
+	tampered.TimestampMS++
+	cases := []struct {
+		name    string
+		cp      checkpoint
+		headers []*legacy.BlockHeader
+	}{
+		{"height", checkpoint{Height: 4, BlockHash: cp.BlockHash, StateRoot: cp.StateRoot}, between},
+		{"block hash", checkpoint{Height: cp.Height, BlockHash: cp.StateRoot, StateRoot: cp.StateRoot}, between},
+		{"state root", checkpoint{Height: cp.Height, BlockHash: cp.BlockHash, StateRoot: cp.BlockHash}, between},
+		{"missing headers", *cp, between[1:]},
+		{"broken chain", *cp, []*legacy.BlockHeader{between[0], &tampered, between[2]}},
+	}
+	for _, c := range cases {
+		err := c.cp.verify(initial, tip, c.headers)
+		if errors.Root(err) != ErrCheckpointMismatch {
+			t.Errorf("%s: verify = %v, want %v", c.name, err, ErrCheckpointMismatch)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package app
+
+import (
+	"encoding/hex"
+	"sort"
+
+	"github.com/chainmint/math/checked"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/vmutil"
+	cmtTypes "github.com/chainmint/types"
+)
+
+// stakeBook records who delegated stake to which validator, the
+// validators' commission rates, and the rewards accrued to each
+// delegator. Keys are hex-encoded validator public keys and
+// delegator control programs, so the book can be persisted as JSON.
+type stakeBook struct {
+	Delegations map[string]map[string]uint64 `json:"delegations"`
+	Commissions map[string]uint64            `json:"commissions"` // basis points
+	Accruals    map[string]uint64            `json:"accruals"`
+}
+
+func newStakeBook() *stakeBook {
+	return &stakeBook{
+		Delegations: make(map[string]map[string]uint64),
+		Commissions: make(map[string]uint64),
+		Accruals:    make(map[string]uint64),
+	}
+}
+
+// applyTx records the stake tx delegates and undelegates. The tx
+// must already have been checked with delegationDeltas.
+func (b *stakeBook) applyTx(tx *legacy.Tx, stakeAsset bc.AssetID) {
+	for _, in := range tx.Inputs {
+		if in.IsIssuance() || in.AssetID() != stakeAsset {
+			continue
+		}
+		validator, owner, ok := vmutil.ParseDelegationProgram(in.ControlProgram())
+		if !ok {
+			continue
+		}
+		v, d := hex.EncodeToString(validator), hex.EncodeToString(owner)
+		amount, ok := checked.SubUint64(b.Delegations[v][d], in.Amount())
+		if !ok {
+			amount = 0
+		}
+		if amount > 0 {
+			b.Delegations[v][d] = amount
+			continue
+		}
+		delete(b.Delegations[v], d)
+		if len(b.Delegations[v]) == 0 {
+			delete(b.Delegations, v)
+		}
+	}
+	for _, out := range tx.Outputs {
+		if out.AssetId == nil || *out.AssetId != stakeAsset {
+			continue
+		}
+		validator, owner, ok := vmutil.ParseDelegationProgram(out.ControlProgram)
+		if !ok {
+			continue
+		}
+		v, d := hex.EncodeToString(validator), hex.EncodeToString(owner)
+		if b.Delegations[v] == nil {
+			b.Delegations[v] = make(map[string]uint64)
+		}
+		amount, ok := checked.AddUint64(b.Delegations[v][d], out.Amount)
+		if !ok {
+			amount = ^uint64(0)
+		}
+		b.Delegations[v][d] = amount
+	}
+}
+
+// delegations returns the delegations in the book, ordered by
+// validator and delegator.
+func (b *stakeBook) delegations() []cmtTypes.Delegation {
+	var ds []cmtTypes.Delegation
+	for v, byOwner := range b.Delegations {
+		validator, _ := hex.DecodeString(v)
+		for d, amount := range byOwner {
+			delegator, _ := hex.DecodeString(d)
+			ds = append(ds, cmtTypes.Delegation{Validator: validator, Delegator: delegator, Amount: amount})
+		}
+	}
+	sort.Slice(ds, func(i, j int) bool {
+		if string(ds[i].Validator) != string(ds[j].Validator) {
+			return string(ds[i].Validator) < string(ds[j].Validator)
+		}
+		return string(ds[i].Delegator) < string(ds[j].Delegator)
+	})
+	return ds
+}
+
+// accrue adds the delegators' shares of rewards to their accruals.
+func (b *stakeBook) accrue(rewards []cmtTypes.Reward) {
+	for _, r := range rewards {
+		for _, d := range r.Delegators {
+			k := hex.EncodeToString(d.Delegator)
+			sum, ok := checked.AddUint64(b.Accruals[k], d.Amount)
+			if !ok {
+				sum = ^uint64(0)
+			}
+			b.Accruals[k] = sum
+		}
+	}
+}
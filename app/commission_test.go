@@ -0,0 +1,42 @@
+package app
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/vm"
+	"github.com/chainmint/protocol/vmutil"
+	cmtTypes "github.com/chainmint/types"
+)
+
+func TestStakeBook(t *testing.T) {
+	stake := bc.NewAssetID([32]byte{1})
+	owner := []byte{byte(vm.OP_TRUE)}
+	val := []byte{0xaa}
+	delegated := vmutil.DelegationProgram(val, owner)
+
+	b := newStakeBook()
+	b.applyTx(&legacy.Tx{TxData: legacy.TxData{
+		Outputs: []*legacy.TxOutput{legacy.NewTxOutput(stake, 30, delegated, nil)},
+	}}, stake)
+	want := []cmtTypes.Delegation{{Validator: val, Delegator: owner, Amount: 30}}
+	if got := b.delegations(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("delegations = %+v, want %+v", got, want)
+	}
+
+	b.applyTx(&legacy.Tx{TxData: legacy.TxData{
+		Inputs: []*legacy.TxInput{legacy.NewSpendInput(nil, bc.Hash{}, stake, 30, 0, delegated, bc.Hash{}, nil)},
+	}}, stake)
+	if got := b.delegations(); len(got) != 0 {
+		t.Errorf("delegations after undelegating = %+v, want none", got)
+	}
+
+	b.accrue([]cmtTypes.Reward{{PubKey: val, Amount: 1, Delegators: []cmtTypes.DelegatorReward{{Delegator: owner, Amount: 7}}}})
+	b.accrue([]cmtTypes.Reward{{PubKey: val, Amount: 1, Delegators: []cmtTypes.DelegatorReward{{Delegator: owner, Amount: 5}}}})
+	if got := b.Accruals[hex.EncodeToString(owner)]; got != 12 {
+		t.Errorf("accrued = %d, want 12", got)
+	}
+}
@@ -0,0 +1,218 @@
+package app
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"sort"
+
+	"github.com/chainmint/crypto/ca"
+	"github.com/chainmint/crypto/ed25519/ecmath"
+	"github.com/chainmint/crypto/sha3pool"
+	"github.com/chainmint/encoding/json"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/upgrade"
+)
+
+// confidentialUpgrade is the upgrade, and its feature, that turns on
+// the experimental confidential amounts mode. A chain schedules it
+// like any other upgrade, in its genesis or by governance, so that
+// every node checks the same txs from the same height.
+const confidentialUpgrade = "confidential-amounts"
+
+func init() {
+	upgrade.Register(upgrade.Upgrade{Name: confidentialUpgrade, Features: []string{confidentialUpgrade}})
+}
+
+// ErrConfidential is returned when a transaction's confidential
+// amounts don't verify.
+var ErrConfidential = errors.New("invalid confidential amounts")
+
+// Confidential amounts are carried in reference data, and the
+// plaintext amount of every confidential input and output is zero,
+// so the protocol's own balance check holds trivially. This file
+// checks the real balance using value commitments instead.
+//
+// An asset opts in at creation with "confidential": true in its
+// definition; only such assets may be issued confidentially, and
+// value can enter commitments only through confidential issuance.
+//
+// The reference data formats are:
+//
+//	output:   {"confidential": {"commitment": <hex>, "range_proof": <hex>}}
+//	spend:    {"confidential": {"spent_output_reference_data": <hex>}}
+//	issuance: {"confidential": {"amount": <n>}}
+//	tx:       {"confidential_excess": {<asset id>: <hex scalar>}}
+//
+// A spend repeats the full reference data of the output it spends,
+// which must hash to the reference data hash in its commitment.
+type (
+	confidentialOutput struct {
+		Commitment json.HexBytes `json:"commitment"`
+		RangeProof json.HexBytes `json:"range_proof"`
+	}
+	confidentialSpend struct {
+		SpentOutputReferenceData json.HexBytes `json:"spent_output_reference_data"`
+	}
+	confidentialIssuance struct {
+		Amount uint64 `json:"amount"`
+	}
+)
+
+// confidentialBalance accumulates the commitments of one asset
+// in a transaction.
+type confidentialBalance struct {
+	inputs, outputs []ca.Commitment
+	issued          uint64
+}
+
+// checkConfidential checks the confidential amounts in tx, bound
+// for the next chain block, if confidentialUpgrade is active there.
+func (app *ChainmintApplication) checkConfidential(tx *legacy.Tx) error {
+	c := app.backend.Chain()
+	rules, err := c.Rules(c.Height() + 1)
+	if err != nil || !rules.Enabled(confidentialUpgrade) {
+		return nil
+	}
+	return validateConfidential(tx)
+}
+
+// validateConfidential checks the confidential amounts in tx.
+func validateConfidential(tx *legacy.Tx) error {
+	balances := make(map[bc.AssetID]*confidentialBalance)
+	balance := func(assetID bc.AssetID) *confidentialBalance {
+		b := balances[assetID]
+		if b == nil {
+			b = new(confidentialBalance)
+			balances[assetID] = b
+		}
+		return b
+	}
+
+	for i, in := range tx.Inputs {
+		switch ti := in.TypedInput.(type) {
+		case *legacy.IssuanceInput:
+			var ref struct {
+				Confidential *confidentialIssuance `json:"confidential"`
+			}
+			if !parseRef(in.ReferenceData, &ref) || ref.Confidential == nil {
+				continue
+			}
+			if ti.Amount != 0 {
+				return errors.WithDetailf(ErrConfidential, "input %d: confidential issuance has plaintext amount", i)
+			}
+			var def struct {
+				Confidential bool `json:"confidential"`
+			}
+			if !parseRef(ti.AssetDefinition, &def) || !def.Confidential {
+				return errors.WithDetailf(ErrConfidential, "input %d: asset is not confidential", i)
+			}
+			b := balance(in.AssetID())
+			if b.issued+ref.Confidential.Amount < b.issued {
+				return errors.WithDetailf(ErrConfidential, "input %d: issued amount overflows", i)
+			}
+			b.issued += ref.Confidential.Amount
+
+		case *legacy.SpendInput:
+			var ref struct {
+				Confidential *confidentialSpend `json:"confidential"`
+			}
+			if !parseRef(in.ReferenceData, &ref) || ref.Confidential == nil || ti.Amount != 0 {
+				continue
+			}
+			spentRef := ref.Confidential.SpentOutputReferenceData
+			var h [32]byte
+			sha3pool.Sum256(h[:], spentRef)
+			if bc.NewHash(h) != ti.RefDataHash {
+				return errors.WithDetailf(ErrConfidential, "input %d: spent output reference data does not match", i)
+			}
+			c, _, err := parseConfidentialOutput(spentRef)
+			if err != nil {
+				return errors.WithDetailf(ErrConfidential, "input %d: %s", i, err)
+			}
+			if c != nil {
+				b := balance(in.AssetID())
+				b.inputs = append(b.inputs, *c)
+			}
+		}
+	}
+
+	for i, out := range tx.Outputs {
+		c, rp, err := parseConfidentialOutput(out.ReferenceData)
+		if err != nil {
+			return errors.WithDetailf(ErrConfidential, "output %d: %s", i, err)
+		}
+		if c == nil {
+			continue
+		}
+		if out.Amount != 0 {
+			return errors.WithDetailf(ErrConfidential, "output %d: confidential output has plaintext amount", i)
+		}
+		if !ca.VerifyRangeProof(*c, rp, out.AssetId.Bytes()) {
+			return errors.WithDetailf(ErrConfidential, "output %d: invalid range proof", i)
+		}
+		b := balance(*out.AssetId)
+		b.outputs = append(b.outputs, *c)
+	}
+
+	if len(balances) == 0 {
+		return nil
+	}
+	var ref struct {
+		Excess map[bc.AssetID]json.HexBytes `json:"confidential_excess"`
+	}
+	parseRef(tx.ReferenceData, &ref)
+
+	// Check assets in a fixed order, so every node reports the
+	// same error.
+	assetIDs := make([]bc.AssetID, 0, len(balances))
+	for assetID := range balances {
+		assetIDs = append(assetIDs, assetID)
+	}
+	sort.Slice(assetIDs, func(i, j int) bool {
+		return bytes.Compare(assetIDs[i].Bytes(), assetIDs[j].Bytes()) < 0
+	})
+	for _, assetID := range assetIDs {
+		b := balances[assetID]
+		excessBytes, ok := ref.Excess[assetID]
+		if !ok || len(excessBytes) != 32 {
+			return errors.WithDetailf(ErrConfidential, "asset %x: missing excess", assetID.Bytes())
+		}
+		var excess ecmath.Scalar
+		copy(excess[:], excessBytes)
+		if !ca.VerifyBalance(b.inputs, b.outputs, b.issued, &excess) {
+			return errors.WithDetailf(ErrConfidential, "asset %x: unbalanced", assetID.Bytes())
+		}
+	}
+	return nil
+}
+
+// parseConfidentialOutput returns the commitment and range proof in
+// an output's reference data, or nil if the output is not
+// confidential.
+func parseConfidentialOutput(refData []byte) (*ca.Commitment, *ca.RangeProof, error) {
+	var ref struct {
+		Confidential *confidentialOutput `json:"confidential"`
+	}
+	if !parseRef(refData, &ref) || ref.Confidential == nil {
+		return nil, nil, nil
+	}
+	if len(ref.Confidential.Commitment) != len(ca.Commitment{}) {
+		return nil, nil, errors.New("malformed commitment")
+	}
+	var c ca.Commitment
+	copy(c[:], ref.Confidential.Commitment)
+	rp, err := ca.ParseRangeProof(ref.Confidential.RangeProof)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &c, rp, nil
+}
+
+// parseRef decodes JSON reference data into v, reporting whether
+// it succeeded. Reference data that isn't JSON is not an error: it
+// just doesn't carry confidential amounts.
+func parseRef(refData []byte, v interface{}) bool {
+	return len(refData) > 0 && stdjson.Unmarshal(refData, v) == nil
+}
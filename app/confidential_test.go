@@ -0,0 +1,52 @@
+package app
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/chainmint/crypto/ca"
+	"github.com/chainmint/crypto/ed25519/ecmath"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+func TestValidateConfidentialOutputs(t *testing.T) {
+	assetID := bc.NewAssetID([32]byte{1})
+	assetHex, _ := assetID.MarshalText()
+	output := func(amount uint64, f *ecmath.Scalar) *legacy.TxOutput {
+		c := ca.Commit(amount, f)
+		rp := ca.CreateRangeProof(amount, f, assetID.Bytes())
+		ref := fmt.Sprintf(`{"confidential": {"commitment": "%x", "range_proof": "%x"}}`, c[:], rp.Bytes())
+		return legacy.NewTxOutput(assetID, 0, []byte{0x51}, []byte(ref))
+	}
+	txWithExcess := func(excess *ecmath.Scalar, outs ...*legacy.TxOutput) *legacy.Tx {
+		ref := fmt.Sprintf(`{"confidential_excess": {"%s": "%s"}}`, assetHex, hex.EncodeToString(excess[:]))
+		return &legacy.Tx{TxData: legacy.TxData{Outputs: outs, ReferenceData: []byte(ref)}}
+	}
+
+	f := ca.RandomScalar()
+	var negf ecmath.Scalar
+	negf.Neg(&f)
+
+	// Commitments to zero balance with no inputs.
+	err := validateConfidential(txWithExcess(&negf, output(0, &f)))
+	if err != nil {
+		t.Errorf("zero-valued output: unexpected error %v", err)
+	}
+
+	// Value can't be created from nothing.
+	err = validateConfidential(txWithExcess(&negf, output(10, &f)))
+	if errors.Root(err) != ErrConfidential {
+		t.Errorf("inflating output: got error %v, want %v", err, ErrConfidential)
+	}
+
+	// Plaintext amounts must be zero.
+	out := output(0, &f)
+	out.Amount = 5
+	err = validateConfidential(txWithExcess(&negf, out))
+	if errors.Root(err) != ErrConfidential {
+		t.Errorf("plaintext amount: got error %v, want %v", err, ErrConfidential)
+	}
+}
@@ -0,0 +1,157 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/chainmint/env"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/log"
+	"github.com/chainmint/metrics"
+	"github.com/chainmint/protocol/bc"
+	abciTypes "github.com/tendermint/abci/types"
+)
+
+// crashDumpDir names a directory in which to write a crash dump
+// for each panic in an ABCI method. An empty path
+// disables crash dumps.
+var crashDumpDir = env.String("CRASH_DUMP_DIR", "")
+
+var abciPanics = metrics.NewCounter("chainmint_abci_panics",
+	"Panics in ABCI methods, by method.", "method")
+
+// crashDump is the record of a panic in an ABCI method written to
+// CRASH_DUMP_DIR, for postmortems.
+type crashDump struct {
+	Method        string                 `json:"method"`
+	Time          time.Time              `json:"time"`
+	Panic         string                 `json:"panic"`
+	Stack         string                 `json:"stack"`
+	Height        uint64                 `json:"height"`
+	ChainHeight   uint64                 `json:"chain_height"`
+	BlockTime     uint64                 `json:"block_time"`
+	LastCheckTx   *bc.Hash               `json:"last_check_tx,omitempty"`
+	LastDeliverTx *bc.Hash               `json:"last_deliver_tx,omitempty"`
+	Config        map[string]interface{} `json:"config"`
+}
+
+// recoverABCI reports a panic in the ABCI method named method. It
+// logs the panic with its stack, counts it, and writes a crash dump
+// if CRASH_DUMP_DIR is set. Then it passes the panic, as an error,
+// to onPanic, which sets the method's response, so that one
+// malformed tx can't take down the mempool or queries. It must be
+// deferred by the method, after any deferred function that reads
+// the response.
+//
+// If onPanic is nil, recoverABCI panics again once it has reported
+// the panic. The methods that change the state, DeliverTx and those
+// of the block, must pass nil: recovering them would leave the node
+// with a block half applied, diverging from the others, instead of
+// stopping it for Tendermint to replay the block on restart.
+// Info must too: a recovered Info would report height 0, for
+// Tendermint to replay the whole chain onto the node's state.
+func (app *ChainmintApplication) recoverABCI(method string, onPanic func(error)) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	ctx := context.Background()
+	err, ok := r.(error)
+	if !ok {
+		err = fmt.Errorf("panic: %v", r)
+	}
+	err = errors.Wrapf(err, "panic in %s", method)
+
+	const size = 64 << 10
+	stack := make([]byte, size)
+	stack = stack[:runtime.Stack(stack, false)]
+	appLog.Errorkv(ctx, "at", "abci panic", "method", method, "height", app.height, log.KeyError, err, log.KeyStack, stack)
+	abciPanics.Inc(method)
+
+	if *crashDumpDir != "" {
+		path, dumpErr := app.writeCrashDump(*crashDumpDir, method, r, stack)
+		if dumpErr != nil {
+			log.Error(ctx, dumpErr, "writing crash dump")
+		} else {
+			appLog.Errorkv(ctx, "at", "wrote crash dump", "method", method, "path", path)
+		}
+	}
+
+	if onPanic == nil {
+		panic(r)
+	}
+	onPanic(err)
+}
+
+// panicResult is the result of a CheckTx that panicked with err.
+func panicResult(err error) abciTypes.Result {
+	return abciTypes.ErrInternalError.AppendLog(err.Error())
+}
+
+// writeCrashDump writes the crash dump of a panic, r, in method to
+// a new file in dir, creating dir if need be, and returns the
+// file's path. It recovers a panic of its own, as the state it
+// reads may be what the first panic left broken.
+func (app *ChainmintApplication) writeCrashDump(dir, method string, r interface{}, stack []byte) (path string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Wrap(fmt.Errorf("panic: %v", r))
+		}
+	}()
+
+	now := time.Now().UTC()
+	dump := crashDump{
+		Method:    method,
+		Time:      now,
+		Panic:     fmt.Sprint(r),
+		Stack:     string(stack),
+		Height:    app.height,
+		BlockTime: app.BlockTime,
+		Config:    configSnapshot(),
+	}
+	if app.lastCheckTx != (bc.Hash{}) {
+		id := app.lastCheckTx
+		dump.LastCheckTx = &id
+	}
+	if app.lastDeliverTx != (bc.Hash{}) {
+		id := app.lastDeliverTx
+		dump.LastDeliverTx = &id
+	}
+	if app.backend != nil {
+		dump.ChainHeight = app.backend.Chain().Height()
+	}
+
+	b, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+	name := fmt.Sprintf("crash-%d-%s-%s.json", app.height, method, now.Format("20060102T150405.000000000"))
+	path = filepath.Join(dir, name)
+	err = ioutil.WriteFile(path, b, 0600)
+	return path, errors.Wrap(err)
+}
+
+// configSnapshot returns the app's configuration, by environment
+// variable, for a crash dump. It holds no secrets, such as
+// database credentials, as crash dumps are meant to be shared.
+func configSnapshot() map[string]interface{} {
+	return map[string]interface{}{
+		"COMMIT_DURABILITY":        *commitDurability,
+		"GENERATOR_TIMEOUT":        generatorTimeout.String(),
+		"MAX_MEMO_BYTES":           *maxMemoBytes,
+		"MEMO_BYTE_PRICE":          *memoBytePrice,
+		"MIN_TX_FEE":               *minTxFee,
+		"SIDE_EFFECT_QUEUE_POLICY": *sideEffectQueuePolicy,
+		"TX_FEE_BYTE_RATE":         *txFeeByteRate,
+	}
+}
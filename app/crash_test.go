@@ -0,0 +1,65 @@
+package app
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chainmint/protocol/bc"
+	abciTypes "github.com/tendermint/abci/types"
+)
+
+func TestRecoverABCI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(old string) { *crashDumpDir = old }(*crashDumpDir)
+	*crashDumpDir = dir
+
+	app := &ChainmintApplication{height: 7, lastDeliverTx: bc.NewHash([32]byte{1})}
+	check := func() (res abciTypes.Result) {
+		defer app.recoverABCI("CheckTx", func(err error) { res = panicResult(err) })
+		var m map[string]int
+		m["x"] = 1 // panics
+		return abciTypes.OK
+	}
+	res := check()
+	if res.Code != abciTypes.CodeType_InternalError || !strings.Contains(res.Log, "panic in CheckTx") {
+		t.Errorf("result = %v want an internal error for a panic in CheckTx", res)
+	}
+
+	paths, _ := filepath.Glob(filepath.Join(dir, "crash-7-CheckTx-*.json"))
+	if len(paths) != 1 {
+		t.Fatalf("crash dumps = %v want one", paths)
+	}
+	b, err := ioutil.ReadFile(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dump crashDump
+	err = json.Unmarshal(b, &dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dump.Height != 7 || dump.LastDeliverTx == nil || *dump.LastDeliverTx != app.lastDeliverTx || dump.LastCheckTx != nil {
+		t.Errorf("crash dump = %+v, want height 7 and the last delivered tx", dump)
+	}
+	if !strings.Contains(dump.Stack, "TestRecoverABCI") || dump.Config["GENERATOR_TIMEOUT"] != generatorTimeout.String() {
+		t.Errorf("crash dump lacks the stack or config:\n%s", b)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("recoverABCI with no onPanic did not panic again")
+		}
+	}()
+	func() {
+		defer app.recoverABCI("Commit", nil)
+		panic("commit")
+	}()
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"context"
+
+	"github.com/chainmint/crypto/provider"
+	"github.com/chainmint/database/pg"
+	"github.com/chainmint/database/sql"
+	"github.com/chainmint/errors"
+)
+
+// The crypto provider of a chain is named in its genesis document,
+// so every node runs the chain under the same one, and is recorded
+// in the node's database when the chain starts. A chain whose
+// genesis names none, as those made before providers could be
+// named, uses provider.Default.
+//
+// The provider covers only the signatures the app checks, such as
+// governance votes and validator registrations, and the app hash of
+// a chain committing to its block hash, which under a provider other
+// than the default is the provider's digest of the block hash. The
+// protocol's own hashes, block hashes, transaction IDs and the state
+// trees among them, app state root included, are SHA3-256 under
+// every provider. A chain that must use no SHA3 at all needs changes
+// to the protocol that are beyond the provider's reach.
+
+// ErrCryptoProviderMismatch is returned when a chain is started with
+// a crypto provider other than the one it was created with.
+var ErrCryptoProviderMismatch = errors.New("crypto provider differs from the chain's")
+
+// useCryptoProvider sets the crypto provider of the chain to the one
+// registered under name.
+func (app *ChainmintApplication) useCryptoProvider(name string) error {
+	p, err := provider.Lookup(name)
+	if err != nil {
+		return err
+	}
+	app.crypto = p
+	return nil
+}
+
+// startCryptoProvider records name, from the genesis document, as
+// the crypto provider of the chain, which has no blocks yet, and
+// uses it.
+func (app *ChainmintApplication) startCryptoProvider(ctx context.Context, name string) error {
+	err := app.useCryptoProvider(name)
+	if err != nil {
+		return err
+	}
+	return pinCryptoProvider(ctx, app.backend.DB(), name)
+}
+
+// pinCryptoProvider records name as the crypto provider of the
+// chain if none is recorded yet, and otherwise checks that it is
+// the one recorded.
+func pinCryptoProvider(ctx context.Context, db pg.DB, name string) error {
+	const q = `
+		WITH ins AS (
+			INSERT INTO chain_crypto (provider) VALUES ($1)
+			ON CONFLICT (singleton) DO NOTHING
+			RETURNING provider
+		)
+		SELECT provider FROM ins
+		UNION ALL SELECT provider FROM chain_crypto
+	`
+	var pinned string
+	err := db.QueryRow(ctx, q, name).Scan(&pinned)
+	if err != nil {
+		return errors.Wrap(err, "chain_crypto upsert query")
+	}
+	if pinned != name {
+		return errors.WithDetailf(ErrCryptoProviderMismatch, "chain was created with %q, started with %q", pinned, name)
+	}
+	return nil
+}
+
+// loadCryptoProvider returns the name of the crypto provider
+// recorded for the chain, or provider.Default if none is, as for a
+// chain not yet started.
+func loadCryptoProvider(ctx context.Context, db pg.DB) (string, error) {
+	var name string
+	err := db.QueryRow(ctx, `SELECT provider FROM chain_crypto`).Scan(&name)
+	if err == sql.ErrNoRows {
+		return provider.Default, nil
+	}
+	return name, errors.Wrap(err, "chain_crypto query")
+}
+
+// appHash returns the app hash committed for the block with the
+// given hash. Under the default provider it is the block hash
+// itself, as it always has been; other providers commit to their
+// own digest of it.
+func (app *ChainmintApplication) appHash(blockHash []byte) []byte {
+	return appHashOf(app.crypto, blockHash)
+}
+
+// appHashOf returns the app hash committed for the block with the
+// given hash under the crypto provider p.
+func appHashOf(p provider.Provider, blockHash []byte) []byte {
+	if p == nil || p.Name() == provider.Default || len(blockHash) == 0 {
+		return blockHash
+	}
+	sum := p.Sum256(append([]byte("chainmint-app-hash:"), blockHash...))
+	return sum[:]
+}
@@ -0,0 +1,35 @@
+package app
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/chainmint/crypto/provider"
+)
+
+func TestAppHash(t *testing.T) {
+	blockHash := bytes.Repeat([]byte{0xab}, 32)
+
+	app := new(ChainmintApplication)
+	if err := app.useCryptoProvider(provider.Default); err != nil {
+		t.Fatal(err)
+	}
+	if got := app.appHash(blockHash); !bytes.Equal(got, blockHash) {
+		t.Errorf("default appHash = %x, want the block hash %x", got, blockHash)
+	}
+
+	if err := app.useCryptoProvider("sha256-ed25519"); err != nil {
+		t.Fatal(err)
+	}
+	got := app.appHash(blockHash)
+	if len(got) != 32 || bytes.Equal(got, blockHash) {
+		t.Errorf("sha256-ed25519 appHash = %x, want a digest of the block hash", got)
+	}
+	if again := app.appHash(blockHash); !bytes.Equal(again, got) {
+		t.Errorf("appHash is not deterministic: %x, then %x", got, again)
+	}
+
+	if err := app.useCryptoProvider("no-such-provider"); err == nil {
+		t.Error("useCryptoProvider accepted an unknown provider")
+	}
+}
@@ -0,0 +1,35 @@
+package app
+
+import (
+	"context"
+	"time"
+)
+
+// DebugState returns a summary of the app's state, for the debug
+// listener's /debug/state. It reads only what is safe to read while
+// Tendermint calls the ABCI methods. It must not be called before
+// Init.
+func (app *ChainmintApplication) DebugState(ctx context.Context) (interface{}, error) {
+	state := struct {
+		ChainHeight     uint64    `json:"chain_height"`
+		BlockTime       time.Time `json:"block_time"`
+		Reconciled      bool      `json:"reconciled"`
+		PendingTxs      int       `json:"pending_txs"`
+		SideEffectQueue int       `json:"side_effect_queue"`
+		Strategy        string    `json:"strategy"`
+		Crypto          string    `json:"crypto_provider"`
+	}{
+		ChainHeight: app.backend.Chain().Height(),
+		Reconciled:  app.backend.Reconciled(),
+		PendingTxs:  app.pending.len(),
+		Strategy:    app.strategyName,
+		Crypto:      app.crypto.Name(),
+	}
+	if b, _ := app.currentState(); b != nil {
+		state.BlockTime = b.Time()
+	}
+	if app.sideEffects != nil {
+		state.SideEffectQueue = app.sideEffects.Len()
+	}
+	return state, nil
+}
@@ -0,0 +1,178 @@
+package app
+
+import (
+	"sort"
+	"time"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/math/checked"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/vmutil"
+	abciTypes "github.com/tendermint/abci/types"
+)
+
+// defaultUnbondingPeriod is the unbonding period of a network whose
+// genesis parameters set none. Like the other parameters, it is the
+// same on every node and changes only by governance.
+const defaultUnbondingPeriod = 21 * 24 * time.Hour
+
+// Errors returned when a delegation or undelegation is rejected.
+// Like the admission errors, their messages are part of the
+// DeliverTx result.
+var (
+	ErrUnknownValidator = errors.New("delegation to unknown validator")
+	ErrNotUnbonding     = errors.New("undelegated stake is not time-locked for the unbonding period")
+)
+
+// powerDelta is the stake delegated to and undelegated from one
+// validator in the block being processed.
+type powerDelta struct {
+	add, sub uint64
+}
+
+// delegationDeltas returns the changes tx makes to validator power.
+// Outputs of the stake asset with a delegation program add to the
+// named validator's power; spends of such outputs subtract from it.
+// Undelegated stake must be paid into time-locked outputs that
+// unlock no earlier than the unbonding period after blockTimeMS.
+func delegationDeltas(tx *legacy.Tx, stakeAsset bc.AssetID, vals []*abciTypes.Validator, blockTimeMS, unbondingMS uint64) (map[string]*powerDelta, error) {
+	deltas := make(map[string]*powerDelta)
+	delta := func(pubkey []byte) *powerDelta {
+		d := deltas[string(pubkey)]
+		if d == nil {
+			d = new(powerDelta)
+			deltas[string(pubkey)] = d
+		}
+		return d
+	}
+
+	var undelegated uint64
+	for _, in := range tx.Inputs {
+		if in.IsIssuance() || in.AssetID() != stakeAsset {
+			continue
+		}
+		validator, _, ok := vmutil.ParseDelegationProgram(in.ControlProgram())
+		if !ok {
+			continue
+		}
+		d := delta(validator)
+		d.sub, ok = checked.AddUint64(d.sub, in.Amount())
+		if !ok {
+			return nil, errors.Wrap(checked.ErrOverflow, "undelegated amount")
+		}
+		undelegated, ok = checked.AddUint64(undelegated, in.Amount())
+		if !ok {
+			return nil, errors.Wrap(checked.ErrOverflow, "undelegated amount")
+		}
+	}
+
+	minUnlock, ok := checked.AddUint64(blockTimeMS, unbondingMS)
+	if !ok {
+		return nil, errors.Wrap(checked.ErrOverflow, "unbonding time")
+	}
+	var locked uint64
+	for _, out := range tx.Outputs {
+		if out.AssetId == nil || *out.AssetId != stakeAsset {
+			continue
+		}
+		if validator, _, ok := vmutil.ParseDelegationProgram(out.ControlProgram); ok {
+			if !hasValidator(vals, validator) {
+				return nil, errors.WithDetailf(ErrUnknownValidator, "validator %x", validator)
+			}
+			d := delta(validator)
+			d.add, ok = checked.AddUint64(d.add, out.Amount)
+			if !ok {
+				return nil, errors.Wrap(checked.ErrOverflow, "delegated amount")
+			}
+			continue
+		}
+		if unlock, _, ok := vmutil.ParseTimeLockProgram(out.ControlProgram); ok && unlock >= minUnlock {
+			locked, ok = checked.AddUint64(locked, out.Amount)
+			if !ok {
+				return nil, errors.Wrap(checked.ErrOverflow, "locked amount")
+			}
+		}
+	}
+	if locked < undelegated {
+		return nil, errors.WithDetailf(ErrNotUnbonding, "%d undelegated, %d locked until %d", undelegated, locked, minUnlock)
+	}
+	return deltas, nil
+}
+
+// addDeltas accumulates the deltas of one transaction into the
+// deltas of the block.
+func addDeltas(block, tx map[string]*powerDelta) {
+	for k, d := range tx {
+		bd := block[k]
+		if bd == nil {
+			bd = new(powerDelta)
+			block[k] = bd
+		}
+		bd.add += d.add
+		bd.sub += d.sub
+	}
+}
+
+// delegationDiffs returns the validator diffs that apply the
+// block's delegation deltas to vals. Delegations to validators no
+// longer in the set are ignored. Diffs are ordered by public key so
+// every node produces the same EndBlock response.
+func delegationDiffs(vals []*abciTypes.Validator, deltas map[string]*powerDelta) []*abciTypes.Validator {
+	var keys []string
+	for k := range deltas {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var diffs []*abciTypes.Validator
+	for _, k := range keys {
+		for _, v := range vals {
+			if string(v.PubKey) != k {
+				continue
+			}
+			d := deltas[k]
+			power, ok := checked.AddUint64(v.Power, d.add)
+			if !ok {
+				power = ^uint64(0)
+			}
+			power, ok = checked.SubUint64(power, d.sub)
+			if !ok {
+				power = 0
+			}
+			if power != v.Power {
+				diffs = append(diffs, &abciTypes.Validator{PubKey: v.PubKey, Power: power})
+			}
+			break
+		}
+	}
+	return diffs
+}
+
+// mergeDiffs appends diffs to base, replacing any earlier diff for
+// the same validator so each validator appears at most once.
+func mergeDiffs(base, diffs []*abciTypes.Validator) []*abciTypes.Validator {
+	for _, d := range diffs {
+		i := 0
+		for ; i < len(base); i++ {
+			if string(base[i].PubKey) == string(d.PubKey) {
+				break
+			}
+		}
+		if i < len(base) {
+			base[i] = d
+		} else {
+			base = append(base, d)
+		}
+	}
+	return base
+}
+
+func hasValidator(vals []*abciTypes.Validator, pubkey []byte) bool {
+	for _, v := range vals {
+		if string(v.PubKey) == string(pubkey) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/vm"
+	"github.com/chainmint/protocol/vmutil"
+	abciTypes "github.com/tendermint/abci/types"
+)
+
+func TestDelegationDeltas(t *testing.T) {
+	stake := bc.NewAssetID([32]byte{1})
+	owner := []byte{byte(vm.OP_TRUE)}
+	val := []byte{0xaa}
+	vals := []*abciTypes.Validator{{PubKey: val, Power: 10}}
+	delegated := vmutil.DelegationProgram(val, owner)
+
+	const now, unbonding = 1000, 500
+
+	spendDelegation := legacy.NewSpendInput(nil, bc.Hash{}, stake, 30, 0, delegated, bc.Hash{}, nil)
+
+	cases := []struct {
+		tx   legacy.TxData
+		want error
+		add  uint64
+		sub  uint64
+	}{{
+		// delegate
+		tx:  legacy.TxData{Outputs: []*legacy.TxOutput{legacy.NewTxOutput(stake, 20, delegated, nil)}},
+		add: 20,
+	}, {
+		// delegate to a non-validator
+		tx:   legacy.TxData{Outputs: []*legacy.TxOutput{legacy.NewTxOutput(stake, 20, vmutil.DelegationProgram([]byte{0xbb}, owner), nil)}},
+		want: ErrUnknownValidator,
+	}, {
+		// undelegate into a time lock
+		tx: legacy.TxData{
+			Inputs:  []*legacy.TxInput{spendDelegation},
+			Outputs: []*legacy.TxOutput{legacy.NewTxOutput(stake, 30, vmutil.TimeLockProgram(now+unbonding, owner), nil)},
+		},
+		sub: 30,
+	}, {
+		// undelegate with a short time lock
+		tx: legacy.TxData{
+			Inputs:  []*legacy.TxInput{spendDelegation},
+			Outputs: []*legacy.TxOutput{legacy.NewTxOutput(stake, 30, vmutil.TimeLockProgram(now+unbonding-1, owner), nil)},
+		},
+		want: ErrNotUnbonding,
+	}, {
+		// undelegate without a time lock
+		tx: legacy.TxData{
+			Inputs:  []*legacy.TxInput{spendDelegation},
+			Outputs: []*legacy.TxOutput{legacy.NewTxOutput(stake, 30, owner, nil)},
+		},
+		want: ErrNotUnbonding,
+	}}
+	for i, c := range cases {
+		deltas, err := delegationDeltas(&legacy.Tx{TxData: c.tx}, stake, vals, now, unbonding)
+		if errors.Root(err) != c.want {
+			t.Errorf("case %d: got error %v, want %v", i, err, c.want)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		d := deltas[string(val)]
+		if d == nil || d.add != c.add || d.sub != c.sub {
+			t.Errorf("case %d: got delta %+v, want add %d sub %d", i, d, c.add, c.sub)
+		}
+	}
+}
+
+func TestDelegationDiffs(t *testing.T) {
+	vals := []*abciTypes.Validator{
+		{PubKey: []byte{0xaa}, Power: 10},
+		{PubKey: []byte{0xbb}, Power: 10},
+	}
+	deltas := map[string]*powerDelta{
+		string([]byte{0xbb}): {add: 5, sub: 1},
+		string([]byte{0xaa}): {sub: 20},
+		string([]byte{0xcc}): {add: 5}, // not a validator
+	}
+	got := delegationDiffs(vals, deltas)
+	if len(got) != 2 {
+		t.Fatalf("got %d diffs, want 2", len(got))
+	}
+	if got[0].PubKey[0] != 0xaa || got[0].Power != 0 {
+		t.Errorf("diff 0 = %x:%d, want aa:0", got[0].PubKey, got[0].Power)
+	}
+	if got[1].PubKey[0] != 0xbb || got[1].Power != 14 {
+		t.Errorf("diff 1 = %x:%d, want bb:14", got[1].PubKey, got[1].Power)
+	}
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/chainmint/database/sql"
+	"github.com/chainmint/env"
+	"github.com/chainmint/errors"
+)
+
+// maxFsyncInterval bounds COMMIT_FSYNC_INTERVAL, and so the blocks
+// Tendermint must replay after the database server crashes.
+const maxFsyncInterval = time.Minute
+
+var (
+	// commitDurability is when Commit waits for its writes to be
+	// flushed to disk: "block" waits in every Commit, and "interval"
+	// only once COMMIT_FSYNC_INTERVAL has passed since it last did.
+	commitDurability    = env.String("COMMIT_DURABILITY", "block")
+	commitFsyncInterval = env.Duration("COMMIT_FSYNC_INTERVAL", 0)
+)
+
+// durability decides which commits wait for their writes to be
+// flushed to disk.
+//
+// Every write Commit makes but the last commits asynchronously, and
+// the last, which waits, flushes them all, so a block costs a single
+// flush. Under an interval, the last write waits only when the
+// interval has passed, and a crash of the database server, though
+// not of the app alone, can lose the blocks committed in the
+// interval before it. The app then reports the height it kept, and
+// Tendermint replays the later blocks from its own store.
+type durability struct {
+	interval time.Duration // 0 flushes every block
+	lastSync time.Time
+}
+
+// configuredDurability returns the durability set by the
+// environment.
+func configuredDurability() (*durability, error) {
+	switch *commitDurability {
+	case "block":
+		if *commitFsyncInterval != 0 {
+			return nil, errors.New("COMMIT_FSYNC_INTERVAL is only used with COMMIT_DURABILITY=interval")
+		}
+		return new(durability), nil
+	case "interval":
+		if *commitFsyncInterval <= 0 {
+			return nil, errors.New("COMMIT_DURABILITY=interval needs a positive COMMIT_FSYNC_INTERVAL")
+		}
+		if *commitFsyncInterval > maxFsyncInterval {
+			return nil, errors.New("COMMIT_FSYNC_INTERVAL " + commitFsyncInterval.String() + " exceeds " + maxFsyncInterval.String() +
+				"; a database crash would lose too many blocks for Tendermint to replay promptly")
+		}
+		return &durability{interval: *commitFsyncInterval}, nil
+	}
+	return nil, errors.New("invalid COMMIT_DURABILITY " + *commitDurability + ", want block or interval")
+}
+
+// syncDue reports whether a commit at now must wait to be flushed.
+func (d *durability) syncDue(now time.Time) bool {
+	return d.interval == 0 || now.Sub(d.lastSync) >= d.interval
+}
+
+// finishCommit saves the strategy state after the block and deletes
+// the commit record, in one transaction. Unless app.durability
+// defers it, the transaction waits to be flushed to disk, flushing
+// the commit's earlier writes with it.
+func (app *ChainmintApplication) finishCommit(ctx context.Context) (st *strategyState, err error) {
+	now := time.Now()
+	sync := app.durability == nil || app.durability.syncDue(now)
+	if !sync {
+		ctx = sql.WithAsyncCommit(ctx)
+	}
+
+	db := app.backend.DB()
+	if beginner, ok := db.(interface {
+		Begin(context.Context) (*sql.Tx, error)
+	}); ok {
+		var dbtx *sql.Tx
+		dbtx, err = beginner.Begin(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "begin strategy state transaction")
+		}
+		defer func() {
+			if err != nil {
+				dbtx.Rollback(ctx)
+				return
+			}
+			err = errors.Wrap(dbtx.Commit(ctx), "commit strategy state transaction")
+			if err == nil && sync && app.durability != nil {
+				app.durability.lastSync = now
+			}
+		}()
+		db = dbtx
+	}
+
+	st, err = app.persistStrategyState(ctx, db)
+	if err != nil {
+		return st, err
+	}
+	// The commit survives a crash from here on, so its
+	// record is no longer needed.
+	err = deleteCommitRecord(ctx, db)
+	return st, err
+}
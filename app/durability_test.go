@@ -0,0 +1,52 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfiguredDurability(t *testing.T) {
+	saveMode, saveInterval := *commitDurability, *commitFsyncInterval
+	defer func() { *commitDurability, *commitFsyncInterval = saveMode, saveInterval }()
+
+	cases := []struct {
+		mode     string
+		interval time.Duration
+		ok       bool
+	}{
+		{"block", 0, true},
+		{"block", time.Second, false},
+		{"interval", time.Second, true},
+		{"interval", 0, false},
+		{"interval", 2 * maxFsyncInterval, false},
+		{"never", 0, false},
+	}
+	for _, c := range cases {
+		*commitDurability, *commitFsyncInterval = c.mode, c.interval
+		d, err := configuredDurability()
+		if (err == nil) != c.ok {
+			t.Errorf("%s/%s: got error %v, want ok=%v", c.mode, c.interval, err, c.ok)
+			continue
+		}
+		if err == nil && d.interval != c.interval {
+			t.Errorf("%s/%s: interval = %s", c.mode, c.interval, d.interval)
+		}
+	}
+}
+
+func TestDurabilitySyncDue(t *testing.T) {
+	now := time.Now()
+	every := new(durability)
+	every.lastSync = now
+	if !every.syncDue(now) {
+		t.Error("block durability: sync not due")
+	}
+
+	d := &durability{interval: time.Second, lastSync: now}
+	if d.syncDue(now.Add(time.Second / 2)) {
+		t.Error("sync due within the interval")
+	}
+	if !d.syncDue(now.Add(time.Second)) {
+		t.Error("sync not due after the interval")
+	}
+}
@@ -0,0 +1,32 @@
+package app
+
+import (
+	abciTypes "github.com/tendermint/abci/types"
+)
+
+// epochBuffer holds back validator diffs until the end of an
+// epoch. Every kind of power change is held back, including
+// admissions and delegations, so the validator set and the set the
+// strategy pays stay fixed for the whole epoch.
+type epochBuffer struct {
+	// number of blocks in an epoch, set in the chain's genesis
+	// parameters; 0 or 1 reports diffs every block
+	length  uint64
+	pending []*abciTypes.Validator
+}
+
+// release adds the diffs of the block at height to the buffer and
+// returns the diffs to report, which are nil except at the end of
+// an epoch.
+func (e *epochBuffer) release(height uint64, diffs []*abciTypes.Validator) []*abciTypes.Validator {
+	if e.length <= 1 {
+		return diffs
+	}
+	e.pending = mergeDiffs(e.pending, diffs)
+	if height%e.length != 0 {
+		return nil
+	}
+	diffs = e.pending
+	e.pending = nil
+	return diffs
+}
@@ -0,0 +1,34 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+
+	abciTypes "github.com/tendermint/abci/types"
+)
+
+func TestEpochBuffer(t *testing.T) {
+	a := []byte{0xaa}
+	e := epochBuffer{length: 3}
+
+	if got := e.release(1, []*abciTypes.Validator{{PubKey: a, Power: 5}}); got != nil {
+		t.Fatalf("released %v mid-epoch", got)
+	}
+	if got := e.release(2, []*abciTypes.Validator{{PubKey: a, Power: 7}}); got != nil {
+		t.Fatalf("released %v mid-epoch", got)
+	}
+	got := e.release(3, nil)
+	want := []*abciTypes.Validator{{PubKey: a, Power: 7}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("at epoch end got %v, want %v", got, want)
+	}
+	if e.pending != nil {
+		t.Errorf("pending = %v after release", e.pending)
+	}
+
+	// Without epochs, diffs pass straight through.
+	e = epochBuffer{}
+	if got := e.release(1, want); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
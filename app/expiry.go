@@ -0,0 +1,38 @@
+package app
+
+import (
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+// ErrTxExpired is returned for a transaction past its max time or
+// max height, which no block may include any longer.
+var ErrTxExpired = errors.New("transaction expired")
+
+// txMaxHeight returns the last block height at which tx may be
+// included, as set by the max_height field of its reference data,
+// or 0 if it sets none. Heights are Tendermint's, as reported by
+// Info.
+func txMaxHeight(tx *legacy.Tx) uint64 {
+	var ref struct {
+		MaxHeight uint64 `json:"max_height"`
+	}
+	if !parseRef(tx.ReferenceData, &ref) {
+		return 0
+	}
+	return ref.MaxHeight
+}
+
+// checkExpiry checks that a block at height, with timestamp timeMS,
+// may include tx: that neither its max time, which is part of the
+// transaction itself, nor its max height has passed. Both are
+// optional, and 0 means unset.
+func checkExpiry(tx *legacy.Tx, height, timeMS uint64) error {
+	if tx.MaxTime > 0 && tx.MaxTime < timeMS {
+		return errors.WithDetailf(ErrTxExpired, "max time %d is before block time %d", tx.MaxTime, timeMS)
+	}
+	if h := txMaxHeight(tx); h > 0 && h < height {
+		return errors.WithDetailf(ErrTxExpired, "max height %d is below block height %d", h, height)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+func TestCheckExpiry(t *testing.T) {
+	cases := []struct {
+		maxTime uint64
+		ref     string
+		height  uint64
+		timeMS  uint64
+		expired bool
+	}{
+		{height: 10, timeMS: 5000},
+		{maxTime: 5000, height: 10, timeMS: 5000},
+		{maxTime: 4999, height: 10, timeMS: 5000, expired: true},
+		{ref: `{"max_height": 10}`, height: 10, timeMS: 5000},
+		{ref: `{"max_height": 9}`, height: 10, timeMS: 5000, expired: true},
+		{ref: `not json`, height: 10, timeMS: 5000},
+	}
+	for i, c := range cases {
+		tx := &legacy.Tx{TxData: legacy.TxData{MaxTime: c.maxTime, ReferenceData: []byte(c.ref)}}
+		err := checkExpiry(tx, c.height, c.timeMS)
+		if expired := errors.Root(err) == ErrTxExpired; expired != c.expired || (err != nil && !expired) {
+			t.Errorf("%d: checkExpiry = %v, want expired %v", i, err, c.expired)
+		}
+	}
+}
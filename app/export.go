@@ -0,0 +1,187 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	stdjson "encoding/json"
+	"io/ioutil"
+	"sort"
+
+	"github.com/chainmint/database/pg"
+	"github.com/chainmint/encoding/json"
+	"github.com/chainmint/env"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/log"
+	cmtTypes "github.com/chainmint/types"
+	abciTypes "github.com/tendermint/abci/types"
+)
+
+// importStatePath names a document written by /export-staking-state
+// to load in InitChain, to restart the network from exported stake.
+var importStatePath = env.String("IMPORT_STAKING_STATE", "")
+
+// ErrBadImport is returned when an imported staking state cannot be
+// used to start the chain.
+var ErrBadImport = errors.New("invalid staking state import")
+
+// stakingExport is the staking state of the chain at a height.
+// Validators are ordered by public key and the maps in Stake are
+// encoded with sorted keys, so exporting the same state twice gives
+// the same document.
+type stakingExport struct {
+	Height       uint64              `json:"height"`
+	Validators   []exportedValidator `json:"validators"`
+	PendingDiffs []exportedValidator `json:"pending_diffs"`
+	Strategy     json.HexBytes       `json:"strategy"` // opaque, see types.PersistentStrategy
+	Governance   *governance         `json:"governance"`
+	Stake        *stakeBook          `json:"stake"`
+}
+
+type exportedValidator struct {
+	PubKey json.HexBytes `json:"pub_key"`
+	Power  uint64        `json:"power"`
+}
+
+func exportValidators(vals []*abciTypes.Validator) []exportedValidator {
+	res := make([]exportedValidator, 0, len(vals))
+	for _, v := range vals {
+		res = append(res, exportedValidator{PubKey: v.PubKey, Power: v.Power})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return bytes.Compare(res[i].PubKey, res[j].PubKey) < 0
+	})
+	return res
+}
+
+func importValidators(vals []exportedValidator) []*abciTypes.Validator {
+	res := make([]*abciTypes.Validator, 0, len(vals))
+	for _, v := range vals {
+		res = append(res, &abciTypes.Validator{PubKey: v.PubKey, Power: v.Power})
+	}
+	return res
+}
+
+// ExportState returns the staking state committed at the given
+// height, or at the last committed height if height is 0. It is
+// installed as the Core's state exporter by Init.
+func (app *ChainmintApplication) ExportState(ctx context.Context, height uint64) (interface{}, error) {
+	st, err := loadStrategyStateAt(ctx, app.backend.DB(), height)
+	if err != nil {
+		return nil, err
+	}
+	if st == nil {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "no staking state at height %d", height)
+	}
+	return newStakingExport(st)
+}
+
+func newStakingExport(st *strategyState) (*stakingExport, error) {
+	doc := &stakingExport{
+		Height:       st.Height,
+		Validators:   exportValidators(st.Validators),
+		PendingDiffs: exportValidators(st.PendingDiffs),
+		Strategy:     st.Data,
+		Governance:   new(governance),
+		Stake:        newStakeBook(),
+	}
+	if len(st.Governance) > 0 {
+		err := stdjson.Unmarshal(st.Governance, doc.Governance)
+		if err != nil {
+			return nil, errors.Wrap(err, "unmarshaling governance state")
+		}
+	}
+	if len(st.Stake) > 0 {
+		err := stdjson.Unmarshal(st.Stake, doc.Stake)
+		if err != nil {
+			return nil, errors.Wrap(err, "unmarshaling stake state")
+		}
+	}
+	return doc, nil
+}
+
+// importStateFile loads the staking state exported to path, which
+// must have the same validators as the genesis validators.
+func (app *ChainmintApplication) importStateFile(path string, genesis []*abciTypes.Validator) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "reading staking state")
+	}
+	doc := new(stakingExport)
+	err = stdjson.Unmarshal(b, doc)
+	if err != nil {
+		return errors.Sub(ErrBadImport, err)
+	}
+	return app.importState(doc, genesis)
+}
+
+// importState restores the staking state in doc at the start of a
+// new chain. Proposal deadlines and activation heights are moved so
+// that they fall the same number of blocks after the new chain's
+// start as they fell after the export height.
+func (app *ChainmintApplication) importState(doc *stakingExport, genesis []*abciTypes.Validator) error {
+	vals := importValidators(doc.Validators)
+	if !sameValidators(vals, genesis) {
+		return errors.WithDetail(ErrBadImport, "genesis validators differ from the exported validators")
+	}
+
+	if ps, ok := app.strategy.(cmtTypes.PersistentStrategy); ok && len(doc.Strategy) > 0 {
+		err := ps.UnmarshalState(doc.Strategy)
+		if err != nil {
+			return errors.Wrap(err, "importing strategy state")
+		}
+	}
+	if doc.Governance != nil {
+		app.governance.Proposals = doc.Governance.Proposals
+		app.governance.Parameters = doc.Governance.Parameters
+		app.governance.Policy = doc.Governance.Policy
+		app.governance.Upgrades = doc.Governance.Upgrades
+		app.governance.rebase(doc.Height)
+		app.applyChanges(&app.governance.Parameters, &app.governance.Policy)
+		for _, u := range app.governance.Upgrades {
+			err := app.scheduleUpgrade(context.Background(), u.Name, u.Height)
+			if err != nil {
+				return errors.Wrap(err, "importing upgrade schedule")
+			}
+		}
+	}
+	if doc.Stake != nil {
+		app.stake = doc.Stake
+		if app.stake.Delegations == nil {
+			app.stake.Delegations = make(map[string]map[string]uint64)
+		}
+		if app.stake.Commissions == nil {
+			app.stake.Commissions = make(map[string]uint64)
+		}
+		if app.stake.Accruals == nil {
+			app.stake.Accruals = make(map[string]uint64)
+		}
+		for v, rate := range app.stake.Commissions {
+			pubkey, _ := hex.DecodeString(v)
+			app.SetCommission(pubkey, rate)
+		}
+	}
+	app.validators = vals
+	app.epoch.pending = importValidators(doc.PendingDiffs)
+	log.Printkv(context.Background(), "at", "imported staking state", "export_height", doc.Height, "validators", len(vals))
+	return nil
+}
+
+// sameValidators reports whether a and b have the same validators
+// with the same power, in any order.
+func sameValidators(a, b []*abciTypes.Validator) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	power := make(map[string]uint64, len(a))
+	for _, v := range a {
+		power[string(v.PubKey)] = v.Power
+	}
+	for _, v := range b {
+		p, ok := power[string(v.PubKey)]
+		if !ok || p != v.Power {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/chainmint/errors"
+	abciTypes "github.com/tendermint/abci/types"
+)
+
+func TestStakingExportRoundTrip(t *testing.T) {
+	g := &governance{Proposals: []*proposal{{Deadline: 110, Activation: 115}}}
+	book := newStakeBook()
+	book.Delegations["aa"] = map[string]uint64{"01": 5}
+	book.Commissions["aa"] = 500
+	govJSON, _ := json.Marshal(g)
+	stakeJSON, _ := json.Marshal(book)
+
+	st := &strategyState{
+		Height:       100,
+		Validators:   []*abciTypes.Validator{{PubKey: []byte{0xbb}, Power: 2}, {PubKey: []byte{0xaa}, Power: 1}},
+		PendingDiffs: []*abciTypes.Validator{{PubKey: []byte{0xcc}, Power: 3}},
+		Governance:   govJSON,
+		Stake:        stakeJSON,
+	}
+	doc, err := newStakingExport(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(doc.Validators[0].PubKey) != "\xaa" {
+		t.Errorf("validators not ordered by public key: %x first", []byte(doc.Validators[0].PubKey))
+	}
+
+	// The document must survive encoding unchanged.
+	b1, _ := json.Marshal(doc)
+	var decoded stakingExport
+	if err := json.Unmarshal(b1, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	b2, _ := json.Marshal(&decoded)
+	if string(b1) != string(b2) {
+		t.Errorf("re-encoded export differs:\n%s\n%s", b1, b2)
+	}
+
+	app := &ChainmintApplication{
+		governance: &governance{},
+		stake:      newStakeBook(),
+	}
+	wrong := []*abciTypes.Validator{{PubKey: []byte{0xaa}, Power: 1}}
+	if err := app.importState(&decoded, wrong); errors.Root(err) != ErrBadImport {
+		t.Errorf("importState(wrong genesis) = %v, want %v", err, ErrBadImport)
+	}
+
+	err = app.importState(&decoded, st.Validators)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(app.validators) != 2 || len(app.epoch.pending) != 1 {
+		t.Errorf("imported %d validators, %d pending diffs, want 2, 1", len(app.validators), len(app.epoch.pending))
+	}
+	if app.stake.Delegations["aa"]["01"] != 5 || app.stake.Commissions["aa"] != 500 {
+		t.Errorf("imported stake book = %+v", app.stake)
+	}
+	p := app.governance.Proposals[0]
+	if p.Deadline != 10 || p.Activation != 15 {
+		t.Errorf("imported deadline, activation = %d, %d, want 10, 15", p.Deadline, p.Activation)
+	}
+}
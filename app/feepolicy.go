@@ -0,0 +1,101 @@
+package app
+
+import (
+	"io/ioutil"
+
+	"github.com/chainmint/env"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/math/checked"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/strategies"
+)
+
+var (
+	// minTxFee is the flat fee, in units of the fee asset, every
+	// transaction must pay.
+	minTxFee = env.Int("MIN_TX_FEE", 0)
+
+	// txFeeByteRate is the further fee, in units of the fee asset,
+	// a transaction must pay for each byte of its serialization.
+	txFeeByteRate = env.Int("TX_FEE_BYTE_RATE", 0)
+
+	// feeExemptAssets are the IDs of assets whose transactions pay
+	// no minimum fee: those moving only these assets.
+	feeExemptAssets = env.StringSlice("FEE_EXEMPT_ASSETS")
+)
+
+// ErrFeeTooLow is returned when a transaction's fee is below the
+// minimum set by the node's fee policy.
+var ErrFeeTooLow = errors.New("fee below minimum")
+
+// feePolicy sets the minimum fee a transaction must pay: a flat
+// amount plus a rate per byte. Transactions moving only exempt
+// assets pay no minimum.
+type feePolicy struct {
+	Min      uint64
+	ByteRate uint64
+	Exempt   map[bc.AssetID]bool
+}
+
+// configuredFeePolicy returns the fee policy set by the
+// environment. Negative amounts are treated as 0.
+func configuredFeePolicy() (feePolicy, error) {
+	p := feePolicy{Exempt: make(map[bc.AssetID]bool)}
+	if *minTxFee > 0 {
+		p.Min = uint64(*minTxFee)
+	}
+	if *txFeeByteRate > 0 {
+		p.ByteRate = uint64(*txFeeByteRate)
+	}
+	for _, s := range *feeExemptAssets {
+		var id bc.AssetID
+		err := id.UnmarshalText([]byte(s))
+		if err != nil {
+			return p, errors.Wrapf(err, "parsing FEE_EXEMPT_ASSETS entry %q", s)
+		}
+		p.Exempt[id] = true
+	}
+	return p, nil
+}
+
+// exempt reports whether every asset tx moves is exempt.
+func (p feePolicy) exempt(tx *legacy.Tx) bool {
+	if len(p.Exempt) == 0 {
+		return false
+	}
+	for _, in := range tx.Inputs {
+		if !p.Exempt[in.AssetID()] {
+			return false
+		}
+	}
+	for _, out := range tx.Outputs {
+		if out.AssetId == nil || !p.Exempt[*out.AssetId] {
+			return false
+		}
+	}
+	return true
+}
+
+// check checks that tx pays at least the minimum fee, in feeAsset.
+// Without a fee asset nothing is enforced. Like checkMemo, the
+// policy is set by each node and so applies only to admission to
+// the mempool.
+func (p feePolicy) check(tx *legacy.Tx, feeAsset bc.AssetID) error {
+	if (p.Min == 0 && p.ByteRate == 0) || feeAsset == (bc.AssetID{}) || p.exempt(tx) {
+		return nil
+	}
+	n, err := tx.WriteTo(ioutil.Discard)
+	if err != nil {
+		return errors.Wrap(err, "serializing transaction")
+	}
+	due, ok := checked.MulUint64(uint64(n), p.ByteRate)
+	if ok {
+		due, ok = checked.AddUint64(due, p.Min)
+	}
+	fee := strategies.Fee(tx, feeAsset)
+	if !ok || fee < due {
+		return errors.WithDetailf(ErrFeeTooLow, "fee %d, minimum %d for %d bytes", fee, due, n)
+	}
+	return nil
+}
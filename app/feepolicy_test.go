@@ -0,0 +1,60 @@
+package app
+
+import (
+	"io/ioutil"
+	"math"
+	"testing"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/vm"
+)
+
+func TestFeePolicy(t *testing.T) {
+	feeAsset := bc.NewAssetID([32]byte{1})
+	exemptAsset := bc.NewAssetID([32]byte{2})
+	feeTx := func(fee uint64, assets ...bc.AssetID) *legacy.Tx {
+		tx := &legacy.Tx{TxData: legacy.TxData{
+			Outputs: []*legacy.TxOutput{
+				legacy.NewTxOutput(feeAsset, fee, []byte{byte(vm.OP_FAIL)}, nil),
+			},
+		}}
+		for _, a := range assets {
+			tx.Outputs = append(tx.Outputs, legacy.NewTxOutput(a, 1, []byte{0x51}, nil))
+		}
+		return tx
+	}
+	size := func(tx *legacy.Tx) uint64 {
+		n, err := tx.WriteTo(ioutil.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return uint64(n)
+	}
+	n := size(feeTx(10))
+
+	exempt := map[bc.AssetID]bool{feeAsset: true, exemptAsset: true}
+	cases := []struct {
+		tx       *legacy.Tx
+		feeAsset bc.AssetID
+		policy   feePolicy
+		want     error
+	}{
+		{feeTx(10), feeAsset, feePolicy{}, nil},
+		{feeTx(10), feeAsset, feePolicy{Min: 10}, nil},
+		{feeTx(9), feeAsset, feePolicy{Min: 10}, ErrFeeTooLow},
+		{feeTx(10 + 2*n), feeAsset, feePolicy{Min: 10, ByteRate: 2}, nil},
+		{feeTx(9 + 2*n), feeAsset, feePolicy{Min: 10, ByteRate: 2}, ErrFeeTooLow},
+		{feeTx(10), feeAsset, feePolicy{ByteRate: math.MaxUint64}, ErrFeeTooLow},
+		{feeTx(0), bc.AssetID{}, feePolicy{Min: 10}, nil}, // no fee asset
+		{feeTx(0, exemptAsset), feeAsset, feePolicy{Min: 10, Exempt: exempt}, nil},
+		{feeTx(0, exemptAsset, bc.NewAssetID([32]byte{3})), feeAsset, feePolicy{Min: 10, Exempt: exempt}, ErrFeeTooLow},
+	}
+	for i, c := range cases {
+		err := c.policy.check(c.tx, c.feeAsset)
+		if errors.Root(err) != c.want {
+			t.Errorf("case %d: check = %v, want %v", i, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,367 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	stdjson "encoding/json"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/chainmint/crypto/ed25519"
+	"github.com/chainmint/crypto/provider"
+	"github.com/chainmint/encoding/json"
+	"github.com/chainmint/env"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/log"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/upgrade"
+	"github.com/chainmint/strategies"
+	abciTypes "github.com/tendermint/abci/types"
+)
+
+// genesisPath names a genesis document written by GenesisSpec.Build.
+// Tendermint reads its validators, and InitChain its app options.
+var genesisPath = env.String("GENESIS_FILE", "")
+
+// ErrBadGenesis is returned for a genesis spec or document that
+// cannot start a network.
+var ErrBadGenesis = errors.New("invalid genesis")
+
+// GenesisSpec declares a new network: its initial validators, the
+// assets issued when it starts, the chain parameters, validator
+// admission rules and governance voting rules it starts with, which
+// nil fields leave at their defaults, the upgrades it schedules,
+// its compensation strategy, defaultStrategy unless Strategy names
+// another, its crypto provider, provider.Default unless
+// CryptoProvider names another, and what its app hash commits to,
+// which is the app state root unless AppHashFormat is "block".
+//
+// Validators are added in a ceremony: each operator signs an entry
+// for their validator with SignGenesisValidator, and the coordinator
+// collects the entries with AddValidator, which checks each is
+// signed by the key it names.
+type GenesisSpec struct {
+	ChainID     string             `json:"chain_id"`
+	GenesisTime time.Time          `json:"genesis_time"`
+	Validators  []GenesisValidator `json:"validators"`
+	Issuances   []*legacy.Tx       `json:"issuances"` // signed issuance txs
+	Parameters  *paramChanges      `json:"parameters,omitempty"`
+	Policy      *policyChanges     `json:"policy,omitempty"`
+	Voting      *votingRules       `json:"voting,omitempty"`
+
+	Upgrades       []upgrade.Activation `json:"upgrades,omitempty"`
+	Strategy       string               `json:"strategy,omitempty"`
+	CryptoProvider string               `json:"crypto_provider,omitempty"`
+	AppHashFormat  string               `json:"app_hash_format,omitempty"`
+}
+
+// GenesisValidator is an initial validator, signed by its key to
+// show that its operator holds the key.
+type GenesisValidator struct {
+	Name      string        `json:"name"`
+	PubKey    json.HexBytes `json:"pub_key"`
+	Power     uint64        `json:"power"`
+	Signature json.HexBytes `json:"signature"`
+}
+
+// genesisAppOptions is the chainmint part of a genesis document.
+type genesisAppOptions struct {
+	Issuances  []*legacy.Tx   `json:"issuances"`
+	Parameters *paramChanges  `json:"parameters,omitempty"`
+	Policy     *policyChanges `json:"policy,omitempty"`
+	Voting     *votingRules   `json:"voting,omitempty"`
+
+	// Upgrades are the upgrades the chain schedules from its start.
+	Upgrades []upgrade.Activation `json:"upgrades,omitempty"`
+
+	// Strategy names the chain's compensation strategy. A document
+	// without it, as written before it existed, names the default.
+	Strategy string `json:"strategy,omitempty"`
+
+	// CryptoProvider names the chain's crypto provider. A document
+	// without it, as written before it existed, names the default.
+	CryptoProvider string `json:"crypto_provider,omitempty"`
+
+	// AppHashFormat is what the chain's app hash commits to. A
+	// document without it, as written before it existed, commits to
+	// the block hash.
+	AppHashFormat string `json:"app_hash_format,omitempty"`
+}
+
+// genesisDoc is a Tendermint genesis document, with the chainmint
+// genesis in its app options.
+type genesisDoc struct {
+	GenesisTime time.Time          `json:"genesis_time"`
+	ChainID     string             `json:"chain_id"`
+	Validators  []genesisDocVal    `json:"validators"`
+	AppHash     json.HexBytes      `json:"app_hash"`
+	AppOptions  *genesisAppOptions `json:"app_options"`
+}
+
+type genesisDocVal struct {
+	PubKey struct {
+		Type string        `json:"type"`
+		Data json.HexBytes `json:"data"`
+	} `json:"pub_key"`
+	Amount int64  `json:"amount"`
+	Name   string `json:"name"`
+}
+
+// genesisValidatorMessage returns the message an operator signs to
+// contribute a validator to the genesis of chainID. It covers the
+// chain ID so that an entry cannot be replayed into another network.
+func genesisValidatorMessage(chainID string, v *GenesisValidator) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("chainmint-genesis:")
+	for _, s := range [][]byte{[]byte(chainID), []byte(v.Name), v.PubKey} {
+		binary.Write(&buf, binary.BigEndian, uint32(len(s)))
+		buf.Write(s)
+	}
+	binary.Write(&buf, binary.BigEndian, v.Power)
+	return buf.Bytes()
+}
+
+// SignGenesisValidator returns the genesis entry for the validator
+// with key priv, named name, with the given power on chainID.
+func SignGenesisValidator(chainID, name string, power uint64, priv ed25519.PrivateKey) GenesisValidator {
+	v := GenesisValidator{
+		Name:   name,
+		PubKey: json.HexBytes(priv.Public().(ed25519.PublicKey)),
+		Power:  power,
+	}
+	v.Signature = ed25519.Sign(priv, genesisValidatorMessage(chainID, &v))
+	return v
+}
+
+// checkValidator checks that v is a well-formed entry for s, signed
+// by its key, that repeats the key or name of none of others.
+func (s *GenesisSpec) checkValidator(v *GenesisValidator, others []GenesisValidator) error {
+	if len(v.PubKey) != ed25519.PublicKeySize {
+		return errors.WithDetailf(ErrBadGenesis, "validator %q: bad public key length %d", v.Name, len(v.PubKey))
+	}
+	if v.Power == 0 {
+		return errors.WithDetailf(ErrBadGenesis, "validator %q: zero power", v.Name)
+	}
+	if !ed25519.Verify(ed25519.PublicKey(v.PubKey), genesisValidatorMessage(s.ChainID, v), v.Signature) {
+		return errors.WithDetailf(ErrBadGenesis, "validator %q: invalid signature for chain %q", v.Name, s.ChainID)
+	}
+	for _, o := range others {
+		if bytes.Equal(o.PubKey, v.PubKey) {
+			return errors.WithDetailf(ErrBadGenesis, "validator %q: key %x already added as %q", v.Name, []byte(v.PubKey), o.Name)
+		}
+		if v.Name != "" && o.Name == v.Name {
+			return errors.WithDetailf(ErrBadGenesis, "validator name %q already added", v.Name)
+		}
+	}
+	return nil
+}
+
+// AddValidator adds the validator entry v to s, after checking it.
+func (s *GenesisSpec) AddValidator(v GenesisValidator) error {
+	err := s.checkValidator(&v, s.Validators)
+	if err != nil {
+		return err
+	}
+	s.Validators = append(s.Validators, v)
+	return nil
+}
+
+// Validate checks that s can start a network.
+func (s *GenesisSpec) Validate() error {
+	if s.ChainID == "" {
+		return errors.WithDetail(ErrBadGenesis, "missing chain_id")
+	}
+	if len(s.Validators) == 0 {
+		return errors.WithDetail(ErrBadGenesis, "no validators")
+	}
+	for i := range s.Validators {
+		err := s.checkValidator(&s.Validators[i], s.Validators[:i])
+		if err != nil {
+			return err
+		}
+	}
+	switch s.AppHashFormat {
+	case "", appHashBlock, appHashStateRoot:
+	default:
+		return errors.WithDetailf(ErrBadGenesis, "unknown app_hash_format %q", s.AppHashFormat)
+	}
+	if s.Strategy != "" {
+		_, err := strategies.New(s.Strategy, strategies.Config{})
+		if err != nil {
+			return errors.Sub(ErrBadGenesis, err)
+		}
+	}
+	if s.CryptoProvider != "" {
+		_, err := provider.Lookup(s.CryptoProvider)
+		if err != nil {
+			return errors.Sub(ErrBadGenesis, err)
+		}
+	}
+	if s.Policy != nil {
+		err := s.Policy.check(ErrBadGenesis)
+		if err != nil {
+			return err
+		}
+	}
+	if s.Voting != nil {
+		err := s.Voting.check()
+		if err != nil {
+			return err
+		}
+	}
+	err := checkActivations(s.Upgrades)
+	if err != nil {
+		return err
+	}
+	for i, tx := range s.Issuances {
+		if tx == nil || len(tx.Inputs) == 0 {
+			return errors.WithDetailf(ErrBadGenesis, "issuance %d: no inputs", i)
+		}
+		for _, in := range tx.Inputs {
+			if !in.IsIssuance() {
+				return errors.WithDetailf(ErrBadGenesis, "issuance %d: spends an output", i)
+			}
+		}
+	}
+	return nil
+}
+
+// Build returns the genesis document for s, to be given to both
+// Tendermint, as its genesis file, and chainmint, as GENESIS_FILE.
+// Validators are ordered by public key, so that the same spec
+// always builds the same document.
+func (s *GenesisSpec) Build() ([]byte, error) {
+	err := s.Validate()
+	if err != nil {
+		return nil, err
+	}
+	vals := append([]GenesisValidator(nil), s.Validators...)
+	sort.Slice(vals, func(i, j int) bool {
+		return bytes.Compare(vals[i].PubKey, vals[j].PubKey) < 0
+	})
+	doc := &genesisDoc{
+		GenesisTime: s.GenesisTime.UTC(),
+		ChainID:     s.ChainID,
+		AppOptions: &genesisAppOptions{
+			Issuances:      s.Issuances,
+			Parameters:     s.Parameters,
+			Policy:         s.Policy,
+			Voting:         s.Voting,
+			Upgrades:       s.Upgrades,
+			Strategy:       s.Strategy,
+			CryptoProvider: s.CryptoProvider,
+			AppHashFormat:  s.AppHashFormat,
+		},
+	}
+	if doc.AppOptions.Issuances == nil {
+		doc.AppOptions.Issuances = []*legacy.Tx{}
+	}
+	if doc.AppOptions.Strategy == "" {
+		doc.AppOptions.Strategy = defaultStrategy
+	}
+	if doc.AppOptions.CryptoProvider == "" {
+		doc.AppOptions.CryptoProvider = provider.Default
+	}
+	if doc.AppOptions.AppHashFormat == "" {
+		doc.AppOptions.AppHashFormat = appHashStateRoot
+	}
+	for _, v := range vals {
+		var dv genesisDocVal
+		dv.PubKey.Type = "ed25519"
+		dv.PubKey.Data = v.PubKey
+		dv.Amount = int64(v.Power)
+		dv.Name = v.Name
+		doc.Validators = append(doc.Validators, dv)
+	}
+	return stdjson.MarshalIndent(doc, "", "  ")
+}
+
+// loadGenesisFile starts the chain from the app options of the
+// genesis document at path, whose validators must be the genesis
+// validators Tendermint gave InitChain.
+func (app *ChainmintApplication) loadGenesisFile(ctx context.Context, path string, genesis []*abciTypes.Validator) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "reading genesis")
+	}
+	doc := new(genesisDoc)
+	err = stdjson.Unmarshal(b, doc)
+	if err != nil {
+		return errors.Sub(ErrBadGenesis, err)
+	}
+	var vals []*abciTypes.Validator
+	for _, v := range doc.Validators {
+		vals = append(vals, &abciTypes.Validator{PubKey: v.PubKey.Data, Power: uint64(v.Amount)})
+	}
+	if !sameValidators(vals, genesis) {
+		return errors.WithDetail(ErrBadGenesis, "genesis validators differ from the genesis file's")
+	}
+	opts := doc.AppOptions
+	strategyName, cryptoName, format := defaultStrategy, provider.Default, appHashBlock
+	if opts != nil && opts.Strategy != "" {
+		strategyName = opts.Strategy
+	}
+	if opts != nil && opts.CryptoProvider != "" {
+		cryptoName = opts.CryptoProvider
+	}
+	if opts != nil && opts.AppHashFormat != "" {
+		format = opts.AppHashFormat
+	}
+	err = app.startStrategy(ctx, strategyName)
+	if err != nil {
+		return errors.Sub(ErrBadGenesis, err)
+	}
+	err = app.startCryptoProvider(ctx, cryptoName)
+	if err != nil {
+		return errors.Sub(ErrBadGenesis, err)
+	}
+	err = app.startAppHashFormat(ctx, format)
+	if err != nil {
+		return errors.Sub(ErrBadGenesis, err)
+	}
+	if opts == nil {
+		return nil
+	}
+
+	if opts.Parameters != nil {
+		app.governance.Parameters.merge(opts.Parameters)
+	}
+	if opts.Policy != nil {
+		app.governance.Policy.merge(opts.Policy)
+	}
+	if opts.Voting != nil {
+		err = opts.Voting.check()
+		if err != nil {
+			return err
+		}
+		app.governance.setRules(opts.Voting)
+	}
+	app.applyChanges(&app.governance.Parameters, &app.governance.Policy)
+	err = checkActivations(opts.Upgrades)
+	if err != nil {
+		return err
+	}
+	for _, u := range opts.Upgrades {
+		err = app.scheduleUpgrade(ctx, u.Name, u.Height)
+		if err != nil {
+			return errors.Sub(ErrBadGenesis, err)
+		}
+		app.governance.Upgrades = append(app.governance.Upgrades, u)
+	}
+
+	// The issuances go into the chain's first block, ahead of the
+	// txs of the first Tendermint block, as every node delivers them
+	// to its generator here.
+	for _, tx := range opts.Issuances {
+		sctx, cancel := context.WithTimeout(ctx, *generatorTimeout)
+		err = app.backend.Generator().Deliver(sctx, tx, 0)
+		cancel()
+		if err != nil {
+			return errors.Wrapf(err, "delivering genesis issuance %x", tx.ID.Bytes())
+		}
+	}
+	log.Printkv(ctx, "at", "loaded genesis", "chain_id", doc.ChainID, "validators", len(vals), "issuances", len(opts.Issuances))
+	return nil
+}
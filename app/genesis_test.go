@@ -0,0 +1,125 @@
+package app
+
+import (
+	stdjson "encoding/json"
+	"testing"
+	"time"
+
+	"github.com/chainmint/crypto/ed25519"
+	"github.com/chainmint/crypto/provider"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/upgrade"
+)
+
+func TestGenesisCeremony(t *testing.T) {
+	_, privA, _ := ed25519.GenerateKey(nil)
+	_, privB, _ := ed25519.GenerateKey(nil)
+	spec := &GenesisSpec{ChainID: "testnet", GenesisTime: time.Unix(1500000000, 0)}
+
+	err := spec.AddValidator(SignGenesisValidator("testnet", "a", 10, privA))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := SignGenesisValidator("testnet", "b", 10, privB)
+	tampered.Power = 20
+	bad := []GenesisValidator{
+		SignGenesisValidator("othernet", "b", 10, privB), // signed for another chain
+		tampered,
+		SignGenesisValidator("testnet", "b", 0, privB),
+		SignGenesisValidator("testnet", "a2", 10, privA), // same key
+		SignGenesisValidator("testnet", "a", 10, privB),  // same name
+	}
+	for i, v := range bad {
+		err := spec.AddValidator(v)
+		if errors.Root(err) != ErrBadGenesis {
+			t.Errorf("%d: AddValidator = %v, want %v", i, err, ErrBadGenesis)
+		}
+	}
+
+	err = spec.AddValidator(SignGenesisValidator("testnet", "b", 20, privB))
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss := legacy.NewTx(legacy.TxData{
+		Version: 1,
+		Inputs:  []*legacy.TxInput{legacy.NewIssuanceInput([]byte{1}, 5, nil, bc.Hash{}, []byte{1}, nil, nil)},
+	})
+	spec.Issuances = append(spec.Issuances, iss)
+
+	b, err := spec.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc genesisDoc
+	err = stdjson.Unmarshal(b, &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.ChainID != "testnet" || len(doc.Validators) != 2 {
+		t.Fatalf("built chain %q with %d validators, want testnet with 2", doc.ChainID, len(doc.Validators))
+	}
+	for _, v := range doc.Validators {
+		if v.PubKey.Type != "ed25519" || len(v.PubKey.Data) != ed25519.PublicKeySize {
+			t.Errorf("validator %q pub_key = %+v", v.Name, v.PubKey)
+		}
+	}
+	if got := doc.AppOptions.Issuances; len(got) != 1 || got[0].ID != iss.ID {
+		t.Errorf("built issuances %v, want %x", got, iss.ID.Bytes())
+	}
+	if doc.AppOptions.AppHashFormat != appHashStateRoot {
+		t.Errorf("built app_hash_format %q, want %q", doc.AppOptions.AppHashFormat, appHashStateRoot)
+	}
+	if doc.AppOptions.CryptoProvider != provider.Default {
+		t.Errorf("built crypto_provider %q, want %q", doc.AppOptions.CryptoProvider, provider.Default)
+	}
+
+	if doc.AppOptions.Strategy != defaultStrategy {
+		t.Errorf("built strategy %q, want %q", doc.AppOptions.Strategy, defaultStrategy)
+	}
+
+	spec.Strategy = "no-such-strategy"
+	_, err = spec.Build()
+	if errors.Root(err) != ErrBadGenesis {
+		t.Errorf("Build with an unknown strategy = %v, want %v", err, ErrBadGenesis)
+	}
+	spec.Strategy = ""
+
+	spec.CryptoProvider = "no-such-provider"
+	_, err = spec.Build()
+	if errors.Root(err) != ErrBadGenesis {
+		t.Errorf("Build with an unknown crypto provider = %v, want %v", err, ErrBadGenesis)
+	}
+	spec.CryptoProvider = ""
+
+	quorum := uint64(10001)
+	spec.Voting = &votingRules{Quorum: &quorum}
+	_, err = spec.Build()
+	if errors.Root(err) != ErrBadGenesis {
+		t.Errorf("Build with a quorum above 10000 = %v, want %v", err, ErrBadGenesis)
+	}
+	spec.Voting = nil
+
+	maxVals := -1
+	spec.Policy = &policyChanges{MaxValidators: &maxVals}
+	_, err = spec.Build()
+	if errors.Root(err) != ErrBadGenesis {
+		t.Errorf("Build with negative max validators = %v, want %v", err, ErrBadGenesis)
+	}
+	spec.Policy = nil
+
+	spec.Upgrades = []upgrade.Activation{{Name: "v2", Height: 10}, {Name: "v2", Height: 20}}
+	_, err = spec.Build()
+	if errors.Root(err) != ErrBadGenesis {
+		t.Errorf("Build scheduling an upgrade twice = %v, want %v", err, ErrBadGenesis)
+	}
+	spec.Upgrades = nil
+
+	spec.ChainID = "renamed"
+	_, err = spec.Build()
+	if errors.Root(err) != ErrBadGenesis {
+		t.Errorf("Build after changing the chain ID = %v, want %v", err, ErrBadGenesis)
+	}
+}
@@ -0,0 +1,464 @@
+package app
+
+import (
+	stdjson "encoding/json"
+	"math/big"
+
+	"github.com/chainmint/crypto/provider"
+	"github.com/chainmint/encoding/json"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/upgrade"
+	abciTypes "github.com/tendermint/abci/types"
+)
+
+// Default voting rules, for a network whose genesis sets none.
+const (
+	defaultVotingPeriod    = 10000 // blocks
+	defaultActivationDelay = 1000  // blocks after the deadline
+	defaultQuorum          = 3334  // basis points of total power
+	defaultThreshold       = 5000  // basis points of power voted
+)
+
+// Errors returned when a proposal or vote is rejected. Like the
+// admission errors, their messages are part of the DeliverTx result.
+var (
+	ErrBadProposal     = errors.New("malformed governance proposal")
+	ErrBadVote         = errors.New("malformed governance vote")
+	ErrUnknownProposal = errors.New("unknown governance proposal")
+	ErrVotingClosed    = errors.New("voting on proposal has closed")
+)
+
+// Proposal kinds.
+const (
+	proposeParameterChange = "parameter_change"
+	proposeValidatorPolicy = "validator_policy"
+	proposeSoftwareUpgrade = "software_upgrade"
+)
+
+// Governance actions are carried in transaction reference data:
+//
+//	{"propose": {"kind": <kind>, "parameters": {...}, "policy": {...}, "upgrade": {...}}}
+//	{"vote": {"proposal": <tx id>, "pub_key": <hex>, "yes": <bool>, "signature": <hex>}}
+//
+// A proposal is identified by the ID of the transaction that
+// made it. Voting is open to validators, each weighted by its
+// power, until the deadline height. Accepted proposals take effect
+// at the activation height.
+type (
+	proposalRequest struct {
+		Kind       string         `json:"kind"`
+		Parameters *paramChanges  `json:"parameters,omitempty"`
+		Policy     *policyChanges `json:"policy,omitempty"`
+		Upgrade    *upgradeSignal `json:"upgrade,omitempty"`
+	}
+	voteRequest struct {
+		Proposal  bc.Hash       `json:"proposal"`
+		PubKey    json.HexBytes `json:"pub_key"`
+		Yes       bool          `json:"yes"`
+		Signature json.HexBytes `json:"signature"`
+	}
+)
+
+// paramChanges are changes to chain parameters. Nil fields are
+// left unchanged. The epoch length is set only in genesis, so that
+// epochs keep the same boundaries for the life of the chain.
+type paramChanges struct {
+	UnbondingPeriodMS *uint64 `json:"unbonding_period_ms,omitempty"`
+	EpochLength       *uint64 `json:"epoch_length,omitempty"`    // blocks; 0 or 1 means no epochs
+	MaxBlockTxs       *uint64 `json:"max_block_txs,omitempty"`   // 0 means the protocol default
+	MaxBlockBytes     *uint64 `json:"max_block_bytes,omitempty"` // 0 means no limit
+}
+
+// policyChanges are changes to the validator admission rules.
+// Nil fields are left unchanged. The bond asset is set only in
+// genesis, as changing it would strand the bonds already posted.
+type policyChanges struct {
+	BondAsset     *bc.AssetID `json:"bond_asset,omitempty"`
+	MinBond       *uint64     `json:"min_bond,omitempty"`
+	MaxValidators *int        `json:"max_validators,omitempty"`
+	MaxCommission *uint64     `json:"max_commission,omitempty"` // basis points
+}
+
+// upgradeSignal announces a software upgrade. Accepting it schedules
+// the named upgrade to activate at Height, a chain block height, so
+// that from then on blocks follow its rules; nodes without it halt
+// below Height.
+type upgradeSignal struct {
+	Name   string `json:"name"`
+	Height uint64 `json:"height"`
+}
+
+// proposal is a proposal being voted on or awaiting activation.
+type proposal struct {
+	ID         bc.Hash         `json:"id"`
+	Request    proposalRequest `json:"request"`
+	Deadline   uint64          `json:"deadline"`
+	Activation uint64          `json:"activation"`
+	Votes      []vote          `json:"votes"`
+	Accepted   bool            `json:"accepted"`
+}
+
+type vote struct {
+	PubKey json.HexBytes `json:"pub_key"`
+	Yes    bool          `json:"yes"`
+}
+
+// votingRules are the voting rules a network starts with, set in
+// its genesis. Nil fields are left at their defaults.
+type votingRules struct {
+	VotingPeriod    *uint64 `json:"voting_period,omitempty"`    // blocks
+	ActivationDelay *uint64 `json:"activation_delay,omitempty"` // blocks after the deadline
+	Quorum          *uint64 `json:"quorum,omitempty"`           // basis points of total power
+	Threshold       *uint64 `json:"threshold,omitempty"`        // basis points of power voted
+}
+
+// check returns an error if r could not decide a vote.
+func (r *votingRules) check() error {
+	if r.VotingPeriod != nil && *r.VotingPeriod == 0 {
+		return errors.WithDetail(ErrBadGenesis, "zero voting period")
+	}
+	if r.Quorum != nil && *r.Quorum > 10000 {
+		return errors.WithDetail(ErrBadGenesis, "quorum above 10000 basis points")
+	}
+	if r.Threshold != nil && *r.Threshold >= 10000 {
+		return errors.WithDetail(ErrBadGenesis, "threshold of 10000 basis points or more")
+	}
+	return nil
+}
+
+// governance tracks open and accepted proposals, the changes
+// accepted proposals have made to the genesis parameters, and
+// the voting rules. Like the rest of its state, the voting rules
+// are the same on every node: they come from genesis, not from the
+// node's configuration.
+type governance struct {
+	VotingPeriod    uint64 `json:"voting_period"`
+	ActivationDelay uint64 `json:"activation_delay"`
+	Quorum          uint64 `json:"quorum"`    // basis points
+	Threshold       uint64 `json:"threshold"` // basis points
+
+	Proposals  []*proposal          `json:"proposals"`
+	Parameters paramChanges         `json:"parameters"`
+	Policy     policyChanges        `json:"policy"`
+	Upgrades   []upgrade.Activation `json:"upgrades,omitempty"`
+}
+
+// newGovernance returns the governance state of a new network,
+// with the default voting rules; see setRules.
+func newGovernance() *governance {
+	return &governance{
+		VotingPeriod:    defaultVotingPeriod,
+		ActivationDelay: defaultActivationDelay,
+		Quorum:          defaultQuorum,
+		Threshold:       defaultThreshold,
+	}
+}
+
+// setRules sets the voting rules r sets, leaving the others.
+func (g *governance) setRules(r *votingRules) {
+	if r.VotingPeriod != nil {
+		g.VotingPeriod = *r.VotingPeriod
+	}
+	if r.ActivationDelay != nil {
+		g.ActivationDelay = *r.ActivationDelay
+	}
+	if r.Quorum != nil {
+		g.Quorum = *r.Quorum
+	}
+	if r.Threshold != nil {
+		g.Threshold = *r.Threshold
+	}
+}
+
+// parseGovernance returns the proposal or vote carried by tx. Both
+// are nil if tx is an ordinary transaction.
+func parseGovernance(tx *legacy.Tx) (*proposalRequest, *voteRequest, error) {
+	var ref struct {
+		Propose *proposalRequest `json:"propose"`
+		Vote    *voteRequest     `json:"vote"`
+	}
+	if len(tx.ReferenceData) == 0 || stdjson.Unmarshal(tx.ReferenceData, &ref) != nil {
+		return nil, nil, nil
+	}
+	if ref.Propose != nil && ref.Vote != nil {
+		return nil, nil, errors.WithDetail(ErrBadProposal, "transaction both proposes and votes")
+	}
+	if ref.Propose != nil {
+		return ref.Propose, nil, checkProposal(ref.Propose)
+	}
+	return nil, ref.Vote, nil
+}
+
+func checkProposal(req *proposalRequest) error {
+	switch req.Kind {
+	case proposeParameterChange:
+		p := req.Parameters
+		if p == nil || req.Policy != nil || req.Upgrade != nil {
+			return errors.WithDetail(ErrBadProposal, "parameter change must set only parameters")
+		}
+		if p.EpochLength != nil {
+			return errors.WithDetail(ErrBadProposal, "epoch length is set only in genesis")
+		}
+	case proposeValidatorPolicy:
+		p := req.Policy
+		if p == nil || req.Parameters != nil || req.Upgrade != nil {
+			return errors.WithDetail(ErrBadProposal, "validator policy must set only policy")
+		}
+		if p.BondAsset != nil {
+			return errors.WithDetail(ErrBadProposal, "bond asset is set only in genesis")
+		}
+		err := p.check(ErrBadProposal)
+		if err != nil {
+			return err
+		}
+	case proposeSoftwareUpgrade:
+		if req.Upgrade == nil || req.Parameters != nil || req.Policy != nil {
+			return errors.WithDetail(ErrBadProposal, "software upgrade must set only upgrade")
+		}
+		if req.Upgrade.Name == "" {
+			return errors.WithDetail(ErrBadProposal, "missing upgrade name")
+		}
+		if req.Upgrade.Height == 0 {
+			return errors.WithDetail(ErrBadProposal, "missing upgrade height")
+		}
+	default:
+		return errors.WithDetailf(ErrBadProposal, "unknown kind %q", req.Kind)
+	}
+	return nil
+}
+
+// propose opens voting on req, made by transaction id at the given
+// height.
+func (g *governance) propose(id bc.Hash, req *proposalRequest, height uint64) *proposal {
+	p := &proposal{
+		ID:       id,
+		Request:  *req,
+		Deadline: height + g.VotingPeriod,
+	}
+	p.Activation = p.Deadline + g.ActivationDelay
+	g.Proposals = append(g.Proposals, p)
+	return p
+}
+
+// checkVote checks that v, carried by tx, is a vote by a validator
+// in vals on an open proposal, signed by the validator's key under
+// the chain's crypto provider for the chain whose initial block hash
+// is chain.
+func (g *governance) checkVote(tx *legacy.Tx, v *voteRequest, chain bc.Hash, height uint64, vals []*abciTypes.Validator, crypto provider.Provider) (*proposal, error) {
+	p := g.find(v.Proposal)
+	if p == nil {
+		return nil, errors.WithDetailf(ErrUnknownProposal, "proposal %x", v.Proposal.Bytes())
+	}
+	if height > p.Deadline {
+		return nil, errors.WithDetailf(ErrVotingClosed, "deadline %d", p.Deadline)
+	}
+	if !hasValidator(vals, v.PubKey) {
+		return nil, errors.WithDetailf(ErrUnknownValidator, "validator %x", []byte(v.PubKey))
+	}
+	if len(tx.SpentOutputIDs) == 0 {
+		return nil, errors.WithDetail(ErrBadVote, "spends no outputs")
+	}
+	if !crypto.Verify(v.PubKey, voteMessage(chain, tx, v), v.Signature) {
+		return nil, errors.WithDetail(ErrBadVote, "invalid signature")
+	}
+	return p, nil
+}
+
+// voteMessage returns the message a validator signs to cast v in tx
+// on the chain whose initial block hash is chain. Like
+// registrationMessage, it covers the outputs tx spends, which no
+// other tx can spend, so that the signature can't be replayed in
+// another vote, on this chain or, as it covers the chain too, on
+// another.
+func voteMessage(chain bc.Hash, tx *legacy.Tx, v *voteRequest) []byte {
+	msg := append([]byte("chainmint-vote:"), chain.Bytes()...)
+	msg = append(msg, v.Proposal.Bytes()...)
+	if v.Yes {
+		msg = append(msg, 1)
+	} else {
+		msg = append(msg, 0)
+	}
+	for _, o := range tx.SpentOutputIDs {
+		msg = append(msg, o.Bytes()...)
+	}
+	return msg
+}
+
+// recordVote records v on p, replacing any earlier vote by the
+// same validator.
+func (p *proposal) recordVote(v *voteRequest) {
+	for i := range p.Votes {
+		if string(p.Votes[i].PubKey) == string(v.PubKey) {
+			p.Votes[i].Yes = v.Yes
+			return
+		}
+	}
+	p.Votes = append(p.Votes, vote{PubKey: v.PubKey, Yes: v.Yes})
+}
+
+func (g *governance) find(id bc.Hash) *proposal {
+	for _, p := range g.Proposals {
+		if p.ID == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// endBlock tallies the proposals whose deadline is height, weighting
+// votes by the power of the validators in vals, and returns the
+// accepted proposals that activate at height, in the order they
+// were proposed. Rejected and activated proposals are discarded.
+func (g *governance) endBlock(height uint64, vals []*abciTypes.Validator) []*proposal {
+	var (
+		activated []*proposal
+		open      []*proposal
+	)
+	for _, p := range g.Proposals {
+		if p.Deadline == height {
+			p.Accepted = g.tally(p, vals)
+			if !p.Accepted {
+				continue
+			}
+		}
+		if p.Activation == height {
+			activated = append(activated, p)
+			continue
+		}
+		open = append(open, p)
+	}
+	g.Proposals = open
+	return activated
+}
+
+// tally reports whether enough power voted on p, and enough of
+// that power voted yes. Votes by validators that have since left
+// the set don't count.
+//
+// Power is summed and scaled to basis points as big.Ints, as either
+// can overflow a uint64.
+func (g *governance) tally(p *proposal, vals []*abciTypes.Validator) bool {
+	total, voted, yes := new(big.Int), new(big.Int), new(big.Int)
+	for _, v := range vals {
+		power := new(big.Int).SetUint64(v.Power)
+		total.Add(total, power)
+		for _, pv := range p.Votes {
+			if string(pv.PubKey) != string(v.PubKey) {
+				continue
+			}
+			voted.Add(voted, power)
+			if pv.Yes {
+				yes.Add(yes, power)
+			}
+		}
+	}
+	if total.Sign() == 0 {
+		return false
+	}
+	quorum := basisPoints(voted, 10000).Cmp(basisPoints(total, g.Quorum)) >= 0
+	passed := basisPoints(yes, 10000).Cmp(basisPoints(voted, g.Threshold)) > 0
+	return quorum && passed
+}
+
+// basisPoints returns x times bp.
+func basisPoints(x *big.Int, bp uint64) *big.Int {
+	return new(big.Int).Mul(x, new(big.Int).SetUint64(bp))
+}
+
+// accept records the changes made by an activated proposal, so
+// they can be reapplied after a restart.
+func (g *governance) accept(p *proposal) {
+	if c := p.Request.Parameters; c != nil {
+		g.Parameters.merge(c)
+	}
+	if c := p.Request.Policy; c != nil {
+		g.Policy.merge(c)
+	}
+	if u := p.Request.Upgrade; u != nil {
+		g.Upgrades = append(g.Upgrades, upgrade.Activation{Name: u.Name, Height: u.Height})
+	}
+}
+
+func (c *paramChanges) merge(o *paramChanges) {
+	if o.UnbondingPeriodMS != nil {
+		c.UnbondingPeriodMS = o.UnbondingPeriodMS
+	}
+	if o.EpochLength != nil {
+		c.EpochLength = o.EpochLength
+	}
+	if o.MaxBlockTxs != nil {
+		c.MaxBlockTxs = o.MaxBlockTxs
+	}
+	if o.MaxBlockBytes != nil {
+		c.MaxBlockBytes = o.MaxBlockBytes
+	}
+}
+
+// check returns root, with detail, if c sets impossible rules.
+func (c *policyChanges) check(root error) error {
+	if c.MaxValidators != nil && *c.MaxValidators < 0 {
+		return errors.WithDetail(root, "negative max validators")
+	}
+	if c.MaxCommission != nil && *c.MaxCommission > 10000 {
+		return errors.WithDetail(root, "max commission above 10000 basis points")
+	}
+	return nil
+}
+
+func (c *policyChanges) merge(o *policyChanges) {
+	if o.BondAsset != nil {
+		c.BondAsset = o.BondAsset
+	}
+	if o.MinBond != nil {
+		c.MinBond = o.MinBond
+	}
+	if o.MaxValidators != nil {
+		c.MaxValidators = o.MaxValidators
+	}
+	if o.MaxCommission != nil {
+		c.MaxCommission = o.MaxCommission
+	}
+}
+
+// apply returns the admission rules with the policy changes made.
+func (c *policyChanges) apply(r admissionRules) admissionRules {
+	if c.BondAsset != nil {
+		r.BondAsset = *c.BondAsset
+	}
+	if c.MinBond != nil {
+		r.MinBond = *c.MinBond
+	}
+	if c.MaxValidators != nil {
+		r.MaxValidators = *c.MaxValidators
+	}
+	if c.MaxCommission != nil {
+		r.MaxCommission = *c.MaxCommission
+	}
+	return r
+}
+
+// rebase moves the heights of open and accepted proposals back by
+// height, for a chain restarted from state exported at height.
+func (g *governance) rebase(height uint64) {
+	for _, p := range g.Proposals {
+		if p.Deadline > height {
+			p.Deadline -= height
+		} else {
+			p.Deadline = 0
+		}
+		if p.Activation > height {
+			p.Activation -= height
+		} else {
+			p.Activation = 0
+		}
+	}
+	for i := range g.Upgrades {
+		if g.Upgrades[i].Height > height {
+			g.Upgrades[i].Height -= height
+		} else {
+			g.Upgrades[i].Height = 0
+		}
+	}
+}
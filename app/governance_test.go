@@ -0,0 +1,184 @@
+package app
+
+import (
+	"math"
+	"testing"
+
+	"github.com/chainmint/crypto/ed25519"
+	"github.com/chainmint/crypto/provider"
+	"github.com/chainmint/encoding/json"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	abciTypes "github.com/tendermint/abci/types"
+)
+
+func TestGovernance(t *testing.T) {
+	pubA, privA, _ := ed25519.GenerateKey(nil)
+	pubB, privB, _ := ed25519.GenerateKey(nil)
+	vals := []*abciTypes.Validator{{PubKey: pubA, Power: 60}, {PubKey: pubB, Power: 40}}
+	crypto, err := provider.Lookup(provider.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := &governance{VotingPeriod: 10, ActivationDelay: 5, Quorum: 5000, Threshold: 5000}
+	period := uint64(1000)
+	req := &proposalRequest{Kind: proposeParameterChange, Parameters: &paramChanges{UnbondingPeriodMS: &period}}
+	if err := checkProposal(req); err != nil {
+		t.Fatal(err)
+	}
+	id := bc.NewHash([32]byte{1})
+	p := g.propose(id, req, 100)
+	if p.Deadline != 110 || p.Activation != 115 {
+		t.Fatalf("deadline, activation = %d, %d, want 110, 115", p.Deadline, p.Activation)
+	}
+
+	// B's vote is signed by the wrong key.
+	tx, badVote := signedVote(id, pubB, privA, false, 1)
+	if _, err := g.checkVote(tx, badVote, testChain, 105, vals, crypto); errors.Root(err) != ErrBadVote {
+		t.Errorf("checkVote(bad signature) = %v, want %v", err, ErrBadVote)
+	}
+
+	for i, k := range []struct {
+		pub  ed25519.PublicKey
+		priv ed25519.PrivateKey
+		yes  bool
+	}{{pubA, privA, true}, {pubB, privB, false}} {
+		tx, v := signedVote(id, k.pub, k.priv, k.yes, byte(i+2))
+		p, err := g.checkVote(tx, v, testChain, 110, vals, crypto)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.recordVote(v)
+	}
+
+	tx, late := signedVote(id, pubA, privA, true, 4)
+	if _, err := g.checkVote(tx, late, testChain, 111, vals, crypto); errors.Root(err) != ErrVotingClosed {
+		t.Errorf("checkVote(late) = %v, want %v", err, ErrVotingClosed)
+	}
+
+	if got := g.endBlock(110, vals); len(got) != 0 || !p.Accepted {
+		t.Fatalf("at deadline: activated %d, accepted %v; want 0, true", len(got), p.Accepted)
+	}
+	got := g.endBlock(115, vals)
+	if len(got) != 1 || got[0] != p {
+		t.Fatalf("at activation: got %v, want proposal", got)
+	}
+	if len(g.Proposals) != 0 {
+		t.Errorf("%d proposals left after activation", len(g.Proposals))
+	}
+	g.accept(p)
+	if g.Parameters.UnbondingPeriodMS == nil || *g.Parameters.UnbondingPeriodMS != 1000 {
+		t.Error("accepted parameter change was not recorded")
+	}
+}
+
+// testChain is the initial block hash of the chain votes are cast
+// on in tests.
+var testChain = bc.NewHash([32]byte{0xcc})
+
+// signedVote returns a vote by pub on proposal, signed by priv for
+// testChain, and the tx carrying it, which spends an output made
+// from spent.
+func signedVote(proposal bc.Hash, pub ed25519.PublicKey, priv ed25519.PrivateKey, yes bool, spent byte) (*legacy.Tx, *voteRequest) {
+	tx := &legacy.Tx{Tx: &bc.Tx{SpentOutputIDs: []bc.Hash{bc.NewHash([32]byte{spent})}}}
+	v := &voteRequest{Proposal: proposal, PubKey: json.HexBytes(pub), Yes: yes}
+	v.Signature = ed25519.Sign(priv, voteMessage(testChain, tx, v))
+	return tx, v
+}
+
+func TestVoteReplay(t *testing.T) {
+	pubA, privA, _ := ed25519.GenerateKey(nil)
+	vals := []*abciTypes.Validator{{PubKey: pubA, Power: 1}}
+	crypto, err := provider.Lookup(provider.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &governance{VotingPeriod: 10}
+	id := bc.NewHash([32]byte{1})
+	g.propose(id, &proposalRequest{Kind: proposeParameterChange, Parameters: &paramChanges{}}, 100)
+
+	// A votes no, then yes in a later tx.
+	noTx, no := signedVote(id, pubA, privA, false, 1)
+	yesTx, yes := signedVote(id, pubA, privA, true, 2)
+	for _, c := range []struct {
+		tx *legacy.Tx
+		v  *voteRequest
+	}{{noTx, no}, {yesTx, yes}} {
+		p, err := g.checkVote(c.tx, c.v, testChain, 101, vals, crypto)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.recordVote(c.v)
+	}
+
+	// The no vote, replayed in a tx spending other outputs to flip
+	// the vote back, doesn't verify.
+	replayTx := &legacy.Tx{Tx: &bc.Tx{SpentOutputIDs: []bc.Hash{bc.NewHash([32]byte{3})}}}
+	if _, err := g.checkVote(replayTx, no, testChain, 102, vals, crypto); errors.Root(err) != ErrBadVote {
+		t.Errorf("checkVote(replayed in another tx) = %v, want %v", err, ErrBadVote)
+	}
+	// Nor does the yes vote, replayed on another chain.
+	if _, err := g.checkVote(yesTx, yes, bc.NewHash([32]byte{0xdd}), 102, vals, crypto); errors.Root(err) != ErrBadVote {
+		t.Errorf("checkVote(replayed on another chain) = %v, want %v", err, ErrBadVote)
+	}
+	// A vote in a tx that spends nothing could be replayed freely.
+	if _, err := g.checkVote(&legacy.Tx{Tx: &bc.Tx{}}, yes, testChain, 102, vals, crypto); errors.Root(err) != ErrBadVote {
+		t.Errorf("checkVote(spending nothing) = %v, want %v", err, ErrBadVote)
+	}
+	if p := g.find(id); len(p.Votes) != 1 || !p.Votes[0].Yes {
+		t.Errorf("votes = %+v, want one yes", p.Votes)
+	}
+}
+
+func TestGovernanceRejected(t *testing.T) {
+	vals := []*abciTypes.Validator{{PubKey: []byte{0xaa}, Power: 60}, {PubKey: []byte{0xbb}, Power: 40}}
+	g := &governance{VotingPeriod: 10, ActivationDelay: 5, Quorum: 5000, Threshold: 5000}
+	id := bc.NewHash([32]byte{2})
+	p := g.propose(id, &proposalRequest{Kind: proposeSoftwareUpgrade, Upgrade: &upgradeSignal{Name: "v2", Height: 200}}, 100)
+
+	// Only 40% of the power votes, short of quorum.
+	p.recordVote(&voteRequest{Proposal: id, PubKey: []byte{0xbb}, Yes: true})
+	g.endBlock(110, vals)
+	if p.Accepted || len(g.Proposals) != 0 {
+		t.Errorf("proposal without quorum: accepted %v, %d proposals left", p.Accepted, len(g.Proposals))
+	}
+}
+
+func TestTallyLargePower(t *testing.T) {
+	// Power near the uint64 limit overflows it when summed or
+	// scaled to basis points.
+	vals := []*abciTypes.Validator{{PubKey: []byte{0xaa}, Power: math.MaxUint64 / 2}, {PubKey: []byte{0xbb}, Power: math.MaxUint64 / 2}}
+	g := newGovernance()
+	id := bc.NewHash([32]byte{3})
+	p := g.propose(id, &proposalRequest{Kind: proposeSoftwareUpgrade, Upgrade: &upgradeSignal{Name: "v2", Height: 200}}, 100)
+	p.recordVote(&voteRequest{Proposal: id, PubKey: []byte{0xaa}, Yes: true})
+	p.recordVote(&voteRequest{Proposal: id, PubKey: []byte{0xbb}, Yes: false})
+	if g.tally(p, vals) {
+		t.Error("tally with half the power voting yes = true, want false")
+	}
+	p.recordVote(&voteRequest{Proposal: id, PubKey: []byte{0xbb}, Yes: true})
+	if !g.tally(p, vals) {
+		t.Error("tally with all the power voting yes = false, want true")
+	}
+}
+
+func TestCheckProposal(t *testing.T) {
+	max := uint64(20000)
+	cases := []*proposalRequest{
+		{Kind: "bogus"},
+		{Kind: proposeParameterChange},
+		{Kind: proposeParameterChange, Parameters: &paramChanges{EpochLength: &max}},
+		{Kind: proposeValidatorPolicy, Policy: &policyChanges{MaxCommission: &max}},
+		{Kind: proposeValidatorPolicy, Policy: &policyChanges{BondAsset: &bc.AssetID{}}},
+		{Kind: proposeSoftwareUpgrade, Upgrade: &upgradeSignal{}},
+		{Kind: proposeSoftwareUpgrade, Upgrade: &upgradeSignal{Name: "v2"}, Policy: &policyChanges{}},
+		{Kind: proposeSoftwareUpgrade, Upgrade: &upgradeSignal{Name: "v2"}},
+	}
+	for i, c := range cases {
+		if err := checkProposal(c); errors.Root(err) != ErrBadProposal {
+			t.Errorf("case %d: err = %v, want %v", i, err, ErrBadProposal)
+		}
+	}
+}
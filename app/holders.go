@@ -0,0 +1,261 @@
+package app
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"github.com/chainmint/database/pg"
+	"github.com/chainmint/database/sql"
+	chainjson "github.com/chainmint/encoding/json"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/vmutil"
+)
+
+// holdersProcessor names the block processor that records the
+// height through which the asset holder index is up to date.
+const holdersProcessor = "asset_holders"
+
+// holding identifies the units of an asset locked by one control
+// program.
+type holding struct {
+	assetID bc.AssetID
+	program string
+}
+
+// holderDeltas returns the net change the transactions of b make to
+// each holding. Issuances have no holder to debit, and retirements
+// no holder to credit.
+func holderDeltas(b *legacy.Block) map[holding]int64 {
+	deltas := make(map[holding]int64)
+	for _, tx := range b.Transactions {
+		for _, in := range tx.Inputs {
+			if in.IsIssuance() {
+				continue
+			}
+			deltas[holding{in.AssetID(), string(in.ControlProgram())}] -= int64(in.Amount())
+		}
+		for _, out := range tx.Outputs {
+			if vmutil.IsUnspendable(out.ControlProgram) {
+				continue
+			}
+			deltas[holding{*out.AssetId, string(out.ControlProgram)}] += int64(out.Amount)
+		}
+	}
+	for h, d := range deltas {
+		if d == 0 {
+			delete(deltas, h)
+		}
+	}
+	return deltas
+}
+
+// indexHolders brings the asset holder index up to date with the
+// chain, applying the holder deltas of each block committed since
+// it was last updated. It returns the height the index is up to
+// date through.
+func (app *ChainmintApplication) indexHolders(ctx context.Context) (uint64, error) {
+	db := app.backend.DB()
+	const q = `
+		INSERT INTO block_processors (name, height) VALUES ($1, 0)
+		ON CONFLICT (name) DO UPDATE SET name = excluded.name
+		RETURNING height
+	`
+	var height uint64
+	err := db.QueryRow(ctx, q, holdersProcessor).Scan(&height)
+	if err != nil {
+		return 0, errors.Wrap(err, "reading asset holder index height")
+	}
+	chain := app.backend.Chain()
+	for height < chain.Height() {
+		height++
+		b, err := chain.GetBlock(ctx, height)
+		if err != nil {
+			return 0, errors.Wrapf(err, "loading block %d", height)
+		}
+		err = saveHolders(ctx, db, height, holderDeltas(b))
+		if err != nil {
+			return 0, err
+		}
+	}
+	return height, nil
+}
+
+// saveHolders applies the holder deltas of the block at height to
+// the index, in a single database transaction when the database
+// supports it. Deltas for a height already applied are ignored.
+func saveHolders(ctx context.Context, db pg.DB, height uint64, deltas map[holding]int64) (err error) {
+	if beginner, ok := db.(interface {
+		Begin(context.Context) (*sql.Tx, error)
+	}); ok {
+		var dbtx *sql.Tx
+		dbtx, err = beginner.Begin(ctx)
+		if err != nil {
+			return errors.Wrap(err, "begin asset holder transaction")
+		}
+		defer func() {
+			if err != nil {
+				dbtx.Rollback(ctx)
+				return
+			}
+			err = errors.Wrap(dbtx.Commit(ctx), "commit asset holder transaction")
+		}()
+		db = dbtx
+	}
+
+	const heightQ = `UPDATE block_processors SET height = $1 WHERE height < $1 AND name = $2`
+	res, err := db.Exec(ctx, heightQ, height, holdersProcessor)
+	if err != nil {
+		return errors.Wrap(err, "advancing asset holder index height")
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return errors.Wrap(err)
+	}
+	return applyHolderDeltas(ctx, db, deltas)
+}
+
+// unsaveHolders reverses the holder deltas of the block at height,
+// moving the index back to the block before it, in a single
+// database transaction when the database supports it. It does
+// nothing unless the index is up to date through height.
+func unsaveHolders(ctx context.Context, db pg.DB, height uint64, deltas map[holding]int64) (err error) {
+	if beginner, ok := db.(interface {
+		Begin(context.Context) (*sql.Tx, error)
+	}); ok {
+		var dbtx *sql.Tx
+		dbtx, err = beginner.Begin(ctx)
+		if err != nil {
+			return errors.Wrap(err, "begin asset holder transaction")
+		}
+		defer func() {
+			if err != nil {
+				dbtx.Rollback(ctx)
+				return
+			}
+			err = errors.Wrap(dbtx.Commit(ctx), "commit asset holder transaction")
+		}()
+		db = dbtx
+	}
+
+	const heightQ = `UPDATE block_processors SET height = $1 - 1 WHERE height = $1 AND name = $2`
+	res, err := db.Exec(ctx, heightQ, height, holdersProcessor)
+	if err != nil {
+		return errors.Wrap(err, "rewinding asset holder index height")
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return errors.Wrap(err)
+	}
+	reversed := make(map[holding]int64, len(deltas))
+	for h, d := range deltas {
+		reversed[h] = -d
+	}
+	return applyHolderDeltas(ctx, db, reversed)
+}
+
+// applyHolderDeltas adds deltas to the amounts in the index,
+// removing holdings that are left empty.
+func applyHolderDeltas(ctx context.Context, db pg.DB, deltas map[holding]int64) error {
+	if len(deltas) == 0 {
+		return nil
+	}
+
+	var (
+		assetIDs = pq.ByteaArray(make([][]byte, 0, len(deltas)))
+		programs = pq.ByteaArray(make([][]byte, 0, len(deltas)))
+		amounts  = pq.Int64Array(make([]int64, 0, len(deltas)))
+	)
+	for h, d := range deltas {
+		assetIDs = append(assetIDs, h.assetID.Bytes())
+		programs = append(programs, []byte(h.program))
+		amounts = append(amounts, d)
+	}
+	const upsertQ = `
+		INSERT INTO asset_holders (asset_id, control_program, amount)
+		SELECT unnest($1::bytea[]), unnest($2::bytea[]), unnest($3::bigint[])
+		ON CONFLICT (asset_id, control_program)
+		DO UPDATE SET amount = asset_holders.amount + excluded.amount
+	`
+	_, err := db.Exec(ctx, upsertQ, assetIDs, programs, amounts)
+	if err != nil {
+		return errors.Wrap(err, "asset_holders upsert query")
+	}
+	_, err = db.Exec(ctx, `DELETE FROM asset_holders WHERE amount = 0`)
+	return errors.Wrap(err, "asset_holders delete query")
+}
+
+// assetHolder is one holder of an asset, as returned by the
+// /assets/{id}/holders query.
+type assetHolder struct {
+	ControlProgram chainjson.HexBytes `json:"control_program"`
+	AccountID      string             `json:"account_id,omitempty"`
+	Amount         uint64             `json:"amount"`
+}
+
+// assetHolders returns up to limit holders of assetID, largest
+// first, starting after the holder identified by the cursor after.
+// It also returns the cursor for the next page.
+func assetHolders(ctx context.Context, db pg.DB, assetID bc.AssetID, after string, limit int) ([]*assetHolder, string, error) {
+	afterAmount, afterProgram := int64(-1), []byte{}
+	if after != "" {
+		var err error
+		parts := strings.SplitN(after, ":", 2)
+		if len(parts) == 2 {
+			afterAmount, err = strconv.ParseInt(parts[0], 10, 64)
+		}
+		if err == nil && len(parts) == 2 {
+			afterProgram, err = hex.DecodeString(parts[1])
+		}
+		if err != nil || len(parts) != 2 || afterAmount < 0 {
+			return nil, "", errors.WithDetail(errBadQuery, "invalid after")
+		}
+	}
+
+	const q = `
+		SELECT h.control_program, COALESCE(acp.signer_id, ''), h.amount
+		FROM asset_holders AS h
+		LEFT JOIN account_control_programs AS acp ON acp.control_program = h.control_program
+		WHERE h.asset_id = $1
+			AND ($2 < 0 OR h.amount < $2 OR (h.amount = $2 AND h.control_program > $3))
+		ORDER BY h.amount DESC, h.control_program ASC
+		LIMIT $4
+	`
+	var holders []*assetHolder
+	err := pg.ForQueryRows(ctx, db, q, assetID, afterAmount, afterProgram, limit, func(prog []byte, accountID string, amount uint64) {
+		holders = append(holders, &assetHolder{ControlProgram: prog, AccountID: accountID, Amount: amount})
+	})
+	if err != nil {
+		return nil, "", errors.Wrap(err, "asset_holders select query")
+	}
+	if len(holders) > 0 {
+		last := holders[len(holders)-1]
+		after = fmt.Sprintf("%d:%x", last.Amount, []byte(last.ControlProgram))
+	}
+	return holders, after, nil
+}
+
+// queryAssetHolders answers a query for the holders of the asset
+// named in the path, given as {"page_size": <n>, "after": <cursor>}.
+func (app *ChainmintApplication) queryAssetHolders(ctx context.Context, data []byte) (interface{}, error) {
+	in, err := decodeListQuery(data)
+	if err != nil {
+		return nil, err
+	}
+	var assetID bc.AssetID
+	err = assetID.UnmarshalText([]byte(pathParam(ctx, "id")))
+	if err != nil {
+		return nil, errors.WithDetail(errBadQuery, "invalid asset id")
+	}
+	holders, next, err := assetHolders(ctx, app.backend.DB(), assetID, in.After, in.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	return queryPage{Items: holders, Next: next, LastPage: len(holders) < in.PageSize}, nil
+}
@@ -0,0 +1,58 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/vm"
+)
+
+func TestHolderDeltas(t *testing.T) {
+	asset := bc.AssetID(bc.NewHash([32]byte{1}))
+	alice, bob := []byte{0x51}, []byte{0x52}
+	spend := &legacy.TxInput{TypedInput: &legacy.SpendInput{SpendCommitment: legacy.SpendCommitment{
+		AssetAmount:    bc.AssetAmount{AssetId: &asset, Amount: 10},
+		ControlProgram: alice,
+	}}}
+	b := &legacy.Block{Transactions: []*legacy.Tx{{TxData: legacy.TxData{
+		Inputs: []*legacy.TxInput{spend},
+		Outputs: []*legacy.TxOutput{
+			legacy.NewTxOutput(asset, 6, bob, nil),
+			legacy.NewTxOutput(asset, 3, alice, nil),
+			legacy.NewTxOutput(asset, 1, []byte{byte(vm.OP_FAIL)}, nil), // retirement
+		},
+	}}}}
+
+	got := holderDeltas(b)
+	want := map[holding]int64{
+		{asset, string(alice)}: -7,
+		{asset, string(bob)}:   6,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("holderDeltas = %v, want %v", got, want)
+	}
+	for h, d := range want {
+		if got[h] != d {
+			t.Errorf("delta for %x = %d, want %d", h.program, got[h], d)
+		}
+	}
+}
+
+func TestLookupQuery(t *testing.T) {
+	app := &ChainmintApplication{}
+	app.queries = app.queryRoutes()
+	h, ctx := app.lookupQuery(context.Background(), "/assets/abcd/holders")
+	if h == nil {
+		t.Fatal("no handler for /assets/abcd/holders")
+	}
+	if id := pathParam(ctx, "id"); id != "abcd" {
+		t.Errorf("id param = %q, want abcd", id)
+	}
+	for _, p := range []string{"/assets//holders", "/assets/abcd", "/assets/abcd/holders/x"} {
+		if h, _ := app.lookupQuery(context.Background(), p); h != nil {
+			t.Errorf("lookupQuery(%q) found a handler", p)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"github.com/chainmint/env"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/math/checked"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/strategies"
+)
+
+var (
+	// maxMemoBytes bounds the reference data a transaction may
+	// carry, in total over the transaction and its inputs and
+	// outputs. 0 means no bound.
+	maxMemoBytes = env.Int("MAX_MEMO_BYTES", 64<<10)
+
+	// memoBytePrice is the fee, in units of the fee asset, a
+	// transaction must pay for each byte of its reference data.
+	memoBytePrice = env.Int("MEMO_BYTE_PRICE", 0)
+)
+
+var (
+	// ErrMemoTooLarge is returned when a transaction carries more
+	// reference data than the node accepts.
+	ErrMemoTooLarge = errors.New("transaction memo too large")
+
+	// ErrMemoUnderpaid is returned when a transaction's fee does
+	// not cover the price of its reference data.
+	ErrMemoUnderpaid = errors.New("fee too low for transaction memo")
+)
+
+// memoPrice returns the configured price per byte of reference
+// data. A negative price is treated as 0.
+func memoPrice() uint64 {
+	if *memoBytePrice < 0 {
+		return 0
+	}
+	return uint64(*memoBytePrice)
+}
+
+// checkMemo checks the reference data of tx against the node's
+// limit on its size and, if a fee asset is configured, its price per
+// byte. A price or limit of 0 is not enforced. Since the limits are
+// set by each node, they apply only to admission to the mempool.
+func checkMemo(tx *legacy.Tx, feeAsset bc.AssetID, maxBytes int, price uint64) error {
+	n := strategies.MemoSize(tx)
+	if maxBytes > 0 && n > maxBytes {
+		return errors.WithDetailf(ErrMemoTooLarge, "%d bytes, limit %d", n, maxBytes)
+	}
+	if price == 0 || feeAsset == (bc.AssetID{}) {
+		return nil
+	}
+	due, ok := checked.MulUint64(uint64(n), price)
+	fee := strategies.Fee(tx, feeAsset)
+	if !ok || fee < due {
+		return errors.WithDetailf(ErrMemoUnderpaid, "%d bytes at %d each, fee %d", n, price, fee)
+	}
+	return nil
+}
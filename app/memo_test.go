@@ -0,0 +1,45 @@
+package app
+
+import (
+	"math"
+	"testing"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/vm"
+)
+
+func TestCheckMemo(t *testing.T) {
+	feeAsset := bc.NewAssetID([32]byte{1})
+	memoTx := func(memo string, fee uint64) *legacy.Tx {
+		return &legacy.Tx{TxData: legacy.TxData{
+			Outputs: []*legacy.TxOutput{
+				legacy.NewTxOutput(feeAsset, fee, []byte{byte(vm.OP_FAIL)}, []byte("ab")),
+			},
+			ReferenceData: []byte(memo),
+		}}
+	}
+
+	cases := []struct {
+		tx       *legacy.Tx
+		feeAsset bc.AssetID
+		maxBytes int
+		price    uint64
+		want     error
+	}{
+		{memoTx("12345678", 0), feeAsset, 10, 0, nil},
+		{memoTx("123456789", 0), feeAsset, 10, 0, ErrMemoTooLarge},
+		{memoTx("123456789", 0), feeAsset, 0, 0, nil}, // unbounded
+		{memoTx("1234", 18), feeAsset, 0, 3, nil},
+		{memoTx("1234", 17), feeAsset, 0, 3, ErrMemoUnderpaid},
+		{memoTx("1234", 0), bc.AssetID{}, 0, 3, nil}, // no fee asset
+		{memoTx("1234", 100), feeAsset, 0, math.MaxUint64, ErrMemoUnderpaid},
+	}
+	for i, c := range cases {
+		err := checkMemo(c.tx, c.feeAsset, c.maxBytes, c.price)
+		if errors.Root(err) != c.want {
+			t.Errorf("case %d: checkMemo = %v, want %v", i, err, c.want)
+		}
+	}
+}
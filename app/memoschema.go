@@ -0,0 +1,183 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"reflect"
+
+	"github.com/chainmint/env"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+// memoSchemaPath names a file holding a JSON schema that the
+// reference data of transactions, their inputs and outputs must
+// match. Empty means reference data is not validated.
+var memoSchemaPath = env.String("MEMO_SCHEMA", "")
+
+// ErrMemoSchema is returned when a transaction carries reference
+// data that is not JSON or does not match the node's memo schema.
+var ErrMemoSchema = errors.New("transaction memo does not match schema")
+
+// memoSchema is the subset of JSON Schema that reference data is
+// validated against: the keywords below, with their JSON Schema
+// meanings. Other keywords are ignored. Reference data carrying
+// validator registrations or governance actions is validated too,
+// so a schema must allow them.
+type memoSchema struct {
+	Type                 string                 `json:"type"`
+	Enum                 []interface{}          `json:"enum"`
+	Properties           map[string]*memoSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+	Items                *memoSchema            `json:"items"`
+	MaxItems             *int                   `json:"maxItems"`
+	MaxLength            *int                   `json:"maxLength"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+}
+
+// loadMemoSchema reads the memo schema in the named file. It
+// returns nil if name is empty.
+func loadMemoSchema(name string) (*memoSchema, error) {
+	if name == "" {
+		return nil, nil
+	}
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading MEMO_SCHEMA")
+	}
+	s := new(memoSchema)
+	err = json.Unmarshal(b, s)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing MEMO_SCHEMA")
+	}
+	return s, nil
+}
+
+// checkMemoSchema checks that each piece of reference data in tx is
+// JSON matching s. Without a schema nothing is checked. Like the
+// limit on memo size, the schema is set by each node and so applies
+// only to admission to the mempool.
+func checkMemoSchema(tx *legacy.Tx, s *memoSchema) error {
+	if s == nil {
+		return nil
+	}
+	err := s.check(tx.ReferenceData, "transaction")
+	if err != nil {
+		return err
+	}
+	for i, in := range tx.Inputs {
+		err = s.check(in.ReferenceData, fmt.Sprintf("input %d", i))
+		if err != nil {
+			return err
+		}
+	}
+	for i, out := range tx.Outputs {
+		err = s.check(out.ReferenceData, fmt.Sprintf("output %d", i))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check checks one piece of reference data, if present.
+func (s *memoSchema) check(data []byte, where string) error {
+	if len(data) == 0 {
+		return nil
+	}
+	var v interface{}
+	err := json.Unmarshal(data, &v)
+	if err != nil {
+		return errors.WithDetailf(ErrMemoSchema, "%s reference data is not JSON", where)
+	}
+	return s.validate(v, where+" reference data")
+}
+
+// validate checks v, as decoded by encoding/json, against s.
+func (s *memoSchema) validate(v interface{}, path string) error {
+	fail := func(format string, args ...interface{}) error {
+		return errors.WithDetailf(ErrMemoSchema, "%s: %s", path, fmt.Sprintf(format, args...))
+	}
+	if s.Type != "" && !hasType(v, s.Type) {
+		return fail("want %s", s.Type)
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			found = found || reflect.DeepEqual(e, v)
+		}
+		if !found {
+			return fail("not one of the allowed values")
+		}
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, k := range s.Required {
+			if _, ok := v[k]; !ok {
+				return fail("missing property %q", k)
+			}
+		}
+		for k, pv := range v {
+			ps, ok := s.Properties[k]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return fail("unexpected property %q", k)
+				}
+				continue
+			}
+			err := ps.validate(pv, path+"."+k)
+			if err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			return fail("%d items, limit %d", len(v), *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, iv := range v {
+				err := s.Items.validate(iv, fmt.Sprintf("%s[%d]", path, i))
+				if err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		if s.MaxLength != nil && len([]rune(v)) > *s.MaxLength {
+			return fail("%d characters, limit %d", len([]rune(v)), *s.MaxLength)
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			return fail("%v is below minimum %v", v, *s.Minimum)
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			return fail("%v is above maximum %v", v, *s.Maximum)
+		}
+	}
+	return nil
+}
+
+// hasType reports whether v, as decoded by encoding/json, is of the
+// named JSON Schema type.
+func hasType(v interface{}, typ string) bool {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return typ == "object"
+	case []interface{}:
+		return typ == "array"
+	case string:
+		return typ == "string"
+	case bool:
+		return typ == "boolean"
+	case float64:
+		return typ == "number" || (typ == "integer" && v == math.Trunc(v))
+	case nil:
+		return typ == "null"
+	}
+	return false
+}
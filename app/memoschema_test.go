@@ -0,0 +1,61 @@
+package app
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+func TestCheckMemoSchema(t *testing.T) {
+	const schemaJSON = `{
+		"type": "object",
+		"required": ["invoice"],
+		"additionalProperties": false,
+		"properties": {
+			"invoice": {"type": "string", "maxLength": 8},
+			"lines": {"type": "array", "maxItems": 2, "items": {"type": "integer", "minimum": 0}},
+			"kind": {"enum": ["sale", "refund"]}
+		}
+	}`
+	schema := new(memoSchema)
+	err := json.Unmarshal([]byte(schemaJSON), schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	memoTx := func(txMemo, outMemo string) *legacy.Tx {
+		return &legacy.Tx{TxData: legacy.TxData{
+			Outputs: []*legacy.TxOutput{
+				legacy.NewTxOutput(bc.AssetID{}, 1, []byte{0x51}, []byte(outMemo)),
+			},
+			ReferenceData: []byte(txMemo),
+		}}
+	}
+
+	cases := []struct {
+		tx     *legacy.Tx
+		schema *memoSchema
+		want   error
+	}{
+		{memoTx(`{"invoice":"A1"}`, ""), schema, nil},
+		{memoTx(`{"invoice":"A1","lines":[1,2],"kind":"sale"}`, ""), schema, nil},
+		{memoTx("not json", ""), nil, nil}, // no schema
+		{memoTx("not json", ""), schema, ErrMemoSchema},
+		{memoTx(`{}`, ""), schema, ErrMemoSchema},
+		{memoTx(`{"invoice":"A123456789"}`, ""), schema, ErrMemoSchema},
+		{memoTx(`{"invoice":"A1","other":1}`, ""), schema, ErrMemoSchema},
+		{memoTx(`{"invoice":"A1","lines":[1,2,3]}`, ""), schema, ErrMemoSchema},
+		{memoTx(`{"invoice":"A1","lines":[1.5]}`, ""), schema, ErrMemoSchema},
+		{memoTx(`{"invoice":"A1","lines":[-1]}`, ""), schema, ErrMemoSchema},
+		{memoTx(`{"invoice":"A1","kind":"gift"}`, ""), schema, ErrMemoSchema},
+		{memoTx(`{"invoice":"A1"}`, `{"invoice":7}`), schema, ErrMemoSchema},
+	}
+	for i, c := range cases {
+		err := checkMemoSchema(c.tx, c.schema)
+		if errors.Root(err) != c.want {
+			t.Errorf("case %d: checkMemoSchema = %v, want %v", i, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"github.com/chainmint/database/pg"
+	"github.com/chainmint/database/sql"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+// metadataProcessor names the block processor that records the
+// height through which the metadata index is up to date.
+const metadataProcessor = "metadata_index"
+
+// Kinds of metadata indexed.
+const (
+	metadataAsset       = "asset"
+	metadataTransaction = "transaction"
+)
+
+const (
+	// maxMetadataFields bounds the fields indexed from one
+	// document; the rest are left out.
+	maxMetadataFields = 256
+
+	// maxMetadataValue bounds the length of an indexed value.
+	maxMetadataValue = 256
+)
+
+// metadataField is one leaf of a JSON document: a dotted path of
+// object keys and its value as text. Array elements share the path
+// of the array.
+type metadataField struct {
+	path, value string
+}
+
+// flattenMetadata returns the fields of a JSON document, sorted by
+// path and value. Documents that are not JSON objects have none.
+func flattenMetadata(doc []byte) []metadataField {
+	if len(bytes.TrimSpace(doc)) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	var v interface{}
+	if dec.Decode(&v) != nil {
+		return nil
+	}
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	var fields []metadataField
+	flattenInto(&fields, "", obj)
+	sort.Slice(fields, func(i, j int) bool {
+		if fields[i].path != fields[j].path {
+			return fields[i].path < fields[j].path
+		}
+		return fields[i].value < fields[j].value
+	})
+	if len(fields) > maxMetadataFields {
+		fields = fields[:maxMetadataFields]
+	}
+	return fields
+}
+
+func flattenInto(fields *[]metadataField, path string, v interface{}) {
+	var value string
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			flattenInto(fields, p, child)
+		}
+		return
+	case []interface{}:
+		for _, child := range v {
+			flattenInto(fields, path, child)
+		}
+		return
+	case string:
+		value = v
+	case json.Number:
+		value = v.String()
+	case bool:
+		value = strconv.FormatBool(v)
+	default:
+		return // null
+	}
+	if len(value) > maxMetadataValue {
+		value = value[:maxMetadataValue]
+	}
+	*fields = append(*fields, metadataField{path, value})
+}
+
+// metadataEntry is a document to index: the reference data of a
+// transaction, or the definition of an asset.
+type metadataEntry struct {
+	kind   string
+	id     []byte
+	fields []metadataField
+}
+
+// blockMetadata returns the documents to index from b. A
+// transaction's fields come from its reference data and from that
+// of its inputs and outputs, under paths prefixed with "inputs."
+// and "outputs.". An asset's come from the definition in its
+// issuances.
+func blockMetadata(b *legacy.Block) []metadataEntry {
+	var entries []metadataEntry
+	for _, tx := range b.Transactions {
+		fields := flattenMetadata(tx.ReferenceData)
+		for _, in := range tx.Inputs {
+			for _, f := range flattenMetadata(in.ReferenceData) {
+				fields = append(fields, metadataField{"inputs." + f.path, f.value})
+			}
+			if iss, ok := in.TypedInput.(*legacy.IssuanceInput); ok {
+				assetID := iss.AssetID()
+				if def := flattenMetadata(iss.AssetDefinition); len(def) > 0 {
+					entries = append(entries, metadataEntry{metadataAsset, assetID.Bytes(), def})
+				}
+			}
+		}
+		for _, out := range tx.Outputs {
+			for _, f := range flattenMetadata(out.ReferenceData) {
+				fields = append(fields, metadataField{"outputs." + f.path, f.value})
+			}
+		}
+		if len(fields) > 0 {
+			entries = append(entries, metadataEntry{metadataTransaction, tx.ID.Bytes(), fields})
+		}
+	}
+	return entries
+}
+
+// indexMetadata brings the metadata index up to date with the
+// chain, indexing the documents of each block committed since it
+// was last updated.
+func (app *ChainmintApplication) indexMetadata(ctx context.Context) error {
+	db := app.backend.DB()
+	const q = `
+		INSERT INTO block_processors (name, height) VALUES ($1, 0)
+		ON CONFLICT (name) DO UPDATE SET name = excluded.name
+		RETURNING height
+	`
+	var height uint64
+	err := db.QueryRow(ctx, q, metadataProcessor).Scan(&height)
+	if err != nil {
+		return errors.Wrap(err, "reading metadata index height")
+	}
+	chain := app.backend.Chain()
+	for height < chain.Height() {
+		height++
+		b, err := chain.GetBlock(ctx, height)
+		if err != nil {
+			return errors.Wrapf(err, "loading block %d", height)
+		}
+		err = saveMetadata(ctx, db, height, blockMetadata(b))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveMetadata indexes the documents of the block at height, in a
+// single database transaction when the database supports it.
+// Documents for a height already indexed are ignored.
+func saveMetadata(ctx context.Context, db pg.DB, height uint64, entries []metadataEntry) (err error) {
+	if beginner, ok := db.(interface {
+		Begin(context.Context) (*sql.Tx, error)
+	}); ok {
+		var dbtx *sql.Tx
+		dbtx, err = beginner.Begin(ctx)
+		if err != nil {
+			return errors.Wrap(err, "begin metadata index transaction")
+		}
+		defer func() {
+			if err != nil {
+				dbtx.Rollback(ctx)
+				return
+			}
+			err = errors.Wrap(dbtx.Commit(ctx), "commit metadata index transaction")
+		}()
+		db = dbtx
+	}
+
+	const heightQ = `UPDATE block_processors SET height = $1 WHERE height < $1 AND name = $2`
+	res, err := db.Exec(ctx, heightQ, height, metadataProcessor)
+	if err != nil {
+		return errors.Wrap(err, "advancing metadata index height")
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return errors.Wrap(err)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	var (
+		kinds  pq.StringArray
+		ids    pq.ByteaArray
+		paths  pq.StringArray
+		values pq.StringArray
+	)
+	for _, e := range entries {
+		for _, f := range e.fields {
+			kinds = append(kinds, e.kind)
+			ids = append(ids, e.id)
+			paths = append(paths, f.path)
+			values = append(values, f.value)
+		}
+	}
+	const insertQ = `
+		INSERT INTO metadata_index (kind, id, block_height, path, value)
+		SELECT unnest($1::text[]), unnest($2::bytea[]), $3, unnest($4::text[]), unnest($5::text[])
+		ON CONFLICT (kind, id, path, value) DO NOTHING
+	`
+	_, err = db.Exec(ctx, insertQ, kinds, ids, height, paths, values)
+	return errors.Wrap(err, "metadata_index insert query")
+}
+
+// metadataMatch is an asset or transaction whose metadata matched
+// a metadata query.
+type metadataMatch struct {
+	Kind        string  `json:"kind"`
+	ID          bc.Hash `json:"id"`
+	BlockHeight uint64  `json:"block_height"`
+}
+
+// searchMetadata returns up to limit assets or transactions of the
+// given kind with a field matching value, case-insensitively, in
+// the order they were first indexed. If field is set, only fields
+// with that path match; if prefix is set, values need only begin
+// with value. It starts after the match identified by the cursor
+// after, and also returns the cursor for the next page.
+func searchMetadata(ctx context.Context, db pg.DB, kind, field, value string, prefix bool, after string, limit int) ([]*metadataMatch, string, error) {
+	afterHeight, afterID := int64(-1), []byte{}
+	if after != "" {
+		var err error
+		parts := strings.SplitN(after, ":", 2)
+		if len(parts) == 2 {
+			afterHeight, err = strconv.ParseInt(parts[0], 10, 64)
+		}
+		if err == nil && len(parts) == 2 {
+			afterID, err = hex.DecodeString(parts[1])
+		}
+		if err != nil || len(parts) != 2 || afterHeight < 0 {
+			return nil, "", errors.WithDetail(errBadQuery, "invalid after")
+		}
+	}
+
+	pattern := strings.ToLower(value)
+	if prefix {
+		r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+		pattern = r.Replace(pattern) + "%"
+	}
+	const q = `
+		SELECT id, MIN(block_height) AS height
+		FROM metadata_index
+		WHERE kind = $1 AND ($2 = '' OR path = $2)
+			AND CASE WHEN $3 THEN lower(value) LIKE $4 ELSE lower(value) = $4 END
+		GROUP BY id
+		HAVING (MIN(block_height), id) > ($5, $6)
+		ORDER BY height, id
+		LIMIT $7
+	`
+	var matches []*metadataMatch
+	err := pg.ForQueryRows(ctx, db, q, kind, field, prefix, pattern, afterHeight, afterID, limit, func(id bc.Hash, height uint64) {
+		matches = append(matches, &metadataMatch{Kind: kind, ID: id, BlockHeight: height})
+	})
+	if err != nil {
+		return nil, "", errors.Wrap(err, "metadata_index select query")
+	}
+	if len(matches) > 0 {
+		last := matches[len(matches)-1]
+		after = fmt.Sprintf("%d:%x", last.BlockHeight, last.ID.Bytes())
+	}
+	return matches, after, nil
+}
+
+// queryMetadata answers a query for the assets or transactions
+// whose metadata matches a value, given as {"kind": "asset" or
+// "transaction", "field": <dotted path>, "value": <value>,
+// "prefix": <bool>, "page_size": <n>, "after": <cursor>}. Asset
+// metadata is the asset definition; transaction metadata is the
+// reference data of the transaction and of its inputs and outputs.
+func (app *ChainmintApplication) queryMetadata(ctx context.Context, data []byte) (interface{}, error) {
+	var in struct {
+		Kind     string `json:"kind"`
+		Field    string `json:"field"`
+		Value    string `json:"value"`
+		Prefix   bool   `json:"prefix"`
+		PageSize int    `json:"page_size"`
+		After    string `json:"after"`
+	}
+	err := decodeQuery(data, &in)
+	if err != nil {
+		return nil, err
+	}
+	if in.Kind != metadataAsset && in.Kind != metadataTransaction {
+		return nil, errors.WithDetail(errBadQuery, `kind must be "asset" or "transaction"`)
+	}
+	if in.Value == "" {
+		return nil, errors.WithDetail(errBadQuery, "value is required")
+	}
+	if in.PageSize <= 0 {
+		in.PageSize = defQueryPageSize
+	}
+	matches, next, err := searchMetadata(ctx, app.backend.DB(), in.Kind, in.Field, in.Value, in.Prefix, in.After, in.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	return queryPage{Items: matches, Next: next, LastPage: len(matches) < in.PageSize}, nil
+}
@@ -0,0 +1,65 @@
+package app
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFlattenMetadata(t *testing.T) {
+	cases := []struct {
+		doc  string
+		want []metadataField
+	}{
+		{``, nil},
+		{`not json`, nil},
+		{`["a", "b"]`, nil},
+		{`"string"`, nil},
+		{`{}`, nil},
+		{
+			`{"alias": "gold", "invoice": {"number": 1001, "paid": true, "note": null}}`,
+			[]metadataField{
+				{"alias", "gold"},
+				{"invoice.number", "1001"},
+				{"invoice.paid", "true"},
+			},
+		},
+		{
+			`{"tags": ["b", "a", {"x": 1.50}]}`,
+			[]metadataField{
+				{"tags", "a"},
+				{"tags", "b"},
+				{"tags.x", "1.50"},
+			},
+		},
+	}
+	for _, c := range cases {
+		got := flattenMetadata([]byte(c.doc))
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("flattenMetadata(%q) = %v, want %v", c.doc, got, c.want)
+		}
+	}
+}
+
+func TestFlattenMetadataLimits(t *testing.T) {
+	long := strings.Repeat("x", maxMetadataValue+10)
+	got := flattenMetadata([]byte(`{"a": "` + long + `"}`))
+	if len(got) != 1 || len(got[0].value) != maxMetadataValue {
+		t.Errorf("long value not truncated to %d bytes: %v", maxMetadataValue, got)
+	}
+
+	var b bytes.Buffer
+	b.WriteString(`{"a": [`)
+	for i := 0; i < maxMetadataFields+10; i++ {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString("1")
+	}
+	b.WriteString("]}")
+	got = flattenMetadata([]byte(b.String()))
+	if len(got) != maxMetadataFields {
+		t.Errorf("got %d fields, want %d", len(got), maxMetadataFields)
+	}
+}
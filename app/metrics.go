@@ -0,0 +1,47 @@
+package app
+
+import (
+	"bufio"
+	"net/http"
+	"strconv"
+
+	"github.com/chainmint/log"
+	"github.com/chainmint/metrics"
+	abciTypes "github.com/tendermint/abci/types"
+)
+
+var (
+	checkTxSeconds = metrics.NewHistogram("chainmint_check_tx_seconds",
+		"Time taken by CheckTx, by result code.", metrics.LatencyBuckets, "code")
+	deliverTxSeconds = metrics.NewHistogram("chainmint_deliver_tx_seconds",
+		"Time taken by DeliverTx, by result code.", metrics.LatencyBuckets, "code")
+	commitSeconds = metrics.NewHistogram("chainmint_block_commit_seconds",
+		"Time taken by Commit to commit a block.", metrics.LatencyBuckets)
+	validatorUpdates = metrics.NewCounter("chainmint_validator_updates",
+		"Validator power changes returned to Tendermint by EndBlock.")
+)
+
+// resultCode is the code label of an ABCI result.
+func resultCode(res abciTypes.Result) string {
+	return strconv.FormatUint(uint64(res.Code), 10)
+}
+
+// MetricsHandler returns a handler serving the node's metrics, as
+// published with package metrics, and the supply of each asset, in
+// the OpenMetrics text format, for monitoring systems to scrape. It
+// must not be called before Init.
+func (app *ChainmintApplication) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		supplies, err := latestSupply(req.Context(), app.backend.DB())
+		if err != nil {
+			log.Error(req.Context(), err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+		bw := bufio.NewWriter(w)
+		metrics.WriteOpenMetrics(bw)
+		writeSupplyMetrics(bw, supplies)
+		bw.Flush()
+	})
+}
@@ -0,0 +1,230 @@
+package app
+
+import (
+	"sync"
+	"time"
+
+	"github.com/chainmint/env"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/errors/errcode"
+	"github.com/chainmint/math/checked"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	abciTypes "github.com/tendermint/abci/types"
+)
+
+// replaceFeeBump is how much more, in basis points of the fees of
+// the pending txs it conflicts with, a tx must pay to replace them.
+var replaceFeeBump = env.Int("REPLACE_FEE_BUMP", 1000)
+
+// CodeConflict is the CheckTx result code of a transaction spending
+// an output a pending transaction already spends, without paying
+// enough more to replace it. The log gives the fee that would.
+const CodeConflict abciTypes.CodeType = 1002
+
+var (
+	// ErrConflict is returned for a transaction that conflicts with
+	// pending transactions and pays too little to replace them.
+	ErrConflict = errors.New("transaction conflicts with a pending transaction")
+
+	// ErrReplaced is returned when Tendermint rechecks a pending
+	// transaction that was replaced, or that conflicts with a
+	// transaction included in a block, so that it leaves the mempool.
+	ErrReplaced = errors.New("transaction was replaced")
+)
+
+// pendingTTL is how long a transaction stays in the pending set
+// without being checked again. Tendermint rechecks the txs in its
+// mempool after each block, so a tx not rechecked for this long has
+// left it some other way.
+const pendingTTL = time.Hour
+
+type pendingTx struct {
+	fee    uint64
+	spends []bc.Hash
+	makes  []bc.Hash // IDs of the tx's results
+	seen   time.Time
+}
+
+// pendingSet tracks the transactions CheckTx has admitted to
+// Tendermint's mempool that no block has yet included, by the
+// outputs they spend, so that a transaction spending the same output
+// as a pending one can replace it by paying a higher fee. Tendermint
+// cannot remove a tx from its mempool at the app's request; instead
+// the replaced txs are rejected when it rechecks them after the next
+// block.
+type pendingSet struct {
+	mu       sync.Mutex
+	bump     uint64 // basis points
+	txs      map[bc.Hash]*pendingTx
+	spentBy  map[bc.Hash]bc.Hash // output ID to pending tx ID
+	replaced map[bc.Hash]time.Time
+	pruneAt  int
+	now      func() time.Time
+}
+
+func newPendingSet(bump uint64) *pendingSet {
+	return &pendingSet{
+		bump:     bump,
+		txs:      make(map[bc.Hash]*pendingTx),
+		spentBy:  make(map[bc.Hash]bc.Hash),
+		replaced: make(map[bc.Hash]time.Time),
+		pruneAt:  minPruneAt,
+		now:      time.Now,
+	}
+}
+
+// add adds tx, paying fee, to the pending set, replacing the pending
+// txs it conflicts with if it pays at least p.bump basis points more
+// than they do together. It returns the IDs of the txs replaced,
+// including those spending their outputs, which could no longer be
+// included in a block. Adding a tx already pending, as when
+// Tendermint rechecks it, only keeps it from expiring.
+func (p *pendingSet) add(tx *legacy.Tx, fee uint64) ([]bc.Hash, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	if _, ok := p.replaced[tx.ID]; ok {
+		delete(p.replaced, tx.ID) // it leaves the mempool now
+		return nil, errors.WithDetailf(ErrReplaced, "tx %x", tx.ID.Bytes())
+	}
+	if ptx, ok := p.txs[tx.ID]; ok {
+		ptx.seen = now
+		return nil, nil
+	}
+
+	conflicts := make(map[bc.Hash]bool)
+	var conflictFee uint64
+	for _, o := range tx.SpentOutputIDs {
+		id, ok := p.spentBy[o]
+		if !ok || conflicts[id] {
+			continue
+		}
+		conflicts[id] = true
+		conflictFee, _ = checked.AddUint64(conflictFee, p.txs[id].fee)
+	}
+	if len(conflicts) > 0 {
+		min := replacementFee(conflictFee, p.bump)
+		if fee < min {
+			return nil, errors.WithDetailf(ErrConflict, "replacing %d pending txs needs a fee of at least %d, tx pays %d", len(conflicts), min, fee)
+		}
+	}
+
+	var replaced []bc.Hash
+	for id := range conflicts {
+		replaced = p.evict(id, now, replaced)
+	}
+	ptx := &pendingTx{fee: fee, spends: tx.SpentOutputIDs, seen: now}
+	for _, id := range tx.ResultIds {
+		ptx.makes = append(ptx.makes, *id)
+	}
+	p.txs[tx.ID] = ptx
+	for _, o := range ptx.spends {
+		p.spentBy[o] = tx.ID
+	}
+	if len(p.txs)+len(p.replaced) > p.pruneAt {
+		p.prune(now)
+	}
+	return replaced, nil
+}
+
+// remove removes tx, which left the mempool for a block, from the
+// pending set. If the block accepted it, the pending txs spending
+// the same outputs can no longer be included, and are replaced.
+func (p *pendingSet) remove(tx *legacy.Tx, accepted bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.replaced, tx.ID)
+	if _, ok := p.txs[tx.ID]; ok {
+		p.drop(tx.ID)
+	}
+	if !accepted {
+		return
+	}
+	now := p.now()
+	for _, o := range tx.SpentOutputIDs {
+		if id, ok := p.spentBy[o]; ok {
+			p.evict(id, now, nil)
+		}
+	}
+}
+
+// evict drops the pending tx with the given ID, and the pending txs
+// spending its outputs, and remembers them as replaced. It returns
+// replaced with their IDs appended. The caller must hold p.mu.
+func (p *pendingSet) evict(id bc.Hash, now time.Time, replaced []bc.Hash) []bc.Hash {
+	ptx, ok := p.txs[id]
+	if !ok {
+		return replaced
+	}
+	p.drop(id)
+	p.replaced[id] = now
+	replaced = append(replaced, id)
+	for _, o := range ptx.makes {
+		if child, ok := p.spentBy[o]; ok {
+			replaced = p.evict(child, now, replaced)
+		}
+	}
+	return replaced
+}
+
+// len returns the number of pending txs.
+func (p *pendingSet) len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.txs)
+}
+
+// drop forgets the pending tx with the given ID. The caller must
+// hold p.mu.
+func (p *pendingSet) drop(id bc.Hash) {
+	for _, o := range p.txs[id].spends {
+		if p.spentBy[o] == id {
+			delete(p.spentBy, o)
+		}
+	}
+	delete(p.txs, id)
+}
+
+// prune drops the pending txs and the replaced txs not seen for
+// pendingTTL. The caller must hold p.mu.
+func (p *pendingSet) prune(now time.Time) {
+	for id, ptx := range p.txs {
+		if now.Sub(ptx.seen) >= pendingTTL {
+			p.drop(id)
+		}
+	}
+	for id, t := range p.replaced {
+		if now.Sub(t) >= pendingTTL {
+			delete(p.replaced, id)
+		}
+	}
+	p.pruneAt = 2 * (len(p.txs) + len(p.replaced))
+	if p.pruneAt < minPruneAt {
+		p.pruneAt = minPruneAt
+	}
+}
+
+// replacementFee returns the least fee that replaces pending txs
+// paying fee together: bump basis points more, and at least 1 more.
+func replacementFee(fee, bump uint64) uint64 {
+	extra, ok := checked.MulUint64(fee, bump)
+	if !ok {
+		extra = fee / 10000 * bump
+	} else {
+		extra /= 10000
+	}
+	if extra == 0 {
+		extra = 1
+	}
+	min, ok := checked.AddUint64(fee, extra)
+	if !ok {
+		return ^uint64(0)
+	}
+	return min
+}
+
+// conflictResult is the CheckTx result for err, an ErrConflict.
+func conflictResult(err error) abciTypes.Result {
+	return abciTypes.NewError(CodeConflict, resultLog(err, errcode.Conflict))
+}
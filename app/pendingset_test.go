@@ -0,0 +1,70 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+func TestPendingSetReplace(t *testing.T) {
+	// pendingTx returns a tx with the given ID spending output
+	// spent and creating output made.
+	pendingTx := func(id, spent, made byte) *legacy.Tx {
+		out := bc.NewHash([32]byte{made})
+		return &legacy.Tx{Tx: &bc.Tx{
+			TxHeader:       &bc.TxHeader{ResultIds: []*bc.Hash{&out}},
+			ID:             bc.NewHash([32]byte{id}),
+			SpentOutputIDs: []bc.Hash{bc.NewHash([32]byte{spent})},
+		}}
+	}
+	p := newPendingSet(1000)
+	a := pendingTx(1, 10, 20)
+	child := pendingTx(2, 20, 30) // spends a's output
+	for _, tx := range []*legacy.Tx{a, child} {
+		if _, err := p.add(tx, 100); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A conflicting tx must pay 10% more than a and its fee.
+	if _, err := p.add(pendingTx(3, 10, 40), 109); errors.Root(err) != ErrConflict {
+		t.Errorf("add(low fee conflict) = %v, want %v", err, ErrConflict)
+	}
+	replaced, err := p.add(pendingTx(4, 10, 50), 110)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replaced) != 2 || replaced[0] != a.ID || replaced[1] != child.ID {
+		t.Errorf("replaced = %x, want a and its child", replaced)
+	}
+
+	// Rechecking a replaced tx rejects it, once.
+	if _, err := p.add(a, 100); errors.Root(err) != ErrReplaced {
+		t.Errorf("recheck(replaced) = %v, want %v", err, ErrReplaced)
+	}
+	if _, ok := p.replaced[a.ID]; ok {
+		t.Error("a still remembered as replaced after its recheck")
+	}
+
+	// A block including a conflicting tx replaces the pending one.
+	p.remove(pendingTx(5, 10, 60), true)
+	if len(p.txs) != 0 || len(p.spentBy) != 0 {
+		t.Errorf("pending set holds %d txs, %d spends; want none", len(p.txs), len(p.spentBy))
+	}
+}
+
+func TestReplacementFee(t *testing.T) {
+	cases := []struct{ fee, bump, want uint64 }{
+		{100, 1000, 110},
+		{5, 1000, 6},
+		{100, 0, 101},
+		{^uint64(0), 1000, ^uint64(0)},
+	}
+	for _, c := range cases {
+		if got := replacementFee(c.fee, c.bump); got != c.want {
+			t.Errorf("replacementFee(%d, %d) = %d, want %d", c.fee, c.bump, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package app
+
+import (
+	"github.com/chainmint/env"
+	"github.com/chainmint/errors/errcode"
+	"github.com/chainmint/txpolicy"
+	abciTypes "github.com/tendermint/abci/types"
+)
+
+var (
+	// txPolicies are the local policies CheckTx applies, in order,
+	// each the name of a registered policy optionally followed by
+	// "=" and its argument; see package txpolicy.
+	txPolicies = env.StringSlice("TX_POLICIES")
+
+	// txPolicyPlugins are the paths of Go plugins registering
+	// further policies, loaded before txPolicies are created.
+	txPolicyPlugins = env.StringSlice("TX_POLICY_PLUGINS")
+)
+
+// CodePolicyRejected is the CheckTx result code of a transaction a
+// local policy rejects. The log names the policy.
+const CodePolicyRejected abciTypes.CodeType = 1003
+
+// configuredPolicies loads the policy plugins and creates the
+// policies set by the environment.
+func configuredPolicies() (*txpolicy.Set, error) {
+	for _, path := range *txPolicyPlugins {
+		err := txpolicy.Load(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return txpolicy.NewSet(*txPolicies)
+}
+
+// policyResult is the CheckTx result for err, a
+// txpolicy.ErrRejected.
+func policyResult(err error) abciTypes.Result {
+	return abciTypes.NewError(CodePolicyRejected, resultLog(err, errcode.PolicyRejected))
+}
@@ -0,0 +1,353 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"strings"
+
+	"github.com/chainmint/core/query"
+	"github.com/chainmint/database/pg"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	abciTypes "github.com/tendermint/abci/types"
+)
+
+// queryVersion is the version of the query response format. It is
+// increased whenever a response changes incompatibly.
+const queryVersion = 2
+
+const defQueryPageSize = 100
+
+// errBadQuery is returned by query handlers for malformed query
+// data.
+var errBadQuery = errors.New("malformed query")
+
+// queryHandler answers a query given the request data of the query.
+type queryHandler func(ctx context.Context, data []byte) (interface{}, error)
+
+// queryResponse is the value of every successful query response.
+type queryResponse struct {
+	Version int         `json:"version"`
+	Path    string      `json:"path"`
+	Data    interface{} `json:"data"`
+}
+
+// queryRoutes returns the handlers for the paths Query answers.
+func (app *ChainmintApplication) queryRoutes() map[string]queryHandler {
+	return map[string]queryHandler{
+		"/accounts":            app.queryAccounts,
+		"/assets":              app.queryAssets,
+		"/assets/{id}/holders": app.queryAssetHolders,
+		"/unspent-outputs":     app.queryUnspentOutputs,
+		"/transactions":        app.queryTransactions,
+		"/blocks":              app.queryBlock,
+		"/transaction":         app.queryTransaction,
+		"/account-balances":    app.queryAccountBalances,
+		"/validators":          app.queryValidators,
+		"/accruals":            app.queryAccruals,
+
+		"/accounts/{id}/balance-history": app.queryBalanceHistory,
+		"/assets/{id}/supply-history":    app.querySupplyHistory,
+
+		"/metadata":    app.queryMetadata,
+		"/mempool":     app.queryMempool,
+		"/state-proof": app.queryStateProof,
+		"/upgrades":    app.queryUpgrades,
+	}
+}
+
+// route answers query with its registered handler, or from the
+// query cache if it has been answered since the state it reads
+// last changed.
+func (app *ChainmintApplication) route(query abciTypes.RequestQuery) abciTypes.ResponseQuery {
+	h, ctx := app.lookupQuery(context.Background(), query.Path)
+	if h == nil {
+		return abciTypes.ResponseQuery{Code: abciTypes.ErrUnknownRequest.Code, Log: "unknown query path " + query.Path}
+	}
+	var (
+		key       queryCacheKey
+		gen       uint64
+		cacheable = app.queryCache != nil && !uncachedQueries[query.Path]
+	)
+	if cacheable {
+		key = queryCacheKey{path: query.Path, data: string(query.Data), height: app.backend.Chain().Height()}
+		var cached []byte
+		var ok bool
+		cached, gen, ok = app.queryCache.get(key)
+		if ok {
+			return abciTypes.ResponseQuery{Code: abciTypes.OK.Code, Value: cached}
+		}
+	}
+	result, err := h(ctx, query.Data)
+	switch errors.Root(err) {
+	case nil:
+	case errBadQuery, pg.ErrUserInputNotFound:
+		return abciTypes.ResponseQuery{Code: abciTypes.ErrEncodingError.Code, Log: errors.Detail(err)}
+	default:
+		return abciTypes.ResponseQuery{Code: abciTypes.ErrInternalError.Code, Log: err.Error()}
+	}
+	bytes, err := json.Marshal(queryResponse{Version: queryVersion, Path: query.Path, Data: result})
+	if err != nil {
+		return abciTypes.ResponseQuery{Code: abciTypes.ErrInternalError.Code, Log: err.Error()}
+	}
+	if cacheable && indexedThrough(app.backend.PinStore(), key.height) {
+		app.queryCache.add(key, gen, bytes)
+	}
+	return abciTypes.ResponseQuery{Code: abciTypes.OK.Code, Value: bytes}
+}
+
+type pathParamsKey struct{}
+
+// lookupQuery returns the handler registered for path. Registered
+// paths may have segments of the form {name}, which match any one
+// segment of path; the returned context carries the segments they
+// matched, for pathParam.
+func (app *ChainmintApplication) lookupQuery(ctx context.Context, path string) (queryHandler, context.Context) {
+	if h, ok := app.queries[path]; ok {
+		return h, ctx
+	}
+	segs := strings.Split(path, "/")
+	for pattern, h := range app.queries {
+		if !strings.Contains(pattern, "{") {
+			continue
+		}
+		params, ok := matchQueryPath(strings.Split(pattern, "/"), segs)
+		if ok {
+			return h, context.WithValue(ctx, pathParamsKey{}, params)
+		}
+	}
+	return nil, ctx
+}
+
+func matchQueryPath(pattern, segs []string) (map[string]string, bool) {
+	if len(pattern) != len(segs) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, p := range pattern {
+		if strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}") {
+			if segs[i] == "" {
+				return nil, false
+			}
+			params[p[1:len(p)-1]] = segs[i]
+		} else if p != segs[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// pathParam returns the path segment matched by {name} in the path
+// of the query being answered.
+func pathParam(ctx context.Context, name string) string {
+	params, _ := ctx.Value(pathParamsKey{}).(map[string]string)
+	return params[name]
+}
+
+// decodeQuery unmarshals the query data into v. Empty data leaves v
+// unchanged.
+func decodeQuery(data []byte, v interface{}) error {
+	if len(data) == 0 {
+		return nil
+	}
+	err := json.Unmarshal(data, v)
+	if err != nil {
+		return errors.WithDetail(errBadQuery, err.Error())
+	}
+	return nil
+}
+
+// listQuery is the request data of the list queries. Filter and
+// FilterParams are as for the corresponding Core API endpoints.
+type listQuery struct {
+	Filter       string        `json:"filter"`
+	FilterParams []interface{} `json:"filter_params"`
+	PageSize     int           `json:"page_size"`
+	After        string        `json:"after"`
+}
+
+// queryPage is a page of results from a list query. Next is passed
+// as After to get the following page.
+type queryPage struct {
+	Items    interface{} `json:"items"`
+	Next     string      `json:"next"`
+	LastPage bool        `json:"last_page"`
+}
+
+func decodeListQuery(data []byte) (*listQuery, error) {
+	in := new(listQuery)
+	err := decodeQuery(data, in)
+	if err != nil {
+		return nil, err
+	}
+	if in.PageSize <= 0 {
+		in.PageSize = defQueryPageSize
+	}
+	return in, nil
+}
+
+func (app *ChainmintApplication) queryAccounts(ctx context.Context, data []byte) (interface{}, error) {
+	in, err := decodeListQuery(data)
+	if err != nil {
+		return nil, err
+	}
+	accounts, after, err := app.backend.Indexer().Accounts(ctx, in.Filter, in.FilterParams, in.After, in.PageSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "running account query")
+	}
+	return queryPage{Items: accounts, Next: after, LastPage: len(accounts) < in.PageSize}, nil
+}
+
+func (app *ChainmintApplication) queryAssets(ctx context.Context, data []byte) (interface{}, error) {
+	in, err := decodeListQuery(data)
+	if err != nil {
+		return nil, err
+	}
+	assets, after, err := app.backend.Indexer().Assets(ctx, in.Filter, in.FilterParams, in.After, in.PageSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "running asset query")
+	}
+	return queryPage{Items: assets, Next: after, LastPage: len(assets) < in.PageSize}, nil
+}
+
+func (app *ChainmintApplication) queryUnspentOutputs(ctx context.Context, data []byte) (interface{}, error) {
+	in, err := decodeListQuery(data)
+	if err != nil {
+		return nil, err
+	}
+	var after *query.OutputsAfter
+	if in.After != "" {
+		after, err = query.DecodeOutputsAfter(in.After)
+		if err != nil {
+			return nil, errors.WithDetail(errBadQuery, "invalid after")
+		}
+	}
+	outputs, next, err := app.backend.Indexer().Outputs(ctx, in.Filter, in.FilterParams, math.MaxInt64, after, in.PageSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "running output query")
+	}
+	return queryPage{Items: outputs, Next: next.String(), LastPage: len(outputs) < in.PageSize}, nil
+}
+
+func (app *ChainmintApplication) queryTransactions(ctx context.Context, data []byte) (interface{}, error) {
+	in, err := decodeListQuery(data)
+	if err != nil {
+		return nil, err
+	}
+	indexer := app.backend.Indexer()
+	var after query.TxAfter
+	if in.After != "" {
+		after, err = query.DecodeTxAfter(in.After)
+		if err != nil {
+			return nil, errors.WithDetail(errBadQuery, "invalid after")
+		}
+	} else {
+		after, err = indexer.LookupTxAfter(ctx, 0, math.MaxInt64)
+		if err != nil {
+			return nil, err
+		}
+	}
+	txs, next, err := indexer.Transactions(ctx, in.Filter, in.FilterParams, after, in.PageSize, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "running tx query")
+	}
+	return queryPage{Items: txs, Next: next.String(), LastPage: len(txs) < in.PageSize}, nil
+}
+
+// queryBlock answers a query for a block with its annotated
+// transactions, given as {"height": <n>} or {"id": <hex>}. With
+// neither, it returns the latest block.
+func (app *ChainmintApplication) queryBlock(ctx context.Context, data []byte) (interface{}, error) {
+	var in struct {
+		Height uint64   `json:"height"`
+		ID     *bc.Hash `json:"id"`
+	}
+	err := decodeQuery(data, &in)
+	if err != nil {
+		return nil, err
+	}
+	indexer := app.backend.Indexer()
+	if in.ID != nil {
+		in.Height, err = indexer.BlockHeight(ctx, *in.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if in.Height == 0 {
+		in.Height = app.backend.Chain().Height()
+	}
+	return indexer.Block(ctx, in.Height)
+}
+
+// queryTransaction answers a query for an annotated transaction,
+// given as {"id": <hex>}.
+func (app *ChainmintApplication) queryTransaction(ctx context.Context, data []byte) (interface{}, error) {
+	var in struct {
+		ID bc.Hash `json:"id"`
+	}
+	err := decodeQuery(data, &in)
+	if err != nil {
+		return nil, err
+	}
+	return app.backend.Indexer().Transaction(ctx, in.ID)
+}
+
+// queryAccountBalances answers a query for the balance of each asset
+// held by an account, given as {"account_id": <id>} or
+// {"account_alias": <alias>}, with an optional "timestamp" in
+// milliseconds.
+func (app *ChainmintApplication) queryAccountBalances(ctx context.Context, data []byte) (interface{}, error) {
+	var in struct {
+		AccountID    string `json:"account_id"`
+		AccountAlias string `json:"account_alias"`
+		TimestampMS  uint64 `json:"timestamp"`
+	}
+	err := decodeQuery(data, &in)
+	if err != nil {
+		return nil, err
+	}
+	account := in.AccountID
+	if account == "" {
+		account = in.AccountAlias
+	}
+	if account == "" {
+		return nil, errors.WithDetail(errBadQuery, "account_id or account_alias is required")
+	}
+	if in.TimestampMS > math.MaxInt64 {
+		return nil, errors.WithDetail(errBadQuery, "timestamp is too large")
+	}
+	return app.backend.Indexer().AccountBalances(ctx, account, in.TimestampMS)
+}
+
+// queryValidators answers a query for the current validator set.
+func (app *ChainmintApplication) queryValidators(ctx context.Context, data []byte) (interface{}, error) {
+	return struct {
+		Height       uint64              `json:"height"`
+		Validators   []exportedValidator `json:"validators"`
+		PendingDiffs []exportedValidator `json:"pending_diffs"`
+	}{app.height, exportValidators(app.validators), exportValidators(app.epoch.pending)}, nil
+}
+
+// queryAccruals answers a query for the rewards accrued to a
+// delegator, given as {"delegator": <hex control program>}. With
+// no delegator, it returns the accruals of all delegators.
+func (app *ChainmintApplication) queryAccruals(ctx context.Context, data []byte) (interface{}, error) {
+	var in struct {
+		Delegator string `json:"delegator"`
+	}
+	err := decodeQuery(data, &in)
+	if err != nil {
+		return nil, err
+	}
+	if in.Delegator == "" {
+		return app.stake.Accruals, nil
+	}
+	return map[string]uint64{in.Delegator: app.stake.Accruals[in.Delegator]}, nil
+}
+
+// queryMempool answers a query for the txs waiting in the local
+// generator's pending tx pool.
+func (app *ChainmintApplication) queryMempool(ctx context.Context, data []byte) (interface{}, error) {
+	return app.backend.PendingTxs()
+}
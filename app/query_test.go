@@ -0,0 +1,92 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	abciTypes "github.com/tendermint/abci/types"
+)
+
+func TestQueryRouting(t *testing.T) {
+	app := &ChainmintApplication{stake: newStakeBook()}
+	app.stake.Accruals["01"] = 7
+	app.queries = app.queryRoutes()
+
+	resp := app.Query(abciTypes.RequestQuery{Path: "/no-such-path"})
+	if resp.Code != abciTypes.ErrUnknownRequest.Code {
+		t.Errorf("unknown path code = %v, want %v", resp.Code, abciTypes.ErrUnknownRequest.Code)
+	}
+	resp = app.Query(abciTypes.RequestQuery{Path: "/accruals", Data: []byte("{")})
+	if resp.Code != abciTypes.ErrEncodingError.Code {
+		t.Errorf("malformed query code = %v, want %v", resp.Code, abciTypes.ErrEncodingError.Code)
+	}
+
+	resp = app.Query(abciTypes.RequestQuery{Path: "/accruals", Data: []byte(`{"delegator":"01"}`)})
+	if resp.Code != abciTypes.OK.Code {
+		t.Fatalf("accruals query failed: %s", resp.Log)
+	}
+	var got struct {
+		Version int               `json:"version"`
+		Path    string            `json:"path"`
+		Data    map[string]uint64 `json:"data"`
+	}
+	if err := json.Unmarshal(resp.Value, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != queryVersion || got.Path != "/accruals" || got.Data["01"] != 7 {
+		t.Errorf("accruals response = %+v", got)
+	}
+}
+
+func TestBatchQuery(t *testing.T) {
+	app := &ChainmintApplication{stake: newStakeBook()}
+	app.stake.Accruals["01"] = 7
+	app.stake.Accruals["02"] = 9
+	app.queries = app.queryRoutes()
+
+	data := []byte(`[
+		{"path": "/accruals", "data": {"delegator": "01"}},
+		{"path": "/no-such-path"},
+		{"path": "/accruals", "data": {"delegator": "02"}},
+		{"path": "/batch", "data": []}
+	]`)
+	resp := app.Query(abciTypes.RequestQuery{Path: "/batch", Data: data})
+	if resp.Code != abciTypes.OK.Code {
+		t.Fatalf("batch query failed: %s", resp.Log)
+	}
+	var got struct {
+		Path string             `json:"path"`
+		Data []batchQueryResult `json:"data"`
+	}
+	if err := json.Unmarshal(resp.Value, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Path != "/batch" || len(got.Data) != 4 {
+		t.Fatalf("batch response = %+v", got)
+	}
+	wantCodes := []abciTypes.CodeType{abciTypes.OK.Code, abciTypes.ErrUnknownRequest.Code, abciTypes.OK.Code, abciTypes.ErrEncodingError.Code}
+	for i, want := range wantCodes {
+		if got.Data[i].Code != want {
+			t.Errorf("result %d code = %v, want %v", i, got.Data[i].Code, want)
+		}
+	}
+	for i, want := range map[int]uint64{0: 7, 2: 9} {
+		var item struct {
+			Data map[string]uint64 `json:"data"`
+		}
+		if err := json.Unmarshal(got.Data[i].Value, &item); err != nil {
+			t.Fatal(err)
+		}
+		if len(item.Data) != 1 || item.Data[fmt.Sprintf("%02d", i/2+1)] != want {
+			t.Errorf("result %d = %s", i, got.Data[i].Value)
+		}
+	}
+
+	defer func(n int) { *maxBatchQueries = n }(*maxBatchQueries)
+	*maxBatchQueries = 2
+	resp = app.Query(abciTypes.RequestQuery{Path: "/batch", Data: data})
+	if resp.Code != abciTypes.ErrEncodingError.Code {
+		t.Errorf("oversized batch code = %v, want %v", resp.Code, abciTypes.ErrEncodingError.Code)
+	}
+}
@@ -0,0 +1,128 @@
+package app
+
+import (
+	"context"
+	"expvar"
+	"sync"
+
+	"github.com/golang/groupcache/lru"
+
+	"github.com/chainmint/core/account"
+	"github.com/chainmint/core/asset"
+	"github.com/chainmint/core/pin"
+	"github.com/chainmint/core/query"
+	"github.com/chainmint/env"
+)
+
+// queryCacheSize bounds the query responses cached. 0 disables
+// the cache.
+var queryCacheSize = env.Int("QUERY_CACHE_SIZE", 1024)
+
+var (
+	queryCacheHits   = expvar.NewInt("app.query_cache_hits")
+	queryCacheMisses = expvar.NewInt("app.query_cache_misses")
+)
+
+// queryCachePins are the Core block processors whose indexes
+// queries read. A response is cached only once they are all up to
+// date with the height it is cached at.
+var queryCachePins = []string{query.TxPinName, account.PinName, asset.PinName}
+
+// uncachedQueries are the query paths whose responses change
+// between commits, so are never cached.
+var uncachedQueries = map[string]bool{
+	"/mempool":  true,
+	"/upgrades": true, // changed by governance without a new block
+}
+
+// queryCacheKey identifies a query response: the same query at the
+// same height has the same response.
+type queryCacheKey struct {
+	path, data string
+	height     uint64
+}
+
+// queryCache is an LRU cache of query responses. It is cleared
+// whenever the state queries read changes: on Commit, and when the
+// app's own indexes catch up after it. A nil *queryCache caches
+// nothing.
+type queryCache struct {
+	mu  sync.Mutex
+	lru *lru.Cache
+	gen uint64 // incremented by clear
+}
+
+func newQueryCache(size int) *queryCache {
+	if size <= 0 {
+		return nil
+	}
+	return &queryCache{lru: lru.New(size)}
+}
+
+// get returns the cached response for key, if any, and the
+// generation of the cache to pass to add.
+func (c *queryCache) get(key queryCacheKey) ([]byte, uint64, bool) {
+	if c == nil {
+		return nil, 0, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.lru.Get(key)
+	if !ok {
+		queryCacheMisses.Add(1)
+		return nil, c.gen, false
+	}
+	queryCacheHits.Add(1)
+	return v.([]byte), c.gen, true
+}
+
+// add caches the response for key, computed at generation gen of
+// the cache. It is not cached if the cache was cleared since, as
+// the response may predate the change that cleared it.
+func (c *queryCache) add(key queryCacheKey, gen uint64, value []byte) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen == c.gen {
+		c.lru.Add(key, value)
+	}
+}
+
+// clear removes every cached response.
+func (c *queryCache) clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lru.Clear()
+	c.gen++
+}
+
+// clearingQueryCache returns a side effect that runs run and then
+// clears the query cache, for side effects that change the state
+// queries read.
+func (app *ChainmintApplication) clearingQueryCache(run func(context.Context) error) func(context.Context) error {
+	return func(ctx context.Context) error {
+		err := run(ctx)
+		app.queryCache.clear()
+		return err
+	}
+}
+
+// indexedThrough reports whether the Core indexes read by queries
+// are up to date with height. Indexes with no pin are not being
+// built, so cannot be behind.
+func indexedThrough(pins *pin.Store, height uint64) bool {
+	if pins == nil {
+		return false // unconfigured Core
+	}
+	for _, name := range queryCachePins {
+		if h, ok := pins.CurrentHeight(name); ok && h < height {
+			return false
+		}
+	}
+	return true
+}
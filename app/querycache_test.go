@@ -0,0 +1,54 @@
+package app
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestQueryCache(t *testing.T) {
+	c := newQueryCache(10)
+	key := queryCacheKey{path: "/blocks", data: `{"height":1}`, height: 1}
+
+	_, gen, ok := c.get(key)
+	if ok {
+		t.Fatal("got response from empty cache")
+	}
+	c.add(key, gen, []byte("resp"))
+	got, _, ok := c.get(key)
+	if !ok || !bytes.Equal(got, []byte("resp")) {
+		t.Fatalf("get = %q, %v, want resp, true", got, ok)
+	}
+
+	// Another height is another key.
+	_, _, ok = c.get(queryCacheKey{path: key.path, data: key.data, height: 2})
+	if ok {
+		t.Error("got response cached at another height")
+	}
+
+	c.clear()
+	_, _, ok = c.get(key)
+	if ok {
+		t.Error("got response after clear")
+	}
+
+	// A response computed before a clear is not cached after it.
+	_, gen, _ = c.get(key)
+	c.clear()
+	c.add(key, gen, []byte("stale"))
+	_, _, ok = c.get(key)
+	if ok {
+		t.Error("cached response computed before clear")
+	}
+}
+
+func TestQueryCacheDisabled(t *testing.T) {
+	c := newQueryCache(0)
+	key := queryCacheKey{path: "/blocks"}
+	_, gen, _ := c.get(key)
+	c.add(key, gen, []byte("resp"))
+	c.clear()
+	_, _, ok := c.get(key)
+	if ok {
+		t.Error("disabled cache returned a response")
+	}
+}
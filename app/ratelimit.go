@@ -0,0 +1,213 @@
+package app
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chainmint/core"
+	"github.com/chainmint/env"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/errors/errcode"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	abciTypes "github.com/tendermint/abci/types"
+)
+
+var (
+	// senderTxRate is the number of transactions per minute
+	// CheckTx admits from each sender. 0 disables the limit.
+	senderTxRate = env.Int("SENDER_TX_RATE", 0)
+
+	// senderTxBurst is the number of transactions a sender may
+	// submit at once, before the rate applies.
+	senderTxBurst = env.Int("SENDER_TX_BURST", 10)
+)
+
+// CodeRateLimited is the CheckTx result code of a transaction from a
+// sender over its rate limit. Like CodeBlockFull it says nothing
+// against the transaction, which may be submitted again later.
+const CodeRateLimited abciTypes.CodeType = 1001
+
+// ErrRateLimited is returned for a transaction from a sender that
+// has submitted more transactions than its rate limit allows.
+var ErrRateLimited = errors.New("sender rate limit exceeded")
+
+// chargedTTL is how long a transaction admitted by the rate limiter
+// is remembered, so that Tendermint rechecking its mempool after a
+// block does not charge its senders again.
+const chargedTTL = 10 * time.Minute
+
+// minPruneAt is the least number of buckets or charged transactions
+// kept before the limiter prunes those it no longer needs.
+const minPruneAt = 1024
+
+// txSenders returns the keys a transaction is rate limited by: the
+// control program of each output it spends, which stands for the
+// account that holds it, and each issuance program it issues by.
+func txSenders(tx *legacy.Tx) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, in := range tx.Inputs {
+		var key string
+		if in.IsIssuance() {
+			key = "i" + string(in.IssuanceProgram())
+		} else {
+			key = "s" + string(in.ControlProgram())
+		}
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// A tokenBucket holds the transactions a sender may still submit.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// senderLimiter limits the rate at which CheckTx admits transactions
+// from each sender, so that no one sender can take up the node's
+// CheckTx time or Tendermint's mempool. A transaction is charged to
+// each of its senders, and is rejected if any of them is over its
+// limit. The limits can be changed while the node runs; see
+// SetOption.
+type senderLimiter struct {
+	mu      sync.Mutex
+	perMin  int
+	burst   int
+	buckets map[string]*tokenBucket
+	charged map[bc.Hash]time.Time
+	pruneAt int
+	now     func() time.Time
+}
+
+func newSenderLimiter(perMin, burst int) *senderLimiter {
+	return &senderLimiter{
+		perMin:  perMin,
+		burst:   burst,
+		buckets: make(map[string]*tokenBucket),
+		charged: make(map[bc.Hash]time.Time),
+		pruneAt: minPruneAt,
+		now:     time.Now,
+	}
+}
+
+// set changes the limits. A perMin of 0 disables them.
+func (l *senderLimiter) set(perMin, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.perMin, l.burst = perMin, burst
+}
+
+// limits returns the current limits.
+func (l *senderLimiter) limits() (perMin, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.perMin, l.burst
+}
+
+// allow charges tx to its senders, or returns ErrRateLimited if any
+// of them is over its limit, in which case none is charged. A
+// transaction already charged is admitted again for free.
+func (l *senderLimiter) allow(tx *legacy.Tx) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.perMin <= 0 {
+		return nil
+	}
+	now := l.now()
+	if t, ok := l.charged[tx.ID]; ok && now.Sub(t) < chargedTTL {
+		return nil
+	}
+
+	keys := txSenders(tx)
+	for _, key := range keys {
+		if l.refill(key, now).tokens < 1 {
+			return errors.WithDetailf(ErrRateLimited, "sender %x is limited to %d transactions per minute", key[1:], l.perMin)
+		}
+	}
+	for _, key := range keys {
+		l.buckets[key].tokens--
+	}
+	l.charged[tx.ID] = now
+	if len(l.buckets)+len(l.charged) > l.pruneAt {
+		l.prune(now)
+	}
+	return nil
+}
+
+// forget drops tx from the charged transactions, once it has left
+// the mempool.
+func (l *senderLimiter) forget(id bc.Hash) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.charged, id)
+}
+
+// refill returns the bucket of key as of now, adding the tokens
+// earned since it was last used. The caller must hold l.mu.
+func (l *senderLimiter) refill(key string, now time.Time) *tokenBucket {
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(l.burst), last: now}
+		l.buckets[key] = b
+		return b
+	}
+	b.tokens += now.Sub(b.last).Minutes() * float64(l.perMin)
+	if b.tokens > float64(l.burst) {
+		b.tokens = float64(l.burst)
+	}
+	b.last = now
+	return b
+}
+
+// prune drops the buckets that have refilled, which are the same as
+// new ones, and the charged transactions past chargedTTL. The caller
+// must hold l.mu.
+func (l *senderLimiter) prune(now time.Time) {
+	for key := range l.buckets {
+		if l.refill(key, now).tokens >= float64(l.burst) {
+			delete(l.buckets, key)
+		}
+	}
+	for id, t := range l.charged {
+		if now.Sub(t) >= chargedTTL {
+			delete(l.charged, id)
+		}
+	}
+	l.pruneAt = 2 * (len(l.buckets) + len(l.charged))
+	if l.pruneAt < minPruneAt {
+		l.pruneAt = minPruneAt
+	}
+}
+
+// rateLimitedResult is the CheckTx result for err, an
+// ErrRateLimited.
+func rateLimitedResult(err error) abciTypes.Result {
+	return abciTypes.NewError(CodeRateLimited, resultLog(err, errcode.RateLimited))
+}
+
+// setRateLimitOption sets the sender rate limit option key, one of
+// sender_tx_rate and sender_tx_burst, to value. It reports whether
+// key is a rate limit option.
+func (app *ChainmintApplication) setRateLimitOption(key, value string) (bool, error) {
+	if key != "sender_tx_rate" && key != "sender_tx_burst" {
+		return false, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return true, errors.WithDetailf(core.ErrBadStateOption, "%s: invalid count %q", key, value)
+	}
+	perMin, burst := app.senders.limits()
+	if key == "sender_tx_rate" {
+		perMin = n
+	} else {
+		burst = n
+	}
+	app.senders.set(perMin, burst)
+	return true, nil
+}
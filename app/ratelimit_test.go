@@ -0,0 +1,62 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+func TestSenderLimiter(t *testing.T) {
+	asset := bc.NewAssetID([32]byte{1})
+	spend := func(n uint64, programs ...string) *legacy.Tx {
+		var ins []*legacy.TxInput
+		for _, p := range programs {
+			ins = append(ins, legacy.NewSpendInput(nil, bc.Hash{}, asset, n, 0, []byte(p), bc.Hash{}, nil))
+		}
+		tx := legacy.NewTx(legacy.TxData{Version: 1, Inputs: ins})
+		tx.ID = bc.NewHash([32]byte{byte(n)})
+		return tx
+	}
+	now := time.Now()
+	l := newSenderLimiter(60, 2)
+	l.now = func() time.Time { return now }
+
+	for n := uint64(1); n <= 2; n++ {
+		if err := l.allow(spend(n, "alice")); err != nil {
+			t.Fatalf("allow(tx %d) = %v, want nil", n, err)
+		}
+	}
+	if err := l.allow(spend(3, "alice")); errors.Root(err) != ErrRateLimited {
+		t.Errorf("allow(tx over burst) = %v, want %v", err, ErrRateLimited)
+	}
+
+	// A tx already admitted, as when Tendermint rechecks its
+	// mempool, is not charged again.
+	if err := l.allow(spend(1, "alice")); err != nil {
+		t.Errorf("allow(admitted tx) = %v, want nil", err)
+	}
+
+	// A tx is rejected if any of its senders is limited, and then
+	// charges none of them.
+	if err := l.allow(spend(4, "bob", "alice")); errors.Root(err) != ErrRateLimited {
+		t.Errorf("allow(tx from bob and alice) = %v, want %v", err, ErrRateLimited)
+	}
+	if got := l.buckets["sbob"].tokens; got != 2 {
+		t.Errorf("bob's tokens = %v, want 2", got)
+	}
+
+	// Senders earn tokens back at the rate.
+	now = now.Add(time.Second)
+	if err := l.allow(spend(5, "alice")); err != nil {
+		t.Errorf("allow(tx after a second) = %v, want nil", err)
+	}
+
+	// The limit can be lifted at runtime.
+	l.set(0, 2)
+	if err := l.allow(spend(6, "alice")); err != nil {
+		t.Errorf("allow(tx without limit) = %v, want nil", err)
+	}
+}
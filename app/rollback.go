@@ -0,0 +1,77 @@
+package app
+
+import (
+	"context"
+
+	"github.com/chainmint/core/txdb"
+	"github.com/chainmint/database/pg"
+	"github.com/chainmint/database/sql"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/log"
+)
+
+// ErrNoRollbackState is returned by Rollback when no strategy state
+// was saved at the chain height to roll back to, as for the states
+// saved before their chain height was recorded.
+var ErrNoRollbackState = errors.New("no strategy state at the rollback height")
+
+// Rollback rewinds the app's state to the chain height height: the
+// strategy states persisted after it are deleted, so the strategy
+// restarts from the state at height, and its indexes forget the
+// blocks above it. It is a core.RollbackFunc, called before Init
+// while the blocks above height are still in store.
+//
+// The strategy states and the commit record are kept by Tendermint
+// height, which runs ahead of the chain height once Tendermint has
+// made blocks with no txs. The state kept is the last one saved at
+// the chain height, and its Tendermint height is what Info reports
+// after the rollback.
+func (app *ChainmintApplication) Rollback(ctx context.Context, db pg.DB, store *txdb.Store, height uint64) error {
+	var tmHeight uint64
+	const tmHeightQ = `SELECT COALESCE(MAX(height), 0) FROM strategy_states WHERE chain_height = $1`
+	err := db.QueryRow(ctx, tmHeightQ, height).Scan(&tmHeight)
+	if err != nil {
+		return errors.Wrap(err, "strategy_states height query")
+	}
+	if tmHeight == 0 {
+		return errors.WithDetailf(ErrNoRollbackState, "chain height %d", height)
+	}
+
+	// The holder index keeps running totals, so the deltas of each
+	// block above height are reversed, newest first.
+	var indexed uint64
+	err = db.QueryRow(ctx, `SELECT height FROM block_processors WHERE name = $1`, holdersProcessor).Scan(&indexed)
+	if err != nil && err != sql.ErrNoRows {
+		return errors.Wrap(err, "reading asset holder index height")
+	}
+	for h := indexed; h > height; h-- {
+		b, err := store.GetBlock(ctx, h)
+		if err != nil {
+			return errors.Wrapf(err, "loading block %d", h)
+		}
+		err = unsaveHolders(ctx, db, h, holderDeltas(b))
+		if err != nil {
+			return err
+		}
+	}
+
+	deletes := []struct {
+		q      string
+		height uint64
+	}{
+		{`DELETE FROM strategy_states WHERE height > $1`, tmHeight},
+		{`DELETE FROM commit_wal WHERE height > $1`, tmHeight},
+		{`DELETE FROM metadata_index WHERE block_height > $1`, height},
+		{`DELETE FROM asset_supply WHERE height > $1`, height},
+		{`DELETE FROM balance_checkpoints WHERE height > $1`, height},
+		{`DELETE FROM balance_checkpoint_blocks WHERE height > $1`, height},
+	}
+	for _, d := range deletes {
+		_, err = db.Exec(ctx, d.q, d.height)
+		if err != nil {
+			return errors.Wrap(err, "rolling back app state")
+		}
+	}
+	log.Printkv(ctx, "at", "rolled back app state", "height", tmHeight, "chain_height", height)
+	return nil
+}
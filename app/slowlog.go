@@ -0,0 +1,81 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/chainmint/env"
+	"github.com/chainmint/trace"
+	abciTypes "github.com/tendermint/abci/types"
+)
+
+var (
+	// slowCommitThreshold is how long a Commit, or an indexing job
+	// it queues, may take before its timings are logged. 0 disables
+	// the log.
+	slowCommitThreshold = env.Duration("SLOW_COMMIT_THRESHOLD", 2*time.Second)
+
+	// slowQueryThreshold is how long a Query may take before it is
+	// logged. 0 disables the log.
+	slowQueryThreshold = env.Duration("SLOW_ABCI_QUERY_THRESHOLD", 500*time.Millisecond)
+)
+
+// commitPhases names the phases of Commit in the slow commit log,
+// by the span timing each.
+var commitPhases = map[string]string{
+	"app.saveCommitRecord":     "wal",
+	"chain.GenerateBlock":      "tx_apply",
+	"generator.signBlock":      "sign",
+	"chain.CommitAppliedBlock": "block_save",
+	"app.finishCommit":         "flush",
+	"app.commitHash":           "app_hash",
+}
+
+// logSlowCommit logs the timings of the Commit of the block at
+// height, with txs transactions, if it took longer than
+// SLOW_COMMIT_THRESHOLD. The Commit is timed by the span
+// abci.Commit, and its phases by the spans in commitPhases, all
+// started under the Context returned with timings.
+func logSlowCommit(ctx context.Context, height uint64, txs int, timings *trace.Timings) {
+	all := timings.All()
+	var total time.Duration
+	for _, t := range all {
+		if t.Name == "abci.Commit" {
+			total = t.Duration
+		}
+	}
+	if *slowCommitThreshold <= 0 || total <= *slowCommitThreshold {
+		return
+	}
+	keyvals := []interface{}{"at", "slow commit", "height", height, "txs", txs, "duration", total}
+	for _, t := range all {
+		if phase, ok := commitPhases[t.Name]; ok {
+			keyvals = append(keyvals, phase, t.Duration)
+		}
+	}
+	appLog.Warnkv(ctx, keyvals...)
+}
+
+// slowIndexLogged returns run, an indexing job queued by the Commit
+// of the block at height, logging its duration if it takes longer
+// than SLOW_COMMIT_THRESHOLD.
+func slowIndexLogged(name string, height uint64, run func(context.Context) error) func(context.Context) error {
+	return func(ctx context.Context) error {
+		t0 := time.Now()
+		err := run(ctx)
+		d := time.Since(t0)
+		if *slowCommitThreshold > 0 && d > *slowCommitThreshold {
+			appLog.Warnkv(ctx, "at", "slow index", "job", name, "height", height, "duration", d)
+		}
+		return err
+	}
+}
+
+// logSlowQuery logs query, which was answered with resp after d, if
+// d is longer than SLOW_ABCI_QUERY_THRESHOLD.
+func logSlowQuery(query abciTypes.RequestQuery, resp abciTypes.ResponseQuery, d time.Duration) {
+	if *slowQueryThreshold <= 0 || d <= *slowQueryThreshold {
+		return
+	}
+	appLog.Warnkv(context.Background(), "at", "slow query", "path", query.Path, "bytes", len(query.Data), "result_code", resp.Code, "response_bytes", len(resp.Value), "duration", d)
+}
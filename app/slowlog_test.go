@@ -0,0 +1,44 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chainmint/log"
+	"github.com/chainmint/trace"
+)
+
+func TestLogSlowCommit(t *testing.T) {
+	buf := new(bytes.Buffer)
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stdout)
+	defer func(old time.Duration) { *slowCommitThreshold = old }(*slowCommitThreshold)
+	*slowCommitThreshold = time.Millisecond
+
+	commit := func(d time.Duration) {
+		ctx, timings := trace.WithTimings(context.Background())
+		defer logSlowCommit(ctx, 9, 3, timings)
+		ctx, span := trace.Start(ctx, "abci.Commit")
+		defer span.End()
+		_, applySpan := trace.Start(ctx, "chain.GenerateBlock")
+		time.Sleep(d)
+		applySpan.End()
+	}
+
+	commit(0)
+	if buf.Len() > 0 {
+		t.Errorf("logged a fast commit: %s", buf)
+	}
+
+	commit(2 * time.Millisecond)
+	got := buf.String()
+	for _, w := range []string{"at=\"slow commit\"", "height=9", "txs=3", "duration=", "tx_apply="} {
+		if !strings.Contains(got, w) {
+			t.Errorf("slow commit log %q did not contain %q", got, w)
+		}
+	}
+}
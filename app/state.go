@@ -0,0 +1,126 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/chainmint/database/pg"
+	"github.com/chainmint/database/sql"
+	"github.com/chainmint/errors"
+	abciTypes "github.com/tendermint/abci/types"
+)
+
+// strategyState is the validator and strategy bookkeeping
+// persisted after each committed block.
+type strategyState struct {
+	Height     uint64
+	Validators []*abciTypes.Validator
+	Data       []byte // opaque strategy state, see types.PersistentStrategy
+	Governance []byte // JSON-encoded governance state
+	Stake      []byte // JSON-encoded stakeBook
+
+	// PendingDiffs are the validator diffs held back until
+	// the end of the current epoch.
+	PendingDiffs []*abciTypes.Validator
+
+	// ChainHeight is the height of the chain after the block at
+	// Height, which is behind Height once Tendermint has made
+	// blocks with no txs. It is 0 in states saved before it was
+	// recorded.
+	ChainHeight uint64
+}
+
+// saveStrategyState persists the validator set and strategy
+// state as of the given Tendermint height.
+func saveStrategyState(ctx context.Context, db pg.DB, st *strategyState) error {
+	validators, err := json.Marshal(st.Validators)
+	if err != nil {
+		return errors.Wrap(err, "marshaling validators")
+	}
+	pendingDiffs, err := json.Marshal(st.PendingDiffs)
+	if err != nil {
+		return errors.Wrap(err, "marshaling pending diffs")
+	}
+	const q = `
+		INSERT INTO strategy_states (height, validators, data, governance, stake, pending_diffs, chain_height)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (height) DO UPDATE
+			SET validators = excluded.validators, data = excluded.data,
+				governance = excluded.governance, stake = excluded.stake,
+				pending_diffs = excluded.pending_diffs, chain_height = excluded.chain_height
+	`
+	_, err = db.Exec(ctx, q, st.Height, validators, st.Data, st.Governance, st.Stake, pendingDiffs, st.ChainHeight)
+	return errors.Wrap(err, "strategy_states insert query")
+}
+
+// loadStrategyState returns the most recently persisted strategy
+// state. If nothing has been persisted yet, it returns nil.
+func loadStrategyState(ctx context.Context, db pg.DB) (*strategyState, error) {
+	return loadStrategyStateAt(ctx, db, 0)
+}
+
+// strategyCheckpointHeight returns the height of the latest
+// persisted strategy state, or 0 if there is none.
+func strategyCheckpointHeight(ctx context.Context, db pg.DB) (uint64, error) {
+	const q = `SELECT COALESCE(MAX(height), 0) FROM strategy_states`
+	var height uint64
+	err := db.QueryRow(ctx, q).Scan(&height)
+	return height, errors.Wrap(err, "strategy_states height query")
+}
+
+// loadStrategyStateAt returns the strategy state persisted at the
+// given height, or the most recent one if height is 0. If there is
+// no such state, it returns nil.
+func loadStrategyStateAt(ctx context.Context, db pg.DB, height uint64) (*strategyState, error) {
+	const q = `
+		SELECT height, validators, data, governance, stake, pending_diffs, COALESCE(chain_height, 0)
+		FROM strategy_states
+		WHERE $1 = 0 OR height = $1
+		ORDER BY height DESC LIMIT 1
+	`
+	var (
+		st           strategyState
+		validators   []byte
+		pendingDiffs []byte
+	)
+	err := db.QueryRow(ctx, q, height).Scan(&st.Height, &validators, &st.Data, &st.Governance, &st.Stake, &pendingDiffs, &st.ChainHeight)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "strategy_states select query")
+	}
+	err = json.Unmarshal(validators, &st.Validators)
+	if err != nil {
+		return nil, errors.Wrap(err, "unmarshaling validators")
+	}
+	if len(pendingDiffs) > 0 {
+		err = json.Unmarshal(pendingDiffs, &st.PendingDiffs)
+		if err != nil {
+			return nil, errors.Wrap(err, "unmarshaling pending diffs")
+		}
+	}
+	return &st, nil
+}
+
+// applyValidatorDiffs returns the validator set that results
+// from applying the EndBlock diffs to vals. A diff with zero
+// power removes the validator.
+func applyValidatorDiffs(vals, diffs []*abciTypes.Validator) []*abciTypes.Validator {
+	for _, d := range diffs {
+		i := 0
+		for ; i < len(vals); i++ {
+			if string(vals[i].PubKey) == string(d.PubKey) {
+				break
+			}
+		}
+		switch {
+		case i == len(vals) && d.Power > 0:
+			vals = append(vals, &abciTypes.Validator{PubKey: d.PubKey, Power: d.Power})
+		case i < len(vals) && d.Power > 0:
+			vals[i] = &abciTypes.Validator{PubKey: d.PubKey, Power: d.Power}
+		case i < len(vals):
+			vals = append(vals[:i], vals[i+1:]...)
+		}
+	}
+	return vals
+}
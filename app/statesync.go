@@ -0,0 +1,441 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"sync"
+
+	"github.com/chainmint/core/txdb"
+	"github.com/chainmint/crypto/provider"
+	"github.com/chainmint/crypto/sha3pool"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/log"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/strategies"
+)
+
+// abci 0.5 has no state sync, so the methods below are called over
+// HTTP instead; see StateSyncHandler and SyncFrom. They follow the
+// ABCI state-sync handshake: a node with state lists its snapshots
+// and serves their chunks, and a new node accepts one of the
+// snapshots offered by its peers and applies its chunks, in place of
+// replaying every block.
+
+const (
+	// syncFormat is the format of the snapshots offered for state
+	// sync: a JSON-encoded syncSnapshotDoc, split into chunks.
+	syncFormat = 1
+
+	syncChunkSize = 1 << 20
+)
+
+// ErrBadSyncSnapshot is returned when a snapshot received for state
+// sync cannot be restored.
+var ErrBadSyncSnapshot = errors.New("invalid state sync snapshot")
+
+// SyncSnapshot describes a snapshot of the application state for
+// state sync. Hash is the SHA3-256 hash of the whole snapshot, and
+// Metadata the SHA3-256 hashes of its chunks, concatenated, so each
+// chunk can be checked as it arrives.
+type SyncSnapshot struct {
+	Height   uint64
+	Format   uint32
+	Chunks   uint32
+	Hash     []byte
+	Metadata []byte
+}
+
+// OfferResult is the answer to OfferSnapshot.
+type OfferResult int
+
+const (
+	OfferAccept       OfferResult = iota // restore this snapshot
+	OfferAbort                           // abort state sync
+	OfferReject                          // reject this snapshot, try others
+	OfferRejectFormat                    // reject all snapshots of this format
+)
+
+// ChunkResult is the answer to ApplySnapshotChunk.
+type ChunkResult int
+
+const (
+	ChunkAccept         ChunkResult = iota // the chunk was applied
+	ChunkAbort                             // abort state sync
+	ChunkRetry                             // refetch the chunks returned with this result
+	ChunkRejectSnapshot                    // reject the snapshot, try others
+)
+
+// syncSnapshotDoc is the content of a state-sync snapshot: the state
+// snapshot of the chain at Block, the initial block, and the strategy
+// state persisted at the same height. Headers are those of the blocks
+// between the initial block and Block, for nodes syncing from a
+// checkpoint; they are left out if the serving node has pruned any.
+// StrategyName, CryptoProvider and AppHashFormat are the
+// compensation strategy, crypto provider and app hash format the
+// chain started with.
+type syncSnapshotDoc struct {
+	InitialBlock *legacy.Block         `json:"initial_block"`
+	Block        *legacy.Block         `json:"block"`
+	Headers      []*legacy.BlockHeader `json:"headers,omitempty"`
+	State        []byte                `json:"state"` // see txdb.EncodeSnapshot
+	Strategy     *strategyState        `json:"strategy"`
+
+	StrategyName   string `json:"strategy_name,omitempty"`
+	CryptoProvider string `json:"crypto_provider,omitempty"`
+	AppHashFormat  string `json:"app_hash_format,omitempty"`
+}
+
+// stateSync holds the snapshots this node serves, by height, built
+// on demand, and the snapshot it is restoring, if any.
+type stateSync struct {
+	mu      sync.Mutex
+	serving map[uint64]*servedSnapshot
+
+	restoring *restore
+}
+
+type servedSnapshot struct {
+	snapshot *SyncSnapshot
+	chunks   [][]byte
+}
+
+type restore struct {
+	snapshot *SyncSnapshot
+	appHash  []byte
+	chunks   [][]byte
+	missing  int
+}
+
+// ListSnapshots returns the snapshots this node can serve: the most
+// recent state snapshot saved by the chain and, if this node has a
+// checkpoint, the snapshot at its height, for peers syncing from the
+// same checkpoint. Each is served only if the strategy state at its
+// height is still stored.
+func (app *ChainmintApplication) ListSnapshots() []*SyncSnapshot {
+	ctx := context.Background()
+	height, _, err := app.backend.Store().LatestSnapshotInfo(ctx)
+	if err != nil || height == 0 {
+		return nil
+	}
+	heights := []uint64{height}
+	if app.checkpoint != nil && app.checkpoint.Height < height {
+		heights = append(heights, app.checkpoint.Height)
+	}
+
+	// Stop serving snapshots superseded by a newer one.
+	app.sync.mu.Lock()
+	for h := range app.sync.serving {
+		if h != height && (app.checkpoint == nil || h != app.checkpoint.Height) {
+			delete(app.sync.serving, h)
+		}
+	}
+	app.sync.mu.Unlock()
+
+	var list []*SyncSnapshot
+	for _, h := range heights {
+		s, err := app.syncSnapshot(ctx, h)
+		if err != nil {
+			log.Error(ctx, err, "at", "listing state sync snapshots", "height", h)
+			continue
+		}
+		if s != nil {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// LoadSnapshotChunk returns the given chunk of the snapshot at
+// height, or nil if it is not being served.
+func (app *ChainmintApplication) LoadSnapshotChunk(height uint64, format, chunk uint32) []byte {
+	app.sync.mu.Lock()
+	defer app.sync.mu.Unlock()
+	served := app.sync.serving[height]
+	if served == nil || served.snapshot.Format != format || chunk >= served.snapshot.Chunks {
+		return nil
+	}
+	return served.chunks[chunk]
+}
+
+// syncSnapshot returns the snapshot at height served for state sync,
+// building it if it is not served yet. It returns nil if there is no
+// snapshot to serve at height.
+func (app *ChainmintApplication) syncSnapshot(ctx context.Context, height uint64) (*SyncSnapshot, error) {
+	store := app.backend.Store()
+	app.sync.mu.Lock()
+	defer app.sync.mu.Unlock()
+	if served := app.sync.serving[height]; served != nil {
+		return served.snapshot, nil
+	}
+
+	st, err := loadStrategyStateAt(ctx, app.backend.DB(), height)
+	if err != nil || st == nil {
+		return nil, err
+	}
+	doc := &syncSnapshotDoc{
+		Strategy:       st,
+		StrategyName:   app.strategyName,
+		CryptoProvider: app.crypto.Name(),
+		AppHashFormat:  app.hashFormat,
+	}
+	doc.State, err = store.GetSnapshot(ctx, height)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading state snapshot")
+	}
+	doc.InitialBlock, err = store.GetBlock(ctx, 1)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading initial block")
+	}
+	doc.Block, err = store.GetBlock(ctx, height)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading snapshot block")
+	}
+	pruned, err := store.PrunedBelow(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if height > 2 && pruned <= 2 {
+		doc.Headers, err = store.GetBlockHeaders(ctx, 2, height-1)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading block headers")
+		}
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "encoding state sync snapshot")
+	}
+	served := new(servedSnapshot)
+	served.snapshot, served.chunks = chunkSnapshot(height, b)
+	if app.sync.serving == nil {
+		app.sync.serving = make(map[uint64]*servedSnapshot)
+	}
+	app.sync.serving[height] = served
+	return served.snapshot, nil
+}
+
+// chunkSnapshot splits b into chunks and describes them.
+func chunkSnapshot(height uint64, b []byte) (*SyncSnapshot, [][]byte) {
+	s := &SyncSnapshot{Height: height, Format: syncFormat}
+	var chunks [][]byte
+	for len(b) > 0 {
+		n := syncChunkSize
+		if n > len(b) {
+			n = len(b)
+		}
+		chunks = append(chunks, b[:n])
+		s.Metadata = append(s.Metadata, sum256(b[:n])...)
+		b = b[n:]
+	}
+	s.Chunks = uint32(len(chunks))
+	s.Hash = sum256(bytes.Join(chunks, nil))
+	return s, chunks
+}
+
+func sum256(b []byte) []byte {
+	var h [32]byte
+	sha3pool.Sum256(h[:], b)
+	return h[:]
+}
+
+// OfferSnapshot is called on a new node with a snapshot offered by
+// a peer, and appHash, the app hash at the snapshot's height as
+// verified by the light client. A node that already has blocks
+// aborts state sync. A node with a checkpoint rejects snapshots at
+// any other height.
+func (app *ChainmintApplication) OfferSnapshot(s *SyncSnapshot, appHash []byte) OfferResult {
+	if b, _ := app.currentState(); b != nil {
+		return OfferAbort
+	}
+	if s.Format != syncFormat {
+		return OfferRejectFormat
+	}
+	if app.checkpoint != nil && s.Height != app.checkpoint.Height {
+		return OfferReject
+	}
+	if s.Chunks == 0 || len(s.Metadata) != 32*int(s.Chunks) || len(s.Hash) != 32 {
+		return OfferReject
+	}
+	app.sync.mu.Lock()
+	defer app.sync.mu.Unlock()
+	app.sync.restoring = &restore{
+		snapshot: s,
+		appHash:  appHash,
+		chunks:   make([][]byte, s.Chunks),
+		missing:  int(s.Chunks),
+	}
+	return OfferAccept
+}
+
+// ApplySnapshotChunk is called with each chunk of the snapshot
+// accepted by OfferSnapshot. A chunk that does not match its hash is
+// fetched again. Once every chunk has arrived, the snapshot is
+// restored; if it cannot be, it is rejected.
+func (app *ChainmintApplication) ApplySnapshotChunk(index uint32, chunk []byte, sender string) (ChunkResult, []uint32) {
+	app.sync.mu.Lock()
+	defer app.sync.mu.Unlock()
+	r := app.sync.restoring
+	if r == nil {
+		return ChunkAbort, nil
+	}
+	if index >= r.snapshot.Chunks {
+		app.sync.restoring = nil
+		return ChunkRejectSnapshot, nil
+	}
+	if !bytes.Equal(sum256(chunk), r.snapshot.Metadata[32*index:32*(index+1)]) {
+		log.Printkv(context.Background(), "at", "state sync chunk hash mismatch", "index", index, "sender", sender)
+		return ChunkRetry, []uint32{index}
+	}
+	if r.chunks[index] == nil {
+		r.chunks[index] = chunk
+		r.missing--
+	}
+	if r.missing > 0 {
+		return ChunkAccept, nil
+	}
+
+	app.sync.restoring = nil
+	err := app.restoreSnapshot(context.Background(), r)
+	if err != nil {
+		log.Error(context.Background(), err, "at", "restoring state sync snapshot", "height", r.snapshot.Height)
+		return ChunkRejectSnapshot, nil
+	}
+	return ChunkAccept, nil
+}
+
+// restoreSnapshot checks the snapshot received in r against the
+// hashes it was offered with, and the checkpoint if any, then stores its blocks, chain state
+// and strategy state as if the node had processed the chain up to
+// its height.
+func (app *ChainmintApplication) restoreSnapshot(ctx context.Context, r *restore) error {
+	doc, err := r.decode()
+	if err != nil {
+		return err
+	}
+	if doc.Block.Height != r.snapshot.Height || doc.InitialBlock.Height != 1 || doc.Strategy.Height != r.snapshot.Height {
+		return errors.WithDetailf(ErrBadSyncSnapshot, "snapshot heights do not match %d", r.snapshot.Height)
+	}
+	if app.checkpoint != nil {
+		err = app.checkpoint.verify(doc.InitialBlock, doc.Block, doc.Headers)
+		if err != nil {
+			return err
+		}
+	}
+	// The provider and format are checked along with the state: a
+	// snapshot naming the wrong ones yields an app hash other than
+	// the trusted one.
+	// Unlike the provider and format, the strategy is not checked
+	// against the trusted app hash.
+	strategyName := doc.StrategyName
+	if strategyName == "" {
+		strategyName = defaultStrategy
+	}
+	_, err = strategies.New(strategyName, strategies.Config{})
+	if err != nil {
+		return errors.Sub(ErrBadSyncSnapshot, err)
+	}
+	cryptoName := doc.CryptoProvider
+	if cryptoName == "" {
+		cryptoName = provider.Default
+	}
+	crypto, err := provider.Lookup(cryptoName)
+	if err != nil {
+		return errors.Sub(ErrBadSyncSnapshot, err)
+	}
+	format := doc.AppHashFormat
+	if format == "" {
+		format = appHashBlock
+	}
+	rules, err := app.backend.Chain().Rules(doc.Block.Height)
+	if err != nil {
+		return err
+	}
+	appHash := appHashOf(crypto, doc.Block.Hash().Bytes())
+	var appState *appStateTree
+	if format == appHashStateRoot || rules.Enabled(stateRootUpgrade) {
+		entries, err := appStateEntries(doc.Block, doc.Strategy)
+		if err != nil {
+			return errors.Sub(ErrBadSyncSnapshot, err)
+		}
+		appState = new(appStateTree)
+		root, err := appState.update(entries)
+		if err != nil {
+			return errors.Sub(ErrBadSyncSnapshot, err)
+		}
+		appHash = root.Bytes()
+	}
+	if len(r.appHash) > 0 && !bytes.Equal(appHash, r.appHash) {
+		return errors.WithDetail(ErrBadSyncSnapshot, "snapshot state does not match the trusted app hash")
+	}
+	snapshot, err := txdb.DecodeSnapshot(doc.State)
+	if err != nil {
+		return errors.Sub(ErrBadSyncSnapshot, err)
+	}
+	if snapshot.Tree.RootHash() != doc.Block.AssetsMerkleRoot {
+		return errors.WithDetail(ErrBadSyncSnapshot, "state root does not match the snapshot block")
+	}
+	// The block commits to the state tree but not to the issuance
+	// nonces, so they are dropped, as when bootstrapping from a peer
+	// Core. Issuances cannot be checked for uniqueness until the
+	// max issuance window has elapsed.
+	snapshot.PruneNonces(math.MaxUint64)
+
+	store := app.backend.Store()
+	if doc.Block.Height > 1 {
+		err = store.SaveBlock(ctx, doc.InitialBlock)
+		if err != nil {
+			return errors.Wrap(err, "saving initial block")
+		}
+	}
+	err = store.SaveSnapshot(ctx, doc.Block.Height, snapshot)
+	if err != nil {
+		return err
+	}
+	err = app.backend.Chain().CommitAppliedBlock(ctx, doc.Block, snapshot)
+	if err != nil {
+		return errors.Wrap(err, "committing snapshot block")
+	}
+	doc.Strategy.ChainHeight = doc.Block.Height
+	err = saveStrategyState(ctx, app.backend.DB(), doc.Strategy)
+	if err != nil {
+		return err
+	}
+	err = app.startStrategy(ctx, strategyName)
+	if err != nil {
+		return err
+	}
+	err = pinCryptoProvider(ctx, app.backend.DB(), cryptoName)
+	if err != nil {
+		return err
+	}
+	err = pinAppHashFormat(ctx, app.backend.DB(), format)
+	if err != nil {
+		return err
+	}
+	app.crypto = crypto
+	app.hashFormat = format
+	if appState != nil {
+		app.appState = appState
+	}
+	log.Printkv(ctx, "at", "restored state sync snapshot", "height", doc.Block.Height)
+	return app.restoreStrategyState(ctx)
+}
+
+// decode checks the chunks of r against the snapshot hash and
+// decodes them.
+func (r *restore) decode() (*syncSnapshotDoc, error) {
+	b := bytes.Join(r.chunks, nil)
+	if !bytes.Equal(sum256(b), r.snapshot.Hash) {
+		return nil, errors.WithDetail(ErrBadSyncSnapshot, "snapshot does not match its hash")
+	}
+	doc := new(syncSnapshotDoc)
+	err := json.Unmarshal(b, doc)
+	if err != nil {
+		return nil, errors.Sub(ErrBadSyncSnapshot, err)
+	}
+	if doc.InitialBlock == nil || doc.Block == nil || doc.Strategy == nil {
+		return nil, errors.WithDetail(ErrBadSyncSnapshot, "snapshot is incomplete")
+	}
+	return doc, nil
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/state"
+)
+
+func TestStateSyncChunks(t *testing.T) {
+	blob := bytes.Repeat([]byte{'x'}, 2*syncChunkSize+10)
+	s, chunks := chunkSnapshot(7, blob)
+	if s.Chunks != 3 || len(chunks) != 3 || len(chunks[2]) != 10 || len(s.Metadata) != 3*32 {
+		t.Fatalf("chunked snapshot = %+v with %d chunks", s, len(chunks))
+	}
+
+	app := &ChainmintApplication{
+		currentState: func() (*legacy.Block, *state.Snapshot) { return nil, nil },
+	}
+	if got := app.OfferSnapshot(&SyncSnapshot{Format: syncFormat + 1}, nil); got != OfferRejectFormat {
+		t.Errorf("offer of unknown format = %v want %v", got, OfferRejectFormat)
+	}
+	if got := app.OfferSnapshot(s, nil); got != OfferAccept {
+		t.Fatalf("offer = %v want %v", got, OfferAccept)
+	}
+
+	res, refetch := app.ApplySnapshotChunk(1, []byte("corrupt"), "peer")
+	if res != ChunkRetry || len(refetch) != 1 || refetch[0] != 1 {
+		t.Errorf("apply of corrupt chunk = %v %v, want retry of 1", res, refetch)
+	}
+	for _, i := range []uint32{0, 1} {
+		res, _ = app.ApplySnapshotChunk(i, chunks[i], "peer")
+		if res != ChunkAccept {
+			t.Fatalf("apply of chunk %d = %v want %v", i, res, ChunkAccept)
+		}
+	}
+	// The chunks are intact, but do not hold a snapshot document.
+	res, _ = app.ApplySnapshotChunk(2, chunks[2], "peer")
+	if res != ChunkRejectSnapshot {
+		t.Errorf("apply of last chunk = %v want %v", res, ChunkRejectSnapshot)
+	}
+	if res, _ = app.ApplySnapshotChunk(0, chunks[0], "peer"); res != ChunkAbort {
+		t.Errorf("apply after rejection = %v want %v", res, ChunkAbort)
+	}
+
+	app.currentState = func() (*legacy.Block, *state.Snapshot) { return &legacy.Block{}, nil }
+	if got := app.OfferSnapshot(s, nil); got != OfferAbort {
+		t.Errorf("offer to a node with blocks = %v want %v", got, OfferAbort)
+	}
+}
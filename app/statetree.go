@@ -0,0 +1,307 @@
+package app
+
+import (
+	"context"
+	"encoding/hex"
+	stdjson "encoding/json"
+	"strconv"
+	"sync"
+
+	"github.com/chainmint/crypto/sha3pool"
+	"github.com/chainmint/database/pg"
+	"github.com/chainmint/database/sql"
+	"github.com/chainmint/encoding/json"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/patricia"
+	"github.com/chainmint/protocol/upgrade"
+)
+
+// Formats of the app hash. A chain commits to its block hash alone,
+// as chains always have, or to the root of its app state tree.
+//
+// The format is chain-wide, never a node's own choice: a chain
+// commits to the format in its genesis document, or to the block
+// hash if the document names none, until the upgrade
+// stateRootUpgrade activates. Each node records the format it
+// started with, from the genesis document or a state sync snapshot
+// checked against the trusted app hash.
+const (
+	appHashBlock     = "block"
+	appHashStateRoot = "state-root"
+)
+
+// stateRootUpgrade is the upgrade, and its feature, that moves a
+// chain committing to its block hash to its app state root.
+const stateRootUpgrade = "state-root-app-hash"
+
+func init() {
+	upgrade.Register(upgrade.Upgrade{Name: stateRootUpgrade, Features: []string{stateRootUpgrade}})
+}
+
+// ErrAppHashMismatch is returned when a chain is started with an app
+// hash format other than the one it was created with.
+var ErrAppHashMismatch = errors.New("app hash format differs from the chain's")
+
+// appStateTree is an authenticated map of the application state,
+// from which the app hash is computed. Each entry is a leaf of a
+// patricia tree holding the hash of its key followed by the hash of
+// its value, so that the tree is updated only where the state
+// changes, and any entry can be proven against the app hash.
+//
+// The entries are the latest block's hash and state root, which
+// commits to the UTXO set, and the validator, stake, governance and
+// strategy state after it; see appStateEntries.
+type appStateTree struct {
+	mu     sync.Mutex
+	tree   patricia.Tree
+	values map[string][]byte
+}
+
+// update makes the entries of t those of entries, and returns the
+// new root hash.
+func (t *appStateTree) update(entries map[string][]byte) (bc.Hash, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.values == nil {
+		t.values = make(map[string][]byte)
+	}
+	for k, v := range t.values {
+		if _, ok := entries[k]; !ok {
+			t.tree.Delete(stateItem(k, v))
+			delete(t.values, k)
+		}
+	}
+	for k, v := range entries {
+		old, ok := t.values[k]
+		if ok && string(old) == string(v) {
+			continue
+		}
+		if ok {
+			t.tree.Delete(stateItem(k, old))
+		}
+		err := t.tree.Insert(stateItem(k, v))
+		if err != nil {
+			return bc.Hash{}, errors.Wrapf(err, "inserting app state entry %s", k)
+		}
+		t.values[k] = v
+	}
+	return t.tree.RootHash(), nil
+}
+
+func (t *appStateTree) root() bc.Hash {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.tree.RootHash()
+}
+
+// prove returns the value of key, the root hash, and a proof that
+// the entry is in the tree, or ok false if there is no such key.
+func (t *appStateTree) prove(key string) (value []byte, root bc.Hash, proof []patricia.ProofStep, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	value, ok = t.values[key]
+	if !ok {
+		return nil, bc.Hash{}, nil, false
+	}
+	proof, ok = t.tree.Prove(stateItem(key, value))
+	return value, t.tree.RootHash(), proof, ok
+}
+
+// stateItem returns the tree item of the entry key, value.
+func stateItem(key string, value []byte) []byte {
+	item := make([]byte, 64)
+	sha3pool.Sum256(item[:32], []byte(key))
+	sha3pool.Sum256(item[32:], value)
+	return item
+}
+
+// appStateEntries returns the entries of the app state tree after
+// block b, given st, the strategy state persisted after it. Keys
+// are paths naming each validator by its hex-encoded public key,
+// and each delegator by its hex-encoded control program.
+func appStateEntries(b *legacy.Block, st *strategyState) (map[string][]byte, error) {
+	entries := map[string][]byte{
+		"block":     b.Hash().Bytes(),
+		"utxo-root": b.AssetsMerkleRoot.Bytes(),
+	}
+	if st == nil {
+		return entries, nil
+	}
+	for _, v := range st.Validators {
+		entries["validators/"+hex.EncodeToString(v.PubKey)] = []byte(strconv.FormatUint(v.Power, 10))
+	}
+	for _, v := range st.PendingDiffs {
+		entries["pending-diffs/"+hex.EncodeToString(v.PubKey)] = []byte(strconv.FormatUint(v.Power, 10))
+	}
+	entries["governance"] = st.Governance
+	entries["strategy"] = st.Data
+	if len(st.Stake) > 0 {
+		var book stakeBook
+		err := stdjson.Unmarshal(st.Stake, &book)
+		if err != nil {
+			return nil, errors.Wrap(err, "unmarshaling stake state")
+		}
+		for v, delegators := range book.Delegations {
+			for d, amount := range delegators {
+				entries["delegations/"+v+"/"+d] = []byte(strconv.FormatUint(amount, 10))
+			}
+		}
+		for v, rate := range book.Commissions {
+			entries["commissions/"+v] = []byte(strconv.FormatUint(rate, 10))
+		}
+		for d, amount := range book.Accruals {
+			entries["accruals/"+d] = []byte(strconv.FormatUint(amount, 10))
+		}
+	}
+	return entries, nil
+}
+
+// pinAppHashFormat records format, from the genesis document or a
+// state sync snapshot, as the app hash format of the chain if none
+// is recorded yet, and otherwise checks that it is the one
+// recorded.
+func pinAppHashFormat(ctx context.Context, db pg.DB, format string) error {
+	if format != appHashBlock && format != appHashStateRoot {
+		return errors.WithDetailf(ErrAppHashMismatch, "unknown app hash format %q", format)
+	}
+	const q = `
+		WITH ins AS (
+			INSERT INTO chain_app_hash (format) VALUES ($1)
+			ON CONFLICT (singleton) DO NOTHING
+			RETURNING format
+		)
+		SELECT format FROM ins
+		UNION ALL SELECT format FROM chain_app_hash
+	`
+	var pinned string
+	err := db.QueryRow(ctx, q, format).Scan(&pinned)
+	if err != nil {
+		return errors.Wrap(err, "chain_app_hash upsert query")
+	}
+	if pinned != format {
+		return errors.WithDetailf(ErrAppHashMismatch, "chain commits to %q, started with %q", pinned, format)
+	}
+	return nil
+}
+
+// loadAppHashFormat returns the app hash format recorded for the
+// chain, or appHashBlock if none is, as for a chain started before
+// formats were recorded.
+func loadAppHashFormat(ctx context.Context, db pg.DB) (string, error) {
+	var format string
+	err := db.QueryRow(ctx, `SELECT format FROM chain_app_hash`).Scan(&format)
+	if err == sql.ErrNoRows {
+		return appHashBlock, nil
+	}
+	return format, errors.Wrap(err, "chain_app_hash query")
+}
+
+// startAppHashFormat records format as the app hash format of the
+// chain, which has no blocks yet.
+func (app *ChainmintApplication) startAppHashFormat(ctx context.Context, format string) error {
+	err := pinAppHashFormat(ctx, app.backend.DB(), format)
+	if err != nil {
+		return err
+	}
+	app.hashFormat = format
+	if format == appHashStateRoot && app.appState == nil {
+		app.appState = new(appStateTree)
+	}
+	return nil
+}
+
+// stateRootAt reports whether the chain commits to its app state
+// root after the block at height, by its recorded format or the
+// activation of stateRootUpgrade.
+func (app *ChainmintApplication) stateRootAt(height uint64) bool {
+	if app.hashFormat == appHashStateRoot {
+		return true
+	}
+	rules, err := app.backend.Chain().Rules(height)
+	return err == nil && rules.Enabled(stateRootUpgrade)
+}
+
+// initAppState loads the chain's app hash format and, if the chain
+// commits to its state root, builds the app state tree from the
+// latest block and strategy state.
+func (app *ChainmintApplication) initAppState(ctx context.Context) error {
+	format, err := loadAppHashFormat(ctx, app.backend.DB())
+	if err != nil {
+		return err
+	}
+	app.hashFormat = format
+	b, _ := app.currentState()
+	var height uint64
+	if b != nil {
+		height = b.Height
+	}
+	if !app.stateRootAt(height) {
+		return nil
+	}
+	app.appState = new(appStateTree)
+	if b == nil {
+		return nil
+	}
+	st, err := loadStrategyState(ctx, app.backend.DB())
+	if err != nil {
+		return errors.Wrap(err, "loading strategy state")
+	}
+	entries, err := appStateEntries(b, st)
+	if err != nil {
+		return err
+	}
+	_, err = app.appState.update(entries)
+	return err
+}
+
+// commitHash returns the app hash to commit after block b, given st,
+// the strategy state after it, and blockHash, b's hash as returned
+// by the generator.
+func (app *ChainmintApplication) commitHash(b *legacy.Block, st *strategyState, blockHash []byte) ([]byte, error) {
+	if b == nil || !app.stateRootAt(b.Height) {
+		return app.appHash(blockHash), nil
+	}
+	if app.appState == nil {
+		app.appState = new(appStateTree) // stateRootUpgrade activates
+	}
+	if st == nil {
+		return nil, errors.New("no strategy state to commit to")
+	}
+	entries, err := appStateEntries(b, st)
+	if err != nil {
+		return nil, err
+	}
+	root, err := app.appState.update(entries)
+	if err != nil {
+		return nil, err
+	}
+	return root.Bytes(), nil
+}
+
+// queryStateProof answers a query for an entry of the app state
+// tree, given as {"key": <key>}, with its value and a proof that it
+// is committed to by the app hash.
+func (app *ChainmintApplication) queryStateProof(ctx context.Context, data []byte) (interface{}, error) {
+	var in struct {
+		Key string `json:"key"`
+	}
+	err := decodeQuery(data, &in)
+	if err != nil {
+		return nil, err
+	}
+	if app.appState == nil {
+		return nil, errors.WithDetail(errBadQuery, "the chain's app hash is its block hash")
+	}
+	value, root, proof, ok := app.appState.prove(in.Key)
+	if !ok {
+		return nil, errors.WithDetailf(errBadQuery, "no app state entry %q", in.Key)
+	}
+	return struct {
+		Key     string               `json:"key"`
+		Value   json.HexBytes        `json:"value"`
+		AppHash bc.Hash              `json:"app_hash"`
+		Proof   []patricia.ProofStep `json:"proof"`
+	}{in.Key, value, root, proof}, nil
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/chainmint/protocol/patricia"
+)
+
+func TestAppStateTreeUpdate(t *testing.T) {
+	var incremental appStateTree
+	_, err := incremental.update(map[string][]byte{
+		"block":         []byte("b1"),
+		"validators/aa": []byte("10"),
+		"validators/bb": []byte("20"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := map[string][]byte{
+		"block":         []byte("b2"),
+		"validators/aa": []byte("10"),
+		"validators/cc": []byte("5"),
+	}
+	got, err := incremental.update(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fresh appStateTree
+	want, err := fresh.update(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("incremental root = %x, want %x", got.Bytes(), want.Bytes())
+	}
+
+	for k, v := range entries {
+		value, root, proof, ok := incremental.prove(k)
+		if !ok || string(value) != string(v) {
+			t.Errorf("prove(%q) = %q, %t want %q, true", k, value, ok, v)
+			continue
+		}
+		if !patricia.VerifyProof(root, stateItem(k, value), proof) {
+			t.Errorf("proof of %q does not verify", k)
+		}
+	}
+	if _, _, _, ok := incremental.prove("validators/bb"); ok {
+		t.Error("prove found a removed entry")
+	}
+}
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"context"
+
+	"github.com/chainmint/database/pg"
+	"github.com/chainmint/database/sql"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/strategies"
+)
+
+// The compensation strategy of a chain is named in its genesis
+// document, so every node pays the same validators, and is recorded
+// in the node's database when the chain starts. A chain whose
+// genesis names none, as those made before strategies could be
+// named, uses defaultStrategy.
+
+// defaultStrategy is the strategy of a chain whose genesis names
+// none.
+const defaultStrategy = "round-robin"
+
+// ErrStrategyMismatch is returned when a chain is started with a
+// compensation strategy other than the one it was created with.
+var ErrStrategyMismatch = errors.New("strategy differs from the chain's")
+
+// useStrategy sets the compensation strategy of the chain to the one
+// registered under name.
+func (app *ChainmintApplication) useStrategy(name string) error {
+	s, err := strategies.New(name, strategies.Config{FeeAsset: app.backend.FeeAsset()})
+	if err != nil {
+		return err
+	}
+	app.strategy = s
+	app.strategyName = name
+	return nil
+}
+
+// startStrategy records name, from the genesis document, as the
+// compensation strategy of the chain, which has no blocks yet, and
+// uses it. A strategy given to NewChainmintApplication is kept.
+func (app *ChainmintApplication) startStrategy(ctx context.Context, name string) error {
+	if app.strategyName != "" && app.strategyName != name {
+		err := app.useStrategy(name)
+		if err != nil {
+			return err
+		}
+	}
+	return pinStrategy(ctx, app.backend.DB(), name)
+}
+
+// pinStrategy records name as the compensation strategy of the
+// chain if none is recorded yet, and otherwise checks that it is
+// the one recorded.
+func pinStrategy(ctx context.Context, db pg.DB, name string) error {
+	const q = `
+		WITH ins AS (
+			INSERT INTO chain_strategy (name) VALUES ($1)
+			ON CONFLICT (singleton) DO NOTHING
+			RETURNING name
+		)
+		SELECT name FROM ins
+		UNION ALL SELECT name FROM chain_strategy
+	`
+	var pinned string
+	err := db.QueryRow(ctx, q, name).Scan(&pinned)
+	if err != nil {
+		return errors.Wrap(err, "chain_strategy upsert query")
+	}
+	if pinned != name {
+		return errors.WithDetailf(ErrStrategyMismatch, "chain was created with %q, started with %q", pinned, name)
+	}
+	return nil
+}
+
+// loadStrategyName returns the name of the compensation strategy
+// recorded for the chain, or defaultStrategy if none is, as for a
+// chain not yet started.
+func loadStrategyName(ctx context.Context, db pg.DB) (string, error) {
+	var name string
+	err := db.QueryRow(ctx, `SELECT name FROM chain_strategy`).Scan(&name)
+	if err == sql.ErrNoRows {
+		return defaultStrategy, nil
+	}
+	return name, errors.Wrap(err, "chain_strategy query")
+}
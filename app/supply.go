@@ -0,0 +1,304 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/lib/pq"
+
+	"github.com/chainmint/database/pg"
+	"github.com/chainmint/database/sql"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/vmutil"
+)
+
+// supplyProcessor names the block processor that records the
+// height through which the asset supply history is up to date.
+const supplyProcessor = "asset_supply"
+
+// maxSupplyPoints bounds the points returned by one supply history
+// query.
+const maxSupplyPoints = 1000
+
+// supplyDelta is the units of an asset issued and retired in one
+// block.
+type supplyDelta struct {
+	issued, retired uint64
+}
+
+// supplyDeltas returns the units of each asset issued and retired
+// by the transactions of b. Assets neither issued nor retired are
+// left out.
+func supplyDeltas(b *legacy.Block) map[bc.AssetID]*supplyDelta {
+	deltas := make(map[bc.AssetID]*supplyDelta)
+	delta := func(assetID bc.AssetID) *supplyDelta {
+		d, ok := deltas[assetID]
+		if !ok {
+			d = new(supplyDelta)
+			deltas[assetID] = d
+		}
+		return d
+	}
+	for _, tx := range b.Transactions {
+		for _, in := range tx.Inputs {
+			if in.IsIssuance() && in.Amount() > 0 {
+				delta(in.AssetID()).issued += in.Amount()
+			}
+		}
+		for _, out := range tx.Outputs {
+			if vmutil.IsUnspendable(out.ControlProgram) && out.Amount > 0 {
+				delta(*out.AssetId).retired += out.Amount
+			}
+		}
+	}
+	return deltas
+}
+
+// indexSupply brings the asset supply history up to date with the
+// chain, recording the supply changes of each block committed since
+// it was last updated.
+func (app *ChainmintApplication) indexSupply(ctx context.Context) error {
+	db := app.backend.DB()
+	const q = `
+		INSERT INTO block_processors (name, height) VALUES ($1, 0)
+		ON CONFLICT (name) DO UPDATE SET name = excluded.name
+		RETURNING height
+	`
+	var height uint64
+	err := db.QueryRow(ctx, q, supplyProcessor).Scan(&height)
+	if err != nil {
+		return errors.Wrap(err, "reading asset supply height")
+	}
+	chain := app.backend.Chain()
+	for height < chain.Height() {
+		height++
+		b, err := chain.GetBlock(ctx, height)
+		if err != nil {
+			return errors.Wrapf(err, "loading block %d", height)
+		}
+		err = saveSupply(ctx, db, height, b.TimestampMS, supplyDeltas(b))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveSupply records the supply changes of the block at height, in
+// a single database transaction when the database supports it.
+// Changes for a height already recorded are ignored.
+func saveSupply(ctx context.Context, db pg.DB, height, timestampMS uint64, deltas map[bc.AssetID]*supplyDelta) (err error) {
+	if beginner, ok := db.(interface {
+		Begin(context.Context) (*sql.Tx, error)
+	}); ok {
+		var dbtx *sql.Tx
+		dbtx, err = beginner.Begin(ctx)
+		if err != nil {
+			return errors.Wrap(err, "begin asset supply transaction")
+		}
+		defer func() {
+			if err != nil {
+				dbtx.Rollback(ctx)
+				return
+			}
+			err = errors.Wrap(dbtx.Commit(ctx), "commit asset supply transaction")
+		}()
+		db = dbtx
+	}
+
+	const heightQ = `UPDATE block_processors SET height = $1 WHERE height < $1 AND name = $2`
+	res, err := db.Exec(ctx, heightQ, height, supplyProcessor)
+	if err != nil {
+		return errors.Wrap(err, "advancing asset supply height")
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return errors.Wrap(err)
+	}
+	if len(deltas) == 0 {
+		return nil
+	}
+
+	var (
+		assetIDs = pq.ByteaArray(make([][]byte, 0, len(deltas)))
+		issued   = pq.Int64Array(make([]int64, 0, len(deltas)))
+		retired  = pq.Int64Array(make([]int64, 0, len(deltas)))
+	)
+	for assetID, d := range deltas {
+		assetIDs = append(assetIDs, assetID.Bytes())
+		issued = append(issued, int64(d.issued))
+		retired = append(retired, int64(d.retired))
+	}
+	const insertQ = `
+		INSERT INTO asset_supply (asset_id, height, timestamp, issued, retired, total_issued, total_retired)
+		SELECT d.asset_id, $1, $2, d.issued, d.retired,
+			COALESCE(p.total_issued, 0) + d.issued, COALESCE(p.total_retired, 0) + d.retired
+		FROM unnest($3::bytea[], $4::bigint[], $5::bigint[]) AS d (asset_id, issued, retired)
+		LEFT JOIN LATERAL (
+			SELECT total_issued, total_retired FROM asset_supply AS s
+			WHERE s.asset_id = d.asset_id
+			ORDER BY s.height DESC LIMIT 1
+		) AS p ON true
+	`
+	_, err = db.Exec(ctx, insertQ, height, timestampMS, assetIDs, issued, retired)
+	return errors.Wrap(err, "asset_supply insert query")
+}
+
+// supplyPoint is the supply of an asset over a span of blocks: the
+// units issued and retired in the span, and the circulating supply
+// at its last block.
+type supplyPoint struct {
+	Height      uint64 `json:"height"`
+	TimestampMS uint64 `json:"timestamp"`
+	Issued      uint64 `json:"issued"`
+	Retired     uint64 `json:"retired"`
+	Supply      uint64 `json:"supply"`
+}
+
+// supplyHistory returns up to limit points of the supply history of
+// assetID from start through end, oldest first. Each point covers
+// interval blocks, aligned to start, and is left out if the supply
+// did not change in them. It also returns the height to start the
+// next page from, or 0 if there is none.
+func supplyHistory(ctx context.Context, db pg.DB, assetID bc.AssetID, start, end, interval uint64, limit int) ([]*supplyPoint, uint64, error) {
+	const q = `
+		SELECT (height - $2) / $4 AS bucket, MAX(height), MAX(timestamp), SUM(issued), SUM(retired),
+			(array_agg(total_issued - total_retired ORDER BY height DESC))[1]
+		FROM asset_supply
+		WHERE asset_id = $1 AND height >= $2 AND height <= $3
+		GROUP BY bucket
+		ORDER BY bucket
+		LIMIT $5
+	`
+	var (
+		points  []*supplyPoint
+		buckets []uint64
+	)
+	err := pg.ForQueryRows(ctx, db, q, assetID, start, end, interval, limit+1, func(bucket, height, timestampMS, issued, retired, supply uint64) {
+		buckets = append(buckets, bucket)
+		points = append(points, &supplyPoint{
+			Height:      height,
+			TimestampMS: timestampMS,
+			Issued:      issued,
+			Retired:     retired,
+			Supply:      supply,
+		})
+	})
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "supply history query")
+	}
+	var next uint64
+	if len(points) > limit {
+		next = start + buckets[limit]*interval
+		points = points[:limit]
+	}
+	return points, next, nil
+}
+
+// querySupplyHistory answers a query for the supply history of the
+// asset named in the path, given as {"start_height": <h>,
+// "end_height": <h>, "interval": <blocks>, "page_size": <n>,
+// "after": <cursor>}. The history is downsampled to a point for
+// each interval blocks in which the supply changed; by default,
+// every such block.
+func (app *ChainmintApplication) querySupplyHistory(ctx context.Context, data []byte) (interface{}, error) {
+	var in struct {
+		StartHeight uint64 `json:"start_height"`
+		EndHeight   uint64 `json:"end_height"`
+		Interval    uint64 `json:"interval"`
+		PageSize    int    `json:"page_size"`
+		After       string `json:"after"`
+	}
+	err := decodeQuery(data, &in)
+	if err != nil {
+		return nil, err
+	}
+	var assetID bc.AssetID
+	err = assetID.UnmarshalText([]byte(pathParam(ctx, "id")))
+	if err != nil {
+		return nil, errors.WithDetail(errBadQuery, "invalid asset id")
+	}
+	if in.EndHeight == 0 {
+		in.EndHeight = math.MaxInt64
+	}
+	if in.EndHeight > math.MaxInt64 || in.StartHeight > in.EndHeight {
+		return nil, errors.WithDetail(errBadQuery, "invalid height range")
+	}
+	if in.Interval == 0 {
+		in.Interval = 1
+	}
+	if in.Interval > math.MaxInt64 {
+		return nil, errors.WithDetail(errBadQuery, "invalid interval")
+	}
+	if in.After != "" {
+		after, err := strconv.ParseUint(in.After, 10, 64)
+		if err != nil || after < in.StartHeight || (after-in.StartHeight)%in.Interval != 0 {
+			return nil, errors.WithDetail(errBadQuery, "invalid after")
+		}
+		// Keep the buckets of later pages aligned with the first.
+		in.StartHeight = after
+	}
+	if in.PageSize <= 0 || in.PageSize > maxSupplyPoints {
+		in.PageSize = maxSupplyPoints
+	}
+
+	points, next, err := supplyHistory(ctx, app.backend.DB(), assetID, in.StartHeight, in.EndHeight, in.Interval, in.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	page := queryPage{Items: points, LastPage: next == 0}
+	if next != 0 {
+		page.Next = strconv.FormatUint(next, 10)
+	}
+	return page, nil
+}
+
+// assetSupply is the latest supply totals of an asset.
+type assetSupply struct {
+	assetID         bc.AssetID
+	issued, retired uint64
+}
+
+func latestSupply(ctx context.Context, db pg.DB) ([]*assetSupply, error) {
+	const q = `
+		SELECT DISTINCT ON (asset_id) asset_id, total_issued, total_retired
+		FROM asset_supply
+		ORDER BY asset_id, height DESC
+	`
+	var supplies []*assetSupply
+	err := pg.ForQueryRows(ctx, db, q, func(assetID bc.AssetID, issued, retired uint64) {
+		supplies = append(supplies, &assetSupply{assetID, issued, retired})
+	})
+	return supplies, errors.Wrap(err, "latest asset supply query")
+}
+
+func writeSupplyMetrics(w io.Writer, supplies []*assetSupply) {
+	families := []struct {
+		name, typ, help string
+		value           func(*assetSupply) uint64
+	}{
+		{"chainmint_asset_supply", "gauge", "Units of the asset in circulation.",
+			func(s *assetSupply) uint64 { return s.issued - s.retired }},
+		{"chainmint_asset_issued", "counter", "Units of the asset issued.",
+			func(s *assetSupply) uint64 { return s.issued }},
+		{"chainmint_asset_retired", "counter", "Units of the asset retired.",
+			func(s *assetSupply) uint64 { return s.retired }},
+	}
+	for _, f := range families {
+		fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.typ)
+		fmt.Fprintf(w, "# HELP %s %s\n", f.name, f.help)
+		sample := f.name
+		if f.typ == "counter" {
+			sample += "_total"
+		}
+		for _, s := range supplies {
+			fmt.Fprintf(w, "%s{asset_id=\"%x\"} %d\n", sample, s.assetID.Bytes(), f.value(s))
+		}
+	}
+	fmt.Fprint(w, "# EOF\n")
+}
@@ -0,0 +1,39 @@
+package app
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/chainmint/protocol/bc"
+)
+
+func TestWriteSupplyMetrics(t *testing.T) {
+	supplies := []*assetSupply{
+		{bc.AssetID(bc.NewHash([32]byte{1})), 100, 30},
+		{bc.AssetID(bc.NewHash([32]byte{2})), 5, 0},
+	}
+	var buf bytes.Buffer
+	writeSupplyMetrics(&buf, supplies)
+
+	const (
+		a1 = "0100000000000000000000000000000000000000000000000000000000000000"
+		a2 = "0200000000000000000000000000000000000000000000000000000000000000"
+	)
+	want := `# TYPE chainmint_asset_supply gauge
+# HELP chainmint_asset_supply Units of the asset in circulation.
+chainmint_asset_supply{asset_id="` + a1 + `"} 70
+chainmint_asset_supply{asset_id="` + a2 + `"} 5
+# TYPE chainmint_asset_issued counter
+# HELP chainmint_asset_issued Units of the asset issued.
+chainmint_asset_issued_total{asset_id="` + a1 + `"} 100
+chainmint_asset_issued_total{asset_id="` + a2 + `"} 5
+# TYPE chainmint_asset_retired counter
+# HELP chainmint_asset_retired Units of the asset retired.
+chainmint_asset_retired_total{asset_id="` + a1 + `"} 30
+chainmint_asset_retired_total{asset_id="` + a2 + `"} 0
+# EOF
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
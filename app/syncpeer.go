@@ -0,0 +1,159 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/log"
+	"github.com/chainmint/net/http/httpjson"
+)
+
+// syncChunkTries is how many times a chunk is fetched before the
+// snapshot it belongs to is given up on.
+const syncChunkTries = 3
+
+// ErrNoSyncSnapshot is returned by SyncFrom when the peer serves no
+// snapshot this node can restore.
+var ErrNoSyncSnapshot = errors.New("no state sync snapshot restored")
+
+// StateSyncHandler returns a handler serving this node's state sync
+// snapshots to peers calling SyncFrom:
+//
+//	/state-sync/snapshots  the snapshots, as ListSnapshots returns them
+//	/state-sync/chunk      the chunk given by the query parameters
+//	                       height, format and chunk
+func (app *ChainmintApplication) StateSyncHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/state-sync/snapshots", func(w http.ResponseWriter, req *http.Request) {
+		httpjson.Write(req.Context(), w, http.StatusOK, httpjson.Array(app.ListSnapshots()))
+	})
+	mux.HandleFunc("/state-sync/chunk", func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		height, err1 := strconv.ParseUint(q.Get("height"), 10, 64)
+		format, err2 := strconv.ParseUint(q.Get("format"), 10, 32)
+		chunk, err3 := strconv.ParseUint(q.Get("chunk"), 10, 32)
+		if err1 != nil || err2 != nil || err3 != nil {
+			http.Error(w, "bad chunk request", http.StatusBadRequest)
+			return
+		}
+		b := app.LoadSnapshotChunk(height, uint32(format), uint32(chunk))
+		if b == nil {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(b)
+	})
+	return mux
+}
+
+// SyncFrom restores the state of this node, which must have no
+// blocks yet, from a snapshot served by StateSyncHandler at the base
+// URL peer, in place of replaying the chain from its initial block.
+// It must be called after Init and before the ABCI server starts.
+//
+// abci 0.5 has no light client to vouch for a snapshot, so SyncFrom
+// requires a checkpoint, CHECKPOINT_HEIGHT and the rest, which the
+// snapshot must match. The strategy state is checked only against
+// CHECKPOINT_APP_HASH, if it is set and the chain commits to its app
+// state root.
+func (app *ChainmintApplication) SyncFrom(ctx context.Context, peer string) error {
+	if app.checkpoint == nil {
+		return errors.New("state sync requires a checkpoint; set CHECKPOINT_HEIGHT")
+	}
+	var snapshots []*SyncSnapshot
+	err := getSyncPeer(ctx, peer, "/state-sync/snapshots", nil, func(b []byte) error {
+		return json.Unmarshal(b, &snapshots)
+	})
+	if err != nil {
+		return errors.Wrap(err, "listing peer snapshots")
+	}
+	for _, s := range snapshots {
+		switch app.OfferSnapshot(s, app.checkpoint.AppHash) {
+		case OfferAbort:
+			log.Printkv(ctx, "at", "state sync skipped", "reason", "node has blocks")
+			return nil
+		case OfferAccept:
+		default:
+			continue
+		}
+		restored, err := app.fetchSnapshot(ctx, peer, s)
+		if err != nil {
+			return err
+		}
+		if restored {
+			return nil
+		}
+	}
+	return errors.WithDetailf(ErrNoSyncSnapshot, "from %s", peer)
+}
+
+// fetchSnapshot fetches the chunks of s, accepted by OfferSnapshot,
+// from peer and applies them. It reports whether s was restored.
+func (app *ChainmintApplication) fetchSnapshot(ctx context.Context, peer string, s *SyncSnapshot) (bool, error) {
+	for i := uint32(0); i < s.Chunks; i++ {
+		for try := 1; ; try++ {
+			params := url.Values{
+				"height": {strconv.FormatUint(s.Height, 10)},
+				"format": {strconv.FormatUint(uint64(s.Format), 10)},
+				"chunk":  {strconv.FormatUint(uint64(i), 10)},
+			}
+			var chunk []byte
+			err := getSyncPeer(ctx, peer, "/state-sync/chunk", params, func(b []byte) error {
+				chunk = b
+				return nil
+			})
+			if err != nil {
+				return false, errors.Wrapf(err, "fetching chunk %d of snapshot %d", i, s.Height)
+			}
+			result, _ := app.ApplySnapshotChunk(i, chunk, peer)
+			switch {
+			case result == ChunkAccept:
+			case result == ChunkRetry && try < syncChunkTries:
+				continue
+			case result == ChunkAbort:
+				return false, errors.New("state sync aborted")
+			default:
+				log.Printkv(ctx, "at", "state sync snapshot rejected", "height", s.Height, "chunk", i)
+				app.sync.mu.Lock()
+				app.sync.restoring = nil
+				app.sync.mu.Unlock()
+				return false, nil
+			}
+			break
+		}
+	}
+	return true, nil
+}
+
+// getSyncPeer gets path, with query params, from the state sync
+// handler at peer, and passes the response body to read.
+func getSyncPeer(ctx context.Context, peer, path string, params url.Values, read func([]byte) error) error {
+	u := peer + path
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.Wrap(fmt.Errorf("%s: %s", u, resp.Status))
+	}
+	return read(b)
+}
@@ -0,0 +1,43 @@
+package app
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStateSyncChunk(t *testing.T) {
+	app := new(ChainmintApplication)
+	served := new(servedSnapshot)
+	served.snapshot, served.chunks = chunkSnapshot(7, []byte("snapshot"))
+	app.sync.serving = map[uint64]*servedSnapshot{7: served}
+	h := app.StateSyncHandler()
+
+	cases := []struct {
+		query string
+		code  int
+	}{
+		{"height=7&format=1&chunk=0", 200},
+		{"height=7&format=1&chunk=1", 404},
+		{"height=8&format=1&chunk=0", 404},
+		{"height=7&format=2&chunk=0", 404},
+		{"height=x&format=1&chunk=0", 400},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/state-sync/chunk?"+c.query, nil))
+		if rec.Code != c.code {
+			t.Errorf("chunk?%s: status = %d want %d", c.query, rec.Code, c.code)
+		}
+		if c.code == 200 && rec.Body.String() != "snapshot" {
+			t.Errorf("chunk?%s: body = %q want %q", c.query, rec.Body.String(), "snapshot")
+		}
+	}
+}
+
+func TestSyncFromNeedsCheckpoint(t *testing.T) {
+	app := new(ChainmintApplication)
+	if err := app.SyncFrom(context.Background(), "http://127.0.0.1:0"); err == nil {
+		t.Error("SyncFrom without a checkpoint succeeded")
+	}
+}
@@ -0,0 +1,225 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/chainmint/core"
+	"github.com/chainmint/env"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/log"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	abciTypes "github.com/tendermint/abci/types"
+)
+
+// txJournalPath names a file in which to journal the txs CheckTx
+// accepted that no block has yet included, so that they are
+// broadcast to Tendermint again after a restart. An empty path
+// disables the journal.
+var txJournalPath = env.String("TX_JOURNAL", "")
+
+// reinjectRetry is how long to wait before retrying to broadcast
+// txs again, while Tendermint is not reachable or the node not yet
+// reconciled.
+const reinjectRetry = 2 * time.Second
+
+// txJournal is an append-only journal of the app's pending tx set,
+// the txs in Tendermint's mempool. Each line is "+" and the text
+// encoding of a tx accepted by CheckTx, or "-" and the hex ID of a
+// tx that left the mempool, having been included in a block or
+// dropped on recheck. Writes are not synced, so a crash may lose the
+// last of them; a tx lost that way is dropped with the mempool, as
+// it would be without the journal, and a removal lost that way only
+// has the tx rejected when it is broadcast again.
+type txJournal struct {
+	mu      sync.Mutex
+	path    string
+	f       *os.File
+	w       *bufio.Writer
+	txs     map[bc.Hash][]byte
+	order   []bc.Hash // by acceptance, including removed txs
+	removed int       // lines that no longer describe a pending tx
+}
+
+// openTxJournal opens the journal at path, creating it if need be,
+// and compacts it to the txs still pending.
+func openTxJournal(path string) (*txJournal, error) {
+	j := &txJournal{path: path, txs: make(map[bc.Hash][]byte)}
+	f, err := os.Open(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "opening tx journal")
+	}
+	if err == nil {
+		s := bufio.NewScanner(f)
+		s.Buffer(nil, 64<<20)
+		for s.Scan() {
+			j.replay(s.Bytes())
+		}
+		f.Close()
+		if err := s.Err(); err != nil {
+			return nil, errors.Wrap(err, "reading tx journal")
+		}
+	}
+	err = j.compact()
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// replay applies a line read from the journal. Lines that cannot be
+// parsed, as when the last write was cut short, are skipped.
+func (j *txJournal) replay(line []byte) {
+	if len(line) < 2 {
+		return
+	}
+	switch line[0] {
+	case '+':
+		tx, err := decodeTx(line[1:])
+		if err != nil {
+			return
+		}
+		if _, ok := j.txs[tx.ID]; !ok {
+			j.order = append(j.order, tx.ID)
+		}
+		j.txs[tx.ID] = append([]byte(nil), line[1:]...)
+	case '-':
+		var id bc.Hash
+		if id.UnmarshalText(line[1:]) == nil {
+			delete(j.txs, id)
+		}
+	}
+}
+
+// compact rewrites the journal with only the pending txs, in the
+// order they were accepted.
+func (j *txJournal) compact() error {
+	tmp := j.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return errors.Wrap(err, "creating tx journal")
+	}
+	w := bufio.NewWriter(f)
+	var order []bc.Hash
+	for _, id := range j.order {
+		tx, ok := j.txs[id]
+		if !ok {
+			continue
+		}
+		order = append(order, id)
+		w.WriteByte('+')
+		w.Write(tx)
+		w.WriteByte('\n')
+	}
+	err = w.Flush()
+	if err == nil {
+		err = os.Rename(tmp, j.path)
+	}
+	if err != nil {
+		f.Close()
+		return errors.Wrap(err, "writing tx journal")
+	}
+	if j.f != nil {
+		j.f.Close()
+	}
+	j.f, j.w = f, bufio.NewWriter(f)
+	j.order, j.removed = order, 0
+	return nil
+}
+
+// add journals tx, accepted by CheckTx.
+func (j *txJournal) add(id bc.Hash, tx []byte) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, ok := j.txs[id]; ok {
+		return
+	}
+	j.txs[id] = append([]byte(nil), tx...)
+	j.order = append(j.order, id)
+	j.w.WriteByte('+')
+	j.w.Write(tx)
+	j.w.WriteByte('\n')
+	j.flush()
+}
+
+// remove journals that the tx with the given ID left the mempool.
+func (j *txJournal) remove(id bc.Hash) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, ok := j.txs[id]; !ok {
+		return
+	}
+	delete(j.txs, id)
+	j.removed += 2 // its addition and its removal
+	j.w.WriteString("-" + hex.EncodeToString(id.Bytes()) + "\n")
+	j.flush()
+	if j.removed > 1000 && j.removed > 2*len(j.txs) {
+		err := j.compact()
+		if err != nil {
+			log.Error(context.Background(), err)
+		}
+	}
+}
+
+func (j *txJournal) flush() {
+	err := j.w.Flush()
+	if err != nil {
+		log.Error(context.Background(), errors.Wrap(err, "writing tx journal"))
+	}
+}
+
+// pending returns the journaled txs, in the order they were
+// accepted, so that a tx comes after any pending tx it spends.
+func (j *txJournal) pending() [][]byte {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var txs [][]byte
+	for _, id := range j.order {
+		if tx, ok := j.txs[id]; ok {
+			txs = append(txs, tx)
+		}
+	}
+	return txs
+}
+
+// journalCheckTx records in the journal the result of CheckTx for
+// tx. A tx rejected for an internal error, such as the node not
+// being reconciled, may yet be valid, so it is kept.
+func (app *ChainmintApplication) journalCheckTx(tx *legacy.Tx, txBytes []byte, res abciTypes.Result) {
+	if app.txJournal == nil {
+		return
+	}
+	if res.IsOK() {
+		app.txJournal.add(tx.ID, txBytes)
+	} else if res.Code != abciTypes.CodeType_InternalError {
+		app.txJournal.remove(tx.ID)
+	}
+}
+
+// rebroadcast broadcasts txs to Tendermint, whose mempool lost them,
+// once it is reachable and the node reconciled: the txs journaled
+// before the node restarted, or those a block was too full to take.
+// A tx CheckTx now rejects leaves the journal as it is rejected.
+func (app *ChainmintApplication) rebroadcast(ctx context.Context, txs [][]byte) {
+	for len(txs) > 0 {
+		if app.backend.Reconciled() {
+			err := app.backend.BroadcastTx(txs[0])
+			if err == nil || errors.Root(err) == core.ErrTxRejected {
+				txs = txs[1:]
+				continue
+			}
+			log.Error(ctx, err, "retrying")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reinjectRetry):
+		}
+	}
+	log.Printkv(ctx, "at", "rebroadcast txs")
+}
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+func TestTxJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "txjournal_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "journal")
+
+	var txs []*legacy.Tx
+	var texts [][]byte
+	for i := 0; i < 3; i++ {
+		tx := legacy.NewTx(legacy.TxData{Version: 1, MinTime: uint64(i)})
+		text, err := tx.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		txs, texts = append(txs, tx), append(texts, text)
+	}
+
+	j, err := openTxJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, tx := range txs {
+		j.add(tx.ID, texts[i])
+	}
+	j.add(txs[0].ID, texts[0]) // already journaled
+	j.remove(txs[1].ID)
+	j.f.Close()
+
+	// A write cut short by a crash.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(append([]byte{'+'}, texts[1][:len(texts[1])/2]...))
+	f.Close()
+
+	j, err = openTxJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.f.Close()
+	want := [][]byte{texts[0], texts[2]}
+	if got := j.pending(); !reflect.DeepEqual(got, want) {
+		t.Errorf("pending = %q, want %q", got, want)
+	}
+	if j.removed != 0 {
+		t.Errorf("removed = %d after reopening, want 0", j.removed)
+	}
+}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"context"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/log"
+	"github.com/chainmint/protocol/upgrade"
+)
+
+// Upgrades are scheduled on a chain only by its genesis and by
+// accepted proposals, so that every node schedules the same ones.
+
+// checkActivations returns an error if list, from a genesis
+// document, schedules an upgrade without a name or height, or
+// schedules one twice.
+func checkActivations(list []upgrade.Activation) error {
+	seen := make(map[string]bool)
+	for _, a := range list {
+		if a.Name == "" || a.Height == 0 {
+			return errors.WithDetailf(ErrBadGenesis, "upgrade %q at height %d: want a name and a nonzero height", a.Name, a.Height)
+		}
+		if seen[a.Name] {
+			return errors.WithDetailf(ErrBadGenesis, "upgrade %q scheduled twice", a.Name)
+		}
+		seen[a.Name] = true
+	}
+	return nil
+}
+
+// scheduleUpgrade schedules the upgrade name on the chain at height,
+// warning if this release does not have it.
+func (app *ChainmintApplication) scheduleUpgrade(ctx context.Context, name string, height uint64) error {
+	err := app.backend.Chain().Upgrades.Add(name, height)
+	if err != nil {
+		return err
+	}
+	if !upgrade.Known(name) {
+		log.Printkv(ctx, "at", "unsupported upgrade scheduled", "name", name, "height", height,
+			"detail", "this node will halt below the upgrade height unless upgraded")
+	}
+	return nil
+}
+
+// checkUpgrades halts the node if the next chain block falls under an
+// upgrade this release does not have, so that it neither builds nor
+// accepts blocks by rules it no longer shares with the network.
+func (app *ChainmintApplication) checkUpgrades(ctx context.Context) {
+	c := app.backend.Chain()
+	_, err := c.Rules(c.Height() + 1)
+	if err != nil {
+		log.Fatalkv(ctx, "at", "halting for upgrade", log.KeyError, err)
+	}
+}
+
+// queryUpgrades answers a query for the upgrades scheduled on the
+// chain, and whether this node supports each.
+func (app *ChainmintApplication) queryUpgrades(ctx context.Context, data []byte) (interface{}, error) {
+	type scheduled struct {
+		upgrade.Activation
+		Supported bool `json:"supported"`
+	}
+	list := []scheduled{}
+	for _, a := range app.backend.Chain().Upgrades.List() {
+		list = append(list, scheduled{a, upgrade.Known(a.Name)})
+	}
+	return list, nil
+}
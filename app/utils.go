@@ -2,19 +2,17 @@ package app
 
 import (
 	//"bytes"
-	"encoding/json"
+	"fmt"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/errors/errcode"
 	//"github.com/chainmint/encoding/blockchain"
 	"github.com/chainmint/protocol/bc/legacy"
 
 	abciTypes "github.com/tendermint/abci/types"
-)
 
-// format of query data
-type jsonRequest struct {
-	Method string          `json:"method"`
-	ID     json.RawMessage `json:"id,omitempty"`
-	Params []interface{}   `json:"params,omitempty"`
-}
+	cmtTypes "github.com/chainmint/types"
+)
 
 // decode to chain's transaction
 func decodeTx(txBytes []byte) (*legacy.Tx, error) {
@@ -26,6 +24,47 @@ func decodeTx(txBytes []byte) (*legacy.Tx, error) {
 	return &tx, nil
 }
 
+// rejectionResult converts the error explaining why a transaction
+// was rejected into the result reported to Tendermint. The error
+// must be deterministic, since the result is part of consensus.
+func rejectionResult(err error) abciTypes.Result {
+	return abciTypes.NewError(abciTypes.CodeType_Unauthorized, resultLog(err, errcode.InvalidTx))
+}
+
+// txErrorCodes are the codes, from package errcode, of the errors
+// for which the app rejects a transaction, for errors that carry no
+// code of their own.
+var txErrorCodes = map[error]string{
+	ErrTxExpired:         errcode.Expired,
+	ErrFeeTooLow:         errcode.FeeTooLow,
+	ErrMemoTooLarge:      errcode.BadMemo,
+	ErrMemoUnderpaid:     errcode.BadMemo,
+	ErrMemoSchema:        errcode.BadMemo,
+	ErrBadRegistration:   errcode.BadStakingAction,
+	ErrBondTooLow:        errcode.BadStakingAction,
+	ErrTooManyValidators: errcode.BadStakingAction,
+	ErrCommissionTooHigh: errcode.BadStakingAction,
+	ErrUnknownValidator:  errcode.BadStakingAction,
+	ErrNotUnbonding:      errcode.BadStakingAction,
+	ErrBadProposal:       errcode.BadStakingAction,
+	ErrBadVote:           errcode.BadStakingAction,
+	ErrUnknownProposal:   errcode.BadStakingAction,
+	ErrVotingClosed:      errcode.BadStakingAction,
+}
+
+// resultLog is the log of the result rejecting a transaction for
+// err: its code, then its root and detail. The code is the one err
+// carries, or else the one in txErrorCodes for its root, or else
+// code. Clients read the code back with errcode.Parse.
+func resultLog(err error, code string) string {
+	if c := errors.Code(err); c != "" {
+		code = c
+	} else if c, ok := txErrorCodes[errors.Root(err)]; ok {
+		code = c
+	}
+	return errcode.Format(code, fmt.Sprintf("%s: %s", errors.Root(err), errors.Detail(err)))
+}
+
 //-------------------------------------------------------
 // convenience methods for validators
 
@@ -45,17 +84,39 @@ func (app *ChainmintApplication) SetValidators(validators []*abciTypes.Validator
 	}
 }
 
+// SetCommission sets a validator's commission rate on the strategy
+func (app *ChainmintApplication) SetCommission(validator []byte, rate uint64) {
+	if app.strategy != nil {
+		app.strategy.SetCommission(validator, rate)
+	}
+}
+
+// SetDelegations sets the delegated stake on the strategy
+func (app *ChainmintApplication) SetDelegations(delegations []cmtTypes.Delegation) {
+	if app.strategy != nil {
+		app.strategy.SetDelegations(delegations)
+	}
+}
+
 // GetUpdatedValidators returns an updated validator set from the strategy
 func (app *ChainmintApplication) GetUpdatedValidators() abciTypes.ResponseEndBlock {
 	if app.strategy != nil {
-		return abciTypes.ResponseEndBlock{Diffs: app.strategy.GetUpdatedValidators()}
+		return abciTypes.ResponseEndBlock{Diffs: app.strategy.ValidatorUpdates()}
 	}
 	return abciTypes.ResponseEndBlock{}
 }
 
-// CollectTx invokes CollectTx on the strategy
-func (app *ChainmintApplication) CollectTx(tx *legacy.Tx) {
+// CollectFee invokes CollectFee on the strategy
+func (app *ChainmintApplication) CollectFee(tx *legacy.Tx) {
+	if app.strategy != nil {
+		app.strategy.CollectFee(tx)
+	}
+}
+
+// Distribute pays out the collected fees according to the strategy
+func (app *ChainmintApplication) Distribute(block cmtTypes.BlockInfo) []cmtTypes.Reward {
 	if app.strategy != nil {
-		app.strategy.CollectTx(tx)
+		return app.strategy.Distribute(block)
 	}
+	return nil
 }
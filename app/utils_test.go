@@ -0,0 +1,31 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/errors/errcode"
+)
+
+func TestResultLog(t *testing.T) {
+	other := errors.New("other")
+	cases := []struct {
+		err  error
+		code string
+	}{
+		{errors.WithDetail(ErrTxExpired, "at 5"), errcode.Expired},
+		{errors.WithCode(errors.Wrap(ErrTxExpired), errcode.DoubleSpend), errcode.DoubleSpend},
+		{other, errcode.InvalidTx},
+	}
+	for _, c := range cases {
+		code, _ := errcode.Parse(rejectionResult(c.err).Log)
+		if code != c.code {
+			t.Errorf("rejectionResult(%v) code = %q want %q", c.err, code, c.code)
+		}
+	}
+
+	code, msg := errcode.Parse(conflictResult(errors.WithDetail(ErrConflict, "tx 01")).Log)
+	if code != errcode.Conflict || msg != ErrConflict.Error()+": tx 01" {
+		t.Errorf("conflictResult log = %q, %q want %q, %q", code, msg, errcode.Conflict, ErrConflict.Error()+": tx 01")
+	}
+}
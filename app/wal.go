@@ -0,0 +1,115 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/chainmint/database/pg"
+	"github.com/chainmint/database/sql"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/log"
+)
+
+// commitRecord is the write-ahead record of a Tendermint block: what
+// BeginBlock and DeliverTx were given, which is all that is needed
+// to process it again. Commit saves it before making the chain block
+// and deletes it once the strategy state after the block is saved,
+// so a record found at startup belongs to a commit that was cut
+// short; see recoverCommit.
+type commitRecord struct {
+	Height uint64 `json:"height"`
+	Time   uint64 `json:"time"`
+
+	// ChainHeight is the height of the chain before the commit.
+	ChainHeight uint64 `json:"chain_height"`
+
+	// Txs are the txs DeliverTx accepted, in order.
+	Txs [][]byte `json:"txs"`
+}
+
+func saveCommitRecord(ctx context.Context, db pg.DB, rec *commitRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return errors.Wrap(err, "marshaling commit record")
+	}
+	const q = `
+		INSERT INTO commit_wal (height, data) VALUES ($1, $2)
+		ON CONFLICT (singleton) DO UPDATE SET height = excluded.height, data = excluded.data
+	`
+	_, err = db.Exec(ctx, q, rec.Height, data)
+	return errors.Wrap(err, "commit_wal insert query")
+}
+
+// loadCommitRecord returns the saved commit record, or nil if there
+// is none.
+func loadCommitRecord(ctx context.Context, db pg.DB) (*commitRecord, error) {
+	var data []byte
+	err := db.QueryRow(ctx, `SELECT data FROM commit_wal`).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "commit_wal select query")
+	}
+	rec := new(commitRecord)
+	err = json.Unmarshal(data, rec)
+	return rec, errors.Wrap(err, "unmarshaling commit record")
+}
+
+func deleteCommitRecord(ctx context.Context, db pg.DB) error {
+	_, err := db.Exec(ctx, `DELETE FROM commit_wal`)
+	return errors.Wrap(err, "commit_wal delete query")
+}
+
+// recoverCommit finishes or undoes a commit cut short by a crash,
+// so that the chain and the strategy state agree again. It must be
+// called after the strategy state is restored.
+//
+// If the strategy state was saved, the commit completed. If the
+// chain block was saved, or the block had no txs to make one from,
+// the block is processed again from its record, without submitting
+// its txs, and the strategy state after it is saved. Otherwise the
+// partial block is discarded: the pending chain block is deleted, so
+// that it is made afresh when Tendermint replays the block.
+func (app *ChainmintApplication) recoverCommit(ctx context.Context) error {
+	db := app.backend.DB()
+	rec, err := loadCommitRecord(ctx, db)
+	if err != nil || rec == nil {
+		return err
+	}
+	switch {
+	case app.height >= rec.Height:
+		// Nothing to do.
+	case app.backend.Chain().Height() == rec.ChainHeight && len(rec.Txs) > 0:
+		_, err = db.Exec(ctx, `DELETE FROM generator_pending_block WHERE height > $1`, rec.ChainHeight)
+		if err != nil {
+			return errors.Wrap(err, "deleting pending block")
+		}
+		log.Printkv(ctx, "at", "discarded partial commit", "height", rec.Height)
+	default:
+		err = app.reapplyCommit(ctx, rec)
+		if err != nil {
+			return errors.Wrapf(err, "reapplying commit of block %d", rec.Height)
+		}
+		log.Printkv(ctx, "at", "reapplied partial commit", "height", rec.Height, "txs", len(rec.Txs))
+	}
+	return deleteCommitRecord(ctx, db)
+}
+
+// reapplyCommit processes the block recorded in rec as BeginBlock,
+// DeliverTx and EndBlock did, and saves the strategy state after it.
+func (app *ChainmintApplication) reapplyCommit(ctx context.Context, rec *commitRecord) error {
+	app.height, app.BlockTime = rec.Height, rec.Time
+	for i, b := range rec.Txs {
+		tx, err := decodeTx(b)
+		if err != nil {
+			return errors.Wrapf(err, "decoding tx %d", i)
+		}
+		res := app.deliverTx(ctx, tx, false)
+		if res.IsErr() {
+			return errors.Wrapf(errors.New(res.Log), "tx %d no longer applies", i)
+		}
+	}
+	app.EndBlock(rec.Height)
+	_, err := app.persistStrategyState(ctx, app.backend.DB())
+	return err
+}
@@ -0,0 +1,229 @@
+package chain
+
+import (
+	"context"
+	stdjson "encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/tendermint/abci/server"
+
+	"github.com/chainmint/app"
+	"github.com/chainmint/core"
+	"github.com/chainmint/database/sql"
+	"github.com/chainmint/env"
+	"github.com/chainmint/errors"
+	chainlog "github.com/chainmint/log"
+)
+
+// chainsConfig is the path of a JSON file describing additional
+// chains to host in this process, alongside the default chain
+// configured by DATABASE_URL.
+var chainsConfig = env.String("CHAINS_CONFIG", "")
+
+var (
+	validChainID       = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+	errBadChainsConfig = errors.New("invalid chains config")
+)
+
+// chainConfig describes one additional chain. Each chain has its
+// own database, ABCI listener and Tendermint node; its Core API is
+// served on the shared HTTP listener under /chains/<id>/.
+//
+// A chain shares none of the default chain's directories, files or
+// block processors: SNAPSHOT_DIR, UTXO_COLD_DIR, BLOCK_PROCESSORS,
+// TX_JOURNAL, GENESIS_FILE and IMPORT_STAKING_STATE configure the
+// default chain alone, and each additional chain sets its own here,
+// which no other chain may use. Left empty, they keep snapshots in
+// the database and the state tree in memory, run no processors,
+// journal no txs, and start the chain from no genesis document or
+// staking state.
+type chainConfig struct {
+	ID             string `json:"id"`
+	DatabaseURL    string `json:"database_url"`
+	ABCIAddr       string `json:"abci_addr"`
+	ABCITransport  string `json:"abci_transport"`
+	TendermintAddr string `json:"tendermint_addr"`
+
+	SnapshotDir string   `json:"snapshot_dir"`
+	ColdDir     string   `json:"utxo_cold_dir"`
+	Processors  []string `json:"block_processors"`
+
+	TxJournal          string `json:"tx_journal"`
+	GenesisFile        string `json:"genesis_file"`
+	ImportStakingState string `json:"import_staking_state"`
+}
+
+// loadChainsConfig reads and validates the chain definitions
+// in the file at path.
+func loadChainsConfig(path string) ([]*chainConfig, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading chains config")
+	}
+	var chains []*chainConfig
+	err = stdjson.Unmarshal(b, &chains)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing chains config")
+	}
+
+	seenIDs := make(map[string]bool)
+	seenAddrs := make(map[string]bool)
+	// dirs holds the directories in use, by what uses them. The cold
+	// tier's directory is cleared at startup, so no two may overlap.
+	dirs := make(map[string]string)
+	useDir := func(dir, user string) error {
+		if dir == "" {
+			return nil
+		}
+		dir = filepath.Clean(dir)
+		for d, u := range dirs {
+			if dirsOverlap(d, dir) {
+				return errors.WithDetailf(errBadChainsConfig, "%s %s overlaps %s %s", user, dir, u, d)
+			}
+		}
+		dirs[dir] = user
+		return nil
+	}
+	// files holds the files in use, by what uses them.
+	files := make(map[string]string)
+	useFile := func(file, user string) error {
+		if file == "" {
+			return nil
+		}
+		file = filepath.Clean(file)
+		if u, ok := files[file]; ok {
+			return errors.WithDetailf(errBadChainsConfig, "%s %s is already used as %s", user, file, u)
+		}
+		files[file] = user
+		return nil
+	}
+	err = useDir(*snapDir, "SNAPSHOT_DIR")
+	if err != nil {
+		return nil, err
+	}
+	err = useDir(*coldDir, "UTXO_COLD_DIR")
+	if err != nil {
+		return nil, err
+	}
+	defaults := app.ConfiguredFiles()
+	err = useFile(defaults.TxJournal, "TX_JOURNAL")
+	if err != nil {
+		return nil, err
+	}
+	err = useFile(defaults.Genesis, "GENESIS_FILE")
+	if err != nil {
+		return nil, err
+	}
+	err = useFile(defaults.ImportStakingState, "IMPORT_STAKING_STATE")
+	if err != nil {
+		return nil, err
+	}
+	for i, c := range chains {
+		if !validChainID.MatchString(c.ID) {
+			return nil, errors.WithDetailf(errBadChainsConfig, "chain %d: invalid id %q", i, c.ID)
+		}
+		if seenIDs[c.ID] {
+			return nil, errors.WithDetailf(errBadChainsConfig, "duplicate chain id %q", c.ID)
+		}
+		seenIDs[c.ID] = true
+		if c.DatabaseURL == "" || c.ABCIAddr == "" || c.TendermintAddr == "" {
+			return nil, errors.WithDetailf(errBadChainsConfig, "chain %q: database_url, abci_addr and tendermint_addr are required", c.ID)
+		}
+		if seenAddrs[c.ABCIAddr] {
+			return nil, errors.WithDetailf(errBadChainsConfig, "chain %q: abci_addr %s already in use", c.ID, c.ABCIAddr)
+		}
+		seenAddrs[c.ABCIAddr] = true
+		if c.ABCITransport == "" {
+			c.ABCITransport = "socket"
+		}
+		err = useDir(c.SnapshotDir, "chain "+c.ID+" snapshot_dir")
+		if err != nil {
+			return nil, err
+		}
+		err = useDir(c.ColdDir, "chain "+c.ID+" utxo_cold_dir")
+		if err != nil {
+			return nil, err
+		}
+		err = useFile(c.TxJournal, "chain "+c.ID+" tx_journal")
+		if err != nil {
+			return nil, err
+		}
+		err = useFile(c.GenesisFile, "chain "+c.ID+" genesis_file")
+		if err != nil {
+			return nil, err
+		}
+		err = useFile(c.ImportStakingState, "chain "+c.ID+" import_staking_state")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return chains, nil
+}
+
+// dirsOverlap reports whether the clean paths a and b are the same
+// directory or one is inside the other.
+func dirsOverlap(a, b string) bool {
+	within := func(dir, parent string) bool {
+		return dir == parent || strings.HasPrefix(dir, strings.TrimSuffix(parent, string(filepath.Separator))+string(filepath.Separator))
+	}
+	return within(a, b) || within(b, a)
+}
+
+// launchChains starts every chain listed in CHAINS_CONFIG and
+// mounts its Core API on mux.
+func launchChains(ctx context.Context, mux *http.ServeMux, processID string) {
+	if *chainsConfig == "" {
+		return
+	}
+	chains, err := loadChainsConfig(*chainsConfig)
+	if err != nil {
+		chainlog.Fatalkv(ctx, chainlog.KeyError, err)
+	}
+	for _, c := range chains {
+		err = launchChain(ctx, mux, processID, c)
+		if err != nil {
+			chainlog.Fatalkv(ctx, chainlog.KeyError, err, "chain", c.ID)
+		}
+	}
+}
+
+func launchChain(ctx context.Context, mux *http.ServeMux, processID string, c *chainConfig) error {
+	db, err := sql.Open("hapg", c.DatabaseURL)
+	if err != nil {
+		return errors.Wrap(err, "opening database")
+	}
+	db.SetMaxOpenConns(*maxDBConns)
+	db.SetMaxIdleConns(*maxDBConns)
+
+	local := chainLocal{snapshotDir: c.SnapshotDir, coldDir: c.ColdDir, processors: c.Processors}
+	api := launchConfiguredCore(ctx, db, c.DatabaseURL, processID, local, nil, nil,
+		core.UseTLS(nil),
+		core.TendermintAddr(c.TendermintAddr),
+	)
+
+	prefix := "/chains/" + c.ID
+	mux.Handle(prefix+"/", http.StripPrefix(prefix, api))
+
+	chainApp := app.NewChainmintApplication(nil)
+	chainApp.UseFiles(app.Files{
+		TxJournal:          c.TxJournal,
+		Genesis:            c.GenesisFile,
+		ImportStakingState: c.ImportStakingState,
+	})
+	chainApp.Init(api)
+
+	srv, err := server.NewServer(c.ABCIAddr, c.ABCITransport, chainApp)
+	if err != nil {
+		return errors.Wrap(err, "creating abci server")
+	}
+	_, err = srv.Start()
+	if err != nil {
+		return errors.Wrap(err, "starting abci server")
+	}
+	chainlog.Printkv(ctx, "at", "chain online", "chain", c.ID, "abci", c.ABCIAddr, "path", prefix)
+	return nil
+}
@@ -4,6 +4,7 @@ package chain
 import (
 	"context"
 	"crypto/tls"
+	"encoding/hex"
 	"expvar"
 	"flag"
 	"fmt"
@@ -22,16 +23,21 @@ import (
 	"github.com/kr/secureheader"
 
 	"github.com/chainmint/core"
+	"github.com/chainmint/core/anomaly"
+	"github.com/chainmint/core/blockproc"
 //	"github.com/chainmint/core/accesstoken"
-	//"github.com/chainmint/core/blocksigner"
+	"github.com/chainmint/core/blocksigner"
 	"github.com/chainmint/core/config"
+	"github.com/chainmint/core/kvdb"
 	//"github.com/chainmint/core/generator"
-//	"github.com/chainmint/core/migrate"
+	"github.com/chainmint/core/migrate"
 	"github.com/chainmint/core/rpc"
 	"github.com/chainmint/core/txdb"
+	"github.com/chainmint/core/txsigner"
 	"github.com/chainmint/crypto/ed25519"
 	//"github.com/chainmint/database/pg"
 	//"github.com/chainmint/database/raft"
+	"github.com/chainmint/database/kv"
 	"github.com/chainmint/database/sql"
 	"github.com/chainmint/encoding/json"
 	"github.com/chainmint/env"
@@ -40,12 +46,16 @@ import (
 	chainlog "github.com/chainmint/log"
 	"github.com/chainmint/log/rotation"
 	"github.com/chainmint/log/splunk"
+	"github.com/chainmint/net/http/debug"
 	//"github.com/chainmint/net/http/limit"
 	"github.com/chainmint/net/http/reqid"
 	"github.com/chainmint/protocol"
 	"github.com/chainmint/protocol/bc"
 	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/patricia"
 	"github.com/chainmint/app"
+	"github.com/chainmint/strategies"
+	"github.com/chainmint/trace"
 	"github.com/chainmint/core/generator"
 )
 
@@ -58,6 +68,20 @@ var (
 	// config vars
 	rootCAs       = env.String("ROOT_CA_CERTS", "") // file path
 	listenAddr    = env.String("LISTEN", ":1999")
+	grpcAddr      = env.String("GRPC_LISTEN", "") // empty disables the gRPC API
+	peerAddr      = env.String("PEER_LISTEN", "")    // empty serves inter-node RPC on LISTEN, unauthenticated
+	peerCert      = env.String("PEER_TLS_CERT", "")  // file path
+	peerKey       = env.String("PEER_TLS_KEY", "")   // file path
+	peerCAs       = env.String("PEER_TLS_CA", "")    // file path
+	peerAllowlist = env.String("PEER_ALLOWLIST", "") // file path
+	peerReload    = env.Duration("PEER_TLS_RELOAD_PERIOD", time.Minute)
+	txSignerURL   = env.String("TX_SIGNER_URL", "")     // empty disables /sign-transaction
+	txSignerCert  = env.String("TX_SIGNER_TLS_CERT", "") // file path
+	txSignerKey   = env.String("TX_SIGNER_TLS_KEY", "")  // file path
+	txSignerCA    = env.String("TX_SIGNER_TLS_CA", "")   // file path
+	blockPub      = env.String("BLOCK_SIGNER_PUB", "")  // hex public key this node signs blocks with; empty disables block signing
+	blockHSMURL   = env.String("BLOCK_HSM_URL", "")     // HSM holding the key of BLOCK_SIGNER_PUB
+	signStateFile = env.String("SIGN_STATE_FILE", "")   // file path recording the last block signed; required with BLOCK_SIGNER_PUB
 	//dbURL         = env.String("DATABASE_URL", "postgres:///core?sslmode=disable")
 	dbURL         = env.String("DATABASE_URL", "user=gavin password=123456 dbname=core sslmode=disable")
 	splunkAddr    = os.Getenv("SPLUNKADDR")
@@ -65,13 +89,60 @@ var (
 	logSize       = env.Int("LOGSIZE", 5e6) // 5MB
 	logCount      = env.Int("LOGCOUNT", 9)
 	logQueries    = env.Bool("LOG_QUERIES", false)
+	logLevels     = env.String("LOG_LEVEL", "info") // default level, then module=level for each override, such as info,app=debug,generator=warn
 	maxDBConns    = env.Int("MAXDBCONNS", 10)           // set to 100 in prod
+	autoMigrate   = env.Bool("MIGRATE", true)           // false only checks at startup that the schema is current
 	rpsToken      = env.Int("RATELIMIT_TOKEN", 0)       // reqs/sec
 	rpsRemoteAddr = env.Int("RATELIMIT_REMOTE_ADDR", 0) // reqs/sec
 	indexTxs      = env.Bool("INDEX_TRANSACTIONS", true)
+	feeAssetID    = env.String("FEE_ASSET_ID", "")
+	slowQuery     = env.Duration("SLOW_QUERY_THRESHOLD", time.Second)
+	prewarm       = env.StringSlice("PREWARM_INDEXES")
+	scrubPeriod   = env.Duration("SCRUB_PERIOD", 10*time.Minute)
+	anomalies     = env.Bool("DETECT_ANOMALIES", false)
+	poolMaxBytes  = env.Int("MEMPOOL_MAX_BYTES", 256<<20)
+	poolMemFrac   = env.Int("MEMPOOL_MEMORY_FRACTION", 2500) // basis points of available memory
+	memoWeight    = env.Int("MEMO_FEE_WEIGHT", 4)            // times each byte of reference data is charged for, beyond its size
+	orphanTTL     = env.Duration("MEMPOOL_ORPHAN_TTL", time.Minute) // 0 disables holding txs that spend unknown outputs
+	maxOrphans    = env.Int("MEMPOOL_MAX_ORPHANS", 1000)
+	emptyBlocks   = env.Bool("MAKE_EMPTY_BLOCKS", false) // make chain blocks for rounds that deliver no txs
+	processors    = env.StringSlice("BLOCK_PROCESSORS")        // name=arg of each; see package core/blockproc
+	procPlugins   = env.StringSlice("BLOCK_PROCESSOR_PLUGINS") // paths of Go plugins registering further processors
+	failover      = env.Bool("GENERATOR_FAILOVER", false)  // elect the process generating blocks among those sharing DATABASE_URL; see core.GeneratorFailover
+	routableAddr  = env.String("ROUTABLE_ADDRESS", "")     // host:port the other processes reach this one at; empty uses LISTEN, and must be set for GENERATOR_FAILOVER
+	replicaOf     = env.String("REPLICA_OF", "")           // URL of the node whose blocks a read replica follows; empty runs the app
+	issueSlack    = env.Duration("ISSUANCE_MIN_TIME_SLACK", 0)
+	issueWindow   = env.Duration("MAX_ISSUANCE_WINDOW", 24*time.Hour) // 0 leaves issuance time ranges unbounded
+	snapInterval  = env.Int("SNAPSHOT_INTERVAL", 0) // blocks; 0 uses SNAPSHOT_FREQUENCY
+	snapDir       = env.String("SNAPSHOT_DIR", "")  // empty stores snapshots in the database
+	snapKeep      = env.Int("SNAPSHOT_KEEP", 3)     // snapshot files kept; 0 keeps all
+	coldDir       = env.String("UTXO_COLD_DIR", "") // scratch directory for the state tree's cold pages; empty keeps the whole tree in memory
+	pageBits      = env.Int("UTXO_PAGE_BITS", 16) // depth, in bits, of the state tree's pages
+	hotBlocks     = env.Int("UTXO_HOT_BLOCKS", 100) // blocks a page stays in memory after it is last used
+	pruneKeep     = env.Int("PRUNE_KEEP_BLOCKS", 0) // most recent blocks kept; 0 keeps all (archive)
+	pruneEvery    = env.Int("PRUNE_KEEP_EVERY", 0)  // older blocks and snapshots kept at multiples of this height
+	prunePeriod   = env.Duration("PRUNE_PERIOD", time.Hour)
+	rollbackBlks  = env.Int("ROLLBACK_BLOCKS", 0) // blocks to roll the chain back by at startup
+	restoreFrom   = env.String("RESTORE_BACKUP", "") // path of a backup, from POST /backup, to replace the database with at startup
+	snapFrequency = env.Duration("SNAPSHOT_FREQUENCY", time.Hour)
+	snapMaxAge    = env.Duration("SNAPSHOT_MAX_AGE", txdb.DefaultSnapshotMaxAge) // snapshots kept in the database; 0 keeps all
+	blockCache    = env.Int("BLOCK_CACHE_SIZE", 30)                              // blocks
+	txCache       = env.Int("TX_CACHE_SIZE", 1000)                               // tx validation results
+	cacheMinFree  = env.Int("CACHE_MIN_FREE_MEMORY", 0)                          // bytes; 0 keeps cache sizes fixed
+	cacheTune     = env.Duration("CACHE_TUNE_PERIOD", 10*time.Second)
+	replayCheck   = env.Duration("REPLAY_CHECK_PERIOD", 0) // 0 disables the background replay check
+	feeEstimate   = env.Int("FEE_ESTIMATE_BLOCKS", 100)    // recent blocks fee estimates are based on; 0 disables them
+	otlpEndpoint  = env.String("OTEL_EXPORTER_OTLP_ENDPOINT", "") // OTLP/HTTP collector spans are exported to; empty disables tracing
+	otelService   = env.String("OTEL_SERVICE_NAME", "chainmint")
+	traceRate     = env.Int("TRACE_SAMPLE_RATE", 10000) // basis points of requests traced
+	debugAddr     = env.String("DEBUG_LISTEN", "")      // empty disables the pprof and debug endpoints; see package net/http/debug
+	debugToken    = env.String("DEBUG_TOKEN", "")       // token every debug request must present; required with DEBUG_LISTEN
+	syncPeer      = env.String("STATE_SYNC_PEER", "")   // URL of a node to restore a new node's state from; requires CHECKPOINT_HEIGHT
 	home          = core.HomeDirFromEnvironment()
 	bootURL       = env.String("BOOTURL", "")
 
+	runLog = chainlog.Module("chain")
+
 	// build vars; initialized by the linker
 	buildTag    = "?"
 	buildCommit = "?"
@@ -135,6 +206,18 @@ func Run(app *app.ChainmintApplication) {
 	env.Parse()
 	//warnCompat(ctx)
 
+	err := chainlog.SetLevels(*logLevels)
+	if err != nil {
+		chainlog.Fatalkv(ctx, chainlog.KeyError, errors.Wrap(err, "LOG_LEVEL"))
+	}
+
+	if *otlpEndpoint != "" {
+		err = trace.Export(ctx, *otlpEndpoint, *otelService, *traceRate)
+		if err != nil {
+			chainlog.Fatalkv(ctx, chainlog.KeyError, err)
+		}
+	}
+
 	listener, err := net.Listen("tcp", *listenAddr)
 	if err != nil {
 		chainlog.Fatalkv(ctx, chainlog.KeyError, err)
@@ -181,12 +264,14 @@ func Run(app *app.ChainmintApplication) {
 	db.SetMaxOpenConns(*maxDBConns)
 	db.SetMaxIdleConns(*maxDBConns)
 
-/*
-	err = migrate.Run(db)
-	if err != nil {
-		chainlog.Fatalkv(ctx, chainlog.KeyError, err)
+	if *restoreFrom != "" {
+		err = restoreBackup(ctx, db, *dbURL, *restoreFrom)
+		if err != nil {
+			chainlog.Fatalkv(ctx, chainlog.KeyError, errors.Wrap(err, "restoring backup"))
+		}
+		// Don't restore again if this process execs itself.
+		os.Unsetenv("RESTORE_BACKUP")
 	}
-	*/
 
 	//accessTokens := &accesstoken.CredentialStore{DB: db}
 
@@ -262,19 +347,130 @@ func Run(app *app.ChainmintApplication) {
 	conf := "not null"
 	var h http.Handler
 	var api *core.API
+	var elected chan struct{} // closed when elected to generate blocks, under GENERATOR_FAILOVER
 	if &conf != nil {
-		api = launchConfiguredCore(ctx, db, processID, core.UseTLS(nil))
+		opts := []core.RunOption{core.UseTLS(nil)}
+		var peerTLS *core.PeerTLS
+		if *peerAddr != "" {
+			peerTLS, err = core.LoadPeerTLS(core.PeerTLSFiles{
+				Cert:      *peerCert,
+				Key:       *peerKey,
+				RootCAs:   *peerCAs,
+				Allowlist: *peerAllowlist,
+			})
+			if err != nil {
+				chainlog.Fatalkv(ctx, chainlog.KeyError, errors.Wrap(err, "loading peer TLS"))
+			}
+			go peerTLS.Watch(ctx, *peerReload)
+			opts = append(opts, core.UsePeerTLS(peerTLS))
+		}
+		if *txSignerURL != "" {
+			opts = append(opts, core.TransactionSigner(remoteTxSigner(ctx, processID)))
+		}
+		if *failover {
+			if *routableAddr == "" {
+				chainlog.Fatalkv(ctx, chainlog.KeyError, errors.New("GENERATOR_FAILOVER requires ROUTABLE_ADDRESS"))
+			}
+			elected = make(chan struct{})
+			opts = append(opts, core.GeneratorFailover(func(context.Context) error {
+				close(elected)
+				return nil
+			}))
+		}
+		var replicated *rpc.Client
+		if *replicaOf != "" {
+			if *failover {
+				chainlog.Fatalkv(ctx, chainlog.KeyError, errors.New("a read replica cannot use GENERATOR_FAILOVER"))
+			}
+			replicated = replicaPeer(ctx, processID, peerTLS)
+		}
+		local := chainLocal{snapshotDir: *snapDir, coldDir: *coldDir, processors: *processors}
+		if *blockPub != "" {
+			if *signStateFile == "" || *blockHSMURL == "" {
+				chainlog.Fatalkv(ctx, chainlog.KeyError, errors.New("BLOCK_SIGNER_PUB requires SIGN_STATE_FILE and BLOCK_HSM_URL"))
+			}
+			local.signStateFile = *signStateFile
+		}
+		api = launchConfiguredCore(ctx, db, *dbURL, processID, local, app.Rollback, replicated, opts...)
 	} else {
 		var opts []core.RunOption
 		//opts = append(opts, core.UseTLS(tlsConfig))
 		//opts = append(opts, enableMockHSM(db)...)
-		chainlog.Printf(ctx, "Launching as unconfigured Core.")
+		runLog.Infokv(ctx, chainlog.KeyMessage, "launching as unconfigured Core")
 		api = core.RunUnconfigured(ctx, db, *listenAddr, opts...)
 	}
-	app.Init(api)
+	if elected != nil {
+		// Serve the API while standing by, forwarding submitted
+		// transactions to the leader, and start the app, and so
+		// its ABCI server, only once this process takes over.
+		coreHandler.Set(api)
+		runLog.Infokv(ctx, chainlog.KeyMessage, "Chain Core standing by to generate blocks")
+		<-elected
+	}
+	if *replicaOf == "" {
+		app.Init(api)
+		mux.Handle("/metrics", app.MetricsHandler())
+		mux.Handle("/state-sync/", app.StateSyncHandler())
+		if *syncPeer != "" {
+			err = app.SyncFrom(ctx, *syncPeer)
+			if err != nil {
+				chainlog.Fatalkv(ctx, chainlog.KeyError, errors.Wrap(err, "state sync"))
+			}
+		}
+	}
+	if *grpcAddr != "" {
+		grpcListener, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			chainlog.Fatalkv(ctx, chainlog.KeyError, err)
+		}
+		go func() {
+			err := api.ServeGRPC(grpcListener)
+			chainlog.Fatalkv(ctx, chainlog.KeyError, errors.Wrap(err, "ServeGRPC"))
+		}()
+		runLog.Infokv(ctx, chainlog.KeyMessage, "Chain Core gRPC API listening", "addr", *grpcAddr)
+	}
+	if *debugAddr != "" {
+		if *debugToken == "" {
+			chainlog.Fatalkv(ctx, chainlog.KeyError, errors.New("DEBUG_LISTEN requires DEBUG_TOKEN"))
+		}
+		debugListener, err := net.Listen("tcp", *debugAddr)
+		if err != nil {
+			chainlog.Fatalkv(ctx, chainlog.KeyError, err)
+		}
+		var state debug.StateFunc
+		if *replicaOf == "" {
+			state = app.DebugState
+		}
+		go func() {
+			err := http.Serve(debugListener, debug.Handler(*debugToken, state))
+			chainlog.Fatalkv(ctx, chainlog.KeyError, errors.Wrap(err, "serving debug endpoints"))
+		}()
+		runLog.Infokv(ctx, chainlog.KeyMessage, "Chain Core debug endpoints listening", "addr", *debugAddr)
+	}
+	if *peerAddr != "" {
+		peerListener, err := net.Listen("tcp", *peerAddr)
+		if err != nil {
+			chainlog.Fatalkv(ctx, chainlog.KeyError, err)
+		}
+		go func() {
+			err := api.ServePeers(peerListener)
+			chainlog.Fatalkv(ctx, chainlog.KeyError, errors.Wrap(err, "ServePeers"))
+		}()
+		runLog.Infokv(ctx, chainlog.KeyMessage, "Chain Core inter-node RPC listening", "addr", *peerAddr)
+	}
 	h = api
-	coreHandler.Set(h)
-	chainlog.Printf(ctx, "Chain Core online and listening at %s", *listenAddr)
+	if elected == nil {
+		coreHandler.Set(h)
+	}
+	launchChains(ctx, mux, processID)
+	runLog.Infokv(ctx, chainlog.KeyMessage, "Chain Core online and listening", "addr", *listenAddr)
+
+	if *replicaOf != "" {
+		// A replica runs no app, so there is no ABCI server for
+		// the caller to start; serve the API alone.
+		runLog.Infokv(ctx, chainlog.KeyMessage, "Chain Core replicating", "source", *replicaOf)
+		select {}
+	}
 
 	// block forever without using any resources so this process won't quit while
 	// the goroutine containing ListenAndServe is still working
@@ -299,31 +495,233 @@ func maybeUseTLS(ln net.Listener) (net.Listener, *tls.Config, error) {
 	return ln, c, nil
 }
 
-func launchConfiguredCore(ctx context.Context, db *sql.DB, processID string, opts ...core.RunOption) *core.API {
+// restoreBackup replaces the contents of db, at dbURL, with the
+// backup in the file at path, then saves the backup's snapshot, as
+// SNAPSHOT_DIR directs, for the Core to recover from.
+func restoreBackup(ctx context.Context, db *sql.DB, dbURL, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	defer f.Close()
+	height, snapshot, err := core.RestoreBackup(ctx, dbURL, f)
+	if err != nil {
+		return err
+	}
+
+	// The backup may be from an older version.
+	err = migrate.Run(db)
+	if err != nil {
+		return err
+	}
+	store := txdb.NewStore(db)
+	if *snapDir != "" {
+		err = store.UseSnapshotDir(*snapDir, *snapKeep)
+		if err != nil {
+			return err
+		}
+	}
+	return store.SaveSnapshot(ctx, height, snapshot)
+}
+
+// openColdTier returns a patricia.Tier keeping the state tree's
+// pages in a LevelDB database in dir. The pages are only needed by
+// the running process, which loads its state from a snapshot at
+// startup, so dir is emptied first.
+func openColdTier(dir string) (*patricia.Tier, error) {
+	if *pageBits < 1 || *pageBits > 255 {
+		return nil, errors.New("UTXO_PAGE_BITS must be between 1 and 255")
+	}
+	if *hotBlocks < 0 {
+		return nil, errors.New("UTXO_HOT_BLOCKS must not be negative")
+	}
+	err := os.RemoveAll(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "clearing the cold tier directory")
+	}
+	store, err := kv.OpenLevelDB(dir)
+	if err != nil {
+		return nil, err
+	}
+	return patricia.NewTier(kvdb.NewPages(store), *pageBits, uint64(*hotBlocks)), nil
+}
+
+// chainLocal is the configuration of a chain that no two chains
+// hosted in one process may share. A chain with no sign state file
+// does not sign blocks.
+type chainLocal struct {
+	snapshotDir   string
+	coldDir       string
+	processors    []string
+	signStateFile string
+}
+
+// launchConfiguredCore starts the Core. If rollbackApp is not nil and
+// ROLLBACK_BLOCKS is set, the chain is first rolled back, and
+// rollbackApp rolls back the app's state with it. If replicated is
+// not nil, the Core is a read replica of that node, and generates no
+// blocks itself.
+func launchConfiguredCore(ctx context.Context, db *sql.DB, dbURL, processID string, local chainLocal, rollbackApp core.RollbackFunc, replicated *rpc.Client, opts ...core.RunOption) *core.API {
+	// Bring the schema up to date, or check that it is,
+	// before anything else reads the database.
+	migrateOrCheck := migrate.Check
+	if *autoMigrate {
+		migrateOrCheck = migrate.Run
+	}
+	err := migrateOrCheck(db)
+	if err != nil {
+		chainlog.Fatalkv(ctx, chainlog.KeyError, err)
+	}
+
 	// Initialize the protocol.Chain.
-	heights, err := txdb.ListenBlocks(ctx, *dbURL)
+	heights, err := txdb.ListenBlocks(ctx, dbURL)
 	if err != nil {
 		chainlog.Fatalkv(ctx, chainlog.KeyError, err)
 	}
 	store := txdb.NewStore(db)
+	store.SetSnapshotMaxAge(*snapMaxAge)
+	if local.snapshotDir != "" {
+		err = store.UseSnapshotDir(local.snapshotDir, *snapKeep)
+		if err != nil {
+			chainlog.Fatalkv(ctx, chainlog.KeyError, err)
+		}
+	}
+	rollback := rollbackApp != nil && *rollbackBlks > 0
+	if rollback {
+		_, err = core.Rollback(ctx, db, store, uint64(*rollbackBlks), rollbackApp)
+		if err != nil {
+			chainlog.Fatalkv(ctx, chainlog.KeyError, errors.Wrap(err, "rolling back"))
+		}
+		// Don't roll back again if this process execs itself.
+		os.Unsetenv("ROLLBACK_BLOCKS")
+	}
 	blockchainId := bc.EmptyStringHash
 	c, err := protocol.NewChain(ctx, blockchainId, store, heights)
 	if err != nil {
 		chainlog.Fatalkv(ctx, chainlog.KeyError, err)
 	}
-	// to do: to added BlockSinger.
-	gen := generator.New(c, db)
-	opts = append(opts, core.GeneratorLocal(gen))
+	c.SetSnapshotSchedule(uint64(*snapInterval), *snapFrequency)
+	if local.coldDir != "" {
+		tier, err := openColdTier(local.coldDir)
+		if err != nil {
+			chainlog.Fatalkv(ctx, chainlog.KeyError, err)
+		}
+		c.SetColdTier(tier)
+	}
+	if rollback {
+		// Load the state at the new height, so that the app reports
+		// it to Tendermint.
+		_, _, err = c.Recover(ctx)
+		if err != nil {
+			chainlog.Fatalkv(ctx, chainlog.KeyError, err)
+		}
+	}
+	if local.signStateFile != "" {
+		signer, err := newBlockSigner(db, c, processID, local.signStateFile)
+		if err != nil {
+			chainlog.Fatalkv(ctx, chainlog.KeyError, err)
+		}
+		opts = append(opts, core.BlockSigner(signer.ValidateAndSignBlock))
+	}
+	var gen *generator.Generator
+	if replicated != nil {
+		opts = append(opts, core.GeneratorRemote(replicated))
+	} else {
+		gen = generator.New(c, db)
+		opts = append(opts, core.GeneratorLocal(gen))
+	}
+	poolLimits := generator.PoolLimits{
+		MaxBytes:       int64(*poolMaxBytes),
+		MemoryFraction: int64(*poolMemFrac),
+		OrphanTTL:      *orphanTTL,
+		MaxOrphans:     *maxOrphans,
+	}
+	if *memoWeight > 0 {
+		poolLimits.Weight = func(tx *legacy.Tx) int64 { return int64(strategies.MemoSize(tx)) * int64(*memoWeight) }
+	}
+
+	if *feeAssetID != "" {
+		var feeAsset bc.AssetID
+		err = feeAsset.UnmarshalText([]byte(*feeAssetID))
+		if err != nil {
+			chainlog.Fatalkv(ctx, chainlog.KeyError, errors.Wrap(err, "parsing FEE_ASSET_ID"))
+		}
+		opts = append(opts, core.FeeAsset(feeAsset))
+		poolLimits.Fee = func(tx *legacy.Tx) uint64 { return strategies.Fee(tx, feeAsset) }
+	}
+	if gen != nil {
+		gen.SetPoolLimits(poolLimits)
+		gen.SetEmptyBlocks(*emptyBlocks)
+	}
+	opts = append(opts, core.SlowQueryThreshold(*slowQuery), core.PrewarmIndexes(*prewarm), core.ScrubPeriod(*scrubPeriod))
+	opts = append(opts, core.ReplayCheckPeriod(*replayCheck))
+	opts = append(opts, core.FeeEstimateBlocks(*feeEstimate))
+	opts = append(opts, core.IssuanceWindow(*issueSlack, *issueWindow))
+	opts = append(opts, core.Pruning(core.RetentionPolicy{
+		KeepLast:  uint64(*pruneKeep),
+		KeepEvery: uint64(*pruneEvery),
+	}, *prunePeriod))
+	opts = append(opts, core.Caches(core.CacheConfig{
+		BlockCache:    *blockCache,
+		TxCache:       *txCache,
+		MinFreeMemory: int64(*cacheMinFree),
+		TunePeriod:    *cacheTune,
+	}))
+	if *anomalies {
+		opts = append(opts, core.AnomalyDetectors(anomaly.NewDetector().Detect))
+	}
+	for _, path := range *procPlugins {
+		err = blockproc.Load(path)
+		if err != nil {
+			chainlog.Fatalkv(ctx, chainlog.KeyError, err)
+		}
+	}
+	procs, err := blockproc.NewSet(local.processors)
+	if err != nil {
+		chainlog.Fatalkv(ctx, chainlog.KeyError, err)
+	}
+	opts = append(opts, core.BlockProcessors(procs))
 
 	// Start up the Core. This will start up the various Core subsystems,
 	// and begin leader election.
-	api, err := core.Run(ctx, db, *dbURL, c, store, *listenAddr, opts...)
+	addr := *listenAddr
+	if *routableAddr != "" {
+		addr = *routableAddr
+	}
+	api, err := core.Run(ctx, db, dbURL, c, store, addr, opts...)
 	if err != nil {
 		chainlog.Fatalkv(ctx, chainlog.KeyError, err)
 	}
 	return api
 }
 
+// newBlockSigner returns a block signer for c signing with the key
+// of BLOCK_SIGNER_PUB held by the HSM at BLOCK_HSM_URL. It loads the
+// signer's state from the file at statePath, so that blocks signed
+// before a restart or a database restore are not signed over.
+func newBlockSigner(db *sql.DB, c *protocol.Chain, processID, statePath string) (*blocksigner.BlockSigner, error) {
+	pub, err := hex.DecodeString(*blockPub)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return nil, errors.New("BLOCK_SIGNER_PUB must be a hex-encoded ed25519 public key")
+	}
+	u, err := url.Parse(*blockHSMURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing BLOCK_HSM_URL")
+	}
+	hsm := &remoteHSM{Client: &rpc.Client{
+		BaseURL:  u.String(),
+		Username: processID,
+		BuildTag: buildTag,
+		Client:   new(http.Client),
+	}}
+	signer := blocksigner.New(ed25519.PublicKey(pub), hsm, db, c)
+	signer.State, err = blocksigner.LoadSignState(statePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading SIGN_STATE_FILE")
+	}
+	return signer, nil
+}
+
 // remoteHSM is a client wrapper for an hsm that is used as a blocksigner.Signer
 type remoteHSM struct {
 	Client *rpc.Client
@@ -338,6 +736,48 @@ func (h *remoteHSM) Sign(ctx context.Context, pk ed25519.PublicKey, bh *legacy.B
 	return
 }
 
+// replicaPeer returns a client for the inter-node RPC of the node at
+// REPLICA_OF. With peer TLS, it presents this node's certificate.
+func replicaPeer(ctx context.Context, processID string, peerTLS *core.PeerTLS) *rpc.Client {
+	u, err := url.Parse(*replicaOf)
+	if err != nil {
+		chainlog.Fatalkv(ctx, chainlog.KeyError, errors.Wrap(err, "parsing REPLICA_OF"))
+	}
+	httpClient := new(http.Client)
+	if peerTLS != nil {
+		httpClient = peerTLS.Client()
+	}
+	return &rpc.Client{
+		BaseURL:  u.String(),
+		Username: processID,
+		BuildTag: buildTag,
+		Client:   httpClient,
+	}
+}
+
+// remoteTxSigner returns a transaction signer that calls the HSM at
+// TX_SIGNER_URL over mutual TLS.
+func remoteTxSigner(ctx context.Context, processID string) *txsigner.Remote {
+	u, err := url.Parse(*txSignerURL)
+	if err != nil {
+		chainlog.Fatalkv(ctx, chainlog.KeyError, errors.Wrap(err, "parsing TX_SIGNER_URL"))
+	}
+	httpClient, err := txsigner.TLSClient(txsigner.TLSFiles{
+		Cert:    *txSignerCert,
+		Key:     *txSignerKey,
+		RootCAs: *txSignerCA,
+	})
+	if err != nil {
+		chainlog.Fatalkv(ctx, chainlog.KeyError, errors.Wrap(err, "loading transaction signer TLS"))
+	}
+	return &txsigner.Remote{Client: &rpc.Client{
+		BaseURL:  u.String(),
+		Username: processID,
+		BuildTag: buildTag,
+		Client:   httpClient,
+	}}
+}
+
 func remoteSignerInfo(ctx context.Context, processID, buildTag, blockchainID string, conf *config.Config, httpClient *http.Client) (a []*remoteSigner) {
 	for _, signer := range conf.Signers {
 		u, err := url.Parse(signer.Url)
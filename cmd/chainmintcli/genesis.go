@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/chainmint/app"
+	"github.com/chainmint/core/rpc"
+	"github.com/chainmint/crypto/ed25519"
+	chainjson "github.com/chainmint/encoding/json"
+)
+
+// The genesis commands run a genesis ceremony offline; they ignore
+// the client.
+//
+// Each validator operator runs genesis-validator with the chain ID
+// and their Tendermint priv_validator.json, and sends the entry it
+// prints to the coordinator. The coordinator writes the network's
+// spec (see app.GenesisSpec), adds the entries with genesis-add,
+// and runs genesis-build to write the genesis file every node gives
+// both Tendermint and chainmint (as GENESIS_FILE).
+
+// genesisValidator prints the signed genesis entry for the validator
+// whose key is in a Tendermint priv_validator.json.
+func genesisValidator(client *rpc.Client, args []string) {
+	if len(args) != 4 {
+		fatalln("usage: corectl genesis-validator [chain-id] [name] [power] [priv_validator.json]")
+	}
+	power, err := strconv.ParseUint(args[2], 10, 64)
+	if err != nil || power == 0 {
+		fatalln("error: invalid power:", args[2])
+	}
+	var pv struct {
+		PrivKey struct {
+			Type string             `json:"type"`
+			Data chainjson.HexBytes `json:"data"`
+		} `json:"priv_key"`
+	}
+	readJSON(args[3], &pv)
+	if pv.PrivKey.Type != "ed25519" || len(pv.PrivKey.Data) != ed25519.PrivateKeySize {
+		fatalln("error: no ed25519 private key in", args[3])
+	}
+	v := app.SignGenesisValidator(args[0], args[1], power, ed25519.PrivateKey(pv.PrivKey.Data))
+	writeJSON(os.Stdout, v)
+}
+
+// genesisAdd checks validator entries and adds them to a genesis
+// spec, rewriting it in place.
+func genesisAdd(client *rpc.Client, args []string) {
+	if len(args) < 2 {
+		fatalln("usage: corectl genesis-add [spec.json] [validator.json]...")
+	}
+	var spec app.GenesisSpec
+	readJSON(args[0], &spec)
+	for _, path := range args[1:] {
+		var v app.GenesisValidator
+		readJSON(path, &v)
+		err := spec.AddValidator(v)
+		if err != nil {
+			fatalln("error: adding", path+":", err)
+		}
+	}
+	f, err := os.Create(args[0])
+	if err != nil {
+		fatalln("error:", err)
+	}
+	writeJSON(f, &spec)
+	err = f.Close()
+	if err != nil {
+		fatalln("error:", err)
+	}
+}
+
+// genesisBuild prints the genesis file for a genesis spec.
+func genesisBuild(client *rpc.Client, args []string) {
+	if len(args) != 1 {
+		fatalln("usage: corectl genesis-build [spec.json]")
+	}
+	var spec app.GenesisSpec
+	readJSON(args[0], &spec)
+	b, err := spec.Build()
+	if err != nil {
+		fatalln("error:", err)
+	}
+	fmt.Printf("%s\n", b)
+}
+
+func readJSON(path string, v interface{}) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		fatalln("error:", err)
+	}
+	err = json.Unmarshal(b, v)
+	if err != nil {
+		fatalln("error: parsing", path+":", err)
+	}
+}
+
+func writeJSON(f *os.File, v interface{}) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fatalln("error:", err)
+	}
+	fmt.Fprintf(f, "%s\n", b)
+}
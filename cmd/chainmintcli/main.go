@@ -4,6 +4,7 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
@@ -11,6 +12,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -52,6 +54,11 @@ type grantReq struct {
 var commands = map[string]*command{
 	"create-block-keypair": {createBlockKeyPair},
 	"reset":                {reset},
+	"rollback":             {rollback},
+	"backup":               {backup},
+	"verify-replay":        {verifyReplay},
+	"estimate-fee":         {estimateFee},
+	"log-levels":           {logLevels},
 	"grant":                {grant},
 	"revoke":               {revoke},
 	"wait":                 {wait},
@@ -61,6 +68,9 @@ var commands = map[string]*command{
 	"update-asset-tags":	{updateAssetTags},
 	"build-transaction": {buildTransaction},
 	"issue-test": {example.IssueTest},
+	"genesis-validator": {genesisValidator},
+	"genesis-add":       {genesisAdd},
+	"genesis-build":     {genesisBuild},
 }
 
 func main() {
@@ -123,6 +133,130 @@ func reset(client *rpc.Client, args []string) {
 	dieOnRPCError(err)
 }
 
+// rollback asks a remote core to restart and roll its chain back by
+// the given number of blocks.
+func rollback(client *rpc.Client, args []string) {
+	if len(args) != 1 {
+		fatalln("usage: corectl rollback [blocks]")
+	}
+	blocks, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		fatalln("error: invalid number of blocks:", args[0])
+	}
+
+	req := map[string]uint64{
+		"blocks": blocks,
+	}
+
+	err = client.Call(context.Background(), "/rollback", req, nil)
+	dieOnRPCError(err)
+}
+
+// backup downloads a backup of a remote core's database to the
+// named file. The core is restored from it by starting it with
+// RESTORE_BACKUP set to the file's path.
+func backup(client *rpc.Client, args []string) {
+	if len(args) != 1 {
+		fatalln("usage: corectl backup [file]")
+	}
+	body, err := client.CallRaw(context.Background(), "/backup", nil)
+	dieOnRPCError(err)
+	defer body.Close()
+
+	// Write to a temporary file and rename it into place, so an
+	// interrupted download never leaves a partial backup.
+	tmp := args[0] + ".partial"
+	f, err := os.Create(tmp)
+	if err != nil {
+		fatalln("error:", err)
+	}
+	_, err = io.Copy(f, body)
+	if err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		os.Remove(tmp)
+		fatalln("error:", err)
+	}
+	err = os.Rename(tmp, args[0])
+	if err != nil {
+		fatalln("error:", err)
+	}
+}
+
+// verifyReplay asks a remote core to re-execute its chain from the
+// initial block, prints the report, and exits with status 1 if the
+// replay diverged from the stored chain.
+func verifyReplay(client *rpc.Client, args []string) {
+	if len(args) != 0 {
+		fatalln("error: verify-replay takes no args")
+	}
+	var report struct {
+		Height     uint64           `json:"height"`
+		ChainTip   uint64           `json:"chain_tip"`
+		Divergence *json.RawMessage `json:"divergence"`
+	}
+	err := client.Call(context.Background(), "/verify-replay", nil, &report)
+	dieOnRPCError(err)
+	if report.Divergence == nil {
+		fmt.Printf("replayed %d of %d blocks as stored\n", report.Height, report.ChainTip)
+		return
+	}
+	fmt.Printf("replayed %d of %d blocks as stored; then diverged: %s\n", report.Height, report.ChainTip, *report.Divergence)
+	os.Exit(1)
+}
+
+// estimateFee asks a remote core for the fee that gets a transaction
+// into one of the given number of blocks, and prints it per kilobyte
+// and, given a transaction size in bytes, for the transaction.
+func estimateFee(client *rpc.Client, args []string) {
+	if len(args) < 1 || len(args) > 2 {
+		fatalln("usage: corectl estimate-fee [blocks] [size]")
+	}
+	blocks, err := strconv.Atoi(args[0])
+	if err != nil {
+		fatalln("error: invalid number of blocks:", args[0])
+	}
+	req := map[string]int64{"block_target": int64(blocks)}
+	if len(args) == 2 {
+		req["size"], err = strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			fatalln("error: invalid size:", args[1])
+		}
+	}
+	var est struct {
+		FeePerKB     uint64 `json:"fee_per_kb"`
+		Fee          uint64 `json:"fee"`
+		SampleBlocks int    `json:"sample_blocks"`
+	}
+	err = client.Call(context.Background(), "/estimate-fee", req, &est)
+	dieOnRPCError(err)
+	fmt.Printf("fee per kilobyte: %d (from %d blocks)\n", est.FeePerKB, est.SampleBlocks)
+	if len(args) == 2 {
+		fmt.Printf("fee: %d\n", est.Fee)
+	}
+}
+
+// logLevels sets the log levels of a remote core, if given, such as
+// "info,app=debug", and prints the levels in effect.
+func logLevels(client *rpc.Client, args []string) {
+	if len(args) > 1 {
+		fatalln("usage: corectl log-levels [levels]")
+	}
+	var req struct {
+		Levels string `json:"levels"`
+	}
+	if len(args) == 1 {
+		req.Levels = args[0]
+	}
+	var resp struct {
+		Levels string `json:"levels"`
+	}
+	err := client.Call(context.Background(), "/set-log-levels", req, &resp)
+	dieOnRPCError(err)
+	fmt.Println(resp.Levels)
+}
+
 func grant(client *rpc.Client, args []string) {
 	editAuthz(client, args, "grant")
 }
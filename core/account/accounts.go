@@ -22,6 +22,8 @@ import (
 	"github.com/chainmint/protocol/vmutil"
 )
 
+var accountLog = log.Module("account")
+
 const maxAccountCache = 1000
 
 var (
@@ -72,7 +74,7 @@ func (m *Manager) ExpireReservations(ctx context.Context, period time.Duration)
 	for {
 		select {
 		case <-ctx.Done():
-			log.Printf(ctx, "Deposed, ExpireReservations exiting")
+			accountLog.Infokv(ctx, log.KeyMessage, "deposed, ExpireReservations exiting")
 			return
 		case <-ticks:
 			err := m.utxoDB.ExpireReservations(ctx)
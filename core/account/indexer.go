@@ -108,7 +108,13 @@ func (m *Manager) ProcessBlocks(ctx context.Context) {
 	})
 	go m.pinStore.ProcessBlocks(ctx, m.chain, DeleteSpentsPinName, func(ctx context.Context, b *legacy.Block) error {
 		<-m.pinStore.PinWaiter(PinName, b.Height)
-		<-m.pinStore.PinWaiter(query.TxPinName, b.Height)
+		if m.indexer != nil {
+			// The tx indexer annotates spends with the
+			// account UTXOs they spend, so they must outlast
+			// it. Without an indexer, as when transactions
+			// are not indexed, there is no tx pin to wait for.
+			<-m.pinStore.PinWaiter(query.TxPinName, b.Height)
+		}
 		return m.deleteSpentOutputs(ctx, b)
 	})
 	m.pinStore.ProcessBlocks(ctx, m.chain, PinName, m.indexAccountUTXOs)
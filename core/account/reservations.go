@@ -0,0 +1,105 @@
+package account
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/chainmint/protocol/bc"
+)
+
+// Reservation describes a reservation of an account's outputs, made
+// when building a transaction that spends them. Until it expires or
+// is canceled, the outputs are not selected by other builds.
+type Reservation struct {
+	ID        uint64     `json:"id"`
+	AccountID string     `json:"account_id"`
+	AssetID   bc.AssetID `json:"asset_id"`
+	Amount    uint64     `json:"amount"`
+	Change    uint64     `json:"change"`
+	OutputIDs []bc.Hash  `json:"output_ids"`
+	ExpiresAt time.Time  `json:"expires_at"`
+}
+
+func (res *reservation) describe() *Reservation {
+	r := &Reservation{
+		ID:        res.ID,
+		AccountID: res.Source.AccountID,
+		AssetID:   res.Source.AssetID,
+		Change:    res.Change,
+		OutputIDs: make([]bc.Hash, 0, len(res.UTXOs)),
+		ExpiresAt: res.Expiry,
+	}
+	for _, u := range res.UTXOs {
+		r.Amount += u.Amount
+		r.OutputIDs = append(r.OutputIDs, u.OutputID)
+	}
+	return r
+}
+
+// Reservations returns the reservations of the outputs of the
+// account with the given ID, or of all accounts if accountID is
+// empty, in order of expiry.
+func (m *Manager) Reservations(accountID string) []*Reservation {
+	re := m.utxoDB
+	var found []*Reservation
+	re.reservationsMu.Lock()
+	for _, res := range re.reservations {
+		if accountID == "" || res.Source.AccountID == accountID {
+			found = append(found, res.describe())
+		}
+	}
+	re.reservationsMu.Unlock()
+
+	sort.Slice(found, func(i, j int) bool {
+		if !found[i].ExpiresAt.Equal(found[j].ExpiresAt) {
+			return found[i].ExpiresAt.Before(found[j].ExpiresAt)
+		}
+		return found[i].ID < found[j].ID
+	})
+	return found
+}
+
+// CancelReservations cancels the reservations with the given IDs
+// and those holding any of the given outputs, making their outputs
+// available to other builds before the reservations expire. It
+// returns the reservations canceled.
+func (m *Manager) CancelReservations(ctx context.Context, ids []uint64, outputIDs []bc.Hash) []*Reservation {
+	re := m.utxoDB
+	want := make(map[uint64]bool, len(ids))
+	for _, id := range ids {
+		want[id] = true
+	}
+	outs := make(map[bc.Hash]bool, len(outputIDs))
+	for _, id := range outputIDs {
+		outs[id] = true
+	}
+
+	var rids []uint64
+	re.reservationsMu.Lock()
+	for rid, res := range re.reservations {
+		match := want[rid]
+		for _, u := range res.UTXOs {
+			match = match || outs[u.OutputID]
+		}
+		if match {
+			rids = append(rids, rid)
+		}
+	}
+	re.reservationsMu.Unlock()
+
+	canceled := []*Reservation{}
+	for _, rid := range rids {
+		re.reservationsMu.Lock()
+		res, ok := re.reservations[rid]
+		re.reservationsMu.Unlock()
+		// The reservation may have expired or been canceled
+		// since it was found.
+		if !ok || re.Cancel(ctx, rid) != nil {
+			continue
+		}
+		canceled = append(canceled, res.describe())
+	}
+	sort.Slice(canceled, func(i, j int) bool { return canceled[i].ID < canceled[j].ID })
+	return canceled
+}
@@ -0,0 +1,46 @@
+package account
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chainmint/protocol/bc"
+)
+
+func TestCancelReservations(t *testing.T) {
+	ctx := context.Background()
+	m := &Manager{utxoDB: newReserver(nil, nil, nil)}
+	now := time.Now()
+	for i, acc := range []string{"acc1", "acc1", "acc2"} {
+		u := &utxo{OutputID: bc.NewHash([32]byte{byte(i)}), AccountID: acc, Amount: 5}
+		res := &reservation{
+			ID:     uint64(i),
+			Source: u.source(),
+			UTXOs:  []*utxo{u},
+			Expiry: now.Add(time.Duration(3-i) * time.Minute),
+		}
+		m.utxoDB.reservations[res.ID] = res
+		m.utxoDB.source(res.Source).reserved[u.OutputID] = res.ID
+	}
+
+	got := m.Reservations("acc1")
+	if len(got) != 2 || got[0].ID != 1 || got[1].ID != 0 || got[0].Amount != 5 {
+		t.Fatalf("Reservations(acc1) = %+v, want 1 then 0", got)
+	}
+	if n := len(m.Reservations("")); n != 3 {
+		t.Errorf("got %d reservations of all accounts, want 3", n)
+	}
+
+	canceled := m.CancelReservations(ctx, []uint64{0}, []bc.Hash{bc.NewHash([32]byte{2})})
+	if len(canceled) != 2 || canceled[0].ID != 0 || canceled[1].ID != 2 {
+		t.Fatalf("canceled = %+v, want 0 and 2", canceled)
+	}
+	if got := m.Reservations(""); len(got) != 1 || got[0].ID != 1 {
+		t.Errorf("remaining reservations = %+v, want 1", got)
+	}
+	src := source{AccountID: "acc2"}
+	if _, ok := m.utxoDB.source(src).reserved[bc.NewHash([32]byte{2})]; ok {
+		t.Error("canceled output still reserved")
+	}
+}
@@ -0,0 +1,218 @@
+// Package anomaly flags unusual account activity as transactions
+// are indexed.
+package anomaly
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/chainmint/core/query"
+	"github.com/chainmint/database/pg"
+	"github.com/chainmint/errors"
+)
+
+// Reasons a transaction is flagged.
+const (
+	ReasonAmount       = "unusual_amount"
+	ReasonCounterparty = "new_counterparty"
+	ReasonVelocity     = "velocity_spike"
+)
+
+// Detector flags the payments an account makes that stand out
+// from its own history. A payment is a non-change output of a
+// transaction spending from the account, to somewhere other than
+// the account. Payments are flagged when
+//
+//   - the amount is more than Deviations standard deviations above
+//     the mean of the account's past payments of the asset;
+//   - the account has never paid the counterparty before (another
+//     account, or a control program outside the Core);
+//   - the account has made more than VelocityFactor times as many
+//     payment transactions in the last Window as it has on average
+//     in the Baseline windows before.
+//
+// Accounts with fewer than MinHistory past payments of an asset
+// are not judged on amount or counterparty.
+type Detector struct {
+	MinHistory     int
+	Deviations     float64
+	Window         time.Duration
+	Baseline       int
+	VelocityFactor float64
+	MinVelocity    int
+}
+
+// NewDetector returns a Detector with default settings.
+func NewDetector() *Detector {
+	return &Detector{
+		MinHistory:     10,
+		Deviations:     4,
+		Window:         time.Hour,
+		Baseline:       24,
+		VelocityFactor: 5,
+		MinVelocity:    5,
+	}
+}
+
+// stats summarizes an account's past payments of an asset.
+type stats struct {
+	n      int
+	mean   float64
+	stddev float64
+}
+
+// Detect is a query.Detector.
+func (d *Detector) Detect(ctx context.Context, db pg.DB, txs []*query.AnnotatedTx) ([]*query.TxFlag, error) {
+	var flags []*query.TxFlag
+	for _, tx := range txs {
+		for _, accountID := range spenders(tx) {
+			flag := func(reason, detail string) {
+				flags = append(flags, &query.TxFlag{
+					TransactionID: tx.ID,
+					BlockHeight:   tx.BlockHeight,
+					AccountID:     accountID,
+					Reason:        reason,
+					Detail:        detail,
+				})
+			}
+
+			var unusual, unknown bool
+			for _, out := range tx.Outputs {
+				if out.Purpose == "change" || out.AccountID == accountID {
+					continue
+				}
+				s, err := paymentStats(ctx, db, accountID, out, tx.BlockHeight)
+				if err != nil {
+					return nil, err
+				}
+				if s.n < d.MinHistory {
+					continue
+				}
+				if detail, ok := d.unusualAmount(out.Amount, s); ok && !unusual {
+					unusual = true
+					flag(ReasonAmount, detail)
+				}
+				if unknown {
+					continue
+				}
+				known, err := knownCounterparty(ctx, db, accountID, out, tx.BlockHeight)
+				if err != nil {
+					return nil, err
+				}
+				if !known {
+					unknown = true
+					flag(ReasonCounterparty, fmt.Sprintf("first payment to %s", counterparty(out)))
+				}
+			}
+
+			recent, base, err := d.paymentCounts(ctx, db, accountID, tx)
+			if err != nil {
+				return nil, err
+			}
+			if detail, ok := d.velocitySpike(recent, base); ok {
+				flag(ReasonVelocity, detail)
+			}
+		}
+	}
+	return flags, nil
+}
+
+// unusualAmount reports whether amount is unusual given the
+// stats of past payments, and if so, why.
+func (d *Detector) unusualAmount(amount uint64, s stats) (string, bool) {
+	if s.stddev == 0 {
+		// Every past payment was the same amount; any other
+		// amount is unusual only when well above it.
+		if float64(amount) <= s.mean*(1+d.Deviations) {
+			return "", false
+		}
+		return fmt.Sprintf("amount %d, past %d payments were all %.0f", amount, s.n, s.mean), true
+	}
+	z := (float64(amount) - s.mean) / s.stddev
+	if z <= d.Deviations {
+		return "", false
+	}
+	return fmt.Sprintf("amount %d is %.1f standard deviations above the mean of %.0f over %d payments", amount, z, s.mean, s.n), true
+}
+
+// velocitySpike reports whether recent payment transactions in
+// the last window are a spike over base transactions in the
+// baseline windows before it, and if so, why.
+func (d *Detector) velocitySpike(recent, base int) (string, bool) {
+	if recent < d.MinVelocity || d.Baseline <= 0 {
+		return "", false
+	}
+	avg := float64(base) / float64(d.Baseline)
+	if float64(recent) <= d.VelocityFactor*math.Max(avg, 1) {
+		return "", false
+	}
+	return fmt.Sprintf("%d payments in %s, against an average of %.1f", recent, d.Window, avg), true
+}
+
+func paymentStats(ctx context.Context, db pg.DB, accountID string, out *query.AnnotatedOutput, height uint64) (stats, error) {
+	const q = `
+		SELECT COUNT(*), COALESCE(AVG(out.amount), 0)::float8, COALESCE(STDDEV_POP(out.amount), 0)::float8
+		FROM annotated_outputs AS out
+		WHERE out.block_height < $3 AND out.asset_id = $2
+			AND out.purpose <> 'change' AND out.account_id IS DISTINCT FROM $1
+			AND EXISTS (SELECT 1 FROM annotated_inputs AS inp
+				WHERE inp.tx_hash = out.tx_hash AND inp.account_id = $1)
+	`
+	var s stats
+	err := db.QueryRow(ctx, q, accountID, out.AssetID, height).Scan(&s.n, &s.mean, &s.stddev)
+	return s, errors.Wrap(err, "payment stats query")
+}
+
+func knownCounterparty(ctx context.Context, db pg.DB, accountID string, out *query.AnnotatedOutput, height uint64) (bool, error) {
+	const q = `
+		SELECT EXISTS (SELECT 1 FROM annotated_outputs AS out
+			WHERE out.block_height < $2 AND out.purpose <> 'change'
+				AND CASE WHEN $3 <> '' THEN out.account_id = $3 ELSE out.control_program = $4 END
+				AND EXISTS (SELECT 1 FROM annotated_inputs AS inp
+					WHERE inp.tx_hash = out.tx_hash AND inp.account_id = $1))
+	`
+	var known bool
+	err := db.QueryRow(ctx, q, accountID, height, out.AccountID, []byte(out.ControlProgram)).Scan(&known)
+	return known, errors.Wrap(err, "counterparty query")
+}
+
+// paymentCounts returns the number of payment transactions the
+// account made in the window ending at tx, and in the baseline
+// windows before that.
+func (d *Detector) paymentCounts(ctx context.Context, db pg.DB, accountID string, tx *query.AnnotatedTx) (recent, base int, err error) {
+	const q = `
+		SELECT COUNT(*) FILTER (WHERE txs.timestamp > $2),
+			COUNT(*) FILTER (WHERE txs.timestamp <= $2)
+		FROM annotated_txs AS txs
+		WHERE txs.timestamp > $3 AND txs.timestamp <= $4
+			AND (txs.block_height, txs.tx_pos) <= ($5, $6)
+			AND EXISTS (SELECT 1 FROM annotated_inputs AS inp
+				WHERE inp.tx_hash = txs.tx_hash AND inp.account_id = $1)
+	`
+	windowStart := tx.Timestamp.Add(-d.Window)
+	baseStart := windowStart.Add(-time.Duration(d.Baseline) * d.Window)
+	err = db.QueryRow(ctx, q, accountID, windowStart, baseStart, tx.Timestamp, tx.BlockHeight, tx.Position).Scan(&recent, &base)
+	return recent, base, errors.Wrap(err, "payment velocity query")
+}
+
+// spenders returns the accounts tx spends from.
+func spenders(tx *query.AnnotatedTx) []string {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, in := range tx.Inputs {
+		if in.AccountID != "" && !seen[in.AccountID] {
+			seen[in.AccountID] = true
+			ids = append(ids, in.AccountID)
+		}
+	}
+	return ids
+}
+
+func counterparty(out *query.AnnotatedOutput) string {
+	if out.AccountID != "" {
+		return "account " + out.AccountID
+	}
+	return fmt.Sprintf("control program %x", []byte(out.ControlProgram))
+}
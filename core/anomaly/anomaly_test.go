@@ -0,0 +1,61 @@
+package anomaly
+
+import (
+	"testing"
+
+	"github.com/chainmint/core/query"
+)
+
+func TestUnusualAmount(t *testing.T) {
+	d := NewDetector()
+	cases := []struct {
+		amount uint64
+		s      stats
+		want   bool
+	}{
+		{100, stats{n: 20, mean: 100, stddev: 10}, false},
+		{140, stats{n: 20, mean: 100, stddev: 10}, false},
+		{141, stats{n: 20, mean: 100, stddev: 10}, true},
+		{500, stats{n: 20, mean: 100, stddev: 0}, false},
+		{501, stats{n: 20, mean: 100, stddev: 0}, true},
+	}
+	for _, c := range cases {
+		_, got := d.unusualAmount(c.amount, c.s)
+		if got != c.want {
+			t.Errorf("unusualAmount(%d, %+v) = %v, want %v", c.amount, c.s, got, c.want)
+		}
+	}
+}
+
+func TestVelocitySpike(t *testing.T) {
+	d := NewDetector()
+	cases := []struct {
+		recent, base int
+		want         bool
+	}{
+		{4, 0, false},    // below MinVelocity
+		{5, 0, false},    // not above 5x the floor of 1
+		{6, 0, true},     // quiet account suddenly busy
+		{50, 240, false}, // 10 per window on average
+		{51, 240, true},
+	}
+	for _, c := range cases {
+		_, got := d.velocitySpike(c.recent, c.base)
+		if got != c.want {
+			t.Errorf("velocitySpike(%d, %d) = %v, want %v", c.recent, c.base, got, c.want)
+		}
+	}
+}
+
+func TestSpenders(t *testing.T) {
+	tx := &query.AnnotatedTx{Inputs: []*query.AnnotatedInput{
+		{AccountID: "acc1"},
+		{},
+		{AccountID: "acc2"},
+		{AccountID: "acc1"},
+	}}
+	got := spenders(tx)
+	if len(got) != 2 || got[0] != "acc1" || got[1] != "acc2" {
+		t.Errorf("spenders = %v, want [acc1 acc2]", got)
+	}
+}
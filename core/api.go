@@ -16,11 +16,13 @@ import (
 	"github.com/chainmint/core/accesstoken"
 	"github.com/chainmint/core/account"
 	"github.com/chainmint/core/asset"
+	"github.com/chainmint/core/blockproc"
 	"github.com/chainmint/core/config"
 	"github.com/chainmint/core/fetch"
 	"github.com/chainmint/core/generator"
 	"github.com/chainmint/core/leader"
 	"github.com/chainmint/core/pin"
+	"github.com/chainmint/core/pubsub"
 	"github.com/chainmint/core/query"
 	"github.com/chainmint/core/rpc"
 	"github.com/chainmint/core/txbuilder"
@@ -39,13 +41,16 @@ import (
 	"github.com/chainmint/net/http/limit"
 	"github.com/chainmint/net/http/static"
 	"github.com/chainmint/protocol"
+	"github.com/chainmint/protocol/bc"
 	"github.com/chainmint/protocol/bc/legacy"
 	rpcClient "github.com/tendermint/tendermint/rpc/lib/client"
+	"golang.org/x/net/websocket"
 	//"github.com/chainmint/app"
 )
 
 const (
 	defGenericPageSize = 100
+	maxPageSize        = 1000
 )
 
 // TODO(kr): change this to "crosscore" or something.
@@ -70,23 +75,45 @@ type API struct {
 	config          *config.Config
 	submitter       txbuilder.Submitter
 	db              pg.DB
+	dbURL           string
 	client rpcClient.HTTPClient
 	mux             *http.ServeMux
 	handler         http.Handler
 	leader          leaderProcess
+	failoverLead    func(context.Context) error
 	addr            string
 	signer          func(context.Context, *legacy.Block) ([]byte, error)
 	requestLimits   []requestLimit
 	generator       *generator.Generator
 	remoteGenerator *rpc.Client
 	indexTxs        bool
+	feeAsset        bc.AssetID
+	slowQueries     *slowQueryLog
+	prewarm         []string
+	scrubPeriod     time.Duration
+	replayPeriod    time.Duration
+	fees            *feeTracker
+	retention       RetentionPolicy
+	prunePeriod     time.Duration
+	caches          *cacheSizes
+	detectors       []query.Detector
+	processors      *blockproc.Set
+	events          *pubsub.Broker
+	exportState     StateExporter
 	useTLS          bool
 	internalSubj    pkix.Name
 	httpClient      *http.Client
+	peerTLS         *PeerTLS
+	peerHandler     http.Handler
+	mockHSM         bool
+	txSigner        txbuilder.Signer
 
 	downloadingSnapshotMu sync.Mutex
 	downloadingSnapshot   *fetch.SnapshotProgress
 
+	reconciledMu sync.Mutex
+	reconciled   *Reconciliation
+
 	healthMu     sync.Mutex
 	healthErrors map[string]string
 }
@@ -103,6 +130,39 @@ func (a *API) HttpClient() *http.Client {
 	return a.httpClient
 }
 
+// Indexer returns the indexer that answers queries
+// about accounts, assets, outputs and transactions.
+func (a *API) Indexer() *query.Indexer {
+	return a.indexer
+}
+
+// PinStore returns the store of the heights through which
+// each of the Core's block processors is up to date.
+func (a *API) PinStore() *pin.Store {
+	return a.pinStore
+}
+
+// FeeAsset returns the asset in which transaction fees are paid.
+func (a *API) FeeAsset() bc.AssetID {
+	return a.feeAsset
+}
+
+// SetStateExporter installs the function that answers
+// /export-staking-state.
+func (a *API) SetStateExporter(f StateExporter) {
+	a.exportState = f
+}
+
+// Store returns the store of blocks and state snapshots.
+func (a *API) Store() *txdb.Store {
+	return a.store
+}
+
+// DB returns the database used by the Core.
+func (a *API) DB() pg.DB {
+	return a.db
+}
+
 func (a *API) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	a.handler.ServeHTTP(rw, req)
 }
@@ -142,6 +202,7 @@ func (a *API) needConfig() func(f interface{}) http.Handler {
 // buildHandler adds the Core API routes to a preexisting http handler.
 func (a *API) buildHandler() {
 	needConfig := a.needConfig()
+	needReconciled := a.needReconciled
 
 	resetAllowed := func(h http.Handler) http.Handler { return alwaysError(errNoReset) }
 	if config.BuildConfig.Reset {
@@ -151,14 +212,21 @@ func (a *API) buildHandler() {
 	m := a.mux
 	m.Handle("/", alwaysError(errNotFound))
 
-	m.Handle("/create-account", needConfig(a.createAccount))
-	m.Handle("/create-asset", needConfig(a.createAsset))
-	m.Handle("/update-account-tags", needConfig(a.updateAccountTags))
-	m.Handle("/update-asset-tags", needConfig(a.updateAssetTags))
-	m.Handle("/build-transaction", needConfig(a.build))
-	m.Handle("/submit-transaction", needConfig(a.submit))
-	m.Handle("/create-control-program", needConfig(a.createControlProgram)) // DEPRECATED
-	m.Handle("/create-account-receiver", needConfig(a.createAccountReceiver))
+	m.Handle("/create-account", needReconciled(needConfig(a.createAccount)))
+	m.Handle("/create-asset", needReconciled(needConfig(a.createAsset)))
+	m.Handle("/update-account-tags", needReconciled(needConfig(a.updateAccountTags)))
+	m.Handle("/update-asset-tags", needReconciled(needConfig(a.updateAssetTags)))
+	m.Handle("/build-transaction", needReconciled(needConfig(a.build)))
+	m.Handle("/submit-transaction", needReconciled(needConfig(a.submit)))
+	m.Handle("/submit-batch", needReconciled(needConfig(a.submitBatch)))
+	m.Handle("/issue-asset", needReconciled(needConfig(a.issueAsset)))
+	m.Handle("/retire-asset", needReconciled(needConfig(a.retireAsset)))
+	m.Handle("/merge-transaction-templates", needConfig(a.mergeTemplates))
+	m.Handle("/combine-transaction-templates", needConfig(a.combineTemplates))
+	m.Handle("/sign-transaction", needConfig(a.signTemplates))
+	m.Handle("/decode-tx", needConfig(a.decodeTx))
+	m.Handle("/create-control-program", needReconciled(needConfig(a.createControlProgram))) // DEPRECATED
+	m.Handle("/create-account-receiver", needReconciled(needConfig(a.createAccountReceiver)))
 	m.Handle("/create-transaction-feed", needConfig(a.createTxFeed))
 	m.Handle("/get-transaction-feed", needConfig(a.getTxFeed))
 	m.Handle("/update-transaction-feed", needConfig(a.updateTxFeed))
@@ -170,16 +238,53 @@ func (a *API) buildHandler() {
 	m.Handle("/list-transactions", needConfig(a.listTransactions))
 	m.Handle("/list-balances", needConfig(a.listBalances))
 	m.Handle("/list-unspent-outputs", needConfig(a.listUnspentOutputs))
+	m.Handle("/list-reservations", needConfig(a.listReservations))
+	m.Handle("/cancel-reservations", needConfig(a.cancelReservations))
+	m.Handle("/list-escrows", needConfig(a.listEscrows))
+	m.Handle("/get-block", needConfig(a.getBlock))
+	m.Handle("/get-transaction", needConfig(a.getTransaction))
+	m.Handle("/get-raw-block", needConfig(a.getRawBlock))
+	m.Handle("/get-raw-transaction", needConfig(a.getRawTransaction))
+	m.Handle("/get-account-balances", needConfig(a.getAccountBalances))
+	m.Handle("/create-category-rule", needConfig(a.createCategoryRule))
+	m.Handle("/list-category-rules", needConfig(a.listCategoryRules))
+	m.Handle("/delete-category-rule", needConfig(a.deleteCategoryRule))
+	m.Handle("/list-category-totals", needConfig(a.listCategoryTotals))
+	m.Handle("/list-transaction-flags", needConfig(a.listTxFlags))
+	m.Handle("/index-stats", needConfig(a.indexStats))
+	m.Handle("/export-staking-state", needConfig(a.exportStakingState))
+	m.Handle("/export-utxo-set", http.HandlerFunc(a.exportUTXOSet))
+	m.Handle("/backup", http.HandlerFunc(a.backup))
+	m.Handle("/replay-block", needConfig(a.replayBlock))
+	m.Handle("/simulate-transaction", needConfig(a.simulateTx))
+	m.Handle("/diff-state", needConfig(a.diffState))
+	m.Handle("/get-issuance-nonces", needConfig(a.getIssuanceNonces))
 	m.Handle("/reset", resetAllowed(needConfig(a.reset)))
-
-	m.Handle(crosscoreRPCPrefix+"submit", needConfig(func(ctx context.Context, tx *legacy.Tx) error {
+	m.Handle("/rollback", needConfig(a.rollback))
+	m.Handle("/verify-replay", needConfig(a.verifyReplay))
+	m.Handle("/estimate-fee", needConfig(a.estimateFee))
+	m.Handle("/pause-block-production", needConfig(a.pauseBlockProduction))
+	m.Handle("/resume-block-production", needConfig(a.resumeBlockProduction))
+	m.Handle("/set-log-levels", jsonHandler(a.setLogLevels))
+	m.Handle("/list-pending-transactions", needConfig(a.listPendingTxs))
+	m.Handle("/evict-pending-transaction", needConfig(a.evictPendingTx))
+	m.Handle("/subscribe", websocket.Handler(a.serveEvents))
+
+	// With peer TLS, inter-node RPC is served only by ServePeers, to
+	// authenticated peers.
+	peers := m
+	if a.peerTLS != nil {
+		peers = http.NewServeMux()
+		m.Handle(crosscoreRPCPrefix, alwaysError(errPeerTLSRequired))
+	}
+	peers.Handle(crosscoreRPCPrefix+"submit", needReconciled(needConfig(func(ctx context.Context, tx *legacy.Tx) error {
 		return a.submitter.Submit(ctx, tx)
-	}))
-	m.Handle(crosscoreRPCPrefix+"get-block", needConfig(a.getBlockRPC))
-	m.Handle(crosscoreRPCPrefix+"get-snapshot-info", needConfig(a.getSnapshotInfoRPC))
-	m.Handle(crosscoreRPCPrefix+"get-snapshot", http.HandlerFunc(a.getSnapshotRPC))
-	m.Handle(crosscoreRPCPrefix+"signer/sign-block", needConfig(a.leaderSignHandler(a.signer)))
-	m.Handle(crosscoreRPCPrefix+"block-height", needConfig(func(ctx context.Context) map[string]uint64 {
+	})))
+	peers.Handle(crosscoreRPCPrefix+"get-block", needConfig(a.getBlockRPC))
+	peers.Handle(crosscoreRPCPrefix+"get-snapshot-info", needConfig(a.getSnapshotInfoRPC))
+	peers.Handle(crosscoreRPCPrefix+"get-snapshot", http.HandlerFunc(a.getSnapshotRPC))
+	peers.Handle(crosscoreRPCPrefix+"signer/sign-block", needConfig(a.leaderSignHandler(a.signer)))
+	peers.Handle(crosscoreRPCPrefix+"block-height", needConfig(func(ctx context.Context) map[string]uint64 {
 		h := a.chain.Height()
 		return map[string]uint64{
 			"block_height": h,
@@ -195,6 +300,7 @@ func (a *API) buildHandler() {
 	//m.Handle("/add-allowed-member", jsonHandler(a.addAllowedMember))
 	m.Handle("/configure", jsonHandler(a.configure))
 	m.Handle("/info", jsonHandler(a.info))
+	m.Handle("/info/capabilities", jsonHandler(a.getCapabilities))
 
 	m.Handle("/debug/vars", expvar.Handler())
 	m.Handle("/debug/pprof/", http.HandlerFunc(pprof.Index))
@@ -222,6 +328,12 @@ func (a *API) buildHandler() {
 		handler = blockchainIDHandler(handler, a.config.BlockchainId.String())
 	}
 	a.handler = handler
+
+	if a.peerTLS != nil {
+		handler = a.peerTLS.handler(maxBytes(peers))
+		handler = coreCounter(handler)
+		a.peerHandler = timeoutContextHandler(handler)
+	}
 }
 
 // Used as a request object for api queries
@@ -245,6 +357,14 @@ type requestQuery struct {
 	StartTimeMS uint64 `json:"start_time,omitempty"`
 	EndTimeMS   uint64 `json:"end_time,omitempty"`
 
+	// Category restricts /list-transactions to transactions
+	// tagged with a category by a category rule.
+	Category string `json:"category,omitempty"`
+
+	// AccountID restricts /list-transaction-flags to the flags
+	// raised for one account.
+	AccountID string `json:"account_id,omitempty"`
+
 	// This is used for point-in-time queries like /list-balances
 	// TODO(bobg): Different request structs for endpoints with different needs
 	TimestampMS uint64 `json:"timestamp,omitempty"`
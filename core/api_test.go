@@ -184,6 +184,73 @@ func TestAccountTransfer(t *testing.T) {
 	}
 }
 
+func TestProcessBlocksUnindexed(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := prottest.NewChain(t)
+	g := generator.New(c, nil, db)
+	pinStore := pin.NewStore(db)
+	a := &API{
+		chain:    c,
+		pinStore: pinStore,
+		assets:   asset.NewRegistry(db, c, pinStore),
+		accounts: account.NewManager(db, c, pinStore),
+		db:       db,
+	}
+	err := a.processBlocks(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acc := coretest.CreateAccount(ctx, t, a.accounts, "", nil)
+	assetID := coretest.CreateAsset(ctx, t, a.assets, nil, "", nil)
+	assetAmt := bc.AssetAmount{
+		AssetId: &assetID,
+		Amount:  100,
+	}
+
+	sources := txbuilder.Action(a.assets.NewIssueAction(assetAmt, nil))
+	dests := a.accounts.NewControlAction(assetAmt, acc, nil)
+	tmpl, err := txbuilder.Build(ctx, nil, []txbuilder.Action{sources, dests}, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	coretest.SignTxTemplate(t, ctx, tmpl, &testutil.TestXPrv)
+	err = txbuilder.FinalizeTx(ctx, c, g, tmpl.Transaction)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prottest.MakeBlock(t, c, g.PendingTxs())
+
+	// Without the tx indexer, the account pin must still advance,
+	// so that the account's new UTXO can be spent.
+	select {
+	case <-pinStore.PinWaiter(account.PinName, c.Height()):
+	case <-time.After(10 * time.Second):
+		t.Fatalf("account pin did not reach height %d", c.Height())
+	}
+
+	sources = a.accounts.NewSpendAction(assetAmt, acc, nil, nil)
+	tmpl, err = txbuilder.Build(ctx, nil, []txbuilder.Action{sources, dests}, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	coretest.SignTxTemplate(t, ctx, tmpl, &testutil.TestXPrv)
+	err = txbuilder.FinalizeTx(ctx, c, g, tmpl.Transaction)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prottest.MakeBlock(t, c, g.PendingTxs())
+
+	// Nor may deleting spent UTXOs wait on the missing tx pin.
+	select {
+	case <-pinStore.PinWaiter(account.DeleteSpentsPinName, c.Height()):
+	case <-time.After(10 * time.Second):
+		t.Fatalf("delete-spents pin did not reach height %d", c.Height())
+	}
+}
+
 func TestMux(t *testing.T) {
 	// Handler calls handleJSON, which panics
 	// if the function signature is not of the right form.
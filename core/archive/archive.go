@@ -0,0 +1,332 @@
+// Package archive keeps committed blocks, and so their transactions,
+// in compact append-only files, for explorer-style lookups of history
+// the Core's database need not keep once pruned.
+//
+// The archive is split into partitions of PartitionBlocks blocks,
+// each a directory named by the height of its first block, holding
+// three files:
+//
+//	blocks.gz  each block, serialized and gzip-compressed on its own
+//	offsets    for each block, the ends of its data in blocks.gz
+//	           and of its records in txs, as two big-endian uint64s
+//	txs        for each transaction, its ID, block height and
+//	           position in the block, as 32, 8 and 4 bytes
+//
+// A block is archived by appending to blocks.gz and txs, then to
+// offsets, so a block whose offsets entry is missing was not
+// archived, and Open truncates what was written for it.
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+// PartitionBlocks is the number of blocks in each partition.
+const PartitionBlocks = 10000
+
+const (
+	offsetSize = 16
+	txSize     = 32 + 8 + 4
+)
+
+var (
+	// ErrNotArchived is returned for a block or transaction not in
+	// the archive.
+	ErrNotArchived = errors.New("not archived")
+
+	// ErrGap is returned by Append for a block that does not
+	// follow the last one archived.
+	ErrGap = errors.New("block does not follow the archive")
+)
+
+// An Archive is a directory of archived blocks. Its methods are safe
+// for concurrent use.
+type Archive struct {
+	dir string
+
+	mu     sync.RWMutex
+	height uint64 // of the last block archived
+	ends   offset // of the last block archived in its partition
+}
+
+type offset struct {
+	blocks, txs uint64
+}
+
+// Open opens the archive in dir, creating dir if need be, and
+// discards anything written for a block whose archiving did not
+// finish.
+func Open(dir string) (*Archive, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating archive directory")
+	}
+	a := &Archive{dir: dir}
+	first, err := a.lastPartition()
+	if err != nil || first == 0 {
+		return a, err
+	}
+	p := a.partition(first)
+	offsets, err := ioutil.ReadFile(filepath.Join(p, "offsets"))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading offsets")
+	}
+	n := len(offsets) / offsetSize
+	if n > 0 {
+		a.height = first + uint64(n) - 1
+		a.ends = decodeOffset(offsets[(n-1)*offsetSize:])
+	} else if first > 1 {
+		// Nothing was archived in this partition; the last
+		// block archived ended the one before.
+		a.height = first - 1
+	}
+	for name, size := range map[string]uint64{
+		"offsets":   uint64(n * offsetSize),
+		"blocks.gz": a.ends.blocks,
+		"txs":       a.ends.txs,
+	} {
+		err = truncate(filepath.Join(p, name), size)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// Height returns the height of the last block archived, or 0 if
+// there is none.
+func (a *Archive) Height() uint64 {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.height
+}
+
+// Append archives b, which must follow the last block archived.
+// Appending a block already archived does nothing.
+func (a *Archive) Append(b *legacy.Block) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if b.Height <= a.height {
+		return nil
+	}
+	if b.Height != a.height+1 {
+		return errors.WithDetailf(ErrGap, "block %d, archive height %d", b.Height, a.height)
+	}
+
+	first := partitionStart(b.Height)
+	ends := a.ends
+	if first == b.Height {
+		ends = offset{}
+	}
+	p := a.partition(first)
+	err := os.MkdirAll(p, 0700)
+	if err != nil {
+		return errors.Wrap(err, "creating partition")
+	}
+
+	data, err := b.Value()
+	if err != nil {
+		return errors.Wrap(err, "serializing block")
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data.([]byte))
+	err = zw.Close()
+	if err != nil {
+		return errors.Wrap(err, "compressing block")
+	}
+	err = appendFile(filepath.Join(p, "blocks.gz"), buf.Bytes())
+	if err != nil {
+		return err
+	}
+	ends.blocks += uint64(buf.Len())
+
+	buf.Reset()
+	for i, tx := range b.Transactions {
+		buf.Write(tx.ID.Bytes())
+		binary.Write(&buf, binary.BigEndian, b.Height)
+		binary.Write(&buf, binary.BigEndian, uint32(i))
+	}
+	err = appendFile(filepath.Join(p, "txs"), buf.Bytes())
+	if err != nil {
+		return err
+	}
+	ends.txs += uint64(buf.Len())
+
+	var entry [offsetSize]byte
+	binary.BigEndian.PutUint64(entry[:8], ends.blocks)
+	binary.BigEndian.PutUint64(entry[8:], ends.txs)
+	err = appendFile(filepath.Join(p, "offsets"), entry[:])
+	if err != nil {
+		return err
+	}
+	a.height, a.ends = b.Height, ends
+	return nil
+}
+
+// ProcessBlock archives b. It makes the archive a block processor;
+// see package blockproc.
+func (a *Archive) ProcessBlock(ctx context.Context, b *legacy.Block) error {
+	return a.Append(b)
+}
+
+// Block returns the archived block at height.
+func (a *Archive) Block(height uint64) (*legacy.Block, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if height == 0 || height > a.height {
+		return nil, errors.WithDetailf(ErrNotArchived, "block %d", height)
+	}
+	first := partitionStart(height)
+	p := a.partition(first)
+	f, err := os.Open(filepath.Join(p, "offsets"))
+	if err != nil {
+		return nil, errors.Wrap(err, "opening offsets")
+	}
+	defer f.Close()
+
+	var start, end offset
+	i := int64(height - first)
+	var entry [offsetSize]byte
+	if i > 0 {
+		_, err = f.ReadAt(entry[:], (i-1)*offsetSize)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading offsets")
+		}
+		start = decodeOffset(entry[:])
+	}
+	_, err = f.ReadAt(entry[:], i*offsetSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading offsets")
+	}
+	end = decodeOffset(entry[:])
+
+	blocks, err := os.Open(filepath.Join(p, "blocks.gz"))
+	if err != nil {
+		return nil, errors.Wrap(err, "opening blocks")
+	}
+	defer blocks.Close()
+	zr, err := gzip.NewReader(io.NewSectionReader(blocks, int64(start.blocks), int64(end.blocks-start.blocks)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "decompressing block %d", height)
+	}
+	data, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "decompressing block %d", height)
+	}
+	b := new(legacy.Block)
+	err = b.Scan(data)
+	return b, errors.Wrapf(err, "decoding block %d", height)
+}
+
+// Tx returns the archived transaction with the given ID, and the
+// height of its block. It scans the transaction records of every
+// partition, newest first.
+func (a *Archive) Tx(id bc.Hash) (*legacy.Tx, uint64, error) {
+	a.mu.RLock()
+	height := a.height
+	a.mu.RUnlock()
+
+	want := id.Bytes()
+	for first := partitionStart(height); first > 0; first = partitionStart(first - 1) {
+		txs, err := ioutil.ReadFile(filepath.Join(a.partition(first), "txs"))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, 0, errors.Wrap(err, "reading transaction records")
+		}
+		for rec := txs; len(rec) >= txSize; rec = rec[txSize:] {
+			if !bytes.Equal(rec[:32], want) {
+				continue
+			}
+			h := binary.BigEndian.Uint64(rec[32:40])
+			pos := binary.BigEndian.Uint32(rec[40:44])
+			b, err := a.Block(h)
+			if err != nil {
+				return nil, 0, err
+			}
+			if int(pos) >= len(b.Transactions) {
+				return nil, 0, fmt.Errorf("archived block %d has no transaction %d", h, pos)
+			}
+			return b.Transactions[pos], h, nil
+		}
+	}
+	return nil, 0, errors.WithDetailf(ErrNotArchived, "transaction %x", want)
+}
+
+func (a *Archive) partition(first uint64) string {
+	return filepath.Join(a.dir, fmt.Sprintf("%012d", first))
+}
+
+// lastPartition returns the first height of the newest partition,
+// or 0 if there is none.
+func (a *Archive) lastPartition() (uint64, error) {
+	names, err := ioutil.ReadDir(a.dir)
+	if err != nil {
+		return 0, errors.Wrap(err, "listing archive")
+	}
+	var firsts []uint64
+	for _, fi := range names {
+		first, err := strconv.ParseUint(fi.Name(), 10, 64)
+		if err == nil && fi.IsDir() {
+			firsts = append(firsts, first)
+		}
+	}
+	if len(firsts) == 0 {
+		return 0, nil
+	}
+	sort.Slice(firsts, func(i, j int) bool { return firsts[i] < firsts[j] })
+	return firsts[len(firsts)-1], nil
+}
+
+func partitionStart(height uint64) uint64 {
+	if height == 0 {
+		return 0
+	}
+	return (height-1)/PartitionBlocks*PartitionBlocks + 1
+}
+
+func decodeOffset(b []byte) offset {
+	return offset{
+		blocks: binary.BigEndian.Uint64(b[:8]),
+		txs:    binary.BigEndian.Uint64(b[8:16]),
+	}
+}
+
+func appendFile(name string, data []byte) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return errors.Wrap(err, "opening archive file")
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	return errors.Wrapf(err, "appending to %s", name)
+}
+
+func truncate(name string, size uint64) error {
+	err := os.Truncate(name, int64(size))
+	if os.IsNotExist(err) && size == 0 {
+		return nil
+	}
+	return errors.Wrapf(err, "truncating %s", name)
+}
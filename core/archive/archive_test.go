@@ -0,0 +1,98 @@
+package archive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+func testBlock(height uint64, ntxs int) *legacy.Block {
+	b := &legacy.Block{BlockHeader: legacy.BlockHeader{Version: 1, Height: height, TimestampMS: height * 1000}}
+	for i := 0; i < ntxs; i++ {
+		b.Transactions = append(b.Transactions, legacy.NewTx(legacy.TxData{
+			Version:       1,
+			ReferenceData: []byte{byte(height), byte(i)},
+		}))
+	}
+	return b
+}
+
+func TestArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var blocks []*legacy.Block
+	for h := uint64(1); h <= 3; h++ {
+		b := testBlock(h, int(h)-1)
+		blocks = append(blocks, b)
+		err = a.Append(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = a.Append(blocks[1]); err != nil {
+		t.Errorf("re-appending block 2: %v", err)
+	}
+	if err = a.Append(testBlock(5, 0)); errors.Root(err) != ErrGap {
+		t.Errorf("appending block 5 = %v, want %v", err, ErrGap)
+	}
+
+	check := func(a *Archive) {
+		if a.Height() != 3 {
+			t.Errorf("Height = %d, want 3", a.Height())
+		}
+		for _, want := range blocks {
+			got, err := a.Block(want.Height)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Hash() != want.Hash() || len(got.Transactions) != len(want.Transactions) {
+				t.Errorf("block %d differs from the one archived", want.Height)
+			}
+		}
+		want := blocks[2].Transactions[1]
+		tx, height, err := a.Tx(want.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tx.ID != want.ID || height != 3 {
+			t.Errorf("Tx = %x at %d, want %x at 3", tx.ID.Bytes(), height, want.ID.Bytes())
+		}
+		if _, err := a.Block(4); errors.Root(err) != ErrNotArchived {
+			t.Errorf("Block(4) = %v, want %v", err, ErrNotArchived)
+		}
+	}
+	check(a)
+
+	// Reopening discards a block whose archiving did not finish.
+	p := a.partition(1)
+	for _, name := range []string{"blocks.gz", "txs"} {
+		err = appendFile(filepath.Join(p, name), []byte("torn"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(a)
+	err = a.Append(testBlock(4, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Block(4); err != nil {
+		t.Errorf("Block(4) after reopening: %v", err)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"sync"
+	"time"
 
 	"golang.org/x/crypto/sha3"
 
@@ -52,6 +53,10 @@ type Registry struct {
 	initialBlockHash bc.Hash
 	pinStore         *pin.Store
 
+	// minTimeSlack is how far before now the issuances built by
+	// the registry begin.
+	minTimeSlack time.Duration
+
 	idGroup    singleflight.Group
 	aliasGroup singleflight.Group
 
@@ -64,6 +69,19 @@ func (reg *Registry) IndexAssets(indexer Saver) {
 	reg.indexer = indexer
 }
 
+// SetMinTimeSlack sets how far before now the issuances built by
+// the registry begin, so that they are valid in blocks timestamped
+// by nodes whose clocks are behind.
+func (reg *Registry) SetMinTimeSlack(d time.Duration) {
+	reg.minTimeSlack = d
+}
+
+// MinTimeSlack returns how far before now the issuances built by the
+// registry begin.
+func (reg *Registry) MinTimeSlack() time.Duration {
+	return reg.minTimeSlack
+}
+
 type Asset struct {
 	AssetID          bc.AssetID
 	Alias            *string
@@ -62,6 +62,12 @@ func (a *issueAction) Build(ctx context.Context, builder *txbuilder.TemplateBuil
 	path := signers.Path(asset.Signer, signers.AssetKeySpace)
 	tplIn.AddWitnessKeys(asset.Signer.XPubs, path, asset.Signer.Quorum)
 
-	builder.RestrictMinTime(time.Now())
+	// The issuance's time range, which protects its nonce from
+	// replay, must fit within the network's issuance window.
+	minTime := time.Now().Add(-a.assets.minTimeSlack)
+	builder.RestrictMinTime(minTime)
+	if w := a.assets.chain.MaxIssuanceWindow; w > 0 {
+		builder.RestrictMaxTime(minTime.Add(w))
+	}
 	return builder.AddInput(txin, tplIn)
 }
@@ -8,6 +8,7 @@ var Policies = []string{
 	"crosscore",
 	"crosscore-signblock",
 	"monitoring",
+	"operator",
 	"internal",
 	"public",
 }
@@ -19,6 +20,9 @@ var policyByRoute = map[string][]string{
 	"/update-asset-tags":        {"client-readwrite"},
 	"/build-transaction":        {"client-readwrite"},
 	"/submit-transaction":       {"client-readwrite"},
+	"/submit-batch":             {"client-readwrite"},
+	"/issue-asset":              {"client-readwrite"},
+	"/retire-asset":             {"client-readwrite"},
 	"/create-control-program":   {"client-readwrite"},
 	"/create-account-receiver":  {"client-readwrite"},
 	"/create-transaction-feed":  {"client-readwrite"},
@@ -31,6 +35,7 @@ var policyByRoute = map[string][]string{
 	"/mockhsm/list-keys":        {"client-readwrite", "client-readonly"},
 	"/mockhsm/delkey":           {"client-readwrite"},
 	"/mockhsm/sign-transaction": {"client-readwrite"},
+	"/sign-transaction":         {"client-readwrite"},
 
 	"/list-accounts":          {"client-readwrite", "client-readonly"},
 	"/list-assets":            {"client-readwrite", "client-readonly"},
@@ -38,8 +43,31 @@ var policyByRoute = map[string][]string{
 	"/list-transactions":      {"client-readwrite", "client-readonly"},
 	"/list-balances":          {"client-readwrite", "client-readonly"},
 	"/list-unspent-outputs":   {"client-readwrite", "client-readonly"},
+	"/list-reservations":      {"client-readwrite", "client-readonly"},
+	"/cancel-reservations":    {"client-readwrite"},
+	"/get-block":              {"client-readwrite", "client-readonly"},
+	"/get-transaction":        {"client-readwrite", "client-readonly"},
+	"/get-raw-block":          {"client-readwrite", "client-readonly"},
+	"/get-raw-transaction":    {"client-readwrite", "client-readonly"},
+	"/get-issuance-nonces":    {"client-readwrite", "client-readonly"},
+	"/simulate-transaction":   {"client-readwrite", "client-readonly"},
+	"/estimate-fee":           {"client-readwrite", "client-readonly"},
+	"/get-account-balances":   {"client-readwrite", "client-readonly"},
+	"/create-category-rule":   {"client-readwrite"},
+	"/list-category-rules":    {"client-readwrite", "client-readonly"},
+	"/delete-category-rule":   {"client-readwrite"},
+	"/list-category-totals":   {"client-readwrite", "client-readonly"},
 	"/reset":                  {"client-readwrite", "internal"},
 
+	"/rollback":                      {"operator", "internal"},
+	"/set-log-levels":                {"operator", "internal"},
+	"/verify-replay":                 {"operator", "internal"},
+	"/diff-state":                    {"operator", "internal"},
+	"/merge-transaction-templates":   {"client-readwrite"},
+	"/combine-transaction-templates": {"client-readwrite"},
+	"/subscribe":                     {"client-readwrite", "client-readonly"},
+	"/list-transaction-flags":        {"client-readwrite", "client-readonly"},
+
 	crosscoreRPCPrefix + "submit":            {"crosscore", "crosscore-signblock"},
 	crosscoreRPCPrefix + "get-block":         {"crosscore", "crosscore-signblock"},
 	crosscoreRPCPrefix + "get-snapshot-info": {"crosscore", "crosscore-signblock"},
@@ -56,6 +84,7 @@ var policyByRoute = map[string][]string{
 	"/add-allowed-member":         {"internal"},
 	"/configure":                  {"client-readwrite", "internal"},
 	"/info":                       {"client-readwrite", "client-readonly", "crosscore", "crosscore-signblock", "monitoring", "internal"},
+	"/info/capabilities":          {"client-readwrite", "client-readonly", "crosscore", "crosscore-signblock", "monitoring", "internal"},
 
 	"/debug/vars":          {"client-readwrite", "client-readonly", "monitoring"}, // should monitoring endpoints also be available to any other policy-holders?
 	"/debug/pprof":         {"client-readwrite", "client-readonly", "monitoring"},
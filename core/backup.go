@@ -0,0 +1,275 @@
+package core
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/chainmint/core/txdb"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/log"
+	"github.com/chainmint/protocol/state"
+)
+
+// backupVersion is the format version of the backups written by
+// writeBackup.
+const backupVersion = 1
+
+// The files of a backup, after its manifest.
+const (
+	backupManifestName = "MANIFEST"
+	backupDatabaseName = "database.dump"
+	backupSnapshotName = "snapshot"
+)
+
+var (
+	errBackupCorrupt = errors.New("backup does not match its manifest")
+	errNoBackup      = errors.New("core cannot back up its database")
+)
+
+// backupManifest describes a backup. It is the first file of the
+// backup, so that each file after it is checked as it is read.
+type backupManifest struct {
+	Version   int          `json:"version"`
+	CreatedAt time.Time    `json:"created_at"`
+	Height    uint64       `json:"height"`
+	Files     []backupFile `json:"files"`
+}
+
+type backupFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// backup streams a consistent backup of the Core's database, with
+// the state snapshot at the current height, while the Core keeps
+// running. The backup is a tar archive of a manifest, the database
+// as dumped by pg_dump, and the snapshot; the manifest records the
+// size and SHA-256 hash of each. See RestoreBackup.
+//
+// POST /backup
+func (a *API) backup(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	if a.dbURL == "" {
+		errorFormatter.Write(ctx, rw, errors.WithDetail(errNoBackup, "database URL unknown"))
+		return
+	}
+	b, snapshot := a.chain.State()
+	if b == nil {
+		errorFormatter.Write(ctx, rw, errors.WithDetail(errNoBackup, "no blocks yet"))
+		return
+	}
+
+	dir, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		errorFormatter.Write(ctx, rw, errors.Wrap(err, "creating backup directory"))
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	// The snapshot is taken first, so that the dump holds every
+	// block after it, and a restored Core recovers from it.
+	snapData, err := txdb.EncodeSnapshot(snapshot)
+	if err != nil {
+		errorFormatter.Write(ctx, rw, err)
+		return
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, backupSnapshotName), snapData, 0600)
+	if err != nil {
+		errorFormatter.Write(ctx, rw, errors.Wrap(err, "writing snapshot"))
+		return
+	}
+	err = dumpDatabase(ctx, a.dbURL, filepath.Join(dir, backupDatabaseName))
+	if err != nil {
+		errorFormatter.Write(ctx, rw, err)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/x-tar")
+	err = writeBackup(rw, dir, b.Height, []string{backupDatabaseName, backupSnapshotName})
+	if err != nil {
+		log.Error(ctx, err, "writing backup at height", b.Height)
+		return
+	}
+	log.Printkv(ctx, "at", "backed up database", "height", b.Height)
+}
+
+// dumpDatabase writes the database at dbURL to path, in pg_dump's
+// custom format. pg_dump reads the database in a single transaction,
+// so the dump is consistent while the Core writes to it.
+func dumpDatabase(ctx context.Context, dbURL, path string) error {
+	cmd := exec.CommandContext(ctx, "pg_dump",
+		"--format=custom", "--no-owner", "--no-privileges",
+		"--file="+path, "--dbname="+dbURL)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return errors.WithDetail(errors.Wrap(err, "pg_dump"), string(out))
+	}
+	return nil
+}
+
+// writeBackup writes a backup of the named files in dir, which
+// hold the state at height, to w.
+func writeBackup(w io.Writer, dir string, height uint64, names []string) error {
+	m := backupManifest{
+		Version:   backupVersion,
+		CreatedAt: time.Now().UTC(),
+		Height:    height,
+	}
+	for _, name := range names {
+		f, err := hashFile(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		f.Name = name
+		m.Files = append(m.Files, f)
+	}
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	tw := tar.NewWriter(w)
+	err = tw.WriteHeader(&tar.Header{Name: backupManifestName, Mode: 0600, Size: int64(len(manifest)), ModTime: m.CreatedAt})
+	if err != nil {
+		return errors.Wrap(err, "writing manifest")
+	}
+	_, err = tw.Write(manifest)
+	if err != nil {
+		return errors.Wrap(err, "writing manifest")
+	}
+	for _, bf := range m.Files {
+		err = tw.WriteHeader(&tar.Header{Name: bf.Name, Mode: 0600, Size: bf.Size, ModTime: m.CreatedAt})
+		if err != nil {
+			return errors.Wrapf(err, "writing %s", bf.Name)
+		}
+		f, err := os.Open(filepath.Join(dir, bf.Name))
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		_, err = io.Copy(tw, f)
+		f.Close()
+		if err != nil {
+			return errors.Wrapf(err, "writing %s", bf.Name)
+		}
+	}
+	return errors.Wrap(tw.Close(), "writing backup")
+}
+
+func hashFile(path string) (backupFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return backupFile{}, errors.Wrap(err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return backupFile{}, errors.Wrapf(err, "hashing %s", path)
+	}
+	return backupFile{Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// readBackup reads the backup in r into dir, checking each file
+// against the manifest, and returns the manifest. It returns
+// errBackupCorrupt if any file is missing, unexpected, or differs
+// from the manifest.
+func readBackup(r io.Reader, dir string) (*backupManifest, error) {
+	tr := tar.NewReader(r)
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, errors.Wrap(err, "reading manifest")
+	}
+	if hdr.Name != backupManifestName {
+		return nil, errors.WithDetailf(errBackupCorrupt, "first file is %q, not the manifest", hdr.Name)
+	}
+	var m backupManifest
+	err = json.NewDecoder(tr).Decode(&m)
+	if err != nil {
+		return nil, errors.WithDetail(errBackupCorrupt, err.Error())
+	}
+	if m.Version != backupVersion {
+		return nil, errors.WithDetailf(errBackupCorrupt, "unknown backup version %d", m.Version)
+	}
+
+	for _, want := range m.Files {
+		hdr, err = tr.Next()
+		if err == io.EOF {
+			return nil, errors.WithDetailf(errBackupCorrupt, "%s is missing", want.Name)
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "reading backup")
+		}
+		if hdr.Name != want.Name || filepath.Base(want.Name) != want.Name {
+			return nil, errors.WithDetailf(errBackupCorrupt, "found %q, want %q", hdr.Name, want.Name)
+		}
+		f, err := os.OpenFile(filepath.Join(dir, want.Name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		h := sha256.New()
+		n, err := io.Copy(io.MultiWriter(f, h), tr)
+		f.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading %s", want.Name)
+		}
+		if n != want.Size || hex.EncodeToString(h.Sum(nil)) != want.SHA256 {
+			return nil, errors.WithDetailf(errBackupCorrupt, "%s does not match its checksum", want.Name)
+		}
+	}
+	_, err = tr.Next()
+	if err != io.EOF {
+		return nil, errors.WithDetail(errBackupCorrupt, "unexpected files after those in the manifest")
+	}
+	return &m, nil
+}
+
+// RestoreBackup replaces the contents of the database at dbURL with
+// those of the backup in r, as written by POST /backup, and returns
+// the height of the backup and the state snapshot at it. Every file
+// is checked against the backup's manifest before anything in the
+// database is changed.
+//
+// Like Rollback, it must be called before the Core is started. The
+// caller saves the snapshot to the Core's store, so that the Core
+// recovers from it and replays the blocks after it.
+func RestoreBackup(ctx context.Context, dbURL string, r io.Reader) (uint64, *state.Snapshot, error) {
+	dir, err := ioutil.TempDir("", "restore")
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "creating restore directory")
+	}
+	defer os.RemoveAll(dir)
+
+	m, err := readBackup(r, dir)
+	if err != nil {
+		return 0, nil, err
+	}
+	snapData, err := ioutil.ReadFile(filepath.Join(dir, backupSnapshotName))
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "reading snapshot")
+	}
+	snapshot, err := txdb.DecodeSnapshot(snapData)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, "pg_restore",
+		"--clean", "--if-exists", "--no-owner", "--no-privileges", "--single-transaction",
+		"--dbname="+dbURL, filepath.Join(dir, backupDatabaseName))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return 0, nil, errors.WithDetail(errors.Wrap(err, "pg_restore"), string(out))
+	}
+	log.Printkv(ctx, "at", "restored database", "height", m.Height, "created_at", m.CreatedAt)
+	return m.Height, snapshot, nil
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chainmint/errors"
+)
+
+func TestBackupRoundTrip(t *testing.T) {
+	src, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(src)
+	files := map[string][]byte{
+		backupDatabaseName: []byte("database contents"),
+		backupSnapshotName: []byte("snapshot contents"),
+	}
+	for name, data := range files {
+		err = ioutil.WriteFile(filepath.Join(src, name), data, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	err = writeBackup(&buf, src, 7, []string{backupDatabaseName, backupSnapshotName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	backup := buf.Bytes()
+
+	dst, err := ioutil.TempDir("", "restore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dst)
+	m, err := readBackup(bytes.NewReader(backup), dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Height != 7 {
+		t.Errorf("height = %d want 7", m.Height)
+	}
+	for name, want := range files {
+		got, err := ioutil.ReadFile(filepath.Join(dst, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s = %q want %q", name, got, want)
+		}
+	}
+
+	// Flip a byte of the snapshot's contents.
+	corrupt := append([]byte(nil), backup...)
+	i := bytes.Index(corrupt, files[backupSnapshotName])
+	corrupt[i] ^= 0xff
+	dst2, err := ioutil.TempDir("", "restore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dst2)
+	_, err = readBackup(bytes.NewReader(corrupt), dst2)
+	if errors.Root(err) != errBackupCorrupt {
+		t.Errorf("corrupt backup: got error %v, want %v", err, errBackupCorrupt)
+	}
+}
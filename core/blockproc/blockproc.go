@@ -0,0 +1,175 @@
+// Package blockproc runs operator block processors: code that
+// follows the chain, processing each block after the app commits it
+// and the Core's own indexers have indexed it, such as to feed an
+// external database or notify another system.
+//
+// Each processor's progress is a pin, like the indexers', so every
+// block is processed at least once, in height order, and after a
+// restart processing resumes from the first block not yet processed.
+// A processor may so see a block more than once, and must tolerate
+// it. Operators add processors by registering them, either from a
+// package compiled into the node or from a Go plugin whose init
+// function calls Register.
+package blockproc
+
+import (
+	"context"
+	"plugin"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/chainmint/core/pin"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+// PinPrefix prefixes the name of a processor to name its pin.
+const PinPrefix = "processor-"
+
+var (
+	// ErrUnknownProcessor is returned by New when no processor has
+	// been registered under the requested name.
+	ErrUnknownProcessor = errors.New("unknown block processor")
+
+	// ErrDuplicateProcessor is returned by NewSet when a processor
+	// is given more than once.
+	ErrDuplicateProcessor = errors.New("duplicate block processor")
+)
+
+// A Processor processes committed blocks.
+type Processor interface {
+	// ProcessBlock processes b. If it returns an error, it is
+	// called again with b until it succeeds.
+	ProcessBlock(ctx context.Context, b *legacy.Block) error
+}
+
+// ProcessorFunc adapts a function to a Processor.
+type ProcessorFunc func(ctx context.Context, b *legacy.Block) error
+
+// ProcessBlock calls f(ctx, b).
+func (f ProcessorFunc) ProcessBlock(ctx context.Context, b *legacy.Block) error { return f(ctx, b) }
+
+// Constructor creates a processor from its argument, whose meaning
+// is up to the processor, such as the URL of a service it notifies.
+// The argument is empty if none was given.
+type Constructor func(arg string) (Processor, error)
+
+var (
+	mu       sync.Mutex
+	registry = make(map[string]Constructor)
+)
+
+func init() {
+	Register("log", newLog)
+	Register("archive", newArchive)
+}
+
+// Register makes a processor available under the given name.
+// It panics if a processor with that name is already registered.
+func Register(name string, ctor Constructor) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := registry[name]; ok {
+		panic("blockproc: duplicate registration of " + name)
+	}
+	registry[name] = ctor
+}
+
+// New creates a processor from spec, the name it is registered
+// under, optionally followed by "=" and its argument.
+func New(spec string) (Processor, error) {
+	name, arg := split(spec)
+	mu.Lock()
+	ctor, ok := registry[name]
+	mu.Unlock()
+	if !ok {
+		return nil, errors.WithDetailf(ErrUnknownProcessor, "processor %q (available: %v)", name, Names())
+	}
+	p, err := ctor(arg)
+	return p, errors.Wrapf(err, "creating processor %q", name)
+}
+
+// Names returns the names of all registered processors, sorted.
+func Names() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	var names []string
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Load opens the Go plugin at path, whose init functions register
+// the processors it provides.
+func Load(path string) error {
+	_, err := plugin.Open(path)
+	return errors.Wrapf(err, "loading block processor plugin %s", path)
+}
+
+// A Set is the processors a node runs.
+type Set struct {
+	names      []string
+	processors []Processor
+}
+
+// NewSet creates the processors given by specs; see New. Each
+// processor is named by its spec's name, so a processor may be
+// run only once.
+func NewSet(specs []string) (*Set, error) {
+	s := new(Set)
+	seen := make(map[string]bool)
+	for _, spec := range specs {
+		name, _ := split(spec)
+		if seen[name] {
+			return nil, errors.WithDetailf(ErrDuplicateProcessor, "processor %q", name)
+		}
+		seen[name] = true
+		p, err := New(spec)
+		if err != nil {
+			return nil, err
+		}
+		s.names = append(s.names, name)
+		s.processors = append(s.processors, p)
+	}
+	return s, nil
+}
+
+// Run starts the processors of s, each processing the blocks of c
+// after the pins named by after have reached them, and returns. It
+// creates the pin of a processor run for the first time at height
+// 0, so that it processes every block. A nil Set runs nothing.
+func (s *Set) Run(ctx context.Context, pins *pin.Store, c *protocol.Chain, after []string) error {
+	if s == nil {
+		return nil
+	}
+	for _, name := range s.names {
+		err := pins.CreatePin(ctx, PinPrefix+name, 0)
+		if err != nil {
+			return errors.Wrapf(err, "creating pin for processor %q", name)
+		}
+	}
+	for i, name := range s.names {
+		name, pinName, p := name, PinPrefix+name, s.processors[i]
+		go pins.ProcessBlocks(ctx, c, pinName, func(ctx context.Context, b *legacy.Block) error {
+			// The pin processes several blocks at once; wait for
+			// the one before, so that p sees them in order.
+			<-pins.PinWaiter(pinName, b.Height-1)
+			for _, other := range after {
+				<-pins.PinWaiter(other, b.Height)
+			}
+			return errors.Wrapf(p.ProcessBlock(ctx, b), "processor %q, block %d", name, b.Height)
+		})
+	}
+	return nil
+}
+
+func split(spec string) (name, arg string) {
+	if i := strings.Index(spec, "="); i >= 0 {
+		return spec[:i], spec[i+1:]
+	}
+	return spec, ""
+}
@@ -0,0 +1,50 @@
+package blockproc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+func TestNewSet(t *testing.T) {
+	var got []string
+	Register("test-record", func(arg string) (Processor, error) {
+		return ProcessorFunc(func(ctx context.Context, b *legacy.Block) error {
+			got = append(got, arg)
+			return nil
+		}), nil
+	})
+
+	s, err := NewSet([]string{"test-record=a", "log"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.names) != 2 || s.names[0] != "test-record" || s.names[1] != "log" {
+		t.Errorf("names = %v, want [test-record log]", s.names)
+	}
+	err = s.processors[0].ProcessBlock(context.Background(), new(legacy.Block))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != "a" {
+		t.Errorf("processed with %v, want [a]", got)
+	}
+
+	_, err = NewSet([]string{"test-record=a", "test-record=b"})
+	if errors.Root(err) != ErrDuplicateProcessor {
+		t.Errorf("NewSet(duplicate) = %v, want %v", err, ErrDuplicateProcessor)
+	}
+	_, err = NewSet([]string{"no-such-processor"})
+	if errors.Root(err) != ErrUnknownProcessor {
+		t.Errorf("NewSet(unknown) = %v, want %v", err, ErrUnknownProcessor)
+	}
+}
+
+func TestNilSetRun(t *testing.T) {
+	var s *Set
+	if err := s.Run(context.Background(), nil, nil, nil); err != nil {
+		t.Errorf("nil Set Run = %v, want nil", err)
+	}
+}
@@ -0,0 +1,36 @@
+package blockproc
+
+import (
+	"context"
+
+	"github.com/chainmint/core/archive"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/log"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+// newLog creates a processor logging the height, ID and number of
+// transactions of each block, with arg, if given, as its message.
+func newLog(arg string) (Processor, error) {
+	msg := arg
+	if msg == "" {
+		msg = "processed block"
+	}
+	return ProcessorFunc(func(ctx context.Context, b *legacy.Block) error {
+		log.Printkv(ctx, "at", msg, "height", b.Height, "id", b.Hash().HexString(), "txs", len(b.Transactions))
+		return nil
+	}), nil
+}
+
+// newArchive creates a processor archiving each block in the
+// directory arg; see package archive.
+func newArchive(arg string) (Processor, error) {
+	if arg == "" {
+		return nil, errors.New("archive processor needs a directory, as archive=dir")
+	}
+	a, err := archive.Open(arg)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
@@ -32,6 +32,11 @@ type Signer interface {
 // BlockSigner validates and signs blocks.
 type BlockSigner struct {
 	Pub ed25519.PublicKey
+
+	// State, if set, guards against double-signing across
+	// restarts and database restores. See LoadSignState.
+	State *SignState
+
 	hsm Signer
 	db  pg.DB
 	c   *protocol.Chain
@@ -59,9 +64,9 @@ func (s *BlockSigner) SignBlock(ctx context.Context, marshalledBlock []byte) ([]
 	if err != nil {
 		return nil, err
 	}
-	err = lockBlockHeight(ctx, s.db, &b)
+	err = s.lock(ctx, &b)
 	if err != nil {
-		return nil, errors.Wrap(err, "lock block height")
+		return nil, err
 	}
 	sig, err := s.hsm.Sign(ctx, s.Pub, &b.BlockHeader)
 	if err != nil {
@@ -100,9 +105,9 @@ func (s *BlockSigner) ValidateAndSignBlock(ctx context.Context, b *legacy.Block)
 		return nil, errors.Wrap(err, "validating block for signature")
 	}
 
-	err = lockBlockHeight(ctx, s.db, b)
+	err = s.lock(ctx, b)
 	if err != nil {
-		return nil, errors.Wrap(err, "lock block height")
+		return nil, err
 	}
 
 	sig, err := s.hsm.Sign(ctx, s.Pub, &b.BlockHeader)
@@ -112,6 +117,27 @@ func (s *BlockSigner) ValidateAndSignBlock(ctx context.Context, b *legacy.Block)
 	return sig, nil
 }
 
+// lock records the intention to sign b, both in the database and,
+// if configured, in the local sign state. The sign state is checked
+// first but advanced only once the database has taken the lock, so
+// that a block the database refuses is never recorded as signed.
+func (s *BlockSigner) lock(ctx context.Context, b *legacy.Block) error {
+	if s.State != nil {
+		err := s.State.Check(b.Height, 0, b.Hash())
+		if err != nil {
+			return err
+		}
+	}
+	err := lockBlockHeight(ctx, s.db, b)
+	if err != nil {
+		return errors.Wrap(err, "lock block height")
+	}
+	if s.State != nil {
+		return s.State.Lock(b.Height, 0, b.Hash())
+	}
+	return nil
+}
+
 // lockBlockHeight records a signer's intention to sign a given block
 // at a given height.  It's an error if a different block at the same
 // height has previously been signed.
@@ -0,0 +1,122 @@
+package blocksigner
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+)
+
+// ErrDoubleSign is returned when signing a block would conflict
+// with a block signed earlier with the same key.
+var ErrDoubleSign = errors.New("refusing to sign at or below last signed height")
+
+// SignState records the last block signed with a block-signing key.
+// It is kept in a local file rather than the database, so that
+// restoring the database from an older backup does not also roll
+// back the record of what has been signed.
+//
+// Chain blocks have no rounds; Round is recorded for consensus
+// engines that sign several times per height and is zero otherwise.
+type SignState struct {
+	Height    uint64  `json:"height"`
+	Round     uint64  `json:"round"`
+	BlockHash bc.Hash `json:"block_hash"`
+
+	mu   sync.Mutex
+	path string
+}
+
+// LoadSignState reads the sign state from the file at path. If the
+// file does not exist, it returns an empty state that will be saved
+// there.
+func LoadSignState(path string) (*SignState, error) {
+	st := &SignState{path: path}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return st, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "reading sign state")
+	}
+	err = json.Unmarshal(b, st)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing sign state")
+	}
+	return st, nil
+}
+
+// Check checks that signing the block with the given hash at height
+// and round cannot conflict with anything signed before. Signing the
+// same block again is allowed; signing anything else at or below the
+// last signed height and round is not.
+func (st *SignState) Check(height, round uint64, hash bc.Hash) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.check(height, round, hash)
+}
+
+func (st *SignState) check(height, round uint64, hash bc.Hash) error {
+	if height < st.Height || (height == st.Height && round <= st.Round) {
+		if height == st.Height && round == st.Round && hash == st.BlockHash {
+			return nil
+		}
+		return errors.WithDetailf(ErrDoubleSign, "last signed height %d round %d, requested height %d round %d", st.Height, st.Round, height, round)
+	}
+	return nil
+}
+
+// Lock checks the block as Check does and records it as the last
+// signed block.
+//
+// The state is saved to disk before Lock returns, so the signature
+// must only be produced after a nil return.
+func (st *SignState) Lock(height, round uint64, hash bc.Hash) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	err := st.check(height, round, hash)
+	if err != nil {
+		return err
+	}
+	if height == st.Height && round == st.Round {
+		return nil
+	}
+
+	prevHeight, prevRound, prevHash := st.Height, st.Round, st.BlockHash
+	st.Height, st.Round, st.BlockHash = height, round, hash
+	err = st.save()
+	if err != nil {
+		st.Height, st.Round, st.BlockHash = prevHeight, prevRound, prevHash
+		return err
+	}
+	return nil
+}
+
+// save atomically replaces the state file, so a crash leaves either
+// the old state or the new one.
+func (st *SignState) save() error {
+	b, err := json.Marshal(st)
+	if err != nil {
+		return errors.Wrap(err, "marshaling sign state")
+	}
+	f, err := ioutil.TempFile(filepath.Dir(st.path), filepath.Base(st.path)+".tmp")
+	if err != nil {
+		return errors.Wrap(err, "creating sign state")
+	}
+	_, err = f.Write(b)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return errors.Wrap(err, "writing sign state")
+	}
+	return errors.Wrap(os.Rename(f.Name(), st.path), "saving sign state")
+}
@@ -0,0 +1,48 @@
+package blocksigner
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+)
+
+func TestSignStateSurvivesRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "signstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sign_state.json")
+
+	st, err := LoadSignState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := bc.NewHash([32]byte{1}), bc.NewHash([32]byte{2})
+	if err := st.Lock(5, 0, a); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Lock(5, 0, a); err != nil {
+		t.Errorf("re-signing the same block: %v", err)
+	}
+
+	st, err = LoadSignState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range []uint64{4, 5} {
+		if err := st.Lock(h, 0, b); errors.Root(err) != ErrDoubleSign {
+			t.Errorf("Lock(%d) after restart = %v, want %v", h, err, ErrDoubleSign)
+		}
+	}
+	if err := st.Check(7, 0, b); err != nil {
+		t.Errorf("Check(7) = %v", err)
+	}
+	if err := st.Lock(6, 0, b); err != nil {
+		t.Errorf("Lock(6) after Check(7) = %v", err)
+	}
+}
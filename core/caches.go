@@ -0,0 +1,184 @@
+package core
+
+import (
+	"context"
+	"expvar"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chainmint/core/meminfo"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/log"
+)
+
+var (
+	cacheBlockSize = expvar.NewInt("caches.block_cache_size")
+	cacheTxSize    = expvar.NewInt("caches.tx_cache_size")
+	cacheShrinks   = expvar.NewInt("caches.shrinks")
+)
+
+// ErrBadStateOption is returned by SetStateOption for an unknown
+// option or an invalid value.
+var ErrBadStateOption = errors.New("invalid state option")
+
+// CacheConfig sizes the Core's in-memory caches of recently used
+// blocks and of transaction validation results.
+//
+// If MinFreeMemory is nonzero, the caches are resized every
+// TunePeriod as the system's available memory changes: halved while
+// less than MinFreeMemory bytes are available, and doubled, up to
+// their configured sizes, while more than twice that is.
+type CacheConfig struct {
+	BlockCache    int
+	TxCache       int
+	MinFreeMemory int64
+	TunePeriod    time.Duration
+}
+
+// cacheSizes holds the configured and current sizes of the caches.
+type cacheSizes struct {
+	mu             sync.Mutex
+	cfg            CacheConfig
+	block, tx      int
+	availableBytes func() int64
+}
+
+// Caches configures the sizes of the Core's caches and how they
+// adapt to the available memory.
+func Caches(cfg CacheConfig) RunOption {
+	return func(a *API) {
+		a.caches = &cacheSizes{
+			cfg:            cfg,
+			block:          cfg.BlockCache,
+			tx:             cfg.TxCache,
+			availableBytes: meminfo.Available,
+		}
+		a.applyCacheSizes()
+	}
+}
+
+// applyCacheSizes resizes the caches to a.caches's current sizes.
+func (a *API) applyCacheSizes() {
+	a.caches.mu.Lock()
+	block, tx := a.caches.block, a.caches.tx
+	a.caches.mu.Unlock()
+	if a.store != nil && block > 0 {
+		a.store.SetBlockCacheSize(block)
+		cacheBlockSize.Set(int64(block))
+	}
+	if tx > 0 {
+		a.chain.SetTxCacheSize(tx)
+		cacheTxSize.Set(int64(tx))
+	}
+}
+
+// tuneCaches periodically resizes the caches to the available
+// memory; see CacheConfig.
+func (a *API) tuneCaches(ctx context.Context, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if a.caches.tune() {
+				a.applyCacheSizes()
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// tune adjusts the current sizes to the available memory and reports
+// whether they changed.
+func (c *cacheSizes) tune() bool {
+	avail := c.availableBytes()
+	if avail == 0 {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	block := tuneSize(c.block, c.cfg.BlockCache, avail, c.cfg.MinFreeMemory)
+	tx := tuneSize(c.tx, c.cfg.TxCache, avail, c.cfg.MinFreeMemory)
+	if block == c.block && tx == c.tx {
+		return false
+	}
+	if block < c.block || tx < c.tx {
+		cacheShrinks.Add(1)
+	}
+	c.block, c.tx = block, tx
+	return true
+}
+
+// tuneSize returns the size a cache of size cur, configured with
+// size max, should have when avail bytes of memory are available and
+// minFree should be.
+func tuneSize(cur, max int, avail, minFree int64) int {
+	switch {
+	case max <= 0:
+		return cur
+	case avail < minFree:
+		cur /= 2
+		if cur < 1 {
+			cur = 1
+		}
+	case avail > 2*minFree && cur < max:
+		cur *= 2
+		if cur > max {
+			cur = max
+		}
+	}
+	return cur
+}
+
+// SetStateOption changes a setting of the chain state's snapshots or
+// caches while the Core runs. Keys are snapshot_interval, in blocks,
+// snapshot_frequency and snapshot_max_age, as durations, and
+// block_cache_size and tx_cache_size, in entries. A cache size set
+// this way is also the most the cache grows to when tuned.
+func (a *API) SetStateOption(key, value string) error {
+	switch key {
+	case "snapshot_interval":
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return errors.WithDetailf(ErrBadStateOption, "%s: %s", key, err)
+		}
+		_, frequency := a.chain.SnapshotSchedule()
+		a.chain.SetSnapshotSchedule(n, frequency)
+	case "snapshot_frequency", "snapshot_max_age":
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return errors.WithDetailf(ErrBadStateOption, "%s: invalid duration %q", key, value)
+		}
+		if key == "snapshot_max_age" {
+			if a.store == nil {
+				return errors.WithDetailf(ErrBadStateOption, "%s: no block store", key)
+			}
+			a.store.SetSnapshotMaxAge(d)
+			break
+		}
+		interval, _ := a.chain.SnapshotSchedule()
+		a.chain.SetSnapshotSchedule(interval, d)
+	case "block_cache_size", "tx_cache_size":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return errors.WithDetailf(ErrBadStateOption, "%s: invalid size %q", key, value)
+		}
+		if a.caches == nil {
+			Caches(CacheConfig{})(a)
+		}
+		a.caches.mu.Lock()
+		if key == "block_cache_size" {
+			a.caches.cfg.BlockCache, a.caches.block = n, n
+		} else {
+			a.caches.cfg.TxCache, a.caches.tx = n, n
+		}
+		a.caches.mu.Unlock()
+		a.applyCacheSizes()
+	default:
+		return errors.WithDetailf(ErrBadStateOption, "unknown option %q", key)
+	}
+	log.Printkv(context.Background(), "at", "set state option", "key", key, "value", value)
+	return nil
+}
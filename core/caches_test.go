@@ -0,0 +1,43 @@
+package core
+
+import "testing"
+
+func TestCacheSizesTune(t *testing.T) {
+	var avail int64
+	c := &cacheSizes{
+		cfg:            CacheConfig{BlockCache: 30, TxCache: 1000, MinFreeMemory: 100},
+		block:          30,
+		tx:             1000,
+		availableBytes: func() int64 { return avail },
+	}
+
+	cases := []struct {
+		avail     int64
+		changed   bool
+		block, tx int
+	}{
+		{avail: 0, changed: false, block: 30, tx: 1000},   // unknown
+		{avail: 150, changed: false, block: 30, tx: 1000}, // enough
+		{avail: 50, changed: true, block: 15, tx: 500},
+		{avail: 50, changed: true, block: 7, tx: 250},
+		{avail: 150, changed: false, block: 7, tx: 250}, // between the thresholds
+		{avail: 500, changed: true, block: 14, tx: 500},
+		{avail: 500, changed: true, block: 28, tx: 1000},
+		{avail: 500, changed: true, block: 30, tx: 1000},
+		{avail: 500, changed: false, block: 30, tx: 1000},
+	}
+	for i, tc := range cases {
+		avail = tc.avail
+		changed := c.tune()
+		if changed != tc.changed || c.block != tc.block || c.tx != tc.tx {
+			t.Errorf("%d: tune() = %v, sizes %d, %d; want %v, %d, %d", i, changed, c.block, c.tx, tc.changed, tc.block, tc.tx)
+		}
+	}
+}
+
+func TestTuneSizeFloor(t *testing.T) {
+	got := tuneSize(1, 30, 0, 100)
+	if got != 1 {
+		t.Errorf("tuneSize(1, ...) = %d, want 1", got)
+	}
+}
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"context"
+
+	"github.com/chainmint/core/config"
+	"github.com/chainmint/core/query"
+	"github.com/chainmint/protocol/bc"
+)
+
+// apiVersion is the version of the Core API. It is increased
+// whenever a route is removed or changes incompatibly; routes that
+// are added are listed by /info/capabilities instead.
+const apiVersion = 1
+
+// capabilities describes the API a Core serves, so that clients can
+// check for the features they need before relying on them.
+type capabilities struct {
+	APIVersion          int                          `json:"api_version"`
+	CrosscoreRPCVersion int                          `json:"crosscore_rpc_version"`
+	Version             string                       `json:"version"`
+	BlockchainID        *bc.Hash                     `json:"blockchain_id,omitempty"`
+	Subsystems          map[string]bool              `json:"subsystems"`
+	QueryFilters        map[string]*query.Filterable `json:"query_filters"`
+}
+
+// getCapabilities is an http handler for discovering the API version
+// of the Core, which of its optional subsystems are enabled, and the
+// fields its list queries may filter on.
+//
+// POST /info/capabilities
+func (a *API) getCapabilities(ctx context.Context) (*capabilities, error) {
+	c := &capabilities{
+		APIVersion:          apiVersion,
+		CrosscoreRPCVersion: crosscoreRPCVersion,
+		Version:             config.Version,
+		Subsystems: map[string]bool{
+			"generator":        a.generator != nil,
+			"remote_generator": a.remoteGenerator != nil,
+			"indexer":          a.indexTxs,
+			"mockhsm":          a.mockHSM,
+			"events":           a.events != nil,
+			"block_signer":     a.signer != nil,
+			"peer_tls":         a.peerTLS != nil,
+			"tx_signer":        a.txSigner != nil,
+			"backup":           a.dbURL != "",
+		},
+		QueryFilters: query.Filterables(),
+	}
+	if a.chain != nil {
+		id := a.chain.InitialBlockHash
+		c.BlockchainID = &id
+	}
+	return c, nil
+}
@@ -0,0 +1,30 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chainmint/core/pubsub"
+)
+
+func TestCapabilities(t *testing.T) {
+	a := &API{indexTxs: true, events: pubsub.NewBroker()}
+	c, err := a.getCapabilities(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.APIVersion != apiVersion || c.BlockchainID != nil {
+		t.Errorf("capabilities = %+v", c)
+	}
+	want := map[string]bool{"indexer": true, "events": true, "generator": false, "peer_tls": false}
+	for name, enabled := range want {
+		if c.Subsystems[name] != enabled {
+			t.Errorf("subsystem %s enabled = %v want %v", name, c.Subsystems[name], enabled)
+		}
+	}
+
+	txs := c.QueryFilters["transactions"]
+	if txs == nil || txs.Fields["block_height"] != "integer" || txs.Subfilters["inputs"]["asset_id"] != "string" {
+		t.Errorf("transaction filters = %+v", txs)
+	}
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"context"
+	"math"
+
+	"github.com/chainmint/core/query"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/net/http/httpjson"
+)
+
+// POST /create-category-rule
+func (a *API) createCategoryRule(ctx context.Context, in query.CategoryRule) (*query.CategoryRule, error) {
+	return a.indexer.CreateCategoryRule(ctx, &in)
+}
+
+// POST /list-category-rules
+func (a *API) listCategoryRules(ctx context.Context) (page, error) {
+	rules, err := a.indexer.CategoryRules(ctx)
+	if err != nil {
+		return page{}, err
+	}
+	return page{Items: httpjson.Array(rules), LastPage: true}, nil
+}
+
+// POST /delete-category-rule
+func (a *API) deleteCategoryRule(ctx context.Context, in struct {
+	ID string `json:"id"`
+}) error {
+	return a.indexer.DeleteCategoryRule(ctx, in.ID)
+}
+
+// listCategoryTotals is an http handler for summing the outputs of
+// the transactions in each category, by asset, over a time range.
+//
+// POST /list-category-totals
+func (a *API) listCategoryTotals(ctx context.Context, in struct {
+	Category    string `json:"category,omitempty"`
+	StartTimeMS uint64 `json:"start_time,omitempty"`
+	EndTimeMS   uint64 `json:"end_time,omitempty"`
+}) (page, error) {
+	if in.StartTimeMS > math.MaxInt64 {
+		return page{}, errors.WithDetail(httpjson.ErrBadRequest, "start timestamp is too large")
+	}
+	endTimeMS := in.EndTimeMS
+	if endTimeMS == 0 {
+		endTimeMS = math.MaxInt64
+	} else if endTimeMS > math.MaxInt64 {
+		return page{}, errors.WithDetail(httpjson.ErrBadRequest, "end timestamp is too large")
+	}
+	totals, err := a.indexer.CategoryTotals(ctx, in.Category, in.StartTimeMS, endTimeMS)
+	if err != nil {
+		return page{}, err
+	}
+	return page{Items: httpjson.Array(totals), LastPage: true}, nil
+}
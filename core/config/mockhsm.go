@@ -11,6 +11,8 @@ import (
 	"github.com/chainmint/log"
 )
 
+var configLog = log.Module("config")
+
 func getOrCreateDevKey(ctx context.Context, db pg.DB, c *Config) (blockPub ed25519.PublicKey, err error) {
 	hsm := mockhsm.New(db)
 	corePub, created, err := hsm.GetOrCreate(ctx, autoBlockKeyAlias)
@@ -18,9 +20,9 @@ func getOrCreateDevKey(ctx context.Context, db pg.DB, c *Config) (blockPub ed255
 		return nil, err
 	}
 	if created {
-		log.Printf(ctx, "Generated new block-signing key %s\n", corePub.Pub)
+		configLog.Infokv(ctx, log.KeyMessage, "generated new block-signing key", "pubkey", corePub.Pub)
 	} else {
-		log.Printf(ctx, "Using block-signing key %s\n", corePub.Pub)
+		configLog.Infokv(ctx, log.KeyMessage, "using block-signing key", "pubkey", corePub.Pub)
 	}
 	c.BlockPub = corePub.Pub
 
@@ -10,12 +10,17 @@ import (
 	"github.com/chainmint/core/fetch"
 	"github.com/chainmint/core/leader"
 	"github.com/chainmint/errors"
+	"github.com/chainmint/errors/errcode"
 	"github.com/chainmint/log"
 	"github.com/chainmint/net/http/httpjson"
 	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	abciTypes "github.com/tendermint/abci/types"
 	ctypes "github.com/tendermint/tendermint/rpc/core/types"
 )
 
+var coreLog = log.Module("core")
+
 var (
 	errAlreadyConfigured = errors.New("core is already configured; must reset first")
 	errUnconfigured      = errors.New("core is not configured")
@@ -23,6 +28,9 @@ var (
 	errNoReset           = errors.New("core is not configured with reset capabilities")
 	errBadBlockPub       = errors.New("supplied block pub key is invalid")
 	errNoClientTokens    = errors.New("cannot enable client auth without client access tokens")
+	errNoGenerator       = errors.New("core is not configured as a local generator")
+	errNoStateExporter   = errors.New("core has no staking state to export")
+	errNoTxSigner        = errors.New("core is not configured with a transaction signer")
 )
 
 const (
@@ -42,14 +50,165 @@ func (a *API) reset(ctx context.Context, req struct {
 	panic("unreached")
 }
 
+// pauseBlockProduction stops the local generator from making new
+// blocks. It returns once any block being made has been committed.
+// Queries are still answered while block production is paused.
+//
+// POST /pause-block-production
+func (a *API) pauseBlockProduction(ctx context.Context) error {
+	if a.generator == nil {
+		return errNoGenerator
+	}
+	a.generator.Pause()
+	log.Printkv(ctx, "at", "block production paused", "height", a.chain.Height())
+	return nil
+}
+
+// resumeBlockProduction undoes pauseBlockProduction.
+//
+// POST /resume-block-production
+func (a *API) resumeBlockProduction(ctx context.Context) error {
+	if a.generator == nil {
+		return errNoGenerator
+	}
+	a.generator.Resume()
+	log.Printkv(ctx, "at", "block production resumed", "height", a.chain.Height())
+	return nil
+}
+
+// PendingTx describes a tx waiting in the local generator's pending
+// tx pool.
+type PendingTx struct {
+	ID        bc.Hash   `json:"id"`
+	Size      int64     `json:"size"`
+	Fee       uint64    `json:"fee"`
+	FirstSeen time.Time `json:"first_seen"`
+}
+
+// PendingTxs describes the txs in the local generator's pending tx
+// pool, in the order they will go into a block.
+func (a *API) PendingTxs() ([]*PendingTx, error) {
+	if a.generator == nil {
+		return nil, errNoGenerator
+	}
+	txs := []*PendingTx{}
+	for _, tx := range a.generator.PoolTxs() {
+		txs = append(txs, &PendingTx{tx.ID, tx.Size, tx.Fee, tx.FirstSeen.UTC()})
+	}
+	return txs, nil
+}
+
+// ErrTxRejected is returned by BroadcastTx for a tx CheckTx rejects.
+// It carries the code, from package errcode, with which CheckTx
+// rejected the tx, if any; see errors.Code.
+var ErrTxRejected = errors.New("transaction rejected by CheckTx")
+
+// BroadcastTx submits tx, in its text encoding, to Tendermint's
+// mempool, and returns the error, if any, with which CheckTx
+// rejected it.
+func (a *API) BroadcastTx(tx []byte) error {
+	result := new(ctypes.ResultBroadcastTx)
+	_, err := a.client.Call("broadcast_tx_sync", map[string]interface{}{"tx": tx}, result)
+	if err != nil {
+		return errors.Wrap(err, "broadcasting tx to Tendermint")
+	}
+	if result.Code != abciTypes.CodeType_OK {
+		code, msg := errcode.Parse(result.Log)
+		return errors.WithCode(errors.WithDetail(ErrTxRejected, msg), code)
+	}
+	return nil
+}
+
+// broadcastSubmitter submits txs to Tendermint's mempool, keeping
+// each in the pending txs of the local generator, with queue, until
+// Tendermint delivers it. Chain blocks are made only from the txs
+// Tendermint delivers; see generator.Generator.Deliver.
+type broadcastSubmitter struct {
+	a     *API
+	queue func(context.Context, *legacy.Tx) error
+}
+
+func (s broadcastSubmitter) Submit(ctx context.Context, tx *legacy.Tx) error {
+	g := s.a.generator
+	// A tx still pending was broadcast already, and Tendermint
+	// refuses txs it has seen; resubmitting it is a no-op.
+	if g.Pending(tx.ID) {
+		return nil
+	}
+	err := s.queue(ctx, tx)
+	if err != nil {
+		return err
+	}
+	raw, err := tx.MarshalText()
+	if err == nil {
+		err = s.a.BroadcastTx(raw)
+	}
+	if err != nil {
+		g.Withdraw(tx)
+		return errors.Wrapf(err, "tx %x", tx.ID.Bytes())
+	}
+	return nil
+}
+
+// listPendingTxs is an http handler for listing the txs in the
+// pending tx pool, for debugging.
+//
+// POST /list-pending-transactions
+func (a *API) listPendingTxs(ctx context.Context) (page, error) {
+	txs, err := a.PendingTxs()
+	if err != nil {
+		return page{}, err
+	}
+	return page{Items: txs, LastPage: true}, nil
+}
+
+// evictPendingTx removes a tx from the pending tx pool, along with
+// the pending txs that spend its outputs, and returns the IDs of the
+// txs removed. It only forgets the tx locally: a tx already passed
+// on to Tendermint is still included in a block if Tendermint
+// delivers it.
+//
+// POST /evict-pending-transaction
+func (a *API) evictPendingTx(ctx context.Context, in struct {
+	ID bc.Hash `json:"id"`
+}) (map[string][]bc.Hash, error) {
+	if a.generator == nil {
+		return nil, errNoGenerator
+	}
+	evicted, err := a.generator.Evict(in.ID)
+	if err != nil {
+		return nil, err
+	}
+	log.Printkv(ctx, "at", "pending tx evicted", "id", in.ID, "evicted", len(evicted))
+	return map[string][]bc.Hash{"evicted": evicted}, nil
+}
+
+// StateExporter returns the staking state committed at a height, or
+// at the last committed height if height is 0, as a JSON document
+// that the application can import in InitChain.
+type StateExporter func(ctx context.Context, height uint64) (interface{}, error)
+
+// exportStakingState is an http handler for exporting the validator,
+// delegation and governance state at a height, to restart the
+// network from it.
+//
+// POST /export-staking-state
+func (a *API) exportStakingState(ctx context.Context, req struct {
+	Height uint64 `json:"height"`
+}) (interface{}, error) {
+	if a.exportState == nil {
+		return nil, errNoStateExporter
+	}
+	return a.exportState(ctx, req.Height)
+}
+
 func (a *API) info(ctx context.Context) (map[string]interface{}, error) {
-	log.Printf(ctx, "-----info----")
 	result := new(ctypes.ResultStatus)
 	_, err := a.client.Call("status", map[string]interface{}{}, result)
 	if err != nil {
 		log.Error(ctx, err)
 	}
-	log.Printf(ctx, "tendermint latest block height:%d", result.LatestBlockHeight)
+	coreLog.Debugkv(ctx, "request", "info", "tendermint_height", result.LatestBlockHeight)
 	if a.config == nil {
 		// never configured
 		return map[string]interface{}{
@@ -126,6 +285,12 @@ func (a *API) leaderInfo(ctx context.Context) (map[string]interface{}, error) {
 		"build_config":                      config.BuildConfig,
 		"health":                            a.health(),
 	}
+	if a.generator != nil {
+		m["block_production_paused"] = a.generator.Paused()
+	}
+	if r := a.Reconciliation(); r != nil {
+		m["reconciliation"] = r
+	}
 
 	// Add in snapshot information if we're downloading a snapshot.
 	if snapshot != nil {
@@ -167,21 +332,21 @@ func closeConnOK(w http.ResponseWriter, req *http.Request) {
 
 	hijacker, ok := w.(http.Hijacker)
 	if !ok {
-		log.Printf(req.Context(), "no hijacker")
+		coreLog.Warnkv(req.Context(), log.KeyMessage, "closing connection: no hijacker")
 		return
 	}
 	conn, buf, err := hijacker.Hijack()
 	if err != nil {
-		log.Printf(req.Context(), "could not hijack connection: %s\n", err)
+		coreLog.Warnkv(req.Context(), log.KeyError, errors.Wrap(err, "hijacking connection"))
 		return
 	}
 	err = buf.Flush()
 	if err != nil {
-		log.Printf(req.Context(), "could not flush connection buffer: %s\n", err)
+		coreLog.Warnkv(req.Context(), log.KeyError, errors.Wrap(err, "flushing connection buffer"))
 	}
 	err = conn.Close()
 	if err != nil {
-		log.Printf(req.Context(), "could not close connection: %s\n", err)
+		coreLog.Warnkv(req.Context(), log.KeyError, errors.Wrap(err, "closing connection"))
 	}
 }
 
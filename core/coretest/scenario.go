@@ -0,0 +1,324 @@
+package coretest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chainmint/core/account"
+	"github.com/chainmint/core/asset"
+	"github.com/chainmint/core/generator"
+	"github.com/chainmint/core/pin"
+	"github.com/chainmint/core/query"
+	"github.com/chainmint/core/txbuilder"
+	"github.com/chainmint/crypto/ed25519/chainkd"
+	"github.com/chainmint/database/pg"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/testutil"
+)
+
+// Node is an in-memory Chain Core node for running scenarios:
+// a generator and the account, asset and transaction indexers
+// of a single chain, without the API.
+type Node struct {
+	DB        pg.DB
+	Chain     *protocol.Chain
+	Generator *generator.Generator
+	PinStore  *pin.Store
+	Accounts  *account.Manager
+	Assets    *asset.Registry
+	Indexer   *query.Indexer
+
+	// aliases of the accounts and assets created by scenarios
+	accountIDs map[string]string
+	assetIDs   map[string]bc.AssetID
+}
+
+// NewNode returns a Node on chain c, storing its state in db.
+// Its indexers run until ctx is canceled.
+func NewNode(ctx context.Context, t testing.TB, db pg.DB, c *protocol.Chain) *Node {
+	pinStore := pin.NewStore(db)
+	CreatePins(ctx, t, pinStore)
+
+	n := &Node{
+		DB:         db,
+		Chain:      c,
+		Generator:  generator.New(c, db),
+		PinStore:   pinStore,
+		Accounts:   account.NewManager(db, c, pinStore),
+		Assets:     asset.NewRegistry(db, c, pinStore),
+		Indexer:    query.NewIndexer(db, c, pinStore),
+		accountIDs: make(map[string]string),
+		assetIDs:   make(map[string]bc.AssetID),
+	}
+	n.Indexer.RegisterAnnotator(n.Assets.AnnotateTxs)
+	n.Indexer.RegisterAnnotator(n.Accounts.AnnotateTxs)
+	n.Accounts.IndexAccounts(n.Indexer)
+	n.Assets.IndexAssets(n.Indexer)
+	go n.Accounts.ProcessBlocks(ctx)
+	go n.Assets.ProcessBlocks(ctx)
+	go n.Indexer.ProcessBlocks(ctx)
+	return n
+}
+
+// Scenario is a sequence of steps, such as creating accounts and
+// assets, submitting txs and checking balances, read from JSON:
+//
+//	{
+//	  "name": "transfer",
+//	  "steps": [
+//	    {"create_asset": {"alias": "gold"}},
+//	    {"create_account": {"alias": "alice"}},
+//	    {"create_account": {"alias": "bob"}},
+//	    {"issue": {"asset": "gold", "amount": 100, "to": "alice"}},
+//	    {"make_block": true},
+//	    {"transfer": {"asset": "gold", "amount": 30, "from": "alice", "to": "bob"}},
+//	    {"transfer": {"asset": "gold", "amount": 500, "from": "alice", "to": "bob"},
+//	     "expect_error": "insufficient funds"},
+//	    {"make_block": true,
+//	     "expect_balances": [
+//	       {"account": "alice", "asset": "gold", "amount": 70},
+//	       {"account": "bob", "asset": "gold", "amount": 30}]}
+//	  ]
+//	}
+//
+// Accounts and assets are referred to by alias. Txs are signed
+// with testutil.TestXPrv and submitted to the node's generator;
+// their outputs are spendable and counted in balances only after
+// a make_block step.
+type Scenario struct {
+	Name  string `json:"name"`
+	Steps []Step `json:"steps"`
+}
+
+// Step is one step of a scenario. It takes at most one action,
+// then checks its expectations. If ExpectError is set, the action
+// must fail with an error containing it.
+type Step struct {
+	CreateAsset   *CreateStep `json:"create_asset"`
+	CreateAccount *CreateStep `json:"create_account"`
+	Issue         *AmountStep `json:"issue"`
+	Transfer      *AmountStep `json:"transfer"`
+	Retire        *AmountStep `json:"retire"`
+	MakeBlock     bool        `json:"make_block"`
+
+	ExpectError    string    `json:"expect_error"`
+	ExpectBalances []Balance `json:"expect_balances"`
+}
+
+// CreateStep creates an account or an asset.
+type CreateStep struct {
+	Alias      string                 `json:"alias"`
+	Tags       map[string]interface{} `json:"tags"`
+	Definition map[string]interface{} `json:"definition"` // assets only
+}
+
+// AmountStep moves an amount of an asset. Issue takes To,
+// Retire takes From, and Transfer takes both.
+type AmountStep struct {
+	Asset  string `json:"asset"`
+	Amount uint64 `json:"amount"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+// Balance is the expected confirmed balance of an asset in an
+// account.
+type Balance struct {
+	Account string `json:"account"`
+	Asset   string `json:"asset"`
+	Amount  uint64 `json:"amount"`
+}
+
+// LoadScenario reads a Scenario from the JSON file at path.
+func LoadScenario(path string) (*Scenario, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading scenario")
+	}
+	s := new(Scenario)
+	err = json.Unmarshal(b, s)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing scenario %s", path)
+	}
+	if s.Name == "" {
+		s.Name = path
+	}
+	return s, nil
+}
+
+// RunScenario runs the steps of s against n in order, failing t
+// at the first step that doesn't go as expected.
+func RunScenario(ctx context.Context, t testing.TB, n *Node, s *Scenario) {
+	for i, step := range s.Steps {
+		err := n.runStep(ctx, &step)
+		switch {
+		case step.ExpectError != "" && err == nil:
+			t.Fatalf("%s: step %d: got no error, want %q", s.Name, i, step.ExpectError)
+		case step.ExpectError != "" && !strings.Contains(err.Error(), step.ExpectError):
+			t.Fatalf("%s: step %d: got error %q, want %q", s.Name, i, err, step.ExpectError)
+		case step.ExpectError == "" && err != nil:
+			t.Logf("%s: step %d", s.Name, i)
+			testutil.FatalErr(t, err)
+		}
+
+		for _, want := range step.ExpectBalances {
+			got, err := n.balance(ctx, want.Account, want.Asset)
+			if err != nil {
+				t.Logf("%s: step %d", s.Name, i)
+				testutil.FatalErr(t, err)
+			}
+			if got != want.Amount {
+				t.Errorf("%s: step %d: balance of %s in %s = %d, want %d", s.Name, i, want.Asset, want.Account, got, want.Amount)
+			}
+		}
+	}
+}
+
+func (n *Node) runStep(ctx context.Context, s *Step) error {
+	switch {
+	case s.CreateAsset != nil:
+		keys := []chainkd.XPub{testutil.TestXPub}
+		a, err := n.Assets.Define(ctx, keys, 1, s.CreateAsset.Definition, s.CreateAsset.Alias, s.CreateAsset.Tags, "")
+		if err != nil {
+			return err
+		}
+		n.assetIDs[s.CreateAsset.Alias] = a.AssetID
+		return nil
+
+	case s.CreateAccount != nil:
+		keys := []chainkd.XPub{testutil.TestXPub}
+		a, err := n.Accounts.Create(ctx, keys, 1, s.CreateAccount.Alias, s.CreateAccount.Tags, "")
+		if err != nil {
+			return err
+		}
+		n.accountIDs[s.CreateAccount.Alias] = a.ID
+		return nil
+
+	case s.Issue != nil:
+		amt, err := n.assetAmount(s.Issue)
+		if err != nil {
+			return err
+		}
+		to, err := n.accountID(s.Issue.To)
+		if err != nil {
+			return err
+		}
+		return n.submit(ctx,
+			n.Assets.NewIssueAction(amt, nil),
+			n.Accounts.NewControlAction(amt, to, nil),
+		)
+
+	case s.Transfer != nil:
+		amt, err := n.assetAmount(s.Transfer)
+		if err != nil {
+			return err
+		}
+		from, err := n.accountID(s.Transfer.From)
+		if err != nil {
+			return err
+		}
+		to, err := n.accountID(s.Transfer.To)
+		if err != nil {
+			return err
+		}
+		return n.submit(ctx,
+			n.Accounts.NewSpendAction(amt, from, nil, nil),
+			n.Accounts.NewControlAction(amt, to, nil),
+		)
+
+	case s.Retire != nil:
+		amt, err := n.assetAmount(s.Retire)
+		if err != nil {
+			return err
+		}
+		from, err := n.accountID(s.Retire.From)
+		if err != nil {
+			return err
+		}
+		retire, err := txbuilder.DecodeRetireAction([]byte(fmt.Sprintf(
+			`{"asset_id": "%x", "amount": %d}`, amt.AssetId.Bytes(), amt.Amount,
+		)))
+		if err != nil {
+			return err
+		}
+		return n.submit(ctx, n.Accounts.NewSpendAction(amt, from, nil, nil), retire)
+
+	case s.MakeBlock:
+		return n.makeBlock(ctx)
+	}
+	return nil
+}
+
+// submit builds a tx from actions, signs it and submits it to the
+// generator.
+func (n *Node) submit(ctx context.Context, actions ...txbuilder.Action) error {
+	tpl, err := txbuilder.Build(ctx, nil, actions, time.Now().Add(time.Hour))
+	if err != nil {
+		return err
+	}
+	priv := testutil.TestXPrv
+	err = txbuilder.Sign(ctx, tpl, []chainkd.XPub{priv.XPub()}, func(_ context.Context, _ chainkd.XPub, path [][]byte, data [32]byte) ([]byte, error) {
+		derived := priv.Derive(path)
+		return derived.Sign(data[:]), nil
+	})
+	if err != nil {
+		return err
+	}
+	return txbuilder.FinalizeTx(ctx, n.Chain, n.Generator, tpl.Transaction)
+}
+
+// makeBlock makes a block of the pending txs and waits for the
+// node's indexers to process it.
+func (n *Node) makeBlock(ctx context.Context) error {
+	err, _ := n.Generator.MakeBlock(ctx, bc.Millis(time.Now()))
+	if err != nil {
+		return err
+	}
+	select {
+	case <-n.PinStore.AllWaiter(n.Chain.Height()):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (n *Node) balance(ctx context.Context, accountAlias, assetAlias string) (uint64, error) {
+	accountID, err := n.accountID(accountAlias)
+	if err != nil {
+		return 0, err
+	}
+	assetID, ok := n.assetIDs[assetAlias]
+	if !ok {
+		return 0, fmt.Errorf("unknown asset %q", assetAlias)
+	}
+	const q = `
+		SELECT COALESCE(SUM(amount), 0) FROM account_utxos
+		WHERE account_id = $1 AND asset_id = $2
+	`
+	var sum uint64
+	err = n.DB.QueryRow(ctx, q, accountID, assetID).Scan(&sum)
+	return sum, errors.Wrap(err, "summing account utxos")
+}
+
+func (n *Node) assetAmount(s *AmountStep) (bc.AssetAmount, error) {
+	assetID, ok := n.assetIDs[s.Asset]
+	if !ok {
+		return bc.AssetAmount{}, fmt.Errorf("unknown asset %q", s.Asset)
+	}
+	return bc.AssetAmount{AssetId: &assetID, Amount: s.Amount}, nil
+}
+
+func (n *Node) accountID(alias string) (string, error) {
+	id, ok := n.accountIDs[alias]
+	if !ok {
+		return "", fmt.Errorf("unknown account %q", alias)
+	}
+	return id, nil
+}
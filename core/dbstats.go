@@ -0,0 +1,123 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/chainmint/database/pg"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/log"
+)
+
+// slowQueryLogSize is the number of slow queries kept for
+// /index-stats.
+const slowQueryLogSize = 100
+
+// slowQuery is a sample of a query request that took longer than
+// the configured threshold.
+type slowQuery struct {
+	Endpoint   string    `json:"endpoint"`
+	Filter     string    `json:"filter"`
+	DurationMS int64     `json:"duration_ms"`
+	At         time.Time `json:"at"`
+}
+
+// slowQueryLog keeps the most recent slow queries.
+type slowQueryLog struct {
+	threshold time.Duration // 0 disables sampling
+
+	mu      sync.Mutex
+	samples []slowQuery // ring buffer
+	next    int
+}
+
+// record samples a query that started at t0, if it was slow.
+func (l *slowQueryLog) record(endpoint, filter string, t0 time.Time) {
+	d := time.Since(t0)
+	if l == nil || l.threshold == 0 || d < l.threshold {
+		return
+	}
+	q := slowQuery{
+		Endpoint:   endpoint,
+		Filter:     filter,
+		DurationMS: int64(d / time.Millisecond),
+		At:         t0.UTC(),
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.samples) < slowQueryLogSize {
+		l.samples = append(l.samples, q)
+		return
+	}
+	l.samples[l.next] = q
+	l.next = (l.next + 1) % slowQueryLogSize
+}
+
+// recent returns the sampled slow queries, oldest first.
+func (l *slowQueryLog) recent() []slowQuery {
+	res := []slowQuery{}
+	if l == nil {
+		return res
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	res = append(res, l.samples[l.next:]...)
+	return append(res, l.samples[:l.next]...)
+}
+
+type indexStat struct {
+	Name    string   `json:"name"`
+	Table   string   `json:"table"`
+	Size    int64    `json:"size"`
+	Scans   int64    `json:"scans"`
+	Hits    int64    `json:"hits"`
+	Misses  int64    `json:"misses"`
+	HitRate *float64 `json:"hit_rate"` // nil if the index was never read
+}
+
+// indexStats is an http handler for reporting the database indexes
+// with their sizes, usage and buffer cache hit rates, along with
+// recent slow queries.
+//
+// POST /index-stats
+func (a *API) indexStats(ctx context.Context) (map[string]interface{}, error) {
+	const q = `
+		SELECT s.indexrelname, s.relname, pg_relation_size(s.indexrelid),
+			s.idx_scan, io.idx_blks_hit, io.idx_blks_read
+		FROM pg_stat_user_indexes s
+		JOIN pg_statio_user_indexes io USING (indexrelid)
+		ORDER BY s.relname, s.indexrelname
+	`
+	indexes := []indexStat{}
+	err := pg.ForQueryRows(ctx, a.db, q, func(name, table string, size, scans, hits, misses int64) {
+		st := indexStat{Name: name, Table: table, Size: size, Scans: scans, Hits: hits, Misses: misses}
+		if hits+misses > 0 {
+			rate := float64(hits) / float64(hits+misses)
+			st.HitRate = &rate
+		}
+		indexes = append(indexes, st)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "querying index statistics")
+	}
+	return map[string]interface{}{
+		"indexes":      indexes,
+		"slow_queries": a.slowQueries.recent(),
+	}, nil
+}
+
+// prewarmIndexes loads the named indexes into the database's
+// buffer cache using the pg_prewarm extension. Failures are logged
+// and otherwise ignored: a cold index is slow, not wrong.
+func prewarmIndexes(ctx context.Context, db pg.DB, names []string) {
+	for _, name := range names {
+		var blocks int64
+		err := db.QueryRow(ctx, `SELECT pg_prewarm($1::regclass)`, name).Scan(&blocks)
+		if err != nil {
+			log.Error(ctx, errors.Wrap(err, "prewarming index "+name))
+			continue
+		}
+		log.Printkv(ctx, "at", "prewarmed index", "index", name, "blocks", blocks)
+	}
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/chainmint/core/query"
+	"github.com/chainmint/core/txbuilder"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+type decodeTxRequest struct {
+	RawTransaction *legacy.Tx `json:"raw_transaction"`
+}
+
+// decodedTx is a human-readable form of a raw transaction. Unlike an
+// annotated transaction from /list-transactions, it need not be in a
+// block.
+type decodedTx struct {
+	ID            bc.Hash                  `json:"id"`
+	Version       uint64                   `json:"version"`
+	MinTime       *time.Time               `json:"min_time,omitempty"`
+	MaxTime       *time.Time               `json:"max_time,omitempty"`
+	ReferenceData *json.RawMessage         `json:"reference_data"`
+	IsLocal       query.Bool               `json:"is_local"`
+	Inputs        []*decodedInput          `json:"inputs"`
+	Outputs       []*query.AnnotatedOutput `json:"outputs"`
+	FeeAssetID    bc.AssetID               `json:"fee_asset_id"`
+	Fee           uint64                   `json:"fee"`
+}
+
+// decodedInput is an annotated input together with the output it
+// spends, if that output is known to this Core.
+type decodedInput struct {
+	*query.AnnotatedInput
+	SpentOutput *query.AnnotatedOutput `json:"spent_output,omitempty"`
+}
+
+// decodeTx is an http handler for decoding a raw transaction into
+// annotated, human-readable JSON. The transaction is not validated
+// or submitted.
+//
+// POST /decode-tx
+func (a *API) decodeTx(ctx context.Context, in decodeTxRequest) (*decodedTx, error) {
+	tx := in.RawTransaction
+	if tx == nil {
+		return nil, errors.Wrap(txbuilder.ErrMissingRawTx)
+	}
+
+	annotated, err := a.indexer.AnnotateTx(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	var spentIDs []bc.Hash
+	for _, in := range annotated.Inputs {
+		if in.SpentOutputID != nil {
+			spentIDs = append(spentIDs, *in.SpentOutputID)
+		}
+	}
+	spent, err := a.indexer.OutputsByID(ctx, spentIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &decodedTx{
+		ID:            annotated.ID,
+		Version:       tx.Version,
+		MinTime:       millisTime(tx.MinTime),
+		MaxTime:       millisTime(tx.MaxTime),
+		ReferenceData: annotated.ReferenceData,
+		IsLocal:       annotated.IsLocal,
+		Inputs:        make([]*decodedInput, 0, len(annotated.Inputs)),
+		Outputs:       annotated.Outputs,
+		FeeAssetID:    a.feeAsset,
+	}
+	for _, in := range annotated.Inputs {
+		d := &decodedInput{AnnotatedInput: in}
+		if in.SpentOutputID != nil {
+			d.SpentOutput = spent[*in.SpentOutputID]
+		}
+		res.Inputs = append(res.Inputs, d)
+	}
+	for _, out := range annotated.Outputs {
+		if out.Type == "retire" && out.AssetID == a.feeAsset {
+			res.Fee += out.Amount
+		}
+	}
+	return res, nil
+}
+
+// millisTime converts a transaction time bound in milliseconds to a
+// time, or nil if the bound is unset.
+func millisTime(ms uint64) *time.Time {
+	if ms == 0 {
+		return nil
+	}
+	t := time.Unix(0, int64(ms)*int64(time.Millisecond)).UTC()
+	return &t
+}
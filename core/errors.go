@@ -8,15 +8,20 @@ import (
 	"github.com/chainmint/core/asset"
 	"github.com/chainmint/core/blocksigner"
 	"github.com/chainmint/core/config"
+	"github.com/chainmint/core/generator"
 	"github.com/chainmint/core/leader"
+	"github.com/chainmint/core/pubsub"
 	"github.com/chainmint/core/query"
 	"github.com/chainmint/core/query/filter"
 	"github.com/chainmint/core/rpc"
 	"github.com/chainmint/core/signers"
 	"github.com/chainmint/core/txbuilder"
 	"github.com/chainmint/core/txfeed"
+	"github.com/chainmint/core/txsigner"
 	"github.com/chainmint/database/pg"
 	"github.com/chainmint/errors"
+	"github.com/chainmint/errors/errcode"
+	"github.com/chainmint/log"
 	"github.com/chainmint/net/http/authz"
 	"github.com/chainmint/net/http/httperror"
 	"github.com/chainmint/net/http/httpjson"
@@ -31,6 +36,8 @@ func isTemporary(info httperror.Info, err error) bool {
 		return true
 	case "CH761": // outputs currently reserved
 		return true
+	case errcode.PoolFull, errcode.Conflict, errcode.RateLimited, errcode.BlockFull:
+		return true
 	case "CH706": // 1 or more action errors
 		errs := errors.Data(err)["actions"].([]httperror.Response)
 		temp := true
@@ -82,10 +89,29 @@ var errorFormatter = httperror.Formatter{
 		config.ErrNoBlockPub:           {400, "CH109", "Block Pub cannot be empty when configuring a mockhsm disabled signer"},
 		errNoMockHSM:                   {400, "CH110", "This endpoint is disabled for this server's configuration"},
 		errNoReset:                     {400, "CH110", "This endpoint is disabled for this server's configuration"},
+		errNoGenerator:                 {400, "CH110", "This endpoint is disabled for this server's configuration"},
+		errNoStateExporter:             {400, "CH110", "This endpoint is disabled for this server's configuration"},
+		errNoTxSigner:                  {400, "CH110", "This endpoint is disabled for this server's configuration"},
+		errNoRollback:                  {400, "CH110", "This endpoint is disabled for this server's configuration"},
 		config.ErrNoBlockHSMURL:        {400, "CH111", "Block HSM URL cannot be empty when configuring a non mockhsm signer"},
 		errNoClientTokens:              {400, "CH120", "Cannot enable client authentication with no client tokens"},
 		blocksigner.ErrConsensusChange: {400, "CH150", "Refuse to sign block with consensus change"},
+		blocksigner.ErrDoubleSign:      {400, "CH151", "Refuse to sign block at or below the last signed height"},
 		//errMissingAddr:                 {400, "CH160", "Address is missing"},
+		pubsub.ErrBadFilter: {400, "CH170", "Invalid event subscription filter"},
+		errSubscriberBehind: {503, "CH171", "Event subscriber fell behind; resubscribe"},
+		errNotReconciled:    {503, "CH172", "Node state is not reconciled; writes are refused until it is"},
+		errNoOutputIndex:    {400, "CH173", "The output index needed to export the UTXO set is unavailable"},
+		errUTXOSetStale:     {503, "CH174", "The output index is behind the chain; retry shortly"},
+		errPeerTLSRequired:  {401, "CH175", "Inter-node RPC requires a mutual TLS connection"},
+		errPeerUnknown:      {403, "CH176", "Peer certificate identity is not allowlisted"},
+		errPeerScope:        {403, "CH177", "Peer identity is not allowed to call this RPC"},
+		errBadRollback:      {400, "CH178", "Cannot roll back the chain to the requested height"},
+		errReplayPruned:     {400, "CH179", "Blocks needed to replay the chain have been pruned"},
+		errBadFeeRequest:    {400, "CH180", "Invalid fee estimate request"},
+		errNoFeeEstimate:    {503, "CH181", "No recent blocks to estimate fees from"},
+		errNoBackup:         {400, "CH182", "Core cannot back up its database"},
+		log.ErrBadLevel:     {400, "CH183", "Invalid log level"},
 
 		// Signers error namespace (2xx)
 		signers.ErrBadQuorum: {400, "CH200", "Quorum must be greater than 1 and less than or equal to the length of xpubs"},
@@ -108,6 +134,7 @@ var errorFormatter = httperror.Formatter{
 		query.ErrBadAfter:               {400, "CH600", "Malformed pagination parameter `after`"},
 		query.ErrParameterCountMismatch: {400, "CH601", "Incorrect number of parameters to filter"},
 		filter.ErrBadFilter:             {400, "CH602", "Malformed query filter"},
+		query.ErrBadCategoryRule:        {400, "CH603", "Invalid category rule"},
 
 		// Transaction error namespace (7xx)
 		// Build error namespace (70x)
@@ -128,6 +155,16 @@ var errorFormatter = httperror.Formatter{
 		txbuilder.ErrNoTxSighashCommitment: {400, "CH736", "Transaction is not final, additional actions still allowed"},
 		txbuilder.ErrTxSignatureFailure:    {400, "CH737", "Transaction signature missing, client may be missing signature key"},
 		txbuilder.ErrNoTxSighashAttempt:    {400, "CH738", "Transaction signature was not attempted"},
+		txbuilder.ErrTemplateMismatch:      {400, "CH739", "Transaction templates to merge do not match"},
+		txbuilder.ErrBadEscrow:             {400, "CH740", "Invalid escrow"},
+		errNotEscrow:                       {400, "CH741", "Output is not an unspent escrow"},
+		generator.ErrNotPending:            {400, "CH743", "Transaction is not in the pending pool"},
+		txbuilder.ErrUnbalanced:            {400, "CH744", "Combined transaction does not balance"},
+		txsigner.ErrBadSignature:           {502, "CH745", "Transaction signer returned an invalid signature"},
+		ErrTxRejected:                      {400, "CH746", "Transaction rejected by the node's mempool checks"},
+		errBatchTooLarge:                   {400, "CH747", "Too many transactions in batch"},
+		errBatchConflict:                   {400, "CH748", "Transaction conflicts with another transaction in the batch"},
+		errBatchDependency:                 {400, "CH749", "Transaction depends on a rejected transaction in the batch"},
 
 		// account action error namespace (76x)
 		account.ErrInsufficient: {400, "CH760", "Insufficient funds for tx"},
@@ -135,4 +172,24 @@ var errorFormatter = httperror.Formatter{
 
 		// Mock HSM error namespace (80x)
 	},
+	// Errors carrying codes, from package errcode, are reported by
+	// code, in place of their roots above.
+	Codes: map[string]httperror.Info{
+		// Transaction validity error namespace (77x and 78x)
+		errcode.BadSignature:     {400, errcode.BadSignature, "Transaction signature or program check failed"},
+		errcode.DoubleSpend:      {400, errcode.DoubleSpend, "Transaction spends an output that is spent or does not exist"},
+		errcode.NonceConflict:    {400, errcode.NonceConflict, "Transaction reuses an issuance nonce"},
+		errcode.Unbalanced:       {400, errcode.Unbalanced, "Transaction inputs and outputs do not balance"},
+		errcode.Expired:          {400, errcode.Expired, "Transaction is outside its time range"},
+		errcode.WrongBlockchain:  {400, errcode.WrongBlockchain, "Transaction is for a different blockchain"},
+		errcode.InvalidTx:        {400, errcode.InvalidTx, "Transaction is invalid"},
+		errcode.FeeTooLow:        {400, errcode.FeeTooLow, "Transaction fee is below the minimum"},
+		errcode.PoolFull:         {503, errcode.PoolFull, "Pending transaction pool is full; try again with a higher fee or later"},
+		errcode.Conflict:         {409, errcode.Conflict, "Transaction conflicts with a pending transaction"},
+		errcode.RateLimited:      {429, errcode.RateLimited, "Sender rate limit exceeded"},
+		errcode.PolicyRejected:   {400, errcode.PolicyRejected, "Transaction rejected by a node policy"},
+		errcode.BlockFull:        {503, errcode.BlockFull, "Block is full"},
+		errcode.BadMemo:          {400, errcode.BadMemo, "Transaction memo is too large, underpaid, or does not match the schema"},
+		errcode.BadStakingAction: {400, errcode.BadStakingAction, "Invalid validator, delegation or governance action"},
+	},
 }
@@ -21,6 +21,8 @@ func TestErrorMapping(t *testing.T) {
 		{errors.Wrap(pg.ErrUserInputNotFound, "foo"), `{"code":"CH002","message":"Not found","temporary":false}`, 400},
 		{errors.WithDetail(pg.ErrUserInputNotFound, "foo"), `{"code":"CH002","message":"Not found","detail":"foo","temporary":false}`, 400},
 		{context.DeadlineExceeded, `{"code":"CH001","message":"Request timed out","temporary":true}`, 408},
+		{errors.WithCode(errors.WithDetail(ErrTxRejected, "invalid prevout"), "CH771"), `{"code":"CH771","message":"Transaction spends an output that is spent or does not exist","detail":"invalid prevout","temporary":false}`, 400},
+		{errors.WithCode(ErrTxRejected, ""), `{"code":"CH746","message":"Transaction rejected by the node's mempool checks","temporary":false}`, 400},
 	}
 
 	for _, test := range cases {
@@ -0,0 +1,153 @@
+package core
+
+import (
+	"context"
+	stdjson "encoding/json"
+	"math"
+	"time"
+
+	"github.com/chainmint/core/txbuilder"
+	"github.com/chainmint/crypto/ed25519"
+	"github.com/chainmint/database/pg"
+	"github.com/chainmint/encoding/json"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/vm"
+	"github.com/chainmint/protocol/vmutil"
+)
+
+var errNotEscrow = errors.New("output is not an unspent escrow")
+
+// escrow is an unspent escrow output, as returned by /list-escrows.
+type escrow struct {
+	OutputID           bc.Hash       `json:"output_id"`
+	TransactionID      bc.Hash       `json:"transaction_id"`
+	Position           int           `json:"position"`
+	AssetID            bc.AssetID    `json:"asset_id"`
+	Amount             uint64        `json:"amount"`
+	OraclePubKey       json.HexBytes `json:"oracle_pub_key"`
+	BeneficiaryProgram json.HexBytes `json:"beneficiary_control_program"`
+	RefundProgram      json.HexBytes `json:"refund_control_program"`
+	RefundAt           time.Time     `json:"refund_at"`
+
+	blockHeight uint64
+	txPos       uint32
+}
+
+// unspentEscrows returns the unspent escrow outputs, optionally
+// restricted to the one with the given ID. Escrow programs begin with
+// JUMPIF, which narrows the scan before the programs are parsed.
+func (a *API) unspentEscrows(ctx context.Context, outputID *bc.Hash) ([]*escrow, error) {
+	const q = `
+		SELECT output_id, tx_hash, output_index, asset_id, amount,
+			control_program, block_height, tx_pos
+		FROM annotated_outputs
+		WHERE timespan @> $1::int8 AND get_byte(control_program, 0) = $2
+			AND ($3::bytea IS NULL OR output_id = $3)
+		ORDER BY block_height, tx_pos, output_index
+	`
+	var idBytes []byte
+	if outputID != nil {
+		idBytes = outputID.Bytes()
+	}
+	escrows := []*escrow{}
+	err := pg.ForQueryRows(ctx, a.db, q, int64(math.MaxInt64), int(vm.OP_JUMPIF), idBytes,
+		func(outID, txHash bc.Hash, pos int, assetID bc.AssetID, amount uint64, prog []byte, height uint64, txPos uint32) {
+			oracle, beneficiary, refund, refundMS, ok := vmutil.ParseEscrowProgram(prog)
+			if !ok {
+				return
+			}
+			escrows = append(escrows, &escrow{
+				OutputID:           outID,
+				TransactionID:      txHash,
+				Position:           pos,
+				AssetID:            assetID,
+				Amount:             amount,
+				OraclePubKey:       json.HexBytes(oracle),
+				BeneficiaryProgram: beneficiary,
+				RefundProgram:      refund,
+				RefundAt:           time.Unix(0, int64(refundMS)*int64(time.Millisecond)).UTC(),
+				blockHeight:        height,
+				txPos:              txPos,
+			})
+		})
+	return escrows, errors.Wrap(err, "querying escrows")
+}
+
+// listEscrows is an http handler for listing the unspent escrow
+// outputs, oldest first.
+//
+// POST /list-escrows
+func (a *API) listEscrows(ctx context.Context) ([]*escrow, error) {
+	return a.unspentEscrows(ctx, nil)
+}
+
+func (a *API) decodeSpendEscrowAction(data []byte) (txbuilder.Action, error) {
+	act := &spendEscrowAction{api: a}
+	err := stdjson.Unmarshal(data, act)
+	return act, err
+}
+
+// spendEscrowAction spends an escrow output and pays its value to
+// the beneficiary, if OracleSignature is set, or otherwise back to
+// the refund program. Neither path commits to the rest of the
+// transaction, so it is normally combined with signed actions, such
+// as a fee payment from an account.
+type spendEscrowAction struct {
+	api             *API
+	OutputID        *bc.Hash      `json:"output_id"`
+	OracleSignature json.HexBytes `json:"oracle_signature"`
+	ReferenceData   json.Map      `json:"reference_data"`
+}
+
+func (act *spendEscrowAction) Build(ctx context.Context, b *txbuilder.TemplateBuilder) error {
+	if act.OutputID == nil {
+		return txbuilder.MissingFieldsError("output_id")
+	}
+	escrows, err := act.api.unspentEscrows(ctx, act.OutputID)
+	if err != nil {
+		return err
+	}
+	if len(escrows) == 0 {
+		return errors.WithDetailf(errNotEscrow, "output %x", act.OutputID.Bytes())
+	}
+	e := escrows[0]
+
+	block, err := act.api.chain.GetBlock(ctx, e.blockHeight)
+	if err != nil {
+		return errors.Wrap(err, "loading escrow block")
+	}
+	if int(e.txPos) >= len(block.Transactions) {
+		return errors.Wrapf(errNotEscrow, "tx position %d in block %d", e.txPos, e.blockHeight)
+	}
+	tx := block.Transactions[e.txPos]
+	out, ok := tx.Entries[*tx.ResultIds[e.Position]].(*bc.Output)
+	if !ok {
+		return errors.WithDetailf(errNotEscrow, "output %x", act.OutputID.Bytes())
+	}
+
+	index := vm.Int64Bytes(int64(b.NextOutputIndex()))
+	var (
+		args  [][]byte
+		payTo []byte
+	)
+	if len(act.OracleSignature) > 0 {
+		if !ed25519.Verify(ed25519.PublicKey(e.OraclePubKey), e.OutputID.Bytes(), act.OracleSignature) {
+			return errors.WithDetail(txbuilder.ErrBadEscrow, "oracle signature does not sign the escrow output id")
+		}
+		args = [][]byte{index, act.OracleSignature, {vmutil.EscrowRelease}}
+		payTo = e.BeneficiaryProgram
+	} else {
+		args = [][]byte{index, {vmutil.EscrowRefund}}
+		payTo = e.RefundProgram
+		b.RestrictMinTime(e.RefundAt)
+	}
+
+	in := legacy.NewSpendInput(args, *out.Source.Ref, e.AssetID, e.Amount, out.Source.Position, out.ControlProgram.Code, *out.Data, act.ReferenceData)
+	err = b.AddInput(in, &txbuilder.SigningInstruction{})
+	if err != nil {
+		return err
+	}
+	return b.AddOutput(legacy.NewTxOutput(e.AssetID, e.Amount, payTo, nil))
+}
@@ -0,0 +1,201 @@
+package core
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/chainmint/core/pubsub"
+	"github.com/chainmint/core/query"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/log"
+	"github.com/chainmint/protocol/bc"
+)
+
+const (
+	// eventBuffer is how many events a subscriber may fall
+	// behind before it is disconnected.
+	eventBuffer = 256
+
+	eventWriteTimeout = 10 * time.Second
+)
+
+var errSubscriberBehind = errors.New("event subscriber fell behind")
+
+// Events returns the broker that publishes chain events to
+// subscribers of /subscribe.
+func (a *API) Events() *pubsub.Broker {
+	return a.events
+}
+
+// blockEvent is the data of a block event.
+type blockEvent struct {
+	ID                string    `json:"id"`
+	Height            uint64    `json:"height"`
+	Timestamp         time.Time `json:"timestamp"`
+	TransactionsCount int       `json:"transactions_count"`
+}
+
+// publishEvents publishes a block event for each new block, and a
+// transaction event for each of its transactions and an anomaly
+// event for each flag raised on them once they have been indexed. It returns when ctx is canceled.
+func (a *API) publishEvents(ctx context.Context) {
+	height := a.chain.Height()
+	for {
+		height++
+		select {
+		case <-ctx.Done():
+			return
+		case <-a.chain.BlockWaiter(height):
+		}
+		if a.indexTxs {
+			select {
+			case <-ctx.Done():
+				return
+			case <-a.pinStore.PinWaiter(query.TxPinName, height):
+			}
+		}
+		err := a.publishBlock(ctx, height)
+		if err != nil {
+			log.Error(ctx, err, "publishing events for block", height)
+		}
+	}
+}
+
+func (a *API) publishBlock(ctx context.Context, height uint64) error {
+	b, err := a.indexer.Block(ctx, height)
+	if err != nil {
+		return err
+	}
+	a.events.Publish(&pubsub.Event{
+		Type:   pubsub.TypeBlock,
+		Height: height,
+		Data: blockEvent{
+			ID:                b.ID.HexString(),
+			Height:            b.Height,
+			Timestamp:         b.Timestamp,
+			TransactionsCount: b.TransactionsCount,
+		},
+	})
+	for _, tx := range b.Transactions {
+		a.events.Publish(&pubsub.Event{
+			Type:   pubsub.TypeTransaction,
+			Height: height,
+			Data:   tx,
+			Topics: txTopics(tx),
+		})
+	}
+	if len(a.detectors) == 0 {
+		return nil
+	}
+	flags, err := a.indexer.TxFlagsAt(ctx, height)
+	if err != nil {
+		return err
+	}
+	for _, f := range flags {
+		var t pubsub.Topics
+		if f.AccountID != "" {
+			t.AccountIDs = []string{f.AccountID}
+		}
+		a.events.Publish(&pubsub.Event{
+			Type:   pubsub.TypeAnomaly,
+			Height: height,
+			Data:   f,
+			Topics: t,
+		})
+	}
+	return nil
+}
+
+// txTopics returns the accounts, assets and control programs of
+// the inputs and outputs of tx.
+func txTopics(tx *query.AnnotatedTx) pubsub.Topics {
+	var t pubsub.Topics
+	for _, in := range tx.Inputs {
+		if in.AccountID != "" {
+			t.AccountIDs = append(t.AccountIDs, in.AccountID)
+		}
+		t.AssetIDs = append(t.AssetIDs, bc.Hash(in.AssetID).HexString())
+		if len(in.ControlProgram) > 0 {
+			t.ControlPrograms = append(t.ControlPrograms, hex.EncodeToString(in.ControlProgram))
+		}
+	}
+	for _, out := range tx.Outputs {
+		if out.AccountID != "" {
+			t.AccountIDs = append(t.AccountIDs, out.AccountID)
+		}
+		t.AssetIDs = append(t.AssetIDs, bc.Hash(out.AssetID).HexString())
+		t.ControlPrograms = append(t.ControlPrograms, hex.EncodeToString(out.ControlProgram))
+	}
+	return t
+}
+
+// serveEvents streams chain events over a WebSocket connection.
+// The client first sends a pubsub.Filter as JSON, for example
+// {"types": ["transaction"], "account_ids": ["acc1"]}; the server
+// then sends each matching event as a JSON message until either
+// side closes the connection. Subscribers that fall too far behind
+// are sent an error and disconnected.
+//
+// GET /subscribe (WebSocket)
+func (a *API) serveEvents(ws *websocket.Conn) {
+	defer ws.Close()
+	ctx := ws.Request().Context()
+	if a.events == nil {
+		sendEventError(ctx, ws, errUnconfigured)
+		return
+	}
+
+	var f pubsub.Filter
+	err := websocket.JSON.Receive(ws, &f)
+	if err != nil {
+		err = errors.WithDetail(pubsub.ErrBadFilter, err.Error())
+	} else {
+		err = f.Validate()
+	}
+	if err != nil {
+		sendEventError(ctx, ws, err)
+		return
+	}
+
+	sub := a.events.Subscribe(f, eventBuffer)
+	defer sub.Close()
+
+	// The client sends nothing after the filter, so the read
+	// returns only once the connection is closed.
+	gone := make(chan struct{})
+	go func() {
+		io.Copy(ioutil.Discard, ws)
+		close(gone)
+	}()
+
+	for {
+		select {
+		case <-gone:
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				sendEventError(ctx, ws, errSubscriberBehind)
+				return
+			}
+			ws.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+			err := websocket.JSON.Send(ws, e)
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+func sendEventError(ctx context.Context, ws *websocket.Conn, err error) {
+	errorFormatter.Log(ctx, err)
+	ws.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+	websocket.JSON.Send(ws, struct {
+		Type string      `json:"type"`
+		Data interface{} `json:"data"`
+	}{"error", errorFormatter.Format(err)})
+}
@@ -4,10 +4,14 @@ package core
 
 import (
 	"os"
+	"strconv"
 	"strings"
 	"syscall"
 )
 
+// canExecSelfRollback reports whether execSelfRollback is supported.
+const canExecSelfRollback = true
+
 // execSelf execs the currently-running binary with os.Args.
 // If dataToReset is nonempty, it adds RESET=dataToReset
 // to the environment of the new process.
@@ -29,6 +33,22 @@ func execSelf(dataToReset string) {
 	}
 }
 
+// execSelfRollback execs the currently-running binary with os.Args,
+// adding ROLLBACK_BLOCKS=blocks to the environment of the new
+// process, so that it rolls back the chain as it starts.
+func execSelfRollback(blocks uint64) {
+	binpath, err := os.Executable()
+	if err != nil {
+		panic(err)
+	}
+
+	env := mergeEnvLists([]string{"ROLLBACK_BLOCKS=" + strconv.FormatUint(blocks, 10)}, os.Environ())
+	err = syscall.Exec(binpath, os.Args, env)
+	if err != nil {
+		panic(err)
+	}
+}
+
 // mergeEnvLists merges the two environment lists such that
 // variables with the same name in "in" replace those in "out".
 // This always returns a newly allocated slice.
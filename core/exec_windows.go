@@ -29,3 +29,12 @@ func execSelf(dataToReset string) {
 	}
 	os.Exit(0)
 }
+
+// canExecSelfRollback reports whether execSelfRollback is supported.
+// The monitor process restarts cored with a fixed environment, so it
+// cannot pass on the number of blocks to roll back.
+const canExecSelfRollback = false
+
+func execSelfRollback(blocks uint64) {
+	panic("unsupported")
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"context"
+	"math"
+
+	"github.com/chainmint/core/query"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/net/http/httpjson"
+	"github.com/chainmint/protocol/bc"
+)
+
+// getBlock is an http handler for fetching a block, with its
+// annotated transactions, by height or by ID. With neither, it
+// returns the latest block.
+//
+// POST /get-block
+func (a *API) getBlock(ctx context.Context, in struct {
+	Height uint64   `json:"height,omitempty"`
+	ID     *bc.Hash `json:"id,omitempty"`
+}) (*query.AnnotatedBlock, error) {
+	height := in.Height
+	if in.ID != nil {
+		h, err := a.indexer.BlockHeight(ctx, *in.ID)
+		if err != nil {
+			return nil, err
+		}
+		if height != 0 && height != h {
+			return nil, errors.WithDetail(httpjson.ErrBadRequest, "height and id name different blocks")
+		}
+		height = h
+	}
+	if height == 0 {
+		height = a.chain.Height()
+	}
+	return a.indexer.Block(ctx, height)
+}
+
+// getTransaction is an http handler for fetching an annotated
+// transaction by ID.
+//
+// POST /get-transaction
+func (a *API) getTransaction(ctx context.Context, in struct {
+	ID bc.Hash `json:"id"`
+}) (*query.AnnotatedTx, error) {
+	return a.indexer.Transaction(ctx, in.ID)
+}
+
+// getAccountBalances is an http handler for fetching the balance of
+// each asset held by an account, given by ID or alias.
+//
+// POST /get-account-balances
+func (a *API) getAccountBalances(ctx context.Context, in struct {
+	AccountID    string `json:"account_id,omitempty"`
+	AccountAlias string `json:"account_alias,omitempty"`
+	TimestampMS  uint64 `json:"timestamp,omitempty"`
+}) (page, error) {
+	account := in.AccountID
+	if account == "" {
+		account = in.AccountAlias
+	}
+	if account == "" {
+		return page{}, errors.WithDetail(httpjson.ErrBadRequest, "account_id or account_alias is required")
+	}
+	if in.TimestampMS > math.MaxInt64 {
+		return page{}, errors.WithDetail(httpjson.ErrBadRequest, "timestamp is too large")
+	}
+	balances, err := a.indexer.AccountBalances(ctx, account, in.TimestampMS)
+	if err != nil {
+		return page{}, err
+	}
+	return page{Items: httpjson.Array(balances), LastPage: true}, nil
+}
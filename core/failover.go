@@ -0,0 +1,44 @@
+package core
+
+import (
+	"context"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/log"
+)
+
+// lead readies this process to generate blocks on its election as
+// leader. The processes standing by follow the chain's height as the
+// leader commits blocks, but not its state, so lead first recovers
+// the state the previous leader committed to the database. It then
+// takes over processing blocks, resuming from the pins the previous
+// leader left, and calls the function given to GeneratorFailover.
+//
+// If the process is later deposed, such as because it could not
+// renew its lease, it re-executes itself to stand by afresh: the new
+// leader now commits blocks, and state held in memory here would go
+// stale. Shutting down, signaled by ctx, does not re-execute it.
+func (a *API) lead(ctx, leadCtx context.Context) {
+	_, _, err := a.chain.Recover(leadCtx)
+	if err != nil {
+		log.Fatalkv(leadCtx, log.KeyError, errors.Wrap(err, "recovering chain state to take over"))
+	}
+	err = a.processBlocks(leadCtx)
+	if err != nil {
+		log.Fatalkv(leadCtx, log.KeyError, errors.Wrap(err, "processing blocks"))
+	}
+	err = a.failoverLead(leadCtx)
+	if err != nil {
+		log.Fatalkv(leadCtx, log.KeyError, errors.Wrap(err, "taking over block generation"))
+	}
+	log.Printkv(leadCtx, "at", "took over block generation", "height", a.chain.Height())
+
+	go func() {
+		<-leadCtx.Done()
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printkv(ctx, "at", "deposed as leader; restarting to stand by")
+		execSelf("")
+	}()
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chainmint/protocol"
+	"github.com/chainmint/protocol/prottest/memstore"
+	"github.com/chainmint/protocol/state"
+)
+
+func TestLead(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The previous leader commits blocks to the shared store.
+	store := memstore.New()
+	b1, err := protocol.NewInitialBlock(nil, 0, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev, err := protocol.NewChain(ctx, b1.Hash(), store, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = prev.CommitAppliedBlock(ctx, b1, state.Empty())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, s := b1, state.Empty()
+	for _, ms := range []uint64{2000, 3000} {
+		b, s, err = prev.GenerateBlock(ctx, b, s, ms, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = prev.CommitAppliedBlock(ctx, b, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := protocol.NewChain(ctx, b1.Hash(), store, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := c.State(); b != nil {
+		t.Fatalf("standby chain has state at height %d", b.Height)
+	}
+
+	var led bool
+	a := &API{chain: c, failoverLead: func(context.Context) error {
+		led = true
+		return nil
+	}}
+	leadCtx, deposed := context.WithCancel(ctx)
+	defer deposed()
+	a.lead(ctx, leadCtx)
+
+	if !led {
+		t.Error("lead function not called")
+	}
+	got, gotState := c.State()
+	if got == nil || got.Height != 3 {
+		t.Fatalf("recovered block = %v, want height 3", got)
+	}
+	if gotState.Tree.RootHash() != s.Tree.RootHash() {
+		t.Error("recovered state differs from the previous leader's")
+	}
+	// Shutting down must not re-execute the process.
+	cancel()
+}
@@ -0,0 +1,166 @@
+package core
+
+import (
+	"context"
+	"io/ioutil"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/log"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/strategies"
+)
+
+// feeEstimateConfidence is the probability with which a tx paying
+// the estimated fee is included within the target number of blocks.
+const feeEstimateConfidence = 0.95
+
+var (
+	errNoFeeEstimate = errors.New("no recent blocks to estimate fees from")
+	errBadFeeRequest = errors.New("invalid fee estimate request")
+)
+
+// feeTracker keeps the least fee per kilobyte paid by a tx included
+// in each of the most recent blocks holding txs.
+type feeTracker struct {
+	mu     sync.Mutex
+	blocks int
+	mins   []uint64 // oldest first
+}
+
+// observe records the least fee per kilobyte, in units of feeAsset,
+// paid by the txs in b. Blocks without txs are not recorded.
+func (t *feeTracker) observe(b *legacy.Block, feeAsset bc.AssetID) {
+	if len(b.Transactions) == 0 {
+		return
+	}
+	min := uint64(math.MaxUint64)
+	for _, tx := range b.Transactions {
+		if rate := feePerKB(tx, feeAsset); rate < min {
+			min = rate
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.mins = append(t.mins, min)
+	if len(t.mins) > t.blocks {
+		t.mins = append([]uint64(nil), t.mins[len(t.mins)-t.blocks:]...)
+	}
+}
+
+// estimate returns the least fee per kilobyte with which a tx would
+// have been included, with probability feeEstimateConfidence, in one
+// of target blocks, had it competed with the txs of the recent
+// blocks, and the number of blocks the estimate is based on. A tx
+// paying a rate at least the least rate a block included is taken
+// to have had room in that block.
+func (t *feeTracker) estimate(target int) (uint64, int) {
+	t.mu.Lock()
+	mins := append([]uint64(nil), t.mins...)
+	t.mu.Unlock()
+	if len(mins) == 0 {
+		return 0, 0
+	}
+	sort.Slice(mins, func(i, j int) bool { return mins[i] < mins[j] })
+
+	// A tx missing each block with probability miss misses target
+	// blocks in a row with probability miss^target.
+	maxMiss := math.Pow(1-feeEstimateConfidence, 1/float64(target))
+	n := len(mins)
+	for i, rate := range mins {
+		if i+1 < n && mins[i+1] == rate {
+			continue
+		}
+		if float64(n-i-1)/float64(n) <= maxMiss {
+			return rate, n
+		}
+	}
+	return mins[n-1], n
+}
+
+// feePerKB returns the fee tx pays, in units of feeAsset, for each
+// 1000 bytes of its serialization.
+func feePerKB(tx *legacy.Tx, feeAsset bc.AssetID) uint64 {
+	size, _ := tx.WriteTo(ioutil.Discard)
+	if size <= 0 {
+		return 0
+	}
+	fee := strategies.Fee(tx, feeAsset)
+	if fee > math.MaxUint64/1000 {
+		return fee / uint64(size) * 1000
+	}
+	return fee * 1000 / uint64(size)
+}
+
+// trackFees records the fees paid in the last blocks the tracker
+// keeps, as far as they are still stored, and then in each new block.
+// It returns when ctx is canceled.
+func (a *API) trackFees(ctx context.Context) {
+	height := a.chain.Height()
+	from := uint64(1)
+	if height > uint64(a.fees.blocks) {
+		from = height - uint64(a.fees.blocks) + 1
+	}
+	for h := from; h <= height; h++ {
+		b, err := a.chain.GetBlock(ctx, h)
+		if err != nil {
+			continue // pruned
+		}
+		a.fees.observe(b, a.feeAsset)
+	}
+	for {
+		height++
+		select {
+		case <-ctx.Done():
+			return
+		case <-a.chain.BlockWaiter(height):
+		}
+		b, err := a.chain.GetBlock(ctx, height)
+		if err != nil {
+			log.Error(ctx, err, "tracking fees of block", height)
+			continue
+		}
+		a.fees.observe(b, a.feeAsset)
+	}
+}
+
+// feeEstimate is the response of /estimate-fee.
+type feeEstimate struct {
+	BlockTarget  int    `json:"block_target"`
+	FeePerKB     uint64 `json:"fee_per_kb"`
+	Fee          uint64 `json:"fee,omitempty"`
+	SampleBlocks int    `json:"sample_blocks"`
+}
+
+// estimateFee estimates the fee per kilobyte, in units of the fee
+// asset, that gets a tx into one of the next BlockTarget blocks, from
+// the least fees paid in recent blocks. Given the Size of a tx, in
+// bytes, it also estimates the fee for that tx.
+//
+// POST /estimate-fee
+func (a *API) estimateFee(ctx context.Context, req struct {
+	BlockTarget int   `json:"block_target"`
+	Size        int64 `json:"size"`
+}) (*feeEstimate, error) {
+	if req.BlockTarget == 0 {
+		req.BlockTarget = 1
+	}
+	if req.BlockTarget < 0 || req.Size < 0 {
+		return nil, errors.WithDetailf(errBadFeeRequest, "block target %d, size %d", req.BlockTarget, req.Size)
+	}
+	if a.fees == nil {
+		return nil, errors.WithDetail(errNoFeeEstimate, "fee estimation is disabled")
+	}
+	rate, n := a.fees.estimate(req.BlockTarget)
+	if n == 0 {
+		return nil, errNoFeeEstimate
+	}
+	est := &feeEstimate{BlockTarget: req.BlockTarget, FeePerKB: rate, SampleBlocks: n}
+	if req.Size > 0 {
+		est.Fee = (rate*uint64(req.Size) + 999) / 1000
+	}
+	return est, nil
+}
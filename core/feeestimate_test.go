@@ -0,0 +1,32 @@
+package core
+
+import "testing"
+
+func TestFeeTrackerEstimate(t *testing.T) {
+	ft := &feeTracker{blocks: 20}
+	if _, n := ft.estimate(1); n != 0 {
+		t.Errorf("estimate with no blocks used %d blocks, want 0", n)
+	}
+
+	// Of 20 blocks, two took at least 100 per kilobyte, three
+	// more at least 50, and the rest anything.
+	for i := 0; i < 15; i++ {
+		ft.mins = append(ft.mins, 0)
+	}
+	ft.mins = append(ft.mins, 100, 50, 50, 100, 50)
+
+	cases := []struct {
+		target int
+		want   uint64
+	}{
+		{1, 100}, // 50 misses 2 blocks in 20, more than 5%
+		{2, 50},  // 50 misses 2 blocks in 20 twice 1% of the time
+		{10, 0},  // 0 misses 5 blocks in 20 ten times 0.0001% of the time
+	}
+	for _, c := range cases {
+		got, n := ft.estimate(c.target)
+		if got != c.want || n != 20 {
+			t.Errorf("estimate(%d) = %d from %d blocks, want %d from 20", c.target, got, n, c.want)
+		}
+	}
+}
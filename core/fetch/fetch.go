@@ -17,6 +17,8 @@ import (
 	"github.com/chainmint/protocol/state"
 )
 
+var fetchLog = log.Module("fetch")
+
 const heightPollingPeriod = 3 * time.Second
 
 var (
@@ -54,7 +56,7 @@ func Fetch(ctx context.Context, c *protocol.Chain, peer *rpc.Client, health func
 	for {
 		select {
 		case <-ctx.Done():
-			log.Printf(ctx, "Deposed, Fetch exiting")
+			fetchLog.Infokv(ctx, log.KeyMessage, "deposed, Fetch exiting")
 			return
 		case err = <-errch:
 			health(err)
@@ -134,7 +136,7 @@ func pollGeneratorHeight(ctx context.Context, peer *rpc.Client) {
 	for {
 		select {
 		case <-ctx.Done():
-			log.Printf(ctx, "Deposed, fetchGeneratorHeight exiting")
+			fetchLog.Infokv(ctx, log.KeyMessage, "deposed, fetchGeneratorHeight exiting")
 			ticker.Stop()
 			return
 		case <-ticker.C:
@@ -218,7 +220,7 @@ func getHeight(ctx context.Context, peer *rpc.Client) (uint64, error) {
 
 func logNetworkError(ctx context.Context, err error) {
 	if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
-		log.Printf(ctx, "%s", err.Error())
+		fetchLog.Warnkv(ctx, log.KeyError, err)
 	} else {
 		log.Error(ctx, err)
 	}
@@ -0,0 +1,27 @@
+package core
+
+import (
+	"context"
+
+	"github.com/chainmint/net/http/httpjson"
+)
+
+// listTxFlags is an http handler for listing the transactions
+// flagged for review by anomaly detectors, oldest first.
+//
+// POST /list-transaction-flags
+func (a *API) listTxFlags(ctx context.Context, in requestQuery) (page, error) {
+	limit := pageLimit(in)
+	flags, after, err := a.indexer.TxFlags(ctx, in.AccountID, in.After, limit)
+	if err != nil {
+		return page{}, err
+	}
+
+	out := in
+	out.After = after
+	return page{
+		Items:    httpjson.Array(flags),
+		LastPage: len(flags) < limit,
+		Next:     out,
+	}, nil
+}
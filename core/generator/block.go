@@ -12,10 +12,10 @@ import (
 	"github.com/chainmint/errors"
 	"github.com/chainmint/log"
 	"github.com/chainmint/metrics"
-	"github.com/chainmint/protocol/bc"
 	"github.com/chainmint/protocol/bc/legacy"
 	"github.com/chainmint/protocol/state"
 	"github.com/chainmint/protocol/vmutil"
+	"github.com/chainmint/trace"
 )
 
 // errTooFewSigners is returned when a block-signing attempt finds
@@ -40,9 +40,38 @@ func recordSince(t0 time.Time) {
 	latency.RecordSince(t0)
 }
 
-// makeBlock generates a new legacy.Block, collects the required signatures
-// and commits the block to the blockchain.
-func (g *Generator) MakeBlock(ctx context.Context, time uint64) (error, []byte) {
+// makeBlock generates a new legacy.Block from the txs delivered since
+// the last call, collects the required signatures and commits the
+// block to the blockchain. It makes no block if none were delivered,
+// unless set to by SetEmptyBlocks. The delivered txs are consumed
+// whether or not the block is made, so each block holds only the
+// txs of its own round; see Deliver.
+//
+// Database calls, signers and any wait while the generator is paused
+// are bounded by ctx, so a deadline on ctx bounds the whole call. If
+// ctx is done while paused, the delivered txs are kept for the next
+// call. If ctx is done before the block is committed, a block
+// already generated is kept pending and committed by the next call.
+func (g *Generator) MakeBlock(ctx context.Context, timeMS uint64) (err error, hash []byte) {
+	ctx, span := trace.Start(ctx, "generator.MakeBlock")
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
+	err = g.startMaking(ctx)
+	if err != nil {
+		return errors.Wrap(err, "making block"), nil
+	}
+	defer g.doneMaking()
+	g.mu.Lock()
+	txs := g.takeDelivered()
+	emptyBlocks := g.emptyBlocks
+	g.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return errors.Wrap(err, "making block"), nil
+	}
+
 	latestBlock, latestSnapshot := g.chain.State()
 //	var b *legacy.Block
 	var s *state.Snapshot
@@ -61,19 +90,25 @@ func (g *Generator) MakeBlock(ctx context.Context, time uint64) (error, []byte)
 			log.Fatalkv(ctx, log.KeyError, err)
 		}
 	} else {
-		g.mu.Lock()
-		txs := g.pool
-		g.pool = nil
-		g.poolHashes = make(map[bc.Hash]bool)
-		g.mu.Unlock()
-
-		b, s, err = g.chain.GenerateBlock(ctx, latestBlock, latestSnapshot, time, txs)
+		_, genSpan := trace.Start(ctx, "chain.GenerateBlock", "txs", len(txs))
+		b, s, err = g.chain.GenerateBlock(ctx, latestBlock, latestSnapshot, timeMS, txs)
+		genSpan.SetError(err)
+		genSpan.End()
 		if err != nil {
 			return errors.Wrap(err, "generate"), nil
 		}
-		if len(b.Transactions) == 0 {
+		if len(b.Transactions) != len(txs) {
+			// Deliver checked each tx would go in, so this means the
+			// chain block would not match the Tendermint block.
+			return errors.WithDetailf(errBlockMismatch, "%d of %d delivered txs in block %d", len(b.Transactions), len(txs), b.Height), nil
+		}
+		if len(b.Transactions) == 0 && !emptyBlocks {
 			return nil, b.Hash().Bytes() // don't bother making an empty block
 		}
+		err = g.chain.CheckBlockTime(ctx, b, latestBlock)
+		if err != nil {
+			return errors.Wrap(err, "generated block"), nil
+		}
 		err = savePendingBlock(ctx, g.db, b)
 		if err != nil {
 			return errors.Wrap(err, "saving pending block"), nil
@@ -83,15 +118,30 @@ func (g *Generator) MakeBlock(ctx context.Context, time uint64) (error, []byte)
 }
 
 func (g *Generator) commitBlock(ctx context.Context, b *legacy.Block, s *state.Snapshot, prevBlock *legacy.Block) (error, []byte) {
-	err := g.getAndAddBlockSignatures(ctx, b, prevBlock)
+	trace.FromContext(ctx).SetAttributes("height", b.Height, "txs", len(b.Transactions))
+
+	signCtx, span := trace.Start(ctx, "generator.signBlock")
+	err := g.getAndAddBlockSignatures(signCtx, b, prevBlock)
+	span.SetError(err)
+	span.End()
 	if err != nil {
 		return errors.Wrap(err, "sign"), nil
 	}
 
-	err = g.chain.CommitAppliedBlock(ctx, b, s)
+	commitCtx, span := trace.Start(ctx, "chain.CommitAppliedBlock")
+	err = g.chain.CommitAppliedBlock(commitCtx, b, s)
+	span.SetError(err)
+	span.End()
 	if err != nil {
 		return errors.Wrap(err, "commit"), nil
 	}
+
+	// The block may spend outputs that pending txs spend, and
+	// create outputs that orphans spend.
+	g.mu.Lock()
+	g.dropStale(s)
+	g.resolveOrphans()
+	g.mu.Unlock()
 	return nil, b.Hash().Bytes()
 }
 
@@ -126,7 +176,13 @@ func (g *Generator) getAndAddBlockSignatures(ctx context.Context, b, prevBlock *
 
 	nready := 0
 	for i := 0; i < len(g.signers) && nready < quorum; i++ {
-		sig := replies[<-done]
+		var sig []byte
+		select {
+		case j := <-done:
+			sig = replies[j]
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "got %d of %d needed signatures", nready, quorum)
+		}
 		if sig == nil {
 			continue
 		}
@@ -0,0 +1,157 @@
+package generator
+
+import (
+	"context"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/state"
+	"github.com/chainmint/trace"
+)
+
+// ErrUndeliverable is returned by Deliver for a tx that cannot go
+// into the block being made, because it is invalid, outside its time
+// range at the block's time, or does not apply to the chain state
+// after the txs delivered before it.
+var ErrUndeliverable = errors.New("tx cannot go into the block being made")
+
+// errBlockMismatch is returned by MakeBlock if the block generated
+// leaves out any of the txs delivered for it.
+var errBlockMismatch = errors.New("generated block does not hold the delivered txs")
+
+// Deliver adds tx to the block being made, after the txs delivered
+// before it. Blocks are made only from delivered txs, in the order
+// delivered, so that the chain block holds exactly the txs of the
+// Tendermint block that DeliverTx accepted. The pending tx pool is
+// only the local view of the txs submitted and not yet delivered;
+// Deliver removes tx from it.
+//
+// Deliver checks tx against the chain state after the txs delivered
+// before it, and against the block time timeMS, if it is not 0, and
+// returns ErrUndeliverable if it would be left out of the block.
+func (g *Generator) Deliver(ctx context.Context, tx *legacy.Tx, timeMS uint64) (err error) {
+	ctx, span := trace.Start(ctx, "generator.Deliver")
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
+	err = g.chain.ValidateTx(tx.Tx)
+	if err != nil {
+		return errors.Sub(ErrUndeliverable, err)
+	}
+	if timeMS > 0 {
+		if tx.MinTime > 0 && tx.MinTime > timeMS {
+			return errors.WithDetailf(ErrUndeliverable, "tx min time %d is after block time %d", tx.MinTime, timeMS)
+		}
+		if tx.MaxTime > 0 && tx.MaxTime < timeMS {
+			return errors.WithDetailf(ErrUndeliverable, "tx max time %d is before block time %d", tx.MaxTime, timeMS)
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return errors.Wrap(err, "delivering tx")
+	}
+	if g.round == nil {
+		_, s := g.chain.State()
+		if s == nil {
+			s = state.Empty()
+		}
+		g.round = state.Copy(s)
+		if timeMS > 0 {
+			g.round.PruneNonces(timeMS)
+		}
+	}
+	// A tx that fails to apply may have changed the snapshot
+	// partway, so apply it to a copy.
+	s := state.Copy(g.round)
+	err = s.ApplyTx(tx.Tx)
+	if err != nil {
+		return errors.Sub(ErrUndeliverable, err)
+	}
+	g.round = s
+	g.delivered = append(g.delivered, tx)
+	g.settle(tx)
+	return nil
+}
+
+// Pending reports whether the tx with the given ID is pending: in
+// the pool, held as an orphan or delivered
+// for the block being made.
+func (g *Generator) Pending(id bc.Hash) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.poolHashes[id] || g.isOrphan(id) {
+		return true
+	}
+	for _, tx := range g.delivered {
+		if tx.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Withdraw removes tx from the pending txs, as when a tx submitted
+// could not be passed on to Tendermint, so that it may be submitted
+// again.
+func (g *Generator) Withdraw(tx *legacy.Tx) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.settle(tx)
+}
+
+// takeDelivered returns the txs delivered for the block being made
+// and starts the next one. The caller must hold g.mu.
+func (g *Generator) takeDelivered() []*legacy.Tx {
+	txs := g.delivered
+	g.delivered = nil
+	g.round = nil
+	return txs
+}
+
+// settle removes tx from the pool and the orphans. The caller must
+// hold g.mu.
+func (g *Generator) settle(tx *legacy.Tx) {
+	for i, p := range g.pool {
+		if p.ID == tx.ID {
+			g.poolBytes -= g.poolInfo[tx.ID].size
+			delete(g.poolInfo, tx.ID)
+			delete(g.poolHashes, tx.ID)
+			g.pool = append(g.pool[:i:i], g.pool[i+1:]...)
+			g.poolResized()
+			break
+		}
+	}
+	for i, o := range g.orphans {
+		if o.tx.ID == tx.ID {
+			g.orphans = append(g.orphans[:i:i], g.orphans[i+1:]...)
+			orphanCount.Set(int64(len(g.orphans)))
+			break
+		}
+	}
+}
+
+// dropStale removes from the pool the txs spending outputs that are
+// neither in s nor created by the txs pending before them, such as
+// those conflicting with a tx just committed. Unlike eviction, they
+// could not go into any block. The caller must hold g.mu.
+func (g *Generator) dropStale(s *state.Snapshot) {
+	pending := make(map[bc.Hash]bool)
+	for i := 0; i < len(g.pool); {
+		tx := g.pool[i]
+		if !spendable(s, pending, tx) {
+			g.evict(i)
+			continue
+		}
+		for _, id := range tx.ResultIds {
+			pending[*id] = true
+		}
+		i++
+	}
+}
@@ -0,0 +1,70 @@
+package generator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/state"
+)
+
+func TestWithdraw(t *testing.T) {
+	ctx := context.Background()
+	g := New(nil, nil)
+
+	a := poolTx(1, 0, 0, 10)
+	b := poolTx(2, 0, 0, 20)
+	for _, tx := range []*legacy.Tx{a, b} {
+		if err := g.Submit(ctx, tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g.delivered = []*legacy.Tx{poolTx(3, 0, 0, 30)}
+
+	g.Withdraw(a)
+	if g.Pending(a.ID) {
+		t.Error("withdrawn tx still pending")
+	}
+	if got := g.PendingTxs(); len(got) != 1 || got[0] != b {
+		t.Errorf("pending txs = %d, want b", len(got))
+	}
+	if g.poolBytes != g.poolInfo[b.ID].size {
+		t.Errorf("pool bytes = %d, want %d", g.poolBytes, g.poolInfo[b.ID].size)
+	}
+	if !g.Pending(bc.NewHash([32]byte{3})) {
+		t.Error("delivered tx not pending")
+	}
+
+	// The next block starts afresh.
+	if txs := g.takeDelivered(); len(txs) != 1 || g.Pending(txs[0].ID) {
+		t.Errorf("took %d delivered txs, want 1 no longer pending", len(txs))
+	}
+}
+
+func TestDropStale(t *testing.T) {
+	ctx := context.Background()
+	g := New(nil, nil)
+
+	s := state.Empty()
+	s.Tree.Insert(bc.NewHash([32]byte{10}).Bytes())
+
+	spends := poolTx(1, 0, 10, 20)     // spends a committed output
+	child := poolTx(2, 0, 20, 30)      // spends spends
+	stale := poolTx(3, 0, 99, 40)      // spends an output now gone
+	staleChild := poolTx(4, 0, 40, 50) // spends stale
+	for _, tx := range []*legacy.Tx{spends, child, stale, staleChild} {
+		if err := g.Submit(ctx, tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	g.dropStale(s)
+	got := g.PendingTxs()
+	if len(got) != 2 || got[0] != spends || got[1] != child {
+		t.Errorf("pending txs = %d, want spends then child", len(got))
+	}
+	if g.Pending(stale.ID) || g.Pending(staleChild.ID) {
+		t.Error("stale txs still pending")
+	}
+}
@@ -7,15 +7,19 @@ package generator
 import (
 	"context"
 	"sync"
-	//"time"
+	"time"
 
 	"github.com/chainmint/database/pg"
+	"github.com/chainmint/errors"
 	//"github.com/chainmint/log"
 	"github.com/chainmint/protocol"
 	"github.com/chainmint/protocol/bc"
 	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/state"
 )
 
+var generatorLog = log.Module("generator")
+
 // A BlockSigner signs blocks.
 type BlockSigner interface {
 	// SignBlock returns an ed25519 signature over the block's sighash.
@@ -24,8 +28,9 @@ type BlockSigner interface {
 	SignBlock(ctx context.Context, marshalledBlock []byte) (signature []byte, err error)
 }
 
-// Generator collects pending transactions and produces new blocks on
-// an interval.
+// Generator makes chain blocks from the transactions Tendermint
+// delivers, and keeps the pool of transactions submitted locally and
+// not yet delivered.
 type Generator struct {
 	// config
 	db      pg.DB
@@ -35,6 +40,33 @@ type Generator struct {
 	mu         sync.Mutex
 	pool       []*legacy.Tx // in topological order
 	poolHashes map[bc.Hash]bool
+	poolInfo   map[bc.Hash]poolEntry
+	poolBytes  int64 // estimated memory held by pool
+
+	// orphans holds the txs submitted before the txs creating the
+	// outputs they spend, oldest first; see PoolLimits.OrphanTTL
+	orphans []orphan
+
+	// delivered holds the txs delivered for the block being made,
+	// in order, and round the chain state after them; see Deliver
+	delivered []*legacy.Tx
+	round     *state.Snapshot
+
+	// emptyBlocks is whether MakeBlock makes blocks with no txs
+	emptyBlocks bool
+
+	// limits bounds poolBytes; memAvailable is the system's
+	// available memory as of memInfoAt
+	limits       PoolLimits
+	memAvailable int64
+	memInfoAt    time.Time
+
+	// pauseMu guards paused and making; pauseCond is
+	// signaled when either changes.
+	pauseMu   sync.Mutex
+	pauseCond *sync.Cond
+	paused    bool
+	making    bool
 }
 
 // New creates and initializes a new Generator.
@@ -42,15 +74,117 @@ func New(
 	c *protocol.Chain,
 	db pg.DB,
 ) *Generator {
-	return &Generator{
+	g := &Generator{
 		db:         db,
 		chain:      c,
 		poolHashes: make(map[bc.Hash]bool),
+		poolInfo:   make(map[bc.Hash]poolEntry),
 	}
+	g.pauseCond = sync.NewCond(&g.pauseMu)
+	return g
+}
+
+// Pause stops the generator from making new blocks. If a block is
+// being made, Pause waits for it to be committed before returning.
+// Later calls to MakeBlock block until Resume is called or their
+// context is done.
+// Transactions can still be submitted and delivered while paused;
+// those delivered go into the first block made after resuming.
+func (g *Generator) Pause() {
+	g.pauseMu.Lock()
+	defer g.pauseMu.Unlock()
+	g.paused = true
+	for g.making {
+		g.pauseCond.Wait()
+	}
+}
+
+// Resume lets the generator make blocks again after Pause.
+func (g *Generator) Resume() {
+	g.pauseMu.Lock()
+	defer g.pauseMu.Unlock()
+	g.paused = false
+	g.pauseCond.Broadcast()
 }
 
-// PendingTxs returns all of the pendings txs that will be
-// included in the generator's next block.
+// Paused reports whether block production is paused.
+func (g *Generator) Paused() bool {
+	g.pauseMu.Lock()
+	defer g.pauseMu.Unlock()
+	return g.paused
+}
+
+// WaitResumed waits until the generator is not paused, or ctx is
+// done. A caller that bounds MakeBlock with a deadline for the work of
+// making a block can call it first, so that the deadline doesn't
+// cut a pause short.
+func (g *Generator) WaitResumed(ctx context.Context) error {
+	g.pauseMu.Lock()
+	defer g.pauseMu.Unlock()
+	return g.waitResumed(ctx)
+}
+
+// startMaking waits until the generator is not paused, then marks
+// a block as being made. It returns an error, and marks nothing, if
+// ctx is done first. Unless it returns an error, the caller must call
+// doneMaking after.
+func (g *Generator) startMaking(ctx context.Context) error {
+	g.pauseMu.Lock()
+	defer g.pauseMu.Unlock()
+	err := g.waitResumed(ctx)
+	if err != nil {
+		return err
+	}
+	g.making = true
+	return nil
+}
+
+// waitResumed waits on pauseCond until the generator is not paused,
+// or ctx is done. The caller must hold g.pauseMu.
+func (g *Generator) waitResumed(ctx context.Context) error {
+	if g.paused {
+		// A sync.Cond can't wait on ctx; wake the waiters when
+		// ctx is done so this one sees it.
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			select {
+			case <-ctx.Done():
+				g.pauseMu.Lock()
+				g.pauseCond.Broadcast()
+				g.pauseMu.Unlock()
+			case <-stop:
+			}
+		}()
+	}
+	for g.paused {
+		if err := ctx.Err(); err != nil {
+			return errors.Wrap(err, "waiting for block production to resume")
+		}
+		g.pauseCond.Wait()
+	}
+	return nil
+}
+
+func (g *Generator) doneMaking() {
+	g.pauseMu.Lock()
+	defer g.pauseMu.Unlock()
+	g.making = false
+	g.pauseCond.Broadcast()
+}
+
+// SetEmptyBlocks sets whether MakeBlock makes a block when no txs
+// were delivered for it. By default it does not, so that the chain
+// does not fill with empty blocks when Tendermint makes a block every
+// round whether or not there are txs.
+func (g *Generator) SetEmptyBlocks(on bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.emptyBlocks = on
+}
+
+// PendingTxs returns the txs in the pending tx pool, submitted and
+// not yet delivered.
 func (g *Generator) PendingTxs() []*legacy.Tx {
 	g.mu.Lock()
 	defer g.mu.Unlock()
@@ -60,17 +194,49 @@ func (g *Generator) PendingTxs() []*legacy.Tx {
 	return txs
 }
 
-// Submit adds a new pending tx to the pending tx pool.
+// Submit adds a new pending tx to the pending tx pool. If the pool
+// is at its memory limit, txs paying the lowest fee per byte are
+// evicted to make room; see SetPoolLimits. A tx spending outputs
+// that no committed or pending tx has created is held as an orphan
+// until they are created; see PoolLimits.OrphanTTL.
 func (g *Generator) Submit(ctx context.Context, tx *legacy.Tx) error {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
-	if g.poolHashes[tx.ID] {
+	// A caller whose deadline passed while waiting for the lock
+	// has given up on the tx; don't add it behind its back.
+	if err := ctx.Err(); err != nil {
+		return errors.Wrap(err, "submitting tx")
+	}
+
+	if g.poolHashes[tx.ID] || g.isOrphan(tx.ID) {
+		return nil
+	}
+	if g.limits.OrphanTTL > 0 && !g.inputsKnown(tx) {
+		g.holdOrphan(tx)
 		return nil
 	}
 
+	err := g.add(tx)
+	if err != nil {
+		return err
+	}
+	g.resolveOrphans()
+	return nil
+}
+
+// add adds tx to the end of the pool, if there is room for it. The
+// caller must hold g.mu.
+func (g *Generator) add(tx *legacy.Tx) error {
+	e, err := g.admit(tx)
+	if err != nil {
+		return err
+	}
 	g.poolHashes[tx.ID] = true
+	g.poolInfo[tx.ID] = e
+	g.poolBytes += e.size
 	g.pool = append(g.pool, tx)
+	g.poolResized()
 	return nil
 }
 
@@ -89,7 +255,7 @@ func (g *Generator) Generate(
 	for {
 		select {
 		case <-ctx.Done():
-			log.Printf(ctx, "Deposed, Generate exiting")
+			generatorLog.Infokv(ctx, log.KeyMessage, "deposed, Generate exiting")
 			return
 		case <-ticks:
 			err := g.MakeBlock(ctx)
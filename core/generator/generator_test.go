@@ -188,3 +188,22 @@ func (s testSigner) SignBlock(ctx context.Context, marshalledBlock []byte) ([]by
 func (s testSigner) String() string {
 	return "test-signer"
 }
+
+func TestStartMakingPausedCanceled(t *testing.T) {
+	g := New(nil, nil)
+	g.Pause()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := g.startMaking(ctx); err == nil {
+		t.Fatal("startMaking while paused returned no error after ctx was done")
+	}
+	if g.making {
+		t.Error("startMaking marked a block as being made after ctx was done")
+	}
+
+	g.Resume()
+	if err := g.startMaking(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	g.doneMaking()
+}
@@ -0,0 +1,270 @@
+package generator
+
+import (
+	"expvar"
+	"io/ioutil"
+	"time"
+
+	"github.com/chainmint/core/meminfo"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/errors/errcode"
+	"github.com/chainmint/metrics"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+// ErrPoolFull is returned by Submit when the pending tx pool is at
+// its memory limit and the tx pays too little to displace any
+// pending tx. It is returned with code errcode.PoolFull.
+var ErrPoolFull = errors.New("pending tx pool is full")
+
+// txMemoryFactor estimates the memory held by a pending tx from its
+// serialized size. A decoded tx keeps both the legacy form and its
+// entries, with their maps and hashes.
+const txMemoryFactor = 4
+
+// memInfoRefresh is how often available system memory is re-read.
+const memInfoRefresh = 5 * time.Second
+
+// ErrNotPending is returned by Evict when no tx in the pending tx
+// pool has the given ID.
+var ErrNotPending = errors.New("tx is not in the pending tx pool")
+
+var (
+	poolBytes      = expvar.NewInt("generator.pool_bytes")
+	poolLimitBytes = expvar.NewInt("generator.pool_limit_bytes")
+	poolEvictions  = expvar.NewInt("generator.pool_evictions")
+	poolRejections = expvar.NewInt("generator.pool_rejections")
+
+	poolTxsMetric   = metrics.NewGauge("chainmint_mempool_txs", "Transactions in the pending tx pool.")
+	poolBytesMetric = metrics.NewGauge("chainmint_mempool_bytes", "Estimated memory held by the pending tx pool.")
+)
+
+// PoolLimits bound the memory used by the pending tx pool.
+type PoolLimits struct {
+	// MaxBytes is a fixed cap on the pool's estimated memory.
+	// Zero means no fixed cap.
+	MaxBytes int64
+
+	// MemoryFraction is the share of the system's available
+	// memory, in basis points, the pool may grow into. Zero
+	// disables scaling with available memory.
+	MemoryFraction int64
+
+	// Fee returns the fee paid by a tx. When the pool is full,
+	// txs paying the least per byte are shed first. If nil,
+	// every tx pays nothing and the oldest are kept.
+	Fee func(*legacy.Tx) uint64
+
+	// Weight returns the bytes a tx is charged for beyond its
+	// serialized size when comparing fees per byte, such as for
+	// reference data, which a block carries to every node. If
+	// nil, txs are charged for their size alone.
+	Weight func(*legacy.Tx) int64
+
+	// OrphanTTL is how long a tx spending outputs that are
+	// neither in the chain state nor created by a pending tx is
+	// held, waiting for the txs creating them, before it is
+	// dropped. Zero disables holding orphans: such txs go into
+	// the pool and are dropped when a block is made.
+	OrphanTTL time.Duration
+
+	// MaxOrphans is the most orphans held at once; the oldest
+	// are dropped first. Zero means no limit.
+	MaxOrphans int
+}
+
+type poolEntry struct {
+	size   int64
+	fee    uint64
+	weight int64 // bytes charged for, for fee per byte
+	seen   time.Time
+}
+
+// PoolTx describes a tx in the pending tx pool.
+type PoolTx struct {
+	ID        bc.Hash
+	Size      int64 // serialized bytes
+	Fee       uint64
+	FirstSeen time.Time
+}
+
+// PoolTxs describes the txs in the pending tx pool, in the order
+// they were submitted. Priority txs are not included.
+func (g *Generator) PoolTxs() []PoolTx {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	txs := make([]PoolTx, 0, len(g.pool))
+	for _, tx := range g.pool {
+		e := g.poolInfo[tx.ID]
+		txs = append(txs, PoolTx{
+			ID:        tx.ID,
+			Size:      e.size / txMemoryFactor,
+			Fee:       e.fee,
+			FirstSeen: e.seen,
+		})
+	}
+	return txs
+}
+
+// Evict removes the tx with the given ID from the pending tx pool,
+// along with any pending txs that spend its outputs. It returns the
+// IDs of the txs removed, or ErrNotPending.
+func (g *Generator) Evict(id bc.Hash) ([]bc.Hash, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for i, tx := range g.pool {
+		if tx.ID != id {
+			continue
+		}
+		// evict reuses the pool's array, so keep the txs
+		// pending before it in their own.
+		pending := g.pool
+		g.pool = make([]*legacy.Tx, len(pending))
+		copy(g.pool, pending)
+		g.evict(i)
+
+		var evicted []bc.Hash
+		for _, tx := range pending {
+			if _, ok := g.poolInfo[tx.ID]; !ok {
+				evicted = append(evicted, tx.ID)
+			}
+		}
+		return evicted, nil
+	}
+	return nil, errors.WithDetailf(ErrNotPending, "tx %x", id.Bytes())
+}
+
+// SetPoolLimits sets the memory limits of the pending tx pool. They
+// apply to txs submitted afterward.
+func (g *Generator) SetPoolLimits(l PoolLimits) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.limits = l
+}
+
+// admit makes room for tx in the pool, evicting the pending txs
+// paying the lowest fee per byte, along with any pending txs that
+// spend their outputs. It returns ErrPoolFull if tx itself would be
+// the first to go. The caller must hold g.mu.
+func (g *Generator) admit(tx *legacy.Tx) (poolEntry, error) {
+	n, _ := tx.WriteTo(ioutil.Discard)
+	e := poolEntry{size: n * txMemoryFactor, weight: n, seen: time.Now()}
+	if g.limits.Fee != nil {
+		e.fee = g.limits.Fee(tx)
+	}
+	if g.limits.Weight != nil {
+		e.weight += g.limits.Weight(tx)
+	}
+
+	limit := g.poolLimit()
+	poolLimitBytes.Set(limit)
+	if limit <= 0 {
+		return e, nil
+	}
+	for g.poolBytes+e.size > limit {
+		victim := g.cheapest()
+		if victim < 0 || !cheaper(g.poolInfo[g.pool[victim].ID], e) {
+			poolRejections.Add(1)
+			err := errors.WithDetailf(ErrPoolFull, "pool holds %d bytes of %d", g.poolBytes, limit)
+			return e, errors.WithCode(err, errcode.PoolFull)
+		}
+		g.evict(victim)
+	}
+	return e, nil
+}
+
+// poolLimit returns the current memory limit of the pool, or 0 if
+// it is unlimited.
+func (g *Generator) poolLimit() int64 {
+	limit := g.limits.MaxBytes
+	if g.limits.MemoryFraction > 0 {
+		if time.Since(g.memInfoAt) > memInfoRefresh {
+			g.memAvailable = meminfo.Available()
+			g.memInfoAt = time.Now()
+		}
+		if g.memAvailable > 0 {
+			// Memory the pool already uses is not available,
+			// but the pool may keep it.
+			scaled := g.poolBytes + g.memAvailable/10000*g.limits.MemoryFraction
+			if limit == 0 || scaled < limit {
+				limit = scaled
+			}
+		}
+	}
+	return limit
+}
+
+// cheapest returns the position in the pool of the tx paying the
+// lowest fee per byte, preferring the newest among equals, or -1 if
+// the pool is empty.
+func (g *Generator) cheapest() int {
+	victim := -1
+	for i := len(g.pool) - 1; i >= 0; i-- {
+		if victim < 0 || cheaper(g.poolInfo[g.pool[i].ID], g.poolInfo[g.pool[victim].ID]) {
+			victim = i
+		}
+	}
+	return victim
+}
+
+// cheaper reports whether a pays a lower fee per byte charged for
+// than b.
+func cheaper(a, b poolEntry) bool {
+	// Compare a.fee/a.weight < b.fee/b.weight without dividing.
+	return float64(a.fee)*float64(b.weight) < float64(b.fee)*float64(a.weight)
+}
+
+// evict removes the tx at position i from the pool, and with it the
+// pending txs that spend its outputs, which could no longer be
+// included in a block.
+func (g *Generator) evict(i int) {
+	spent := make(map[bc.Hash]bool)
+	for _, id := range g.pool[i].ResultIds {
+		spent[*id] = true
+	}
+	g.drop(i)
+
+	kept := g.pool[:0]
+	for _, tx := range g.pool {
+		dependent := false
+		for _, id := range tx.SpentOutputIDs {
+			if spent[id] {
+				dependent = true
+				break
+			}
+		}
+		if !dependent {
+			kept = append(kept, tx)
+			continue
+		}
+		for _, id := range tx.ResultIds {
+			spent[*id] = true
+		}
+		g.forget(tx)
+	}
+	g.pool = kept
+}
+
+func (g *Generator) drop(i int) {
+	g.forget(g.pool[i])
+	g.pool = append(g.pool[:i], g.pool[i+1:]...)
+}
+
+func (g *Generator) forget(tx *legacy.Tx) {
+	g.poolBytes -= g.poolInfo[tx.ID].size
+	poolEvictions.Add(1)
+	delete(g.poolInfo, tx.ID)
+	delete(g.poolHashes, tx.ID)
+	g.poolResized()
+}
+
+// poolResized publishes the size of the pool after a tx is added to
+// or removed from it. The caller must hold g.mu.
+func (g *Generator) poolResized() {
+	poolBytes.Set(g.poolBytes)
+	poolTxsMetric.Set(float64(len(g.poolInfo)))
+	poolBytesMetric.Set(float64(g.poolBytes))
+}
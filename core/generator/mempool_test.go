@@ -0,0 +1,153 @@
+package generator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+// poolTx returns a tx with the given ID and fee reference data, that
+// spends spent and creates an output with ID result.
+func poolTx(id byte, fee uint64, spent, result byte) *legacy.Tx {
+	out := bc.NewHash([32]byte{result})
+	tx := &legacy.Tx{
+		TxData: legacy.TxData{Version: 1, ReferenceData: []byte{byte(fee)}},
+		Tx: &bc.Tx{
+			TxHeader: &bc.TxHeader{ResultIds: []*bc.Hash{&out}},
+			ID:       bc.NewHash([32]byte{id}),
+		},
+	}
+	if spent != 0 {
+		tx.SpentOutputIDs = []bc.Hash{bc.NewHash([32]byte{spent})}
+	}
+	return tx
+}
+
+func TestPoolEviction(t *testing.T) {
+	ctx := context.Background()
+	g := New(nil, nil)
+
+	a := poolTx(1, 5, 0, 10)
+	b := poolTx(2, 1, 0, 20)
+	c := poolTx(3, 9, 20, 30) // spends b
+	e, err := g.admit(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.SetPoolLimits(PoolLimits{
+		MaxBytes: 3 * e.size,
+		Fee:      func(tx *legacy.Tx) uint64 { return uint64(tx.ReferenceData[0]) },
+	})
+	for _, tx := range []*legacy.Tx{a, b, c} {
+		if err := g.Submit(ctx, tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A tx paying less than everything pending is turned away.
+	if err := g.Submit(ctx, poolTx(4, 0, 0, 40)); errors.Root(err) != ErrPoolFull {
+		t.Errorf("Submit(cheap tx) = %v, want %v", err, ErrPoolFull)
+	}
+
+	// A better-paying tx displaces b, and c with it.
+	d := poolTx(5, 7, 0, 50)
+	if err := g.Submit(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	got := g.PendingTxs()
+	if len(got) != 2 || got[0] != a || got[1] != d {
+		t.Errorf("pending txs = %d, want a and d", len(got))
+	}
+	if g.poolBytes != 2*e.size {
+		t.Errorf("pool bytes = %d, want %d", g.poolBytes, 2*e.size)
+	}
+}
+
+func TestPoolWeight(t *testing.T) {
+	ctx := context.Background()
+	g := New(nil, nil)
+
+	a := poolTx(1, 5, 0, 10)
+	b := poolTx(2, 6, 0, 20) // pays more, but for a large memo
+	e, err := g.admit(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.SetPoolLimits(PoolLimits{
+		MaxBytes: 2 * e.size,
+		Fee:      func(tx *legacy.Tx) uint64 { return uint64(tx.ReferenceData[0]) },
+		Weight: func(tx *legacy.Tx) int64 {
+			if tx == b {
+				return 10 * e.weight
+			}
+			return 0
+		},
+	})
+	for _, tx := range []*legacy.Tx{a, b} {
+		if err := g.Submit(ctx, tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// b pays the least per byte charged for, so it goes first.
+	c := poolTx(3, 5, 0, 30)
+	if err := g.Submit(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+	got := g.PendingTxs()
+	if len(got) != 2 || got[0] != a || got[1] != c {
+		t.Errorf("pending txs = %d, want a and c", len(got))
+	}
+}
+
+func TestEvict(t *testing.T) {
+	ctx := context.Background()
+	g := New(nil, nil)
+	g.SetPoolLimits(PoolLimits{
+		Fee: func(tx *legacy.Tx) uint64 { return uint64(tx.ReferenceData[0]) },
+	})
+
+	a := poolTx(1, 5, 0, 10)
+	b := poolTx(2, 1, 0, 20)
+	c := poolTx(3, 9, 20, 30) // spends b
+	for _, tx := range []*legacy.Tx{a, b, c} {
+		if err := g.Submit(ctx, tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := g.PoolTxs()
+	if len(got) != 3 || got[1].ID != b.ID || got[1].Fee != 1 || got[1].Size == 0 || got[1].FirstSeen.IsZero() {
+		t.Fatalf("pool txs = %+v, want a, b and c", got)
+	}
+
+	evicted, err := g.Evict(b.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evicted) != 2 || evicted[0] != b.ID || evicted[1] != c.ID {
+		t.Errorf("evicted = %x, want b and c", evicted)
+	}
+	if pending := g.PendingTxs(); len(pending) != 1 || pending[0] != a {
+		t.Errorf("pending txs = %d, want a", len(pending))
+	}
+
+	_, err = g.Evict(b.ID)
+	if errors.Root(err) != ErrNotPending {
+		t.Errorf("Evict(evicted tx) = %v, want %v", err, ErrNotPending)
+	}
+}
+
+func TestSubmitCanceled(t *testing.T) {
+	g := New(nil, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := g.Submit(ctx, poolTx(1, 0, 0, 10)); errors.Root(err) != context.Canceled {
+		t.Errorf("Submit(canceled) = %v, want %v", err, context.Canceled)
+	}
+	if n := len(g.PendingTxs()); n != 0 {
+		t.Errorf("%d pending txs after canceled submit, want 0", n)
+	}
+}
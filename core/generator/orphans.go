@@ -0,0 +1,120 @@
+package generator
+
+import (
+	"expvar"
+	"time"
+
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/state"
+)
+
+var (
+	orphanCount    = expvar.NewInt("generator.orphans")
+	orphanDrops    = expvar.NewInt("generator.orphan_drops")
+	orphanResolves = expvar.NewInt("generator.orphan_resolves")
+)
+
+// An orphan is a submitted tx spending outputs that neither the
+// chain state nor the pool held when it was submitted, as when a
+// chained spend arrives before the tx it spends from.
+type orphan struct {
+	tx   *legacy.Tx
+	seen time.Time
+}
+
+// isOrphan reports whether the tx with the given ID is held as an
+// orphan. The caller must hold g.mu.
+func (g *Generator) isOrphan(id bc.Hash) bool {
+	for _, o := range g.orphans {
+		if o.tx.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// holdOrphan holds tx until the outputs it spends are created,
+// dropping the oldest orphan if too many are held. The caller must
+// hold g.mu.
+func (g *Generator) holdOrphan(tx *legacy.Tx) {
+	g.orphans = append(g.orphans, orphan{tx: tx, seen: time.Now()})
+	if max := g.limits.MaxOrphans; max > 0 && len(g.orphans) > max {
+		orphanDrops.Add(int64(len(g.orphans) - max))
+		g.orphans = append([]orphan(nil), g.orphans[len(g.orphans)-max:]...)
+	}
+	orphanCount.Set(int64(len(g.orphans)))
+}
+
+// resolveOrphans moves to the pool the orphans whose spent outputs
+// are now in the chain state or created by pending txs, including
+// other orphans moved before them, and drops the orphans held longer
+// than g.limits.OrphanTTL. The caller must hold g.mu.
+func (g *Generator) resolveOrphans() {
+	if len(g.orphans) == 0 {
+		return
+	}
+	_, s := g.chain.State()
+	pending := g.pendingOutputs()
+	now := time.Now()
+	for moved := true; moved; {
+		moved = false
+		kept := g.orphans[:0]
+		for _, o := range g.orphans {
+			switch {
+			case now.Sub(o.seen) > g.limits.OrphanTTL:
+				orphanDrops.Add(1)
+			case spendable(s, pending, o.tx):
+				err := g.add(o.tx)
+				if err != nil {
+					orphanDrops.Add(1)
+					continue
+				}
+				orphanResolves.Add(1)
+				for _, id := range o.tx.ResultIds {
+					pending[*id] = true
+				}
+				moved = true
+			default:
+				kept = append(kept, o)
+			}
+		}
+		g.orphans = kept
+	}
+	orphanCount.Set(int64(len(g.orphans)))
+}
+
+// inputsKnown reports whether every output tx spends is in the chain
+// state or created by a pending tx. The caller must hold g.mu.
+func (g *Generator) inputsKnown(tx *legacy.Tx) bool {
+	_, s := g.chain.State()
+	for _, id := range tx.SpentOutputIDs {
+		if s == nil || !s.Tree.Contains(id.Bytes()) {
+			return spendable(s, g.pendingOutputs(), tx)
+		}
+	}
+	return true
+}
+
+// pendingOutputs returns the IDs of the results of the pending txs.
+// The caller must hold g.mu.
+func (g *Generator) pendingOutputs() map[bc.Hash]bool {
+	outputs := make(map[bc.Hash]bool)
+	for _, tx := range g.pool {
+		for _, id := range tx.ResultIds {
+			outputs[*id] = true
+		}
+	}
+	return outputs
+}
+
+// spendable reports whether every output tx spends is in s or in
+// pending.
+func spendable(s *state.Snapshot, pending map[bc.Hash]bool, tx *legacy.Tx) bool {
+	for _, id := range tx.SpentOutputIDs {
+		if !pending[id] && (s == nil || !s.Tree.Contains(id.Bytes())) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,76 @@
+package generator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chainmint/protocol"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/prottest/memstore"
+	"github.com/chainmint/protocol/state"
+)
+
+func TestOrphans(t *testing.T) {
+	ctx := context.Background()
+	c, err := protocol.NewChain(ctx, bc.Hash{}, memstore.New(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := New(c, nil)
+	g.SetPoolLimits(PoolLimits{OrphanTTL: time.Minute, MaxOrphans: 2})
+
+	// A chained spend arriving before its parent waits for it.
+	b := poolTx(2, 0, 10, 20)
+	a := poolTx(1, 0, 0, 10)
+	for _, tx := range []*legacy.Tx{b, a} {
+		if err := g.Submit(ctx, tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := g.PendingTxs(); len(got) != 2 || got[0] != a || got[1] != b {
+		t.Errorf("pending txs = %d, want a then b", len(got))
+	}
+	if len(g.orphans) != 0 {
+		t.Errorf("orphans = %d, want 0", len(g.orphans))
+	}
+
+	// An orphan spending a committed output goes into the pool
+	// when the block creating it is committed.
+	d := poolTx(4, 0, 40, 50)
+	if err := g.Submit(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	s := state.Empty()
+	out := bc.NewHash([32]byte{40})
+	if err := s.Tree.Insert(out.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	b1, err := protocol.NewInitialBlock(nil, 0, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CommitAppliedBlock(ctx, b1, s); err != nil {
+		t.Fatal(err)
+	}
+	g.resolveOrphans()
+	if got := g.PendingTxs(); len(got) != 3 || got[2] != d {
+		t.Errorf("pending txs = %d, want d last", len(got))
+	}
+
+	// Orphans past the limit or held too long are dropped.
+	for i := byte(5); i < 8; i++ {
+		if err := g.Submit(ctx, poolTx(i, 0, 100+i, 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(g.orphans) != 2 || g.orphans[0].tx.ID != bc.NewHash([32]byte{6}) {
+		t.Fatalf("orphans = %d, want the newest 2", len(g.orphans))
+	}
+	g.orphans[0].seen = time.Now().Add(-time.Hour)
+	g.resolveOrphans()
+	if len(g.orphans) != 1 {
+		t.Errorf("orphans = %d after expiry, want 1", len(g.orphans))
+	}
+}
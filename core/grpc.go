@@ -0,0 +1,377 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	"github.com/chainmint/core/grpcapi"
+	"github.com/chainmint/core/pubsub"
+	"github.com/chainmint/core/txbuilder"
+	chainjson "github.com/chainmint/encoding/json"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/net/http/httperror"
+	"github.com/chainmint/net/http/httpjson"
+	"github.com/chainmint/protocol/bc"
+)
+
+// grpcServer serves the Core API over gRPC, as defined in package
+// grpcapi. It calls the same handlers as the HTTP API, so requests
+// and results are the same; documents whose shape varies by type are
+// passed through as JSON.
+type grpcServer struct {
+	a *API
+}
+
+// ServeGRPC serves the Core API over gRPC on connections accepted
+// from ln. It returns when accepting fails.
+func (a *API) ServeGRPC(ln net.Listener) error {
+	s := grpc.NewServer(grpc.UnaryInterceptor(grpcRecover))
+	grpcapi.RegisterCoreServer(s, &grpcServer{a})
+	return s.Serve(ln)
+}
+
+// grpcRecover turns a panic in a handler into an internal error, as
+// net/http does for the HTTP API, rather than crashing the process.
+func grpcRecover(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = grpcError(ctx, errors.Wrap(fmt.Errorf("panic with %T in %s", r, info.FullMethod)))
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// grpcCodes maps the HTTP status of a Chain error to the closest
+// gRPC status code. Others map to codes.Internal.
+var grpcCodes = map[int]codes.Code{
+	400: codes.InvalidArgument,
+	401: codes.Unauthenticated,
+	403: codes.PermissionDenied,
+	404: codes.NotFound,
+	408: codes.DeadlineExceeded,
+	429: codes.ResourceExhausted,
+	503: codes.Unavailable,
+}
+
+// grpcError logs err and converts it to a gRPC error whose
+// description starts with its Chain error code.
+func grpcError(ctx context.Context, err error) error {
+	errorFormatter.Log(ctx, err)
+	resp := errorFormatter.Format(err)
+	code, ok := grpcCodes[resp.HTTPStatus]
+	if !ok {
+		code = codes.Internal
+	}
+	msg := resp.Message
+	if resp.Detail != "" {
+		msg += ": " + resp.Detail
+	}
+	return grpc.Errorf(code, "%s: %s", resp.ChainCode, msg)
+}
+
+// batchError converts the error response for one item of a batch.
+func batchError(resp httperror.Response) *grpcapi.Error {
+	return &grpcapi.Error{
+		Code:      resp.ChainCode,
+		Message:   resp.Message,
+		Detail:    resp.Detail,
+		Temporary: resp.Temporary,
+	}
+}
+
+func badGRPCRequest(format string, args ...interface{}) error {
+	return errors.WithDetailf(httpjson.ErrBadRequest, format, args...)
+}
+
+func (s *grpcServer) BuildTransaction(ctx context.Context, in *grpcapi.BuildTransactionRequest) (*grpcapi.BuildTransactionResponse, error) {
+	if !s.a.Reconciled() {
+		return nil, grpcError(ctx, errNotReconciled)
+	}
+	reqs := make([]*BuildRequest, len(in.Requests))
+	for i, b := range in.Requests {
+		reqs[i] = new(BuildRequest)
+		err := json.Unmarshal(b, reqs[i])
+		if err != nil {
+			return nil, grpcError(ctx, badGRPCRequest("request %d: %s", i, err))
+		}
+	}
+	results, err := s.a.build(ctx, reqs)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	out := new(grpcapi.BuildTransactionResponse)
+	for _, r := range results.([]interface{}) {
+		res := new(grpcapi.TemplateResult)
+		if resp, ok := r.(httperror.Response); ok {
+			res.Error = batchError(resp)
+		} else {
+			res.Template, err = json.Marshal(r)
+			if err != nil {
+				return nil, grpcError(ctx, errors.Wrap(err))
+			}
+		}
+		out.Results = append(out.Results, res)
+	}
+	return out, nil
+}
+
+func (s *grpcServer) SubmitTransaction(ctx context.Context, in *grpcapi.SubmitTransactionRequest) (*grpcapi.SubmitTransactionResponse, error) {
+	if !s.a.Reconciled() {
+		return nil, grpcError(ctx, errNotReconciled)
+	}
+	x := submitArg{
+		Transactions: make([]txbuilder.Template, len(in.Templates)),
+		WaitUntil:    in.WaitUntil,
+	}
+	for i, b := range in.Templates {
+		err := json.Unmarshal(b, &x.Transactions[i])
+		if err != nil {
+			return nil, grpcError(ctx, badGRPCRequest("template %d: %s", i, err))
+		}
+	}
+	results, err := s.a.submit(ctx, x)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	// A submission forwarded to the leader returns its JSON response.
+	if raw, ok := results.(json.RawMessage); ok {
+		var items []json.RawMessage
+		err = json.Unmarshal(raw, &items)
+		if err != nil {
+			return nil, grpcError(ctx, errors.Wrap(err, "decoding leader response"))
+		}
+		results = rawSubmitResults(items)
+	}
+	out := new(grpcapi.SubmitTransactionResponse)
+	for _, r := range results.([]interface{}) {
+		res := new(grpcapi.SubmitResult)
+		switch r := r.(type) {
+		case httperror.Response:
+			res.Error = batchError(r)
+		case map[string]string:
+			res.Id = r["id"]
+		case *committedTx:
+			res.Id = r.ID
+		}
+		out.Results = append(out.Results, res)
+	}
+	return out, nil
+}
+
+// rawSubmitResults decodes each item of a forwarded submission
+// response into the form a.submit returns locally.
+func rawSubmitResults(items []json.RawMessage) []interface{} {
+	results := make([]interface{}, len(items))
+	for i, item := range items {
+		var v struct {
+			httperror.Response
+			ID string `json:"id"`
+		}
+		json.Unmarshal(item, &v)
+		if v.ID != "" {
+			results[i] = map[string]string{"id": v.ID}
+		} else {
+			results[i] = v.Response
+		}
+	}
+	return results
+}
+
+// grpcQuery converts a gRPC list request to the query of the
+// equivalent HTTP request.
+func grpcQuery(in *grpcapi.ListRequest) (requestQuery, error) {
+	q := requestQuery{
+		Filter:      in.Filter,
+		SumBy:       in.SumBy,
+		PageSize:    int(in.PageSize),
+		After:       in.After,
+		StartTimeMS: in.StartTime,
+		EndTimeMS:   in.EndTime,
+		TimestampMS: in.Timestamp,
+		AscLongPoll: in.AscendingWithLongPoll,
+		Timeout:     chainjson.Duration{Duration: time.Duration(in.TimeoutMs) * time.Millisecond},
+	}
+	for i, b := range in.FilterParams {
+		var p interface{}
+		err := json.Unmarshal(b, &p)
+		if err != nil {
+			return q, badGRPCRequest("filter param %d: %s", i, err)
+		}
+		q.FilterParams = append(q.FilterParams, p)
+	}
+	return q, nil
+}
+
+// grpcListRequest converts the query for the next page of a list
+// back to a gRPC list request.
+func grpcListRequest(q requestQuery) (*grpcapi.ListRequest, error) {
+	out := &grpcapi.ListRequest{
+		Filter:                q.Filter,
+		SumBy:                 q.SumBy,
+		PageSize:              int32(q.PageSize),
+		After:                 q.After,
+		StartTime:             q.StartTimeMS,
+		EndTime:               q.EndTimeMS,
+		Timestamp:             q.TimestampMS,
+		AscendingWithLongPoll: q.AscLongPoll,
+		TimeoutMs:             uint64(q.Timeout.Duration / time.Millisecond),
+	}
+	for _, p := range q.FilterParams {
+		b, err := json.Marshal(p)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		out.FilterParams = append(out.FilterParams, b)
+	}
+	return out, nil
+}
+
+// list serves a gRPC list request with the handler of the
+// equivalent HTTP request.
+func (s *grpcServer) list(ctx context.Context, in *grpcapi.ListRequest, f func(context.Context, requestQuery) (page, error)) (*grpcapi.ListResponse, error) {
+	q, err := grpcQuery(in)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	p, err := f(ctx, q)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	out, err := grpcListResponse(p)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return out, nil
+}
+
+func grpcListResponse(p page) (*grpcapi.ListResponse, error) {
+	b, err := json.Marshal(p.Items)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	var items []json.RawMessage
+	err = json.Unmarshal(b, &items)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	next, err := grpcListRequest(p.Next)
+	if err != nil {
+		return nil, err
+	}
+	out := &grpcapi.ListResponse{Next: next, LastPage: p.LastPage}
+	for _, item := range items {
+		out.Items = append(out.Items, item)
+	}
+	return out, nil
+}
+
+func (s *grpcServer) ListAccounts(ctx context.Context, in *grpcapi.ListRequest) (*grpcapi.ListResponse, error) {
+	return s.list(ctx, in, s.a.listAccounts)
+}
+
+func (s *grpcServer) ListAssets(ctx context.Context, in *grpcapi.ListRequest) (*grpcapi.ListResponse, error) {
+	return s.list(ctx, in, s.a.listAssets)
+}
+
+func (s *grpcServer) ListTransactions(ctx context.Context, in *grpcapi.ListRequest) (*grpcapi.ListResponse, error) {
+	return s.list(ctx, in, s.a.listTransactions)
+}
+
+func (s *grpcServer) ListBalances(ctx context.Context, in *grpcapi.ListRequest) (*grpcapi.ListResponse, error) {
+	return s.list(ctx, in, s.a.listBalances)
+}
+
+func (s *grpcServer) ListUnspentOutputs(ctx context.Context, in *grpcapi.ListRequest) (*grpcapi.ListResponse, error) {
+	return s.list(ctx, in, s.a.listUnspentOutputs)
+}
+
+func grpcDocument(ctx context.Context, v interface{}) (*grpcapi.Document, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, grpcError(ctx, errors.Wrap(err))
+	}
+	return &grpcapi.Document{Json: b}, nil
+}
+
+func (s *grpcServer) GetBlock(ctx context.Context, in *grpcapi.GetBlockRequest) (*grpcapi.Document, error) {
+	var req struct {
+		Height uint64   `json:"height,omitempty"`
+		ID     *bc.Hash `json:"id,omitempty"`
+	}
+	req.Height = in.Height
+	if in.Id != "" {
+		req.ID = new(bc.Hash)
+		err := req.ID.UnmarshalText([]byte(in.Id))
+		if err != nil {
+			return nil, grpcError(ctx, badGRPCRequest("invalid block id"))
+		}
+	}
+	b, err := s.a.getBlock(ctx, req)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return grpcDocument(ctx, b)
+}
+
+func (s *grpcServer) GetTransaction(ctx context.Context, in *grpcapi.GetTransactionRequest) (*grpcapi.Document, error) {
+	var req struct {
+		ID bc.Hash `json:"id"`
+	}
+	err := req.ID.UnmarshalText([]byte(in.Id))
+	if err != nil {
+		return nil, grpcError(ctx, badGRPCRequest("invalid transaction id"))
+	}
+	tx, err := s.a.getTransaction(ctx, req)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return grpcDocument(ctx, tx)
+}
+
+// Subscribe streams the chain events matching in, as /subscribe
+// does over a WebSocket, until the client cancels the call.
+// Subscribers that fall too far behind are sent an error.
+func (s *grpcServer) Subscribe(in *grpcapi.SubscribeRequest, stream grpcapi.Core_SubscribeServer) error {
+	ctx := stream.Context()
+	if s.a.events == nil {
+		return grpcError(ctx, errUnconfigured)
+	}
+	f := pubsub.Filter{
+		Types:           in.Types,
+		AccountIDs:      in.AccountIds,
+		AssetIDs:        in.AssetIds,
+		ControlPrograms: in.ControlPrograms,
+	}
+	err := f.Validate()
+	if err != nil {
+		return grpcError(ctx, err)
+	}
+
+	sub := s.a.events.Subscribe(f, eventBuffer)
+	defer sub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-sub.C:
+			if !ok {
+				return grpcError(ctx, errSubscriberBehind)
+			}
+			data, err := json.Marshal(e.Data)
+			if err != nil {
+				return grpcError(ctx, errors.Wrap(err))
+			}
+			err = stream.Send(&grpcapi.Event{Type: e.Type, Height: e.Height, Data: data})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
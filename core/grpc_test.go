@@ -0,0 +1,101 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	"github.com/chainmint/core/grpcapi"
+	chainjson "github.com/chainmint/encoding/json"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/net/http/httpjson"
+)
+
+func TestGRPCError(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		err      error
+		wantCode codes.Code
+		wantDesc string
+	}{
+		{errors.WithDetail(httpjson.ErrBadRequest, "bad filter"), codes.InvalidArgument, "CH003: Invalid request body: bad filter"},
+		{errNotReconciled, codes.Unavailable, "CH172: "},
+		{context.DeadlineExceeded, codes.DeadlineExceeded, "CH001: Request timed out"},
+		{errors.New("boom"), codes.Internal, "CH000: Chain API Error"},
+	}
+	for _, c := range cases {
+		err := grpcError(ctx, c.err)
+		if got := grpc.Code(err); got != c.wantCode {
+			t.Errorf("grpcError(%v) code = %v want %v", c.err, got, c.wantCode)
+		}
+		if got := grpc.ErrorDesc(err); !strings.HasPrefix(got, c.wantDesc) {
+			t.Errorf("grpcError(%v) desc = %q want prefix %q", c.err, got, c.wantDesc)
+		}
+	}
+}
+
+func TestGRPCQuery(t *testing.T) {
+	in := &grpcapi.ListRequest{
+		Filter:                "account_id=$1",
+		FilterParams:          [][]byte{[]byte(`"acc1"`)},
+		PageSize:              10,
+		After:                 "1:2-3",
+		StartTime:             100,
+		EndTime:               200,
+		AscendingWithLongPoll: true,
+		TimeoutMs:             1500,
+	}
+	q, err := grpcQuery(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := requestQuery{
+		Filter:       "account_id=$1",
+		FilterParams: []interface{}{"acc1"},
+		PageSize:     10,
+		After:        "1:2-3",
+		StartTimeMS:  100,
+		EndTimeMS:    200,
+		AscLongPoll:  true,
+		Timeout:      chainjson.Duration{Duration: 1500 * time.Millisecond},
+	}
+	if !reflect.DeepEqual(q, want) {
+		t.Errorf("grpcQuery = %+v want %+v", q, want)
+	}
+
+	back, err := grpcListRequest(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, in) {
+		t.Errorf("grpcListRequest = %+v want %+v", back, in)
+	}
+
+	_, err = grpcQuery(&grpcapi.ListRequest{FilterParams: [][]byte{[]byte(`{`)}})
+	if errors.Root(err) != httpjson.ErrBadRequest {
+		t.Errorf("grpcQuery(bad param) error = %v want %v", err, httpjson.ErrBadRequest)
+	}
+}
+
+func TestGRPCListResponse(t *testing.T) {
+	p := page{
+		Items: []map[string]string{{"id": "a"}, {"id": "b"}},
+		Next:  requestQuery{After: "b", PageSize: 2},
+	}
+	got, err := grpcListResponse(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &grpcapi.ListResponse{
+		Items: [][]byte{[]byte(`{"id":"a"}`), []byte(`{"id":"b"}`)},
+		Next:  &grpcapi.ListRequest{After: "b", PageSize: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("grpcListResponse = %+v want %+v", got, want)
+	}
+}
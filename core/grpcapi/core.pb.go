@@ -0,0 +1,935 @@
+// Code generated by protoc-gen-go.
+// source: core.proto
+// DO NOT EDIT!
+
+/*
+Package grpcapi is a generated protocol buffer package.
+
+It is generated from these files:
+	core.proto
+
+It has these top-level messages:
+	Error
+	BuildTransactionRequest
+	BuildTransactionResponse
+	TemplateResult
+	SubmitTransactionRequest
+	SubmitTransactionResponse
+	SubmitResult
+	ListRequest
+	ListResponse
+	GetBlockRequest
+	GetTransactionRequest
+	Document
+	SubscribeRequest
+	Event
+*/
+package grpcapi
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+// Error is a Chain error, for one item of a batch.
+type Error struct {
+	Code      string `protobuf:"bytes,1,opt,name=code" json:"code,omitempty"`
+	Message   string `protobuf:"bytes,2,opt,name=message" json:"message,omitempty"`
+	Detail    string `protobuf:"bytes,3,opt,name=detail" json:"detail,omitempty"`
+	Temporary bool   `protobuf:"varint,4,opt,name=temporary" json:"temporary,omitempty"`
+}
+
+func (m *Error) Reset()                    { *m = Error{} }
+func (m *Error) String() string            { return proto.CompactTextString(m) }
+func (*Error) ProtoMessage()               {}
+func (*Error) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+func (m *Error) GetCode() string {
+	if m != nil {
+		return m.Code
+	}
+	return ""
+}
+
+func (m *Error) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+func (m *Error) GetDetail() string {
+	if m != nil {
+		return m.Detail
+	}
+	return ""
+}
+
+func (m *Error) GetTemporary() bool {
+	if m != nil {
+		return m.Temporary
+	}
+	return false
+}
+
+type BuildTransactionRequest struct {
+	// Each request is a JSON build request, as for /build-transaction.
+	Requests [][]byte `protobuf:"bytes,1,rep,name=requests,proto3" json:"requests,omitempty"`
+}
+
+func (m *BuildTransactionRequest) Reset()                    { *m = BuildTransactionRequest{} }
+func (m *BuildTransactionRequest) String() string            { return proto.CompactTextString(m) }
+func (*BuildTransactionRequest) ProtoMessage()               {}
+func (*BuildTransactionRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *BuildTransactionRequest) GetRequests() [][]byte {
+	if m != nil {
+		return m.Requests
+	}
+	return nil
+}
+
+type BuildTransactionResponse struct {
+	Results []*TemplateResult `protobuf:"bytes,1,rep,name=results" json:"results,omitempty"`
+}
+
+func (m *BuildTransactionResponse) Reset()                    { *m = BuildTransactionResponse{} }
+func (m *BuildTransactionResponse) String() string            { return proto.CompactTextString(m) }
+func (*BuildTransactionResponse) ProtoMessage()               {}
+func (*BuildTransactionResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+func (m *BuildTransactionResponse) GetResults() []*TemplateResult {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
+type TemplateResult struct {
+	Template []byte `protobuf:"bytes,1,opt,name=template,proto3" json:"template,omitempty"`
+	Error    *Error `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *TemplateResult) Reset()                    { *m = TemplateResult{} }
+func (m *TemplateResult) String() string            { return proto.CompactTextString(m) }
+func (*TemplateResult) ProtoMessage()               {}
+func (*TemplateResult) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *TemplateResult) GetTemplate() []byte {
+	if m != nil {
+		return m.Template
+	}
+	return nil
+}
+
+func (m *TemplateResult) GetError() *Error {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type SubmitTransactionRequest struct {
+	// Each template is a signed JSON transaction template.
+	Templates [][]byte `protobuf:"bytes,1,rep,name=templates,proto3" json:"templates,omitempty"`
+	WaitUntil string   `protobuf:"bytes,2,opt,name=wait_until,json=waitUntil" json:"wait_until,omitempty"`
+}
+
+func (m *SubmitTransactionRequest) Reset()                    { *m = SubmitTransactionRequest{} }
+func (m *SubmitTransactionRequest) String() string            { return proto.CompactTextString(m) }
+func (*SubmitTransactionRequest) ProtoMessage()               {}
+func (*SubmitTransactionRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *SubmitTransactionRequest) GetTemplates() [][]byte {
+	if m != nil {
+		return m.Templates
+	}
+	return nil
+}
+
+func (m *SubmitTransactionRequest) GetWaitUntil() string {
+	if m != nil {
+		return m.WaitUntil
+	}
+	return ""
+}
+
+type SubmitTransactionResponse struct {
+	Results []*SubmitResult `protobuf:"bytes,1,rep,name=results" json:"results,omitempty"`
+}
+
+func (m *SubmitTransactionResponse) Reset()                    { *m = SubmitTransactionResponse{} }
+func (m *SubmitTransactionResponse) String() string            { return proto.CompactTextString(m) }
+func (*SubmitTransactionResponse) ProtoMessage()               {}
+func (*SubmitTransactionResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *SubmitTransactionResponse) GetResults() []*SubmitResult {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
+type SubmitResult struct {
+	Id    string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Error *Error `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *SubmitResult) Reset()                    { *m = SubmitResult{} }
+func (m *SubmitResult) String() string            { return proto.CompactTextString(m) }
+func (*SubmitResult) ProtoMessage()               {}
+func (*SubmitResult) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *SubmitResult) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *SubmitResult) GetError() *Error {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type ListRequest struct {
+	Filter                string   `protobuf:"bytes,1,opt,name=filter" json:"filter,omitempty"`
+	FilterParams          [][]byte `protobuf:"bytes,2,rep,name=filter_params,json=filterParams,proto3" json:"filter_params,omitempty"`
+	SumBy                 []string `protobuf:"bytes,3,rep,name=sum_by,json=sumBy" json:"sum_by,omitempty"`
+	PageSize              int32    `protobuf:"varint,4,opt,name=page_size,json=pageSize" json:"page_size,omitempty"`
+	After                 string   `protobuf:"bytes,5,opt,name=after" json:"after,omitempty"`
+	StartTime             uint64   `protobuf:"varint,6,opt,name=start_time,json=startTime" json:"start_time,omitempty"`
+	EndTime               uint64   `protobuf:"varint,7,opt,name=end_time,json=endTime" json:"end_time,omitempty"`
+	Timestamp             uint64   `protobuf:"varint,8,opt,name=timestamp" json:"timestamp,omitempty"`
+	AscendingWithLongPoll bool     `protobuf:"varint,9,opt,name=ascending_with_long_poll,json=ascendingWithLongPoll" json:"ascending_with_long_poll,omitempty"`
+	TimeoutMs             uint64   `protobuf:"varint,10,opt,name=timeout_ms,json=timeoutMs" json:"timeout_ms,omitempty"`
+}
+
+func (m *ListRequest) Reset()                    { *m = ListRequest{} }
+func (m *ListRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListRequest) ProtoMessage()               {}
+func (*ListRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *ListRequest) GetFilter() string {
+	if m != nil {
+		return m.Filter
+	}
+	return ""
+}
+
+func (m *ListRequest) GetFilterParams() [][]byte {
+	if m != nil {
+		return m.FilterParams
+	}
+	return nil
+}
+
+func (m *ListRequest) GetSumBy() []string {
+	if m != nil {
+		return m.SumBy
+	}
+	return nil
+}
+
+func (m *ListRequest) GetPageSize() int32 {
+	if m != nil {
+		return m.PageSize
+	}
+	return 0
+}
+
+func (m *ListRequest) GetAfter() string {
+	if m != nil {
+		return m.After
+	}
+	return ""
+}
+
+func (m *ListRequest) GetStartTime() uint64 {
+	if m != nil {
+		return m.StartTime
+	}
+	return 0
+}
+
+func (m *ListRequest) GetEndTime() uint64 {
+	if m != nil {
+		return m.EndTime
+	}
+	return 0
+}
+
+func (m *ListRequest) GetTimestamp() uint64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+func (m *ListRequest) GetAscendingWithLongPoll() bool {
+	if m != nil {
+		return m.AscendingWithLongPoll
+	}
+	return false
+}
+
+func (m *ListRequest) GetTimeoutMs() uint64 {
+	if m != nil {
+		return m.TimeoutMs
+	}
+	return 0
+}
+
+type ListResponse struct {
+	Items    [][]byte     `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+	Next     *ListRequest `protobuf:"bytes,2,opt,name=next" json:"next,omitempty"`
+	LastPage bool         `protobuf:"varint,3,opt,name=last_page,json=lastPage" json:"last_page,omitempty"`
+}
+
+func (m *ListResponse) Reset()                    { *m = ListResponse{} }
+func (m *ListResponse) String() string            { return proto.CompactTextString(m) }
+func (*ListResponse) ProtoMessage()               {}
+func (*ListResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *ListResponse) GetItems() [][]byte {
+	if m != nil {
+		return m.Items
+	}
+	return nil
+}
+
+func (m *ListResponse) GetNext() *ListRequest {
+	if m != nil {
+		return m.Next
+	}
+	return nil
+}
+
+func (m *ListResponse) GetLastPage() bool {
+	if m != nil {
+		return m.LastPage
+	}
+	return false
+}
+
+type GetBlockRequest struct {
+	Height uint64 `protobuf:"varint,1,opt,name=height" json:"height,omitempty"`
+	Id     string `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
+}
+
+func (m *GetBlockRequest) Reset()                    { *m = GetBlockRequest{} }
+func (m *GetBlockRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetBlockRequest) ProtoMessage()               {}
+func (*GetBlockRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *GetBlockRequest) GetHeight() uint64 {
+	if m != nil {
+		return m.Height
+	}
+	return 0
+}
+
+func (m *GetBlockRequest) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+type GetTransactionRequest struct {
+	Id string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+}
+
+func (m *GetTransactionRequest) Reset()                    { *m = GetTransactionRequest{} }
+func (m *GetTransactionRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetTransactionRequest) ProtoMessage()               {}
+func (*GetTransactionRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *GetTransactionRequest) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+type Document struct {
+	Json []byte `protobuf:"bytes,1,opt,name=json,proto3" json:"json,omitempty"`
+}
+
+func (m *Document) Reset()                    { *m = Document{} }
+func (m *Document) String() string            { return proto.CompactTextString(m) }
+func (*Document) ProtoMessage()               {}
+func (*Document) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+func (m *Document) GetJson() []byte {
+	if m != nil {
+		return m.Json
+	}
+	return nil
+}
+
+type SubscribeRequest struct {
+	Types           []string `protobuf:"bytes,1,rep,name=types" json:"types,omitempty"`
+	AccountIds      []string `protobuf:"bytes,2,rep,name=account_ids,json=accountIds" json:"account_ids,omitempty"`
+	AssetIds        []string `protobuf:"bytes,3,rep,name=asset_ids,json=assetIds" json:"asset_ids,omitempty"`
+	ControlPrograms []string `protobuf:"bytes,4,rep,name=control_programs,json=controlPrograms" json:"control_programs,omitempty"`
+}
+
+func (m *SubscribeRequest) Reset()                    { *m = SubscribeRequest{} }
+func (m *SubscribeRequest) String() string            { return proto.CompactTextString(m) }
+func (*SubscribeRequest) ProtoMessage()               {}
+func (*SubscribeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+func (m *SubscribeRequest) GetTypes() []string {
+	if m != nil {
+		return m.Types
+	}
+	return nil
+}
+
+func (m *SubscribeRequest) GetAccountIds() []string {
+	if m != nil {
+		return m.AccountIds
+	}
+	return nil
+}
+
+func (m *SubscribeRequest) GetAssetIds() []string {
+	if m != nil {
+		return m.AssetIds
+	}
+	return nil
+}
+
+func (m *SubscribeRequest) GetControlPrograms() []string {
+	if m != nil {
+		return m.ControlPrograms
+	}
+	return nil
+}
+
+type Event struct {
+	Type   string `protobuf:"bytes,1,opt,name=type" json:"type,omitempty"`
+	Height uint64 `protobuf:"varint,2,opt,name=height" json:"height,omitempty"`
+	Data   []byte `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (m *Event) Reset()                    { *m = Event{} }
+func (m *Event) String() string            { return proto.CompactTextString(m) }
+func (*Event) ProtoMessage()               {}
+func (*Event) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+func (m *Event) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *Event) GetHeight() uint64 {
+	if m != nil {
+		return m.Height
+	}
+	return 0
+}
+
+func (m *Event) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*Error)(nil), "grpcapi.Error")
+	proto.RegisterType((*BuildTransactionRequest)(nil), "grpcapi.BuildTransactionRequest")
+	proto.RegisterType((*BuildTransactionResponse)(nil), "grpcapi.BuildTransactionResponse")
+	proto.RegisterType((*TemplateResult)(nil), "grpcapi.TemplateResult")
+	proto.RegisterType((*SubmitTransactionRequest)(nil), "grpcapi.SubmitTransactionRequest")
+	proto.RegisterType((*SubmitTransactionResponse)(nil), "grpcapi.SubmitTransactionResponse")
+	proto.RegisterType((*SubmitResult)(nil), "grpcapi.SubmitResult")
+	proto.RegisterType((*ListRequest)(nil), "grpcapi.ListRequest")
+	proto.RegisterType((*ListResponse)(nil), "grpcapi.ListResponse")
+	proto.RegisterType((*GetBlockRequest)(nil), "grpcapi.GetBlockRequest")
+	proto.RegisterType((*GetTransactionRequest)(nil), "grpcapi.GetTransactionRequest")
+	proto.RegisterType((*Document)(nil), "grpcapi.Document")
+	proto.RegisterType((*SubscribeRequest)(nil), "grpcapi.SubscribeRequest")
+	proto.RegisterType((*Event)(nil), "grpcapi.Event")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// Client API for Core service
+
+// Core serves the Core API over gRPC. Documents whose shape varies
+// by type, such as build actions, transaction templates, annotated
+// transactions and event data, are JSON, as in the HTTP API.
+type CoreClient interface {
+	BuildTransaction(ctx context.Context, in *BuildTransactionRequest, opts ...grpc.CallOption) (*BuildTransactionResponse, error)
+	SubmitTransaction(ctx context.Context, in *SubmitTransactionRequest, opts ...grpc.CallOption) (*SubmitTransactionResponse, error)
+	ListAccounts(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	ListAssets(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	ListTransactions(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	ListBalances(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	ListUnspentOutputs(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	GetBlock(ctx context.Context, in *GetBlockRequest, opts ...grpc.CallOption) (*Document, error)
+	GetTransaction(ctx context.Context, in *GetTransactionRequest, opts ...grpc.CallOption) (*Document, error)
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (Core_SubscribeClient, error)
+}
+
+type coreClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewCoreClient(cc *grpc.ClientConn) CoreClient {
+	return &coreClient{cc}
+}
+
+func (c *coreClient) BuildTransaction(ctx context.Context, in *BuildTransactionRequest, opts ...grpc.CallOption) (*BuildTransactionResponse, error) {
+	out := new(BuildTransactionResponse)
+	err := grpc.Invoke(ctx, "/grpcapi.Core/BuildTransaction", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coreClient) SubmitTransaction(ctx context.Context, in *SubmitTransactionRequest, opts ...grpc.CallOption) (*SubmitTransactionResponse, error) {
+	out := new(SubmitTransactionResponse)
+	err := grpc.Invoke(ctx, "/grpcapi.Core/SubmitTransaction", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coreClient) ListAccounts(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	out := new(ListResponse)
+	err := grpc.Invoke(ctx, "/grpcapi.Core/ListAccounts", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coreClient) ListAssets(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	out := new(ListResponse)
+	err := grpc.Invoke(ctx, "/grpcapi.Core/ListAssets", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coreClient) ListTransactions(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	out := new(ListResponse)
+	err := grpc.Invoke(ctx, "/grpcapi.Core/ListTransactions", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coreClient) ListBalances(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	out := new(ListResponse)
+	err := grpc.Invoke(ctx, "/grpcapi.Core/ListBalances", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coreClient) ListUnspentOutputs(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	out := new(ListResponse)
+	err := grpc.Invoke(ctx, "/grpcapi.Core/ListUnspentOutputs", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coreClient) GetBlock(ctx context.Context, in *GetBlockRequest, opts ...grpc.CallOption) (*Document, error) {
+	out := new(Document)
+	err := grpc.Invoke(ctx, "/grpcapi.Core/GetBlock", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coreClient) GetTransaction(ctx context.Context, in *GetTransactionRequest, opts ...grpc.CallOption) (*Document, error) {
+	out := new(Document)
+	err := grpc.Invoke(ctx, "/grpcapi.Core/GetTransaction", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coreClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (Core_SubscribeClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Core_serviceDesc.Streams[0], c.cc, "/grpcapi.Core/Subscribe", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &coreSubscribeClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Core_SubscribeClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type coreSubscribeClient struct {
+	grpc.ClientStream
+}
+
+func (x *coreSubscribeClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Server API for Core service
+
+type CoreServer interface {
+	BuildTransaction(context.Context, *BuildTransactionRequest) (*BuildTransactionResponse, error)
+	SubmitTransaction(context.Context, *SubmitTransactionRequest) (*SubmitTransactionResponse, error)
+	ListAccounts(context.Context, *ListRequest) (*ListResponse, error)
+	ListAssets(context.Context, *ListRequest) (*ListResponse, error)
+	ListTransactions(context.Context, *ListRequest) (*ListResponse, error)
+	ListBalances(context.Context, *ListRequest) (*ListResponse, error)
+	ListUnspentOutputs(context.Context, *ListRequest) (*ListResponse, error)
+	GetBlock(context.Context, *GetBlockRequest) (*Document, error)
+	GetTransaction(context.Context, *GetTransactionRequest) (*Document, error)
+	Subscribe(*SubscribeRequest, Core_SubscribeServer) error
+}
+
+func RegisterCoreServer(s *grpc.Server, srv CoreServer) {
+	s.RegisterService(&_Core_serviceDesc, srv)
+}
+
+func _Core_BuildTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BuildTransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoreServer).BuildTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/grpcapi.Core/BuildTransaction",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoreServer).BuildTransaction(ctx, req.(*BuildTransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Core_SubmitTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitTransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoreServer).SubmitTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/grpcapi.Core/SubmitTransaction",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoreServer).SubmitTransaction(ctx, req.(*SubmitTransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Core_ListAccounts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoreServer).ListAccounts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/grpcapi.Core/ListAccounts",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoreServer).ListAccounts(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Core_ListAssets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoreServer).ListAssets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/grpcapi.Core/ListAssets",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoreServer).ListAssets(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Core_ListTransactions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoreServer).ListTransactions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/grpcapi.Core/ListTransactions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoreServer).ListTransactions(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Core_ListBalances_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoreServer).ListBalances(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/grpcapi.Core/ListBalances",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoreServer).ListBalances(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Core_ListUnspentOutputs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoreServer).ListUnspentOutputs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/grpcapi.Core/ListUnspentOutputs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoreServer).ListUnspentOutputs(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Core_GetBlock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBlockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoreServer).GetBlock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/grpcapi.Core/GetBlock",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoreServer).GetBlock(ctx, req.(*GetBlockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Core_GetTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoreServer).GetTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/grpcapi.Core/GetTransaction",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoreServer).GetTransaction(ctx, req.(*GetTransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Core_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CoreServer).Subscribe(m, &coreSubscribeServer{stream})
+}
+
+type Core_SubscribeServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type coreSubscribeServer struct {
+	grpc.ServerStream
+}
+
+func (x *coreSubscribeServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _Core_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "grpcapi.Core",
+	HandlerType: (*CoreServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "BuildTransaction",
+			Handler:    _Core_BuildTransaction_Handler,
+		},
+		{
+			MethodName: "SubmitTransaction",
+			Handler:    _Core_SubmitTransaction_Handler,
+		},
+		{
+			MethodName: "ListAccounts",
+			Handler:    _Core_ListAccounts_Handler,
+		},
+		{
+			MethodName: "ListAssets",
+			Handler:    _Core_ListAssets_Handler,
+		},
+		{
+			MethodName: "ListTransactions",
+			Handler:    _Core_ListTransactions_Handler,
+		},
+		{
+			MethodName: "ListBalances",
+			Handler:    _Core_ListBalances_Handler,
+		},
+		{
+			MethodName: "ListUnspentOutputs",
+			Handler:    _Core_ListUnspentOutputs_Handler,
+		},
+		{
+			MethodName: "GetBlock",
+			Handler:    _Core_GetBlock_Handler,
+		},
+		{
+			MethodName: "GetTransaction",
+			Handler:    _Core_GetTransaction_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _Core_Subscribe_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "core.proto",
+}
+
+func init() { proto.RegisterFile("core.proto", fileDescriptor0) }
+
+var fileDescriptor0 = []byte{
+	// 856 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x95, 0x56, 0x6d, 0x4f, 0xd4, 0x40,
+	0x10, 0x0e, 0xc7, 0x1d, 0xd7, 0x9b, 0x3b, 0xe1, 0xd8, 0x70, 0x52, 0x4e, 0x45, 0xa9, 0x26, 0xe2,
+	0x17, 0x54, 0x8c, 0x41, 0xe2, 0x07, 0xc3, 0x8b, 0x21, 0x26, 0x10, 0x49, 0x81, 0x90, 0xf8, 0xa5,
+	0x59, 0x7a, 0xcb, 0xb1, 0xd2, 0xeb, 0xd6, 0xee, 0x56, 0xc4, 0x7f, 0xe1, 0xff, 0xf0, 0x67, 0xf8,
+	0xc3, 0x9c, 0xdd, 0x6e, 0xcb, 0x71, 0x9c, 0x44, 0x3e, 0x75, 0xe7, 0x99, 0x97, 0xdd, 0x99, 0x79,
+	0x66, 0x52, 0x80, 0x50, 0xa4, 0x6c, 0x25, 0x49, 0x85, 0x12, 0xa4, 0xde, 0x4f, 0x93, 0x90, 0x26,
+	0xdc, 0x3b, 0x87, 0xda, 0xc7, 0x34, 0x15, 0x29, 0x21, 0x50, 0x0d, 0x45, 0x8f, 0xb9, 0x13, 0x4f,
+	0x26, 0x96, 0x1b, 0xbe, 0x39, 0x13, 0x17, 0xea, 0x03, 0x26, 0x25, 0xed, 0x33, 0xb7, 0x62, 0xe0,
+	0x42, 0x24, 0xf7, 0x61, 0xaa, 0xc7, 0x14, 0xe5, 0x91, 0x3b, 0x69, 0x14, 0x56, 0x22, 0x0f, 0xa1,
+	0xa1, 0xd8, 0x20, 0x11, 0x29, 0x4d, 0x2f, 0xdd, 0x2a, 0xaa, 0x1c, 0xff, 0x0a, 0xf0, 0xde, 0xc2,
+	0xfc, 0x66, 0xc6, 0xa3, 0xde, 0x61, 0x4a, 0x63, 0x49, 0x43, 0xc5, 0x45, 0xec, 0xb3, 0x6f, 0x19,
+	0x93, 0x8a, 0x74, 0xc1, 0x49, 0xf3, 0xa3, 0xc4, 0x27, 0x4c, 0x2e, 0xb7, 0xfc, 0x52, 0xf6, 0xf6,
+	0xc0, 0xbd, 0xe9, 0x26, 0x13, 0x11, 0x4b, 0x46, 0x5e, 0x43, 0x3d, 0x65, 0x32, 0x8b, 0xac, 0x5b,
+	0x73, 0x75, 0x7e, 0xc5, 0xa6, 0xb6, 0x72, 0x88, 0xf7, 0x46, 0x54, 0x31, 0xdf, 0xe8, 0xfd, 0xc2,
+	0xce, 0xf3, 0x61, 0xfa, 0xba, 0x4a, 0x5f, 0xae, 0x2c, 0x62, 0xf2, 0xc7, 0xcb, 0x0b, 0x99, 0x3c,
+	0x83, 0x1a, 0xd3, 0x05, 0x32, 0x15, 0x68, 0xae, 0x4e, 0x97, 0xe1, 0x4d, 0xd9, 0xfc, 0x5c, 0xe9,
+	0x1d, 0x83, 0x7b, 0x90, 0x9d, 0x0c, 0xb8, 0x1a, 0x93, 0x9a, 0xad, 0x89, 0x8e, 0x56, 0xe4, 0x76,
+	0x05, 0x90, 0x47, 0x00, 0x17, 0x94, 0xab, 0x20, 0x8b, 0x15, 0x56, 0x33, 0x2f, 0x73, 0x43, 0x23,
+	0x47, 0x1a, 0xf0, 0x76, 0x61, 0x61, 0x4c, 0x60, 0x9b, 0xfc, 0xcb, 0xd1, 0xe4, 0x3b, 0xe5, 0xeb,
+	0x72, 0xa7, 0xd1, 0xd4, 0xb7, 0xa1, 0x35, 0xac, 0x20, 0xd3, 0x50, 0xe1, 0x3d, 0xdb, 0x72, 0x3c,
+	0xfd, 0x67, 0xb2, 0x7f, 0x2a, 0xd0, 0xdc, 0xe5, 0x52, 0x15, 0x09, 0x22, 0x19, 0x4e, 0x79, 0xa4,
+	0x58, 0x6a, 0x23, 0x59, 0x89, 0x3c, 0x85, 0x7b, 0xf9, 0x29, 0x48, 0x68, 0x4a, 0x07, 0x12, 0xa3,
+	0xea, 0xe4, 0x5b, 0x39, 0xb8, 0x6f, 0x30, 0xd2, 0x81, 0x29, 0x99, 0x0d, 0x82, 0x93, 0x4b, 0x64,
+	0xd2, 0x24, 0x3a, 0xd7, 0x50, 0xda, 0xbc, 0x24, 0x0f, 0xa0, 0x91, 0x20, 0xd1, 0x02, 0xc9, 0x7f,
+	0x32, 0x43, 0xa4, 0x9a, 0xef, 0x68, 0xe0, 0x00, 0x65, 0x32, 0x07, 0x35, 0x7a, 0xaa, 0xef, 0xab,
+	0x99, 0xfb, 0x72, 0x41, 0x57, 0x52, 0x2a, 0x9a, 0xaa, 0x40, 0xf1, 0x01, 0x73, 0xa7, 0x50, 0x55,
+	0xf5, 0x1b, 0x06, 0x39, 0x44, 0x80, 0x2c, 0x80, 0xc3, 0xe2, 0x5e, 0xae, 0xac, 0x1b, 0x65, 0x1d,
+	0x65, 0xa3, 0xd2, 0x1d, 0xc2, 0x2f, 0xda, 0x0e, 0x12, 0xd7, 0xc9, 0x1d, 0x4b, 0x80, 0xac, 0x81,
+	0x4b, 0x65, 0x88, 0xb6, 0x3c, 0xee, 0x07, 0x17, 0x5c, 0x9d, 0x05, 0x91, 0xc0, 0x53, 0x22, 0xa2,
+	0xc8, 0x6d, 0x18, 0x8a, 0x77, 0x4a, 0xfd, 0x31, 0xaa, 0x77, 0x51, 0xbb, 0x8f, 0x4a, 0xfd, 0x20,
+	0x1d, 0x45, 0x64, 0x2a, 0xc0, 0xe4, 0xe1, 0x2a, 0x2e, 0x22, 0x7b, 0x12, 0x47, 0xaf, 0x95, 0x57,
+	0xd1, 0x76, 0x13, 0xb3, 0xe2, 0xc8, 0x8b, 0x82, 0x23, 0xb9, 0x40, 0x96, 0xa1, 0x1a, 0xb3, 0x1f,
+	0xca, 0x76, 0x64, 0xae, 0xec, 0xc8, 0x50, 0x03, 0x7c, 0x63, 0xa1, 0x4b, 0x16, 0x51, 0xa9, 0x02,
+	0x5d, 0x26, 0x33, 0x96, 0x8e, 0xef, 0x68, 0x60, 0x1f, 0x65, 0x6f, 0x1d, 0x66, 0x76, 0x98, 0xda,
+	0x8c, 0x44, 0x78, 0x3e, 0xd4, 0xb6, 0x33, 0xc6, 0xfb, 0x67, 0xca, 0xb4, 0xad, 0xea, 0x5b, 0xc9,
+	0x92, 0xa2, 0x52, 0x90, 0xc2, 0x7b, 0x0e, 0x1d, 0x74, 0x1d, 0x43, 0xec, 0x11, 0xf6, 0x78, 0x8b,
+	0xe0, 0x6c, 0x8b, 0x30, 0x1b, 0xb0, 0x58, 0xe9, 0x75, 0xf2, 0x55, 0x8a, 0xd8, 0x8e, 0x93, 0x39,
+	0x7b, 0xbf, 0x26, 0xa0, 0x8d, 0xf4, 0x93, 0x61, 0xca, 0x4f, 0x58, 0x11, 0x04, 0xb3, 0x56, 0x97,
+	0x89, 0x9d, 0x0c, 0xec, 0xa5, 0x11, 0xc8, 0x63, 0x68, 0xd2, 0x30, 0x14, 0x38, 0x14, 0x01, 0xef,
+	0xe5, 0xc4, 0x69, 0xf8, 0x60, 0xa1, 0x4f, 0x3d, 0xa9, 0x93, 0xa5, 0x52, 0xb2, 0x5c, 0x9d, 0x33,
+	0xc7, 0x31, 0x80, 0x56, 0xbe, 0x80, 0x76, 0x28, 0x62, 0x95, 0x8a, 0x28, 0xc0, 0x75, 0xd7, 0x37,
+	0xdc, 0xab, 0x1a, 0x9b, 0x19, 0x8b, 0xef, 0x5b, 0xd8, 0xdb, 0xc1, 0xfd, 0xf7, 0xdd, 0x3e, 0x58,
+	0x5f, 0x5d, 0xec, 0x3f, 0x7d, 0x1e, 0xaa, 0x50, 0xe5, 0x5a, 0x85, 0xd0, 0xb6, 0x47, 0x15, 0x35,
+	0x45, 0xc6, 0xe4, 0xf4, 0x79, 0xf5, 0x77, 0x0d, 0xaa, 0x5b, 0xb8, 0x60, 0xc9, 0x31, 0xb4, 0x47,
+	0xb7, 0x15, 0x79, 0x52, 0xb6, 0xed, 0x1f, 0xfb, 0xaf, 0xbb, 0x74, 0x8b, 0x85, 0xe5, 0xc7, 0x17,
+	0x98, 0xbd, 0xb1, 0x0a, 0xc8, 0xd2, 0xc8, 0xc4, 0x8f, 0x09, 0xed, 0xdd, 0x66, 0x62, 0x63, 0xbf,
+	0xcf, 0xb9, 0xb8, 0x91, 0x17, 0x58, 0x92, 0xb1, 0x3c, 0xeb, 0x76, 0x46, 0x50, 0xeb, 0xbc, 0x0e,
+	0x60, 0x9c, 0x75, 0xf9, 0xef, 0xe8, 0xfa, 0x01, 0xda, 0x5a, 0x1e, 0x7a, 0xd2, 0x1d, 0x03, 0xd8,
+	0x87, 0x6f, 0xd2, 0x88, 0xc6, 0x21, 0xbb, 0xa3, 0xf3, 0x06, 0x10, 0x2d, 0x1f, 0xc5, 0x32, 0x41,
+	0x0a, 0x7c, 0xce, 0x54, 0x92, 0xdd, 0x35, 0x81, 0x35, 0x70, 0x8a, 0xb9, 0x22, 0x6e, 0x69, 0x32,
+	0x32, 0x6a, 0xdd, 0xd9, 0x52, 0x53, 0x0e, 0xc8, 0x16, 0x4c, 0x5f, 0x9f, 0x2a, 0xb2, 0x38, 0xec,
+	0x3e, 0xa6, 0x8f, 0x63, 0x82, 0xbc, 0x83, 0x46, 0x39, 0x50, 0x64, 0x61, 0xb8, 0xcf, 0xd7, 0x86,
+	0xac, 0x3b, 0xb4, 0xc8, 0x35, 0xd9, 0x5f, 0x4d, 0x9c, 0x4c, 0x99, 0xff, 0x80, 0x37, 0x7f, 0x01,
+	0x02, 0x71, 0x5e, 0x03, 0x15, 0x08, 0x00, 0x00,
+}
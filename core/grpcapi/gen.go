@@ -0,0 +1,6 @@
+// Package grpcapi defines the messages and service of the Core's
+// gRPC API.
+package grpcapi
+
+// Generate code for the Core service and its messages.
+//go:generate protoc --go_out=plugins=grpc:. core.proto
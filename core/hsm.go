@@ -6,7 +6,6 @@ import (
 	"context"
 
 	"github.com/chainmint/core/mockhsm"
-	"github.com/chainmint/core/txbuilder"
 	"github.com/chainmint/crypto/ed25519/chainkd"
 	"github.com/chainmint/net/http/httperror"
 	"github.com/chainmint/net/http/httpjson"
@@ -22,6 +21,7 @@ func init() {
 // is only included in non-production builds.
 func MockHSM(hsm *mockhsm.HSM) RunOption {
 	return func(a *API) {
+		a.mockHSM = true
 		h := &mockHSMHandler{MockHSM: hsm}
 
 		needConfig := a.needConfig()
@@ -74,21 +74,8 @@ func (h *mockHSMHandler) mockhsmDelKey(ctx context.Context, xpub chainkd.XPub) e
 	return h.MockHSM.DeleteChainKDKey(ctx, xpub)
 }
 
-func (h *mockHSMHandler) mockhsmSignTemplates(ctx context.Context, x struct {
-	Txs   []*txbuilder.Template `json:"transactions"`
-	XPubs []chainkd.XPub        `json:"xpubs"`
-}) []interface{} {
-	resp := make([]interface{}, 0, len(x.Txs))
-	for _, tx := range x.Txs {
-		err := txbuilder.Sign(ctx, tx, x.XPubs, h.mockhsmSignTemplate)
-		if err != nil {
-			info := errorFormatter.Format(err)
-			resp = append(resp, info)
-		} else {
-			resp = append(resp, tx)
-		}
-	}
-	return resp
+func (h *mockHSMHandler) mockhsmSignTemplates(ctx context.Context, x signRequest) []interface{} {
+	return signWith(ctx, x, h.mockhsmSignTemplate)
 }
 
 func (h *mockHSMHandler) mockhsmSignTemplate(ctx context.Context, xpub chainkd.XPub, path [][]byte, data [32]byte) ([]byte, error) {
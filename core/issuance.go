@@ -0,0 +1,135 @@
+package core
+
+import (
+	"context"
+
+	chainjson "github.com/chainmint/encoding/json"
+	"github.com/chainmint/errors"
+)
+
+// issueRequest asks for units of an asset to be issued to an account
+// or to a control program.
+type issueRequest struct {
+	AssetID        string             `json:"asset_id"`
+	AssetAlias     string             `json:"asset_alias"`
+	Amount         uint64             `json:"amount"`
+	AccountID      string             `json:"account_id"`
+	AccountAlias   string             `json:"account_alias"`
+	ControlProgram chainjson.HexBytes `json:"control_program"`
+	ReferenceData  chainjson.Map      `json:"reference_data"`
+	TTL            chainjson.Duration `json:"ttl"`
+}
+
+// retireRequest asks for units of an asset held by an account to be
+// retired.
+type retireRequest struct {
+	AssetID       string             `json:"asset_id"`
+	AssetAlias    string             `json:"asset_alias"`
+	Amount        uint64             `json:"amount"`
+	AccountID     string             `json:"account_id"`
+	AccountAlias  string             `json:"account_alias"`
+	ReferenceData chainjson.Map      `json:"reference_data"`
+	TTL           chainjson.Duration `json:"ttl"`
+}
+
+// buildRequest returns the build request for the issuance: an issue
+// action and a control action for the issued units.
+func (r *issueRequest) buildRequest() (*BuildRequest, error) {
+	haveAccount := r.AccountID != "" || r.AccountAlias != ""
+	if haveAccount == (len(r.ControlProgram) > 0) {
+		return nil, errors.WithDetail(errBadAction, "exactly one of an account or a control_program must receive the issued units")
+	}
+	issue := assetAction("issue", r.AssetID, r.AssetAlias, r.Amount)
+	if len(r.ReferenceData) > 0 {
+		issue["reference_data"] = r.ReferenceData
+	}
+	var control map[string]interface{}
+	if haveAccount {
+		control = assetAction("control_account", r.AssetID, r.AssetAlias, r.Amount)
+		setAccount(control, r.AccountID, r.AccountAlias)
+	} else {
+		control = assetAction("control_program", r.AssetID, r.AssetAlias, r.Amount)
+		control["control_program"] = r.ControlProgram
+	}
+	return &BuildRequest{
+		Actions: []map[string]interface{}{issue, control},
+		TTL:     r.TTL,
+	}, nil
+}
+
+// buildRequest returns the build request for the retirement: a spend
+// from the account and a retire action for the spent units.
+func (r *retireRequest) buildRequest() (*BuildRequest, error) {
+	if r.AccountID == "" && r.AccountAlias == "" {
+		return nil, errors.WithDetail(errBadAction, "an account must hold the units to retire")
+	}
+	spend := assetAction("spend_account", r.AssetID, r.AssetAlias, r.Amount)
+	setAccount(spend, r.AccountID, r.AccountAlias)
+	retire := assetAction("retire", r.AssetID, r.AssetAlias, r.Amount)
+	if len(r.ReferenceData) > 0 {
+		retire["reference_data"] = r.ReferenceData
+	}
+	return &BuildRequest{
+		Actions: []map[string]interface{}{spend, retire},
+		TTL:     r.TTL,
+	}, nil
+}
+
+// assetAction returns a build action of the given type for an amount
+// of an asset, named by ID or alias.
+func assetAction(typ, assetID, assetAlias string, amount uint64) map[string]interface{} {
+	act := map[string]interface{}{"type": typ, "amount": amount}
+	if assetID != "" {
+		act["asset_id"] = assetID
+	}
+	if assetAlias != "" {
+		act["asset_alias"] = assetAlias
+	}
+	return act
+}
+
+// setAccount names the account of act by ID or alias.
+func setAccount(act map[string]interface{}, accountID, accountAlias string) {
+	if accountID != "" {
+		act["account_id"] = accountID
+	}
+	if accountAlias != "" {
+		act["account_alias"] = accountAlias
+	}
+}
+
+// issueAsset is an http handler for building transactions that issue
+// units of assets defined with /create-asset. Like /build-transaction,
+// it returns templates to be signed and then submitted with
+// /submit-transaction.
+//
+// POST /issue-asset
+func (a *API) issueAsset(ctx context.Context, ins []*issueRequest) (interface{}, error) {
+	reqs := make([]*BuildRequest, len(ins))
+	for i, in := range ins {
+		req, err := in.buildRequest()
+		if err != nil {
+			return nil, errors.WithDetailf(err, "on request %d", i)
+		}
+		reqs[i] = req
+	}
+	return a.build(ctx, reqs)
+}
+
+// retireAsset is an http handler for building transactions that
+// retire units of assets held by accounts. Like /build-transaction,
+// it returns templates to be signed and then submitted with
+// /submit-transaction.
+//
+// POST /retire-asset
+func (a *API) retireAsset(ctx context.Context, ins []*retireRequest) (interface{}, error) {
+	reqs := make([]*BuildRequest, len(ins))
+	for i, in := range ins {
+		req, err := in.buildRequest()
+		if err != nil {
+			return nil, errors.WithDetailf(err, "on request %d", i)
+		}
+		reqs[i] = req
+	}
+	return a.build(ctx, reqs)
+}
@@ -0,0 +1,57 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+
+	chainjson "github.com/chainmint/encoding/json"
+	"github.com/chainmint/errors"
+)
+
+func TestIssuanceBuildRequests(t *testing.T) {
+	assetID := "0000000000000000000000000000000000000000000000000000000000000001"
+	issue := &issueRequest{AssetID: assetID, Amount: 5, AccountAlias: "alice"}
+	req, err := issue.buildRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(req.Actions) != 2 || req.Actions[0]["type"] != "issue" || req.Actions[1]["type"] != "control_account" {
+		t.Fatalf("issue actions = %v", req.Actions)
+	}
+	if req.Actions[1]["account_alias"] != "alice" || req.Actions[1]["asset_id"] != assetID {
+		t.Errorf("control action = %v", req.Actions[1])
+	}
+
+	// The issued units go to exactly one destination.
+	for _, bad := range []*issueRequest{
+		{AssetID: assetID, Amount: 5},
+		{AssetID: assetID, Amount: 5, AccountID: "acc1", ControlProgram: chainjson.HexBytes{0x51}},
+	} {
+		_, err = bad.buildRequest()
+		if errors.Root(err) != errBadAction {
+			t.Errorf("buildRequest(%+v) error = %v want %v", bad, err, errBadAction)
+		}
+	}
+
+	retire := &retireRequest{AssetAlias: "gold", Amount: 3, AccountID: "acc1", ReferenceData: chainjson.Map(`{"memo":"burn"}`)}
+	req, err = retire.buildRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(req.Actions) != 2 || req.Actions[0]["type"] != "spend_account" || req.Actions[1]["type"] != "retire" {
+		t.Fatalf("retire actions = %v", req.Actions)
+	}
+	b, err := json.Marshal(req.Actions[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"amount":3,"asset_alias":"gold","reference_data":{"memo":"burn"},"type":"retire"}`
+	if string(b) != want {
+		t.Errorf("retire action = %s want %s", b, want)
+	}
+
+	_, err = (&retireRequest{AssetID: assetID, Amount: 3}).buildRequest()
+	if errors.Root(err) != errBadAction {
+		t.Errorf("retire without account error = %v want %v", err, errBadAction)
+	}
+}
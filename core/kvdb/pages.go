@@ -0,0 +1,67 @@
+// Package kvdb keeps parts of the chain state in an embedded
+// key-value store, next to the node's Postgres database: the cold
+// pages of the state tree, held on disk rather than in memory.
+package kvdb
+
+import (
+	"encoding/binary"
+
+	"github.com/chainmint/database/kv"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/patricia"
+)
+
+// pagePrefix begins the keys of pages, then the page's hash.
+const pagePrefix = 'p'
+
+// Pages stores the pages of a patricia.Tier in a kv.Store. Each is
+// stored as its items, each preceded by its length as a uvarint.
+type Pages struct {
+	kv kv.Store
+}
+
+var _ patricia.ColdStore = (*Pages)(nil)
+
+// NewPages returns a Pages keeping pages in s.
+func NewPages(s kv.Store) *Pages {
+	return &Pages{kv: s}
+}
+
+func pageKey(hash bc.Hash) []byte {
+	return append([]byte{pagePrefix}, hash.Bytes()...)
+}
+
+// PutPages implements patricia.ColdStore.
+func (p *Pages) PutPages(pages map[bc.Hash][][]byte) error {
+	var batch kv.Batch
+	for hash, items := range pages {
+		var data []byte
+		for _, item := range items {
+			var n [binary.MaxVarintLen64]byte
+			data = append(data, n[:binary.PutUvarint(n[:], uint64(len(item)))]...)
+			data = append(data, item...)
+		}
+		batch.Put(pageKey(hash), data)
+	}
+	return errors.Wrap(p.kv.Write(&batch), "saving pages")
+}
+
+// GetPage implements patricia.ColdStore.
+func (p *Pages) GetPage(hash bc.Hash) ([][]byte, error) {
+	data, err := p.kv.Get(pageKey(hash))
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting page %x", hash.Bytes())
+	}
+	var items [][]byte
+	for len(data) > 0 {
+		n, size := binary.Uvarint(data)
+		if size <= 0 || n > uint64(len(data)-size) {
+			return nil, errors.WithDetailf(patricia.ErrPageCorrupt, "page %x is truncated", hash.Bytes())
+		}
+		data = data[size:]
+		items = append(items, data[:n:n])
+		data = data[n:]
+	}
+	return items, nil
+}
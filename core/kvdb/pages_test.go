@@ -0,0 +1,47 @@
+package kvdb
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/chainmint/database/kv"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/patricia"
+)
+
+func TestPages(t *testing.T) {
+	mem := kv.NewMem()
+	p := NewPages(mem)
+	h1, h2 := bc.NewHash([32]byte{1}), bc.NewHash([32]byte{2})
+	want := [][]byte{[]byte("a"), bytes.Repeat([]byte{'b'}, 300), {}}
+	err := p.PutPages(map[bc.Hash][][]byte{h1: want, h2: nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := p.GetPage(h1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("GetPage = %d items, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if !bytes.Equal(got[i], want[i]) {
+			t.Errorf("item %d = %q want %q", i, got[i], want[i])
+		}
+	}
+	if got, err = p.GetPage(h2); err != nil || len(got) != 0 {
+		t.Errorf("GetPage of empty page = %q, %v", got, err)
+	}
+	if _, err = p.GetPage(bc.NewHash([32]byte{3})); errors.Root(err) != kv.ErrNotFound {
+		t.Errorf("GetPage of missing page: got error %v, want %v", err, kv.ErrNotFound)
+	}
+
+	var batch kv.Batch
+	batch.Put(pageKey(h1), []byte{5, 'a'})
+	mem.Write(&batch)
+	if _, err = p.GetPage(h1); errors.Root(err) != patricia.ErrPageCorrupt {
+		t.Errorf("GetPage of truncated page: got error %v, want %v", err, patricia.ErrPageCorrupt)
+	}
+}
@@ -14,6 +14,8 @@ import (
 	"github.com/chainmint/log"
 )
 
+var leaderLog = log.Module("leader")
+
 // ProcessState is an enum describing the current state of the
 // process. A recovering process has become leader but is still
 // recovering the blockchain state. Some functionality is not
@@ -99,20 +101,20 @@ func Run(ctx context.Context, db pg.DB, addr string, lead func(context.Context))
 		lead:    lead,
 		address: addr,
 	}
-	log.Printf(ctx, "Using leaderKey: %q", l.key)
+	leaderLog.Infokv(ctx, log.KeyMessage, "using leader key", "key", l.key)
 
 	go func() {
 		cancel := func() {}
 		var leadCtx context.Context
 		for leader := range leadershipChanges(ctx, l) {
 			if leader {
-				log.Printf(ctx, "I am the core leader")
+				leaderLog.Infokv(ctx, log.KeyMessage, "I am the core leader")
 				l.state.Store(Recovering)
 				leadCtx, cancel = context.WithCancel(ctx)
 				l.lead(leadCtx)
 				l.state.Store(Leading)
 			} else {
-				log.Printf(ctx, "No longer core leader")
+				leaderLog.Infokv(ctx, log.KeyMessage, "no longer core leader")
 				l.state.Store(Following)
 				cancel()
 			}
@@ -0,0 +1,27 @@
+package core
+
+import (
+	"context"
+
+	"github.com/chainmint/log"
+)
+
+// setLogLevels sets the log levels in req.Levels, as LOG_LEVEL
+// does at startup, such as "info,app=debug" to log the ABCI
+// requests Tendermint makes. An empty req.Levels changes nothing.
+// It returns the levels in effect afterward. The levels last until
+// the process restarts.
+//
+// POST /set-log-levels
+func (a *API) setLogLevels(ctx context.Context, req struct {
+	Levels string `json:"levels"`
+}) (map[string]string, error) {
+	if req.Levels != "" {
+		err := log.SetLevels(req.Levels)
+		if err != nil {
+			return nil, err
+		}
+		log.Printkv(ctx, "at", "log levels set", "levels", log.Levels())
+	}
+	return map[string]string{"levels": log.Levels()}, nil
+}
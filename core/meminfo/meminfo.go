@@ -0,0 +1,33 @@
+// Package meminfo reports the memory available on the system.
+package meminfo
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"strconv"
+)
+
+// Available returns the memory available to new allocations
+// without swapping, in bytes, as reported by the kernel. It returns
+// 0 if this is not known.
+func Available() int64 {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := bytes.Fields(s.Bytes())
+		if len(fields) < 2 || string(fields[0]) != "MemAvailable:" {
+			continue
+		}
+		kb, err := strconv.ParseInt(string(fields[1]), 10, 64)
+		if err != nil {
+			return 0
+		}
+		return kb * 1024
+	}
+	return 0
+}
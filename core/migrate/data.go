@@ -67,4 +67,154 @@ var migrations = []migration{
 		ALTER TABLE generator_pending_block
 			ADD COLUMN height bigint;
 	`},
+	{Name: `2017-05-01.0.app.strategy-states.sql`, SQL: `
+		CREATE TABLE strategy_states (
+			height bigint NOT NULL PRIMARY KEY,
+			validators bytea NOT NULL,
+			data bytea
+		);
+	`},
+	{Name: `2017-05-01.1.app.governance.sql`, SQL: `
+		ALTER TABLE strategy_states ADD COLUMN governance bytea;
+	`},
+	{Name: `2017-05-01.2.app.stake-book.sql`, SQL: `
+		ALTER TABLE strategy_states ADD COLUMN stake bytea;
+	`},
+	{Name: `2017-05-01.3.app.epoch-pending-diffs.sql`, SQL: `
+		ALTER TABLE strategy_states ADD COLUMN pending_diffs bytea;
+	`},
+	{Name: `2017-05-01.5.query.tx-categories.sql`, SQL: `
+		CREATE TABLE category_rules (
+			id text DEFAULT next_chain_id('catr'::text) NOT NULL PRIMARY KEY,
+			category text NOT NULL,
+			filter text NOT NULL,
+			filter_params jsonb NOT NULL,
+			min_amount bigint NOT NULL,
+			max_amount bigint NOT NULL
+		);
+		CREATE TABLE annotated_tx_categories (
+			block_height bigint NOT NULL,
+			tx_hash bytea NOT NULL,
+			category text NOT NULL,
+			PRIMARY KEY (tx_hash, category)
+		);
+		CREATE INDEX annotated_tx_categories_category_idx ON annotated_tx_categories USING btree (category, block_height);
+	`},
+	{Name: `2017-05-01.6.app.asset-holders.sql`, SQL: `
+		CREATE TABLE asset_holders (
+			asset_id bytea NOT NULL,
+			control_program bytea NOT NULL,
+			amount bigint NOT NULL,
+			PRIMARY KEY (asset_id, control_program)
+		);
+		CREATE INDEX asset_holders_asset_id_amount_idx ON asset_holders USING btree (asset_id, amount DESC, control_program);
+	`},
+	{Name: `2017-05-01.7.app.chain-crypto.sql`, SQL: `
+		CREATE TABLE chain_crypto (
+			singleton boolean DEFAULT true NOT NULL,
+			provider text NOT NULL,
+			CONSTRAINT chain_crypto_singleton CHECK (singleton),
+			PRIMARY KEY (singleton)
+		);
+	`},
+	{Name: `2017-05-01.8.query.filter-lookup-indexes.sql`, SQL: `
+		CREATE INDEX annotated_txs_tx_hash_idx ON annotated_txs USING btree (tx_hash);
+		CREATE INDEX annotated_outputs_tx_hash_idx ON annotated_outputs USING btree (tx_hash);
+		CREATE INDEX annotated_inputs_asset_id_idx ON annotated_inputs USING btree (encode(asset_id, 'hex'));
+		CREATE INDEX annotated_inputs_account_id_idx ON annotated_inputs USING btree (account_id) WHERE account_id IS NOT NULL;
+		CREATE INDEX annotated_outputs_asset_id_idx ON annotated_outputs USING btree (encode(asset_id, 'hex'));
+		CREATE INDEX annotated_outputs_account_id_idx ON annotated_outputs USING btree (account_id) WHERE account_id IS NOT NULL;
+	`},
+	{Name: `2017-05-01.9.query.tx-flags.sql`, SQL: `
+		CREATE TABLE annotated_tx_flags (
+			id bigserial PRIMARY KEY,
+			tx_hash bytea NOT NULL,
+			block_height bigint NOT NULL,
+			account_id text DEFAULT ''::text NOT NULL,
+			reason text NOT NULL,
+			detail text DEFAULT ''::text NOT NULL,
+			UNIQUE (tx_hash, account_id, reason)
+		);
+		CREATE INDEX annotated_tx_flags_block_height_idx ON annotated_tx_flags USING btree (block_height);
+	`},
+	{Name: `2017-05-02.0.app.balance-checkpoints.sql`, SQL: `
+		CREATE TABLE balance_checkpoint_blocks (
+			height bigint NOT NULL PRIMARY KEY,
+			"timestamp" bigint NOT NULL
+		);
+		CREATE TABLE balance_checkpoints (
+			height bigint NOT NULL,
+			account_id text NOT NULL,
+			asset_id bytea NOT NULL,
+			amount bigint NOT NULL,
+			PRIMARY KEY (account_id, height, asset_id)
+		);
+	`},
+	{Name: `2017-05-03.0.app.metadata-index.sql`, SQL: `
+		CREATE TABLE metadata_index (
+			kind text NOT NULL,
+			id bytea NOT NULL,
+			block_height bigint NOT NULL,
+			path text NOT NULL,
+			value text NOT NULL,
+			PRIMARY KEY (kind, id, path, value)
+		);
+		CREATE INDEX metadata_index_value_idx ON metadata_index USING btree (kind, path, lower(value) text_pattern_ops);
+	`},
+	{Name: `2017-05-04.0.app.asset-supply.sql`, SQL: `
+		CREATE TABLE asset_supply (
+			asset_id bytea NOT NULL,
+			height bigint NOT NULL,
+			"timestamp" bigint NOT NULL,
+			issued bigint NOT NULL,
+			retired bigint NOT NULL,
+			total_issued bigint NOT NULL,
+			total_retired bigint NOT NULL,
+			PRIMARY KEY (asset_id, height)
+		);
+	`},
+	{Name: `2017-05-05.0.core.snapshot-files.sql`, SQL: `
+		CREATE TABLE snapshot_files (
+			height bigint NOT NULL PRIMARY KEY,
+			hash bytea NOT NULL,
+			size bigint NOT NULL,
+			created_at timestamp without time zone DEFAULT now() NOT NULL
+		);
+	`},
+	{Name: `2017-05-06.0.core.block-pruning.sql`, SQL: `
+		CREATE TABLE block_pruning (
+			singleton boolean DEFAULT true NOT NULL,
+			below bigint NOT NULL,
+			CONSTRAINT block_pruning_singleton CHECK (singleton),
+			PRIMARY KEY (singleton)
+		);
+	`},
+	{Name: `2017-05-07.0.app.chain-app-hash.sql`, SQL: `
+		CREATE TABLE chain_app_hash (
+			singleton boolean DEFAULT true NOT NULL,
+			format text NOT NULL,
+			CONSTRAINT chain_app_hash_singleton CHECK (singleton),
+			PRIMARY KEY (singleton)
+		);
+	`},
+	{Name: `2017-05-08.0.app.commit-wal.sql`, SQL: `
+		CREATE TABLE commit_wal (
+			singleton boolean DEFAULT true NOT NULL,
+			height bigint NOT NULL,
+			data bytea NOT NULL,
+			CONSTRAINT commit_wal_singleton CHECK (singleton),
+			PRIMARY KEY (singleton)
+		);
+	`},
+	{Name: `2017-05-10.0.app.strategy-state-chain-height.sql`, SQL: `
+		ALTER TABLE strategy_states ADD COLUMN chain_height bigint;
+	`},
+	{Name: `2017-05-11.0.app.chain-strategy.sql`, SQL: `
+		CREATE TABLE chain_strategy (
+			singleton boolean DEFAULT true NOT NULL,
+			name text NOT NULL,
+			CONSTRAINT chain_strategy_singleton CHECK (singleton),
+			PRIMARY KEY (singleton)
+		);
+	`},
 }
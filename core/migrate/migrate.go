@@ -7,11 +7,31 @@ import (
 	"time"
 
 	"github.com/chainmint/database/pg"
+	"github.com/chainmint/database/sql"
 	"github.com/chainmint/errors"
 	"github.com/chainmint/log"
 )
 
+var (
+	// ErrDirty is returned when a migration was started but never
+	// recorded as applied. The schema may be partly migrated, so
+	// nothing more is run until an operator has looked at it.
+	ErrDirty = errors.New("database schema is dirty")
+
+	// ErrPending is returned by Check when the database is missing
+	// migrations this binary knows about.
+	ErrPending = errors.New("database schema is out of date")
+
+	// ErrNewer is returned when the database has migrations applied
+	// that are newer than any this binary knows about, as when
+	// downgrading to an older version.
+	ErrNewer = errors.New("database schema is newer than this binary")
+)
+
 // Run runs all built-in migrations.
+// It refuses to run any if the database is dirty or newer than
+// this binary. A migration that fails leaves the database dirty,
+// and the returned error names it.
 func Run(db pg.DB) error {
 	ctx := context.Background()
 
@@ -26,24 +46,40 @@ func Run(db pg.DB) error {
 		return err
 	}
 
+	err = checkDirty(db)
+	if err != nil {
+		return err
+	}
+
 	err = loadStatus(db, migrations)
 	if err != nil {
 		return err
 	}
 
+	err = checkNewer(db, migrations)
+	if err != nil {
+		return err
+	}
+
 	for _, m := range migrations {
 		if !m.AppliedAt.IsZero() {
 			continue
 		}
 		fmt.Println("Pending migration:", m.Name)
-		_, err := db.Exec(ctx, m.SQL)
+		err = markDirty(db, m)
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec(ctx, m.SQL)
 		if err != nil {
-			return errors.Wrapf(err, "migration %s", m.Name)
+			return errors.WithDetailf(errors.Wrapf(err, "migration %s", m.Name),
+				"migration %s failed and the database is marked dirty", m.Name)
 		}
 
 		// The migration and the insertion cannot be grouped in a single
 		// transaction, because some migrations contain SQL that cannot be
-		// run within a transaction.
+		// run within a transaction. A crash between the two leaves the
+		// database dirty.
 		err = insertAppliedMigration(db, m)
 		if err != nil {
 			return err
@@ -54,6 +90,37 @@ func Run(db pg.DB) error {
 	return nil
 }
 
+// Check reports whether the database schema is current,
+// without changing it. It is meant to be run at startup,
+// before anything else uses the database.
+// It returns ErrDirty, ErrNewer or ErrPending,
+// with details naming the migration concerned.
+func Check(db pg.DB) error {
+	err := checkDirty(db)
+	if err != nil {
+		return err
+	}
+
+	ms := make([]migration, len(migrations))
+	copy(ms, migrations)
+	err = loadStatus(db, ms)
+	if err != nil {
+		return err
+	}
+
+	err = checkNewer(db, ms)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range ms {
+		if m.AppliedAt.IsZero() {
+			return errors.WithDetailf(ErrPending, "migration %s has not been applied", m.Name)
+		}
+	}
+	return nil
+}
+
 // PrintStatus prints the status of each built-in migration.
 func PrintStatus(db pg.DB) error {
 	err := loadStatus(db, migrations)
@@ -79,6 +146,13 @@ const createMigrationTableSQL = `
 		  applied_at timestamp with time zone DEFAULT now() NOT NULL,
 		  PRIMARY KEY(filename)
 	  );
+	  CREATE TABLE IF NOT EXISTS migration_dirty (
+		  singleton boolean DEFAULT true NOT NULL,
+		  filename text NOT NULL,
+		  started_at timestamp with time zone DEFAULT now() NOT NULL,
+		  CONSTRAINT migration_dirty_singleton CHECK (singleton),
+		  PRIMARY KEY(singleton)
+	  );
 `
 
 func (m migration) String() string {
@@ -91,16 +165,11 @@ func (m migration) String() string {
 // from the migration's computed hash.
 func loadStatus(db pg.DB, ms []migration) error {
 	ctx := context.Background()
-	const q = `
-		SELECT count(*) FROM pg_tables
-		WHERE schemaname='public' AND tablename='migrations'
-	`
-	var n int
-	err := db.QueryRow(ctx, q).Scan(&n)
+	ok, err := tableExists(db, "migrations")
 	if err != nil {
 		log.Fatalkv(ctx, log.KeyError, err)
 	}
-	if n == 0 {
+	if !ok {
 		return nil // no schema; nothing has been applied
 	}
 
@@ -128,6 +197,75 @@ func loadStatus(db pg.DB, ms []migration) error {
 	return errors.Wrap(rows.Err())
 }
 
+// tableExists reports whether the named table
+// is in the public schema of db.
+func tableExists(db pg.DB, name string) (bool, error) {
+	const q = `
+		SELECT count(*) FROM pg_tables
+		WHERE schemaname='public' AND tablename=$1
+	`
+	var n int
+	err := db.QueryRow(context.Background(), q, name).Scan(&n)
+	return n > 0, errors.Wrap(err)
+}
+
+// checkDirty returns ErrDirty if a migration
+// was started in db and never finished.
+func checkDirty(db pg.DB) error {
+	ok, err := tableExists(db, "migration_dirty")
+	if err != nil || !ok {
+		return err
+	}
+
+	var (
+		name    string
+		started time.Time
+	)
+	const q = `SELECT filename, started_at FROM migration_dirty`
+	err = db.QueryRow(context.Background(), q).Scan(&name, &started)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	return errors.WithDetailf(ErrDirty,
+		"migration %s started at %s did not finish; once the schema has been repaired, delete the row in table migration_dirty",
+		name, started.Format(time.RFC3339))
+}
+
+// checkNewer returns ErrNewer if db records an applied
+// migration that sorts after the last one in ms.
+// Unknown names sorting earlier are migrations
+// since squashed into a snapshot, and are ignored.
+func checkNewer(db pg.DB, ms []migration) error {
+	if len(ms) == 0 {
+		return nil
+	}
+	ok, err := tableExists(db, "migrations")
+	if err != nil || !ok {
+		return err
+	}
+
+	var name string
+	const q = `SELECT filename FROM migrations WHERE filename > $1 ORDER BY filename LIMIT 1`
+	err = db.QueryRow(context.Background(), q, ms[len(ms)-1].Name).Scan(&name)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	return errors.WithDetailf(ErrNewer, "migration %s is applied but unknown to this binary", name)
+}
+
+// markDirty records that m is about to be run.
+func markDirty(db pg.DB, m migration) error {
+	const q = `INSERT INTO migration_dirty (filename) VALUES ($1)`
+	_, err := db.Exec(context.Background(), q, m.Name)
+	return errors.Wrap(err, "marking migration started")
+}
+
 // Well this is funny. We are going to migrate our migrations.
 // We squashed our migration history into a single migration
 // (2016-10-17.0.core.schema-snapshot.sql), but some deployed
@@ -181,7 +319,10 @@ func find(name string, ms []migration) *migration {
 func insertAppliedMigration(db pg.DB, m migration) error {
 	ctx := context.Background()
 
+	// Clear the dirty mark in the same statement,
+	// so the two can't disagree.
 	const q = `
+		WITH clean AS (DELETE FROM migration_dirty)
 		INSERT INTO migrations (filename, hash, applied_at)
 		VALUES($1, $2, NOW())
 	`
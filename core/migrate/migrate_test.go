@@ -3,10 +3,12 @@ package migrate
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/chainmint/database/pg/pgtest"
+	"github.com/chainmint/errors"
 )
 
 func TestLoadStatus(t *testing.T) {
@@ -123,3 +125,70 @@ func TestApplyMigrationSQL(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestRunFailedMigrationIsDirty(t *testing.T) {
+	save := migrations
+	defer func() { migrations = save }()
+
+	_, db := pgtest.NewDB(t, "testdata/empty.sql")
+
+	migrations = []migration{
+		{Name: "2017-05-09.0.test.ok.sql", SQL: `CREATE TABLE test_table (a int);`},
+		{Name: "2017-05-09.1.test.broken.sql", SQL: `ALTER TABLE no_such_table ADD COLUMN b int;`},
+	}
+	for i, m := range migrations {
+		h := sha256.Sum256([]byte(m.SQL))
+		migrations[i].Hash = hex.EncodeToString(h[:])
+	}
+
+	err := Run(db)
+	if err == nil {
+		t.Fatal("expected migration to fail")
+	}
+	if got := errors.Detail(err); !strings.Contains(got, migrations[1].Name) {
+		t.Errorf("error detail = %q, want it to name %s", got, migrations[1].Name)
+	}
+
+	err = Run(db)
+	if errors.Root(err) != ErrDirty {
+		t.Errorf("rerun: got error %v, want %v", err, ErrDirty)
+	}
+	err = Check(db)
+	if errors.Root(err) != ErrDirty {
+		t.Errorf("check: got error %v, want %v", err, ErrDirty)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	save := migrations
+	defer func() { migrations = save }()
+
+	_, db := pgtest.NewDB(t, "testdata/empty.sql")
+
+	migrations = []migration{{Name: "2017-05-09.0.test.a.sql", SQL: `CREATE TABLE test_a (a int);`}}
+	h := sha256.Sum256([]byte(migrations[0].SQL))
+	migrations[0].Hash = hex.EncodeToString(h[:])
+
+	err := Check(db)
+	if errors.Root(err) != ErrPending {
+		t.Errorf("before Run: got error %v, want %v", err, ErrPending)
+	}
+
+	err = Run(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Check(db)
+	if err != nil {
+		t.Errorf("after Run: got error %v", err)
+	}
+
+	// An older binary must not touch the newer schema.
+	migrations = []migration{{Name: "2017-05-08.0.test.old.sql", SQL: `SELECT 1;`}}
+	h = sha256.Sum256([]byte(migrations[0].SQL))
+	migrations[0].Hash = hex.EncodeToString(h[:])
+	err = Check(db)
+	if errors.Root(err) != ErrNewer {
+		t.Errorf("older binary: got error %v, want %v", err, ErrNewer)
+	}
+}
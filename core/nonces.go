@@ -0,0 +1,58 @@
+package core
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/chainmint/protocol/bc"
+)
+
+// issuanceNonces describes the nonce set of the chain state: the
+// nonces of issuances whose time ranges have not yet passed, which
+// may not be used again.
+type issuanceNonces struct {
+	Height              uint64        `json:"height"`
+	Count               int           `json:"count"`
+	MinTimeSlackMS      uint64        `json:"min_time_slack_ms"`
+	MaxIssuanceWindowMS uint64        `json:"max_issuance_window_ms"`
+	Nonces              []*issueNonce `json:"nonces"`
+}
+
+type issueNonce struct {
+	ID        bc.Hash   `json:"id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// getIssuanceNonces is an http handler for inspecting the nonce set
+// and the issuance window, for issuers tuning the time ranges of
+// their issuances. It lists the nonces that expire soonest, up to
+// page_size.
+//
+// POST /get-issuance-nonces
+func (a *API) getIssuanceNonces(ctx context.Context, in struct {
+	PageSize int `json:"page_size"`
+}) (*issuanceNonces, error) {
+	b, snapshot := a.chain.State()
+	resp := &issuanceNonces{
+		MinTimeSlackMS:      bc.DurationMillis(a.assets.MinTimeSlack()),
+		MaxIssuanceWindowMS: bc.DurationMillis(a.chain.MaxIssuanceWindow),
+		Nonces:              []*issueNonce{},
+	}
+	if b == nil {
+		return resp, nil
+	}
+	resp.Height = b.Height
+	resp.Count = len(snapshot.Nonces)
+
+	for id, expiryMS := range snapshot.Nonces {
+		resp.Nonces = append(resp.Nonces, &issueNonce{ID: id, ExpiresAt: time.Unix(0, int64(expiryMS)*int64(time.Millisecond)).UTC()})
+	}
+	sort.Slice(resp.Nonces, func(i, j int) bool {
+		return resp.Nonces[i].ExpiresAt.Before(resp.Nonces[j].ExpiresAt)
+	})
+	if limit := pageLimit(requestQuery{PageSize: in.PageSize}); len(resp.Nonces) > limit {
+		resp.Nonces = resp.Nonces[:limit]
+	}
+	return resp, nil
+}
@@ -0,0 +1,49 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chainmint/core/asset"
+	"github.com/chainmint/protocol"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/prottest/memstore"
+	"github.com/chainmint/protocol/state"
+)
+
+func TestGetIssuanceNonces(t *testing.T) {
+	ctx := context.Background()
+	c, err := protocol.NewChain(ctx, bc.Hash{}, memstore.New(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &API{chain: c, assets: asset.NewRegistry(nil, c, nil)}
+	IssuanceWindow(time.Minute, time.Hour)(a)
+
+	b, err := protocol.NewInitialBlock(nil, 0, bc.Millis(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot := state.Empty()
+	for i := 3; i > 0; i-- {
+		snapshot.Nonces[bc.NewHash([32]byte{byte(i)})] = uint64(i) * 1000
+	}
+	err = c.CommitAppliedBlock(ctx, b, snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := a.getIssuanceNonces(ctx, struct {
+		PageSize int `json:"page_size"`
+	}{2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Count != 3 || got.MinTimeSlackMS != 60000 || got.MaxIssuanceWindowMS != 3600000 {
+		t.Errorf("nonce set = %+v", got)
+	}
+	if len(got.Nonces) != 2 || got.Nonces[0].ID != bc.NewHash([32]byte{1}) || !got.Nonces[1].ExpiresAt.Equal(time.Unix(2, 0)) {
+		t.Errorf("nonces = %+v, want the 2 expiring soonest", got.Nonces)
+	}
+}
@@ -0,0 +1,281 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/log"
+	chainnet "github.com/chainmint/net"
+)
+
+// Scopes name the inter-node RPCs a peer may be allowed to call.
+const (
+	ScopeSubmit    = "submit"    // relay transactions to the generator
+	ScopeBlocks    = "blocks"    // fetch blocks and the block height
+	ScopeSnapshots = "snapshots" // download state snapshots
+	ScopeSign      = "sign"      // request block signatures
+)
+
+// peerScopes maps each inter-node RPC route to the scope needed to
+// call it.
+var peerScopes = map[string]string{
+	crosscoreRPCPrefix + "submit":            ScopeSubmit,
+	crosscoreRPCPrefix + "get-block":         ScopeBlocks,
+	crosscoreRPCPrefix + "block-height":      ScopeBlocks,
+	crosscoreRPCPrefix + "get-snapshot-info": ScopeSnapshots,
+	crosscoreRPCPrefix + "get-snapshot":      ScopeSnapshots,
+	crosscoreRPCPrefix + "signer/sign-block": ScopeSign,
+}
+
+var (
+	errPeerTLSRequired = errors.New("inter-node RPC requires mutual TLS")
+	errPeerUnknown     = errors.New("peer is not allowlisted")
+	errPeerScope       = errors.New("peer lacks the scope for this RPC")
+)
+
+// PeerIdentity is an entry in the peer allowlist. Name is matched
+// against the common name of the certificate a peer presents.
+type PeerIdentity struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// PeerTLSFiles names the files a PeerTLS is loaded from. Cert and
+// Key hold this node's PEM-encoded certificate and private key,
+// RootCAs the PEM-encoded CAs that issue peer certificates, and
+// Allowlist a JSON array of PeerIdentity.
+type PeerTLSFiles struct {
+	Cert      string
+	Key       string
+	RootCAs   string
+	Allowlist string
+}
+
+// PeerTLS holds the identity a node presents to its peers, and the
+// peers it accepts, for inter-node RPC over mutual TLS. Its files
+// may be replaced while the node runs; Reload picks up the changes,
+// so certificates can be rotated without a restart.
+type PeerTLS struct {
+	files PeerTLSFiles
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	roots     *x509.CertPool
+	allowlist map[string]map[string]bool // name -> scopes
+	modTimes  [4]time.Time
+}
+
+// LoadPeerTLS loads the peer TLS configuration in files.
+func LoadPeerTLS(files PeerTLSFiles) (*PeerTLS, error) {
+	if files.RootCAs == "" {
+		return nil, errors.WithDetail(ErrNoTLS, "peer TLS needs root CAs")
+	}
+	p := &PeerTLS{files: files}
+	_, err := p.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Reload re-reads the configuration if any of its files has changed
+// since it was last read, and reports whether it did. If the files
+// cannot be read, or do not form a valid configuration, it returns
+// an error and the configuration in use is kept.
+func (p *PeerTLS) Reload() (bool, error) {
+	var modTimes [4]time.Time
+	for i, name := range []string{p.files.Cert, p.files.Key, p.files.RootCAs, p.files.Allowlist} {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return false, errors.Wrap(err)
+		}
+		modTimes[i] = fi.ModTime()
+	}
+	p.mu.Lock()
+	unchanged := p.cert != nil && modTimes == p.modTimes
+	p.mu.Unlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(p.files.Cert, p.files.Key)
+	if err != nil {
+		return false, errors.Wrap(err, "loading peer certificate")
+	}
+	roots, err := loadRootCAs(p.files.RootCAs)
+	if err != nil {
+		return false, errors.Wrap(err, "loading peer root CAs")
+	}
+	allowlist, err := readAllowlist(p.files.Allowlist)
+	if err != nil {
+		return false, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cert, p.roots, p.allowlist, p.modTimes = &cert, roots, allowlist, modTimes
+	return true, nil
+}
+
+// Watch calls Reload every period until ctx is done.
+func (p *PeerTLS) Watch(ctx context.Context, period time.Duration) {
+	ticks := time.Tick(period)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticks:
+			changed, err := p.Reload()
+			if err != nil {
+				log.Error(ctx, err, "reloading peer TLS")
+			} else if changed {
+				coreLog.Infokv(ctx, log.KeyMessage, "reloaded peer TLS configuration")
+			}
+		}
+	}
+}
+
+func readAllowlist(name string) (map[string]map[string]bool, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading peer allowlist")
+	}
+	var peers []PeerIdentity
+	err = json.Unmarshal(b, &peers)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing peer allowlist")
+	}
+	valid := make(map[string]bool)
+	for _, scope := range peerScopes {
+		valid[scope] = true
+	}
+	allowlist := make(map[string]map[string]bool, len(peers))
+	for _, peer := range peers {
+		if peer.Name == "" {
+			return nil, errors.New("peer allowlist entry has no name")
+		}
+		scopes := make(map[string]bool, len(peer.Scopes))
+		for _, scope := range peer.Scopes {
+			if !valid[scope] {
+				return nil, fmt.Errorf("peer %s has unknown scope %q", peer.Name, scope)
+			}
+			scopes[scope] = true
+		}
+		allowlist[peer.Name] = scopes
+	}
+	return allowlist, nil
+}
+
+// ServerConfig returns a TLS config for serving inter-node RPC. It
+// requires clients to present a certificate issued by the root CAs,
+// and always uses the most recently loaded configuration.
+func (p *PeerTLS) ServerConfig() *tls.Config {
+	config := chainnet.DefaultTLSConfig()
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		c := chainnet.DefaultTLSConfig()
+		c.Certificates = []tls.Certificate{*p.cert}
+		c.ClientCAs = p.roots
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+		return c, nil
+	}
+	return config
+}
+
+// clientConfig returns a TLS config for calling the peer at host.
+func (p *PeerTLS) clientConfig(host string) *tls.Config {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c := chainnet.DefaultTLSConfig()
+	c.Certificates = []tls.Certificate{*p.cert}
+	c.RootCAs = p.roots
+	c.ServerName = host
+	return c
+}
+
+// Client returns an HTTP client for calling other nodes' inter-node
+// RPC. It presents this node's certificate, and verifies the peer's
+// against the root CAs, as loaded when each connection is made.
+func (p *PeerTLS) Client() *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			DialTLS: func(network, addr string) (net.Conn, error) {
+				host, _, err := net.SplitHostPort(addr)
+				if err != nil {
+					return nil, err
+				}
+				return tls.DialWithDialer(dialer, network, addr, p.clientConfig(host))
+			},
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+	}
+}
+
+// authorize checks that the peer making req is allowlisted with the
+// scope of the route it calls.
+func (p *PeerTLS) authorize(req *http.Request) error {
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 {
+		return errPeerTLSRequired
+	}
+	scope, ok := peerScopes[req.URL.Path]
+	if !ok {
+		return errNotFound
+	}
+	name := req.TLS.VerifiedChains[0][0].Subject.CommonName
+
+	p.mu.Lock()
+	scopes, ok := p.allowlist[name]
+	p.mu.Unlock()
+	if !ok {
+		return errors.WithDetailf(errPeerUnknown, "peer %q", name)
+	}
+	if !scopes[scope] {
+		return errors.WithDetailf(errPeerScope, "peer %q lacks scope %q", name, scope)
+	}
+	return nil
+}
+
+// handler serves requests with h if authorize allows them.
+func (p *PeerTLS) handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		err := p.authorize(req)
+		if err != nil {
+			errorFormatter.Write(req.Context(), rw, err)
+			return
+		}
+		h.ServeHTTP(rw, req)
+	})
+}
+
+// ServePeers serves inter-node RPC over mutual TLS on connections
+// accepted from ln, to the peers allowed by the UsePeerTLS option.
+// It returns when accepting fails.
+func (a *API) ServePeers(ln net.Listener) error {
+	if a.peerTLS == nil {
+		return errors.WithDetail(ErrNoTLS, "peer TLS is not configured")
+	}
+	srv := &http.Server{
+		Handler:      a.peerHandler,
+		TLSConfig:    a.peerTLS.ServerConfig(),
+		ReadTimeout:  2 * time.Minute,
+		WriteTimeout: time.Hour,
+	}
+	return srv.Serve(tls.NewListener(ln, srv.TLSConfig))
+}
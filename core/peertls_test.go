@@ -0,0 +1,189 @@
+package core
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chainmint/errors"
+)
+
+func TestPeerTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "peertls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca, caKey := testCert(t, "ca", nil, nil)
+	writePEM(t, filepath.Join(dir, "ca.crt"), "CERTIFICATE", ca.Raw)
+	files := func(name string) PeerTLSFiles {
+		cert, key := testCert(t, name, ca, caKey)
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f := PeerTLSFiles{
+			Cert:      filepath.Join(dir, name+".crt"),
+			Key:       filepath.Join(dir, name+".key"),
+			RootCAs:   filepath.Join(dir, "ca.crt"),
+			Allowlist: filepath.Join(dir, name+".json"),
+		}
+		writePEM(t, f.Cert, "CERTIFICATE", cert.Raw)
+		writePEM(t, f.Key, "EC PRIVATE KEY", keyDER)
+		writeFile(t, f.Allowlist, `[{"name": "node-b", "scopes": ["blocks"]}]`)
+		return f
+	}
+	aFiles, bFiles := files("node-a"), files("node-b")
+	a, err := LoadPeerTLS(aFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := LoadPeerTLS(bFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	srv := httptest.NewUnstartedServer(a.handler(ok))
+	srv.TLS = a.ServerConfig()
+	srv.StartTLS()
+	defer srv.Close()
+
+	call := func(path string) int {
+		resp, err := b.Client().Post(srv.URL+path, "application/json", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if got := call(crosscoreRPCPrefix + "get-block"); got != 200 {
+		t.Errorf("get-block status = %d want 200", got)
+	}
+	if got := call(crosscoreRPCPrefix + "get-snapshot"); got != 403 {
+		t.Errorf("get-snapshot without scope status = %d want 403", got)
+	}
+
+	// Rotating the allowlist takes effect on reload.
+	writeFile(t, aFiles.Allowlist, `[{"name": "node-c", "scopes": ["blocks"]}]`)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(aFiles.Allowlist, future, future)
+	changed, err := a.Reload()
+	if err != nil || !changed {
+		t.Fatalf("Reload() = %v, %v want true, nil", changed, err)
+	}
+	if got := call(crosscoreRPCPrefix + "get-block"); got != 403 {
+		t.Errorf("get-block after removal status = %d want 403", got)
+	}
+	changed, err = a.Reload()
+	if err != nil || changed {
+		t.Errorf("second Reload() = %v, %v want false, nil", changed, err)
+	}
+
+	// A bad file leaves the configuration in use.
+	writeFile(t, aFiles.Allowlist, `[{"name": "node-b", "scopes": ["everything"]}]`)
+	future = future.Add(time.Minute)
+	os.Chtimes(aFiles.Allowlist, future, future)
+	_, err = a.Reload()
+	if err == nil {
+		t.Error("Reload() with unknown scope succeeded")
+	}
+	if got := call(crosscoreRPCPrefix + "get-block"); got != 403 {
+		t.Errorf("get-block after bad reload status = %d want 403", got)
+	}
+
+	// Clients without a certificate are refused during the handshake.
+	resp, err := http.Post(srv.URL+crosscoreRPCPrefix+"get-block", "application/json", nil)
+	if err == nil {
+		resp.Body.Close()
+		t.Error("call without client certificate succeeded")
+	}
+}
+
+func TestPeerAuthorize(t *testing.T) {
+	p := &PeerTLS{allowlist: map[string]map[string]bool{
+		"node-b": {ScopeBlocks: true, ScopeSnapshots: true},
+	}}
+	peer := func(name string) *tls.ConnectionState {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: name}}
+		return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	}
+	cases := []struct {
+		path string
+		tls  *tls.ConnectionState
+		want error
+	}{
+		{crosscoreRPCPrefix + "get-snapshot", peer("node-b"), nil},
+		{crosscoreRPCPrefix + "block-height", peer("node-b"), nil},
+		{crosscoreRPCPrefix + "submit", peer("node-b"), errPeerScope},
+		{crosscoreRPCPrefix + "get-block", peer("node-c"), errPeerUnknown},
+		{crosscoreRPCPrefix + "get-block", nil, errPeerTLSRequired},
+		{crosscoreRPCPrefix + "nonexistent", peer("node-b"), errNotFound},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("POST", c.path, nil)
+		req.TLS = c.tls
+		got := p.authorize(req)
+		if errors.Root(got) != c.want {
+			t.Errorf("authorize(%s) = %v want %v", c.path, got, c.want)
+		}
+	}
+}
+
+// testCert returns a certificate for name, issued by parent, or
+// self-signed if parent is nil.
+func testCert(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func writePEM(t *testing.T, name, typ string, der []byte) {
+	writeFile(t, name, string(pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})))
+}
+
+func writeFile(t *testing.T, name, contents string) {
+	err := ioutil.WriteFile(name, []byte(contents), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -77,6 +77,19 @@ func (s *Store) Height(name string) uint64 {
 	return p.getHeight()
 }
 
+// CurrentHeight returns the height of the named pin and true, or
+// false if there is no such pin. Unlike Height, it does not wait
+// for the pin to be created.
+func (s *Store) CurrentHeight(name string) (uint64, bool) {
+	s.mu.Lock()
+	p, ok := s.pins[name]
+	s.mu.Unlock()
+	if !ok {
+		return 0, false
+	}
+	return p.getHeight(), true
+}
+
 func (s *Store) LoadAll(ctx context.Context) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -23,6 +23,12 @@ func TestCreatePin(t *testing.T) {
 	if h := store.Height("example"); h != 100 {
 		t.Errorf("pin height got %d, want %d", h, 100)
 	}
+	if h, ok := store.CurrentHeight("example"); !ok || h != 100 {
+		t.Errorf("CurrentHeight = %d, %v, want %d, true", h, ok, 100)
+	}
+	if _, ok := store.CurrentHeight("missing"); ok {
+		t.Error("CurrentHeight found a pin that was never created")
+	}
 
 	// Try to create the pin again but with a higher height.
 	// The pin should be unchanged.
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"context"
+	"expvar"
+	"time"
+
+	"github.com/chainmint/log"
+)
+
+var (
+	pruneBlocks    = expvar.NewInt("prune.blocks_deleted")
+	pruneSnapshots = expvar.NewInt("prune.snapshots_deleted")
+	pruneOutputs   = expvar.NewInt("prune.outputs_deleted")
+	pruneHeight    = expvar.NewInt("prune.below_height")
+)
+
+// RetentionPolicy describes which historical blocks, snapshots and
+// spent outputs a Core keeps.
+//
+// KeepLast is the number of most recent blocks kept. Zero keeps
+// every block: the Core is an archive node and nothing is pruned.
+// Below the most recent KeepLast blocks, the blocks and snapshots at
+// multiples of KeepEvery, if it is nonzero, are kept as checkpoints.
+// The initial block is always kept.
+type RetentionPolicy struct {
+	KeepLast  uint64
+	KeepEvery uint64
+}
+
+// Archive reports whether p keeps every block.
+func (p RetentionPolicy) Archive() bool {
+	return p.KeepLast == 0
+}
+
+// Pruning configures the Core to prune historical data according to
+// policy, every period. An archive policy or a zero period disables
+// pruning.
+func Pruning(policy RetentionPolicy, period time.Duration) RunOption {
+	return func(a *API) {
+		a.retention = policy
+		a.prunePeriod = period
+	}
+}
+
+// prune periodically deletes the blocks, snapshots and spent outputs
+// that a.retention no longer keeps.
+func (a *API) prune(ctx context.Context, period time.Duration) {
+	setHealth := a.healthSetter("pruner")
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			err := a.pruneOnce(ctx)
+			setHealth(err)
+			if err != nil {
+				log.Error(ctx, err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// pruneOnce prunes below the height chosen by pruneBelow.
+func (a *API) pruneOnce(ctx context.Context) error {
+	snapHeight, _, err := a.store.LatestSnapshotInfo(ctx)
+	if err != nil {
+		return err
+	}
+	var pinHeights []uint64
+	for _, name := range a.blockProcessorPins() {
+		pinHeights = append(pinHeights, a.pinStore.Height(name))
+	}
+	below := pruneBelow(a.retention, a.chain.Height(), snapHeight, pinHeights)
+	if below == 0 {
+		return nil
+	}
+
+	// Spent outputs are pruned up to the time of the oldest block
+	// kept, so the outputs and balances of every kept block can
+	// still be queried.
+	b, err := a.store.GetBlock(ctx, below)
+	if err != nil {
+		return err
+	}
+	n, err := a.store.PruneSnapshots(ctx, below, a.retention.KeepEvery)
+	if err != nil {
+		return err
+	}
+	pruneSnapshots.Add(n)
+	n, err = a.store.PruneBlocks(ctx, below, a.retention.KeepEvery)
+	if err != nil {
+		return err
+	}
+	pruneBlocks.Add(n)
+	if a.indexTxs {
+		n, err = a.indexer.PruneSpentOutputs(ctx, b.TimestampMS)
+		if err != nil {
+			return err
+		}
+		pruneOutputs.Add(n)
+	}
+	pruneHeight.Set(int64(below))
+	return nil
+}
+
+// pruneBelow returns the height below which policy allows data to be
+// pruned, given the chain height, the height of the latest snapshot
+// and the heights of the block processors, or 0 if nothing is to be
+// pruned. It never prunes the latest snapshot's block, from which the
+// state is recovered, or blocks a processor has yet to process.
+func pruneBelow(policy RetentionPolicy, height, snapHeight uint64, pinHeights []uint64) uint64 {
+	if policy.Archive() || height < policy.KeepLast {
+		return 0
+	}
+	below := height - policy.KeepLast + 1
+	if snapHeight < below {
+		below = snapHeight
+	}
+	for _, h := range pinHeights {
+		if h+1 < below {
+			below = h + 1
+		}
+	}
+	if below <= 2 {
+		return 0
+	}
+	return below
+}
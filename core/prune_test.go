@@ -0,0 +1,28 @@
+package core
+
+import "testing"
+
+func TestPruneBelow(t *testing.T) {
+	cases := []struct {
+		policy     RetentionPolicy
+		height     uint64
+		snapHeight uint64
+		pinHeights []uint64
+		want       uint64
+	}{
+		{RetentionPolicy{}, 1000, 1000, nil, 0},                               // archive
+		{RetentionPolicy{KeepLast: 100}, 50, 50, nil, 0},                      // fewer blocks than kept
+		{RetentionPolicy{KeepLast: 100}, 101, 101, nil, 0},                    // only the initial block to prune
+		{RetentionPolicy{KeepLast: 100}, 1000, 1000, nil, 901},                // keep the last 100
+		{RetentionPolicy{KeepLast: 100, KeepEvery: 50}, 1000, 1000, nil, 901}, // checkpoints kept by PruneBlocks
+		{RetentionPolicy{KeepLast: 100}, 1000, 800, nil, 800},                 // keep the latest snapshot's block
+		{RetentionPolicy{KeepLast: 100}, 1000, 1000, []uint64{999, 500}, 501}, // keep unprocessed blocks
+		{RetentionPolicy{KeepLast: 100}, 1000, 0, nil, 0},                     // no snapshot
+	}
+	for _, c := range cases {
+		got := pruneBelow(c.policy, c.height, c.snapHeight, c.pinHeights)
+		if got != c.want {
+			t.Errorf("pruneBelow(%+v, %d, %d, %v) = %d want %d", c.policy, c.height, c.snapHeight, c.pinHeights, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Package pubsub publishes chain events to subscribers, filtered by
+// event type and by the accounts, assets and control programs the
+// events concern.
+package pubsub
+
+import (
+	"expvar"
+	"sync"
+
+	"github.com/chainmint/errors"
+)
+
+// Event types.
+const (
+	TypeBlock           = "block"
+	TypeTransaction     = "transaction"
+	TypeValidatorUpdate = "validator_update"
+	TypeAnomaly         = "anomaly"
+)
+
+var ErrBadFilter = errors.New("invalid subscription filter")
+
+var (
+	subscribers = expvar.NewInt("pubsub.subscribers")
+	published   = expvar.NewInt("pubsub.published")
+	dropped     = expvar.NewInt("pubsub.dropped_subscribers")
+)
+
+// Event is a chain event delivered to subscribers.
+type Event struct {
+	Type   string      `json:"type"`
+	Height uint64      `json:"height"`
+	Data   interface{} `json:"data"`
+
+	// Topics are what the event concerns, for filtering.
+	Topics Topics `json:"-"`
+}
+
+// Topics are the accounts, assets and hex-encoded control programs
+// an event concerns.
+type Topics struct {
+	AccountIDs      []string
+	AssetIDs        []string
+	ControlPrograms []string
+}
+
+// Filter selects the events delivered to a subscription. Empty
+// Types selects every type. If any of the topic lists are set, only
+// events concerning one of their topics are delivered; events that
+// carry no topics, such as blocks, are not subject to them.
+type Filter struct {
+	Types           []string `json:"types"`
+	AccountIDs      []string `json:"account_ids"`
+	AssetIDs        []string `json:"asset_ids"`
+	ControlPrograms []string `json:"control_programs"`
+}
+
+// Validate checks that f names only known event types.
+func (f *Filter) Validate() error {
+	for _, t := range f.Types {
+		switch t {
+		case TypeBlock, TypeTransaction, TypeValidatorUpdate, TypeAnomaly:
+		default:
+			return errors.WithDetailf(ErrBadFilter, "unknown event type %q", t)
+		}
+	}
+	return nil
+}
+
+func (f *Filter) matches(e *Event) bool {
+	if len(f.Types) > 0 && !contains(f.Types, e.Type) {
+		return false
+	}
+	t := e.Topics
+	if len(t.AccountIDs)+len(t.AssetIDs)+len(t.ControlPrograms) == 0 {
+		return true
+	}
+	if len(f.AccountIDs)+len(f.AssetIDs)+len(f.ControlPrograms) == 0 {
+		return true
+	}
+	return intersects(f.AccountIDs, t.AccountIDs) ||
+		intersects(f.AssetIDs, t.AssetIDs) ||
+		intersects(f.ControlPrograms, t.ControlPrograms)
+}
+
+// Broker delivers published events to matching subscriptions.
+type Broker struct {
+	mu   sync.Mutex
+	subs map[*Subscription]bool
+}
+
+// NewBroker returns a Broker with no subscriptions.
+func NewBroker() *Broker {
+	return &Broker{subs: make(map[*Subscription]bool)}
+}
+
+// Subscription receives the events matching its filter on C. C is
+// closed when the subscription is closed, including by the broker
+// when more than the subscription's buffer of events are waiting to
+// be received.
+type Subscription struct {
+	C <-chan *Event
+
+	c      chan *Event
+	filter Filter
+	broker *Broker
+}
+
+// Subscribe returns a subscription to the events matching f,
+// buffering up to buffer events.
+func (b *Broker) Subscribe(f Filter, buffer int) *Subscription {
+	c := make(chan *Event, buffer)
+	s := &Subscription{C: c, c: c, filter: f, broker: b}
+	b.mu.Lock()
+	b.subs[s] = true
+	b.mu.Unlock()
+	subscribers.Add(1)
+	return s
+}
+
+// Publish delivers e to every matching subscription. It does not
+// block; subscriptions that have fallen behind are closed.
+func (b *Broker) Publish(e *Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	published.Add(1)
+	for s := range b.subs {
+		if !s.filter.matches(e) {
+			continue
+		}
+		select {
+		case s.c <- e:
+		default:
+			b.remove(s)
+			dropped.Add(1)
+		}
+	}
+}
+
+// Close ends the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.remove(s)
+}
+
+// remove closes s if it is subscribed. The caller must hold b.mu.
+func (b *Broker) remove(s *Subscription) {
+	if !b.subs[s] {
+		return
+	}
+	delete(b.subs, s)
+	close(s.c)
+	subscribers.Add(-1)
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func intersects(a, b []string) bool {
+	for _, x := range a {
+		if contains(b, x) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package pubsub
+
+import (
+	"testing"
+
+	"github.com/chainmint/errors"
+)
+
+func TestFilter(t *testing.T) {
+	block := &Event{Type: TypeBlock}
+	tx := &Event{Type: TypeTransaction, Topics: Topics{
+		AccountIDs:      []string{"acc1"},
+		AssetIDs:        []string{"gold"},
+		ControlPrograms: []string{"abcd"},
+	}}
+
+	cases := []struct {
+		filter    Filter
+		block, tx bool
+	}{
+		{Filter{}, true, true},
+		{Filter{Types: []string{TypeTransaction}}, false, true},
+		{Filter{AccountIDs: []string{"acc1"}}, true, true},
+		{Filter{AccountIDs: []string{"acc2"}}, true, false},
+		{Filter{AccountIDs: []string{"acc2"}, AssetIDs: []string{"gold"}}, true, true},
+		{Filter{ControlPrograms: []string{"abcd"}, Types: []string{TypeTransaction}}, false, true},
+	}
+	for i, c := range cases {
+		if got := c.filter.matches(block); got != c.block {
+			t.Errorf("case %d: matches(block) = %v, want %v", i, got, c.block)
+		}
+		if got := c.filter.matches(tx); got != c.tx {
+			t.Errorf("case %d: matches(tx) = %v, want %v", i, got, c.tx)
+		}
+	}
+
+	bad := Filter{Types: []string{"mempool"}}
+	if err := bad.Validate(); errors.Root(err) != ErrBadFilter {
+		t.Errorf("Validate(%v) = %v, want %v", bad.Types, err, ErrBadFilter)
+	}
+}
+
+func TestBroker(t *testing.T) {
+	b := NewBroker()
+	blocks := b.Subscribe(Filter{Types: []string{TypeBlock}}, 1)
+	all := b.Subscribe(Filter{}, 10)
+
+	b.Publish(&Event{Type: TypeBlock, Height: 1})
+	b.Publish(&Event{Type: TypeTransaction, Height: 1})
+	if e := <-blocks.C; e.Type != TypeBlock {
+		t.Errorf("blocks got %s event", e.Type)
+	}
+	if n := len(all.C); n != 2 {
+		t.Errorf("all has %d events, want 2", n)
+	}
+
+	// A subscriber that falls behind is dropped.
+	b.Publish(&Event{Type: TypeBlock, Height: 2})
+	b.Publish(&Event{Type: TypeBlock, Height: 3})
+	<-blocks.C
+	if _, ok := <-blocks.C; ok {
+		t.Error("lagging subscription is still open")
+	}
+	blocks.Close() // no-op
+
+	all.Close()
+	for range all.C {
+	}
+	b.Publish(&Event{Type: TypeBlock, Height: 4})
+}
@@ -3,6 +3,7 @@ package core
 import (
 	"context"
 	"math"
+	"time"
 
 	"github.com/chainmint/core/query"
 	"github.com/chainmint/core/query/filter"
@@ -10,15 +11,27 @@ import (
 	"github.com/chainmint/net/http/httpjson"
 )
 
+// pageLimit returns the number of items to return in one page of
+// results for in, capping page sizes requested by clients at
+// maxPageSize.
+func pageLimit(in requestQuery) int {
+	switch {
+	case in.PageSize <= 0:
+		return defGenericPageSize
+	case in.PageSize > maxPageSize:
+		return maxPageSize
+	}
+	return in.PageSize
+}
+
 // listAccounts is an http handler for listing accounts matching
 // an index or an ad-hoc filter.
 //
 // POST /list-accounts
 func (a *API) listAccounts(ctx context.Context, in requestQuery) (page, error) {
-	limit := in.PageSize
-	if limit == 0 {
-		limit = defGenericPageSize
-	}
+	defer a.slowQueries.record("list-accounts", in.Filter, time.Now())
+
+	limit := pageLimit(in)
 	after := in.After
 
 	// Use the filter engine for querying account tags.
@@ -42,10 +55,9 @@ func (a *API) listAccounts(ctx context.Context, in requestQuery) (page, error) {
 //
 // POST /list-assets
 func (a *API) listAssets(ctx context.Context, in requestQuery) (page, error) {
-	limit := in.PageSize
-	if limit == 0 {
-		limit = defGenericPageSize
-	}
+	defer a.slowQueries.record("list-assets", in.Filter, time.Now())
+
+	limit := pageLimit(in)
 	after := in.After
 
 	// Use the query engine for querying asset tags.
@@ -65,6 +77,8 @@ func (a *API) listAssets(ctx context.Context, in requestQuery) (page, error) {
 
 // POST /list-balances
 func (a *API) listBalances(ctx context.Context, in requestQuery) (result page, err error) {
+	defer a.slowQueries.record("list-balances", in.Filter, time.Now())
+
 	var sumBy []filter.Field
 
 	// Since an empty SumBy yields a meaningless result, we'll provide a
@@ -88,16 +102,29 @@ func (a *API) listBalances(ctx context.Context, in requestQuery) (result page, e
 		return result, errors.WithDetail(httpjson.ErrBadRequest, "timestamp is too large")
 	}
 
-	// TODO(jackson): paginate this endpoint.
-	balances, err := a.indexer.Balances(ctx, in.Filter, in.FilterParams, sumBy, timestampMS)
+	var after *query.BalancesAfter
+	if in.After != "" {
+		after, err = query.DecodeBalancesAfter(in.After)
+		if err != nil {
+			return result, errors.Wrap(err, "decoding `after`")
+		}
+	}
+
+	limit := pageLimit(in)
+	balances, nextAfter, err := a.indexer.Balances(ctx, in.Filter, in.FilterParams, sumBy, timestampMS, after, limit)
 	if err != nil {
 		return result, err
 	}
 
-	result.Items = httpjson.Array(balances)
-	result.LastPage = true
-	result.Next = in
-	return result, nil
+	out := in
+	if nextAfter != nil {
+		out.After = nextAfter.String()
+	}
+	return page{
+		Items:    httpjson.Array(balances),
+		LastPage: len(balances) < limit,
+		Next:     out,
+	}, nil
 }
 
 // listTransactions is an http handler for listing transactions matching
@@ -105,6 +132,8 @@ func (a *API) listBalances(ctx context.Context, in requestQuery) (result page, e
 //
 // POST /list-transactions
 func (a *API) listTransactions(ctx context.Context, in requestQuery) (result page, err error) {
+	defer a.slowQueries.record("list-transactions", in.Filter, time.Now())
+
 	var c context.CancelFunc
 	timeout := in.Timeout.Duration
 	if timeout != 0 {
@@ -112,10 +141,7 @@ func (a *API) listTransactions(ctx context.Context, in requestQuery) (result pag
 		defer c()
 	}
 
-	limit := in.PageSize
-	if limit == 0 {
-		limit = defGenericPageSize
-	}
+	limit := pageLimit(in)
 
 	endTimeMS := in.EndTimeMS
 	if endTimeMS == 0 {
@@ -138,7 +164,7 @@ func (a *API) listTransactions(ctx context.Context, in requestQuery) (result pag
 		}
 	}
 
-	txns, nextAfter, err := a.indexer.Transactions(ctx, in.Filter, in.FilterParams, after, limit, in.AscLongPoll)
+	txns, nextAfter, err := a.indexer.CategoryTransactions(ctx, in.Category, in.Filter, in.FilterParams, after, limit, in.AscLongPoll)
 	if err != nil {
 		return result, errors.Wrap(err, "running tx query")
 	}
@@ -156,10 +182,7 @@ func (a *API) listTransactions(ctx context.Context, in requestQuery) (result pag
 //
 // POST /list-transaction-feeds
 func (a *API) listTxFeeds(ctx context.Context, in requestQuery) (page, error) {
-	limit := in.PageSize
-	if limit == 0 {
-		limit = defGenericPageSize
-	}
+	limit := pageLimit(in)
 
 	after := in.After
 
@@ -179,10 +202,9 @@ func (a *API) listTxFeeds(ctx context.Context, in requestQuery) (page, error) {
 
 // POST /list-unspent-outputs
 func (a *API) listUnspentOutputs(ctx context.Context, in requestQuery) (result page, err error) {
-	limit := in.PageSize
-	if limit == 0 {
-		limit = defGenericPageSize
-	}
+	defer a.slowQueries.record("list-unspent-outputs", in.Filter, time.Now())
+
+	limit := pageLimit(in)
 
 	var after *query.OutputsAfter
 	if in.After != "" {
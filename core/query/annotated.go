@@ -8,6 +8,7 @@ import (
 
 	"github.com/chainmint/crypto/ed25519/chainkd"
 	chainjson "github.com/chainmint/encoding/json"
+	"github.com/chainmint/errors"
 	"github.com/chainmint/protocol/bc"
 	"github.com/chainmint/protocol/bc/legacy"
 	"github.com/chainmint/protocol/vmutil"
@@ -116,15 +117,17 @@ var emptyJSONObject = json.RawMessage(`{}`)
 
 func buildAnnotatedTransaction(orig *legacy.Tx, b *legacy.Block, indexInBlock uint32) *AnnotatedTx {
 	tx := &AnnotatedTx{
-		ID:                     orig.ID,
-		Timestamp:              b.Time(),
-		BlockID:                b.Hash(),
-		BlockHeight:            b.Height,
-		Position:               indexInBlock,
-		BlockTransactionsCount: uint32(len(b.Transactions)),
-		ReferenceData:          &emptyJSONObject,
-		Inputs:                 make([]*AnnotatedInput, 0, len(orig.Inputs)),
-		Outputs:                make([]*AnnotatedOutput, 0, len(orig.Outputs)),
+		ID:            orig.ID,
+		Position:      indexInBlock,
+		ReferenceData: &emptyJSONObject,
+		Inputs:        make([]*AnnotatedInput, 0, len(orig.Inputs)),
+		Outputs:       make([]*AnnotatedOutput, 0, len(orig.Outputs)),
+	}
+	if b != nil {
+		tx.Timestamp = b.Time()
+		tx.BlockID = b.Hash()
+		tx.BlockHeight = b.Height
+		tx.BlockTransactionsCount = uint32(len(b.Transactions))
 	}
 	if len(orig.ReferenceData) > 0 {
 		referenceData := json.RawMessage(orig.ReferenceData)
@@ -195,6 +198,21 @@ func buildAnnotatedOutput(tx *legacy.Tx, idx int) *AnnotatedOutput {
 	return out
 }
 
+// AnnotateTx annotates a single transaction that need not be in a
+// block, using the same annotators as the indexer. Block fields of
+// the result are left empty.
+func (ind *Indexer) AnnotateTx(ctx context.Context, tx *legacy.Tx) (*AnnotatedTx, error) {
+	annotated := []*AnnotatedTx{buildAnnotatedTransaction(tx, nil, 0)}
+	for _, annotator := range ind.annotators {
+		err := annotator(ctx, annotated)
+		if err != nil {
+			return nil, errors.Wrap(err, "adding external annotations")
+		}
+	}
+	localAnnotator(ctx, annotated)
+	return annotated[0], nil
+}
+
 // localAnnotator depends on the asset and account annotators and
 // must be run after them.
 func localAnnotator(ctx context.Context, txs []*AnnotatedTx) {
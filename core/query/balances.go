@@ -3,8 +3,11 @@ package query
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/lib/pq"
 
@@ -12,30 +15,68 @@ import (
 	"github.com/chainmint/errors"
 )
 
+// BalancesAfter is a cursor into the results of a balances query.
+// It holds the sum_by values of the last balance returned, in sum_by
+// order; a nil value stands for SQL NULL.
+type BalancesAfter struct {
+	groups []*string
+}
+
+func (cur BalancesAfter) String() string {
+	b, _ := json.Marshal(cur.groups)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func DecodeBalancesAfter(str string) (*BalancesAfter, error) {
+	b, err := base64.RawURLEncoding.DecodeString(str)
+	if err != nil {
+		return nil, errors.Sub(ErrBadAfter, err)
+	}
+	cur := new(BalancesAfter)
+	err = json.Unmarshal(b, &cur.groups)
+	if err != nil {
+		return nil, errors.Sub(ErrBadAfter, err)
+	}
+	if len(cur.groups) == 0 {
+		return nil, errors.Wrap(ErrBadAfter)
+	}
+	return cur, nil
+}
+
 // Balances performs a balances query against the annotated_outputs.
-func (ind *Indexer) Balances(ctx context.Context, filt string, vals []interface{}, sumBy []filter.Field, timestampMS uint64) ([]interface{}, error) {
+// If limit is positive and sumBy is not empty, it returns at most
+// limit balances, ordered by their sum_by values and starting after
+// the cursor after, along with the cursor for the next page. The
+// returned cursor is nil if there are no balances after after.
+func (ind *Indexer) Balances(ctx context.Context, filt string, vals []interface{}, sumBy []filter.Field, timestampMS uint64, after *BalancesAfter, limit int) ([]interface{}, *BalancesAfter, error) {
 	p, err := filter.Parse(filt, outputsTable, vals)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if len(vals) != p.Parameters {
-		return nil, ErrParameterCountMismatch
+		return nil, nil, ErrParameterCountMismatch
+	}
+	if after != nil && len(after.groups) != len(sumBy) {
+		return nil, nil, errors.WithDetail(ErrBadAfter, "cursor does not match sum_by")
 	}
 	expr, err := filter.AsSQL(p, outputsTable, vals)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	queryStr, queryArgs, err := constructBalancesQuery(expr, vals, sumBy, timestampMS)
+	queryStr, queryArgs, err := constructBalancesQuery(expr, vals, sumBy, timestampMS, after, limit)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	rows, err := ind.db.Query(ctx, queryStr, queryArgs...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
 
-	var balances []interface{}
+	var (
+		balances []interface{}
+		next     *BalancesAfter
+	)
 	for rows.Next() {
 		// balance and groupings will hold the output of the row scan
 		var balance uint64
@@ -47,12 +88,14 @@ func (ind *Indexer) Balances(ctx context.Context, filt string, vals []interface{
 		}
 		err := rows.Scan(scanArguments...)
 		if err != nil {
-			return nil, errors.Wrap(err, "scanning balance row")
+			return nil, nil, errors.Wrap(err, "scanning balance row")
 		}
 
+		next = &BalancesAfter{groups: make([]*string, 0, len(sumBy))}
 		sumByValues := map[string]interface{}{}
 		for i, f := range sumBy {
 			sumByValues[f.String()] = scanArguments[i+1]
+			next.groups = append(next.groups, *scanArguments[i+1].(**string))
 		}
 		// This struct enforces JSON field ordering in API output.
 		item := struct {
@@ -66,12 +109,20 @@ func (ind *Indexer) Balances(ctx context.Context, filt string, vals []interface{
 		}
 		balances = append(balances, item)
 	}
-	return balances, errors.Wrap(rows.Err())
+	if err := rows.Err(); err != nil {
+		return nil, nil, errors.Wrap(err)
+	}
+	return balances, next, nil
 }
 
-func constructBalancesQuery(expr string, vals []interface{}, sumBy []filter.Field, timestampMS uint64) (string, []interface{}, error) {
+func constructBalancesQuery(expr string, vals []interface{}, sumBy []filter.Field, timestampMS uint64, after *BalancesAfter, limit int) (string, []interface{}, error) {
 	var buf bytes.Buffer
 
+	// Pages are ordered by the sum_by values, NULLs first. Each value
+	// contributes two sort keys, so that NULL sorts apart from the
+	// empty string.
+	var sortKeys []string
+
 	buf.WriteString("SELECT COALESCE(SUM(amount), 0)")
 	for _, field := range sumBy {
 		fieldSQL, err := filter.FieldAsSQL(outputsTable, field)
@@ -81,6 +132,9 @@ func constructBalancesQuery(expr string, vals []interface{}, sumBy []filter.Fiel
 
 		buf.WriteString(", ")
 		buf.WriteString(fieldSQL)
+		sortKeys = append(sortKeys,
+			fmt.Sprintf("(%s) IS NOT NULL", fieldSQL),
+			fmt.Sprintf("COALESCE((%s)::text, '')", fieldSQL))
 	}
 	buf.WriteString(" FROM ")
 	buf.WriteString(pq.QuoteIdentifier("annotated_outputs"))
@@ -104,6 +158,26 @@ func constructBalancesQuery(expr string, vals []interface{}, sumBy []filter.Fiel
 			buf.WriteString(strconv.Itoa(i + 2)) // 1-indexed, skipping first col
 		}
 	}
-	// TODO(jackson): Support pagination.
+	if len(sumBy) == 0 || limit <= 0 {
+		return buf.String(), vals, nil
+	}
+
+	if after != nil {
+		buf.WriteString(" HAVING (" + strings.Join(sortKeys, ", ") + ") > (")
+		for i, g := range after.groups {
+			if i != 0 {
+				buf.WriteString(", ")
+			}
+			vals = append(vals, g != nil, "")
+			if g != nil {
+				vals[len(vals)-1] = *g
+			}
+			fmt.Fprintf(&buf, "$%d::boolean, $%d::text", len(vals)-1, len(vals))
+		}
+		buf.WriteString(")")
+	}
+	buf.WriteString(" ORDER BY " + strings.Join(sortKeys, ", "))
+	vals = append(vals, limit)
+	fmt.Fprintf(&buf, " LIMIT $%d", len(vals))
 	return buf.String(), vals, nil
 }
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/chainmint/core/query/filter"
+	"github.com/chainmint/errors"
 	"github.com/chainmint/testutil"
 )
 
@@ -13,6 +14,8 @@ func TestConstructBalancesQuery(t *testing.T) {
 		predicate  string
 		sumBy      []string
 		values     []interface{}
+		after      *BalancesAfter
+		limit      int
 		wantQuery  string
 		wantValues []interface{}
 	}{
@@ -42,6 +45,23 @@ func TestConstructBalancesQuery(t *testing.T) {
 			wantQuery:  `SELECT COALESCE(SUM(amount), 0), out."asset_tags"->>'currency' FROM "annotated_outputs" AS out WHERE (out."account_id" = $1) AND timespan @> $2::int8 GROUP BY 2`,
 			wantValues: []interface{}{`foo`, now},
 		},
+		{
+			predicate:  "account_id = $1",
+			sumBy:      []string{"asset_id"},
+			values:     []interface{}{"abc"},
+			limit:      10,
+			wantQuery:  `SELECT COALESCE(SUM(amount), 0), encode(out."asset_id", 'hex') FROM "annotated_outputs" AS out WHERE (out."account_id" = $1) AND timespan @> $2::int8 GROUP BY 2 ORDER BY (encode(out."asset_id", 'hex')) IS NOT NULL, COALESCE((encode(out."asset_id", 'hex'))::text, '') LIMIT $3`,
+			wantValues: []interface{}{`abc`, now, 10},
+		},
+		{
+			predicate:  "account_id = $1",
+			sumBy:      []string{"asset_alias", "asset_tags.currency"},
+			values:     []interface{}{"abc"},
+			after:      &BalancesAfter{groups: []*string{nil, strPtr("usd")}},
+			limit:      10,
+			wantQuery:  `SELECT COALESCE(SUM(amount), 0), out."asset_alias", out."asset_tags"->>'currency' FROM "annotated_outputs" AS out WHERE (out."account_id" = $1) AND timespan @> $2::int8 GROUP BY 2, 3 HAVING ((out."asset_alias") IS NOT NULL, COALESCE((out."asset_alias")::text, ''), (out."asset_tags"->>'currency') IS NOT NULL, COALESCE((out."asset_tags"->>'currency')::text, '')) > ($3::boolean, $4::text, $5::boolean, $6::text) ORDER BY (out."asset_alias") IS NOT NULL, COALESCE((out."asset_alias")::text, ''), (out."asset_tags"->>'currency') IS NOT NULL, COALESCE((out."asset_tags"->>'currency')::text, '') LIMIT $7`,
+			wantValues: []interface{}{`abc`, now, false, ``, true, `usd`, 10},
+		},
 	}
 
 	for i, tc := range testCases {
@@ -62,7 +82,7 @@ func TestConstructBalancesQuery(t *testing.T) {
 			fields = append(fields, f)
 		}
 
-		query, values, err := constructBalancesQuery(expr, tc.values, fields, now)
+		query, values, err := constructBalancesQuery(expr, tc.values, fields, now, tc.after, tc.limit)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -74,3 +94,29 @@ func TestConstructBalancesQuery(t *testing.T) {
 		}
 	}
 }
+
+func TestDecodeBalancesAfter(t *testing.T) {
+	cases := []*BalancesAfter{
+		{groups: []*string{strPtr("gold")}},
+		{groups: []*string{nil, strPtr("")}},
+		{groups: []*string{strPtr("a:b"), strPtr("\u00e9")}},
+	}
+	for _, cur := range cases {
+		decoded, err := DecodeBalancesAfter(cur.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !testutil.DeepEqual(decoded, cur) {
+			t.Errorf("got %#v, want %#v", decoded, cur)
+		}
+	}
+
+	for _, str := range []string{"", "not base64!", "bnVsbA", "W10"} {
+		_, err := DecodeBalancesAfter(str)
+		if errors.Root(err) != ErrBadAfter {
+			t.Errorf("DecodeBalancesAfter(%q) = %v, want %v", str, err, ErrBadAfter)
+		}
+	}
+}
+
+func strPtr(s string) *string { return &s }
@@ -0,0 +1,112 @@
+package query
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/chainmint/core/query/filter"
+	"github.com/chainmint/database/pg"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+)
+
+// AnnotatedBlock is a block with its annotated transactions, as
+// served to block explorers.
+type AnnotatedBlock struct {
+	ID                bc.Hash        `json:"id"`
+	Height            uint64         `json:"height"`
+	Timestamp         time.Time      `json:"timestamp"`
+	PreviousBlockID   bc.Hash        `json:"previous_block_id"`
+	TransactionsCount int            `json:"transactions_count"`
+	Transactions      []*AnnotatedTx `json:"transactions"`
+}
+
+// Block returns the block at height with its annotated
+// transactions. Transactions appear only once the block has been
+// indexed.
+func (ind *Indexer) Block(ctx context.Context, height uint64) (*AnnotatedBlock, error) {
+	if height == 0 || height > ind.c.Height() {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "no block at height %d", height)
+	}
+	b, err := ind.c.GetBlock(ctx, height)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading block")
+	}
+
+	const q = `
+		SELECT data FROM annotated_txs
+		WHERE block_height = $1
+		ORDER BY tx_pos
+	`
+	txs := make([]*AnnotatedTx, 0, len(b.Transactions))
+	err = pg.ForQueryRows(ctx, ind.db, q, height, func(data []byte) error {
+		tx := new(AnnotatedTx)
+		err := json.Unmarshal(data, tx)
+		if err != nil {
+			return errors.Wrap(err, "unmarshaling annotated transaction")
+		}
+		txs = append(txs, tx)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "annotated_txs select query")
+	}
+	return &AnnotatedBlock{
+		ID:                b.Hash(),
+		Height:            b.Height,
+		Timestamp:         b.Time(),
+		PreviousBlockID:   b.PreviousBlockHash,
+		TransactionsCount: len(b.Transactions),
+		Transactions:      txs,
+	}, nil
+}
+
+// BlockHeight returns the height of the block with the given ID.
+func (ind *Indexer) BlockHeight(ctx context.Context, id bc.Hash) (uint64, error) {
+	const q = `SELECT height FROM blocks WHERE block_hash = $1`
+	var height uint64
+	err := ind.db.QueryRow(ctx, q, id.Bytes()).Scan(&height)
+	if err == sql.ErrNoRows {
+		return 0, errors.WithDetailf(pg.ErrUserInputNotFound, "block id: %s", id.String())
+	}
+	return height, errors.Wrap(err, "blocks select query")
+}
+
+// Transaction returns the annotated transaction with the given ID.
+// Its inputs carry the asset, amount and account of the outputs
+// they spend.
+func (ind *Indexer) Transaction(ctx context.Context, id bc.Hash) (*AnnotatedTx, error) {
+	const q = `SELECT data FROM annotated_txs WHERE tx_hash = $1`
+	var data []byte
+	err := ind.db.QueryRow(ctx, q, id.Bytes()).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "transaction id: %s", id.String())
+	} else if err != nil {
+		return nil, errors.Wrap(err, "annotated_txs select query")
+	}
+	tx := new(AnnotatedTx)
+	err = json.Unmarshal(data, tx)
+	return tx, errors.Wrap(err, "unmarshaling annotated transaction")
+}
+
+// AccountBalances returns the balance of each asset held by the
+// account with the given ID or alias, as of timestampMS. A zero
+// timestamp means now.
+func (ind *Indexer) AccountBalances(ctx context.Context, account string, timestampMS uint64) ([]interface{}, error) {
+	if timestampMS == 0 {
+		timestampMS = math.MaxInt64
+	}
+	sumBy := []filter.Field{}
+	for _, s := range []string{"asset_alias", "asset_id"} {
+		f, err := filter.ParseField(s)
+		if err != nil {
+			return nil, err
+		}
+		sumBy = append(sumBy, f)
+	}
+	balances, _, err := ind.Balances(ctx, "account_id = $1 OR account_alias = $1", []interface{}{account}, sumBy, timestampMS, nil, 0)
+	return balances, err
+}
@@ -0,0 +1,55 @@
+package query
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chainmint/database/pg"
+	"github.com/chainmint/database/pg/pgtest"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/bctest"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/prottest"
+)
+
+func TestTransactionByID(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.NewTx(t)
+
+	c := prottest.NewChain(t)
+	indexer := NewIndexer(db, c, nil)
+	tx := bctest.NewIssuanceTx(t, prottest.Initial(t, c).Hash())
+	b := &legacy.Block{Transactions: []*legacy.Tx{tx}}
+	_, err := indexer.insertAnnotatedTxs(ctx, db, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := indexer.Transaction(ctx, tx.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != tx.ID || len(got.Inputs) != len(tx.Inputs) || len(got.Outputs) != len(tx.Outputs) {
+		t.Errorf("Transaction(%x) = %+v", tx.ID.Bytes(), got)
+	}
+
+	_, err = indexer.Transaction(ctx, bc.Hash{})
+	if errors.Root(err) != pg.ErrUserInputNotFound {
+		t.Errorf("Transaction(unknown) error = %v, want %v", err, pg.ErrUserInputNotFound)
+	}
+}
+
+func TestBlockOutOfRange(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.NewTx(t)
+
+	c := prottest.NewChain(t)
+	indexer := NewIndexer(db, c, nil)
+	for _, h := range []uint64{0, c.Height() + 1} {
+		_, err := indexer.Block(ctx, h)
+		if errors.Root(err) != pg.ErrUserInputNotFound {
+			t.Errorf("Block(%d) error = %v, want %v", h, err, pg.ErrUserInputNotFound)
+		}
+	}
+}
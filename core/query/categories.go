@@ -0,0 +1,210 @@
+package query
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/chainmint/core/query/filter"
+	"github.com/chainmint/database/pg"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+)
+
+var ErrBadCategoryRule = errors.New("invalid category rule")
+
+// CategoryRule tags the transactions it matches with a category as
+// they are indexed. A transaction matches if it satisfies Filter, a
+// transaction filter over assets, accounts and reference data, and,
+// when amount bounds are set, has a non-change output with an amount
+// within them. A zero MaxAmount means no upper bound. Rules apply to
+// blocks indexed after they are created.
+type CategoryRule struct {
+	ID           string        `json:"id"`
+	Category     string        `json:"category"`
+	Filter       string        `json:"filter"`
+	FilterParams []interface{} `json:"filter_params"`
+	MinAmount    uint64        `json:"min_amount,omitempty"`
+	MaxAmount    uint64        `json:"max_amount,omitempty"`
+}
+
+// CategoryTotal sums the non-change outputs of one asset over the
+// transactions in a category.
+type CategoryTotal struct {
+	Category         string     `json:"category"`
+	AssetID          bc.AssetID `json:"asset_id"`
+	AssetAlias       string     `json:"asset_alias,omitempty"`
+	TransactionCount uint64     `json:"transaction_count"`
+	Amount           uint64     `json:"amount"`
+}
+
+// CreateCategoryRule validates and saves a category rule, filling
+// in its ID.
+func (ind *Indexer) CreateCategoryRule(ctx context.Context, rule *CategoryRule) (*CategoryRule, error) {
+	if rule.Category == "" {
+		return nil, errors.WithDetail(ErrBadCategoryRule, "category is required")
+	}
+	if rule.MinAmount > math.MaxInt64 || rule.MaxAmount > math.MaxInt64 {
+		return nil, errors.WithDetail(ErrBadCategoryRule, "amount bound is too large")
+	}
+	if rule.MaxAmount != 0 && rule.MaxAmount < rule.MinAmount {
+		return nil, errors.WithDetail(ErrBadCategoryRule, "max_amount is less than min_amount")
+	}
+	_, _, err := categoryRuleSQL(rule)
+	if err != nil {
+		return nil, err
+	}
+	if rule.FilterParams == nil {
+		rule.FilterParams = []interface{}{}
+	}
+	params, err := json.Marshal(rule.FilterParams)
+	if err != nil {
+		return nil, errors.Wrap(err, "encoding filter params")
+	}
+
+	const q = `
+		INSERT INTO category_rules (category, filter, filter_params, min_amount, max_amount)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+	err = ind.db.QueryRow(ctx, q, rule.Category, rule.Filter, params, rule.MinAmount, rule.MaxAmount).Scan(&rule.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "category_rules insert query")
+	}
+	return rule, nil
+}
+
+// CategoryRules returns every category rule, oldest first.
+func (ind *Indexer) CategoryRules(ctx context.Context) ([]*CategoryRule, error) {
+	return categoryRules(ctx, ind.db)
+}
+
+// DeleteCategoryRule deletes a category rule. Transactions it has
+// already tagged keep their category.
+func (ind *Indexer) DeleteCategoryRule(ctx context.Context, id string) error {
+	const q = `DELETE FROM category_rules WHERE id = $1`
+	res, err := ind.db.Exec(ctx, q, id)
+	if err != nil {
+		return errors.Wrap(err, "category_rules delete query")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	if n == 0 {
+		return errors.WithDetailf(pg.ErrUserInputNotFound, "category rule id: %s", id)
+	}
+	return nil
+}
+
+// CategoryTotals sums the non-change outputs of the transactions in
+// each category, by asset, over the blocks with timestamps from
+// startMS through endMS. If category is not empty, only its totals
+// are returned.
+func (ind *Indexer) CategoryTotals(ctx context.Context, category string, startMS, endMS uint64) ([]*CategoryTotal, error) {
+	const q = `
+		SELECT cat.category, out.asset_id, out.asset_alias,
+			COUNT(DISTINCT cat.tx_hash), SUM(out.amount)::bigint
+		FROM annotated_tx_categories AS cat
+		JOIN query_blocks AS b ON b.height = cat.block_height
+		JOIN annotated_outputs AS out ON out.tx_hash = cat.tx_hash
+		WHERE ($1 = '' OR cat.category = $1)
+			AND b.timestamp >= $2 AND b.timestamp <= $3
+			AND out.purpose <> 'change'
+		GROUP BY cat.category, out.asset_id, out.asset_alias
+		ORDER BY cat.category, out.asset_id
+	`
+	var totals []*CategoryTotal
+	err := pg.ForQueryRows(ctx, ind.db, q, category, startMS, endMS, func(cat string, assetID bc.AssetID, alias string, n, amount uint64) {
+		totals = append(totals, &CategoryTotal{
+			Category:         cat,
+			AssetID:          assetID,
+			AssetAlias:       alias,
+			TransactionCount: n,
+			Amount:           amount,
+		})
+	})
+	return totals, errors.Wrap(err, "category totals query")
+}
+
+// categorize tags the transactions of the block at height with the
+// categories of the rules they match.
+func (ind *Indexer) categorize(ctx context.Context, db pg.DB, height uint64) error {
+	rules, err := categoryRules(ctx, db)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		cond, vals, err := categoryRuleSQL(rule)
+		if err != nil {
+			return errors.Wrapf(err, "category rule %s", rule.ID)
+		}
+		q := fmt.Sprintf(`
+			INSERT INTO annotated_tx_categories (block_height, tx_hash, category)
+			SELECT txs.block_height, txs.tx_hash, $%d FROM annotated_txs AS txs
+			WHERE txs.block_height = $%d AND %s
+			ON CONFLICT DO NOTHING
+		`, len(vals)+1, len(vals)+2, cond)
+		_, err = db.Exec(ctx, q, append(vals, rule.Category, height)...)
+		if err != nil {
+			return errors.Wrapf(err, "applying category rule %s", rule.ID)
+		}
+	}
+	return nil
+}
+
+func categoryRules(ctx context.Context, db pg.DB) ([]*CategoryRule, error) {
+	const q = `
+		SELECT id, category, filter, filter_params, min_amount, max_amount
+		FROM category_rules ORDER BY id
+	`
+	var rules []*CategoryRule
+	err := pg.ForQueryRows(ctx, db, q, func(id, category, filt string, params []byte, min, max uint64) error {
+		rule := &CategoryRule{ID: id, Category: category, Filter: filt, MinAmount: min, MaxAmount: max}
+		err := json.Unmarshal(params, &rule.FilterParams)
+		if err != nil {
+			return errors.Wrap(err, "decoding filter params")
+		}
+		rules = append(rules, rule)
+		return nil
+	})
+	return rules, errors.Wrap(err, "category_rules select query")
+}
+
+// categoryRuleSQL returns the condition on annotated_txs AS txs
+// under which rule matches a transaction, and its arguments,
+// numbered from $1.
+func categoryRuleSQL(rule *CategoryRule) (string, []interface{}, error) {
+	vals := append([]interface{}(nil), rule.FilterParams...)
+	p, err := filter.Parse(rule.Filter, transactionsTable, vals)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(vals) != p.Parameters {
+		return "", nil, ErrParameterCountMismatch
+	}
+	expr, err := filter.AsSQL(p, transactionsTable, vals)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "converting to SQL")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("TRUE")
+	if len(expr) > 0 {
+		buf.WriteString(" AND (" + expr + ")")
+	}
+	if rule.MinAmount > 0 || rule.MaxAmount > 0 {
+		buf.WriteString(" AND EXISTS (SELECT 1 FROM annotated_outputs AS out")
+		buf.WriteString(" WHERE out.tx_hash = txs.tx_hash AND out.purpose <> 'change'")
+		fmt.Fprintf(&buf, " AND out.amount >= $%d", len(vals)+1)
+		vals = append(vals, rule.MinAmount)
+		if rule.MaxAmount > 0 {
+			fmt.Fprintf(&buf, " AND out.amount <= $%d", len(vals)+1)
+			vals = append(vals, rule.MaxAmount)
+		}
+		buf.WriteString(")")
+	}
+	return buf.String(), vals, nil
+}
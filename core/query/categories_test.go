@@ -0,0 +1,41 @@
+package query
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCategoryRuleSQL(t *testing.T) {
+	cases := []struct {
+		rule     CategoryRule
+		wantCond string
+		wantVals []interface{}
+	}{
+		{
+			rule:     CategoryRule{Filter: ""},
+			wantCond: "TRUE",
+		},
+		{
+			rule:     CategoryRule{Filter: "reference_data.type = $1", FilterParams: []interface{}{"payroll"}},
+			wantCond: `TRUE AND ((txs."reference_data"->>'type') = $1)`,
+			wantVals: []interface{}{"payroll"},
+		},
+		{
+			rule:     CategoryRule{MinAmount: 100, MaxAmount: 500},
+			wantCond: "TRUE AND EXISTS (SELECT 1 FROM annotated_outputs AS out WHERE out.tx_hash = txs.tx_hash AND out.purpose <> 'change' AND out.amount >= $1 AND out.amount <= $2)",
+			wantVals: []interface{}{uint64(100), uint64(500)},
+		},
+	}
+	for _, c := range cases {
+		cond, vals, err := categoryRuleSQL(&c.rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cond != c.wantCond {
+			t.Errorf("categoryRuleSQL(%+v) cond = %q, want %q", c.rule, cond, c.wantCond)
+		}
+		if len(vals) != len(c.wantVals) || (len(vals) > 0 && !reflect.DeepEqual(vals, c.wantVals)) {
+			t.Errorf("categoryRuleSQL(%+v) vals = %v, want %v", c.rule, vals, c.wantVals)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package query
+
+import (
+	"context"
+	"expvar"
+	"strconv"
+
+	"github.com/chainmint/database/pg"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/log"
+	"github.com/chainmint/protocol/bc"
+)
+
+var flaggedTxs = expvar.NewInt("query.flagged_txs")
+
+// TxFlag marks an indexed transaction for review, for example
+// because a Detector found it unusual for one of its accounts.
+type TxFlag struct {
+	ID            string  `json:"id"`
+	TransactionID bc.Hash `json:"transaction_id"`
+	BlockHeight   uint64  `json:"block_height"`
+	AccountID     string  `json:"account_id,omitempty"`
+	Reason        string  `json:"reason"`
+	Detail        string  `json:"detail,omitempty"`
+}
+
+// Detector inspects the transactions of each block as it is
+// indexed and returns flags for the ones that need review. It
+// reads past activity through db, which includes the block's own
+// transactions, inputs and outputs. An error stops indexing of
+// the block, to be retried.
+type Detector func(ctx context.Context, db pg.DB, txs []*AnnotatedTx) ([]*TxFlag, error)
+
+// RegisterDetector adds a detector to run on the transactions of
+// each indexed block.
+func (ind *Indexer) RegisterDetector(detector Detector) {
+	ind.detectors = append(ind.detectors, detector)
+}
+
+// detect runs the indexer's detectors on the transactions of a
+// block and saves the flags they return.
+func (ind *Indexer) detect(ctx context.Context, db pg.DB, txs []*AnnotatedTx) error {
+	for _, detector := range ind.detectors {
+		flags, err := detector(ctx, db, txs)
+		if err != nil {
+			return errors.Wrap(err, "detecting anomalies")
+		}
+		for _, f := range flags {
+			err = insertTxFlag(ctx, db, f)
+			if err != nil {
+				return err
+			}
+			log.Printkv(ctx, "at", "flagged transaction", "tx", f.TransactionID.HexString(),
+				"account", f.AccountID, "reason", f.Reason, "detail", f.Detail)
+			flaggedTxs.Add(1)
+		}
+	}
+	return nil
+}
+
+func insertTxFlag(ctx context.Context, db pg.DB, f *TxFlag) error {
+	const q = `
+		INSERT INTO annotated_tx_flags (tx_hash, block_height, account_id, reason, detail)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (tx_hash, account_id, reason) DO NOTHING
+	`
+	_, err := db.Exec(ctx, q, f.TransactionID, f.BlockHeight, f.AccountID, f.Reason, f.Detail)
+	return errors.Wrap(err, "annotated_tx_flags insert query")
+}
+
+// TxFlags returns up to limit flags, oldest first, starting after
+// the flag with ID after. If accountID is not empty, only the
+// flags for that account are returned. The second return value
+// is the ID to pass as after for the next page.
+func (ind *Indexer) TxFlags(ctx context.Context, accountID, after string, limit int) ([]*TxFlag, string, error) {
+	var afterID int64
+	if after != "" {
+		var err error
+		afterID, err = strconv.ParseInt(after, 10, 64)
+		if err != nil {
+			return nil, "", errors.WithDetailf(ErrBadAfter, "decoding after: %s", err)
+		}
+	}
+	const q = `
+		SELECT id, tx_hash, block_height, account_id, reason, detail
+		FROM annotated_tx_flags
+		WHERE id > $1 AND ($2 = '' OR account_id = $2)
+		ORDER BY id
+		LIMIT $3
+	`
+	flags, err := queryTxFlags(ctx, ind.db, q, afterID, accountID, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	next := after
+	if len(flags) > 0 {
+		next = flags[len(flags)-1].ID
+	}
+	return flags, next, nil
+}
+
+// TxFlagsAt returns the flags raised on the transactions of the
+// block at height.
+func (ind *Indexer) TxFlagsAt(ctx context.Context, height uint64) ([]*TxFlag, error) {
+	const q = `
+		SELECT id, tx_hash, block_height, account_id, reason, detail
+		FROM annotated_tx_flags
+		WHERE block_height = $1
+		ORDER BY id
+	`
+	return queryTxFlags(ctx, ind.db, q, height)
+}
+
+func queryTxFlags(ctx context.Context, db pg.DB, q string, args ...interface{}) ([]*TxFlag, error) {
+	var flags []*TxFlag
+	args = append(args, func(id int64, txHash bc.Hash, height uint64, accountID, reason, detail string) {
+		flags = append(flags, &TxFlag{
+			ID:            strconv.FormatInt(id, 10),
+			TransactionID: txHash,
+			BlockHeight:   height,
+			AccountID:     accountID,
+			Reason:        reason,
+			Detail:        detail,
+		})
+	})
+	err := pg.ForQueryRows(ctx, db, q, args...)
+	return flags, errors.Wrap(err, "annotated_tx_flags select query")
+}
@@ -9,7 +9,9 @@ import (
 
 	"github.com/chainmint/core/pin"
 	"github.com/chainmint/database/pg"
+	chainsql "github.com/chainmint/database/sql"
 	"github.com/chainmint/errors"
+	"github.com/chainmint/log"
 	"github.com/chainmint/protocol"
 	"github.com/chainmint/protocol/bc/legacy"
 )
@@ -36,6 +38,7 @@ type Indexer struct {
 	c          *protocol.Chain
 	pinStore   *pin.Store
 	annotators []Annotator
+	detectors  []Detector
 }
 
 // Annotator describes a function capable of adding annotations
@@ -62,32 +65,140 @@ func (ind *Indexer) IndexTransactions(ctx context.Context, b *legacy.Block) erro
 	<-ind.pinStore.PinWaiter("account", b.Height)
 	<-ind.pinStore.PinWaiter(TxPinName, b.Height-1)
 
-	err := ind.insertBlock(ctx, b)
+	return ind.indexBlock(ctx, b)
+}
+
+// indexBlock saves the block's annotated transactions, inputs and
+// outputs, tags the transactions with their categories, flags the
+// ones the indexer's detectors find unusual, and advances the
+// indexed-through height of the tx pin. When the indexer's database
+// supports it, all of this happens in a single database transaction,
+// so a crash can never leave a partially indexed block behind.
+func (ind *Indexer) indexBlock(ctx context.Context, b *legacy.Block) (err error) {
+	db := ind.db
+	if beginner, ok := db.(interface {
+		Begin(context.Context) (*chainsql.Tx, error)
+	}); ok {
+		var dbtx *chainsql.Tx
+		dbtx, err = beginner.Begin(ctx)
+		if err != nil {
+			return errors.Wrap(err, "begin index transaction")
+		}
+		defer func() {
+			if err != nil {
+				dbtx.Rollback(ctx)
+				return
+			}
+			err = errors.Wrap(dbtx.Commit(ctx), "commit index transaction")
+		}()
+		db = dbtx
+	}
+
+	txs, err := ind.insertAnnotatedTxs(ctx, db, b)
+	if err != nil {
+		return err
+	}
+	err = ind.insertAnnotatedOutputs(ctx, db, b, txs)
+	if err != nil {
+		return err
+	}
+	err = ind.insertAnnotatedInputs(ctx, db, b, txs)
+	if err != nil {
+		return err
+	}
+	err = ind.categorize(ctx, db, b.Height)
+	if err != nil {
+		return err
+	}
+	err = ind.detect(ctx, db, txs)
 	if err != nil {
 		return err
 	}
-	txs, err := ind.insertAnnotatedTxs(ctx, b)
+	// The block is inserted last: its presence in query_blocks
+	// means the block has been fully indexed.
+	err = ind.insertBlock(ctx, db, b)
 	if err != nil {
 		return err
 	}
-	err = ind.insertAnnotatedOutputs(ctx, b, txs)
+	return setIndexedHeight(ctx, db, b.Height)
+}
+
+// setIndexedHeight records that every block up to and including
+// height has been indexed.
+func setIndexedHeight(ctx context.Context, db pg.DB, height uint64) error {
+	const q = `UPDATE block_processors SET height=$1 WHERE height<$1 AND name=$2`
+	_, err := db.Exec(ctx, q, height, TxPinName)
+	return errors.Wrap(err, "recording indexed height")
+}
+
+// Recover repairs the transaction index after an unclean shutdown.
+// It discards any index data written for blocks above the recorded
+// indexed-through height, which is the tx pin's height. The tx pin
+// then replays the blocks from that height once it starts, indexing
+// each only after the asset and account pins have processed it, as
+// the annotations come from their indexes; see IndexTransactions.
+//
+// Recover must be called before the tx pin starts processing
+// blocks.
+func (ind *Indexer) Recover(ctx context.Context) error {
+	const heightQ = `SELECT height FROM block_processors WHERE name = $1`
+	var indexed uint64
+	err := ind.db.QueryRow(ctx, heightQ, TxPinName).Scan(&indexed)
+	if err == sql.ErrNoRows {
+		return nil // nothing indexed yet
+	} else if err != nil {
+		return errors.Wrap(err, "looking up indexed height")
+	}
+
+	err = ind.discardAbove(ctx, indexed)
 	if err != nil {
 		return err
 	}
-	err = ind.insertAnnotatedInputs(ctx, b, txs)
-	return err
+
+	height := ind.c.Height()
+	if height > indexed {
+		log.Printkv(ctx, "at", "tx index resumes", "from", indexed+1, "to", height)
+	}
+	return nil
+}
+
+// discardAbove removes index data belonging to blocks above height,
+// and reopens the timespans of outputs spent in those blocks.
+func (ind *Indexer) discardAbove(ctx context.Context, height uint64) error {
+	deletes := []string{
+		`DELETE FROM annotated_inputs WHERE tx_hash IN
+			(SELECT tx_hash FROM annotated_txs WHERE block_height > $1)`,
+		`DELETE FROM annotated_tx_categories WHERE block_height > $1`,
+		`DELETE FROM annotated_tx_flags WHERE block_height > $1`,
+		`DELETE FROM annotated_txs WHERE block_height > $1`,
+		`DELETE FROM annotated_outputs WHERE block_height > $1`,
+		`DELETE FROM query_blocks WHERE height > $1`,
+	}
+	const reopenQ = `
+		UPDATE annotated_outputs SET timespan = INT8RANGE(LOWER(timespan), NULL)
+		WHERE type <> 'retire' AND UPPER(timespan) >
+			(SELECT COALESCE(MAX(timestamp), 0) FROM query_blocks)
+	`
+	for _, q := range deletes {
+		_, err := ind.db.Exec(ctx, q, height)
+		if err != nil {
+			return errors.Wrap(err, "discarding torn index data")
+		}
+	}
+	_, err := ind.db.Exec(ctx, reopenQ)
+	return errors.Wrap(err, "reopening spent output timespans")
 }
 
-func (ind *Indexer) insertBlock(ctx context.Context, b *legacy.Block) error {
+func (ind *Indexer) insertBlock(ctx context.Context, db pg.DB, b *legacy.Block) error {
 	const q = `
 		INSERT INTO query_blocks (height, timestamp) VALUES($1, $2)
 		ON CONFLICT (height) DO NOTHING
 	`
-	_, err := ind.db.Exec(ctx, q, b.Height, b.TimestampMS)
+	_, err := db.Exec(ctx, q, b.Height, b.TimestampMS)
 	return errors.Wrap(err, "inserting block timestamp")
 }
 
-func (ind *Indexer) insertAnnotatedTxs(ctx context.Context, b *legacy.Block) ([]*AnnotatedTx, error) {
+func (ind *Indexer) insertAnnotatedTxs(ctx context.Context, db pg.DB, b *legacy.Block) ([]*AnnotatedTx, error) {
 	var (
 		hashes           = pq.ByteaArray(make([][]byte, 0, len(b.Transactions)))
 		positions        = make([]uint32, 0, len(b.Transactions))
@@ -130,7 +241,7 @@ func (ind *Indexer) insertAnnotatedTxs(ctx context.Context, b *legacy.Block) ([]
 			unnest($6::jsonb[]), unnest($7::boolean[]), unnest($8::jsonb[]), $9
 		ON CONFLICT (block_height, tx_pos) DO NOTHING;
 	`
-	_, err := ind.db.Exec(ctx, insertQ, b.Height, b.Hash(), b.Time(),
+	_, err := db.Exec(ctx, insertQ, b.Height, b.Hash(), b.Time(),
 		pq.Array(positions), hashes, annotatedTxBlobs, locals,
 		referenceDatas, len(b.Transactions))
 	if err != nil {
@@ -139,7 +250,7 @@ func (ind *Indexer) insertAnnotatedTxs(ctx context.Context, b *legacy.Block) ([]
 	return annotatedTxs, nil
 }
 
-func (ind *Indexer) insertAnnotatedInputs(ctx context.Context, b *legacy.Block, annotatedTxs []*AnnotatedTx) error {
+func (ind *Indexer) insertAnnotatedInputs(ctx context.Context, db pg.DB, b *legacy.Block, annotatedTxs []*AnnotatedTx) error {
 	var (
 		inputTxHashes         pq.ByteaArray
 		inputIndexes          pq.Int64Array
@@ -198,14 +309,14 @@ func (ind *Indexer) insertAnnotatedInputs(ctx context.Context, b *legacy.Block,
 		unnest($13::bytea[]), unnest($14::jsonb[]), unnest($15::boolean[]), unnest($16::bytea[])
 		ON CONFLICT (tx_hash, index) DO NOTHING;
 	`
-	_, err := ind.db.Exec(ctx, insertQ, inputTxHashes, inputIndexes, inputTypes, inputAssetIDs,
+	_, err := db.Exec(ctx, insertQ, inputTxHashes, inputIndexes, inputTypes, inputAssetIDs,
 		inputAssetAliases, inputAssetDefinitions, pq.Array(inputAssetTags), inputAssetLocals,
 		inputAmounts, pq.Array(inputAccountIDs), pq.Array(inputAccountAliases), pq.Array(inputAccountTags),
 		inputIssuancePrograms, inputReferenceDatas, inputLocals, inputSpentOutputIDs)
 	return errors.Wrap(err, "batch inserting annotated inputs")
 }
 
-func (ind *Indexer) insertAnnotatedOutputs(ctx context.Context, b *legacy.Block, annotatedTxs []*AnnotatedTx) error {
+func (ind *Indexer) insertAnnotatedOutputs(ctx context.Context, db pg.DB, b *legacy.Block, annotatedTxs []*AnnotatedTx) error {
 	var (
 		outputIDs              pq.ByteaArray
 		outputTxPositions      []uint32
@@ -282,7 +393,7 @@ func (ind *Indexer) insertAnnotatedOutputs(ctx context.Context, b *legacy.Block,
 		FROM utxos
 		ON CONFLICT (block_height, tx_pos, output_index) DO NOTHING;
 	`
-	_, err := ind.db.Exec(ctx, insertQ, b.Height, pq.Array(outputTxPositions),
+	_, err := db.Exec(ctx, insertQ, b.Height, pq.Array(outputTxPositions),
 		pq.Array(outputIndexes), outputTxHashes, b.TimestampMS, outputIDs, outputTypes,
 		outputPurposes, outputAssetIDs, outputAssetAliases,
 		outputAssetDefinitions, outputAssetTags, outputAssetLocals,
@@ -297,6 +408,6 @@ func (ind *Indexer) insertAnnotatedOutputs(ctx context.Context, b *legacy.Block,
 		UPDATE annotated_outputs SET timespan = INT8RANGE(LOWER(timespan), $1)
 		WHERE (output_id) IN (SELECT unnest($2::bytea[]))
 	`
-	_, err = ind.db.Exec(ctx, updateQ, b.TimestampMS, prevoutIDs)
+	_, err = db.Exec(ctx, updateQ, b.TimestampMS, prevoutIDs)
 	return errors.Wrap(err, "updating spent annotated outputs")
 }
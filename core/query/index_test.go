@@ -22,7 +22,7 @@ func TestAnnotatedTxs(t *testing.T) {
 			bctest.NewIssuanceTx(t, prottest.Initial(t, c).Hash()),
 		},
 	}
-	txs, err := indexer.insertAnnotatedTxs(ctx, b)
+	txs, err := indexer.insertAnnotatedTxs(ctx, db, b)
 	if err != nil {
 		t.Error(err)
 	}
@@ -30,3 +30,38 @@ func TestAnnotatedTxs(t *testing.T) {
 		t.Errorf("Got %d transactions, expected %d", len(txs), len(b.Transactions))
 	}
 }
+
+func TestRecoverDiscardsTornBlocks(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.NewTx(t)
+
+	c := prottest.NewChain(t)
+	indexer := NewIndexer(db, c, nil)
+	pgtest.Exec(ctx, db, t, `INSERT INTO block_processors (name, height) VALUES ($1, 0)`, TxPinName)
+
+	// Simulate a crash after the transactions of a block beyond the
+	// chain height were written, but before the block completed.
+	b := &legacy.Block{
+		BlockHeader: legacy.BlockHeader{Height: c.Height() + 1},
+		Transactions: []*legacy.Tx{
+			bctest.NewIssuanceTx(t, prottest.Initial(t, c).Hash()),
+		},
+	}
+	_, err := indexer.insertAnnotatedTxs(ctx, db, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = indexer.discardAbove(ctx, c.Height())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	err = db.QueryRow(ctx, `SELECT COUNT(*) FROM annotated_txs WHERE block_height > $1`, c.Height()).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("got %d torn transactions after recovery, want 0", n)
+	}
+}
@@ -73,47 +73,9 @@ func (ind *Indexer) Outputs(ctx context.Context, filt string, vals []interface{}
 
 	outputs := make([]*AnnotatedOutput, 0, limit)
 	for rows.Next() {
-		var (
-			blockHeight  uint64
-			txPos        uint32
-			txID         = new(bc.Hash)
-			accountID    *string
-			accountAlias *string
-			out          = new(AnnotatedOutput)
-		)
-		err = rows.Scan(
-			&blockHeight,
-			&txPos,
-			&out.Position,
-			txID,
-			&out.OutputID,
-			&out.Type,
-			&out.Purpose,
-			&out.AssetID,
-			&out.AssetAlias,
-			&out.AssetDefinition,
-			&out.AssetTags,
-			&out.AssetIsLocal,
-			&out.Amount,
-			&accountID,
-			&accountAlias,
-			&out.AccountTags,
-			&out.ControlProgram,
-			&out.ReferenceData,
-			&out.IsLocal,
-		)
+		out, blockHeight, txPos, err := scanAnnotatedOutput(rows)
 		if err != nil {
-			return nil, nil, errors.Wrap(err, "scanning annotated output")
-		}
-
-		out.TransactionID = txID
-
-		// Set nullable fields.
-		if accountID != nil {
-			out.AccountID = *accountID
-		}
-		if accountAlias != nil {
-			out.AccountAlias = *accountAlias
+			return nil, nil, err
 		}
 
 		outputs = append(outputs, out)
@@ -130,6 +92,86 @@ func (ind *Indexer) Outputs(ctx context.Context, filt string, vals []interface{}
 	return outputs, &newAfter, nil
 }
 
+// OutputsByID returns the annotated outputs with the given IDs,
+// whether or not they have been spent. IDs that are not indexed are
+// missing from the result.
+func (ind *Indexer) OutputsByID(ctx context.Context, ids []bc.Hash) (map[bc.Hash]*AnnotatedOutput, error) {
+	idBytes := make(pq.ByteaArray, 0, len(ids))
+	for _, id := range ids {
+		idBytes = append(idBytes, id.Bytes())
+	}
+	const q = `
+		SELECT block_height, tx_pos, output_index, tx_hash, output_id, type, purpose,
+			asset_id, asset_alias, asset_definition, asset_tags, asset_local,
+			amount, account_id, account_alias, account_tags, control_program,
+			reference_data, local
+		FROM annotated_outputs WHERE output_id = ANY($1)
+	`
+	rows, err := ind.db.Query(ctx, q, idBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying outputs by id")
+	}
+	defer rows.Close()
+
+	outputs := make(map[bc.Hash]*AnnotatedOutput, len(ids))
+	for rows.Next() {
+		out, _, _, err := scanAnnotatedOutput(rows)
+		if err != nil {
+			return nil, err
+		}
+		outputs[out.OutputID] = out
+	}
+	return outputs, errors.Wrap(rows.Err())
+}
+
+// scanAnnotatedOutput scans a row of annotated_outputs, with the
+// columns selected in the order used by constructOutputsQuery.
+func scanAnnotatedOutput(rows interface {
+	Scan(...interface{}) error
+}) (out *AnnotatedOutput, blockHeight uint64, txPos uint32, err error) {
+	var (
+		txID         = new(bc.Hash)
+		accountID    *string
+		accountAlias *string
+	)
+	out = new(AnnotatedOutput)
+	err = rows.Scan(
+		&blockHeight,
+		&txPos,
+		&out.Position,
+		txID,
+		&out.OutputID,
+		&out.Type,
+		&out.Purpose,
+		&out.AssetID,
+		&out.AssetAlias,
+		&out.AssetDefinition,
+		&out.AssetTags,
+		&out.AssetIsLocal,
+		&out.Amount,
+		&accountID,
+		&accountAlias,
+		&out.AccountTags,
+		&out.ControlProgram,
+		&out.ReferenceData,
+		&out.IsLocal,
+	)
+	if err != nil {
+		return nil, 0, 0, errors.Wrap(err, "scanning annotated output")
+	}
+
+	out.TransactionID = txID
+
+	// Set nullable fields.
+	if accountID != nil {
+		out.AccountID = *accountID
+	}
+	if accountAlias != nil {
+		out.AccountAlias = *accountAlias
+	}
+	return out, blockHeight, txPos, nil
+}
+
 func constructOutputsQuery(where string, vals []interface{}, timestampMS uint64, after *OutputsAfter, limit int) (string, []interface{}) {
 	var buf bytes.Buffer
 
@@ -0,0 +1,24 @@
+package query
+
+import (
+	"context"
+
+	"github.com/chainmint/errors"
+)
+
+// PruneSpentOutputs deletes the annotated outputs spent (or retired)
+// before timestampMS. Queries for outputs or balances at earlier
+// times no longer include them; queries at or after timestampMS are
+// unaffected. It returns the number of outputs deleted.
+func (ind *Indexer) PruneSpentOutputs(ctx context.Context, timestampMS uint64) (int64, error) {
+	const q = `
+		DELETE FROM annotated_outputs
+		WHERE NOT UPPER_INF(timespan) AND UPPER(timespan) <= $1
+	`
+	res, err := ind.db.Exec(ctx, q, timestampMS)
+	if err != nil {
+		return 0, errors.Wrap(err, "deleting spent annotated outputs")
+	}
+	n, err := res.RowsAffected()
+	return n, errors.Wrap(err)
+}
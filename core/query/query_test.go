@@ -314,7 +314,7 @@ func TestQueryBalances(t *testing.T) {
 			fields = append(fields, f)
 		}
 
-		balances, err := indexer.Balances(ctx, tc.predicate, tc.values, fields, bc.Millis(tc.when))
+		balances, _, err := indexer.Balances(ctx, tc.predicate, tc.values, fields, bc.Millis(tc.when), nil, 0)
 		if err != nil {
 			t.Fatal(err)
 		}
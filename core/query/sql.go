@@ -85,9 +85,52 @@ var (
 			"reference_data":           {Name: "reference_data", Type: filter.Object, SQLType: filter.SQLJSONB},
 			"is_local":                 {Name: "local", Type: filter.String, SQLType: filter.SQLBool},
 		},
+		// Filters like inputs(asset_id=$1) AND outputs(account_id=$2)
+		// compile to EXISTS subqueries joined on tx_hash. The joins,
+		// and the asset_id and account_id comparisons within them,
+		// are indexed; see migration 2017-05-01.8.
 		ForeignKeys: map[string]*filter.SQLForeignKey{
 			"inputs":  {Table: inputsTable, LocalColumn: "tx_hash", ForeignColumn: "tx_hash"},
 			"outputs": {Table: outputsTable, LocalColumn: "tx_hash", ForeignColumn: "tx_hash"},
 		},
 	}
 )
+
+// Filterable describes the fields the filter of a list query may
+// compare, by name, with the type of each. Subfilters are the
+// fields, in the same form, of the items a subfilter such as
+// inputs(...) may select.
+type Filterable struct {
+	Fields     map[string]string            `json:"fields"`
+	Subfilters map[string]map[string]string `json:"subfilters,omitempty"`
+}
+
+// Filterables returns the fields that may be filtered on by the
+// kind of item listed: accounts, assets, transactions, and outputs,
+// which are also the items /list-balances sums.
+func Filterables() map[string]*Filterable {
+	return map[string]*Filterable{
+		"accounts":     filterable(accountsTable),
+		"assets":       filterable(assetsTable),
+		"transactions": filterable(transactionsTable),
+		"outputs":      filterable(outputsTable),
+	}
+}
+
+func filterable(tbl *filter.SQLTable) *Filterable {
+	fields := func(tbl *filter.SQLTable) map[string]string {
+		m := make(map[string]string, len(tbl.Columns))
+		for name, col := range tbl.Columns {
+			m[name] = col.Type.String()
+		}
+		return m
+	}
+	f := &Filterable{Fields: fields(tbl)}
+	for name, fk := range tbl.ForeignKeys {
+		if f.Subfilters == nil {
+			f.Subfilters = make(map[string]map[string]string)
+		}
+		f.Subfilters[name] = fields(fk.Table)
+	}
+	return f
+}
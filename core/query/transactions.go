@@ -77,6 +77,13 @@ func (ind *Indexer) LookupTxAfter(ctx context.Context, begin, end uint64) (TxAft
 // Transactions queries the blockchain for transactions matching the
 // filter predicate `filt`.
 func (ind *Indexer) Transactions(ctx context.Context, filt string, vals []interface{}, after TxAfter, limit int, asc bool) ([]*AnnotatedTx, *TxAfter, error) {
+	return ind.CategoryTransactions(ctx, "", filt, vals, after, limit, asc)
+}
+
+// CategoryTransactions is like Transactions, but only returns
+// transactions tagged with category. An empty category matches
+// every transaction.
+func (ind *Indexer) CategoryTransactions(ctx context.Context, category, filt string, vals []interface{}, after TxAfter, limit int, asc bool) ([]*AnnotatedTx, *TxAfter, error) {
 	p, err := filter.Parse(filt, transactionsTable, vals)
 	if err != nil {
 		return nil, nil, err
@@ -88,6 +95,13 @@ func (ind *Indexer) Transactions(ctx context.Context, filt string, vals []interf
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "converting to SQL")
 	}
+	if category != "" {
+		if len(expr) > 0 {
+			expr = "(" + expr + ") AND "
+		}
+		vals = append(append([]interface{}(nil), vals...), category)
+		expr += fmt.Sprintf("EXISTS (SELECT 1 FROM annotated_tx_categories AS cat WHERE cat.tx_hash = txs.tx_hash AND cat.category = $%d)", len(vals))
+	}
 
 	queryStr, queryArgs := constructTransactionsQuery(expr, vals, after, asc, limit)
 
@@ -0,0 +1,45 @@
+package query
+
+import (
+	"context"
+
+	"github.com/chainmint/database/pg"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+// ErrIndexMismatch is returned by VerifyBlockIndex when the index
+// disagrees with the block it was built from.
+var ErrIndexMismatch = errors.New("transaction index does not match block")
+
+// VerifyBlockIndex checks that the transactions indexed for b are
+// the transactions of b, in order. Blocks not yet indexed pass.
+func (ind *Indexer) VerifyBlockIndex(ctx context.Context, b *legacy.Block) error {
+	var indexed bool
+	err := ind.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM query_blocks WHERE height = $1)`, b.Height).Scan(&indexed)
+	if err != nil {
+		return errors.Wrap(err, "query_blocks select query")
+	}
+	if !indexed {
+		return nil
+	}
+
+	const q = `SELECT tx_hash FROM annotated_txs WHERE block_height = $1 ORDER BY tx_pos`
+	var ids []bc.Hash
+	err = pg.ForQueryRows(ctx, ind.db, q, b.Height, func(id bc.Hash) {
+		ids = append(ids, id)
+	})
+	if err != nil {
+		return errors.Wrap(err, "annotated_txs select query")
+	}
+	if len(ids) != len(b.Transactions) {
+		return errors.WithDetailf(ErrIndexMismatch, "block %d has %d transactions, %d indexed", b.Height, len(b.Transactions), len(ids))
+	}
+	for i, tx := range b.Transactions {
+		if ids[i] != tx.ID {
+			return errors.WithDetailf(ErrIndexMismatch, "transaction %d of block %d is indexed as %x, want %x", i, b.Height, ids[i].Bytes(), tx.ID.Bytes())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+
+	"github.com/chainmint/core/query"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/net/http/httpjson"
+	"github.com/chainmint/protocol"
+	"github.com/chainmint/protocol/bc"
+)
+
+// rawBlock is a block as serialized on the chain.
+type rawBlock struct {
+	Height   uint64  `json:"height"`
+	ID       bc.Hash `json:"id"`
+	Encoding string  `json:"encoding"`
+	RawBlock string  `json:"raw_block"`
+}
+
+// rawTx is a transaction as serialized in its block.
+type rawTx struct {
+	ID             bc.Hash `json:"id"`
+	BlockHeight    uint64  `json:"block_height"`
+	Position       uint32  `json:"position"`
+	Encoding       string  `json:"encoding"`
+	RawTransaction string  `json:"raw_transaction"`
+}
+
+// encodeRaw encodes serialized data as hex, the default, or base64.
+func encodeRaw(b []byte, encoding string) (string, string, error) {
+	switch encoding {
+	case "", "hex":
+		return hex.EncodeToString(b), "hex", nil
+	case "base64":
+		return base64.StdEncoding.EncodeToString(b), "base64", nil
+	}
+	return "", "", errors.WithDetailf(httpjson.ErrBadRequest, "unknown encoding %q; want hex or base64", encoding)
+}
+
+// getRawBlock is an http handler for fetching the exact serialized
+// bytes of a block, given by height or ID. With neither, it returns
+// the latest block.
+//
+// POST /get-raw-block
+func (a *API) getRawBlock(ctx context.Context, in struct {
+	Height   uint64   `json:"height,omitempty"`
+	ID       *bc.Hash `json:"id,omitempty"`
+	Encoding string   `json:"encoding"`
+}) (*rawBlock, error) {
+	height := in.Height
+	if in.ID != nil {
+		h, err := a.indexer.BlockHeight(ctx, *in.ID)
+		if err != nil {
+			return nil, err
+		}
+		if height != 0 && height != h {
+			return nil, errors.WithDetail(httpjson.ErrBadRequest, "height and id name different blocks")
+		}
+		height = h
+	}
+	if height == 0 {
+		height = a.chain.Height()
+	}
+	if height > a.chain.Height() {
+		return nil, errors.WithDetailf(protocol.ErrTheDistantFuture, "height %d, chain height %d", height, a.chain.Height())
+	}
+	b, err := a.store.GetBlock(ctx, height)
+	if err != nil {
+		return nil, err
+	}
+	data, err := a.store.GetRawBlock(ctx, height)
+	if err != nil {
+		return nil, err
+	}
+	enc, encoding, err := encodeRaw(data, in.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	return &rawBlock{Height: height, ID: b.Hash(), Encoding: encoding, RawBlock: enc}, nil
+}
+
+// getRawTransaction is an http handler for fetching the exact
+// serialized bytes of a transaction in a block, given by ID. It
+// finds the transaction's block in the transaction index.
+//
+// POST /get-raw-transaction
+func (a *API) getRawTransaction(ctx context.Context, in struct {
+	ID       bc.Hash `json:"id"`
+	Encoding string  `json:"encoding"`
+}) (*rawTx, error) {
+	annotated, err := a.indexer.Transaction(ctx, in.ID)
+	if err != nil {
+		return nil, err
+	}
+	b, err := a.store.GetBlock(ctx, annotated.BlockHeight)
+	if err != nil {
+		return nil, err
+	}
+	pos := annotated.Position
+	if int(pos) >= len(b.Transactions) || b.Transactions[pos].ID != in.ID {
+		return nil, errors.WithDetailf(query.ErrIndexMismatch, "transaction %x is not at position %d of block %d", in.ID.Bytes(), pos, b.Height)
+	}
+	var buf bytes.Buffer
+	_, err = b.Transactions[pos].WriteTo(&buf)
+	if err != nil {
+		return nil, errors.Wrap(err, "serializing transaction")
+	}
+	enc, encoding, err := encodeRaw(buf.Bytes(), in.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	return &rawTx{
+		ID:             in.ID,
+		BlockHeight:    b.Height,
+		Position:       pos,
+		Encoding:       encoding,
+		RawTransaction: enc,
+	}, nil
+}
@@ -0,0 +1,33 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/net/http/httpjson"
+)
+
+func TestEncodeRaw(t *testing.T) {
+	data := []byte{0x03, 0xfb, 0xff}
+	cases := []struct {
+		encoding, want, wantEncoding string
+	}{
+		{"", "03fbff", "hex"},
+		{"hex", "03fbff", "hex"},
+		{"base64", "A/v/", "base64"},
+	}
+	for _, c := range cases {
+		got, gotEncoding, err := encodeRaw(data, c.encoding)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want || gotEncoding != c.wantEncoding {
+			t.Errorf("encodeRaw(%q) = %q, %q want %q, %q", c.encoding, got, gotEncoding, c.want, c.wantEncoding)
+		}
+	}
+
+	_, _, err := encodeRaw(data, "base58")
+	if errors.Root(err) != httpjson.ErrBadRequest {
+		t.Errorf("encodeRaw(base58) error = %v want %v", err, httpjson.ErrBadRequest)
+	}
+}
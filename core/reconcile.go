@@ -0,0 +1,200 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chainmint/core/account"
+	"github.com/chainmint/core/asset"
+	"github.com/chainmint/core/query"
+	"github.com/chainmint/database/sql"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/log"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+)
+
+// reconcilePeriod is how often an inconsistent node is checked
+// again, to find when replay and indexing have repaired it.
+const reconcilePeriod = 5 * time.Second
+
+var errNotReconciled = errors.New("node state not reconciled")
+
+// reconciledPins are the block processors whose indexes must be up
+// to date with the chain before the Core serves writes.
+var reconciledPins = []string{query.TxPinName, account.PinName, asset.PinName}
+
+// Reconciliation compares the heights reached by each part of the
+// node. Tendermint's height and the app's strategy checkpoint count
+// Tendermint blocks; the others count chain blocks, which may be
+// one ahead for the initial block the Core makes at configuration.
+type Reconciliation struct {
+	TendermintHeight uint64            `json:"tendermint_height"`
+	BlockHeight      uint64            `json:"block_height"`
+	SnapshotHeight   uint64            `json:"snapshot_height"`
+	IndexedHeight    uint64            `json:"indexed_height"`
+	Indexes          map[string]uint64 `json:"indexes"`
+	CheckpointHeight uint64            `json:"checkpoint_height"`
+	Problems         []string          `json:"problems"`
+	Consistent       bool              `json:"consistent"`
+	CheckedAt        time.Time         `json:"checked_at"`
+}
+
+// check sets r.Problems, in a fixed order, and r.Consistent from
+// the heights in r.
+func (r *Reconciliation) check() {
+	r.Problems = []string{}
+	problem := func(format string, args ...interface{}) {
+		r.Problems = append(r.Problems, fmt.Sprintf(format, args...))
+	}
+	if r.CheckpointHeight > r.TendermintHeight {
+		problem("strategy checkpoint %d is ahead of Tendermint height %d", r.CheckpointHeight, r.TendermintHeight)
+	} else if r.CheckpointHeight < r.TendermintHeight {
+		problem("strategy checkpoint %d is behind Tendermint height %d; awaiting replay", r.CheckpointHeight, r.TendermintHeight)
+	}
+	if r.BlockHeight < r.CheckpointHeight {
+		problem("block height %d is behind strategy checkpoint %d", r.BlockHeight, r.CheckpointHeight)
+	}
+	if r.BlockHeight > r.TendermintHeight+1 {
+		problem("block height %d is ahead of Tendermint height %d", r.BlockHeight, r.TendermintHeight)
+	}
+	if r.SnapshotHeight > r.BlockHeight {
+		problem("snapshot height %d is ahead of block height %d", r.SnapshotHeight, r.BlockHeight)
+	}
+	for _, name := range reconciledPins {
+		if h, ok := r.Indexes[name]; ok && h < r.BlockHeight {
+			problem("%s index at %d is behind block height %d; awaiting indexing", name, h, r.BlockHeight)
+		}
+	}
+	r.Consistent = len(r.Problems) == 0
+}
+
+// StartReconciliation compares the heights reached by each part of
+// the node, logs the report and publishes it in /info. Until they
+// are consistent, the Core refuses writes and the check is repeated
+// periodically. checkpointHeight returns the height of the app's
+// latest strategy checkpoint.
+func (a *API) StartReconciliation(ctx context.Context, checkpointHeight func(context.Context) (uint64, error)) {
+	r := a.reconcile(ctx, checkpointHeight)
+	if r.Consistent {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(reconcilePeriod)
+		defer ticker.Stop()
+		for !r.Consistent {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r = a.reconcile(ctx, checkpointHeight)
+			}
+		}
+	}()
+}
+
+// reconcile produces, logs and publishes a reconciliation report.
+func (a *API) reconcile(ctx context.Context, checkpointHeight func(context.Context) (uint64, error)) *Reconciliation {
+	r, err := a.reconciliation(ctx, checkpointHeight)
+	if err != nil {
+		r.Problems = append(r.Problems, err.Error())
+		r.Consistent = false
+	}
+	a.reconciledMu.Lock()
+	a.reconciled = r
+	a.reconciledMu.Unlock()
+
+	log.Printkv(ctx,
+		"at", "reconciliation",
+		"consistent", r.Consistent,
+		"tendermint_height", r.TendermintHeight,
+		"block_height", r.BlockHeight,
+		"snapshot_height", r.SnapshotHeight,
+		"indexed_height", r.IndexedHeight,
+		"checkpoint_height", r.CheckpointHeight,
+		"problems", strings.Join(r.Problems, "; "),
+	)
+	if r.Consistent {
+		a.setHealth("reconciliation", nil)
+	} else {
+		a.setHealth("reconciliation", errors.WithDetail(errNotReconciled, r.Problems[0]))
+	}
+	return r
+}
+
+// reconciliation gathers the heights of each part of the node and
+// checks them. If a height can't be read, the report returned has
+// the heights read so far and the error.
+func (a *API) reconciliation(ctx context.Context, checkpointHeight func(context.Context) (uint64, error)) (*Reconciliation, error) {
+	r := &Reconciliation{
+		BlockHeight: a.chain.Height(),
+		Indexes:     make(map[string]uint64),
+		Problems:    []string{},
+		CheckedAt:   time.Now().UTC(),
+	}
+
+	status := new(ctypes.ResultStatus)
+	_, err := a.client.Call("status", map[string]interface{}{}, status)
+	if err != nil {
+		return r, errors.Wrap(err, "reading Tendermint status")
+	}
+	r.TendermintHeight = uint64(status.LatestBlockHeight)
+
+	if a.store != nil {
+		r.SnapshotHeight, _, err = a.store.LatestSnapshotInfo(ctx)
+		if err != nil && err != sql.ErrNoRows {
+			return r, errors.Wrap(err, "reading snapshot height")
+		}
+	}
+
+	r.IndexedHeight = r.BlockHeight
+	for _, name := range reconciledPins {
+		h, ok := a.pinStore.CurrentHeight(name)
+		if !ok {
+			continue // not indexing
+		}
+		r.Indexes[name] = h
+		if h < r.IndexedHeight {
+			r.IndexedHeight = h
+		}
+	}
+
+	r.CheckpointHeight, err = checkpointHeight(ctx)
+	if err != nil {
+		return r, errors.Wrap(err, "reading strategy checkpoint height")
+	}
+
+	r.check()
+	return r, nil
+}
+
+// Reconciliation returns the latest reconciliation report, or nil
+// if reconciliation was never started.
+func (a *API) Reconciliation() *Reconciliation {
+	a.reconciledMu.Lock()
+	defer a.reconciledMu.Unlock()
+	return a.reconciled
+}
+
+// Reconciled reports whether the Core may serve writes: either
+// reconciliation was never started, or it found the node
+// consistent.
+func (a *API) Reconciled() bool {
+	r := a.Reconciliation()
+	return r == nil || r.Consistent
+}
+
+// needReconciled wraps a handler for writes so that it refuses them
+// until the node is reconciled.
+func (a *API) needReconciled(h http.Handler) http.Handler {
+	refuse := alwaysError(errNotReconciled)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !a.Reconciled() {
+			refuse.ServeHTTP(w, req)
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,52 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/chainmint/core/query"
+)
+
+func TestReconciliationCheck(t *testing.T) {
+	cases := []struct {
+		r    Reconciliation
+		want []string
+	}{{
+		r:    Reconciliation{},
+		want: []string{},
+	}, {
+		// The chain's initial block precedes Tendermint's first.
+		r:    Reconciliation{TendermintHeight: 10, CheckpointHeight: 10, BlockHeight: 11, SnapshotHeight: 5},
+		want: []string{},
+	}, {
+		r: Reconciliation{TendermintHeight: 12, CheckpointHeight: 10, BlockHeight: 10},
+		want: []string{
+			"strategy checkpoint 10 is behind Tendermint height 12; awaiting replay",
+		},
+	}, {
+		r: Reconciliation{TendermintHeight: 7, CheckpointHeight: 10, BlockHeight: 9, SnapshotHeight: 10},
+		want: []string{
+			"strategy checkpoint 10 is ahead of Tendermint height 7",
+			"block height 9 is behind strategy checkpoint 10",
+			"block height 9 is ahead of Tendermint height 7",
+			"snapshot height 10 is ahead of block height 9",
+		},
+	}, {
+		r: Reconciliation{
+			TendermintHeight: 10, CheckpointHeight: 10, BlockHeight: 10,
+			Indexes: map[string]uint64{query.TxPinName: 7},
+		},
+		want: []string{
+			"tx index at 7 is behind block height 10; awaiting indexing",
+		},
+	}}
+	for i, c := range cases {
+		c.r.check()
+		if !reflect.DeepEqual(c.r.Problems, c.want) {
+			t.Errorf("case %d: problems = %q, want %q", i, c.r.Problems, c.want)
+		}
+		if c.r.Consistent != (len(c.want) == 0) {
+			t.Errorf("case %d: consistent = %v, want %v", i, c.r.Consistent, len(c.want) == 0)
+		}
+	}
+}
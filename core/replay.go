@@ -0,0 +1,199 @@
+package core
+
+import (
+	"context"
+
+	"github.com/chainmint/core/txdb"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/net/http/httpjson"
+	"github.com/chainmint/protocol"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/state"
+	"github.com/chainmint/protocol/validation"
+)
+
+// blockReplay is the result of replaying a block against the state
+// after its parent. Valid reports whether the block validated and
+// its transactions applied to give the state root it commits to;
+// if not, Error says why.
+type blockReplay struct {
+	Height          uint64      `json:"height"`
+	BlockID         bc.Hash     `json:"block_id"`
+	ParentHeight    uint64      `json:"parent_height"`
+	ParentStateRoot bc.Hash     `json:"parent_state_root"`
+	StateRoot       bc.Hash     `json:"state_root"`
+	BlockStateRoot  bc.Hash     `json:"block_state_root"`
+	PrunedNonces    int         `json:"pruned_nonces"`
+	Valid           bool        `json:"valid"`
+	Error           string      `json:"error,omitempty"`
+	Transactions    []*txReplay `json:"transactions"`
+}
+
+// txReplay is the trace of one transaction of a replayed block: the
+// reads and writes of the state it made, in order, and why it is
+// invalid or failed to apply, if it did.
+type txReplay struct {
+	ID       bc.Hash        `json:"id"`
+	Position int            `json:"position"`
+	Error    string         `json:"error,omitempty"`
+	Accesses []*stateAccess `json:"state_accesses"`
+}
+
+// stateAccess is a state.Access, annotated with the value of the
+// output accessed.
+type stateAccess struct {
+	Kind     string      `json:"kind"`
+	Op       string      `json:"op"`
+	ID       bc.Hash     `json:"id"`
+	AssetID  *bc.AssetID `json:"asset_id,omitempty"`
+	Amount   *uint64     `json:"amount,omitempty"`
+	ExpiryMS uint64      `json:"expiry_ms,omitempty"`
+}
+
+// replayBlock replays the block at the given height against the
+// state after its parent, rebuilt from the nearest stored snapshot,
+// and returns the trace of its execution. Nothing replayed touches
+// the node's own state, so auditors can check the accounting of any
+// block independently of how the node applied it.
+//
+// POST /replay-block
+func (a *API) replayBlock(ctx context.Context, in struct {
+	Height uint64 `json:"height"`
+}) (*blockReplay, error) {
+	if in.Height == 0 {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "height is required")
+	}
+	if in.Height > a.chain.Height() {
+		return nil, errors.WithDetailf(protocol.ErrTheDistantFuture, "height %d, chain height %d", in.Height, a.chain.Height())
+	}
+	parent, err := a.parentSnapshot(ctx, in.Height)
+	if err != nil {
+		return nil, err
+	}
+	b, err := a.store.GetBlock(ctx, in.Height)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting block %d", in.Height)
+	}
+	var prev *legacy.Block
+	if in.Height > 1 {
+		prev, err = a.store.GetBlock(ctx, in.Height-1)
+		if err != nil {
+			return nil, errors.Wrapf(err, "getting block %d", in.Height-1)
+		}
+	}
+	return traceBlock(b, prev, parent, a.chain.InitialBlockHash), nil
+}
+
+// parentSnapshot rebuilds the state after the block before height.
+func (a *API) parentSnapshot(ctx context.Context, height uint64) (*state.Snapshot, error) {
+	return snapshotAt(ctx, a.store, height-1)
+}
+
+// snapshotAt rebuilds the state after the block at height, applying
+// the blocks after the nearest snapshot stored at or below height
+// to it.
+func snapshotAt(ctx context.Context, store *txdb.Store, height uint64) (*state.Snapshot, error) {
+	snapshot, snapHeight, err := store.SnapshotAtOrBelow(ctx, height)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting snapshot")
+	}
+	for h := snapHeight + 1; h <= height; h++ {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		b, err := store.GetBlock(ctx, h)
+		if err != nil {
+			return nil, errors.Wrapf(err, "getting block %d", h)
+		}
+		err = snapshot.ApplyBlock(legacy.MapBlock(b))
+		if err != nil {
+			return nil, errors.Wrapf(err, "applying block %d", h)
+		}
+		if b.AssetsMerkleRoot != snapshot.Tree.RootHash() {
+			return nil, errors.Wrapf(protocol.ErrBadStateRoot, "rebuilding state at block %d", h)
+		}
+	}
+	return snapshot, nil
+}
+
+// traceBlock validates b against prev, its parent, and applies its
+// transactions to parent, the state after prev, recording each
+// transaction's accesses to the state. It modifies parent. prev is
+// nil for the initial block.
+func traceBlock(b, prev *legacy.Block, parent *state.Snapshot, initialBlockHash bc.Hash) *blockReplay {
+	r := &blockReplay{
+		Height:          b.Height,
+		BlockID:         b.Hash(),
+		ParentStateRoot: parent.Tree.RootHash(),
+		BlockStateRoot:  b.AssetsMerkleRoot,
+		Transactions:    []*txReplay{},
+	}
+	if prev != nil {
+		r.ParentHeight = prev.Height
+	}
+	fail := func(err error) {
+		if r.Error == "" {
+			r.Error = err.Error()
+		}
+	}
+
+	blockEnts := legacy.MapBlock(b)
+	var prevEnts *bc.Block
+	if prev != nil {
+		prevEnts = legacy.MapBlock(prev)
+	}
+	validateTx := func(tx *bc.Tx) error { return validation.ValidateTx(tx, initialBlockHash) }
+	err := validation.ValidateBlock(blockEnts, prevEnts, initialBlockHash, validateTx)
+	if err == nil && prevEnts != nil {
+		err = validation.ValidateBlockSig(blockEnts, prevEnts.NextConsensusProgram)
+	}
+	if err != nil {
+		fail(errors.Wrap(err, "validating block"))
+	}
+
+	nonces := len(parent.Nonces)
+	parent.PruneNonces(blockEnts.TimestampMs)
+	r.PrunedNonces = nonces - len(parent.Nonces)
+
+	for i, tx := range blockEnts.Transactions {
+		t := &txReplay{ID: tx.ID, Position: i}
+		r.Transactions = append(r.Transactions, t)
+		err := validateTx(tx)
+		if err != nil {
+			t.Error = err.Error()
+		}
+		accesses, err := parent.TraceTx(tx)
+		for _, acc := range accesses {
+			t.Accesses = append(t.Accesses, annotateAccess(tx, acc))
+		}
+		if err != nil {
+			if t.Error == "" {
+				t.Error = err.Error()
+			}
+			// The state is no longer that the rest of the block
+			// applies to.
+			fail(errors.Wrapf(err, "applying transaction %d", i))
+			break
+		}
+	}
+
+	r.StateRoot = parent.Tree.RootHash()
+	if r.StateRoot != r.BlockStateRoot {
+		fail(protocol.ErrBadStateRoot)
+	}
+	r.Valid = r.Error == ""
+	return r
+}
+
+// annotateAccess adds the value of the output accessed by acc,
+// which tx holds for the outputs it spends as well as creates.
+func annotateAccess(tx *bc.Tx, acc state.Access) *stateAccess {
+	sa := &stateAccess{Kind: acc.Kind, Op: acc.Op, ID: acc.ID, ExpiryMS: acc.ExpiryMS}
+	out, ok := tx.Entries[acc.ID].(*bc.Output)
+	if ok && out.Source != nil && out.Source.Value != nil {
+		sa.AssetID = out.Source.Value.AssetId
+		sa.Amount = &out.Source.Value.Amount
+	}
+	return sa
+}
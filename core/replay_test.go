@@ -0,0 +1,78 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chainmint/protocol"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/bctest"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/state"
+)
+
+func TestTraceBlock(t *testing.T) {
+	initial := bc.EmptyStringHash
+	tx := bctest.NewIssuanceTx(t, initial)
+	b := &legacy.Block{
+		BlockHeader: legacy.BlockHeader{
+			Version:     1,
+			Height:      1,
+			TimestampMS: bc.Millis(time.Now()),
+		},
+		Transactions: []*legacy.Tx{tx},
+	}
+	txRoot, err := bc.MerkleRoot([]*bc.Tx{tx.Tx})
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := state.Empty()
+	err = after.ApplyTx(tx.Tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.TransactionsMerkleRoot = txRoot
+	b.AssetsMerkleRoot = after.Tree.RootHash()
+
+	r := traceBlock(b, nil, state.Empty(), initial)
+	if !r.Valid || r.Error != "" {
+		t.Fatalf("replay invalid: %s", r.Error)
+	}
+	if r.StateRoot != b.AssetsMerkleRoot || len(r.Transactions) != 1 {
+		t.Fatalf("replay = %+v", r)
+	}
+	got := r.Transactions[0]
+	if got.ID != tx.ID || got.Error != "" {
+		t.Errorf("tx replay = %+v", got)
+	}
+	// An issuance reads and inserts its nonce, then inserts its
+	// output.
+	ops := []string{state.OpRead, state.OpInsert, state.OpInsert}
+	kinds := []string{state.KindNonce, state.KindNonce, state.KindOutput}
+	if len(got.Accesses) != len(ops) {
+		t.Fatalf("accesses = %+v", got.Accesses)
+	}
+	for i, acc := range got.Accesses {
+		if acc.Op != ops[i] || acc.Kind != kinds[i] {
+			t.Errorf("access %d = %s %s want %s %s", i, acc.Op, acc.Kind, ops[i], kinds[i])
+		}
+	}
+	out := got.Accesses[2]
+	if out.Amount == nil || *out.Amount != 100 || out.AssetID == nil || *out.AssetID != *tx.Outputs[0].AssetId {
+		t.Errorf("output access = %+v, want 100 of %x", out, tx.Outputs[0].AssetId.Bytes())
+	}
+
+	// A block that commits to the wrong state root is invalid.
+	b.AssetsMerkleRoot = bc.Hash{}
+	r = traceBlock(b, nil, state.Empty(), initial)
+	if r.Valid || r.Error != protocol.ErrBadStateRoot.Error() {
+		t.Errorf("replay with bad state root: valid %v, error %q", r.Valid, r.Error)
+	}
+
+	// Replaying onto a state where the nonce is used stops at the
+	// failing transaction.
+	r = traceBlock(b, nil, after, initial)
+	if r.Valid || len(r.Transactions[0].Accesses) != 1 || r.Transactions[0].Error == "" {
+		t.Errorf("replay of conflicting tx = %+v", r.Transactions[0])
+	}
+}
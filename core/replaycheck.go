@@ -0,0 +1,111 @@
+package core
+
+import (
+	"context"
+	"expvar"
+	"time"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/log"
+	"github.com/chainmint/protocol"
+)
+
+var (
+	replayCheckedHeight = expvar.NewInt("replay_check.height")
+	replayDivergences   = expvar.NewInt("replay_check.divergences")
+)
+
+var (
+	errReplayDiverged = errors.New("replay of the stored chain diverged")
+	errReplayPruned   = errors.New("blocks needed to replay the chain have been pruned")
+)
+
+// replayReport is the result of replaying the stored chain from the
+// initial block. Height is the last block that replayed as stored;
+// if Divergence is set, the block after it did not.
+type replayReport struct {
+	Height     uint64               `json:"height"`
+	ChainTip   uint64               `json:"chain_tip"`
+	Divergence *protocol.Divergence `json:"divergence"`
+	StartedAt  time.Time            `json:"started_at"`
+	FinishedAt time.Time            `json:"finished_at"`
+}
+
+// verifyReplay re-executes the entire stored chain from the initial
+// block and reports the first block whose validity or state root,
+// or the first stored snapshot, the replay does not reproduce. It
+// can take a long time on a long chain.
+//
+// POST /verify-replay
+func (a *API) verifyReplay(ctx context.Context) (*replayReport, error) {
+	err := a.checkReplayable(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return a.replayChain(ctx, a.chain.NewReplayer())
+}
+
+// checkReplayable checks that the blocks from the initial block on
+// are still stored.
+func (a *API) checkReplayable(ctx context.Context) error {
+	floor, err := a.store.PrunedBelow(ctx)
+	if err != nil {
+		return err
+	}
+	if floor > 1 {
+		return errors.WithDetailf(errReplayPruned, "blocks below %d are pruned", floor)
+	}
+	return nil
+}
+
+// replayChain replays the blocks r has not replayed, up to the
+// chain's current height, and logs the result.
+func (a *API) replayChain(ctx context.Context, r *protocol.Replayer) (*replayReport, error) {
+	rep := &replayReport{ChainTip: a.chain.Height(), StartedAt: time.Now().UTC()}
+	d, err := r.Replay(ctx, rep.ChainTip)
+	if err != nil {
+		return nil, err
+	}
+	rep.Height = r.Height()
+	rep.Divergence = d
+	rep.FinishedAt = time.Now().UTC()
+	replayCheckedHeight.Set(int64(rep.Height))
+	if d != nil {
+		replayDivergences.Add(1)
+		log.Printkv(ctx, "at", "replay diverged", "height", d.Height, "kind", d.Kind,
+			"stored", d.Stored, "replayed", d.Replayed, "detail", d.Detail)
+	}
+	return rep, nil
+}
+
+// checkReplay replays the chain in the background, from the initial
+// block on and then each period over the blocks added since, so
+// that a block or snapshot the node would not produce again is
+// found as soon as it is stored. A divergence is logged, counted in
+// the replay_check.divergences expvar and reported by /health; the
+// check then stops, since the blocks after it cannot be replayed.
+func (a *API) checkReplay(ctx context.Context, period time.Duration) {
+	err := a.checkReplayable(ctx)
+	if err != nil {
+		log.Error(ctx, err, "replay check disabled")
+		return
+	}
+	setHealth := a.healthSetter("replay")
+	r := a.chain.NewReplayer()
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		rep, err := a.replayChain(ctx, r)
+		if err != nil {
+			log.Error(ctx, err)
+		} else if d := rep.Divergence; d != nil {
+			setHealth(errors.WithDetailf(errReplayDiverged, "%s diverged at block %d", d.Kind, d.Height))
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package core
+
+import (
+	"context"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/net/http/httpjson"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+// listReservations is an http handler for listing the reservations
+// /build-transaction has made of the outputs of an account, given by
+// ID or alias, or of all accounts.
+//
+// POST /list-reservations
+func (a *API) listReservations(ctx context.Context, in struct {
+	AccountID    string `json:"account_id,omitempty"`
+	AccountAlias string `json:"account_alias,omitempty"`
+}) (page, error) {
+	accountID := in.AccountID
+	if accountID == "" && in.AccountAlias != "" {
+		acc, err := a.accounts.FindByAlias(ctx, in.AccountAlias)
+		if err != nil {
+			return page{}, err
+		}
+		accountID = acc.ID
+	}
+	return page{Items: httpjson.Array(a.accounts.Reservations(accountID)), LastPage: true}, nil
+}
+
+// cancelReservations is an http handler for canceling reservations
+// before they expire, so that their outputs may be spent by other
+// transactions. Reservations are given by ID, by the outputs they
+// hold, or by a built transaction, whose reservations are those
+// holding the outputs it spends.
+//
+// POST /cancel-reservations
+func (a *API) cancelReservations(ctx context.Context, in struct {
+	IDs         []uint64   `json:"ids"`
+	OutputIDs   []bc.Hash  `json:"output_ids"`
+	Transaction *legacy.Tx `json:"transaction"`
+}) (page, error) {
+	outputIDs := in.OutputIDs
+	if in.Transaction != nil {
+		for i, txin := range in.Transaction.Inputs {
+			id, err := txin.SpentOutputID()
+			if err != nil {
+				return page{}, errors.WithDetailf(httpjson.ErrBadRequest, "input %d: %s", i, err)
+			}
+			if id != (bc.Hash{}) {
+				outputIDs = append(outputIDs, id)
+			}
+		}
+	}
+	if len(in.IDs) == 0 && len(outputIDs) == 0 {
+		return page{}, errors.WithDetail(httpjson.ErrBadRequest, "ids, output_ids or a transaction spending account outputs is required")
+	}
+	canceled := a.accounts.CancelReservations(ctx, in.IDs, outputIDs)
+	return page{Items: canceled, LastPage: true}, nil
+}
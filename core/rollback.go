@@ -0,0 +1,129 @@
+package core
+
+import (
+	"context"
+
+	"github.com/lib/pq"
+
+	"github.com/chainmint/core/account"
+	"github.com/chainmint/core/txdb"
+	"github.com/chainmint/database/pg"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/log"
+	"github.com/chainmint/net/http/httpjson"
+)
+
+var (
+	errBadRollback = errors.New("cannot roll back to the requested height")
+	errNoRollback  = errors.New("core cannot restart itself to roll back on this platform")
+)
+
+// A RollbackFunc rewinds state kept outside the Core, such as the
+// app's, to height. Rollback calls it while the blocks above height
+// are still stored.
+type RollbackFunc func(ctx context.Context, db pg.DB, store *txdb.Store, height uint64) error
+
+// Rollback rewinds the chain stored in db and store by blocks
+// blocks, after a consensus failure or a bad upgrade, and returns
+// the height it rewound to. It must be called before the Core is
+// started, like Tendermint's own rollback command: the blocks and
+// snapshots above the new height are deleted, the state at it is
+// saved as a snapshot, and the block processors are moved back so
+// that the indexes are rebuilt. Each of fns is called first to
+// rewind state kept elsewhere.
+//
+// Once the chain has been recovered, the app reports the new height
+// to Tendermint, whose handshake replays any later blocks it still
+// has.
+func Rollback(ctx context.Context, db pg.DB, store *txdb.Store, blocks uint64, fns ...RollbackFunc) (uint64, error) {
+	height, err := rollbackHeight(ctx, store, blocks)
+	if err != nil {
+		return 0, err
+	}
+	snapshot, err := snapshotAt(ctx, store, height)
+	if err != nil {
+		return 0, err
+	}
+	for _, fn := range fns {
+		err = fn(ctx, db, store, height)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// The account UTXO index forgets outputs once they are spent,
+	// so it is rebuilt from the initial block.
+	const pinsQ = `UPDATE block_processors SET height = $1 WHERE height > $1`
+	_, err = db.Exec(ctx, pinsQ, height)
+	if err != nil {
+		return 0, errors.Wrap(err, "rewinding block processors")
+	}
+	const accountPinsQ = `UPDATE block_processors SET height = 0 WHERE name = ANY($1)`
+	_, err = db.Exec(ctx, accountPinsQ, pq.StringArray{account.PinName, account.DeleteSpentsPinName})
+	if err != nil {
+		return 0, errors.Wrap(err, "resetting account processors")
+	}
+	_, err = db.Exec(ctx, `DELETE FROM account_utxos`)
+	if err != nil {
+		return 0, errors.Wrap(err, "deleting account utxos")
+	}
+	_, err = db.Exec(ctx, `DELETE FROM generator_pending_block`)
+	if err != nil {
+		return 0, errors.Wrap(err, "deleting pending block")
+	}
+
+	err = store.RollbackTo(ctx, height)
+	if err != nil {
+		return 0, err
+	}
+	err = store.SaveSnapshot(ctx, height, snapshot)
+	if err != nil {
+		return 0, err
+	}
+	log.Printkv(ctx, "at", "rolled back chain", "blocks", blocks, "height", height)
+	return height, nil
+}
+
+// rollbackHeight returns the height of the chain in store once it
+// is rolled back by blocks blocks. The initial block is never rolled
+// back, and a pruned chain is not rolled back at all, since the
+// account index could not be rebuilt.
+func rollbackHeight(ctx context.Context, store *txdb.Store, blocks uint64) (uint64, error) {
+	tip, err := store.Height(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if blocks == 0 || blocks >= tip {
+		return 0, errors.WithDetailf(errBadRollback, "cannot roll back %d of %d blocks", blocks, tip)
+	}
+	pruned, err := store.PrunedBelow(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if pruned > 0 {
+		return 0, errors.WithDetailf(errBadRollback, "blocks below %d have been pruned", pruned)
+	}
+	return tip - blocks, nil
+}
+
+// rollback checks that the chain can be rolled back by in.Blocks
+// blocks, then restarts the Core so that it rolls back as it starts;
+// see Rollback. The connection to Tendermint is lost, so Tendermint
+// must be restarted too, after its own rollback command if its state
+// is to be rewound to match.
+//
+// POST /rollback
+func (a *API) rollback(ctx context.Context, in struct {
+	Blocks uint64 `json:"blocks"`
+}) error {
+	if !canExecSelfRollback {
+		return errNoRollback
+	}
+	_, err := rollbackHeight(ctx, a.store, in.Blocks)
+	if err != nil {
+		return err
+	}
+	closeConnOK(httpjson.ResponseWriter(ctx), httpjson.Request(ctx))
+	execSelfRollback(in.Blocks)
+	panic("unreached")
+}
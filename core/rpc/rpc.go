@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/chainmint/errors"
+	"github.com/chainmint/metrics"
 	"github.com/chainmint/net/http/httperror"
 	"github.com/chainmint/net/http/reqid"
 )
@@ -28,6 +29,8 @@ const (
 // the RPC client's blockchain ID.
 var ErrWrongNetwork = errors.New("connected to a peer on a different network")
 
+var clientErrors = metrics.NewCounter("chainmint_rpc_client_errors", "Failed RPCs to other nodes, by path.", "path")
+
 // A Client is a Chain RPC client. It performs RPCs over HTTP using JSON
 // request and responses. A Client must be configured with a secret token
 // to authenticate with other Cores on the network.
@@ -78,6 +81,14 @@ func (c *Client) Call(ctx context.Context, path string, request, response interf
 // CallRaw calls a remote procedure on another node, specified by the path. It
 // returns a io.ReadCloser of the raw response body.
 func (c *Client) CallRaw(ctx context.Context, path string, request interface{}) (io.ReadCloser, error) {
+	r, err := c.callRaw(ctx, path, request)
+	if err != nil {
+		clientErrors.Inc(path)
+	}
+	return r, err
+}
+
+func (c *Client) callRaw(ctx context.Context, path string, request interface{}) (io.ReadCloser, error) {
 	u, err := url.Parse(c.BaseURL)
 	if err != nil {
 		return nil, errors.Wrap(err)
@@ -12,24 +12,40 @@ import (
 	"github.com/chainmint/core/accesstoken"
 	"github.com/chainmint/core/account"
 	"github.com/chainmint/core/asset"
+	"github.com/chainmint/core/blockproc"
 	//"github.com/chainmint/core/config"
-	//"github.com/chainmint/core/fetch"
+	"github.com/chainmint/core/fetch"
 	"github.com/chainmint/core/generator"
-	//"github.com/chainmint/core/leader"
+	"github.com/chainmint/core/leader"
 	"github.com/chainmint/core/pin"
+	"github.com/chainmint/core/pubsub"
 	"github.com/chainmint/core/query"
 	"github.com/chainmint/core/rpc"
 	"github.com/chainmint/core/txbuilder"
 	"github.com/chainmint/core/txdb"
 	"github.com/chainmint/core/txfeed"
 	"github.com/chainmint/database/pg"
+	"github.com/chainmint/errors"
 	//"github.com/chainmint/database/raft"
 	//"github.com/chainmint/log"
 	"github.com/chainmint/protocol"
+	"github.com/chainmint/protocol/bc"
 	"github.com/chainmint/protocol/bc/legacy"
 	rpcClient "github.com/tendermint/tendermint/rpc/lib/client"
 )
 
+// blockProcessorPins are the pins of the block processors that
+// index committed blocks: assets, the UTXOs and control programs of
+// accounts, which building and reserving need whether or not
+// transactions are indexed, and, last, transactions.
+var blockProcessorPins = []string{
+	asset.PinName,
+	account.PinName,
+	account.ExpirePinName,
+	account.DeleteSpentsPinName,
+	query.TxPinName,
+}
+
 const (
 	blockPeriod              = time.Second
 	expireReservationsPeriod = time.Second
@@ -70,6 +86,13 @@ func UseTLS(c *tls.Config) RunOption {
 	}
 }
 
+// UsePeerTLS configures the Core to serve inter-node RPC only to the
+// peers allowlisted by p, over mutual TLS, with API.ServePeers. The
+// RPC routes are then refused on the API handler.
+func UsePeerTLS(p *PeerTLS) RunOption {
+	return func(a *API) { a.peerTLS = p }
+}
+
 // BlockSigner configures the Core to use signFn to handle block-signing
 // requests. In production, this will be a function to call out to signerd
 // and its HSM. In development, it'll use the MockHSM.
@@ -77,6 +100,13 @@ func BlockSigner(signFn func(context.Context, *legacy.Block) ([]byte, error)) Ru
 	return func(a *API) { a.signer = signFn }
 }
 
+// TransactionSigner configures the Core to sign transaction templates
+// at /sign-transaction with s. In production, this is a remote HSM,
+// so that transaction keys are not held by the Core.
+func TransactionSigner(s txbuilder.Signer) RunOption {
+	return func(a *API) { a.txSigner = s }
+}
+
 // GeneratorLocal configures the launched Core to run as a Generator.
 func GeneratorLocal(gen *generator.Generator) RunOption {
 	return func(a *API) {
@@ -84,12 +114,15 @@ func GeneratorLocal(gen *generator.Generator) RunOption {
 			panic("core configured with local and remote generator")
 		}
 		a.generator = gen
-		a.submitter = gen
+		a.submitter = broadcastSubmitter{a, gen.Submit}
 	}
 }
 
 // GeneratorRemote configures the launched Core to fetch blocks from
-// the provided remote generator.
+// the provided remote generator. The Core so runs as a read replica:
+// it follows the chain the remote node commits, serving queries and
+// building transactions from its own copy, and submits transactions
+// to the remote node, taking no part in consensus itself.
 func GeneratorRemote(client *rpc.Client) RunOption {
 	return func(a *API) {
 		if a.generator != nil {
@@ -106,6 +139,99 @@ func IndexTransactions(b bool) RunOption {
 	return func(a *API) { a.indexTxs = b }
 }
 
+// FeeAsset configures the asset in which transaction fees are
+// paid. Fees are the amounts of this asset a transaction retires.
+func FeeAsset(assetID bc.AssetID) RunOption {
+	return func(a *API) { a.feeAsset = assetID }
+}
+
+// SlowQueryThreshold configures the Core to keep samples of query
+// requests that take at least d, reported by /index-stats. Zero
+// disables sampling.
+func SlowQueryThreshold(d time.Duration) RunOption {
+	return func(a *API) { a.slowQueries = &slowQueryLog{threshold: d} }
+}
+
+// PrewarmIndexes configures the Core to load the named database
+// indexes into memory at startup. It requires the pg_prewarm
+// extension.
+func PrewarmIndexes(names []string) RunOption {
+	return func(a *API) { a.prewarm = names }
+}
+
+// IssuanceWindow configures the replay-protection window of
+// issuances. The issuances the Core builds begin minTimeSlack before
+// now, to allow for clock skew and latency, and transactions whose
+// issuances have time ranges longer than maxWindow are invalid.
+// A zero maxWindow leaves time ranges unbounded.
+func IssuanceWindow(minTimeSlack, maxWindow time.Duration) RunOption {
+	return func(a *API) {
+		a.assets.SetMinTimeSlack(minTimeSlack)
+		a.chain.MaxIssuanceWindow = maxWindow
+	}
+}
+
+// ScrubPeriod configures how often the Core re-reads and verifies a
+// random historical block and snapshot. Zero disables scrubbing.
+func ScrubPeriod(d time.Duration) RunOption {
+	return func(a *API) { a.scrubPeriod = d }
+}
+
+// ReplayCheckPeriod configures how often the Core replays the blocks
+// added to the chain since it last did, having first replayed the
+// whole chain from the initial block; see /verify-replay. Zero
+// disables the check.
+func ReplayCheckPeriod(d time.Duration) RunOption {
+	return func(a *API) { a.replayPeriod = d }
+}
+
+// FeeEstimateBlocks configures how many of the most recent blocks
+// holding transactions /estimate-fee bases its estimates on. Zero
+// disables fee estimation.
+func FeeEstimateBlocks(n int) RunOption {
+	return func(a *API) {
+		a.fees = nil
+		if n > 0 {
+			a.fees = &feeTracker{blocks: n}
+		}
+	}
+}
+
+// AnomalyDetectors configures detectors to run on each block's
+// transactions as they are indexed. The transactions they flag are
+// listed by /list-transaction-flags and published to subscribers
+// as anomaly events.
+func AnomalyDetectors(detectors ...query.Detector) RunOption {
+	return func(a *API) { a.detectors = append(a.detectors, detectors...) }
+}
+
+// BlockProcessors configures the block processors to run on each
+// committed block, after the indexers; see package blockproc.
+func BlockProcessors(s *blockproc.Set) RunOption {
+	return func(a *API) { a.processors = s }
+}
+
+// GeneratorFailover configures the Core as one of several processes
+// sharing a database, of which one, the leader, generates blocks for
+// the app, while the others stand by as hot spares. They elect the
+// leader by a lease in the database, which the leader renews every
+// half second; see package leader. A process standing by follows the
+// chain as the leader commits blocks, and forwards submitted
+// transactions to the leader. If the leader's lease lapses, such as
+// because it is unreachable, a process standing by takes over: it
+// recovers the chain state from the database and calls lead, which
+// must ready the app to generate blocks.
+func GeneratorFailover(lead func(context.Context) error) RunOption {
+	return func(a *API) { a.failoverLead = lead }
+}
+
+// TendermintAddr configures the address of the Tendermint RPC
+// server the Core queries for node status. It defaults to
+// tcp://0.0.0.0:46657.
+func TendermintAddr(addr string) RunOption {
+	return func(a *API) { a.client = rpcClient.NewURIClient(addr) }
+}
+
 // RateLimit adds a rate-limiting restriction, using keyFn to extract the
 // key to rate limit on. It will allow up to burst requests in the bucket
 // and will refill the bucket at perSecond tokens per second.
@@ -186,9 +312,11 @@ func Run(
 		indexer:      indexer,
 		accessTokens: &accesstoken.CredentialStore{DB: db},
 		db:           db,
+		dbURL:        dbURL,
 		client:       rpcClient.NewURIClient(tendermintLAddr),
 		mux:          http.NewServeMux(),
 		addr:         routableAddress,
+		events:       pubsub.NewBroker(),
 	}
 	for _, opt := range opts {
 		opt(a)
@@ -201,19 +329,78 @@ func Run(
 		go pinStore.Listen(ctx, query.TxPinName, dbURL)
 		a.indexer.RegisterAnnotator(a.assets.AnnotateTxs)
 		a.indexer.RegisterAnnotator(a.accounts.AnnotateTxs)
+		for _, d := range a.detectors {
+			a.indexer.RegisterDetector(d)
+		}
 		a.assets.IndexAssets(a.indexer)
 		a.accounts.IndexAccounts(a.indexer)
 	}
 
+	// A replica follows the chain by fetching each block the remote
+	// generator commits, from the local chain's latest.
+	if a.remoteGenerator != nil {
+		_, _, err = c.Recover(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "recovering chain state")
+		}
+		fetch.Init(ctx, a.remoteGenerator)
+		go fetch.Fetch(ctx, c, a.remoteGenerator, a.healthSetter("fetch"))
+	}
+
+	// Under GeneratorFailover, only the leader processes blocks,
+	// from a.lead; processes standing by would duplicate its work.
+	if a.failoverLead == nil {
+		err = a.processBlocks(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(a.prewarm) > 0 {
+		go prewarmIndexes(ctx, a.db, a.prewarm)
+	}
+
 	// Clean up expired UTXO reservations periodically.
 	go accounts.ExpireReservations(ctx, expireReservationsPeriod)
 
 	// GC old submitted txs periodically.
 	go cleanUpSubmittedTxs(ctx, a.db)
 
+	// Publish chain events to /subscribe subscribers.
+	go a.publishEvents(ctx)
+
+	// Check stored chain data for corruption periodically.
+	if a.scrubPeriod > 0 && a.store != nil {
+		go a.scrub(ctx, a.scrubPeriod)
+	}
+
+	// Re-execute the chain to check that it replays as stored.
+	if a.replayPeriod > 0 && a.store != nil {
+		go a.checkReplay(ctx, a.replayPeriod)
+	}
+
+	// Track the fees paid in recent blocks for /estimate-fee.
+	if a.fees != nil {
+		go a.trackFees(ctx)
+	}
+
+	// Prune historical data no longer kept by the retention policy.
+	if a.prunePeriod > 0 && !a.retention.Archive() && a.store != nil {
+		go a.prune(ctx, a.prunePeriod)
+	}
+
+	// Resize caches to the memory available.
+	if a.caches != nil && a.caches.cfg.MinFreeMemory > 0 && a.caches.cfg.TunePeriod > 0 {
+		go a.tuneCaches(ctx, a.caches.cfg.TunePeriod)
+	}
+
 	// When this cored becomes leader, run a.lead to perform
 	// leader-only Core duties.
-	//a.leader = leader.Run(ctx, db, routableAddress, a.lead)
+	if a.failoverLead != nil {
+		a.leader = leader.Run(ctx, db, routableAddress, func(leadCtx context.Context) {
+			a.lead(ctx, leadCtx)
+		})
+	}
 
 	/*err = a.addAllowedMember(ctx, struct{ Addr string }{routableAddress})
 	if err != nil {
@@ -226,3 +413,47 @@ func Run(
 
 	return a, nil
 }
+
+// processBlocks starts the asset and account processors, the tx
+// indexer if indexing transactions, and then the operator's block
+// processors, which process each block after the others have.
+func (a *API) processBlocks(ctx context.Context) error {
+	if a.indexTxs {
+		// Discard any index writes torn by an unclean shutdown
+		// before the tx pin resumes processing blocks, indexing
+		// them again.
+		err := a.indexer.Recover(ctx)
+		if err != nil {
+			return errors.Wrap(err, "recovering tx index")
+		}
+	}
+	err := a.pinStore.LoadAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Process each block as the app commits it, resuming wherever
+	// the block processors left off.
+	pins := a.blockProcessorPins()
+	for _, name := range pins {
+		err = a.pinStore.CreatePin(ctx, name, 0)
+		if err != nil {
+			return errors.Wrapf(err, "creating pin %q", name)
+		}
+	}
+	if a.indexTxs {
+		go a.indexer.ProcessBlocks(ctx)
+	}
+	go a.assets.ProcessBlocks(ctx)
+	go a.accounts.ProcessBlocks(ctx)
+	return a.processors.Run(ctx, a.pinStore, a.chain, pins)
+}
+
+// blockProcessorPins returns the pins of the block processors a
+// runs, leaving out the tx pin unless it indexes transactions.
+func (a *API) blockProcessorPins() []string {
+	if a.indexTxs {
+		return blockProcessorPins
+	}
+	return blockProcessorPins[:len(blockProcessorPins)-1]
+}
@@ -0,0 +1,34 @@
+package core
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/chainmint/core/coretest"
+	"github.com/chainmint/database/pg/pgtest"
+	"github.com/chainmint/protocol/prottest"
+)
+
+// TestScenarios runs each scenario in testdata/scenarios on a
+// fresh node.
+func TestScenarios(t *testing.T) {
+	paths, err := filepath.Glob("testdata/scenarios/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		s, err := coretest.LoadScenario(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Run(s.Name, func(t *testing.T) {
+			_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			n := coretest.NewNode(ctx, t, db, prottest.NewChain(t))
+			coretest.RunScenario(ctx, t, n, s)
+		})
+	}
+}
@@ -250,6 +250,38 @@ CREATE TABLE annotated_outputs (
 
 
 
+CREATE TABLE annotated_tx_categories (
+    block_height bigint NOT NULL,
+    tx_hash bytea NOT NULL,
+    category text NOT NULL
+);
+
+
+
+CREATE TABLE annotated_tx_flags (
+    id bigint NOT NULL,
+    tx_hash bytea NOT NULL,
+    block_height bigint NOT NULL,
+    account_id text DEFAULT ''::text NOT NULL,
+    reason text NOT NULL,
+    detail text DEFAULT ''::text NOT NULL
+);
+
+
+
+CREATE SEQUENCE annotated_tx_flags_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+
+ALTER SEQUENCE annotated_tx_flags_id_seq OWNED BY annotated_tx_flags.id;
+
+
+
 CREATE TABLE annotated_txs (
     block_height bigint NOT NULL,
     tx_pos integer NOT NULL,
@@ -264,6 +296,26 @@ CREATE TABLE annotated_txs (
 
 
 
+CREATE TABLE asset_holders (
+    asset_id bytea NOT NULL,
+    control_program bytea NOT NULL,
+    amount bigint NOT NULL
+);
+
+
+
+CREATE TABLE asset_supply (
+    asset_id bytea NOT NULL,
+    height bigint NOT NULL,
+    "timestamp" bigint NOT NULL,
+    issued bigint NOT NULL,
+    retired bigint NOT NULL,
+    total_issued bigint NOT NULL,
+    total_retired bigint NOT NULL
+);
+
+
+
 CREATE TABLE asset_tags (
     asset_id bytea NOT NULL,
     tags jsonb
@@ -296,6 +348,22 @@ CREATE SEQUENCE assets_key_index_seq
 
 
 
+CREATE TABLE balance_checkpoint_blocks (
+    height bigint NOT NULL,
+    "timestamp" bigint NOT NULL
+);
+
+
+
+CREATE TABLE balance_checkpoints (
+    height bigint NOT NULL,
+    account_id text NOT NULL,
+    asset_id bytea NOT NULL,
+    amount bigint NOT NULL
+);
+
+
+
 CREATE TABLE block_processors (
     name text NOT NULL,
     height bigint DEFAULT 0 NOT NULL
@@ -303,6 +371,14 @@ CREATE TABLE block_processors (
 
 
 
+CREATE TABLE block_pruning (
+    singleton boolean DEFAULT true NOT NULL,
+    below bigint NOT NULL,
+    CONSTRAINT block_pruning_singleton CHECK (singleton)
+);
+
+
+
 CREATE TABLE blocks (
     block_hash bytea NOT NULL,
     height bigint NOT NULL,
@@ -312,6 +388,41 @@ CREATE TABLE blocks (
 
 
 
+CREATE TABLE category_rules (
+    id text DEFAULT next_chain_id('catr'::text) NOT NULL,
+    category text NOT NULL,
+    filter text NOT NULL,
+    filter_params jsonb NOT NULL,
+    min_amount bigint NOT NULL,
+    max_amount bigint NOT NULL
+);
+
+
+
+CREATE TABLE chain_app_hash (
+    singleton boolean DEFAULT true NOT NULL,
+    format text NOT NULL,
+    CONSTRAINT chain_app_hash_singleton CHECK (singleton)
+);
+
+
+
+CREATE TABLE chain_crypto (
+    singleton boolean DEFAULT true NOT NULL,
+    provider text NOT NULL,
+    CONSTRAINT chain_crypto_singleton CHECK (singleton)
+);
+
+
+
+CREATE TABLE chain_strategy (
+    singleton boolean DEFAULT true NOT NULL,
+    name text NOT NULL,
+    CONSTRAINT chain_strategy_singleton CHECK (singleton)
+);
+
+
+
 CREATE SEQUENCE chain_id_seq
     START WITH 1
     INCREMENT BY 1
@@ -321,6 +432,15 @@ CREATE SEQUENCE chain_id_seq
 
 
 
+CREATE TABLE commit_wal (
+    singleton boolean DEFAULT true NOT NULL,
+    height bigint NOT NULL,
+    data bytea NOT NULL,
+    CONSTRAINT commit_wal_singleton CHECK (singleton)
+);
+
+
+
 CREATE TABLE config (
     singleton boolean DEFAULT true NOT NULL,
     is_signer boolean,
@@ -359,6 +479,16 @@ CREATE TABLE leader (
 
 
 
+CREATE TABLE metadata_index (
+    kind text NOT NULL,
+    id bytea NOT NULL,
+    block_height bigint NOT NULL,
+    path text NOT NULL,
+    value text NOT NULL
+);
+
+
+
 CREATE TABLE migrations (
     filename text NOT NULL,
     hash text NOT NULL,
@@ -432,6 +562,27 @@ CREATE TABLE snapshots (
 
 
 
+CREATE TABLE snapshot_files (
+    height bigint NOT NULL,
+    hash bytea NOT NULL,
+    size bigint NOT NULL,
+    created_at timestamp without time zone DEFAULT now() NOT NULL
+);
+
+
+
+CREATE TABLE strategy_states (
+    height bigint NOT NULL,
+    validators bytea NOT NULL,
+    data bytea,
+    governance bytea,
+    stake bytea,
+    pending_diffs bytea,
+    chain_height bigint
+);
+
+
+
 CREATE TABLE submitted_txs (
     tx_hash bytea NOT NULL,
     height bigint NOT NULL,
@@ -450,6 +601,10 @@ CREATE TABLE txfeeds (
 
 
 
+ALTER TABLE ONLY annotated_tx_flags ALTER COLUMN id SET DEFAULT nextval('annotated_tx_flags_id_seq'::regclass);
+
+
+
 ALTER TABLE ONLY signers ALTER COLUMN key_index SET DEFAULT nextval('signers_key_index_seq'::regclass);
 
 
@@ -509,11 +664,36 @@ ALTER TABLE ONLY annotated_outputs
 
 
 
+ALTER TABLE ONLY annotated_tx_categories
+    ADD CONSTRAINT annotated_tx_categories_pkey PRIMARY KEY (tx_hash, category);
+
+
+
+ALTER TABLE ONLY annotated_tx_flags
+    ADD CONSTRAINT annotated_tx_flags_pkey PRIMARY KEY (id);
+
+
+
+ALTER TABLE ONLY annotated_tx_flags
+    ADD CONSTRAINT annotated_tx_flags_tx_hash_account_id_reason_key UNIQUE (tx_hash, account_id, reason);
+
+
+
 ALTER TABLE ONLY annotated_txs
     ADD CONSTRAINT annotated_txs_pkey PRIMARY KEY (block_height, tx_pos);
 
 
 
+ALTER TABLE ONLY asset_holders
+    ADD CONSTRAINT asset_holders_pkey PRIMARY KEY (asset_id, control_program);
+
+
+
+ALTER TABLE ONLY asset_supply
+    ADD CONSTRAINT asset_supply_pkey PRIMARY KEY (asset_id, height);
+
+
+
 ALTER TABLE ONLY asset_tags
     ADD CONSTRAINT asset_tags_asset_id_key UNIQUE (asset_id);
 
@@ -534,11 +714,26 @@ ALTER TABLE ONLY assets
 
 
 
+ALTER TABLE ONLY balance_checkpoint_blocks
+    ADD CONSTRAINT balance_checkpoint_blocks_pkey PRIMARY KEY (height);
+
+
+
+ALTER TABLE ONLY balance_checkpoints
+    ADD CONSTRAINT balance_checkpoints_pkey PRIMARY KEY (account_id, height, asset_id);
+
+
+
 ALTER TABLE ONLY block_processors
     ADD CONSTRAINT block_processors_name_key UNIQUE (name);
 
 
 
+ALTER TABLE ONLY block_pruning
+    ADD CONSTRAINT block_pruning_pkey PRIMARY KEY (singleton);
+
+
+
 ALTER TABLE ONLY blocks
     ADD CONSTRAINT blocks_height_key UNIQUE (height);
 
@@ -549,6 +744,31 @@ ALTER TABLE ONLY blocks
 
 
 
+ALTER TABLE ONLY category_rules
+    ADD CONSTRAINT category_rules_pkey PRIMARY KEY (id);
+
+
+
+ALTER TABLE ONLY chain_app_hash
+    ADD CONSTRAINT chain_app_hash_pkey PRIMARY KEY (singleton);
+
+
+
+ALTER TABLE ONLY chain_crypto
+    ADD CONSTRAINT chain_crypto_pkey PRIMARY KEY (singleton);
+
+
+
+ALTER TABLE ONLY chain_strategy
+    ADD CONSTRAINT chain_strategy_pkey PRIMARY KEY (singleton);
+
+
+
+ALTER TABLE ONLY commit_wal
+    ADD CONSTRAINT commit_wal_pkey PRIMARY KEY (singleton);
+
+
+
 ALTER TABLE ONLY config
     ADD CONSTRAINT config_pkey PRIMARY KEY (singleton);
 
@@ -564,6 +784,11 @@ ALTER TABLE ONLY leader
 
 
 
+ALTER TABLE ONLY metadata_index
+    ADD CONSTRAINT metadata_index_pkey PRIMARY KEY (kind, id, path, value);
+
+
+
 ALTER TABLE ONLY migrations
     ADD CONSTRAINT migrations_pkey PRIMARY KEY (filename);
 
@@ -599,11 +824,21 @@ ALTER TABLE ONLY mockhsm
 
 
 
+ALTER TABLE ONLY snapshot_files
+    ADD CONSTRAINT snapshot_files_pkey PRIMARY KEY (height);
+
+
+
 ALTER TABLE ONLY snapshots
     ADD CONSTRAINT state_trees_pkey PRIMARY KEY (height);
 
 
 
+ALTER TABLE ONLY strategy_states
+    ADD CONSTRAINT strategy_states_pkey PRIMARY KEY (height);
+
+
+
 ALTER TABLE ONLY submitted_txs
     ADD CONSTRAINT submitted_txs_pkey PRIMARY KEY (tx_hash);
 
@@ -632,18 +867,58 @@ CREATE INDEX annotated_assets_sort_id ON annotated_assets USING btree (sort_id);
 
 
 
+CREATE INDEX annotated_inputs_account_id_idx ON annotated_inputs USING btree (account_id) WHERE (account_id IS NOT NULL);
+
+
+
+CREATE INDEX annotated_inputs_asset_id_idx ON annotated_inputs USING btree (encode(asset_id, 'hex'::text));
+
+
+
+CREATE INDEX annotated_outputs_account_id_idx ON annotated_outputs USING btree (account_id) WHERE (account_id IS NOT NULL);
+
+
+
+CREATE INDEX annotated_outputs_asset_id_idx ON annotated_outputs USING btree (encode(asset_id, 'hex'::text));
+
+
+
 CREATE INDEX annotated_outputs_timespan_idx ON annotated_outputs USING gist (timespan);
 
 
 
+CREATE INDEX annotated_outputs_tx_hash_idx ON annotated_outputs USING btree (tx_hash);
+
+
+
+CREATE INDEX annotated_tx_categories_category_idx ON annotated_tx_categories USING btree (category, block_height);
+
+
+
+CREATE INDEX annotated_tx_flags_block_height_idx ON annotated_tx_flags USING btree (block_height);
+
+
+
 CREATE INDEX annotated_txs_data_idx ON annotated_txs USING gin (data jsonb_path_ops);
 
 
 
+CREATE INDEX annotated_txs_tx_hash_idx ON annotated_txs USING btree (tx_hash);
+
+
+
+CREATE INDEX asset_holders_asset_id_amount_idx ON asset_holders USING btree (asset_id, amount DESC, control_program);
+
+
+
 CREATE INDEX assets_sort_id ON assets USING btree (sort_id);
 
 
 
+CREATE INDEX metadata_index_value_idx ON metadata_index USING btree (kind, path, lower(value) text_pattern_ops);
+
+
+
 CREATE INDEX query_blocks_timestamp_idx ON query_blocks USING btree ("timestamp");
 
 
@@ -666,3 +941,21 @@ insert into migrations (filename, hash) values ('2017-03-09.0.core.account-utxos
 insert into migrations (filename, hash) values ('2017-04-13.0.query.block-transactions-count.sql', '7cb17e05596dbfdf75e347e43ccab110e393f41ea86f70697e59cf0c32c3a564');
 insert into migrations (filename, hash) values ('2017-04-17.0.core.null-token-type.sql', '185942cec464c12a2573f19ae386153389328f8e282af071024706e105e37eeb');
 insert into migrations (filename, hash) values ('2017-04-27.0.generator.pending-block-height.sql', 'bfe4fe5eec143e4367a91fd952cb5e3879f1c311f649ec13bfe95b202e94d4ec');
+insert into migrations (filename, hash) values ('2017-05-01.0.app.strategy-states.sql', '79c43359fe2ffe3a0b3717dc7a45eddb4d824d1f590896354ba71de8c73ca3f2');
+insert into migrations (filename, hash) values ('2017-05-01.1.app.governance.sql', 'b72bf3449fac6196518a23c3644c7bd9d308ceeaf6fd984cd6bd0ac2893dc12d');
+insert into migrations (filename, hash) values ('2017-05-01.2.app.stake-book.sql', '690155a4b3ecc6e974b85609c35db39351cc1288e4e0d79c97bd29925370e7b9');
+insert into migrations (filename, hash) values ('2017-05-01.3.app.epoch-pending-diffs.sql', '2b9e53a8c4657103346aad2f2c0d614658d4e60c4c3e194140e63095d1dfbcc6');
+insert into migrations (filename, hash) values ('2017-05-01.5.query.tx-categories.sql', 'f48dc7e489d220eb82147f9464e8825f8842c1dfe80ffa93a562c1eb09b25c02');
+insert into migrations (filename, hash) values ('2017-05-01.6.app.asset-holders.sql', '8216644a6248b81da12645387db2437a30d9f522e78cce8f0e3d89796fd81832');
+insert into migrations (filename, hash) values ('2017-05-01.7.app.chain-crypto.sql', '89da751c620b7210f6b5f9c5e4a88c557b56018ab70de5ba7c5bd1d9f6e7d1c9');
+insert into migrations (filename, hash) values ('2017-05-01.8.query.filter-lookup-indexes.sql', '922c46ea5e4e2705b8dd609c77dddd8d8adb594f6ad1a67badf8e10dc221a3e9');
+insert into migrations (filename, hash) values ('2017-05-01.9.query.tx-flags.sql', '2ea0a0f3473d6d444472bbf874a0ce871fa133a939002a285efc2f612dc54e0a');
+insert into migrations (filename, hash) values ('2017-05-02.0.app.balance-checkpoints.sql', '6ef4f369aa6fdd37d6cb720f27b76f3cb53e44405dd605eed900a261d913ddcc');
+insert into migrations (filename, hash) values ('2017-05-03.0.app.metadata-index.sql', '60c60d6efc759018af8325b351ea101085f6d2ade67c24dbc8ba4797c4dc099f');
+insert into migrations (filename, hash) values ('2017-05-04.0.app.asset-supply.sql', '7c81de883e78a5e088e59f13644aac948b5c13d3128f7935ea6f315142389e18');
+insert into migrations (filename, hash) values ('2017-05-05.0.core.snapshot-files.sql', '86630f0b7e40416569b3b4ccf58290ef6bf1ac43d486728b4ae2c97da2626889');
+insert into migrations (filename, hash) values ('2017-05-06.0.core.block-pruning.sql', 'c3af163f73f7e6fb3a8f1b3c84ef0cf6f722b442716c7e84fac951be2632368a');
+insert into migrations (filename, hash) values ('2017-05-07.0.app.chain-app-hash.sql', 'c55b2ab6baa33519ae14bd52bc59ded8dd97e7478012b17ca15bc71848656059');
+insert into migrations (filename, hash) values ('2017-05-08.0.app.commit-wal.sql', 'c90c607c9ea43bdad2d9e48e5437c2c587187ea97ec08ad9dca367eeadcc29b3');
+insert into migrations (filename, hash) values ('2017-05-10.0.app.strategy-state-chain-height.sql', 'a7c6a077d6f62e30a3cc385b4d2ccd3d3a0405644dc4c5fba0ad74ad18ea924a');
+insert into migrations (filename, hash) values ('2017-05-11.0.app.chain-strategy.sql', 'e759d8ed5c1b03cb0418a626eed628c81c9d6fe30de6f40598fc790ce20d91fd');
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"context"
+	"expvar"
+	"math/rand"
+	"time"
+
+	"github.com/chainmint/core/query"
+	"github.com/chainmint/core/txdb"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/log"
+)
+
+var (
+	scrubBlocks    = expvar.NewInt("scrub.blocks_checked")
+	scrubSnapshots = expvar.NewInt("scrub.snapshots_checked")
+	scrubFailures  = expvar.NewInt("scrub.corruptions")
+	scrubLastOK    = expvar.NewInt("scrub.last_pass_unix")
+)
+
+// scrub periodically re-reads a randomly chosen historical block and
+// snapshot from the database and verifies them, and the index built
+// from the block, so that silent disk corruption is noticed before
+// it reaches backups. Corruption is logged, counted in the
+// scrub.corruptions expvar and reported by /health until a later
+// pass succeeds.
+func (a *API) scrub(ctx context.Context, period time.Duration) {
+	setHealth := a.healthSetter("scrubber")
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			err := a.scrubOnce(ctx)
+			if errors.Root(err) == txdb.ErrCorrupt || errors.Root(err) == query.ErrIndexMismatch {
+				scrubFailures.Add(1)
+				setHealth(err)
+				log.Printkv(ctx, "at", "scrub found corruption", "detail", errors.Detail(err))
+				log.Error(ctx, err)
+			} else if err != nil {
+				log.Error(ctx, err)
+			} else {
+				setHealth(nil)
+				scrubLastOK.Set(time.Now().Unix())
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// scrubOnce verifies one random unpruned block, its index entries,
+// and one random snapshot.
+func (a *API) scrubOnce(ctx context.Context) error {
+	height := a.chain.Height()
+	if height == 0 {
+		return nil
+	}
+	// Choose among the blocks not yet pruned.
+	floor, err := a.store.PrunedBelow(ctx)
+	if err != nil {
+		return err
+	}
+	if floor == 0 {
+		floor = 1
+	}
+	if floor > height {
+		return nil
+	}
+	height = floor + uint64(rand.Int63n(int64(height-floor+1)))
+	b, err := a.store.VerifyBlock(ctx, height)
+	if err != nil {
+		return err
+	}
+	if a.indexTxs {
+		err = a.indexer.VerifyBlockIndex(ctx, b)
+		if err != nil {
+			return err
+		}
+	}
+	scrubBlocks.Add(1)
+
+	snapHeight, err := a.store.RandomSnapshotHeight(ctx)
+	if err != nil || snapHeight == 0 {
+		return err
+	}
+	err = a.store.VerifySnapshot(ctx, snapHeight)
+	if err != nil {
+		return err
+	}
+	scrubSnapshots.Add(1)
+	return nil
+}
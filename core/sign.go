@@ -0,0 +1,39 @@
+package core
+
+import (
+	"context"
+
+	"github.com/chainmint/core/txbuilder"
+	"github.com/chainmint/crypto/ed25519/chainkd"
+)
+
+type signRequest struct {
+	Txs   []*txbuilder.Template `json:"transactions"`
+	XPubs []chainkd.XPub        `json:"xpubs"`
+}
+
+// signTemplates is an http handler for signing transaction templates
+// with the Core's transaction signer, for the keys among xpubs that
+// it holds. Each result is either the signed template or an error.
+//
+// POST /sign-transaction
+func (a *API) signTemplates(ctx context.Context, x signRequest) ([]interface{}, error) {
+	if a.txSigner == nil {
+		return nil, errNoTxSigner
+	}
+	return signWith(ctx, x, txbuilder.SignWith(a.txSigner)), nil
+}
+
+func signWith(ctx context.Context, x signRequest, signFn txbuilder.SignFunc) []interface{} {
+	resp := make([]interface{}, 0, len(x.Txs))
+	for _, tx := range x.Txs {
+		err := txbuilder.Sign(ctx, tx, x.XPubs, signFn)
+		if err != nil {
+			info := errorFormatter.Format(err)
+			resp = append(resp, info)
+		} else {
+			resp = append(resp, tx)
+		}
+	}
+	return resp
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chainmint/core/txbuilder"
+	"github.com/chainmint/crypto/ed25519/chainkd"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+type testSigner map[chainkd.XPub]chainkd.XPrv
+
+func (s testSigner) XSign(ctx context.Context, xpub chainkd.XPub, path [][]byte, msg []byte) ([]byte, error) {
+	xprv, ok := s[xpub]
+	if !ok {
+		return nil, nil
+	}
+	return xprv.Derive(path).Sign(msg), nil
+}
+
+func TestSignTemplates(t *testing.T) {
+	ctx := context.Background()
+	xprv, xpub, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := legacy.NewTx(legacy.TxData{
+		Version: 1,
+		Inputs: []*legacy.TxInput{
+			legacy.NewSpendInput(nil, bc.NewHash([32]byte{1}), bc.AssetID{}, 5, 0, []byte{0x51}, bc.Hash{}, nil),
+		},
+		Outputs: []*legacy.TxOutput{
+			legacy.NewTxOutput(bc.AssetID{}, 5, []byte{0x51}, nil),
+		},
+	})
+	sigInst := &txbuilder.SigningInstruction{Position: 0}
+	sigInst.AddWitnessKeys([]chainkd.XPub{xpub}, [][]byte{{1}}, 1)
+	tpl := &txbuilder.Template{Transaction: tx, SigningInstructions: []*txbuilder.SigningInstruction{sigInst}}
+	req := signRequest{Txs: []*txbuilder.Template{tpl}, XPubs: []chainkd.XPub{xpub}}
+
+	a := &API{}
+	_, err = a.signTemplates(ctx, req)
+	if err != errNoTxSigner {
+		t.Errorf("signTemplates without a signer error = %v want %v", err, errNoTxSigner)
+	}
+
+	a.txSigner = testSigner{xpub: xprv}
+	resp, err := a.signTemplates(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp) != 1 || resp[0] != tpl {
+		t.Fatalf("signTemplates = %+v, want the signed template", resp)
+	}
+	if sigs := sigInst.SignatureWitnesses[0].Sigs; len(sigs) != 1 || len(sigs[0]) == 0 {
+		t.Errorf("signatures = %x, want one", sigs)
+	}
+}
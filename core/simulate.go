@@ -0,0 +1,103 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/net/http/httpjson"
+	"github.com/chainmint/protocol"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/state"
+)
+
+// txSimulation is the result of running a transaction against the
+// state at some height without submitting it. Valid reports whether
+// it would be accepted in the next block; if not, Error says why.
+// Accesses are the reads and writes of the state it makes, so far as
+// it applies, and StateRoot the state root after them.
+type txSimulation struct {
+	ID        bc.Hash        `json:"id"`
+	Height    uint64         `json:"height"`
+	Valid     bool           `json:"valid"`
+	Error     string         `json:"error,omitempty"`
+	Accesses  []*stateAccess `json:"state_accesses"`
+	StateRoot bc.Hash        `json:"state_root"`
+}
+
+// simulateTx is an http handler for checking a transaction, signed
+// or not, before submitting it. It validates the transaction as if
+// it were to go in the next block after the current state, or after
+// the block at the given height, and applies it to a copy of that
+// state. An unsigned transaction fails validation, but its state
+// changes are still reported.
+//
+// POST /simulate-transaction
+func (a *API) simulateTx(ctx context.Context, in struct {
+	Transaction *legacy.Tx `json:"transaction"`
+	Height      uint64     `json:"height,omitempty"`
+}) (*txSimulation, error) {
+	if in.Transaction == nil {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "transaction is required")
+	}
+	b, snapshot := a.chain.State()
+	if b == nil {
+		return nil, errors.WithDetail(protocol.ErrTheDistantFuture, "no blocks yet")
+	}
+	height := b.Height
+	if in.Height > height {
+		return nil, errors.WithDetailf(protocol.ErrTheDistantFuture, "height %d, chain height %d", in.Height, height)
+	}
+	if in.Height > 0 && in.Height < height {
+		var err error
+		height = in.Height
+		snapshot, err = a.parentSnapshot(ctx, height+1)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		snapshot = state.Copy(snapshot)
+	}
+	sim := simulate(in.Transaction, snapshot, bc.Millis(time.Now()), a.chain.ValidateTx)
+	sim.Height = height
+	return sim, nil
+}
+
+// simulate validates tx with validate, checks its time range against
+// timestampMS, the time of the next block, and applies it to s,
+// which it modifies.
+func simulate(tx *legacy.Tx, s *state.Snapshot, timestampMS uint64, validate func(*bc.Tx) error) *txSimulation {
+	sim := &txSimulation{ID: tx.ID, Accesses: []*stateAccess{}}
+	fail := func(err error) {
+		if sim.Error == "" {
+			sim.Error = err.Error()
+			if d := errors.Detail(err); d != "" {
+				sim.Error += ": " + d
+			}
+		}
+	}
+
+	err := validate(tx.Tx)
+	if err != nil {
+		fail(err)
+	}
+	if tx.MinTimeMs > timestampMS {
+		fail(errors.WithDetailf(protocol.ErrBadTx, "transaction is not valid until %d, now %d", tx.MinTimeMs, timestampMS))
+	}
+	if tx.MaxTimeMs > 0 && tx.MaxTimeMs < timestampMS {
+		fail(errors.WithDetailf(protocol.ErrBadTx, "transaction expired at %d, now %d", tx.MaxTimeMs, timestampMS))
+	}
+
+	s.PruneNonces(timestampMS)
+	accesses, err := s.TraceTx(tx.Tx)
+	for _, acc := range accesses {
+		sim.Accesses = append(sim.Accesses, annotateAccess(tx.Tx, acc))
+	}
+	if err != nil {
+		fail(errors.Wrap(err, "applying transaction"))
+	}
+	sim.StateRoot = s.Tree.RootHash()
+	sim.Valid = sim.Error == ""
+	return sim
+}
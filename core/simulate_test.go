@@ -0,0 +1,39 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/bctest"
+	"github.com/chainmint/protocol/state"
+	"github.com/chainmint/protocol/validation"
+)
+
+func TestSimulate(t *testing.T) {
+	initial := bc.EmptyStringHash
+	tx := bctest.NewIssuanceTx(t, initial)
+	validate := func(tx *bc.Tx) error { return validation.ValidateTx(tx, initial) }
+	now := tx.MinTimeMs
+
+	s := state.Empty()
+	sim := simulate(tx, s, now, validate)
+	if !sim.Valid || sim.Error != "" || sim.ID != tx.ID {
+		t.Fatalf("simulation of valid tx = %+v", sim)
+	}
+	if len(sim.Accesses) != 3 || sim.StateRoot != s.Tree.RootHash() {
+		t.Errorf("simulation accesses = %+v", sim.Accesses)
+	}
+
+	// The nonce is now used, so the same issuance fails to apply.
+	sim = simulate(tx, s, now, validate)
+	if sim.Valid || !strings.Contains(sim.Error, "conflicting nonce") {
+		t.Errorf("simulation of replayed tx: valid %v, error %q", sim.Valid, sim.Error)
+	}
+
+	// After its time range, the tx has expired.
+	sim = simulate(tx, state.Empty(), tx.MaxTimeMs+1, validate)
+	if sim.Valid || !strings.Contains(sim.Error, "expired") {
+		t.Errorf("simulation of expired tx: valid %v, error %q", sim.Valid, sim.Error)
+	}
+}
@@ -0,0 +1,129 @@
+package core
+
+import (
+	"context"
+
+	"github.com/chainmint/core/txdb"
+	"github.com/chainmint/database/sql"
+	"github.com/chainmint/encoding/json"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/net/http/httpjson"
+	"github.com/chainmint/protocol"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/state"
+)
+
+// stateDiff reports where the local state at some height first
+// differs from a peer's. Node is the deepest node of the state
+// trees the two share whose hashes differ, Output the first output
+// (UTXO) in only one of them, and Nonce the first issuance nonce
+// whose expiry differs. Each is omitted if the two agree on it.
+type stateDiff struct {
+	Height    uint64           `json:"height"`
+	Equal     bool             `json:"equal"`
+	LocalRoot bc.Hash          `json:"local_state_root"`
+	PeerRoot  bc.Hash          `json:"peer_state_root"`
+	Node      *stateDiffNode   `json:"tree_node,omitempty"`
+	Output    *stateDiffOutput `json:"output,omitempty"`
+	Nonce     *stateDiffNonce  `json:"nonce,omitempty"`
+}
+
+type stateDiffNode struct {
+	Prefix    string  `json:"prefix"`
+	LocalHash bc.Hash `json:"local_hash"`
+	PeerHash  bc.Hash `json:"peer_hash"`
+}
+
+// stateDiffOutput is an output in only one state. If the output was
+// ever indexed locally, BlockHeight is the height it was created at
+// and AccountID the local account, if any, that received it.
+type stateDiffOutput struct {
+	ID          bc.Hash `json:"id"`
+	OnlyIn      string  `json:"only_in"`
+	BlockHeight uint64  `json:"block_height,omitempty"`
+	AccountID   string  `json:"account_id,omitempty"`
+}
+
+type stateDiffNonce struct {
+	ID          bc.Hash `json:"id"`
+	LocalExpiry uint64  `json:"local_expiry_ms"`
+	PeerExpiry  uint64  `json:"peer_expiry_ms"`
+}
+
+// diffState is an http handler for diagnosing an app hash mismatch.
+// It compares the state after the block at in.Height with
+// in.Snapshot, a peer's state at the same height in the raw form
+// served by get-snapshot, and reports where they first differ.
+//
+// POST /diff-state
+func (a *API) diffState(ctx context.Context, in struct {
+	Height   uint64        `json:"height"`
+	Snapshot json.HexBytes `json:"snapshot"`
+}) (*stateDiff, error) {
+	if in.Height == 0 {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "height is required")
+	}
+	peer, err := txdb.DecodeSnapshot(in.Snapshot)
+	if err != nil {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "snapshot is not a valid state snapshot")
+	}
+	b, local := a.chain.State()
+	if b == nil || in.Height > b.Height {
+		return nil, errors.WithDetailf(protocol.ErrTheDistantFuture, "height %d", in.Height)
+	}
+	if in.Height < b.Height {
+		local, err = snapshotAt(ctx, a.store, in.Height)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resp := &stateDiff{
+		Height:    in.Height,
+		Equal:     true,
+		LocalRoot: local.Tree.RootHash(),
+		PeerRoot:  peer.Tree.RootHash(),
+	}
+	d := state.Compare(local, peer)
+	if d == nil {
+		return resp, nil
+	}
+	resp.Equal = false
+	if d.Tree != nil {
+		resp.Node = &stateDiffNode{
+			Prefix:    d.Tree.Prefix,
+			LocalHash: d.Tree.HashA,
+			PeerHash:  d.Tree.HashB,
+		}
+		resp.Output, err = a.diffOutput(ctx, d.Tree.Item, d.Tree.InA)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if d.Nonce != nil {
+		resp.Nonce = &stateDiffNonce{
+			ID:          *d.Nonce,
+			LocalExpiry: d.ExpiryA,
+			PeerExpiry:  d.ExpiryB,
+		}
+	}
+	return resp, nil
+}
+
+func (a *API) diffOutput(ctx context.Context, item []byte, local bool) (*stateDiffOutput, error) {
+	var id [32]byte
+	copy(id[:], item)
+	out := &stateDiffOutput{ID: bc.NewHash(id), OnlyIn: "peer"}
+	if local {
+		out.OnlyIn = "local"
+	}
+	const q = `
+		SELECT block_height, COALESCE(account_id, '') FROM annotated_outputs
+		WHERE output_id = $1
+	`
+	err := a.db.QueryRow(ctx, q, item).Scan(&out.BlockHeight, &out.AccountID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.Wrap(err, "looking up output")
+	}
+	return out, nil
+}
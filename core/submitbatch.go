@@ -0,0 +1,117 @@
+package core
+
+import (
+	"context"
+
+	"github.com/chainmint/core/txbuilder"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+// maxBatchTxs is the most transactions /submit-batch takes at once.
+const maxBatchTxs = 1000
+
+var (
+	errBatchTooLarge   = errors.New("too many transactions in batch")
+	errBatchConflict   = errors.New("transaction spends an output another transaction in the batch spends")
+	errBatchDependency = errors.New("transaction spends an output of a rejected transaction in the batch")
+)
+
+// submitBatch validates the given signed transactions and broadcasts
+// them to Tendermint, in dependency order, so that a transaction
+// spending the output of another in the batch follows it whatever
+// their order in the request. It returns a result for each
+// transaction, in the order of the request: its ID if Tendermint
+// accepted it, or else the error rejecting it. A transaction spending
+// the output of a rejected one is rejected too, as is one spending
+// the same output as a transaction before it.
+//
+// POST /submit-batch
+func (a *API) submitBatch(ctx context.Context, x struct {
+	Transactions []*legacy.Tx `json:"transactions"`
+}) ([]interface{}, error) {
+	if len(x.Transactions) > maxBatchTxs {
+		return nil, errors.WithDetailf(errBatchTooLarge, "%d transactions, at most %d", len(x.Transactions), maxBatchTxs)
+	}
+	for i, tx := range x.Transactions {
+		if tx == nil {
+			return nil, errors.WithDetailf(txbuilder.ErrMissingRawTx, "transaction %d", i)
+		}
+	}
+
+	responses := make([]interface{}, len(x.Transactions))
+	failed := make(map[bc.Hash]bool) // outputs of rejected txs
+	spent := make(map[bc.Hash]bool)  // outputs spent by accepted txs
+	for _, i := range batchOrder(x.Transactions) {
+		tx := x.Transactions[i]
+		err := a.submitBatchTx(tx, failed, spent)
+		if err != nil {
+			for _, id := range tx.ResultIds {
+				failed[*id] = true
+			}
+			errorFormatter.Log(ctx, err)
+			responses[i] = errorFormatter.Format(err)
+			continue
+		}
+		for _, id := range tx.SpentOutputIDs {
+			spent[id] = true
+		}
+		responses[i] = map[string]string{"id": tx.ID.String()}
+	}
+	return responses, nil
+}
+
+func (a *API) submitBatchTx(tx *legacy.Tx, failed, spent map[bc.Hash]bool) error {
+	for _, id := range tx.SpentOutputIDs {
+		if failed[id] {
+			return errors.WithDetailf(errBatchDependency, "tx %x spends output %x", tx.ID.Bytes(), id.Bytes())
+		}
+		if spent[id] {
+			return errors.WithDetailf(errBatchConflict, "tx %x spends output %x", tx.ID.Bytes(), id.Bytes())
+		}
+	}
+	err := a.chain.ValidateTx(tx.Tx)
+	if errors.Root(err) == protocol.ErrBadTx {
+		return errors.Sub(txbuilder.ErrRejected, err)
+	} else if err != nil {
+		return errors.Wrapf(err, "tx %x", tx.ID.Bytes())
+	}
+	raw, err := tx.MarshalText()
+	if err != nil {
+		return errors.Wrap(err, "marshaling tx")
+	}
+	return errors.Wrapf(a.BroadcastTx(raw), "tx %x", tx.ID.Bytes())
+}
+
+// batchOrder returns the positions of txs in an order in which each
+// tx follows the txs whose outputs it spends, keeping their order
+// otherwise.
+func batchOrder(txs []*legacy.Tx) []int {
+	creator := make(map[bc.Hash]int)
+	for i, tx := range txs {
+		for _, id := range tx.ResultIds {
+			creator[*id] = i
+		}
+	}
+	order := make([]int, 0, len(txs))
+	visited := make([]bool, len(txs))
+	var visit func(i int)
+	visit = func(i int) {
+		if visited[i] {
+			return
+		}
+		visited[i] = true
+		for _, id := range txs[i].SpentOutputIDs {
+			if parent, ok := creator[id]; ok {
+				visit(parent)
+			}
+		}
+		order = append(order, i)
+	}
+	for i := range txs {
+		visit(i)
+	}
+	return order
+}
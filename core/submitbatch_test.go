@@ -0,0 +1,53 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+// batchTx returns a tx with the given ID that spends spent and
+// creates an output with ID made.
+func batchTx(id, spent, made byte) *legacy.Tx {
+	out := bc.NewHash([32]byte{made})
+	tx := &legacy.Tx{Tx: &bc.Tx{
+		TxHeader: &bc.TxHeader{ResultIds: []*bc.Hash{&out}},
+		ID:       bc.NewHash([32]byte{id}),
+	}}
+	if spent != 0 {
+		tx.SpentOutputIDs = []bc.Hash{bc.NewHash([32]byte{spent})}
+	}
+	return tx
+}
+
+func TestBatchOrder(t *testing.T) {
+	txs := []*legacy.Tx{
+		batchTx(1, 20, 30), // spends tx 3's output
+		batchTx(2, 0, 40),
+		batchTx(3, 10, 20), // spends tx 4's output
+		batchTx(4, 0, 10),
+	}
+	got := batchOrder(txs)
+	want := []int{3, 2, 0, 1}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("batchOrder = %v, want %v", got, want)
+	}
+}
+
+func TestSubmitBatchTxChecks(t *testing.T) {
+	a := new(API)
+	failed := map[bc.Hash]bool{bc.NewHash([32]byte{10}): true}
+	spent := map[bc.Hash]bool{bc.NewHash([32]byte{20}): true}
+
+	err := a.submitBatchTx(batchTx(1, 10, 30), failed, spent)
+	if errors.Root(err) != errBatchDependency {
+		t.Errorf("submitBatchTx(child of rejected tx) = %v, want %v", err, errBatchDependency)
+	}
+	err = a.submitBatchTx(batchTx(2, 20, 40), failed, spent)
+	if errors.Root(err) != errBatchConflict {
+		t.Errorf("submitBatchTx(double spend) = %v, want %v", err, errBatchConflict)
+	}
+}
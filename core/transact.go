@@ -30,6 +30,8 @@ func (a *API) actionDecoder(action string) (func([]byte) (txbuilder.Action, erro
 		decoder = txbuilder.DecodeControlProgramAction
 	case "control_receiver":
 		decoder = txbuilder.DecodeControlReceiverAction
+	case "escrow":
+		decoder = txbuilder.DecodeEscrowAction
 	case "issue":
 		decoder = a.assets.DecodeIssueAction
 	case "retire":
@@ -38,6 +40,8 @@ func (a *API) actionDecoder(action string) (func([]byte) (txbuilder.Action, erro
 		decoder = a.accounts.DecodeSpendAction
 	case "spend_account_unspent_output":
 		decoder = a.accounts.DecodeSpendUTXOAction
+	case "spend_escrow":
+		decoder = a.decodeSpendEscrowAction
 	case "set_transaction_reference_data":
 		decoder = txbuilder.DecodeSetTxRefDataAction
 	default:
@@ -140,6 +144,11 @@ func (a *API) submitSingle(ctx context.Context, tpl *txbuilder.Template, waitUnt
 		return nil, errors.Wrap(txbuilder.ErrMissingRawTx)
 	}
 
+	if waitUntil == "committed" {
+		tx, err := a.submitCommitted(ctx, tpl)
+		return tx, errors.Wrapf(err, "tx %s", tpl.Transaction.ID.String())
+	}
+
 	err := a.finalizeTxWait(ctx, tpl, waitUntil)
 	if err != nil {
 		return nil, errors.Wrapf(err, "tx %s", tpl.Transaction.ID.String())
@@ -292,7 +301,7 @@ func (a *API) waitForTxInBlock(ctx context.Context, tx *legacy.Tx, height uint64
 type submitArg struct {
 	Transactions []txbuilder.Template
 	wait         chainjson.Duration
-	WaitUntil    string `json:"wait_until"` // values none, confirmed, processed. default: processed
+	WaitUntil    string `json:"wait_until"` // values none, confirmed, committed, processed. default: processed
 }
 
 // POST /submit-transaction
@@ -332,3 +341,29 @@ func (a *API) submit(ctx context.Context, x submitArg) (interface{}, error) {
 	wg.Wait()
 	return responses, nil
 }
+
+// mergeTemplates combines templates of the same transaction, each
+// signed by some of its parties, into one carrying all their
+// signatures. Parties holding different keys can so sign a template
+// in parallel, rather than passing it from one to the next, and
+// submit the merged template once it has a quorum of signatures.
+//
+// POST /merge-transaction-templates
+func (a *API) mergeTemplates(ctx context.Context, in struct {
+	Templates []*txbuilder.Template `json:"templates"`
+}) (*txbuilder.Template, error) {
+	return txbuilder.Merge(in.Templates)
+}
+
+// combineTemplates joins the unsigned partial templates of the
+// parties to an exchange, such as a swap of different assets, into
+// one transaction, after checking that it gives each party the
+// outputs it asked for in return for what it spends. Each party
+// then signs the combined template.
+//
+// POST /combine-transaction-templates
+func (a *API) combineTemplates(ctx context.Context, in struct {
+	Templates []*txbuilder.Template `json:"templates"`
+}) (*txbuilder.Template, error) {
+	return txbuilder.Combine(in.Templates)
+}
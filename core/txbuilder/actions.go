@@ -3,8 +3,11 @@ package txbuilder
 import (
 	"context"
 	stdjson "encoding/json"
+	"time"
 
+	"github.com/chainmint/crypto/ed25519"
 	"github.com/chainmint/encoding/json"
+	"github.com/chainmint/errors"
 	"github.com/chainmint/protocol/bc"
 	"github.com/chainmint/protocol/bc/legacy"
 	"github.com/chainmint/protocol/vm"
@@ -120,3 +123,50 @@ func (a *retireAction) Build(ctx context.Context, b *TemplateBuilder) error {
 	out := legacy.NewTxOutput(*a.AssetId, a.Amount, retirementProgram, a.ReferenceData)
 	return b.AddOutput(out)
 }
+
+func DecodeEscrowAction(data []byte) (Action, error) {
+	a := new(escrowAction)
+	err := stdjson.Unmarshal(data, a)
+	return a, err
+}
+
+// escrowAction locks value in an escrow output, released to the
+// beneficiary by a signature from the oracle or refunded after
+// RefundAt.
+type escrowAction struct {
+	bc.AssetAmount
+	Oracle             json.HexBytes `json:"oracle_pub_key"`
+	BeneficiaryProgram json.HexBytes `json:"beneficiary_control_program"`
+	RefundProgram      json.HexBytes `json:"refund_control_program"`
+	RefundAt           time.Time     `json:"refund_at"`
+	ReferenceData      json.Map      `json:"reference_data"`
+}
+
+func (a *escrowAction) Build(ctx context.Context, b *TemplateBuilder) error {
+	var missing []string
+	if len(a.Oracle) == 0 {
+		missing = append(missing, "oracle_pub_key")
+	}
+	if len(a.BeneficiaryProgram) == 0 {
+		missing = append(missing, "beneficiary_control_program")
+	}
+	if len(a.RefundProgram) == 0 {
+		missing = append(missing, "refund_control_program")
+	}
+	if a.RefundAt.IsZero() {
+		missing = append(missing, "refund_at")
+	}
+	if a.AssetId.IsZero() {
+		missing = append(missing, "asset_id")
+	}
+	if len(missing) > 0 {
+		return MissingFieldsError(missing...)
+	}
+	if len(a.Oracle) != ed25519.PublicKeySize {
+		return errors.WithDetailf(ErrBadEscrow, "oracle_pub_key must be %d bytes", ed25519.PublicKeySize)
+	}
+
+	prog := vmutil.EscrowProgram(ed25519.PublicKey(a.Oracle), a.BeneficiaryProgram, a.RefundProgram, bc.Millis(a.RefundAt))
+	out := legacy.NewTxOutput(*a.AssetId, a.Amount, prog, a.ReferenceData)
+	return b.AddOutput(out)
+}
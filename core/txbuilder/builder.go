@@ -43,6 +43,16 @@ func (b *TemplateBuilder) AddOutput(o *legacy.TxOutput) error {
 	return nil
 }
 
+// NextOutputIndex returns the position in the transaction of the
+// next output to be added.
+func (b *TemplateBuilder) NextOutputIndex() uint64 {
+	n := len(b.outputs)
+	if b.base != nil {
+		n += len(b.base.Outputs)
+	}
+	return uint64(n)
+}
+
 func (b *TemplateBuilder) RestrictMinTime(t time.Time) {
 	if t.After(b.minTime) {
 		b.minTime = t
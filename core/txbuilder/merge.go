@@ -0,0 +1,138 @@
+package txbuilder
+
+import (
+	"bytes"
+
+	chainjson "github.com/chainmint/encoding/json"
+	"github.com/chainmint/errors"
+)
+
+// ErrTemplateMismatch is returned by Merge when the templates are
+// not of the same transaction, or do not ask for the same
+// signatures.
+var ErrTemplateMismatch = errors.New("templates do not match")
+
+// Merge combines templates of the same transaction, each signed by
+// some of the parties to it, so that parties holding different keys
+// may sign in parallel. It adds the signatures in the other
+// templates to the first, materializes the witnesses of its inputs,
+// and returns it. The merged template is local only if all of them
+// are.
+func Merge(tpls []*Template) (*Template, error) {
+	if len(tpls) == 0 {
+		return nil, errors.Wrap(ErrMissingRawTx)
+	}
+	merged := tpls[0]
+	if merged.Transaction == nil {
+		return nil, errors.Wrap(ErrMissingRawTx)
+	}
+	for n, tpl := range tpls[1:] {
+		err := mergeTemplate(merged, tpl)
+		if err != nil {
+			return nil, errors.WithDetailf(err, "template %d", n+1)
+		}
+	}
+
+	for _, sigInst := range merged.SigningInstructions {
+		for _, sw := range sigInst.SignatureWitnesses {
+			// The program signed is not carried in a template's
+			// JSON; it is inferred again, as when signing.
+			if len(sw.Program) == 0 && hasSig(sw.Sigs) {
+				sw.Program = buildSigProgram(merged, sigInst.Position)
+			}
+		}
+	}
+	err := materializeWitnesses(merged)
+	if err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// mergeTemplate adds the signatures in tpl to merged.
+func mergeTemplate(merged, tpl *Template) error {
+	if tpl.Transaction == nil {
+		return errors.Wrap(ErrMissingRawTx)
+	}
+	if tpl.Transaction.ID != merged.Transaction.ID {
+		return errors.WithDetail(ErrTemplateMismatch, "different transactions")
+	}
+	if tpl.AllowAdditional != merged.AllowAdditional {
+		return errors.WithDetail(ErrTemplateMismatch, "different allow_additional_actions")
+	}
+	if len(tpl.SigningInstructions) != len(merged.SigningInstructions) {
+		return errors.WithDetail(ErrTemplateMismatch, "different signing instructions")
+	}
+	merged.Local = merged.Local && tpl.Local
+
+	for i, sigInst := range tpl.SigningInstructions {
+		into := merged.SigningInstructions[i]
+		if sigInst.Position != into.Position || len(sigInst.SignatureWitnesses) != len(into.SignatureWitnesses) {
+			return errors.WithDetailf(ErrTemplateMismatch, "different signing instruction %d", i)
+		}
+		for j, sw := range sigInst.SignatureWitnesses {
+			err := mergeWitness(into.SignatureWitnesses[j], sw)
+			if err != nil {
+				return errors.WithDetailf(err, "witness component %d of input %d", j, sigInst.Position)
+			}
+		}
+	}
+	return nil
+}
+
+// mergeWitness adds the signatures in sw to merged, which must ask
+// for the same signatures.
+func mergeWitness(merged, sw *signatureWitness) error {
+	if sw.Quorum != merged.Quorum || len(sw.Keys) != len(merged.Keys) {
+		return errors.WithDetail(ErrTemplateMismatch, "different keys")
+	}
+	for i, k := range sw.Keys {
+		if k.XPub != merged.Keys[i].XPub || !samePath(k.DerivationPath, merged.Keys[i].DerivationPath) {
+			return errors.WithDetail(ErrTemplateMismatch, "different keys")
+		}
+	}
+	if len(sw.Program) > 0 && len(merged.Program) > 0 && !bytes.Equal(sw.Program, merged.Program) {
+		return errors.WithDetail(ErrTemplateMismatch, "different signature programs")
+	}
+	if len(merged.Program) == 0 {
+		merged.Program = sw.Program
+	}
+
+	if len(merged.Sigs) < len(merged.Keys) {
+		sigs := make([]chainjson.HexBytes, len(merged.Keys))
+		copy(sigs, merged.Sigs)
+		merged.Sigs = sigs
+	}
+	for i, sig := range sw.Sigs {
+		if i >= len(merged.Sigs) || len(sig) == 0 {
+			continue
+		}
+		if len(merged.Sigs[i]) == 0 {
+			merged.Sigs[i] = sig
+		} else if !bytes.Equal(merged.Sigs[i], sig) {
+			return errors.WithDetailf(ErrTemplateMismatch, "conflicting signatures by key %d", i)
+		}
+	}
+	return nil
+}
+
+func samePath(a, b []chainjson.HexBytes) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasSig(sigs []chainjson.HexBytes) bool {
+	for _, sig := range sigs {
+		if len(sig) > 0 {
+			return true
+		}
+	}
+	return false
+}
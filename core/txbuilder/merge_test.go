@@ -0,0 +1,93 @@
+package txbuilder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/chainmint/crypto/ed25519"
+	"github.com/chainmint/crypto/ed25519/chainkd"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/vmutil"
+)
+
+func TestMerge(t *testing.T) {
+	ctx := context.Background()
+	var xprvs []chainkd.XPrv
+	var xpubs []chainkd.XPub
+	for i := 0; i < 2; i++ {
+		xprv, xpub, err := chainkd.NewXKeys(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		xprvs, xpubs = append(xprvs, xprv), append(xpubs, xpub)
+	}
+	signFn := func(_ context.Context, xpub chainkd.XPub, path [][]byte, h [32]byte) ([]byte, error) {
+		for i, x := range xpubs {
+			if x == xpub {
+				return xprvs[i].Derive(path).Sign(h[:]), nil
+			}
+		}
+		return nil, errors.New("unknown key")
+	}
+
+	var initialBlockHash bc.Hash
+	issuanceProg, _ := vmutil.P2SPMultiSigProgram([]ed25519.PublicKey{xpubs[0].PublicKey()}, 1)
+	assetID := bc.ComputeAssetID(issuanceProg, &initialBlockHash, 1, &bc.EmptyStringHash)
+	newTemplate := func(amount uint64) *Template {
+		tpl := &Template{
+			Transaction: legacy.NewTx(legacy.TxData{
+				Version: 1,
+				Inputs: []*legacy.TxInput{
+					legacy.NewIssuanceInput([]byte{1}, amount, nil, initialBlockHash, issuanceProg, nil, nil),
+				},
+				Outputs: []*legacy.TxOutput{
+					legacy.NewTxOutput(assetID, amount, []byte{0x51}, nil),
+				},
+			}),
+			SigningInstructions: []*SigningInstruction{{}},
+		}
+		tpl.SigningInstructions[0].AddWitnessKeys(xpubs, nil, 2)
+
+		// Each party gets its own copy, as it would over the API.
+		b, err := json.Marshal(tpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tpl = new(Template)
+		err = json.Unmarshal(b, tpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tpl
+	}
+
+	// Each party signs with its own key.
+	a, b := newTemplate(5), newTemplate(5)
+	if err := Sign(ctx, a, xpubs[:1], signFn); err != nil {
+		t.Fatal(err)
+	}
+	if err := Sign(ctx, b, xpubs[1:], signFn); err != nil {
+		t.Fatal(err)
+	}
+	sigA := a.SigningInstructions[0].SignatureWitnesses[0].Sigs[0]
+	sigB := b.SigningInstructions[0].SignatureWitnesses[0].Sigs[1]
+
+	merged, err := Merge([]*Template{a, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := merged.Transaction.Inputs[0].Arguments()
+	if len(args) != 4 || !bytes.Equal(args[1], sigA) || !bytes.Equal(args[2], sigB) {
+		t.Errorf("merged witness = %x, want signatures %x and %x", args, sigA, sigB)
+	}
+
+	// Templates of different transactions do not merge.
+	_, err = Merge([]*Template{newTemplate(5), newTemplate(6)})
+	if errors.Root(err) != ErrTemplateMismatch {
+		t.Errorf("Merge of different txs error = %v want %v", err, ErrTemplateMismatch)
+	}
+}
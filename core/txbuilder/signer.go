@@ -0,0 +1,22 @@
+package txbuilder
+
+import (
+	"context"
+
+	"github.com/chainmint/crypto/ed25519/chainkd"
+)
+
+// Signer holds private keys for signing transactions, such as an HSM.
+// XSign signs msg with the private key derived by path from the key
+// of xpub. A Signer that does not hold the key of xpub returns a nil
+// signature and no error, so the key may be left to another signer.
+type Signer interface {
+	XSign(ctx context.Context, xpub chainkd.XPub, path [][]byte, msg []byte) ([]byte, error)
+}
+
+// SignWith returns a SignFunc that signs with s.
+func SignWith(s Signer) SignFunc {
+	return func(ctx context.Context, xpub chainkd.XPub, path [][]byte, data [32]byte) ([]byte, error) {
+		return s.XSign(ctx, xpub, path, data[:])
+	}
+}
@@ -0,0 +1,138 @@
+package txbuilder
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/math/checked"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+// ErrUnbalanced is returned by Combine when the parties' templates
+// do not together balance: some asset is spent but not received,
+// or received but not spent.
+var ErrUnbalanced = errors.New("combined transaction is unbalanced")
+
+// Combine joins partial templates built separately by the parties to
+// an exchange, such as an atomic swap of different assets, into one
+// transaction. Each template holds one party's side: what it spends
+// and what it must receive in return. The inputs and outputs of the
+// templates are concatenated, so every output a party asked for is
+// in the combined transaction, and the combined transaction must
+// spend exactly what it pays out, asset by asset.
+//
+// The templates must not yet be signed, since signatures commit to
+// the transaction they were made for. The combined template is
+// complete; each party signs it once it has checked its side.
+func Combine(tpls []*Template) (*Template, error) {
+	if len(tpls) == 0 {
+		return nil, errors.Wrap(ErrMissingRawTx)
+	}
+	combined := &legacy.TxData{Version: 1}
+	res := &Template{Local: true}
+	spent := make(map[bc.Hash]int)
+	for n, tpl := range tpls {
+		if tpl.Transaction == nil {
+			return nil, errors.WithDetailf(ErrMissingRawTx, "template %d", n)
+		}
+		tx := tpl.Transaction.TxData
+		if tx.Version != combined.Version {
+			return nil, errors.WithDetailf(ErrTemplateMismatch, "template %d has transaction version %d", n, tx.Version)
+		}
+		if len(tx.ReferenceData) > 0 {
+			if len(combined.ReferenceData) > 0 && !bytes.Equal(tx.ReferenceData, combined.ReferenceData) {
+				return nil, errors.WithDetailf(ErrBadRefData, "template %d", n)
+			}
+			combined.ReferenceData = tx.ReferenceData
+		}
+		if tx.MinTime > combined.MinTime {
+			combined.MinTime = tx.MinTime
+		}
+		if tx.MaxTime > 0 && (combined.MaxTime == 0 || tx.MaxTime < combined.MaxTime) {
+			combined.MaxTime = tx.MaxTime
+		}
+
+		offset := uint32(len(combined.Inputs))
+		for i, in := range tx.Inputs {
+			id, err := in.SpentOutputID()
+			if err != nil {
+				return nil, errors.Wrapf(err, "template %d input %d", n, i)
+			}
+			if id != (bc.Hash{}) {
+				if prev, ok := spent[id]; ok {
+					return nil, errors.WithDetailf(ErrTemplateMismatch, "templates %d and %d spend the same output", prev, n)
+				}
+				spent[id] = n
+			}
+		}
+		for i, sigInst := range tpl.SigningInstructions {
+			for _, sw := range sigInst.SignatureWitnesses {
+				if hasSig(sw.Sigs) {
+					return nil, errors.WithDetailf(ErrTemplateMismatch, "template %d is signed; combine templates before signing them", n)
+				}
+			}
+			if int(sigInst.Position) >= len(tx.Inputs) {
+				return nil, errors.WithDetailf(ErrBadTxInputIdx, "template %d signing instruction %d references missing tx input %d", n, i, sigInst.Position)
+			}
+			res.SigningInstructions = append(res.SigningInstructions, &SigningInstruction{
+				Position:           offset + sigInst.Position,
+				SignatureWitnesses: sigInst.SignatureWitnesses,
+			})
+		}
+		combined.Inputs = append(combined.Inputs, tx.Inputs...)
+		combined.Outputs = append(combined.Outputs, tx.Outputs...)
+		res.Local = res.Local && tpl.Local
+	}
+	if combined.MaxTime > 0 && combined.MinTime > combined.MaxTime {
+		return nil, errors.WithDetail(ErrTemplateMismatch, "the templates' time ranges do not overlap")
+	}
+
+	err := checkBalanced(combined)
+	if err != nil {
+		return nil, err
+	}
+	res.Transaction = legacy.NewTx(*combined)
+	if res.SigningInstructions == nil {
+		res.SigningInstructions = []*SigningInstruction{}
+	}
+	return res, nil
+}
+
+// checkBalanced checks that tx pays out exactly the amount of each
+// asset it spends or issues.
+func checkBalanced(tx *legacy.TxData) error {
+	net := make(map[bc.AssetID]int64)
+	var ok bool
+	for _, in := range tx.Inputs {
+		asset := in.AssetID()
+		net[asset], ok = checked.AddInt64(net[asset], int64(in.Amount()))
+		if !ok {
+			return errors.WithDetailf(ErrBadAmount, "cumulative amounts for asset %x overflow the allowed asset amount 2^63", asset.Bytes())
+		}
+	}
+	for _, out := range tx.Outputs {
+		net[*out.AssetId], ok = checked.SubInt64(net[*out.AssetId], int64(out.Amount))
+		if !ok {
+			return errors.WithDetailf(ErrBadAmount, "cumulative amounts for asset %x overflow the allowed asset amount 2^63", out.AssetId.Bytes())
+		}
+	}
+
+	var unbalanced []string
+	for asset, amt := range net {
+		switch {
+		case amt > 0:
+			unbalanced = append(unbalanced, fmt.Sprintf("%d of asset %x spent but not received", amt, asset.Bytes()))
+		case amt < 0:
+			unbalanced = append(unbalanced, fmt.Sprintf("%d of asset %x received but not spent", -amt, asset.Bytes()))
+		}
+	}
+	if len(unbalanced) > 0 {
+		sort.Strings(unbalanced)
+		return errors.WithDetail(ErrUnbalanced, strings.Join(unbalanced, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package txbuilder
+
+import (
+	"testing"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+func TestCombine(t *testing.T) {
+	gold := bc.NewAssetID([32]byte{1})
+	silver := bc.NewAssetID([32]byte{2})
+	side := func(source byte, give bc.AssetID, giveAmt uint64, get bc.AssetID, getAmt uint64, maxTime uint64) *Template {
+		tx := legacy.NewTx(legacy.TxData{
+			Version: 1,
+			MaxTime: maxTime,
+			Inputs: []*legacy.TxInput{
+				legacy.NewSpendInput(nil, bc.NewHash([32]byte{source}), give, giveAmt, 0, []byte{0x51}, bc.Hash{}, nil),
+			},
+			Outputs: []*legacy.TxOutput{
+				legacy.NewTxOutput(get, getAmt, []byte{source}, nil),
+			},
+		})
+		sigInst := &SigningInstruction{Position: 0}
+		sigInst.AddWitnessKeys(nil, nil, 1)
+		return &Template{Transaction: tx, SigningInstructions: []*SigningInstruction{sigInst}, Local: true}
+	}
+
+	// Alice gives 5 gold for 10 silver; Bob gives 10 silver for 5 gold.
+	alice := side(1, gold, 5, silver, 10, 2000)
+	bob := side(2, silver, 10, gold, 5, 1000)
+	tpl, err := Combine([]*Template{alice, bob})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := tpl.Transaction
+	if len(tx.Inputs) != 2 || len(tx.Outputs) != 2 || tx.MaxTime != 1000 {
+		t.Fatalf("combined tx = %+v", tx.TxData)
+	}
+	if len(tpl.SigningInstructions) != 2 || tpl.SigningInstructions[1].Position != 1 || !tpl.Local || tpl.AllowAdditional {
+		t.Errorf("combined template = %+v", tpl)
+	}
+	if tx.ID != legacy.NewTx(tx.TxData).ID {
+		t.Error("combined tx ID does not match its contents")
+	}
+
+	// Bob asking for more gold than Alice gives is unbalanced.
+	_, err = Combine([]*Template{alice, side(2, silver, 10, gold, 6, 0)})
+	if errors.Root(err) != ErrUnbalanced {
+		t.Errorf("Combine of unbalanced sides error = %v want %v", err, ErrUnbalanced)
+	}
+
+	// Both sides spending the same output conflict.
+	_, err = Combine([]*Template{alice, side(1, gold, 5, silver, 10, 0)})
+	if errors.Root(err) != ErrTemplateMismatch {
+		t.Errorf("Combine of conflicting spends error = %v want %v", err, ErrTemplateMismatch)
+	}
+}
@@ -21,6 +21,7 @@ var (
 	ErrBlankCheck          = errors.New("unsafe transaction: leaves assets free to control")
 	ErrAction              = errors.New("errors occurred in one or more actions")
 	ErrMissingFields       = errors.New("required field is missing")
+	ErrBadEscrow           = errors.New("invalid escrow")
 )
 
 // Build builds or adds on to a transaction.
@@ -37,6 +37,12 @@ func materializeWitnesses(txTemplate *Template) error {
 			return errors.WithDetailf(ErrBadTxInputIdx, "signing instruction %d references missing tx input %d", i, sigInst.Position)
 		}
 
+		// Inputs without witness components, such as escrow spends,
+		// keep the arguments they were built with.
+		if len(sigInst.SignatureWitnesses) == 0 {
+			continue
+		}
+
 		var witness [][]byte
 		for j, sw := range sigInst.SignatureWitnesses {
 			err := sw.materialize(txTemplate, sigInst.Position, &witness)
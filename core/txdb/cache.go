@@ -66,3 +66,23 @@ func (c *blockCache) add(block *legacy.Block) {
 	c.lru.Add(block.Height, block)
 	c.mu.Unlock()
 }
+
+// setSize sets the number of blocks cached, evicting the least
+// recently used if there are more.
+func (c *blockCache) setSize(n int) {
+	if n < 1 {
+		n = 1
+	}
+	c.mu.Lock()
+	c.lru.MaxEntries = n
+	for c.lru.Len() > n {
+		c.lru.RemoveOldest()
+	}
+	c.mu.Unlock()
+}
+
+func (c *blockCache) remove(height uint64) {
+	c.mu.Lock()
+	c.lru.Remove(height)
+	c.mu.Unlock()
+}
@@ -0,0 +1,65 @@
+package txdb
+
+import (
+	"context"
+
+	"github.com/chainmint/errors"
+)
+
+// PruneBlocks deletes the blocks below height, other than the initial
+// block and, if keepEvery is nonzero, the blocks at multiples of it.
+// It records height, so that PrunedBelow reports it from then on,
+// and returns the number of blocks deleted.
+func (s *Store) PruneBlocks(ctx context.Context, height, keepEvery uint64) (int64, error) {
+	const q = `
+		DELETE FROM blocks
+		WHERE height > 1 AND height < $1 AND ($2 = 0 OR height % $2 <> 0)
+	`
+	res, err := s.db.Exec(ctx, q, height, keepEvery)
+	if err != nil {
+		return 0, errors.Wrap(err, "deleting blocks")
+	}
+	const floorQ = `
+		INSERT INTO block_pruning (below) VALUES ($1)
+		ON CONFLICT (singleton) DO UPDATE SET below = GREATEST(block_pruning.below, $1)
+	`
+	_, err = s.db.Exec(ctx, floorQ, height)
+	if err != nil {
+		return 0, errors.Wrap(err, "recording pruned height")
+	}
+	n, err := res.RowsAffected()
+	return n, errors.Wrap(err)
+}
+
+// PrunedBelow returns the height below which blocks may have been
+// deleted by PruneBlocks, or 0 if none have been.
+func (s *Store) PrunedBelow(ctx context.Context) (uint64, error) {
+	const q = `SELECT COALESCE((SELECT below FROM block_pruning), 0)`
+	var height uint64
+	err := s.db.QueryRow(ctx, q).Scan(&height)
+	return height, errors.Wrap(err, "reading pruned height")
+}
+
+// PruneSnapshots deletes the state snapshots below height by the same
+// rule as PruneBlocks, so each snapshot kept has its block, and
+// returns the number deleted.
+func (s *Store) PruneSnapshots(ctx context.Context, height, keepEvery uint64) (int64, error) {
+	if s.files != nil {
+		return s.files.pruneBelow(ctx, height, keepEvery)
+	}
+	const q = `DELETE FROM snapshots WHERE height < $1 AND ($2 = 0 OR height % $2 <> 0)`
+	res, err := s.db.Exec(ctx, q, height, keepEvery)
+	if err != nil {
+		return 0, errors.Wrap(err, "deleting snapshots")
+	}
+	n, err := res.RowsAffected()
+	return n, errors.Wrap(err)
+}
+
+func (f *snapshotFiles) pruneBelow(ctx context.Context, height, keepEvery uint64) (int64, error) {
+	const q = `
+		DELETE FROM snapshot_files WHERE height < $1 AND ($2 = 0 OR height % $2 <> 0)
+		RETURNING height
+	`
+	return f.remove(ctx, q, height, keepEvery)
+}
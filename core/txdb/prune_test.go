@@ -0,0 +1,73 @@
+package txdb
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/chainmint/database/pg"
+	"github.com/chainmint/database/pg/pgtest"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+func TestPruneBlocks(t *testing.T) {
+	ctx := context.Background()
+	dbtx := pgtest.NewTx(t)
+	store := NewStore(dbtx)
+
+	root, err := bc.MerkleRoot(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var prev bc.Hash
+	for h := uint64(1); h <= 10; h++ {
+		b := &legacy.Block{
+			BlockHeader: legacy.BlockHeader{
+				Version:           1,
+				Height:            h,
+				PreviousBlockHash: prev,
+				TimestampMS:       100 * h,
+				BlockCommitment:   legacy.BlockCommitment{TransactionsMerkleRoot: root},
+			},
+		}
+		err = store.SaveBlock(ctx, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prev = b.Hash()
+	}
+
+	n, err := store.PruneBlocks(ctx, 7, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("PruneBlocks deleted %d blocks, want 3", n)
+	}
+	var heights []uint64
+	err = pg.ForQueryRows(ctx, dbtx, `SELECT height FROM blocks ORDER BY height`, func(h uint64) {
+		heights = append(heights, h)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uint64{1, 3, 6, 7, 8, 9, 10}
+	if !reflect.DeepEqual(heights, want) {
+		t.Errorf("after pruning, heights = %v want %v", heights, want)
+	}
+
+	below, err := store.PrunedBelow(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if below != 7 {
+		t.Errorf("PrunedBelow() = %d want 7", below)
+	}
+
+	// A kept checkpoint still verifies, though its predecessor is gone.
+	_, err = store.VerifyBlock(ctx, 3)
+	if err != nil {
+		t.Errorf("VerifyBlock(3) = %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+package txdb
+
+import (
+	"context"
+
+	"github.com/chainmint/errors"
+)
+
+// RollbackTo deletes the blocks and state snapshots above height,
+// so that the store holds the chain as it was at height. Snapshots
+// are deleted first, so that an interrupted rollback never leaves a
+// snapshot above the last block.
+func (s *Store) RollbackTo(ctx context.Context, height uint64) error {
+	tip, err := s.Height(ctx)
+	if err != nil {
+		return err
+	}
+	if s.files != nil {
+		_, err = s.files.remove(ctx, `DELETE FROM snapshot_files WHERE height > $1 RETURNING height`, height)
+	} else {
+		_, err = s.db.Exec(ctx, `DELETE FROM snapshots WHERE height > $1`, height)
+	}
+	if err != nil {
+		return errors.Wrap(err, "deleting snapshots")
+	}
+	_, err = s.db.Exec(ctx, `DELETE FROM blocks WHERE height > $1`, height)
+	if err != nil {
+		return errors.Wrap(err, "deleting blocks")
+	}
+	for h := height + 1; h <= tip; h++ {
+		s.cache.remove(h)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package txdb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chainmint/database/pg/pgtest"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+func TestRollbackTo(t *testing.T) {
+	ctx := context.Background()
+	dbtx := pgtest.NewTx(t)
+	store := NewStore(dbtx)
+
+	root, err := bc.MerkleRoot(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var prev bc.Hash
+	for h := uint64(1); h <= 5; h++ {
+		b := &legacy.Block{
+			BlockHeader: legacy.BlockHeader{
+				Version:           1,
+				Height:            h,
+				PreviousBlockHash: prev,
+				TimestampMS:       100 * h,
+				BlockCommitment:   legacy.BlockCommitment{TransactionsMerkleRoot: root},
+			},
+		}
+		err = store.SaveBlock(ctx, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prev = b.Hash()
+	}
+
+	err = store.RollbackTo(ctx, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	height, err := store.Height(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if height != 3 {
+		t.Errorf("after rollback, Height() = %d want 3", height)
+	}
+	_, err = store.GetBlock(ctx, 4)
+	if err == nil {
+		t.Error("GetBlock(4) succeeded after rollback, want error")
+	}
+}
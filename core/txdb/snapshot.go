@@ -1,7 +1,11 @@
 package txdb
 
 import (
+	"bytes"
 	"context"
+	"math"
+	"sort"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 
@@ -9,6 +13,7 @@ import (
 	"github.com/chainmint/database/pg"
 	"github.com/chainmint/database/sql"
 	"github.com/chainmint/errors"
+	"github.com/chainmint/metrics"
 	"github.com/chainmint/protocol/bc"
 	"github.com/chainmint/protocol/patricia"
 	"github.com/chainmint/protocol/state"
@@ -45,7 +50,11 @@ func DecodeSnapshot(data []byte) (*state.Snapshot, error) {
 	}, nil
 }
 
-func storeStateSnapshot(ctx context.Context, db pg.DB, snapshot *state.Snapshot, blockHeight uint64) error {
+// EncodeSnapshot encodes a snapshot in the Chain Core's binary,
+// protobuf representation. The encoding is deterministic: equal
+// snapshots encode to the same bytes, so nodes that save the state
+// at the same height can compare the hashes of their snapshots.
+func EncodeSnapshot(snapshot *state.Snapshot) ([]byte, error) {
 	var storedSnapshot storage.Snapshot
 	err := patricia.Walk(snapshot.Tree, func(key []byte) error {
 		n := &storage.Snapshot_StateTreeNode{Key: key}
@@ -53,7 +62,7 @@ func storeStateSnapshot(ctx context.Context, db pg.DB, snapshot *state.Snapshot,
 		return nil
 	})
 	if err != nil {
-		return errors.Wrap(err, "walking patricia tree")
+		return nil, errors.Wrap(err, "walking patricia tree")
 	}
 
 	storedSnapshot.Nonces = make([]*storage.Snapshot_Nonce, 0, len(snapshot.Nonces))
@@ -64,10 +73,23 @@ func storeStateSnapshot(ctx context.Context, db pg.DB, snapshot *state.Snapshot,
 			ExpiryMs: v,
 		})
 	}
+	sort.Slice(storedSnapshot.Nonces, func(i, j int) bool {
+		return bytes.Compare(storedSnapshot.Nonces[i].Hash, storedSnapshot.Nonces[j].Hash) < 0
+	})
 
 	b, err := proto.Marshal(&storedSnapshot)
+	return b, errors.Wrap(err, "marshaling state snapshot")
+}
+
+var snapshotBytes = metrics.NewGauge("chainmint_snapshot_bytes", "Encoded size of the state snapshot saved last.")
+
+// storeStateSnapshot saves snapshot as the state at blockHeight and,
+// if maxAge is nonzero, deletes the snapshots saved more than maxAge
+// ago.
+func storeStateSnapshot(ctx context.Context, db pg.DB, snapshot *state.Snapshot, blockHeight uint64, maxAge time.Duration) error {
+	b, err := EncodeSnapshot(snapshot)
 	if err != nil {
-		return errors.Wrap(err, "marshaling state snapshot")
+		return err
 	}
 
 	const insertQ = `
@@ -78,22 +100,32 @@ func storeStateSnapshot(ctx context.Context, db pg.DB, snapshot *state.Snapshot,
 	if err != nil {
 		return errors.Wrap(err, "writing state snapshot to database")
 	}
+	snapshotBytes.Set(float64(len(b)))
 
-	const deleteQ = `DELETE FROM snapshots WHERE created_at < NOW() - INTERVAL '24 hours'`
-	_, err = db.Exec(ctx, deleteQ)
+	if maxAge == 0 {
+		return nil
+	}
+	const deleteQ = `DELETE FROM snapshots WHERE created_at < NOW() - $1 * INTERVAL '1 millisecond'`
+	_, err = db.Exec(ctx, deleteQ, int64(maxAge/time.Millisecond))
 	return errors.Wrap(err, "deleting old snapshots")
 }
 
 func getStateSnapshot(ctx context.Context, db pg.DB) (*state.Snapshot, uint64, error) {
+	return getStateSnapshotAtOrBelow(ctx, db, math.MaxInt64)
+}
+
+// getStateSnapshotAtOrBelow returns the most recent state snapshot
+// at or below maxHeight, and its height.
+func getStateSnapshotAtOrBelow(ctx context.Context, db pg.DB, maxHeight uint64) (*state.Snapshot, uint64, error) {
 	const q = `
-		SELECT data, height FROM snapshots ORDER BY height DESC LIMIT 1
+		SELECT data, height FROM snapshots WHERE height <= $1 ORDER BY height DESC LIMIT 1
 	`
 	var (
 		data   []byte
 		height uint64
 	)
 
-	err := db.QueryRow(ctx, q).Scan(&data, &height)
+	err := db.QueryRow(ctx, q, maxHeight).Scan(&data, &height)
 	if err == sql.ErrNoRows {
 		return state.Empty(), 0, nil
 	} else if err != nil {
@@ -1,11 +1,15 @@
 package txdb
 
 import (
+	"bytes"
 	"context"
+	"io/ioutil"
 	"math/rand"
+	"os"
 	"testing"
 
 	"github.com/chainmint/database/pg/pgtest"
+	"github.com/chainmint/errors"
 	"github.com/chainmint/protocol/bc"
 	"github.com/chainmint/protocol/state"
 	"github.com/chainmint/testutil"
@@ -18,7 +22,7 @@ func TestReadWriteStateSnapshotNonceSet(t *testing.T) {
 	snapshot.Nonces[bc.NewHash([32]byte{0x01})] = 10
 	snapshot.Nonces[bc.NewHash([32]byte{0x02})] = 10
 	snapshot.Nonces[bc.NewHash([32]byte{0x03})] = 45
-	err := storeStateSnapshot(ctx, dbtx, snapshot, 200)
+	err := storeStateSnapshot(ctx, dbtx, snapshot, 200, DefaultSnapshotMaxAge)
 	if err != nil {
 		t.Fatalf("Error writing state snapshot to db: %s\n", err)
 	}
@@ -93,7 +97,7 @@ func TestReadWriteStateSnapshot(t *testing.T) {
 			snapshot.Tree.Delete(key.Bytes())
 		}
 
-		err := storeStateSnapshot(ctx, dbtx, snapshot, uint64(i))
+		err := storeStateSnapshot(ctx, dbtx, snapshot, uint64(i), DefaultSnapshotMaxAge)
 		if err != nil {
 			t.Fatalf("Error writing state snapshot to db: %s\n", err)
 		}
@@ -122,6 +126,83 @@ func TestReadWriteStateSnapshot(t *testing.T) {
 	}
 }
 
+func TestEncodeSnapshotDeterministic(t *testing.T) {
+	snapshot := state.Empty()
+	for i := byte(0); i < 20; i++ {
+		err := snapshot.Tree.Insert(bc.NewHash([32]byte{i}).Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		snapshot.Nonces[bc.NewHash([32]byte{i, 1})] = uint64(i)
+	}
+	want, err := EncodeSnapshot(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		got, err := EncodeSnapshot(state.Copy(snapshot))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("encoding %d differs from the first", i)
+		}
+	}
+}
+
+func TestSnapshotFiles(t *testing.T) {
+	dbtx := pgtest.NewTx(t)
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := NewStore(dbtx)
+	err = store.UseSnapshotDir(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for height := uint64(1); height <= 3; height++ {
+		snapshot := state.Empty()
+		snapshot.Nonces[bc.NewHash([32]byte{byte(height)})] = height
+		err = store.SaveSnapshot(ctx, height, snapshot)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(store.files.path(1)); !os.IsNotExist(err) {
+		t.Errorf("oldest snapshot file not removed: %v", err)
+	}
+
+	got, height, err := store.LatestSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if height != 3 || got.Nonces[bc.NewHash([32]byte{3})] != 3 {
+		t.Fatalf("LatestSnapshot = %#v at %d, want the snapshot at 3", got.Nonces, height)
+	}
+
+	// A corrupt file is refused, and the state is loaded from the
+	// snapshot before it.
+	err = ioutil.WriteFile(store.files.path(3), []byte("corrupt"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.GetSnapshot(ctx, 3)
+	if errors.Root(err) != ErrSnapshotCorrupt {
+		t.Errorf("GetSnapshot(3) error = %v want %v", err, ErrSnapshotCorrupt)
+	}
+	_, height, err = store.LatestSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if height != 2 {
+		t.Errorf("LatestSnapshot with corrupt file at height %d, want 2", height)
+	}
+}
+
 func BenchmarkStoreSnapshot100(b *testing.B) {
 	benchmarkStoreSnapshot(100, 100, b)
 }
@@ -169,7 +250,7 @@ func benchmarkStoreSnapshot(nodes, nonces int, b *testing.B) {
 
 	b.StartTimer()
 	for i := 0; i < b.N; i++ {
-		err := storeStateSnapshot(ctx, db, snapshot, uint64(i))
+		err := storeStateSnapshot(ctx, db, snapshot, uint64(i), DefaultSnapshotMaxAge)
 		if err != nil {
 			b.Fatal(err)
 		}
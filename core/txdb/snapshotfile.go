@@ -0,0 +1,185 @@
+package txdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/chainmint/crypto/sha3pool"
+	"github.com/chainmint/database/pg"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/log"
+	"github.com/chainmint/protocol/state"
+)
+
+// ErrSnapshotCorrupt is returned when a snapshot file does not match
+// the hash recorded for it when it was written.
+var ErrSnapshotCorrupt = errors.New("snapshot file does not match its recorded hash")
+
+// snapshotFiles stores state snapshots as files in a directory,
+// recording the height, size and SHA3-256 hash of each in the
+// snapshot_files table. A file is checked against its hash whenever
+// it is read, so a truncated or corrupt file is never loaded.
+type snapshotFiles struct {
+	db   pg.DB
+	dir  string
+	keep int
+}
+
+// UseSnapshotDir configures s to store state snapshots as files in
+// dir, instead of in the database, keeping the keep most recent of
+// them. If keep is 0, all of them are kept.
+func (s *Store) UseSnapshotDir(dir string, keep int) error {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return errors.Wrap(err, "creating snapshot directory")
+	}
+	s.files = &snapshotFiles{db: s.db, dir: dir, keep: keep}
+	return nil
+}
+
+func (f *snapshotFiles) path(height uint64) string {
+	return filepath.Join(f.dir, fmt.Sprintf("%020d.snapshot", height))
+}
+
+// save writes the snapshot at height to its file and records its
+// hash, then removes the files beyond the most recent f.keep.
+func (f *snapshotFiles) save(ctx context.Context, height uint64, snapshot *state.Snapshot) error {
+	b, err := EncodeSnapshot(snapshot)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file and rename it into place, so a crash
+	// never leaves a partial file under a snapshot's name.
+	tmp, err := ioutil.TempFile(f.dir, "snapshot")
+	if err != nil {
+		return errors.Wrap(err, "creating snapshot file")
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(b)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrap(err, "writing snapshot file")
+	}
+	err = os.Rename(tmp.Name(), f.path(height))
+	if err != nil {
+		return errors.Wrap(err, "renaming snapshot file")
+	}
+
+	var hash [32]byte
+	sha3pool.Sum256(hash[:], b)
+	const insertQ = `
+		INSERT INTO snapshot_files (height, hash, size) VALUES ($1, $2, $3)
+		ON CONFLICT (height) DO UPDATE SET hash = $2, size = $3, created_at = NOW()
+	`
+	_, err = f.db.Exec(ctx, insertQ, height, hash[:], len(b))
+	if err != nil {
+		return errors.Wrap(err, "recording snapshot file hash")
+	}
+	snapshotBytes.Set(float64(len(b)))
+	return f.prune(ctx)
+}
+
+// prune removes all but the f.keep most recent snapshot files.
+func (f *snapshotFiles) prune(ctx context.Context) error {
+	if f.keep == 0 {
+		return nil
+	}
+	const q = `
+		DELETE FROM snapshot_files WHERE height IN (
+			SELECT height FROM snapshot_files ORDER BY height DESC OFFSET $1
+		) RETURNING height
+	`
+	_, err := f.remove(ctx, q, f.keep)
+	return err
+}
+
+// remove runs q, which deletes rows of snapshot_files and returns
+// their heights, and removes the files at those heights. It returns
+// the number of snapshots removed.
+func (f *snapshotFiles) remove(ctx context.Context, q string, args ...interface{}) (int64, error) {
+	var heights []uint64
+	err := pg.ForQueryRows(ctx, f.db, q, append(args, func(height uint64) {
+		heights = append(heights, height)
+	})...)
+	if err != nil {
+		return 0, errors.Wrap(err, "deleting snapshot file hashes")
+	}
+	for _, height := range heights {
+		err = os.Remove(f.path(height))
+		if err != nil && !os.IsNotExist(err) {
+			return 0, errors.Wrap(err, "removing snapshot file")
+		}
+	}
+	return int64(len(heights)), nil
+}
+
+// read returns the contents of the snapshot file at height, once
+// they have been checked against the recorded hash.
+func (f *snapshotFiles) read(ctx context.Context, height uint64) ([]byte, error) {
+	var want []byte
+	err := f.db.QueryRow(ctx, `SELECT hash FROM snapshot_files WHERE height = $1`, height).Scan(&want)
+	if err != nil {
+		return nil, errors.Wrap(err, "looking up snapshot file hash")
+	}
+	b, err := ioutil.ReadFile(f.path(height))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading snapshot file")
+	}
+	var hash [32]byte
+	sha3pool.Sum256(hash[:], b)
+	if !bytes.Equal(hash[:], want) {
+		return nil, errors.WithDetailf(ErrSnapshotCorrupt, "snapshot at height %d has hash %x, recorded %x", height, hash[:], want)
+	}
+	return b, nil
+}
+
+// atOrBelow returns the most recent snapshot at or below maxHeight
+// that can be read and decoded, and its height. It skips snapshots
+// whose files are missing or corrupt, so that the state is rebuilt
+// from an older one. If there is none, it returns an empty snapshot
+// and height 0.
+func (f *snapshotFiles) atOrBelow(ctx context.Context, maxHeight uint64) (*state.Snapshot, uint64, error) {
+	const q = `SELECT height FROM snapshot_files WHERE height <= $1 ORDER BY height DESC`
+	var heights []uint64
+	err := pg.ForQueryRows(ctx, f.db, q, maxHeight, func(height uint64) {
+		heights = append(heights, height)
+	})
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "listing snapshot files")
+	}
+	for _, height := range heights {
+		b, err := f.read(ctx, height)
+		if err == nil {
+			var snapshot *state.Snapshot
+			snapshot, err = DecodeSnapshot(b)
+			if err == nil {
+				return snapshot, height, nil
+			}
+		}
+		log.Error(ctx, err, "at", "loading snapshot file", "height", height)
+	}
+	return state.Empty(), 0, nil
+}
+
+// latestInfo returns the height and size of the most recent
+// snapshot file.
+func (f *snapshotFiles) latestInfo(ctx context.Context) (height, size uint64, err error) {
+	const q = `SELECT height, size FROM snapshot_files ORDER BY height DESC LIMIT 1`
+	err = f.db.QueryRow(ctx, q).Scan(&height, &size)
+	return height, size, err
+}
+
+func (f *snapshotFiles) latest(ctx context.Context) (*state.Snapshot, uint64, error) {
+	return f.atOrBelow(ctx, math.MaxInt64)
+}
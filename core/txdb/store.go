@@ -2,6 +2,8 @@ package txdb
 
 import (
 	"context"
+	"sync/atomic"
+	"time"
 
 	"github.com/chainmint/database/pg"
 	"github.com/chainmint/errors"
@@ -17,8 +19,21 @@ type Store struct {
 	db pg.DB
 
 	cache blockCache
+
+	// files, if set, stores state snapshots instead of the
+	// database; see UseSnapshotDir.
+	files *snapshotFiles
+
+	// snapshotMaxAge is how long, in nanoseconds, snapshots
+	// stored in the database are kept; see SetSnapshotMaxAge.
+	// It is accessed atomically.
+	snapshotMaxAge int64
 }
 
+// DefaultSnapshotMaxAge is how long snapshots stored in the database
+// are kept, unless changed with SetSnapshotMaxAge.
+const DefaultSnapshotMaxAge = 24 * time.Hour
+
 var _ protocol.Store = (*Store)(nil)
 
 // NewStore creates and returns a new Store object.
@@ -28,7 +43,8 @@ var _ protocol.Store = (*Store)(nil)
 // instead.
 func NewStore(db pg.DB) *Store {
 	return &Store{
-		db: db,
+		db:             db,
+		snapshotMaxAge: int64(DefaultSnapshotMaxAge),
 		cache: newBlockCache(func(height uint64) (*legacy.Block, error) {
 			const q = `SELECT data FROM blocks WHERE height = $1`
 			var b legacy.Block
@@ -41,6 +57,19 @@ func NewStore(db pg.DB) *Store {
 	}
 }
 
+// SetBlockCacheSize sets the number of recently used blocks s keeps
+// in memory.
+func (s *Store) SetBlockCacheSize(n int) {
+	s.cache.setSize(n)
+}
+
+// SetSnapshotMaxAge sets how long snapshots stored in the database
+// are kept once newer ones are saved. If d is zero, they are kept
+// until pruned. Snapshot files are kept as UseSnapshotDir says.
+func (s *Store) SetSnapshotMaxAge(d time.Duration) {
+	atomic.StoreInt64(&s.snapshotMaxAge, int64(d))
+}
+
 // Height returns the height of the blockchain.
 func (s *Store) Height(ctx context.Context) (uint64, error) {
 	const q = `SELECT COALESCE(MAX(height), 0) FROM blocks`
@@ -56,15 +85,51 @@ func (s *Store) GetBlock(ctx context.Context, height uint64) (*legacy.Block, err
 	return s.cache.lookup(height)
 }
 
-// LatestSnapshot returns the most recent state snapshot stored in
-// the database and its corresponding block height.
+var errMissingBlocks = errors.New("missing blocks")
+
+// GetBlockHeaders returns the headers of the blocks from height
+// from to height to, inclusive. It returns an error if any of the
+// blocks is not stored, as when it has been pruned.
+func (s *Store) GetBlockHeaders(ctx context.Context, from, to uint64) ([]*legacy.BlockHeader, error) {
+	const q = `SELECT header FROM blocks WHERE height >= $1 AND height <= $2 ORDER BY height`
+	var headers []*legacy.BlockHeader
+	err := pg.ForQueryRows(ctx, s.db, q, from, to, func(h legacy.BlockHeader) {
+		headers = append(headers, &h)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "block headers query")
+	}
+	if from <= to && uint64(len(headers)) != to-from+1 {
+		return nil, errors.WithDetailf(errMissingBlocks, "blocks %d to %d are not all stored", from, to)
+	}
+	return headers, nil
+}
+
+// LatestSnapshot returns the most recent state snapshot stored and
+// its corresponding block height.
 func (s *Store) LatestSnapshot(ctx context.Context) (*state.Snapshot, uint64, error) {
+	if s.files != nil {
+		return s.files.latest(ctx)
+	}
 	return getStateSnapshot(ctx, s.db)
 }
 
+// SnapshotAtOrBelow returns the most recent state snapshot stored
+// at or below height, and its block height. If
+// there is none, it returns an empty snapshot and height 0.
+func (s *Store) SnapshotAtOrBelow(ctx context.Context, height uint64) (*state.Snapshot, uint64, error) {
+	if s.files != nil {
+		return s.files.atOrBelow(ctx, height)
+	}
+	return getStateSnapshotAtOrBelow(ctx, s.db, height)
+}
+
 // LatestSnapshotInfo returns the height and size of the most recent
-// state snapshot stored in the database.
+// state snapshot stored.
 func (s *Store) LatestSnapshotInfo(ctx context.Context) (height uint64, size uint64, err error) {
+	if s.files != nil {
+		return s.files.latestInfo(ctx)
+	}
 	const q = `
 		SELECT height, octet_length(data) FROM snapshots ORDER BY height DESC LIMIT 1
 	`
@@ -76,6 +141,9 @@ func (s *Store) LatestSnapshotInfo(ctx context.Context) (height uint64, size uin
 // in Chain Core's binary protobuf representation. If no snapshot exists
 // at the provided height, an error is returned.
 func (s *Store) GetSnapshot(ctx context.Context, height uint64) ([]byte, error) {
+	if s.files != nil {
+		return s.files.read(ctx, height)
+	}
 	return getRawSnapshot(ctx, s.db, height)
 }
 
@@ -95,9 +163,14 @@ func (s *Store) SaveBlock(ctx context.Context, block *legacy.Block) error {
 	return nil
 }
 
-// SaveSnapshot saves a state snapshot to the database.
+// SaveSnapshot saves a state snapshot to the database, or to a file
+// if the store is configured with a snapshot directory.
 func (s *Store) SaveSnapshot(ctx context.Context, height uint64, snapshot *state.Snapshot) error {
-	err := storeStateSnapshot(ctx, s.db, snapshot, height)
+	if s.files != nil {
+		return errors.Wrap(s.files.save(ctx, height, snapshot), "saving state snapshot file")
+	}
+	maxAge := time.Duration(atomic.LoadInt64(&s.snapshotMaxAge))
+	err := storeStateSnapshot(ctx, s.db, snapshot, height, maxAge)
 	return errors.Wrap(err, "saving state tree")
 }
 
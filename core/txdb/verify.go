@@ -0,0 +1,106 @@
+package txdb
+
+import (
+	"context"
+
+	"github.com/chainmint/database/sql"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+// ErrCorrupt is returned by the verification methods of Store when
+// stored chain data does not match its own hashes or commitments.
+var ErrCorrupt = errors.New("stored chain data is corrupt")
+
+// VerifyBlock re-reads the block at height from the database,
+// bypassing the block cache, and checks it against its stored hash,
+// its header, its transactions merkle root and the block before it,
+// unless that block has been pruned. It returns the block as read.
+func (s *Store) VerifyBlock(ctx context.Context, height uint64) (*legacy.Block, error) {
+	const q = `SELECT block_hash, data, header FROM blocks WHERE height = $1`
+	var (
+		storedHash bc.Hash
+		b          legacy.Block
+		header     legacy.BlockHeader
+	)
+	err := s.db.QueryRow(ctx, q, height).Scan(&storedHash, &b, &header)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading block %d", height)
+	}
+	if b.Height != height {
+		return nil, errors.WithDetailf(ErrCorrupt, "block stored at height %d has height %d", height, b.Height)
+	}
+	if h := b.Hash(); h != storedHash {
+		return nil, errors.WithDetailf(ErrCorrupt, "block %d hashes to %x, stored as %x", height, h.Bytes(), storedHash.Bytes())
+	}
+	if h := header.Hash(); h != storedHash {
+		return nil, errors.WithDetailf(ErrCorrupt, "header of block %d hashes to %x, stored as %x", height, h.Bytes(), storedHash.Bytes())
+	}
+
+	txEntries := make([]*bc.Tx, 0, len(b.Transactions))
+	for _, tx := range b.Transactions {
+		txEntries = append(txEntries, tx.Tx)
+	}
+	root, err := bc.MerkleRoot(txEntries)
+	if err != nil {
+		return nil, errors.Wrapf(err, "computing transactions merkle root of block %d", height)
+	}
+	if root != b.TransactionsMerkleRoot {
+		return nil, errors.WithDetailf(ErrCorrupt, "transactions of block %d do not match its merkle root", height)
+	}
+
+	if height > 1 {
+		var prevHash bc.Hash
+		err = s.db.QueryRow(ctx, `SELECT block_hash FROM blocks WHERE height = $1`, height-1).Scan(&prevHash)
+		if err == sql.ErrNoRows {
+			// The block before may have been pruned.
+			below, pruneErr := s.PrunedBelow(ctx)
+			if pruneErr != nil {
+				return nil, pruneErr
+			}
+			if height-1 < below {
+				return &b, nil
+			}
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading hash of block %d", height-1)
+		}
+		if prevHash != b.PreviousBlockHash {
+			return nil, errors.WithDetailf(ErrCorrupt, "block %d does not follow the stored block %d", height, height-1)
+		}
+	}
+	return &b, nil
+}
+
+// VerifySnapshot re-reads the state snapshot at height and checks
+// its root hash against the assets merkle root of the block at the
+// same height.
+func (s *Store) VerifySnapshot(ctx context.Context, height uint64) error {
+	data, err := getRawSnapshot(ctx, s.db, height)
+	if err != nil {
+		return errors.Wrapf(err, "reading snapshot %d", height)
+	}
+	snapshot, err := DecodeSnapshot(data)
+	if err != nil {
+		return errors.Sub(ErrCorrupt, errors.Wrapf(err, "decoding snapshot %d", height))
+	}
+	var header legacy.BlockHeader
+	err = s.db.QueryRow(ctx, `SELECT header FROM blocks WHERE height = $1`, height).Scan(&header)
+	if err != nil {
+		return errors.Wrapf(err, "reading header of block %d", height)
+	}
+	if snapshot.Tree.RootHash() != header.AssetsMerkleRoot {
+		return errors.WithDetailf(ErrCorrupt, "snapshot %d does not match the assets merkle root of its block", height)
+	}
+	return nil
+}
+
+// RandomSnapshotHeight returns the height of a stored snapshot
+// chosen at random, or 0 if there are none.
+func (s *Store) RandomSnapshotHeight(ctx context.Context) (uint64, error) {
+	const q = `SELECT COALESCE((SELECT height FROM snapshots ORDER BY random() LIMIT 1), 0)`
+	var height uint64
+	err := s.db.QueryRow(ctx, q).Scan(&height)
+	return height, errors.Wrap(err, "choosing snapshot")
+}
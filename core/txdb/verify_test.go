@@ -0,0 +1,45 @@
+package txdb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chainmint/database/pg/pgtest"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+func TestVerifyBlock(t *testing.T) {
+	ctx := context.Background()
+	dbtx := pgtest.NewTx(t)
+	store := NewStore(dbtx)
+
+	root, err := bc.MerkleRoot(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &legacy.Block{
+		BlockHeader: legacy.BlockHeader{
+			Version:         1,
+			Height:          1,
+			TimestampMS:     100,
+			BlockCommitment: legacy.BlockCommitment{TransactionsMerkleRoot: root},
+		},
+	}
+	err = store.SaveBlock(ctx, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.VerifyBlock(ctx, 1)
+	if err != nil {
+		t.Fatalf("VerifyBlock(intact block) = %v", err)
+	}
+
+	// Flip the stored hash, as a bad disk might.
+	pgtest.Exec(ctx, dbtx, t, `UPDATE blocks SET block_hash = set_byte(block_hash, 0, get_byte(block_hash, 0) # 1)`)
+	_, err = store.VerifyBlock(ctx, 1)
+	if errors.Root(err) != ErrCorrupt {
+		t.Errorf("VerifyBlock(corrupt block) = %v, want %v", err, ErrCorrupt)
+	}
+}
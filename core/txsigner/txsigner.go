@@ -0,0 +1,102 @@
+// Package txsigner implements a transaction signer that calls out
+// to a remote HSM service, such as signerd, over mutual TLS, so that
+// issuance and account keys are never held by the Core itself.
+package txsigner
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/chainmint/core/rpc"
+	"github.com/chainmint/crypto/ed25519/chainkd"
+	"github.com/chainmint/encoding/json"
+	"github.com/chainmint/errors"
+	chainnet "github.com/chainmint/net"
+)
+
+// ErrBadSignature is returned when the remote signer returns a
+// signature that does not verify against the requested key.
+var ErrBadSignature = errors.New("remote signer returned an invalid signature")
+
+// TLSFiles names the PEM-encoded files a Remote authenticates with.
+// Cert and Key are the certificate and private key the Core presents
+// to the signer, and RootCAs the CAs that issue the signer's
+// certificate.
+type TLSFiles struct {
+	Cert    string
+	Key     string
+	RootCAs string
+}
+
+// TLSClient returns an HTTP client that calls the signer over mutual
+// TLS with the certificates in files. All of the files are required.
+func TLSClient(files TLSFiles) (*http.Client, error) {
+	if files.Cert == "" || files.Key == "" || files.RootCAs == "" {
+		return nil, errors.New("remote signer needs a certificate, key and root CAs")
+	}
+	cert, err := tls.LoadX509KeyPair(files.Cert, files.Key)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading signer client certificate")
+	}
+	pem, err := ioutil.ReadFile(files.RootCAs)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading signer root CAs")
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, errors.New("no certificates in signer root CAs")
+	}
+
+	config := chainnet.DefaultTLSConfig()
+	config.Certificates = []tls.Certificate{cert}
+	config.RootCAs = roots
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig:     config,
+			TLSHandshakeTimeout: 10 * time.Second,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		Timeout: 30 * time.Second,
+	}, nil
+}
+
+// Remote is a txbuilder.Signer that asks a remote HSM service to
+// sign. It checks each signature it is given against the key it
+// asked for, so a misconfigured signer cannot produce a transaction
+// that fails validation only once it is submitted.
+type Remote struct {
+	Client *rpc.Client
+}
+
+// XSign asks the remote signer to sign msg with the key derived by
+// path from xpub. If the signer does not hold the key, it returns
+// a nil signature and no error.
+func (r *Remote) XSign(ctx context.Context, xpub chainkd.XPub, path [][]byte, msg []byte) ([]byte, error) {
+	hexPath := make([]json.HexBytes, 0, len(path))
+	for _, p := range path {
+		hexPath = append(hexPath, p)
+	}
+	body := struct {
+		XPub    chainkd.XPub    `json:"xpub"`
+		Path    []json.HexBytes `json:"path"`
+		Message json.HexBytes   `json:"message"`
+	}{xpub, hexPath, msg}
+	var resp struct {
+		Signature json.HexBytes `json:"signature"`
+	}
+	err := r.Client.Call(ctx, "/sign-message", body, &resp)
+	if err != nil {
+		return nil, errors.Wrap(err, "calling remote signer")
+	}
+	if len(resp.Signature) == 0 {
+		return nil, nil
+	}
+	if !xpub.Derive(path).Verify(msg, resp.Signature) {
+		return nil, errors.WithDetailf(ErrBadSignature, "xpub %s", xpub)
+	}
+	return resp.Signature, nil
+}
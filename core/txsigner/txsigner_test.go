@@ -0,0 +1,76 @@
+package txsigner
+
+import (
+	"context"
+	stdjson "encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chainmint/core/rpc"
+	"github.com/chainmint/crypto/ed25519/chainkd"
+	"github.com/chainmint/encoding/json"
+	"github.com/chainmint/errors"
+)
+
+func TestRemoteXSign(t *testing.T) {
+	xprv, xpub, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, other, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var corrupt bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var in struct {
+			XPub    chainkd.XPub
+			Path    []json.HexBytes
+			Message json.HexBytes
+		}
+		err := stdjson.NewDecoder(req.Body).Decode(&in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resp struct {
+			Signature json.HexBytes `json:"signature"`
+		}
+		if in.XPub == xpub {
+			path := make([][]byte, 0, len(in.Path))
+			for _, p := range in.Path {
+				path = append(path, p)
+			}
+			resp.Signature = xprv.Derive(path).Sign(in.Message)
+			if corrupt {
+				resp.Signature[0] ^= 1
+			}
+		}
+		stdjson.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	r := &Remote{Client: &rpc.Client{BaseURL: srv.URL, Client: srv.Client()}}
+	path := [][]byte{{1}, {2, 3}}
+	msg := []byte("message")
+
+	sig, err := r.XSign(ctx, xpub, path, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !xpub.Derive(path).Verify(msg, sig) {
+		t.Error("signature does not verify")
+	}
+
+	sig, err = r.XSign(ctx, other, path, msg)
+	if err != nil || sig != nil {
+		t.Errorf("XSign with unknown key = %x, %v, want nil, nil", sig, err)
+	}
+
+	corrupt = true
+	_, err = r.XSign(ctx, xpub, path, msg)
+	if errors.Root(err) != ErrBadSignature {
+		t.Errorf("XSign with bad signature error = %v want %v", err, ErrBadSignature)
+	}
+}
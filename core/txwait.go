@@ -0,0 +1,89 @@
+package core
+
+import (
+	"context"
+	"encoding/hex"
+
+	"github.com/chainmint/core/pubsub"
+	"github.com/chainmint/core/query"
+	"github.com/chainmint/core/txbuilder"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+// committedTx is the result of a transaction submitted with
+// wait_until "committed": where in the chain it landed.
+type committedTx struct {
+	ID          string `json:"id"`
+	BlockHeight uint64 `json:"block_height"`
+	Position    uint32 `json:"position"`
+}
+
+// submitCommitted submits the transaction of tpl and waits for its
+// transaction event, returning the height and position of the block
+// it was committed in. Unlike waitForTxInBlock, it does not fetch
+// each new block; it subscribes to the events of the transactions
+// paying to or spending from its control programs, and to block
+// events to notice when the transaction's max time has passed.
+func (a *API) submitCommitted(ctx context.Context, tpl *txbuilder.Template) (*committedTx, error) {
+	tx := tpl.Transaction
+
+	// Subscribe before submitting, so as not to miss the event of
+	// a transaction committed at once.
+	sub := a.events.Subscribe(txFilter(tx), eventBuffer)
+	defer sub.Close()
+
+	err := a.finalizeTxWait(ctx, tpl, "none")
+	if err != nil {
+		return nil, err
+	}
+	return waitForTxEvent(ctx, sub, tx)
+}
+
+// txFilter returns a filter selecting the block events and the
+// transaction events concerning any of the control programs of tx.
+func txFilter(tx *legacy.Tx) pubsub.Filter {
+	f := pubsub.Filter{Types: []string{pubsub.TypeBlock, pubsub.TypeTransaction}}
+	for _, in := range tx.Inputs {
+		if prog := in.ControlProgram(); len(prog) > 0 {
+			f.ControlPrograms = append(f.ControlPrograms, hex.EncodeToString(prog))
+		}
+	}
+	for _, out := range tx.Outputs {
+		f.ControlPrograms = append(f.ControlPrograms, hex.EncodeToString(out.ControlProgram))
+	}
+	return f
+}
+
+// waitForTxEvent waits for the transaction event of tx on sub. It
+// returns txbuilder.ErrRejected once a block past the max time of tx
+// is committed without it, and errSubscriberBehind if sub falls too
+// far behind and is closed.
+func waitForTxEvent(ctx context.Context, sub *pubsub.Subscription, tx *legacy.Tx) (*committedTx, error) {
+	for {
+		var e *pubsub.Event
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case e = <-sub.C:
+		}
+		if e == nil {
+			return nil, errors.Wrap(errSubscriberBehind, "waiting for transaction event")
+		}
+		switch data := e.Data.(type) {
+		case *query.AnnotatedTx:
+			if data.ID == tx.ID {
+				return &committedTx{
+					ID:          tx.ID.String(),
+					BlockHeight: data.BlockHeight,
+					Position:    data.Position,
+				}, nil
+			}
+		case blockEvent:
+			if tx.MaxTime > 0 && tx.MaxTime < bc.Millis(data.Timestamp) {
+				return nil, errors.Wrap(txbuilder.ErrRejected, "transaction max time exceeded")
+			}
+		}
+	}
+}
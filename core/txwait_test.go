@@ -0,0 +1,58 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chainmint/core/pubsub"
+	"github.com/chainmint/core/query"
+	"github.com/chainmint/core/txbuilder"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+func TestWaitForTxEvent(t *testing.T) {
+	prog := []byte{0x51}
+	tx := &legacy.Tx{
+		TxData: legacy.TxData{
+			MaxTime: 2000,
+			Outputs: []*legacy.TxOutput{legacy.NewTxOutput(bc.AssetID{}, 1, prog, nil)},
+		},
+		Tx: &bc.Tx{ID: bc.NewHash([32]byte{1})},
+	}
+	other := &query.AnnotatedTx{ID: bc.NewHash([32]byte{2}), BlockHeight: 5}
+	mine := &query.AnnotatedTx{ID: tx.ID, BlockHeight: 6, Position: 3}
+	ctx := context.Background()
+
+	b := pubsub.NewBroker()
+	sub := b.Subscribe(txFilter(tx), eventBuffer)
+	b.Publish(&pubsub.Event{Type: pubsub.TypeBlock, Height: 5, Data: blockEvent{Timestamp: time.Unix(1, 0)}})
+	b.Publish(&pubsub.Event{Type: pubsub.TypeTransaction, Height: 5, Data: other, Topics: pubsub.Topics{ControlPrograms: []string{"51"}}})
+	b.Publish(&pubsub.Event{Type: pubsub.TypeTransaction, Height: 6, Data: mine, Topics: pubsub.Topics{ControlPrograms: []string{"51"}}})
+	got, err := waitForTxEvent(ctx, sub, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := committedTx{ID: tx.ID.String(), BlockHeight: 6, Position: 3}
+	if *got != want {
+		t.Errorf("waitForTxEvent = %+v, want %+v", *got, want)
+	}
+	sub.Close()
+
+	sub = b.Subscribe(txFilter(tx), eventBuffer)
+	b.Publish(&pubsub.Event{Type: pubsub.TypeBlock, Height: 7, Data: blockEvent{Timestamp: time.Unix(3, 0)}})
+	_, err = waitForTxEvent(ctx, sub, tx)
+	if errors.Root(err) != txbuilder.ErrRejected {
+		t.Errorf("waitForTxEvent(past max time) = %v, want %v", err, txbuilder.ErrRejected)
+	}
+	sub.Close()
+
+	sub = b.Subscribe(txFilter(tx), 0)
+	b.Publish(&pubsub.Event{Type: pubsub.TypeBlock, Height: 8, Data: blockEvent{Timestamp: time.Unix(1, 0)}})
+	_, err = waitForTxEvent(ctx, sub, tx)
+	if errors.Root(err) != errSubscriberBehind {
+		t.Errorf("waitForTxEvent(closed subscription) = %v, want %v", err, errSubscriberBehind)
+	}
+}
@@ -0,0 +1,258 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/lib/pq"
+
+	"github.com/chainmint/core/query"
+	"github.com/chainmint/crypto/sha3pool"
+	"github.com/chainmint/database/pg"
+	"github.com/chainmint/errors"
+	"github.com/chainmint/log"
+	"github.com/chainmint/net/http/httpjson"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/patricia"
+)
+
+// utxoBatchSize is how many outputs of the state tree are looked up
+// in the output index at once.
+const utxoBatchSize = 1000
+
+// utxoSetMagic begins a UTXO set export, followed by its format
+// version.
+var utxoSetMagic = []byte("CMUTXOS\x01")
+
+var (
+	errNoOutputIndex = errors.New("output index unavailable")
+	errUTXOSetStale  = errors.New("output index is behind the chain")
+)
+
+// utxo is an unspent output, as exported.
+type utxo struct {
+	outputID bc.Hash
+	assetID  bc.AssetID
+	amount   uint64
+	program  []byte
+}
+
+// exportUTXOSet streams every unspent output at the current block
+// height, or only those of the asset given as {"asset_id": <id>}, in
+// the canonical format written by writeUTXOSet. It needs the
+// transaction index, to find the contents of each output in the
+// state tree.
+//
+// A response that ends without its trailer is incomplete; the error
+// that ended it is logged.
+//
+// POST /export-utxo-set
+func (a *API) exportUTXOSet(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	var in struct {
+		AssetID *bc.AssetID `json:"asset_id"`
+	}
+	err := json.NewDecoder(req.Body).Decode(&in)
+	if err != nil && err != io.EOF {
+		errorFormatter.Write(ctx, rw, errors.WithDetail(httpjson.ErrBadRequest, err.Error()))
+		return
+	}
+	if !a.indexTxs || a.pinStore == nil {
+		errorFormatter.Write(ctx, rw, errors.WithDetail(errNoOutputIndex, "transaction indexing is disabled"))
+		return
+	}
+	b, snapshot := a.chain.State()
+	if b == nil {
+		errorFormatter.Write(ctx, rw, errors.WithDetail(errNoOutputIndex, "no blocks yet"))
+		return
+	}
+	if h, ok := a.pinStore.CurrentHeight(query.TxPinName); !ok || h < b.Height {
+		errorFormatter.Write(ctx, rw, errors.WithDetailf(errUTXOSetStale, "indexed through %d of %d", h, b.Height))
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/octet-stream")
+	bw := bufio.NewWriter(rw)
+	err = writeUTXOSet(ctx, bw, b, snapshot.Tree, in.AssetID, a.lookupUTXOs)
+	if err != nil {
+		log.Error(ctx, err, "exporting utxo set at height", b.Height)
+		return
+	}
+	bw.Flush()
+}
+
+// lookupUTXOs returns the indexed outputs with the given IDs.
+func (a *API) lookupUTXOs(ctx context.Context, ids [][]byte) (map[bc.Hash]*utxo, error) {
+	const q = `
+		SELECT output_id, asset_id, amount, control_program
+		FROM annotated_outputs WHERE output_id = ANY($1)
+	`
+	found := make(map[bc.Hash]*utxo, len(ids))
+	err := pg.ForQueryRows(ctx, a.db, q, pq.ByteaArray(ids), func(outputID bc.Hash, assetID bc.AssetID, amount uint64, program []byte) {
+		found[outputID] = &utxo{outputID, assetID, amount, program}
+	})
+	return found, errors.Wrap(err, "annotated outputs query")
+}
+
+// writeUTXOSet writes the unspent outputs in tree, the state after
+// block b, in a canonical binary format. If assetID is not nil, only
+// the outputs of that asset are written. lookup returns the contents
+// of the outputs with the given IDs.
+//
+// All integers are big-endian. The export is a header, the outputs
+// in order of output ID, and a trailer:
+//
+//	header:  "CMUTXOS" 0x01 | height (8) | block ID (32) | assets merkle root (32) | asset ID filter (32, zero for none)
+//	output:  0x01 | output ID (32) | asset ID (32) | amount (8) | program length (4) | control program
+//	trailer: 0x00 | output count (8) | UTXO merkle root (32)
+//
+// The assets merkle root is that committed to by block b. The UTXO
+// merkle root is the root of a binary Merkle tree, formed as for the
+// transactions of a block, whose leaves are the outputs written, each
+// encoded without its leading 0x01.
+func writeUTXOSet(ctx context.Context, w io.Writer, b *legacy.Block, tree *patricia.Tree, assetID *bc.AssetID, lookup func(context.Context, [][]byte) (map[bc.Hash]*utxo, error)) error {
+	var filter bc.AssetID
+	if assetID != nil {
+		filter = *assetID
+	}
+	var hdr []byte
+	hdr = append(hdr, utxoSetMagic...)
+	hdr = appendUint64(hdr, b.Height)
+	hdr = append(hdr, b.BlockHeader.Hash().Bytes()...)
+	hdr = append(hdr, b.AssetsMerkleRoot.Bytes()...)
+	hdr = append(hdr, filter.Bytes()...)
+	_, err := w.Write(hdr)
+	if err != nil {
+		return errors.Wrap(err, "writing header")
+	}
+
+	var (
+		acc   merkleAccumulator
+		count uint64
+		batch [][]byte
+		rec   []byte
+	)
+	flush := func() error {
+		found, err := lookup(ctx, batch)
+		if err != nil {
+			return err
+		}
+		for _, id := range batch {
+			u, ok := found[bc.NewHash(hashBytes(id))]
+			if !ok {
+				return errors.WithDetailf(errUTXOSetStale, "output %x not indexed", id)
+			}
+			if assetID != nil && u.assetID != *assetID {
+				continue
+			}
+			rec = append(rec[:0], 0x01)
+			rec = appendUTXO(rec, u)
+			_, err = w.Write(rec)
+			if err != nil {
+				return errors.Wrap(err, "writing output")
+			}
+			acc.addLeaf(rec[1:])
+			count++
+		}
+		batch = batch[:0]
+		return nil
+	}
+	err = patricia.Walk(tree, func(item []byte) error {
+		batch = append(batch, item)
+		if len(batch) < utxoBatchSize {
+			return nil
+		}
+		return flush()
+	})
+	if err == nil && len(batch) > 0 {
+		err = flush()
+	}
+	if err != nil {
+		return err
+	}
+
+	trailer := appendUint64([]byte{0x00}, count)
+	root := acc.root()
+	trailer = append(trailer, root.Bytes()...)
+	_, err = w.Write(trailer)
+	return errors.Wrap(err, "writing trailer")
+}
+
+func appendUTXO(buf []byte, u *utxo) []byte {
+	buf = append(buf, u.outputID.Bytes()...)
+	buf = append(buf, u.assetID.Bytes()...)
+	buf = appendUint64(buf, u.amount)
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], uint32(len(u.program)))
+	buf = append(buf, n[:]...)
+	return append(buf, u.program...)
+}
+
+func appendUint64(buf []byte, v uint64) []byte {
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], v)
+	return append(buf, n[:]...)
+}
+
+func hashBytes(b []byte) (h [32]byte) {
+	copy(h[:], b)
+	return h
+}
+
+// merkleAccumulator computes the root of a binary Merkle tree, formed
+// as bc.MerkleRoot forms the tree of a block's transactions, from
+// leaves added one at a time, without holding them all.
+type merkleAccumulator struct {
+	// subtrees are the roots of the complete subtrees formed so
+	// far, largest first, and the number of leaves under each.
+	subtrees []merkleSubtree
+}
+
+type merkleSubtree struct {
+	root   bc.Hash
+	leaves int
+}
+
+// addLeaf adds a leaf with the given contents to the right of the
+// tree.
+func (m *merkleAccumulator) addLeaf(data []byte) {
+	h := sha3pool.Get256()
+	defer sha3pool.Put256(h)
+	h.Write([]byte{0x00})
+	h.Write(data)
+	var leaf bc.Hash
+	leaf.ReadFrom(h)
+
+	m.subtrees = append(m.subtrees, merkleSubtree{leaf, 1})
+	for n := len(m.subtrees); n > 1 && m.subtrees[n-2].leaves == m.subtrees[n-1].leaves; n-- {
+		left, right := m.subtrees[n-2], m.subtrees[n-1]
+		m.subtrees = append(m.subtrees[:n-2], merkleSubtree{merkleInterior(left.root, right.root), left.leaves * 2})
+	}
+}
+
+// root returns the root of the tree of the leaves added so far.
+func (m *merkleAccumulator) root() bc.Hash {
+	if len(m.subtrees) == 0 {
+		return bc.EmptyStringHash
+	}
+	root := m.subtrees[len(m.subtrees)-1].root
+	for i := len(m.subtrees) - 2; i >= 0; i-- {
+		root = merkleInterior(m.subtrees[i].root, root)
+	}
+	return root
+}
+
+func merkleInterior(left, right bc.Hash) (root bc.Hash) {
+	h := sha3pool.Get256()
+	defer sha3pool.Put256(h)
+	h.Write([]byte{0x01})
+	left.WriteTo(h)
+	right.WriteTo(h)
+	root.ReadFrom(h)
+	return root
+}
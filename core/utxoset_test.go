@@ -0,0 +1,96 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/patricia"
+)
+
+func TestMerkleAccumulator(t *testing.T) {
+	for n := 0; n <= 9; n++ {
+		var (
+			acc merkleAccumulator
+			txs []*bc.Tx
+		)
+		for i := 0; i < n; i++ {
+			id := bc.NewHash([32]byte{byte(i + 1)})
+			acc.addLeaf(id.Bytes())
+			txs = append(txs, &bc.Tx{ID: id})
+		}
+		want, err := bc.MerkleRoot(txs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := acc.root(); got != want {
+			t.Errorf("%d leaves: root = %x want %x", n, got.Bytes(), want.Bytes())
+		}
+	}
+}
+
+func TestWriteUTXOSet(t *testing.T) {
+	ctx := context.Background()
+	assetA := bc.NewAssetID([32]byte{0xaa})
+	assetB := bc.NewAssetID([32]byte{0xbb})
+	utxos := map[bc.Hash]*utxo{}
+	tree := new(patricia.Tree)
+	for i, assetID := range []bc.AssetID{assetB, assetA, assetB} {
+		id := bc.NewHash([32]byte{byte(3 - i)}) // inserted out of order
+		utxos[id] = &utxo{id, assetID, uint64(10 * (i + 1)), []byte{byte(i)}}
+		err := tree.Insert(id.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	lookup := func(_ context.Context, ids [][]byte) (map[bc.Hash]*utxo, error) {
+		return utxos, nil
+	}
+	b := &legacy.Block{BlockHeader: legacy.BlockHeader{Height: 7}}
+
+	var buf bytes.Buffer
+	err := writeUTXOSet(ctx, &buf, b, tree, &assetB, lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.Bytes()
+	if !bytes.HasPrefix(out, utxoSetMagic) {
+		t.Fatalf("export starts %x, want magic", out[:8])
+	}
+	out = out[len(utxoSetMagic):]
+	if h := binary.BigEndian.Uint64(out); h != 7 {
+		t.Errorf("height = %d want 7", h)
+	}
+	if !bytes.Equal(out[8+64:8+96], assetB.Bytes()) {
+		t.Errorf("asset filter = %x want %x", out[8+64:8+96], assetB.Bytes())
+	}
+	out = out[8+96:]
+
+	// Only the outputs of assetB, in order of output ID.
+	var acc merkleAccumulator
+	for _, want := range []byte{1, 3} {
+		u := utxos[bc.NewHash([32]byte{want})]
+		rec := appendUTXO(nil, u)
+		if out[0] != 0x01 || !bytes.Equal(out[1:1+len(rec)], rec) {
+			t.Fatalf("output %d = %x want %x", want, out[1:1+len(rec)], rec)
+		}
+		acc.addLeaf(rec)
+		out = out[1+len(rec):]
+	}
+	root := acc.root()
+	trailer := append(appendUint64([]byte{0x00}, 2), root.Bytes()...)
+	if !bytes.Equal(out, trailer) {
+		t.Errorf("trailer = %x want %x", out, trailer)
+	}
+
+	// An output missing from the index fails the export.
+	delete(utxos, bc.NewHash([32]byte{2}))
+	err = writeUTXOSet(ctx, new(bytes.Buffer), b, tree, nil, lookup)
+	if errors.Root(err) != errUTXOSetStale {
+		t.Errorf("export with missing output = %v want %v", err, errUTXOSetStale)
+	}
+}
@@ -0,0 +1,55 @@
+package ca
+
+import (
+	"testing"
+
+	"github.com/chainmint/crypto/ed25519/ecmath"
+)
+
+func TestRangeProof(t *testing.T) {
+	msg := []byte("msg")
+	for _, amount := range []uint64{0, 1, 1000, 1<<64 - 1} {
+		f := RandomScalar()
+		c := Commit(amount, &f)
+		rp := CreateRangeProof(amount, &f, msg)
+		if !VerifyRangeProof(c, rp, msg) {
+			t.Errorf("range proof for %d did not verify", amount)
+		}
+
+		rp2, err := ParseRangeProof(rp.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !VerifyRangeProof(c, rp2, msg) {
+			t.Errorf("decoded range proof for %d did not verify", amount)
+		}
+		if VerifyRangeProof(c, rp, []byte("other")) {
+			t.Errorf("range proof for %d verified with the wrong message", amount)
+		}
+		other := Commit(amount+1, &f)
+		if VerifyRangeProof(other, rp, msg) {
+			t.Errorf("range proof for %d verified against the wrong commitment", amount)
+		}
+	}
+}
+
+func TestVerifyBalance(t *testing.T) {
+	fin1, fin2 := RandomScalar(), RandomScalar()
+	fout := RandomScalar()
+	ins := []Commitment{Commit(30, &fin1), Commit(12, &fin2)}
+	outs := []Commitment{Commit(50, &fout)}
+
+	var excess ecmath.Scalar
+	excess.Add(&fin1, &fin2)
+	excess.Sub(&excess, &fout)
+
+	if !VerifyBalance(ins, outs, 8, &excess) {
+		t.Error("balanced commitments did not verify")
+	}
+	if VerifyBalance(ins, outs, 9, &excess) {
+		t.Error("unbalanced commitments verified")
+	}
+	if VerifyBalance(ins, outs, 8, &fout) {
+		t.Error("commitments verified with the wrong excess")
+	}
+}
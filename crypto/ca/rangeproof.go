@@ -0,0 +1,155 @@
+package ca
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/chainmint/crypto/ed25519/ecmath"
+)
+
+// rangeBits is the number of bits a range proof covers; a proven
+// amount lies in [0, 2^64).
+const rangeBits = 64
+
+// RangeProofSize is the length of an encoded RangeProof.
+const RangeProofSize = rangeBits * (32 + 32 + 64)
+
+var errBadRangeProof = errors.New("malformed range proof")
+
+// RangeProof proves that a value commitment commits to an amount
+// that fits in 64 bits, without revealing the amount.
+//
+// The commitment is split into one commitment per bit,
+//
+//	C_i = b_i·2^i·H + f_i·G,  with ΣC_i = C,
+//
+// and each C_i carries a two-member ring signature showing that
+// the prover knows its blinding factor with respect to either C_i
+// or C_i − 2^i·H, i.e. that b_i is 0 or 1.
+type RangeProof struct {
+	Bits [rangeBits]Commitment
+	E0   [rangeBits]ecmath.Scalar
+	S    [rangeBits][2]ecmath.Scalar
+}
+
+// CreateRangeProof proves that c = Commit(amount, f) commits to a
+// 64-bit amount. The proof is bound to msg.
+func CreateRangeProof(amount uint64, f *ecmath.Scalar, msg []byte) *RangeProof {
+	rp := new(RangeProof)
+	c := Commit(amount, f)
+
+	var sumf ecmath.Scalar
+	for i := 0; i < rangeBits; i++ {
+		var fi ecmath.Scalar
+		if i < rangeBits-1 {
+			fi = RandomScalar()
+			sumf.Add(&sumf, &fi)
+		} else {
+			fi.Sub(f, &sumf)
+		}
+
+		bit := amount >> uint(i) & 1
+		rp.Bits[i] = Commit(bit<<uint(i), &fi)
+		keys := ringKeys(rp.Bits[i], i)
+
+		// Sign the ring at position bit, where the public key is
+		// fi·G.
+		j := int(bit)
+		o := 1 - j
+		k := RandomScalar()
+		var R ecmath.Point
+		R.ScMulBase(&k)
+		e := make([]ecmath.Scalar, 2)
+		e[o] = ringChallenge(msg, c, i, j, &R)
+		rp.S[i][o] = RandomScalar()
+		R.ScMulAdd(&keys[o], negate(&e[o]), &rp.S[i][o])
+		e[j] = ringChallenge(msg, c, i, o, &R)
+		rp.S[i][j].MulAdd(&e[j], &fi, &k)
+		rp.E0[i] = e[0]
+	}
+	return rp
+}
+
+// VerifyRangeProof reports whether rp proves that c commits to a
+// 64-bit amount, and is bound to msg.
+func VerifyRangeProof(c Commitment, rp *RangeProof, msg []byte) bool {
+	sum := ecmath.ZeroPoint
+	for i := 0; i < rangeBits; i++ {
+		p, ok := rp.Bits[i].point()
+		if !ok {
+			return false
+		}
+		sum.Add(&sum, p)
+
+		keys := ringKeys(rp.Bits[i], i)
+		e := rp.E0[i]
+		for t := 0; t < 2; t++ {
+			var R ecmath.Point
+			R.ScMulAdd(&keys[t], negate(&e), &rp.S[i][t])
+			e = ringChallenge(msg, c, i, t, &R)
+		}
+		if !e.Equal(&rp.E0[i]) {
+			return false
+		}
+	}
+	cp, ok := c.point()
+	return ok && sum.ConstTimeEqual(cp)
+}
+
+// Bytes returns the encoding of rp.
+func (rp *RangeProof) Bytes() []byte {
+	b := make([]byte, 0, RangeProofSize)
+	for i := 0; i < rangeBits; i++ {
+		b = append(b, rp.Bits[i][:]...)
+		b = append(b, rp.E0[i][:]...)
+		b = append(b, rp.S[i][0][:]...)
+		b = append(b, rp.S[i][1][:]...)
+	}
+	return b
+}
+
+// ParseRangeProof decodes a range proof encoded with Bytes.
+func ParseRangeProof(b []byte) (*RangeProof, error) {
+	if len(b) != RangeProofSize {
+		return nil, errBadRangeProof
+	}
+	rp := new(RangeProof)
+	for i := 0; i < rangeBits; i++ {
+		copy(rp.Bits[i][:], b[0:32])
+		copy(rp.E0[i][:], b[32:64])
+		copy(rp.S[i][0][:], b[64:96])
+		copy(rp.S[i][1][:], b[96:128])
+		b = b[128:]
+	}
+	return rp, nil
+}
+
+// ringKeys returns the two ring members for bit i: C_i, whose
+// discrete log is known when the bit is 0, and C_i − 2^i·H, whose
+// discrete log is known when the bit is 1.
+func ringKeys(ci Commitment, i int) [2]ecmath.Point {
+	var keys [2]ecmath.Point
+	p, ok := ci.point()
+	if !ok {
+		// An invalid point can't satisfy the ring equations; use
+		// the zero point so verification fails cleanly.
+		p = &ecmath.ZeroPoint
+	}
+	keys[0] = *p
+	pow := amountScalar(1 << uint(i))
+	var powH ecmath.Point
+	powH.ScMul(&H, &pow)
+	keys[1].Sub(p, &powH)
+	return keys
+}
+
+func ringChallenge(msg []byte, c Commitment, i, pos int, R *ecmath.Point) ecmath.Scalar {
+	var idx [2]byte
+	binary.LittleEndian.PutUint16(idx[:], uint16(i))
+	r := R.Encode()
+	return hashToScalar([]byte("ChainmintRangeProof"), msg, c[:], idx[:], []byte{byte(pos)}, r[:])
+}
+
+func negate(s *ecmath.Scalar) *ecmath.Scalar {
+	return new(ecmath.Scalar).Neg(s)
+}
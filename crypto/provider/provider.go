@@ -0,0 +1,118 @@
+// Package provider abstracts the hash and signature algorithms a
+// chain uses for its app hash and for the signatures the app checks,
+// such as governance votes.
+//
+// A chain picks its provider by name at genesis and keeps it for its
+// lifetime. Deployments that need other algorithms, for instance
+// SM3 and SM2, implement Provider in their own package and call
+// Register from its init function; the validation code is unchanged.
+package provider
+
+import (
+	"crypto/sha256"
+	"sort"
+	"sync"
+
+	"github.com/chainmint/crypto/ed25519"
+	"github.com/chainmint/crypto/sha3pool"
+	"github.com/chainmint/errors"
+)
+
+// Default is the name of the provider chains use unless configured
+// otherwise. It hashes with SHA3-256 and verifies Ed25519
+// signatures, as chains always have.
+const Default = "sha3-ed25519"
+
+// ErrUnknown is returned by Lookup for names no provider is
+// registered under.
+var ErrUnknown = errors.New("unknown crypto provider")
+
+// Provider is a pair of hash and signature algorithms.
+type Provider interface {
+	// Name returns the name the provider is registered under.
+	Name() string
+
+	// Sum256 returns the 256-bit digest of data.
+	Sum256(data []byte) [32]byte
+
+	// Verify reports whether sig is a valid signature of msg by
+	// pubkey. It returns false for malformed keys and signatures.
+	Verify(pubkey, msg, sig []byte) bool
+}
+
+var (
+	mu        sync.RWMutex
+	providers = make(map[string]Provider)
+)
+
+func init() {
+	Register(sha3Ed25519{})
+	Register(sha256Ed25519{})
+}
+
+// Register makes p available to Lookup under p.Name(). It panics
+// if a provider is already registered under that name.
+func Register(p Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := providers[p.Name()]; ok {
+		panic("crypto provider " + p.Name() + " registered twice")
+	}
+	providers[p.Name()] = p
+}
+
+// Lookup returns the provider registered under name.
+func Lookup(name string) (Provider, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	p, ok := providers[name]
+	if !ok {
+		return nil, errors.WithDetailf(ErrUnknown, "no crypto provider %q (have %v)", name, namesLocked())
+	}
+	return p, nil
+}
+
+// Names returns the names of the registered providers, sorted.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return namesLocked()
+}
+
+func namesLocked() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func verifyEd25519(pubkey, msg, sig []byte) bool {
+	return len(pubkey) == ed25519.PublicKeySize && ed25519.Verify(ed25519.PublicKey(pubkey), msg, sig)
+}
+
+type sha3Ed25519 struct{}
+
+func (sha3Ed25519) Name() string { return Default }
+
+func (sha3Ed25519) Sum256(data []byte) (sum [32]byte) {
+	sha3pool.Sum256(sum[:], data)
+	return sum
+}
+
+func (sha3Ed25519) Verify(pubkey, msg, sig []byte) bool {
+	return verifyEd25519(pubkey, msg, sig)
+}
+
+type sha256Ed25519 struct{}
+
+func (sha256Ed25519) Name() string { return "sha256-ed25519" }
+
+func (sha256Ed25519) Sum256(data []byte) [32]byte {
+	return sha256.Sum256(data)
+}
+
+func (sha256Ed25519) Verify(pubkey, msg, sig []byte) bool {
+	return verifyEd25519(pubkey, msg, sig)
+}
@@ -0,0 +1,57 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/chainmint/crypto/ed25519"
+	"github.com/chainmint/errors"
+)
+
+func TestLookup(t *testing.T) {
+	for _, name := range []string{Default, "sha256-ed25519"} {
+		p, err := Lookup(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Name() != name {
+			t.Errorf("Lookup(%q).Name() = %q", name, p.Name())
+		}
+	}
+	_, err := Lookup("sm3-sm2")
+	if errors.Root(err) != ErrUnknown {
+		t.Errorf("Lookup(sm3-sm2) = %v, want %v", err, ErrUnknown)
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic registering a provider twice")
+		}
+	}()
+	Register(sha256Ed25519{})
+}
+
+func TestSHA256Ed25519(t *testing.T) {
+	p, err := Lookup("sha256-ed25519")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("hello")
+	if p.Sum256(msg) != sha256.Sum256(msg) {
+		t.Error("Sum256 does not match SHA-256")
+	}
+
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	sig := ed25519.Sign(priv, msg)
+	if !p.Verify(pub, msg, sig) {
+		t.Error("Verify rejected a valid signature")
+	}
+	if p.Verify(pub, []byte("goodbye"), sig) {
+		t.Error("Verify accepted a signature of another message")
+	}
+	if p.Verify(pub[:16], msg, sig) {
+		t.Error("Verify accepted a short public key")
+	}
+}
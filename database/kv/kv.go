@@ -0,0 +1,111 @@
+// Package kv provides embedded key-value storage, for data a node
+// keeps in its own files rather than in its Postgres database. A
+// Store is backed either by LevelDB, on disk, or by memory.
+package kv
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	"github.com/chainmint/errors"
+)
+
+// ErrNotFound is returned for a key that is not stored.
+var ErrNotFound = errors.New("key not found")
+
+// A Store is an ordered key-value store. Its methods are safe for
+// concurrent use.
+type Store interface {
+	// Get returns the value stored under key, or ErrNotFound.
+	Get(key []byte) ([]byte, error)
+
+	// Write applies the puts and deletes of b atomically. Once it
+	// returns, they survive a crash.
+	Write(b *Batch) error
+
+	// Last returns the greatest key beginning with prefix, and its
+	// value, or ErrNotFound if no key begins with prefix.
+	Last(prefix []byte) (key, value []byte, err error)
+
+	Close() error
+}
+
+// A Batch is a sequence of puts and deletes, applied in order by
+// Store.Write.
+type Batch struct {
+	ops []op
+}
+
+type op struct {
+	key, value []byte
+	del        bool
+}
+
+// Put adds to b storing value under key.
+func (b *Batch) Put(key, value []byte) {
+	b.ops = append(b.ops, op{key: key, value: value})
+}
+
+// Delete adds to b deleting key.
+func (b *Batch) Delete(key []byte) {
+	b.ops = append(b.ops, op{key: key, del: true})
+}
+
+// Mem is a Store held in memory, for tests and for nodes that keep
+// no data across restarts.
+type Mem struct {
+	mu sync.Mutex
+	m  map[string][]byte
+}
+
+// NewMem returns an empty Mem.
+func NewMem() *Mem {
+	return &Mem{m: make(map[string][]byte)}
+}
+
+// Get implements Store.
+func (s *Mem) Get(key []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.m[string(key)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), v...), nil
+}
+
+// Write implements Store.
+func (s *Mem) Write(b *Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, o := range b.ops {
+		if o.del {
+			delete(s.m, string(o.key))
+		} else {
+			s.m[string(o.key)] = append([]byte(nil), o.value...)
+		}
+	}
+	return nil
+}
+
+// Last implements Store.
+func (s *Mem) Last(prefix []byte) (key, value []byte, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for k := range s.m {
+		if bytes.HasPrefix([]byte(k), prefix) {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil, ErrNotFound
+	}
+	sort.Strings(keys)
+	k := keys[len(keys)-1]
+	return []byte(k), append([]byte(nil), s.m[k]...), nil
+}
+
+// Close implements Store.
+func (s *Mem) Close() error { return nil }
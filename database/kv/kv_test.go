@@ -0,0 +1,30 @@
+package kv
+
+import "testing"
+
+func TestMem(t *testing.T) {
+	s := NewMem()
+	if _, _, err := s.Last([]byte("a")); err != ErrNotFound {
+		t.Errorf("Last on empty store = %v, want %v", err, ErrNotFound)
+	}
+
+	var b Batch
+	b.Put([]byte("a1"), []byte("x"))
+	b.Put([]byte("a2"), []byte("y"))
+	b.Put([]byte("b1"), []byte("z"))
+	b.Delete([]byte("a1"))
+	if err := s.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Get([]byte("a1")); err != ErrNotFound {
+		t.Errorf("Get(deleted) = %v, want %v", err, ErrNotFound)
+	}
+	if v, err := s.Get([]byte("a2")); err != nil || string(v) != "y" {
+		t.Errorf("Get(a2) = %q, %v, want y", v, err)
+	}
+	k, v, err := s.Last([]byte("a"))
+	if err != nil || string(k) != "a2" || string(v) != "y" {
+		t.Errorf("Last(a) = %q, %q, %v, want a2, y", k, v, err)
+	}
+}
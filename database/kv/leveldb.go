@@ -0,0 +1,68 @@
+package kv
+
+import (
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+
+	"github.com/chainmint/errors"
+)
+
+// LevelDB is a Store in a LevelDB database on disk.
+type LevelDB struct {
+	db *leveldb.DB
+}
+
+// OpenLevelDB opens the LevelDB database in dir, creating it if it
+// does not exist.
+func OpenLevelDB(dir string) (*LevelDB, error) {
+	db, err := leveldb.OpenFile(dir, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening leveldb %s", dir)
+	}
+	return &LevelDB{db: db}, nil
+}
+
+// Get implements Store.
+func (s *LevelDB) Get(key []byte) ([]byte, error) {
+	v, err := s.db.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	return v, errors.Wrap(err, "leveldb get")
+}
+
+// Write implements Store.
+func (s *LevelDB) Write(b *Batch) error {
+	batch := new(leveldb.Batch)
+	for _, o := range b.ops {
+		if o.del {
+			batch.Delete(o.key)
+		} else {
+			batch.Put(o.key, o.value)
+		}
+	}
+	err := s.db.Write(batch, &opt.WriteOptions{Sync: true})
+	return errors.Wrap(err, "leveldb write")
+}
+
+// Last implements Store.
+func (s *LevelDB) Last(prefix []byte) (key, value []byte, err error) {
+	it := s.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer it.Release()
+	if !it.Last() {
+		if err := it.Error(); err != nil {
+			return nil, nil, errors.Wrap(err, "leveldb iterate")
+		}
+		return nil, nil, ErrNotFound
+	}
+	// The iterator reuses its buffers once moved or released.
+	key = append([]byte(nil), it.Key()...)
+	value = append([]byte(nil), it.Value()...)
+	return key, value, nil
+}
+
+// Close implements Store.
+func (s *LevelDB) Close() error {
+	return s.db.Close()
+}
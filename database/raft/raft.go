@@ -32,6 +32,8 @@ import (
 	"github.com/chainmint/log"
 )
 
+var raftLog = log.Module("raft")
+
 var ErrUnsatisfied = errors.New("precondition not satisfied")
 
 // TODO(kr): do we need a "client" mode?
@@ -331,7 +333,7 @@ func (sv *Service) runUpdates(wal *wal.WAL) {
 			sv.errMu.Lock()
 			sv.err = err
 			sv.errMu.Unlock()
-			log.Printf(context.Background(), "raft exiting: %v", err)
+			raftLog.Errorkv(context.Background(), log.KeyMessage, "raft exiting", log.KeyError, err)
 			debug.PrintStack()
 		} else if v != nil {
 			panic(v)
@@ -344,7 +346,7 @@ func (sv *Service) runUpdates(wal *wal.WAL) {
 	}()
 	defer sv.raftNode.Stop()
 	defer close(sv.donec)
-	defer log.Printf(context.Background(), "ats:got here deferred")
+	defer raftLog.Debugkv(context.Background(), log.KeyMessage, "runUpdates exiting")
 
 	rdIndices := make(map[string]chan uint64)
 	writers := make(map[string]chan bool)
@@ -443,7 +445,7 @@ func (sv *Service) allocNodeID(ctx context.Context) (uint64, error) {
 	for err == ErrUnsatisfied {
 		sv.stateMu.Lock()
 		nextID, index = sv.state.NextNodeID()
-		log.Printf(ctx, "raft: attempting to allocate nodeID %d at version %d", nextID, index)
+		raftLog.Debugkv(ctx, log.KeyMessage, "attempting to allocate node ID", "id", nextID, "version", index)
 		sv.stateMu.Unlock()
 		b := state.IncrementNextNodeID(nextID, index)
 		err = sv.exec(ctx, b)
@@ -190,19 +190,62 @@ func (db *DB) SetMaxOpenConns(n int) {
 	db.db.SetMaxOpenConns(n)
 }
 
+type asyncCommitKey struct{}
+
+// WithAsyncCommit returns a context in which the transactions begun
+// by DB.Begin, and the statements run by DB.Exec, commit without
+// waiting for the commit to be flushed to disk, as with Postgres's
+// synchronous_commit setting turned off.
+//
+// A crash of the database server can lose the latest of these
+// commits, but never one without those committed after it. The next
+// commit that does wait flushes all those before it, so a series of
+// writes made with ctx, and a last one made without it, are flushed
+// at once.
+func WithAsyncCommit(ctx context.Context) context.Context {
+	return context.WithValue(ctx, asyncCommitKey{}, true)
+}
+
+func asyncCommit(ctx context.Context) bool {
+	async, _ := ctx.Value(asyncCommitKey{}).(bool)
+	return async
+}
+
 // Begin starts a transaction. The isolation level is dependent on
-// the driver.
+// the driver. If ctx is from WithAsyncCommit, the transaction
+// commits asynchronously.
 func (db *DB) Begin(ctx context.Context) (*Tx, error) {
 	tx, err := db.db.Begin()
 	if err != nil {
 		return nil, errors.Wrap(err)
 	}
+	if asyncCommit(ctx) {
+		_, err = tx.ExecContext(ctx, `SET LOCAL synchronous_commit TO OFF`)
+		if err != nil {
+			tx.Rollback()
+			return nil, errors.Wrap(err, "setting asynchronous commit")
+		}
+	}
 	return &Tx{tx: tx}, nil
 }
 
 // Exec executes a query without returning any rows.
 // The args are for any placeholder parameters in the query.
+// If ctx is from WithAsyncCommit, the query runs in its own
+// transaction, which commits asynchronously.
 func (db *DB) Exec(ctx context.Context, query string, args ...interface{}) (Result, error) {
+	if asyncCommit(ctx) {
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			return nil, err
+		}
+		res, err := tx.Exec(ctx, query, args...)
+		if err != nil {
+			tx.Rollback(ctx)
+			return nil, err
+		}
+		return res, errors.Wrap(tx.Commit(ctx))
+	}
 	logQuery(ctx, query, args)
 	return db.db.ExecContext(ctx, query, args...)
 }
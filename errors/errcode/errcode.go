@@ -0,0 +1,105 @@
+// Package errcode defines the stable, machine-readable codes of the
+// ways a transaction can be refused, shared by the layers that
+// refuse it and by every interface reporting why: the results of
+// CheckTx and DeliverTx, the Core API's HTTP and gRPC error
+// responses, and the log.
+//
+// The codes extend the Core API's namespace of error codes, in
+// which 7xx are transaction errors. A code, once published, keeps
+// its meaning; new kinds of failure get new codes.
+//
+// Codes are attached with errors.WithCode where a failure is
+// detected and read back with errors.Code.
+package errcode
+
+import "strings"
+
+// Transaction validity error namespace (77x and 78x)
+const (
+	// BadSignature is a control or issuance program, such as a
+	// signature check, failing to verify.
+	BadSignature = "CH770"
+
+	// DoubleSpend is a spend of an output that is not unspent in
+	// the chain state: already spent, or never created.
+	DoubleSpend = "CH771"
+
+	// NonceConflict is an issuance reusing a nonce in the chain
+	// state.
+	NonceConflict = "CH772"
+
+	// Unbalanced is a transaction whose inputs and outputs do not
+	// balance.
+	Unbalanced = "CH773"
+
+	// Expired is a transaction outside its time range.
+	Expired = "CH774"
+
+	// WrongBlockchain is a transaction for another blockchain.
+	WrongBlockchain = "CH775"
+
+	// InvalidTx is any other invalid transaction.
+	InvalidTx = "CH776"
+
+	// FeeTooLow is a fee below what the node requires.
+	FeeTooLow = "CH777"
+
+	// PoolFull is a transaction turned away by a full pending
+	// transaction pool.
+	PoolFull = "CH778"
+
+	// Conflict is a transaction spending an output that a pending
+	// transaction spends.
+	Conflict = "CH779"
+
+	// RateLimited is a transaction from a sender over its rate
+	// limit.
+	RateLimited = "CH780"
+
+	// PolicyRejected is a transaction refused by a node's
+	// transaction policy.
+	PolicyRejected = "CH781"
+
+	// BlockFull is a transaction left out of a full block.
+	BlockFull = "CH782"
+
+	// BadMemo is a memo that is too large, underpaid for, or does
+	// not match the chain's schema.
+	BadMemo = "CH783"
+
+	// BadStakingAction is a malformed or disallowed validator
+	// registration, delegation or governance action.
+	BadStakingAction = "CH784"
+)
+
+// Format returns msg prefixed with code, for messages, such as the
+// logs of ABCI results, that carry a code as text. An empty code
+// leaves msg as it is.
+func Format(code, msg string) string {
+	if code == "" {
+		return msg
+	}
+	return code + ": " + msg
+}
+
+// Parse returns the code and message of s, as made by Format. It
+// returns an empty code if s has none.
+func Parse(s string) (code, msg string) {
+	i := strings.Index(s, ": ")
+	if i < 0 || !isCode(s[:i]) {
+		return "", s
+	}
+	return s[:i], s[i+2:]
+}
+
+func isCode(s string) bool {
+	if len(s) != 5 || !strings.HasPrefix(s, "CH") {
+		return false
+	}
+	for _, c := range s[2:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,21 @@
+package errcode
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		s, code, msg string
+	}{
+		{Format(DoubleSpend, "invalid prevout: x"), DoubleSpend, "invalid prevout: x"},
+		{Format("", "invalid prevout: x"), "", "invalid prevout: x"},
+		{"invalid prevout: x", "", "invalid prevout: x"},
+		{"CH7: short", "", "CH7: short"},
+		{"CHxyz: letters", "", "CHxyz: letters"},
+	}
+	for _, c := range cases {
+		code, msg := Parse(c.s)
+		if code != c.code || msg != c.msg {
+			t.Errorf("Parse(%q) = %q, %q want %q, %q", c.s, code, msg, c.code, c.msg)
+		}
+	}
+}
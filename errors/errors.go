@@ -16,6 +16,7 @@ type wrapperError struct {
 	msg    string
 	detail []string
 	data   map[string]interface{}
+	code   string
 	stack  []StackFrame
 	root   error
 }
@@ -117,6 +118,31 @@ func Detail(err error) string {
 	return strings.Join(wrapper.detail, "; ")
 }
 
+// WithCode returns a new error that wraps err
+// with code, a stable, machine-readable identifier
+// of the kind of failure, such as "CH771",
+// for clients to act on without parsing messages.
+// Function Code will return code
+// when called on the new error value,
+// or on any error wrapping it,
+// unless it is wrapped with another code.
+// WithCode returns nil if err is nil.
+func WithCode(err error, code string) error {
+	if err == nil {
+		return nil
+	}
+	e1 := wrap(err, "", 1).(wrapperError)
+	e1.code = code
+	return e1
+}
+
+// Code returns the code contained in err, if any.
+// An error has a code if it was made by WithCode.
+func Code(err error) string {
+	wrapper, _ := err.(wrapperError)
+	return wrapper.code
+}
+
 // withData returns a new error that wraps err
 // as a chain error message containing v as
 // an extra data item.
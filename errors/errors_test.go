@@ -118,6 +118,33 @@ func TestData(t *testing.T) {
 	}
 }
 
+func TestCode(t *testing.T) {
+	root := errors.New("foo")
+	cases := []struct {
+		err  error
+		code string
+	}{
+		{root, ""},
+		{Wrap(root), ""},
+		{WithCode(root, "CH1"), "CH1"},
+		{WithDetail(WithCode(root, "CH1"), "bar"), "CH1"},
+		{WithCode(WithCode(root, "CH1"), "CH2"), "CH2"},
+		{Sub(errors.New("baz"), WithCode(root, "CH1")), "CH1"},
+	}
+
+	for _, test := range cases {
+		if got := Code(test.err); got != test.code {
+			t.Errorf("Code(%#v) = %q want %q", test.err, got, test.code)
+		}
+	}
+	if got := Root(WithCode(root, "CH1")); got != root {
+		t.Errorf("Root(WithCode(%v)) = %v want %v", root, got, root)
+	}
+	if WithCode(nil, "CH1") != nil {
+		t.Error("WithCode(nil) != nil")
+	}
+}
+
 func TestSub(t *testing.T) {
 	x := errors.New("x")
 	y := errors.New("y")
@@ -0,0 +1,207 @@
+package log
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chainmint/errors"
+)
+
+// A Level is the severity of a log entry. Entries below the level
+// set for their module are not written.
+type Level int
+
+// Levels, from least to most severe.
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// Conventional key names for leveled log entries
+const (
+	KeyLevel  = "level"  // produced by Logger
+	KeyModule = "module" // produced by Logger
+)
+
+var levelNames = [...]string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return "?"
+	}
+	return levelNames[l]
+}
+
+// ErrBadLevel is returned by ParseLevel and SetLevels for a level
+// name that is not debug, info, warn or error.
+var ErrBadLevel = errors.New("invalid log level")
+
+// ParseLevel returns the Level named s.
+func ParseLevel(s string) (Level, error) {
+	for l, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return Level(l), nil
+		}
+	}
+	return 0, errors.WithDetailf(ErrBadLevel, "%q", s)
+}
+
+var (
+	levelsMu     sync.RWMutex // protects the following
+	defaultLevel = LevelInfo
+	moduleLevels = map[string]Level{}
+)
+
+// SetLevel sets the level of module. Entries logged by its Logger
+// below l are not written. An empty module sets the default level,
+// for modules with no level of their own.
+func SetLevel(module string, l Level) {
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
+	if module == "" {
+		defaultLevel = l
+	} else {
+		moduleLevels[module] = l
+	}
+}
+
+// SetLevels sets the levels in spec, a comma-separated list of
+// level names, each optionally prefixed by a module name and '=',
+// such as "info,app=debug,generator=warn". A level with no module
+// sets the default level. Modules not named in spec keep the levels
+// they had.
+func SetLevels(spec string) error {
+	levels := map[string]Level{}
+	for _, s := range strings.Split(spec, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		var module string
+		if i := strings.IndexByte(s, '='); i >= 0 {
+			module, s = s[:i], s[i+1:]
+		}
+		l, err := ParseLevel(s)
+		if err != nil {
+			return err
+		}
+		levels[module] = l
+	}
+	for module, l := range levels {
+		SetLevel(module, l)
+	}
+	return nil
+}
+
+// Levels returns the current levels in the form SetLevels takes,
+// the default level first.
+func Levels() string {
+	levelsMu.RLock()
+	defer levelsMu.RUnlock()
+	var modules []string
+	for module, l := range moduleLevels {
+		modules = append(modules, module+"="+l.String())
+	}
+	sort.Strings(modules)
+	return strings.Join(append([]string{defaultLevel.String()}, modules...), ",")
+}
+
+func levelOf(module string) Level {
+	levelsMu.RLock()
+	defer levelsMu.RUnlock()
+	if l, ok := moduleLevels[module]; ok {
+		return l
+	}
+	return defaultLevel
+}
+
+// A Logger writes leveled log entries for a module, a part of the
+// program whose level can be set apart from the rest with SetLevel.
+// Each entry carries its level and module, under KeyLevel and
+// KeyModule, ahead of the fields given.
+type Logger struct {
+	module  string
+	sampler *sampler
+}
+
+// Module returns a Logger for the named module.
+func Module(name string) *Logger {
+	return &Logger{module: name}
+}
+
+// Sampled returns a Logger for the same module that, of the debug
+// and info entries it is given, writes the first first in each
+// second, and every thereafter-th entry after that, dropping the
+// rest. It is meant for hot paths, such as CheckTx, that would
+// otherwise drown the log. Warnings and errors are never dropped.
+func (l *Logger) Sampled(first, thereafter int) *Logger {
+	return &Logger{module: l.module, sampler: &sampler{first: first, thereafter: thereafter}}
+}
+
+// Enabled reports whether l writes entries at level lv. Callers
+// can use it to skip building fields that would not be written.
+func (l *Logger) Enabled(lv Level) bool {
+	return lv >= levelOf(l.module)
+}
+
+// Debugkv writes a debug entry with the fields in keyvals, as
+// Printkv does.
+func (l *Logger) Debugkv(ctx context.Context, keyvals ...interface{}) {
+	l.printkv(ctx, LevelDebug, keyvals)
+}
+
+// Infokv writes an info entry with the fields in keyvals, as Printkv
+// does.
+func (l *Logger) Infokv(ctx context.Context, keyvals ...interface{}) {
+	l.printkv(ctx, LevelInfo, keyvals)
+}
+
+// Warnkv writes a warning with the fields in keyvals, as Printkv
+// does.
+func (l *Logger) Warnkv(ctx context.Context, keyvals ...interface{}) {
+	l.printkv(ctx, LevelWarn, keyvals)
+}
+
+// Errorkv writes an error entry with the fields in keyvals, as
+// Printkv does.
+func (l *Logger) Errorkv(ctx context.Context, keyvals ...interface{}) {
+	l.printkv(ctx, LevelError, keyvals)
+}
+
+func (l *Logger) printkv(ctx context.Context, lv Level, keyvals []interface{}) {
+	if !l.Enabled(lv) {
+		return
+	}
+	if l.sampler != nil && lv < LevelWarn && !l.sampler.allow(time.Now()) {
+		return
+	}
+	Printkv(ctx, append([]interface{}{KeyLevel, lv, KeyModule, l.module}, keyvals...)...)
+}
+
+// sampler passes the first first events in each second, and every
+// thereafter-th event after that.
+type sampler struct {
+	first, thereafter int
+
+	mu    sync.Mutex
+	start time.Time // of the current second
+	n     int       // events in the current second
+}
+
+func (s *sampler) allow(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.start) >= time.Second {
+		s.start, s.n = now, 0
+	}
+	s.n++
+	if s.n <= s.first {
+		return true
+	}
+	return s.thereafter > 0 && (s.n-s.first)%s.thereafter == 0
+}
@@ -0,0 +1,77 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoggerLevels(t *testing.T) {
+	buf := new(bytes.Buffer)
+	SetOutput(buf)
+	defer SetOutput(os.Stdout)
+	defer resetLevels()
+
+	err := SetLevels("warn, test=debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Levels(); got != "warn,test=debug" {
+		t.Errorf("Levels() = %q want warn,test=debug", got)
+	}
+
+	ctx := context.Background()
+	Module("test").Debugkv(ctx, "n", 1)
+	Module("other").Infokv(ctx, "n", 2)
+	Module("other").Warnkv(ctx, "n", 3)
+
+	got := buf.String()
+	for _, w := range []string{
+		"at=level_test.go:",
+		"level=debug module=test n=1\n",
+		"level=warn module=other n=3\n",
+	} {
+		if !strings.Contains(got, w) {
+			t.Errorf("output %q did not contain %q", got, w)
+		}
+	}
+	if strings.Contains(got, "n=2") {
+		t.Errorf("output %q contains info entry below level warn", got)
+	}
+
+	err = SetLevels("info,test=loud")
+	if err == nil {
+		t.Error("SetLevels accepted level loud")
+	}
+	if got := Levels(); got != "warn,test=debug" {
+		t.Errorf("after bad SetLevels, Levels() = %q want warn,test=debug", got)
+	}
+}
+
+func TestSampler(t *testing.T) {
+	s := &sampler{first: 2, thereafter: 3}
+	t0 := time.Now()
+	var got []bool
+	for i := 0; i < 8; i++ {
+		got = append(got, s.allow(t0))
+	}
+	want := []bool{true, true, false, false, true, false, false, true}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("allowed %v want %v", got, want)
+		}
+	}
+	if !s.allow(t0.Add(time.Second)) {
+		t.Error("sampler did not allow the first event of the next second")
+	}
+}
+
+func resetLevels() {
+	levelsMu.Lock()
+	defaultLevel = LevelInfo
+	moduleLevels = map[string]Level{}
+	levelsMu.Unlock()
+}
@@ -47,6 +47,7 @@ const (
 
 	KeyMessage = "message" // produced by Message
 	KeyError   = "error"   // produced by Error
+	KeyCode    = "code"    // code of a KeyError value; see errors.Code
 	KeyStack   = "stack"   // used by Printkv to print stack on subsequent lines
 
 	keyLogError = "log-error" // for errors produced by the log package itself
@@ -110,6 +111,9 @@ func prefix(ctx context.Context) []byte {
 // Use SkipFunc to prevent helper functions from showing up in the
 // at=[file:line] field.
 //
+// A KeyError value that is an error with a code, as set by
+// errors.WithCode, is followed by the code, under KeyCode.
+//
 // Printkv will also print the stack trace, if any, on separate lines
 // following the message. The stack is obtained from the following,
 // in order of preference:
@@ -138,12 +142,16 @@ func Printkv(ctx context.Context, keyvals ...interface{}) {
 			stack = v
 			continue
 		}
+		out += " " + formatKey(k) + "=" + formatValue(v)
 		if k == KeyError {
-			if e, ok := v.(error); ok && stack == nil {
+			e, ok := v.(error)
+			if ok && stack == nil {
 				stack = errors.Stack(errors.Wrap(e)) // wrap to ensure callstack
 			}
+			if ok && errors.Code(e) != "" {
+				out += " " + KeyCode + "=" + formatValue(errors.Code(e))
+			}
 		}
-		out += " " + formatKey(k) + "=" + formatValue(v)
 	}
 
 	logWriterMu.Lock()
@@ -267,6 +267,20 @@ func TestError(t *testing.T) {
 	}
 }
 
+func TestErrorCode(t *testing.T) {
+	buf := new(bytes.Buffer)
+	SetOutput(buf)
+	defer SetOutput(os.Stdout)
+
+	Error(context.Background(), errors.WithCode(errors.New("boo"), "CH771"))
+
+	got := buf.String()
+	want := "error=boo code=CH771\n"
+	if !strings.Contains(got, want) {
+		t.Errorf("output %q did not contain %q", got, want)
+	}
+}
+
 func TestRawStack(t *testing.T) {
 	var buf bytes.Buffer
 	SetOutput(&buf)
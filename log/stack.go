@@ -7,18 +7,23 @@ import (
 )
 
 var skipFunc = map[string]bool{
-	"chainmint/log.Printkv":            true,
-	"chainmint/log.Printf":             true,
-	"chainmint/log.Error":              true,
-	"chainmint/log.Fatalkv":            true,
-	"chainmint/log.RecoverAndLogError": true,
+	"github.com/chainmint/log.Printkv":            true,
+	"github.com/chainmint/log.Printf":             true,
+	"github.com/chainmint/log.Error":              true,
+	"github.com/chainmint/log.Fatalkv":            true,
+	"github.com/chainmint/log.RecoverAndLogError": true,
+	"github.com/chainmint/log.(*Logger).Debugkv":  true,
+	"github.com/chainmint/log.(*Logger).Infokv":   true,
+	"github.com/chainmint/log.(*Logger).Warnkv":   true,
+	"github.com/chainmint/log.(*Logger).Errorkv":  true,
+	"github.com/chainmint/log.(*Logger).printkv":  true,
 }
 
 // SkipFunc removes the named function from stack traces
 // and at=[file:line] entries printed to the log output.
 // The provided name should be a fully-qualified function name
 // comprising the import path and identifier separated by a dot.
-// For example, chain/log.Printkv.
+// For example, github.com/chainmint/log.Printkv.
 // SkipFunc must not be called concurrently with any function
 // in this package (including itself).
 func SkipFunc(name string) {
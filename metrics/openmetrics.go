@@ -0,0 +1,246 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LatencyBuckets are the upper bounds, in seconds, of the buckets of
+// a Histogram of latencies from a millisecond to ten seconds.
+var LatencyBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+var (
+	familiesMu sync.Mutex
+	families   = map[string]*family{}
+)
+
+// family is a metric, with a series of values for each combination
+// of the values of its labels.
+type family struct {
+	name, typ, help string
+	labels          []string
+	buckets         []float64 // histograms only
+
+	mu     sync.Mutex
+	series map[string]*series // by labelKey of the label values
+}
+
+type series struct {
+	labelValues []string
+	value       float64  // counters and gauges
+	counts      []uint64 // histograms; per bucket, then above the last
+	sum         float64  // histograms
+}
+
+// register publishes a new family, as WriteOpenMetrics writes it.
+// Like expvar.Publish, it panics if the name is already in use.
+func register(name, typ, help string, buckets []float64, labels []string) *family {
+	f := &family{
+		name:    name,
+		typ:     typ,
+		help:    help,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+	familiesMu.Lock()
+	defer familiesMu.Unlock()
+	if families[name] != nil {
+		panic("metrics: reuse of metric name " + name)
+	}
+	families[name] = f
+	return f
+}
+
+// with calls fn with the series of f for labelValues, under f.mu.
+func (f *family) with(labelValues []string, fn func(*series)) {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", f.name, len(f.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := f.series[key]
+	if s == nil {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		if f.typ == "histogram" {
+			s.counts = make([]uint64, len(f.buckets)+1)
+		}
+		f.series[key] = s
+	}
+	fn(s)
+}
+
+// A Counter is a count that only goes up, such as of requests or
+// errors, with a value for each combination of its labels' values.
+// Its methods are safe to call concurrently.
+type Counter struct {
+	f *family
+}
+
+// NewCounter returns a new Counter, published under name. Each call
+// to its methods takes a value for each of labels, in order.
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{register(name, "counter", help, nil, labels)}
+}
+
+// Add adds n to the count for labelValues.
+func (c *Counter) Add(n float64, labelValues ...string) {
+	c.f.with(labelValues, func(s *series) { s.value += n })
+}
+
+// Inc adds 1 to the count for labelValues.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// A Gauge is a value that goes up and down, such as the size of a
+// queue. Its methods are safe to call concurrently.
+type Gauge struct {
+	f *family
+}
+
+// NewGauge returns a new Gauge, published under name. Each call to
+// its methods takes a value for each of labels, in order.
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{register(name, "gauge", help, nil, labels)}
+}
+
+// Set sets the gauge for labelValues to v.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.f.with(labelValues, func(s *series) { s.value = v })
+}
+
+// A Histogram counts observations, such as latencies, in buckets.
+// Its methods are safe to call concurrently.
+type Histogram struct {
+	f *family
+}
+
+// NewHistogram returns a new Histogram, published under name, with
+// buckets of the given increasing upper bounds, and one above them.
+// Each call to its methods takes a value for each of labels, in
+// order.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if !sort.Float64sAreSorted(buckets) {
+		panic("metrics: buckets of " + name + " are not in increasing order")
+	}
+	return &Histogram{register(name, "histogram", help, buckets, labels)}
+}
+
+// Observe records v for labelValues.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	i := sort.SearchFloat64s(h.f.buckets, v)
+	h.f.with(labelValues, func(s *series) {
+		s.counts[i]++
+		s.sum += v
+	})
+}
+
+// ObserveSince records the time since t0, in seconds, for
+// labelValues.
+func (h *Histogram) ObserveSince(t0 time.Time, labelValues ...string) {
+	h.Observe(time.Since(t0).Seconds(), labelValues...)
+}
+
+// WriteOpenMetrics writes every Counter, Gauge and Histogram, in
+// order of name, to w in the OpenMetrics text format. It does not
+// write the terminating "# EOF" line, so that callers may write
+// further metrics after them.
+func WriteOpenMetrics(w io.Writer) error {
+	familiesMu.Lock()
+	fs := make([]*family, 0, len(families))
+	for _, f := range families {
+		fs = append(fs, f)
+	}
+	familiesMu.Unlock()
+	sort.Slice(fs, func(i, j int) bool { return fs[i].name < fs[j].name })
+
+	bw := bufio.NewWriter(w)
+	for _, f := range fs {
+		f.write(bw)
+	}
+	return bw.Flush()
+}
+
+func (f *family) write(w *bufio.Writer) {
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.typ)
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, escape(f.help, false))
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := f.series[k]
+		switch f.typ {
+		case "counter":
+			writeSample(w, f.name+"_total", f.labels, s.labelValues, "", s.value)
+		case "gauge":
+			writeSample(w, f.name, f.labels, s.labelValues, "", s.value)
+		case "histogram":
+			var n uint64
+			for i, count := range s.counts {
+				n += count
+				le := math.Inf(1)
+				if i < len(f.buckets) {
+					le = f.buckets[i]
+				}
+				writeSample(w, f.name+"_bucket", f.labels, s.labelValues, formatFloat(le), float64(n))
+			}
+			writeSample(w, f.name+"_count", f.labels, s.labelValues, "", float64(n))
+			writeSample(w, f.name+"_sum", f.labels, s.labelValues, "", s.sum)
+		}
+	}
+}
+
+// writeSample writes a sample line. If le is not empty, it is added
+// as the last label.
+func writeSample(w *bufio.Writer, name string, labels, values []string, le string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || le != "" {
+		w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", l, escape(values[i], true))
+		}
+		if le != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "le=\"%s\"", le)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// escape escapes s for a HELP line or, if quoted, a label value.
+func escape(s string, quoted bool) string {
+	r := strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	if quoted {
+		r = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	}
+	return r.Replace(s)
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteOpenMetrics(t *testing.T) {
+	familiesMu.Lock()
+	saved := families
+	families = map[string]*family{}
+	familiesMu.Unlock()
+	defer func() {
+		familiesMu.Lock()
+		families = saved
+		familiesMu.Unlock()
+	}()
+
+	c := NewCounter("test_errors", "Errors, by path.", "path")
+	c.Inc("/b")
+	c.Add(2, "/a\"")
+	g := NewGauge("test_size", "Size\nof things.")
+	g.Set(1.5)
+	h := NewHistogram("test_seconds", "Latency.", []float64{.1, 1}, "code")
+	h.Observe(.05, "0")
+	h.Observe(.5, "0")
+	h.Observe(3, "0")
+
+	var buf bytes.Buffer
+	err := WriteOpenMetrics(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# TYPE test_errors counter
+# HELP test_errors Errors, by path.
+test_errors_total{path="/a\""} 2
+test_errors_total{path="/b"} 1
+# TYPE test_seconds histogram
+# HELP test_seconds Latency.
+test_seconds_bucket{code="0",le="0.1"} 1
+test_seconds_bucket{code="0",le="1"} 2
+test_seconds_bucket{code="0",le="+Inf"} 3
+test_seconds_count{code="0"} 3
+test_seconds_sum{code="0"} 3.55
+# TYPE test_size gauge
+# HELP test_size Size\nof things.
+test_size 1.5
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("reusing a metric name did not panic")
+		}
+	}()
+	NewGauge("test_size", "")
+}
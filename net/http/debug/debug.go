@@ -0,0 +1,125 @@
+// Package debug serves a process's profiles, goroutine dumps,
+// expvars and a summary of its state, for troubleshooting it in
+// production. The handler is meant for a listener of its own, apart
+// from the API, and every request must present its token.
+package debug
+
+import (
+	"context"
+	"crypto/subtle"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	rpprof "runtime/pprof"
+	"strings"
+	"time"
+
+	"github.com/chainmint/log"
+	"github.com/chainmint/net/http/httpjson"
+)
+
+var started = time.Now()
+
+// StateFunc returns a summary of a process's state, to be encoded
+// as JSON.
+type StateFunc func(context.Context) (interface{}, error)
+
+// Handler returns a handler serving, to requests presenting token
+// as a bearer token or as the password of basic auth:
+//
+//	/debug/pprof/     the profiles of package net/http/pprof
+//	/debug/goroutines the stacks of all goroutines
+//	/debug/vars       the expvars, as package expvar serves them
+//	/debug/state      a summary of the runtime and, if state is not
+//	                  nil, the summary state returns
+//
+// Other requests get 401 Unauthorized. An empty token admits no
+// request.
+func Handler(token string, state StateFunc) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/goroutines", serveGoroutines)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.Handle("/debug/state", stateHandler(state))
+	return &authHandler{token: token, next: mux}
+}
+
+type authHandler struct {
+	token string
+	next  http.Handler
+}
+
+func (h *authHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !h.authorized(req) {
+		log.Printkv(req.Context(), "at", "unauthorized debug request", "path", req.URL.Path, "remote_addr", req.RemoteAddr)
+		w.Header().Set("WWW-Authenticate", `Basic realm="debug"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	h.next.ServeHTTP(w, req)
+}
+
+func (h *authHandler) authorized(req *http.Request) bool {
+	if h.token == "" {
+		return false
+	}
+	got, ok := "", false
+	if _, pw, basic := req.BasicAuth(); basic {
+		got, ok = pw, true
+	} else if a := req.Header.Get("Authorization"); strings.HasPrefix(a, "Bearer ") {
+		got, ok = strings.TrimPrefix(a, "Bearer "), true
+	}
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(h.token)) == 1
+}
+
+func serveGoroutines(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	rpprof.Lookup("goroutine").WriteTo(w, 2)
+}
+
+type runtimeState struct {
+	Uptime     string `json:"uptime"`
+	Goroutines int    `json:"goroutines"`
+	GOMAXPROCS int    `json:"gomaxprocs"`
+	HeapAlloc  uint64 `json:"heap_alloc_bytes"`
+	HeapSys    uint64 `json:"heap_sys_bytes"`
+	NumGC      uint32 `json:"num_gc"`
+	LogLevels  string `json:"log_levels"`
+}
+
+func stateHandler(state StateFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		resp := struct {
+			Runtime runtimeState `json:"runtime"`
+			State   interface{}  `json:"state,omitempty"`
+		}{
+			Runtime: runtimeState{
+				Uptime:     time.Since(started).Round(time.Second).String(),
+				Goroutines: runtime.NumGoroutine(),
+				GOMAXPROCS: runtime.GOMAXPROCS(0),
+				HeapAlloc:  mem.HeapAlloc,
+				HeapSys:    mem.HeapSys,
+				NumGC:      mem.NumGC,
+				LogLevels:  log.Levels(),
+			},
+		}
+		if state != nil {
+			s, err := state(ctx)
+			if err != nil {
+				log.Error(ctx, err, "summarizing state")
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+				return
+			}
+			resp.State = s
+		}
+		httpjson.Write(ctx, w, http.StatusOK, resp)
+	})
+}
@@ -0,0 +1,68 @@
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuth(t *testing.T) {
+	h := Handler("s3cret", nil)
+	cases := []struct {
+		setAuth func(*http.Request)
+		want    int
+	}{
+		{func(*http.Request) {}, 401},
+		{func(r *http.Request) { r.Header.Set("Authorization", "Bearer s3cret") }, 200},
+		{func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") }, 401},
+		{func(r *http.Request) { r.SetBasicAuth("", "s3cret") }, 200},
+		{func(r *http.Request) { r.SetBasicAuth("s3cret", "") }, 401},
+	}
+	for i, c := range cases {
+		req := httptest.NewRequest("GET", "/debug/goroutines", nil)
+		c.setAuth(req)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != c.want {
+			t.Errorf("case %d: status = %d want %d", i, rec.Code, c.want)
+		}
+		if c.want == 200 && !strings.Contains(rec.Body.String(), "goroutine") {
+			t.Errorf("case %d: body = %q, want a goroutine dump", i, rec.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/debug/goroutines", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	rec := httptest.NewRecorder()
+	Handler("", nil).ServeHTTP(rec, req)
+	if rec.Code != 401 {
+		t.Errorf("with an empty token, status = %d want 401", rec.Code)
+	}
+}
+
+func TestState(t *testing.T) {
+	h := Handler("s3cret", func(context.Context) (interface{}, error) {
+		return map[string]int{"height": 7}, nil
+	})
+	req := httptest.NewRequest("GET", "/debug/state", nil)
+	req.SetBasicAuth("", "s3cret")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("status = %d want 200", rec.Code)
+	}
+	var got struct {
+		Runtime runtimeState
+		State   map[string]int
+	}
+	err := json.Unmarshal(rec.Body.Bytes(), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Runtime.Goroutines == 0 || got.State["height"] != 7 {
+		t.Errorf("state = %+v, want the runtime and height 7", got)
+	}
+}
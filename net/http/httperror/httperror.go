@@ -13,8 +13,8 @@ import (
 )
 
 func init() {
-	log.SkipFunc("chain/net/http/httperror.Formatter.Log")
-	log.SkipFunc("chain/net/http/httperror.Formatter.Write")
+	log.SkipFunc("github.com/chainmint/net/http/httperror.Formatter.Log")
+	log.SkipFunc("github.com/chainmint/net/http/httperror.Formatter.Write")
 }
 
 // Info contains a set of error codes to send to the user.
@@ -38,10 +38,16 @@ type Formatter struct {
 	Default     Info
 	IsTemporary func(info Info, err error) bool
 	Errors      map[error]Info
+
+	// Codes maps the codes carried by errors, as set by
+	// errors.WithCode, to responses. An error's code takes
+	// precedence over its root in Errors.
+	Codes map[string]Info
 }
 
 // Format builds an error Response body describing err by consulting
-// the f.Errors lookup table. If no entry is found, it returns f.Default.
+// the f.Codes lookup table, then the f.Errors lookup table. If no
+// entry is found, it returns f.Default.
 func (f Formatter) Format(err error) (body Response) {
 	root := errors.Root(err)
 	// Some types cannot be used as map keys, for example slices.
@@ -52,7 +58,10 @@ func (f Formatter) Format(err error) (body Response) {
 			body = Response{f.Default, "", nil, true}
 		}
 	}()
-	info, ok := f.Errors[root]
+	info, ok := f.Codes[errors.Code(err)]
+	if !ok {
+		info, ok = f.Errors[root]
+	}
 	if !ok {
 		info = f.Default
 	}
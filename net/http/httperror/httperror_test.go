@@ -19,6 +19,9 @@ var (
 		Errors: map[error]Info{
 			errNotFound: {400, "CH002", "Not found"},
 		},
+		Codes: map[string]Info{
+			"CH404": {404, "CH404", "Gone missing"},
+		},
 	}
 )
 
@@ -37,6 +40,9 @@ func TestInfo(t *testing.T) {
 		{context.Canceled, 500},
 		{errNotFound, 400},
 		{errors.Wrap(errNotFound, "foo"), 400},
+		{errors.WithCode(errNotFound, "CH404"), 404},
+		{errors.WithCode(errNotFound, "CH999"), 400},
+		{errors.WithCode(fmt.Errorf("an error!"), "CH404"), 404},
 		{sliceError{}, 500},
 		{fmt.Errorf("an error!"), 500},
 	}
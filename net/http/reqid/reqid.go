@@ -10,6 +10,8 @@ import (
 	"github.com/chainmint/log"
 )
 
+var reqidLog = log.Module("reqid")
+
 // key is an unexported type for keys defined in this package.
 // This prevents collisions with keys defined in other packages.
 type key int
@@ -43,7 +45,7 @@ func New() string {
 	b := make([]byte, l)
 	_, err := rand.Read(b)
 	if err != nil {
-		log.Printf(context.Background(), "error making reqID")
+		reqidLog.Errorkv(context.Background(), log.KeyMessage, "error making request ID", log.KeyError, err)
 	}
 	return hex.EncodeToString(b)
 }
@@ -3,6 +3,7 @@ package protocol
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"time"
 
 	"github.com/chainmint/crypto/ed25519"
@@ -15,8 +16,10 @@ import (
 	"github.com/chainmint/protocol/vmutil"
 )
 
+var protocolLog = log.Module("protocol")
+
 // maxBlockTxs limits the number of transactions
-// included in each block.
+// included in each block, unless changed by SetBlockLimits.
 const maxBlockTxs = 10000
 
 // saveSnapshotFrequency stores how often to save a state
@@ -66,11 +69,22 @@ func (c *Chain) GenerateBlock(ctx context.Context, prev *legacy.Block, snapshot
 			return nil, nil, fmt.Errorf("timestamp %d is earlier than prevblock timestamp %d", timestampMS, prev.TimestampMS)
 		}
 
+		// The block's version is the least the rules at its height
+		// allow, and never goes back.
+		rules, err := c.Rules(prev.Height + 1)
+		if err != nil {
+			return nil, nil, err
+		}
+		version := rules.BlockVersion
+		if version < prev.Version {
+			version = prev.Version
+		}
+
 		// Make a copy of the snapshot that we can apply our changes to.
 
 		b = &legacy.Block{
 			BlockHeader: legacy.BlockHeader{
-				Version:           1,
+				Version:           version,
 				Height:            prev.Height + 1,
 				PreviousBlockHash: prev.Hash(),
 				TimestampMS:       timestampMS,
@@ -84,13 +98,28 @@ func (c *Chain) GenerateBlock(ctx context.Context, prev *legacy.Block, snapshot
 	newSnapshot.PruneNonces(timestampMS)
 	var txEntries []*bc.Tx
 
-	for _, tx := range txs {
-		if len(b.Transactions) >= maxBlockTxs {
+	// Validate the candidate txs, and look up the outputs they spend,
+	// concurrently, before applying them to the state in order.
+	candidates := make([]*bc.Tx, len(txs))
+	for i, tx := range txs {
+		candidates[i] = tx.Tx
+	}
+	txErrs := validation.ValidateTxs(candidates, c.ValidateTx)
+	prefetched := newSnapshot.Prefetch(candidates)
+
+	maxTxs, maxBytes := c.BlockLimits()
+	var size int64
+	for i, tx := range txs {
+		if len(b.Transactions) >= maxTxs {
+			break
+		}
+		n, _ := tx.WriteTo(ioutil.Discard)
+		if maxBytes > 0 && size+n > maxBytes {
 			break
 		}
 
 		// Filter out transactions that are not well-formed.
-		err := c.ValidateTx(tx.Tx)
+		err := txErrs[i]
 		if err != nil {
 			// TODO(bobg): log this?
 			continue
@@ -106,7 +135,7 @@ func (c *Chain) GenerateBlock(ctx context.Context, prev *legacy.Block, snapshot
 		}
 
 		// Filter out double-spends etc.
-		err = newSnapshot.ApplyTx(tx.Tx)
+		err = newSnapshot.ApplyPrefetchedTx(tx.Tx, prefetched)
 		if err != nil {
 			// TODO(bobg): log this?
 			continue
@@ -114,6 +143,7 @@ func (c *Chain) GenerateBlock(ctx context.Context, prev *legacy.Block, snapshot
 
 		b.Transactions = append(b.Transactions, tx)
 		txEntries = append(txEntries, tx.Tx)
+		size += n
 	}
 
 	var err error
@@ -132,9 +162,13 @@ func (c *Chain) GenerateBlock(ctx context.Context, prev *legacy.Block, snapshot
 // to a snapshot (with ApplyValidBlock) and committing it to the
 // blockchain (with CommitAppliedBlock).
 func (c *Chain) ValidateBlock(block, prev *legacy.Block) error {
+	err := c.checkRules(block)
+	if err != nil {
+		return err
+	}
 	blockEnts := legacy.MapBlock(block)
 	prevEnts := legacy.MapBlock(prev)
-	err := validation.ValidateBlock(blockEnts, prevEnts, c.InitialBlockHash, c.ValidateTx)
+	err = validation.ValidateBlock(blockEnts, prevEnts, c.InitialBlockHash, c.ValidateTx)
 	if err != nil {
 		return errors.Sub(ErrBadBlock, err)
 	}
@@ -144,10 +178,53 @@ func (c *Chain) ValidateBlock(block, prev *legacy.Block) error {
 	return errors.Sub(ErrBadBlock, err)
 }
 
+// ValidateHeaderChain checks that headers, in order of height, form a
+// chain from the initial block: each is valid against the header
+// before it and satisfies its consensus program. The blocks'
+// transactions are not checked, so this suits a node that trusts the
+// state after the last header, as when syncing from a checkpoint.
+func ValidateHeaderChain(headers []*legacy.BlockHeader) error {
+	if len(headers) == 0 || headers[0].Height != 1 {
+		return errors.WithDetail(ErrBadBlock, "header chain does not start at the initial block")
+	}
+	prev := legacy.MapBlock(&legacy.Block{BlockHeader: *headers[0]})
+	for _, h := range headers[1:] {
+		b := legacy.MapBlock(&legacy.Block{BlockHeader: *h})
+		err := validation.ValidateBlockHeader(b, prev)
+		if err == nil {
+			err = validation.ValidateBlockSig(b, prev.NextConsensusProgram)
+		}
+		if err != nil {
+			return errors.Sub(ErrBadBlock, errors.Wrapf(err, "header of block %d", h.Height))
+		}
+		prev = b
+	}
+	return nil
+}
+
+// checkRules checks block against the protocol rules in force at its
+// height. It returns upgrade.ErrMissingUpgrade, rather than
+// ErrBadBlock, if this release cannot tell whether block is valid.
+func (c *Chain) checkRules(block *legacy.Block) error {
+	rules, err := c.Rules(block.Height)
+	if err != nil {
+		return err
+	}
+	if block.Version < rules.BlockVersion {
+		return errors.WithDetailf(ErrBadBlock, "block version %d, rules at height %d require %d", block.Version, block.Height, rules.BlockVersion)
+	}
+	return nil
+}
+
 // ApplyValidBlock creates an updated snapshot without validating the
 // block.
 func (c *Chain) ApplyValidBlock(block *legacy.Block) (*state.Snapshot, error) {
-	newSnapshot := state.Copy(c.state.snapshot)
+	_, snapshot := c.State()
+	if snapshot == nil {
+		// The initial block applies to the empty state.
+		snapshot = state.Empty()
+	}
+	newSnapshot := state.Copy(snapshot)
 	err := newSnapshot.ApplyBlock(legacy.MapBlock(block))
 	if err != nil {
 		return nil, err
@@ -163,13 +240,21 @@ func (c *Chain) ApplyValidBlock(block *legacy.Block) (*state.Snapshot, error) {
 // ApplyNewBlock, which will have produced the new snapshot that's
 // required here.
 //
-// This function saves the block to the store and sometimes (not more
-// often than saveSnapshotFrequency) saves the state tree to the
-// store. New-block callbacks (via asynchronous block-processor pins)
-// are triggered.
+// This function saves the block to the store and sometimes (every
+// SnapshotInterval blocks, or not more often than
+// saveSnapshotFrequency) saves the state tree to the store. New-block
+// callbacks (via asynchronous block-processor pins) are triggered.
 //
 // TODO(bobg): rename to CommitAppliedBlock for clarity (deferred from https://github.com/chain/chain/pull/788)
 func (c *Chain) CommitAppliedBlock(ctx context.Context, block *legacy.Block, snapshot *state.Snapshot) error {
+	if c.tier != nil {
+		tree, err := c.tier.Spill(*snapshot.Tree)
+		if err != nil {
+			return errors.Wrap(err, "spilling state tree")
+		}
+		snapshot = &state.Snapshot{Tree: &tree, Nonces: snapshot.Nonces}
+	}
+
 	// SaveBlock is the linearization point. Once the block is committed
 	// to persistent storage, the block has been applied and everything
 	// else can be derived from that block.
@@ -177,7 +262,7 @@ func (c *Chain) CommitAppliedBlock(ctx context.Context, block *legacy.Block, sna
 	if err != nil {
 		return errors.Wrap(err, "storing block")
 	}
-	if block.Time().After(c.lastQueuedSnapshot.Add(saveSnapshotFrequency)) {
+	if c.snapshotDue(block) {
 		c.queueSnapshot(ctx, block.Height, block.Time(), snapshot)
 	}
 
@@ -195,9 +280,24 @@ func (c *Chain) CommitAppliedBlock(ctx context.Context, block *legacy.Block, sna
 	// harmless; and the following call is required in the cases where
 	// it's not redundant.
 	c.setState(block, snapshot)
+	c.recordBlockTime(block)
 	return nil
 }
 
+// snapshotDue reports whether the state after block should be saved.
+func (c *Chain) snapshotDue(block *legacy.Block) bool {
+	c.snapMu.Lock()
+	interval, frequency := c.SnapshotInterval, c.SnapshotFrequency
+	c.snapMu.Unlock()
+	if interval > 0 {
+		return block.Height%interval == 0
+	}
+	if frequency == 0 {
+		frequency = saveSnapshotFrequency
+	}
+	return block.Time().After(c.lastQueuedSnapshot.Add(frequency))
+}
+
 func (c *Chain) queueSnapshot(ctx context.Context, height uint64, timestamp time.Time, s *state.Snapshot) {
 	// Non-blockingly queue the snapshot for storage.
 	ps := pendingSnapshot{height: height, snapshot: s}
@@ -206,8 +306,8 @@ func (c *Chain) queueSnapshot(ctx context.Context, height uint64, timestamp time
 		c.lastQueuedSnapshot = timestamp
 	default:
 		// Skip it; saving snapshots is taking longer than the snapshotting period.
-		log.Printf(ctx, "snapshot storage is taking too long; last queued at %s",
-			c.lastQueuedSnapshot)
+		protocolLog.Warnkv(ctx, log.KeyMessage, "snapshot storage is taking too long",
+			"last_queued", c.lastQueuedSnapshot)
 	}
 }
 
@@ -243,7 +343,11 @@ func (c *Chain) ValidateBlockForSig(ctx context.Context, block *legacy.Block) er
 		}
 	}
 
-	err := validation.ValidateBlock(legacy.MapBlock(block), legacy.MapBlock(prev), c.InitialBlockHash, c.ValidateTx)
+	err := c.checkRules(block)
+	if err != nil {
+		return err
+	}
+	err = validation.ValidateBlock(legacy.MapBlock(block), legacy.MapBlock(prev), c.InitialBlockHash, c.ValidateTx)
 	return errors.Sub(ErrBadBlock, err)
 }
 
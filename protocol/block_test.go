@@ -275,3 +275,27 @@ func mustDecodeHash(s string) (h bc.Hash) {
 	}
 	return h
 }
+
+func TestSnapshotDue(t *testing.T) {
+	c := &Chain{}
+	now := time.Now()
+	b := &legacy.Block{BlockHeader: legacy.BlockHeader{Height: 10, TimestampMS: bc.Millis(now)}}
+	c.lastQueuedSnapshot = now.Add(-time.Minute)
+	if c.snapshotDue(b) {
+		t.Error("snapshot due a minute after the last one")
+	}
+	c.lastQueuedSnapshot = now.Add(-2 * saveSnapshotFrequency)
+	if !c.snapshotDue(b) {
+		t.Error("snapshot not due after saveSnapshotFrequency")
+	}
+
+	c.SnapshotInterval = 5
+	if !c.snapshotDue(b) {
+		t.Error("snapshot not due at a multiple of the interval")
+	}
+	b.Height = 11
+	c.lastQueuedSnapshot = time.Time{}
+	if c.snapshotDue(b) {
+		t.Error("snapshot due between multiples of the interval")
+	}
+}
@@ -0,0 +1,96 @@
+package protocol
+
+import (
+	"context"
+	"sort"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+// medianTimeBlocks is the number of most recent blocks whose median
+// timestamp a new block's timestamp must be after.
+const medianTimeBlocks = 11
+
+// ErrBadBlockTime is returned by CheckBlockTime for a block whose
+// timestamp is not after the previous block's and the median time of
+// the recent blocks.
+var ErrBadBlockTime = errors.New("invalid block timestamp")
+
+// MedianTime returns the median timestamp of the most recent
+// medianTimeBlocks blocks, or of all the blocks if there are fewer,
+// or 0 if there are none.
+func (c *Chain) MedianTime(ctx context.Context) (uint64, error) {
+	c.blockTimes.Lock()
+	defer c.blockTimes.Unlock()
+	err := c.loadBlockTimes(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return median(c.blockTimes.recent), nil
+}
+
+// CheckBlockTime checks that the timestamp of b, a block made to
+// follow prev, is after prev's timestamp and the median time of the
+// recent blocks. The checks depend only on the chain, never on the
+// local clock, so every node agrees on them; a generator must not
+// commit a block failing either.
+func (c *Chain) CheckBlockTime(ctx context.Context, b, prev *legacy.Block) error {
+	if prev != nil && b.TimestampMS <= prev.TimestampMS {
+		return errors.WithDetailf(ErrBadBlockTime, "block %d time %d is not after previous block time %d", b.Height, b.TimestampMS, prev.TimestampMS)
+	}
+	mt, err := c.MedianTime(ctx)
+	if err != nil {
+		return err
+	}
+	if mt > 0 && b.TimestampMS <= mt {
+		return errors.WithDetailf(ErrBadBlockTime, "block %d time %d is not after median time %d", b.Height, b.TimestampMS, mt)
+	}
+	return nil
+}
+
+// loadBlockTimes reads the timestamps of the most recent blocks from
+// the store, unless they are already loaded. The caller must hold
+// c.blockTimes.
+func (c *Chain) loadBlockTimes(ctx context.Context) error {
+	if c.blockTimes.loaded {
+		return nil
+	}
+	height := c.Height()
+	var recent []uint64
+	for h := height; h > 0 && height-h < medianTimeBlocks; h-- {
+		b, err := c.store.GetBlock(ctx, h)
+		if err != nil {
+			return errors.Wrapf(err, "getting block %d for median time", h)
+		}
+		recent = append([]uint64{b.TimestampMS}, recent...)
+	}
+	c.blockTimes.recent = recent
+	c.blockTimes.loaded = true
+	return nil
+}
+
+// recordBlockTime adds the timestamp of b, just committed, to the
+// recent block timestamps, if they are loaded.
+func (c *Chain) recordBlockTime(b *legacy.Block) {
+	c.blockTimes.Lock()
+	defer c.blockTimes.Unlock()
+	if !c.blockTimes.loaded {
+		return
+	}
+	recent := append(c.blockTimes.recent, b.TimestampMS)
+	if len(recent) > medianTimeBlocks {
+		recent = recent[len(recent)-medianTimeBlocks:]
+	}
+	c.blockTimes.recent = recent
+}
+
+// median returns the median of times, or 0 if there are none.
+func median(times []uint64) uint64 {
+	if len(times) == 0 {
+		return 0
+	}
+	sorted := append([]uint64(nil), times...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2]
+}
@@ -0,0 +1,98 @@
+package protocol
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/prottest/memstore"
+	"github.com/chainmint/protocol/state"
+)
+
+func TestCheckBlockTime(t *testing.T) {
+	ctx := context.Background()
+	b1, err := NewInitialBlock(nil, 0, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewChain(ctx, b1.Hash(), memstore.New(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.CommitAppliedBlock(ctx, b1, state.Empty())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Out-of-order times, as if set by skewed proposers, so the
+	// median is below the latest.
+	prev := b1
+	for _, ms := range []uint64{5000, 3000, 4000, 9000} {
+		b := &legacy.Block{BlockHeader: legacy.BlockHeader{Height: prev.Height + 1, TimestampMS: ms}}
+		err = c.CommitAppliedBlock(ctx, b, state.Empty())
+		if err != nil {
+			t.Fatal(err)
+		}
+		prev = b
+	}
+	mt, err := c.MedianTime(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mt != 4000 {
+		t.Errorf("MedianTime = %d, want 4000", mt)
+	}
+
+	// The median tracks blocks committed after loading.
+	b := &legacy.Block{BlockHeader: legacy.BlockHeader{Height: 6, TimestampMS: 10000}}
+	err = c.CommitAppliedBlock(ctx, b, state.Empty())
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev = b
+	mt, _ = c.MedianTime(ctx)
+	if mt != 5000 {
+		t.Errorf("MedianTime after commit = %d, want 5000", mt)
+	}
+
+	cases := []struct {
+		ms   uint64
+		want error
+	}{
+		{10001, nil},
+		{1 << 50, nil},           // the local clock does not bound it
+		{10000, ErrBadBlockTime}, // not after the previous block
+	}
+	for _, tc := range cases {
+		next := &legacy.Block{BlockHeader: legacy.BlockHeader{Height: 7, TimestampMS: tc.ms}}
+		err := c.CheckBlockTime(ctx, next, prev)
+		if errors.Root(err) != tc.want {
+			t.Errorf("CheckBlockTime(%d) = %v, want %v", tc.ms, err, tc.want)
+		}
+	}
+
+	// Without a previous block, the median still bounds the time.
+	next := &legacy.Block{BlockHeader: legacy.BlockHeader{Height: 7, TimestampMS: 5000}}
+	err = c.CheckBlockTime(ctx, next, nil)
+	if errors.Root(err) != ErrBadBlockTime {
+		t.Errorf("CheckBlockTime(at median) = %v, want %v", err, ErrBadBlockTime)
+	}
+}
+
+func TestMedian(t *testing.T) {
+	cases := []struct {
+		times []uint64
+		want  uint64
+	}{
+		{nil, 0},
+		{[]uint64{7}, 7},
+		{[]uint64{3, 1, 2}, 2},
+		{[]uint64{4, 1, 3, 2}, 3},
+	}
+	for _, c := range cases {
+		if got := median(c.times); got != c.want {
+			t.Errorf("median(%v) = %d, want %d", c.times, got, c.want)
+		}
+	}
+}
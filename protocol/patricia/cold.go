@@ -0,0 +1,232 @@
+package patricia
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+)
+
+// ErrPageCorrupt is returned, in a panic, when a page read from a
+// ColdStore does not hold the subtree it was stored for.
+var ErrPageCorrupt = errors.New("cold page does not match its hash")
+
+// A ColdStore holds the items of the subtrees a Tier moves out of
+// memory, by the hash of each subtree. Since a subtree's hash
+// commits to its items, putting the same page twice stores the same
+// items.
+type ColdStore interface {
+	// PutPages stores the items of each page by its hash. Spill
+	// calls it once, with every page it writes.
+	PutPages(pages map[bc.Hash][][]byte) error
+
+	GetPage(hash bc.Hash) ([][]byte, error)
+}
+
+// A Tier keeps the subtrees of a tree, below a fixed depth, in a
+// ColdStore instead of memory, except for those used recently, so
+// that the memory a tree takes no longer grows with its items.
+//
+// Each such subtree is a page. Spill writes a page to the store once
+// it has changed, and replaces it in the tree with a stub holding
+// its hash and a Bloom filter of its items. A stub loads the page
+// when the tree is read or changed under it, and keeps it loaded
+// until it goes unused for the tier's hot period. Lookups of items
+// the filter rules out do not load the page at all.
+//
+// Trees with stubs are read and changed like any other. A page that
+// cannot be loaded makes the tree's methods panic, since the tree
+// cannot be read without it.
+type Tier struct {
+	store    ColdStore
+	pageBits int
+	hot      uint64
+
+	spills uint64 // atomic
+}
+
+// NewTier returns a Tier that keeps pages, the subtrees whose keys
+// are at least pageBits bits long, in store. A loaded page is
+// dropped from memory by the hot'th call to Spill that finds it
+// unused.
+func NewTier(store ColdStore, pageBits int, hot uint64) *Tier {
+	return &Tier{store: store, pageBits: pageBits, hot: hot}
+}
+
+// Spill returns a copy of t with each of its pages a stub, writing
+// the pages that have changed since the last spill to the store, and
+// dropping the loaded pages gone unused for the tier's hot period.
+// t itself is not modified, so it may still be read concurrently.
+func (c *Tier) Spill(t Tree) (Tree, error) {
+	spills := atomic.AddUint64(&c.spills, 1)
+	if t.root == nil {
+		return t, nil
+	}
+	pages := make(map[bc.Hash][][]byte)
+	root := c.spill(t.root, spills, pages)
+	if len(pages) > 0 {
+		err := c.store.PutPages(pages)
+		if err != nil {
+			return t, errors.Wrapf(err, "storing %d pages", len(pages))
+		}
+	}
+	return Tree{root: root}, nil
+}
+
+// spill returns n with each of its pages a stub, adding the items of
+// those that were not stubs already to pages.
+func (c *Tier) spill(n *node, spills uint64, pages map[bc.Hash][][]byte) *node {
+	if n.isLeaf {
+		return n
+	}
+	if n.page != nil {
+		n.page.dropIfUnused(spills)
+		return n
+	}
+	if len(n.key) >= c.pageBits {
+		var items [][]byte
+		walk(n, func(item []byte) error {
+			items = append(items, item)
+			return nil
+		})
+		hash := n.Hash()
+		pages[hash] = items
+		p := &page{tier: c, filter: newBloomFilter(len(items)), used: spills, root: n}
+		for _, item := range items {
+			p.filter.add(leafHash(item))
+		}
+		return &node{key: n.key, hash: &hash, page: p}
+	}
+
+	var children [2]*node
+	for i, child := range n.children {
+		children[i] = c.spill(child, spills, pages)
+	}
+	if children == n.children {
+		return n
+	}
+	n.calcHash()
+	newNode := new(node)
+	*newNode = *n
+	newNode.children = children
+	return newNode
+}
+
+// page is the part of a stub node that finds the subtree it stands
+// for.
+type page struct {
+	tier   *Tier
+	filter bloomFilter
+	used   uint64 // atomic; the spill that last found it used
+
+	mu   sync.Mutex
+	root *node // the subtree, while loaded, or nil
+}
+
+// resolve returns n, or if n is a stub, the subtree it stands for,
+// loading and keeping it if need be.
+func (n *node) resolve() *node {
+	if n.page == nil {
+		return n
+	}
+	p := n.page
+	atomic.StoreUint64(&p.used, atomic.LoadUint64(&p.tier.spills))
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.root == nil {
+		p.root = n.readPage()
+	}
+	return p.root
+}
+
+// view is resolve, but does not keep a page it loads, for reads that
+// visit many pages once, such as Walk.
+func (n *node) view() *node {
+	if n.page == nil {
+		return n
+	}
+	p := n.page
+	p.mu.Lock()
+	root := p.root
+	p.mu.Unlock()
+	if root != nil {
+		return root
+	}
+	return n.readPage()
+}
+
+// readPage reads the subtree stub n stands for from its store, and
+// checks it against n's hash.
+func (n *node) readPage() *node {
+	items, err := n.page.tier.store.GetPage(*n.hash)
+	if err != nil {
+		panic(errors.Wrapf(err, "loading page %x", n.hash.Bytes()))
+	}
+	var t Tree
+	for _, item := range items {
+		err = t.Insert(item)
+		if err != nil {
+			panic(errors.Wrapf(err, "loading page %x", n.hash.Bytes()))
+		}
+	}
+	if t.root == nil || t.root.Hash() != *n.hash {
+		panic(errors.WithDetailf(ErrPageCorrupt, "page %x", n.hash.Bytes()))
+	}
+	return t.root
+}
+
+// mayContain reports whether the page may hold the item whose leaf
+// has the given hash.
+func (p *page) mayContain(hash bc.Hash) bool {
+	return p.filter.mayContain(hash)
+}
+
+// dropIfUnused drops the loaded subtree of p if it has gone unused
+// for the tier's hot period as of the given spill.
+func (p *page) dropIfUnused(spills uint64) {
+	if atomic.LoadUint64(&p.used)+p.tier.hot > spills {
+		return
+	}
+	p.mu.Lock()
+	p.root = nil
+	p.mu.Unlock()
+}
+
+// Bloom filters are sized for about a 1% false positive rate.
+const (
+	bloomBitsPerItem = 10
+	bloomHashes      = 7
+)
+
+// bloomFilter is a Bloom filter of leaf hashes. Since the hashes are
+// already uniform, its hash functions are formed from their words by
+// double hashing.
+type bloomFilter []uint64
+
+func newBloomFilter(items int) bloomFilter {
+	bits := items * bloomBitsPerItem
+	if bits < 64 {
+		bits = 64
+	}
+	return make(bloomFilter, (bits+63)/64)
+}
+
+func (f bloomFilter) add(h bc.Hash) {
+	m := uint64(len(f)) * 64
+	for i := uint64(0); i < bloomHashes; i++ {
+		bit := (h.V0 + i*(h.V1|1)) % m
+		f[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+func (f bloomFilter) mayContain(h bc.Hash) bool {
+	m := uint64(len(f)) * 64
+	for i := uint64(0); i < bloomHashes; i++ {
+		bit := (h.V0 + i*(h.V1|1)) % m
+		if f[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
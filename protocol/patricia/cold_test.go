@@ -0,0 +1,184 @@
+package patricia
+
+import (
+	"bytes"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+)
+
+type memColdStore struct {
+	mu    sync.Mutex
+	pages map[bc.Hash][][]byte
+	gets  int
+}
+
+func (s *memColdStore) PutPages(pages map[bc.Hash][][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pages == nil {
+		s.pages = make(map[bc.Hash][][]byte)
+	}
+	for hash, items := range pages {
+		s.pages[hash] = items
+	}
+	return nil
+}
+
+func (s *memColdStore) GetPage(hash bc.Hash) ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gets++
+	items, ok := s.pages[hash]
+	if !ok {
+		return nil, errors.New("no such page")
+	}
+	return items, nil
+}
+
+func randItems(r *rand.Rand, n int) [][]byte {
+	items := make([][]byte, n)
+	for i := range items {
+		items[i] = make([]byte, 32)
+		r.Read(items[i])
+	}
+	return items
+}
+
+func walkItems(t *testing.T, tr *Tree) [][]byte {
+	var items [][]byte
+	err := Walk(tr, func(item []byte) error {
+		items = append(items, item)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return items
+}
+
+func TestTierSpill(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	items := randItems(r, 2000)
+	plain := new(Tree)
+	for _, item := range items {
+		err := plain.Insert(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store := new(memColdStore)
+	tier := NewTier(store, 6, 1)
+	cold, err := tier.Spill(*plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(store.pages) == 0 {
+		t.Fatal("no pages stored")
+	}
+	if cold.RootHash() != plain.RootHash() {
+		t.Fatal("spilled tree has a different root hash")
+	}
+
+	// Drop every loaded page, so that reads load them again.
+	for i := 0; i < 2; i++ {
+		cold, err = tier.Spill(cold)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, want := walkItems(t, &cold), walkItems(t, plain)
+	if len(got) != len(want) {
+		t.Fatalf("walked %d items, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if !bytes.Equal(got[i], want[i]) {
+			t.Fatalf("item %d = %x want %x", i, got[i], want[i])
+		}
+	}
+
+	// Lookups of absent items mostly stop at the Bloom filters.
+	store.gets = 0
+	for _, item := range randItems(r, 200) {
+		if cold.Contains(item) {
+			t.Errorf("contains absent item %x", item)
+		}
+	}
+	if store.gets > 20 {
+		t.Errorf("%d pages loaded for 200 absent items", store.gets)
+	}
+	for _, item := range items {
+		if !cold.Contains(item) {
+			t.Fatalf("missing item %x", item)
+		}
+	}
+
+	// Changes to the spilled tree match those to the plain one.
+	added := randItems(r, 500)
+	for i, item := range added {
+		plain.Delete(items[i])
+		cold.Delete(items[i])
+		err = plain.Insert(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = cold.Insert(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cold.RootHash() != plain.RootHash() {
+		t.Fatal("changed spilled tree has a different root hash")
+	}
+	before := cold
+	cold, err = tier.Spill(cold)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cold.RootHash() != plain.RootHash() {
+		t.Fatal("respilled tree has a different root hash")
+	}
+	if d := Diff(&before, &cold); d != nil {
+		t.Errorf("diff of equal trees = %+v", d)
+	}
+	proof, ok := cold.Prove(added[0])
+	if !ok || !VerifyProof(cold.RootHash(), added[0], proof) {
+		t.Error("cannot prove an item in the spilled tree")
+	}
+	if cold.Contains(items[0]) {
+		t.Error("contains deleted item")
+	}
+}
+
+func TestTierCorruptPage(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	tr := new(Tree)
+	for _, item := range randItems(r, 100) {
+		tr.Insert(item)
+	}
+	store := new(memColdStore)
+	tier := NewTier(store, 2, 0)
+	cold, err := tier.Spill(*tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cold, err = tier.Spill(cold) // drop the loaded pages
+	if err != nil {
+		t.Fatal(err)
+	}
+	for hash, items := range store.pages {
+		store.pages[hash] = items[1:]
+	}
+
+	defer func() {
+		err, _ := recover().(error)
+		if errors.Root(err) != ErrPageCorrupt {
+			t.Errorf("got panic %v, want %v", err, ErrPageCorrupt)
+		}
+	}()
+	Walk(&cold, func([]byte) error { return nil })
+}
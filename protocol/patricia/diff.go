@@ -0,0 +1,112 @@
+package patricia
+
+import (
+	"bytes"
+
+	"github.com/chainmint/protocol/bc"
+)
+
+// A Difference locates where two trees, a and b, first differ.
+type Difference struct {
+	// Prefix is the key prefix, as a string of 0 and 1 bits, of the
+	// deepest node the trees share whose hashes differ, and HashA
+	// and HashB are its hashes in each tree. If the trees share no
+	// such node, Prefix is empty and the hashes are the root hashes.
+	Prefix       string
+	HashA, HashB bc.Hash
+
+	// Item is the first item, in Walk order, that is in exactly one
+	// of the trees. InA reports whether that tree is a.
+	Item []byte
+	InA  bool
+}
+
+// Diff compares a and b and returns where they first differ, or nil
+// if they hold the same items. Subtrees with equal hashes are not
+// visited, so the time taken depends on the depth of the trees
+// rather than their size.
+func Diff(a, b *Tree) *Difference {
+	hashA, hashB := a.RootHash(), b.RootHash()
+	if hashA == hashB {
+		return nil
+	}
+	d := &Difference{HashA: hashA, HashB: hashB}
+	switch {
+	case a.root == nil:
+		d.Item = first(b.root)
+	case b.root == nil:
+		d.Item, d.InA = first(a.root), true
+	default:
+		d.Item, d.InA = diff(a.root, b.root, d)
+	}
+	return d
+}
+
+// diff returns the first item in exactly one of x, from a, and y,
+// from b, whose hashes differ, and whether it is in x. It records
+// in d the deepest pair of nodes it visits with the same key.
+func diff(x, y *node, d *Difference) ([]byte, bool) {
+	x, y = x.view(), y.view()
+	if bytes.Equal(x.key, y.key) {
+		d.Prefix, d.HashA, d.HashB = bitString(x.key), x.Hash(), y.Hash()
+		if x.isLeaf {
+			return x.Key(), true
+		}
+		if y.isLeaf {
+			return y.Key(), false
+		}
+		if x.children[0].Hash() != y.children[0].Hash() {
+			return diff(x.children[0], y.children[0], d)
+		}
+		return diff(x.children[1], y.children[1], d)
+	}
+	if bytes.HasPrefix(y.key, x.key) {
+		return diffWithin(x, y, d, true)
+	}
+	if bytes.HasPrefix(x.key, y.key) {
+		return diffWithin(y, x, d, false)
+	}
+	// The keys diverge, so neither subtree has any item of the
+	// other; whichever comes first holds the first difference.
+	common := commonPrefixLen(x.key, y.key)
+	if x.key[common] == 0 {
+		return first(x), true
+	}
+	return first(y), false
+}
+
+// diffWithin is diff for outer and inner, whose key has outer's as
+// a proper prefix. inA reports whether outer is from a.
+func diffWithin(outer, inner *node, d *Difference, inA bool) ([]byte, bool) {
+	outer = outer.view()
+	if outer.isLeaf {
+		return outer.Key(), inA
+	}
+	bit := inner.key[len(outer.key)]
+	if bit == 1 {
+		return first(outer.children[0]), inA
+	}
+	if outer.children[0].Hash() == inner.Hash() {
+		return first(outer.children[1]), inA
+	}
+	if inA {
+		return diff(outer.children[0], inner, d)
+	}
+	return diff(inner, outer.children[0], d)
+}
+
+// first returns the first item under n in Walk order.
+func first(n *node) []byte {
+	for n = n.view(); !n.isLeaf; n = n.view() {
+		n = n.children[0]
+	}
+	return n.Key()
+}
+
+func bitString(bitKey []uint8) string {
+	s := make([]byte, len(bitKey))
+	for i, bit := range bitKey {
+		s[i] = '0' + bit
+	}
+	return string(s)
+}
@@ -0,0 +1,84 @@
+package patricia
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	r := rand.New(rand.NewSource(12345))
+	for i := 0; i < 200; i++ {
+		var a, b Tree
+		for j := r.Intn(20); j > 0; j-- {
+			item := []byte{byte(r.Intn(256)), byte(r.Intn(4))}
+			switch r.Intn(4) {
+			case 0:
+				mustInsert(t, &a, item)
+			case 1:
+				mustInsert(t, &b, item)
+			default:
+				mustInsert(t, &a, item)
+				mustInsert(t, &b, item)
+			}
+		}
+
+		wantItem, wantInA := naiveDiff(t, &a, &b)
+		d := Diff(&a, &b)
+		if wantItem == nil {
+			if d != nil {
+				t.Fatalf("Diff of equal trees = %+v, want nil", d)
+			}
+			continue
+		}
+		if d == nil {
+			t.Fatalf("Diff = nil, want item %x", wantItem)
+		}
+		if !bytes.Equal(d.Item, wantItem) || d.InA != wantInA {
+			t.Errorf("Diff item = %x (in a: %t), want %x (in a: %t)", d.Item, d.InA, wantItem, wantInA)
+		}
+		if d.HashA == d.HashB {
+			t.Errorf("Diff node %q has equal hashes %x", d.Prefix, d.HashA.Bytes())
+		}
+	}
+}
+
+// naiveDiff returns the first item, in Walk order, in exactly one of
+// a and b, by walking both.
+func naiveDiff(t *testing.T, a, b *Tree) ([]byte, bool) {
+	items := func(tr *Tree) (s [][]byte) {
+		err := Walk(tr, func(item []byte) error {
+			s = append(s, item)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	x, y := items(a), items(b)
+	for len(x) > 0 && len(y) > 0 {
+		switch bytes.Compare(x[0], y[0]) {
+		case 0:
+			x, y = x[1:], y[1:]
+		case -1:
+			return x[0], true
+		default:
+			return y[0], false
+		}
+	}
+	if len(x) > 0 {
+		return x[0], true
+	}
+	if len(y) > 0 {
+		return y[0], false
+	}
+	return nil, false
+}
+
+func mustInsert(t *testing.T, tr *Tree, item []byte) {
+	err := tr.Insert(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -52,6 +52,7 @@ func Walk(t *Tree, walkFn WalkFunc) error {
 }
 
 func walk(n *node, walkFn WalkFunc) error {
+	n = n.view()
 	if n.isLeaf {
 		return walkFn(n.Key())
 	}
@@ -71,19 +72,20 @@ func (t *Tree) Contains(item []byte) bool {
 		return false
 	}
 
-	key := bitKey(item)
-	n := lookup(t.root, key)
-
-	var hash bc.Hash
-	h := sha3pool.Get256()
-	h.Write(leafPrefix)
-	h.Write(item)
-	hash.ReadFrom(h)
-	sha3pool.Put256(h)
+	hash := leafHash(item)
+	n := lookup(t.root, bitKey(item), hash)
 	return n != nil && n.Hash() == hash
 }
 
-func lookup(n *node, key []uint8) *node {
+// lookup returns the leaf with the given key and hash under n, or
+// nil. Pages whose filters rule out the hash are not loaded.
+func lookup(n *node, key []uint8, hash bc.Hash) *node {
+	if n.page != nil {
+		if !n.page.mayContain(hash) {
+			return nil
+		}
+		n = n.resolve()
+	}
 	if bytes.Equal(n.key, key) {
 		if !n.isLeaf {
 			return nil
@@ -95,7 +97,7 @@ func lookup(n *node, key []uint8) *node {
 	}
 
 	bit := key[len(n.key)]
-	return lookup(n.children[bit], key)
+	return lookup(n.children[bit], key, hash)
 }
 
 // Insert inserts item into t.
@@ -106,13 +108,7 @@ func lookup(n *node, key []uint8) *node {
 // (and this is not an error).
 func (t *Tree) Insert(item []byte) error {
 	key := bitKey(item)
-
-	var hash bc.Hash
-	h := sha3pool.Get256()
-	h.Write(leafPrefix)
-	h.Write(item)
-	hash.ReadFrom(h)
-	sha3pool.Put256(h)
+	hash := leafHash(item)
 
 	if t.root == nil {
 		t.root = &node{key: key, hash: &hash, isLeaf: true}
@@ -142,6 +138,7 @@ func insert(n *node, key []uint8, hash *bc.Hash) (*node, error) {
 		if n.isLeaf {
 			return n, errors.Wrap(errors.New("key provided is a prefix to other keys"))
 		}
+		n = n.resolve()
 		bit := key[len(n.key)]
 
 		child := n.children[bit]
@@ -190,6 +187,7 @@ func delete(n *node, key []uint8) *node {
 		return n
 	}
 
+	n = n.resolve()
 	bit := key[len(n.key)]
 	newChild := delete(n.children[bit], key)
 
@@ -215,6 +213,17 @@ func (t *Tree) RootHash() bc.Hash {
 	return root.Hash()
 }
 
+// leafHash returns the hash of the leaf holding item.
+func leafHash(item []byte) bc.Hash {
+	var hash bc.Hash
+	h := sha3pool.Get256()
+	h.Write(leafPrefix)
+	h.Write(item)
+	hash.ReadFrom(h)
+	sha3pool.Put256(h)
+	return hash
+}
+
 // bitKey takes a byte array and returns a key that can
 // be used inside insert and delete operations.
 func bitKey(byteKey []byte) []uint8 {
@@ -252,12 +261,15 @@ func commonPrefixLen(a, b []uint8) int {
 	return common
 }
 
-// node is a leaf or branch node in a tree
+// node is a leaf or branch node in a tree, or a stub standing for
+// a branch node whose subtree is in a Tier's cold store. A stub has
+// a page, and its hash, but no children; see resolve.
 type node struct {
 	key      []uint8
 	hash     *bc.Hash
 	isLeaf   bool
 	children [2]*node
+	page     *page
 }
 
 // Key returns the key for the current node as bytes, as it
@@ -0,0 +1,64 @@
+package patricia
+
+import (
+	"bytes"
+
+	"github.com/chainmint/crypto/sha3pool"
+	"github.com/chainmint/protocol/bc"
+)
+
+// A ProofStep is the hash of a sibling on the path from an item's
+// leaf to the root of a tree. Left reports whether the sibling is
+// the left child of their parent.
+type ProofStep struct {
+	Hash bc.Hash `json:"hash"`
+	Left bool    `json:"left"`
+}
+
+// Prove returns the steps, from the leaf up, that show item is in
+// t, and false if it is not.
+func (t *Tree) Prove(item []byte) ([]ProofStep, bool) {
+	key := bitKey(item)
+	var steps []ProofStep
+	n := t.root
+	for n != nil && !n.isLeaf {
+		n = n.view()
+		if !bytes.HasPrefix(key, n.key) || len(key) == len(n.key) {
+			return nil, false
+		}
+		bit := key[len(n.key)]
+		steps = append(steps, ProofStep{Hash: n.children[1-bit].Hash(), Left: bit == 1})
+		n = n.children[bit]
+	}
+	if n == nil || !bytes.Equal(n.key, key) {
+		return nil, false
+	}
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+	return steps, true
+}
+
+// VerifyProof reports whether proof, as returned by Prove, shows
+// that item is in a tree with the given root hash.
+func VerifyProof(root bc.Hash, item []byte, proof []ProofStep) bool {
+	var hash bc.Hash
+	h := sha3pool.Get256()
+	defer sha3pool.Put256(h)
+	h.Write(leafPrefix)
+	h.Write(item)
+	hash.ReadFrom(h)
+	for _, step := range proof {
+		h.Reset()
+		h.Write(interiorPrefix)
+		if step.Left {
+			step.Hash.WriteTo(h)
+			hash.WriteTo(h)
+		} else {
+			hash.WriteTo(h)
+			step.Hash.WriteTo(h)
+		}
+		hash.ReadFrom(h)
+	}
+	return hash == root
+}
@@ -0,0 +1,44 @@
+package patricia
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestProve(t *testing.T) {
+	r := rand.New(rand.NewSource(12345))
+	var tr Tree
+	var items [][]byte
+	for i := 0; i < 50; i++ {
+		item := make([]byte, 8)
+		r.Read(item)
+		mustInsert(t, &tr, item)
+		items = append(items, item)
+	}
+	root := tr.RootHash()
+	for _, item := range items {
+		proof, ok := tr.Prove(item)
+		if !ok {
+			t.Fatalf("Prove(%x) found no item", item)
+		}
+		if !VerifyProof(root, item, proof) {
+			t.Errorf("VerifyProof(%x) = false, want true", item)
+		}
+		other := append([]byte(nil), item...)
+		other[0] ^= 1
+		if VerifyProof(root, other, proof) {
+			t.Errorf("VerifyProof(%x) with the proof of %x = true, want false", other, item)
+		}
+	}
+	_, ok := tr.Prove([]byte{1, 2, 3, 4, 5, 6, 7, 8})
+	if ok {
+		t.Error("Prove of a missing item found it")
+	}
+
+	var single Tree
+	mustInsert(t, &single, items[0])
+	proof, ok := single.Prove(items[0])
+	if !ok || len(proof) != 0 || !VerifyProof(single.RootHash(), items[0], proof) {
+		t.Errorf("Prove in a one-item tree = %v, %t", proof, ok)
+	}
+}
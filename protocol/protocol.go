@@ -51,7 +51,9 @@ import (
 	"github.com/chainmint/log"
 	"github.com/chainmint/protocol/bc"
 	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/patricia"
 	"github.com/chainmint/protocol/state"
+	"github.com/chainmint/protocol/upgrade"
 )
 
 // maxCachedValidatedTxs is the max number of validated txs to cache.
@@ -88,6 +90,40 @@ type Chain struct {
 	InitialBlockHash  bc.Hash
 	MaxIssuanceWindow time.Duration // only used by generators
 
+	// SnapshotInterval, if set, is the number of blocks between
+	// saved state snapshots: the state is saved after every block
+	// whose height is a multiple of it, so that nodes save it at
+	// the same heights. If zero, it is saved at most once per
+	// saveSnapshotFrequency.
+	SnapshotInterval uint64
+
+	// SnapshotFrequency, if set, replaces saveSnapshotFrequency.
+	SnapshotFrequency time.Duration
+
+	// snapMu protects SnapshotInterval and SnapshotFrequency
+	// once the chain is in use; see SetSnapshotSchedule.
+	snapMu sync.Mutex
+
+	// limits bounds the blocks GenerateBlock makes; see
+	// SetBlockLimits.
+	limits struct {
+		sync.Mutex
+		txs   int
+		bytes int64
+	}
+
+	// blockTimes holds the timestamps of the most recent blocks,
+	// oldest first, once loaded.
+	blockTimes struct {
+		sync.Mutex
+		recent []uint64
+		loaded bool
+	}
+
+	// Upgrades holds the upgrades scheduled on the chain, which
+	// set the rules blocks are validated and built by; see Rules.
+	Upgrades upgrade.Schedule
+
 	state struct {
 		cond     sync.Cond // protects height, block, snapshot
 		height   uint64
@@ -96,6 +132,10 @@ type Chain struct {
 	}
 	store Store
 
+	// tier, if set, keeps the state tree's pages out of memory;
+	// see SetColdTier.
+	tier *patricia.Tier
+
 	lastQueuedSnapshot time.Time
 	pendingSnapshots   chan pendingSnapshot
 
@@ -151,6 +191,68 @@ func NewChain(ctx context.Context, initialBlockHash bc.Hash, store Store, height
 	return c, nil
 }
 
+// SetSnapshotSchedule changes SnapshotInterval and SnapshotFrequency.
+// Unlike setting the fields, it is safe while blocks are committed.
+func (c *Chain) SetSnapshotSchedule(interval uint64, frequency time.Duration) {
+	c.snapMu.Lock()
+	defer c.snapMu.Unlock()
+	c.SnapshotInterval = interval
+	c.SnapshotFrequency = frequency
+}
+
+// SnapshotSchedule returns SnapshotInterval and SnapshotFrequency.
+func (c *Chain) SnapshotSchedule() (interval uint64, frequency time.Duration) {
+	c.snapMu.Lock()
+	defer c.snapMu.Unlock()
+	return c.SnapshotInterval, c.SnapshotFrequency
+}
+
+// SetBlockLimits sets the most transactions, and the most bytes of
+// serialized transactions, in a block made by GenerateBlock. A limit
+// of zero is the default: maxBlockTxs transactions, and any number
+// of bytes.
+func (c *Chain) SetBlockLimits(maxTxs int, maxBytes int64) {
+	c.limits.Lock()
+	defer c.limits.Unlock()
+	c.limits.txs = maxTxs
+	c.limits.bytes = maxBytes
+}
+
+// BlockLimits returns the limits set by SetBlockLimits, with the
+// default in place of a zero transaction limit. A byte limit of zero
+// means no limit.
+func (c *Chain) BlockLimits() (maxTxs int, maxBytes int64) {
+	c.limits.Lock()
+	defer c.limits.Unlock()
+	maxTxs = c.limits.txs
+	if maxTxs <= 0 {
+		maxTxs = maxBlockTxs
+	}
+	return maxTxs, c.limits.bytes
+}
+
+// SetTxCacheSize sets the number of tx validation results cached,
+// evicting the least recently used if there are more.
+func (c *Chain) SetTxCacheSize(n int) {
+	c.prevalidated.setSize(n)
+}
+
+// SetColdTier makes c keep most of the state tree in t's store
+// rather than memory, spilling the tree to it as each block is
+// committed, so that the memory the state takes stays bounded as
+// the number of unspent outputs grows. It must be called before c
+// commits any blocks.
+func (c *Chain) SetColdTier(t *patricia.Tier) {
+	c.tier = t
+}
+
+// Rules returns the protocol rules in force at height. It returns
+// upgrade.ErrMissingUpgrade if they are set by an upgrade this
+// release does not have.
+func (c *Chain) Rules(height uint64) (upgrade.Rules, error) {
+	return c.Upgrades.Rules(height)
+}
+
 // Height returns the current height of the blockchain.
 func (c *Chain) Height() uint64 {
 	c.state.cond.L.Lock()
@@ -0,0 +1,118 @@
+package protocol
+
+import (
+	"context"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/state"
+	"github.com/chainmint/protocol/upgrade"
+)
+
+// Kinds of divergence found by a Replayer.
+const (
+	DivergedBlock     = "block"      // the block is invalid against the replayed chain
+	DivergedStateRoot = "state_root" // the block's state root is not the replayed one
+	DivergedSnapshot  = "snapshot"   // a stored snapshot's state root is not the replayed one
+)
+
+// A Divergence is the first block at which a replay of the stored
+// chain disagrees with it.
+type Divergence struct {
+	Height   uint64  `json:"height"`
+	Kind     string  `json:"kind"`
+	Stored   bc.Hash `json:"stored"`
+	Replayed bc.Hash `json:"replayed"`
+	Detail   string  `json:"detail,omitempty"`
+}
+
+// A Replayer re-executes the chain's stored blocks from the initial
+// block, starting from the empty state, and checks each against the
+// result: that it is valid and follows the block before it, that its
+// state root is the root of the replayed state, and that the latest
+// stored snapshot, when the replay reaches its height, has the same
+// root. This finds stored blocks and snapshots that the node would
+// not produce again, whether corrupted or applied
+// nondeterministically, which a node applying only new blocks does
+// not notice.
+//
+// A Replayer keeps the replayed state between calls to Replay, so
+// that it can follow the chain as it grows.
+type Replayer struct {
+	chain    *Chain
+	prev     *legacy.Block
+	snapshot *state.Snapshot
+}
+
+// NewReplayer returns a Replayer that has replayed no blocks.
+func (c *Chain) NewReplayer() *Replayer {
+	return &Replayer{chain: c, snapshot: state.Empty()}
+}
+
+// Height returns the height of the last block replayed.
+func (r *Replayer) Height() uint64 {
+	if r.prev == nil {
+		return 0
+	}
+	return r.prev.Height
+}
+
+// Replay replays the stored blocks above r.Height() up to and
+// including height to, and returns the first divergence, or nil if
+// they all replay as stored. A divergent block is not replayed, so
+// r stays at the block before it.
+//
+// It returns an error, rather than a divergence, if a block cannot be
+// read, or if this release lacks an upgrade needed to validate it.
+func (r *Replayer) Replay(ctx context.Context, to uint64) (*Divergence, error) {
+	snap, snapHeight, err := r.chain.store.LatestSnapshot(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading latest snapshot")
+	}
+	for h := r.Height() + 1; h <= to; h++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		b, err := r.chain.store.GetBlock(ctx, h)
+		if err != nil {
+			return nil, errors.Wrapf(err, "loading block %d", h)
+		}
+		d, err := r.replayBlock(b)
+		if d != nil || err != nil {
+			return d, err
+		}
+		if h == snapHeight && snap.Tree.RootHash() != r.snapshot.Tree.RootHash() {
+			return &Divergence{Height: h, Kind: DivergedSnapshot, Stored: snap.Tree.RootHash(), Replayed: r.snapshot.Tree.RootHash()}, nil
+		}
+	}
+	return nil, nil
+}
+
+// replayBlock validates b against the replayed chain and applies it
+// to the replayed state.
+func (r *Replayer) replayBlock(b *legacy.Block) (*Divergence, error) {
+	if b.Height == 1 {
+		if h := b.Hash(); h != r.chain.InitialBlockHash {
+			return &Divergence{Height: 1, Kind: DivergedBlock, Stored: h, Replayed: r.chain.InitialBlockHash, Detail: "initial block hash is not the chain's"}, nil
+		}
+	} else {
+		err := r.chain.ValidateBlock(b, r.prev)
+		if errors.Root(err) == upgrade.ErrMissingUpgrade {
+			return nil, err
+		} else if err != nil {
+			return &Divergence{Height: b.Height, Kind: DivergedBlock, Stored: b.Hash(), Detail: errors.Detail(err)}, nil
+		}
+	}
+
+	s := state.Copy(r.snapshot)
+	err := s.ApplyBlock(legacy.MapBlock(b))
+	if err != nil {
+		return &Divergence{Height: b.Height, Kind: DivergedBlock, Stored: b.Hash(), Detail: errors.Detail(err)}, nil
+	}
+	if root := s.Tree.RootHash(); root != b.AssetsMerkleRoot {
+		return &Divergence{Height: b.Height, Kind: DivergedStateRoot, Stored: b.AssetsMerkleRoot, Replayed: root}, nil
+	}
+	r.prev, r.snapshot = b, s
+	return nil, nil
+}
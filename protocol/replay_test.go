@@ -0,0 +1,77 @@
+package protocol
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/prottest/memstore"
+	"github.com/chainmint/protocol/state"
+)
+
+func TestReplay(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New()
+	b1, err := NewInitialBlock(nil, 0, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewChain(ctx, b1.Hash(), store, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.CommitAppliedBlock(ctx, b1, state.Empty())
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev, s := b1, state.Empty()
+	for i := 0; i < 3; i++ {
+		prev, s, err = c.GenerateBlock(ctx, prev, s, prev.TimestampMS+1000, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = c.CommitAppliedBlock(ctx, prev, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := c.NewReplayer()
+	d, err := r.Replay(ctx, c.Height())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != nil || r.Height() != 4 {
+		t.Fatalf("Replay = %+v at height %d, want no divergence at height 4", d, r.Height())
+	}
+
+	// A block whose state root was stored wrong.
+	bad := *prev
+	bad.Height, bad.PreviousBlockHash = 5, prev.Hash()
+	bad.TimestampMS += 1000
+	bad.AssetsMerkleRoot = bc.NewHash([32]byte{1})
+	store.Blocks[5] = &bad
+	d, err = r.Replay(ctx, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Divergence{Height: 5, Kind: DivergedStateRoot, Stored: bad.AssetsMerkleRoot, Replayed: s.Tree.RootHash()}
+	if d == nil || *d != *want {
+		t.Errorf("Replay = %+v, want %+v", d, want)
+	}
+	if r.Height() != 4 {
+		t.Errorf("Height after divergence = %d, want 4", r.Height())
+	}
+
+	// A block that does not follow the one before it.
+	bad.PreviousBlockHash = b1.Hash()
+	bad.AssetsMerkleRoot = s.Tree.RootHash()
+	store.Blocks[5] = &bad
+	d, err = r.Replay(ctx, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d == nil || d.Kind != DivergedBlock || d.Height != 5 {
+		t.Errorf("Replay = %+v, want a divergent block at height 5", d)
+	}
+}
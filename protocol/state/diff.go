@@ -0,0 +1,50 @@
+package state
+
+import (
+	"bytes"
+
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/patricia"
+)
+
+// A Diff reports where two snapshots, a and b, first differ.
+type Diff struct {
+	// Tree locates the first difference between the state trees, or
+	// is nil if they hold the same outputs.
+	Tree *patricia.Difference
+
+	// Nonce is the lowest nonce ID in one nonce set but not the
+	// other, or in both with different expiry times, or nil if the
+	// sets are equal. ExpiryA and ExpiryB are its expiry times in
+	// each, or zero where it is absent.
+	Nonce            *bc.Hash
+	ExpiryA, ExpiryB uint64
+}
+
+// Compare compares a and b and returns where they first differ, or
+// nil if they are equal.
+func Compare(a, b *Snapshot) *Diff {
+	d := &Diff{Tree: patricia.Diff(a.Tree, b.Tree)}
+	for id, expiry := range a.Nonces {
+		if e, ok := b.Nonces[id]; !ok || e != expiry {
+			d.noteNonce(id, a, b)
+		}
+	}
+	for id := range b.Nonces {
+		if _, ok := a.Nonces[id]; !ok {
+			d.noteNonce(id, a, b)
+		}
+	}
+	if d.Tree == nil && d.Nonce == nil {
+		return nil
+	}
+	return d
+}
+
+func (d *Diff) noteNonce(id bc.Hash, a, b *Snapshot) {
+	if d.Nonce != nil && bytes.Compare(d.Nonce.Bytes(), id.Bytes()) < 0 {
+		return
+	}
+	d.Nonce = &id
+	d.ExpiryA, d.ExpiryB = a.Nonces[id], b.Nonces[id]
+}
@@ -0,0 +1,40 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/chainmint/protocol/bc"
+)
+
+func TestCompare(t *testing.T) {
+	a, b := Empty(), Empty()
+	if d := Compare(a, b); d != nil {
+		t.Fatalf("Compare(empty, empty) = %+v, want nil", d)
+	}
+
+	out1, out2 := bc.NewHash([32]byte{1}), bc.NewHash([32]byte{2})
+	nonce1, nonce2 := bc.NewHash([32]byte{3}), bc.NewHash([32]byte{4})
+	for _, s := range []*Snapshot{a, b} {
+		s.Tree.Insert(out1.Bytes())
+		s.Nonces[nonce1] = 10
+	}
+	b.Tree.Insert(out2.Bytes())
+	a.Nonces[nonce2] = 20
+	b.Nonces[nonce1] = 15
+
+	d := Compare(a, b)
+	if d == nil {
+		t.Fatal("Compare = nil, want difference")
+	}
+	if d.Tree == nil || bc.NewHash(sliceTo32(d.Tree.Item)) != out2 || d.Tree.InA {
+		t.Errorf("tree difference = %+v, want %x only in b", d.Tree, out2.Bytes())
+	}
+	if d.Nonce == nil || *d.Nonce != nonce1 || d.ExpiryA != 10 || d.ExpiryB != 15 {
+		t.Errorf("nonce difference = %v (%d, %d), want %x (10, 15)", d.Nonce, d.ExpiryA, d.ExpiryB, nonce1.Bytes())
+	}
+}
+
+func sliceTo32(b []byte) (a [32]byte) {
+	copy(a[:], b)
+	return a
+}
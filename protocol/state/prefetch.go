@@ -0,0 +1,84 @@
+package state
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/chainmint/protocol/bc"
+)
+
+// minParallelLookups is the number of spent outputs below which
+// Prefetch looks them up on the calling goroutine; for fewer, the
+// cost of starting workers outweighs the lookups.
+const minParallelLookups = 64
+
+// Prefetched records which of the outputs spent by a block's txs
+// were in the state tree before any of them was applied, so that
+// applying the txs in order with ApplyPrefetchedTx needs no further
+// lookups in the tree. It also tracks the outputs the txs applied so
+// far have spent and created, to catch double spends and spends of
+// outputs created earlier in the block.
+type Prefetched struct {
+	present map[bc.Hash]bool
+	spent   map[bc.Hash]bool
+	created map[bc.Hash]bool
+}
+
+// Prefetch looks up the outputs spent by txs in s's state tree,
+// concurrently for large blocks. s must not be modified until the
+// lookups are done, when Prefetch returns.
+func (s *Snapshot) Prefetch(txs []*bc.Tx) *Prefetched {
+	var ids []bc.Hash
+	for _, tx := range txs {
+		ids = append(ids, tx.SpentOutputIDs...)
+	}
+	found := make([]bool, len(ids))
+	workers := runtime.GOMAXPROCS(0)
+	if len(ids) < minParallelLookups || workers < 2 {
+		workers = 1
+	}
+
+	// The tree is a persistent data structure, so a copy of it can
+	// be read from many goroutines at once.
+	tree := *s.Tree
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(ids); i += workers {
+				found[i] = tree.Contains(ids[i].Bytes())
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	p := &Prefetched{
+		present: make(map[bc.Hash]bool, len(ids)),
+		spent:   make(map[bc.Hash]bool, len(ids)),
+		created: make(map[bc.Hash]bool),
+	}
+	for i, id := range ids {
+		if found[i] {
+			p.present[id] = true
+		}
+	}
+	return p
+}
+
+// spend reports whether id can be spent, and if so marks it spent.
+func (p *Prefetched) spend(id bc.Hash) bool {
+	if p.spent[id] || (!p.present[id] && !p.created[id]) {
+		return false
+	}
+	p.spent[id] = true
+	return true
+}
+
+// ApplyPrefetchedTx updates s in place, as ApplyTx does, checking
+// the outputs tx spends against p rather than the state tree. The
+// txs given to Prefetch must be applied in order, and s must not be
+// otherwise modified in between.
+func (s *Snapshot) ApplyPrefetchedTx(tx *bc.Tx, p *Prefetched) error {
+	return s.applyTx(tx, nil, p)
+}
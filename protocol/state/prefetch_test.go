@@ -0,0 +1,70 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+func TestApplyPrefetchedTx(t *testing.T) {
+	assetID := bc.AssetID{}
+	snap := Empty()
+
+	// Enough spends of outputs already in the tree that they are
+	// looked up concurrently.
+	var txs []*bc.Tx
+	for i := 0; i < 2*minParallelLookups; i++ {
+		sourceID := bc.NewHash([32]byte{byte(i), byte(i >> 8), 1})
+		in := legacy.NewSpendInput(nil, sourceID, assetID, 100, 0, nil, bc.Hash{}, nil)
+		spentID, err := in.SpentOutputID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		snap.Tree.Insert(spentID.Bytes())
+		txs = append(txs, legacy.MapTx(&legacy.TxData{
+			Version: 1,
+			Inputs:  []*legacy.TxInput{in},
+			Outputs: []*legacy.TxOutput{legacy.NewTxOutput(assetID, 100, []byte{byte(i)}, nil)},
+		}))
+	}
+
+	// A spend of an output created earlier in the block.
+	created := txs[0].Entries[*txs[0].ResultIds[0]].(*bc.Output)
+	in := legacy.NewSpendInput(nil, *created.Source.Ref, assetID, 100, created.Source.Position, []byte{0}, *created.Data, nil)
+	spentID, err := in.SpentOutputID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spentID != *txs[0].ResultIds[0] {
+		t.Fatalf("spend of created output has ID %x, want %x", spentID.Bytes(), txs[0].ResultIds[0].Bytes())
+	}
+	txs = append(txs, legacy.MapTx(&legacy.TxData{Version: 1, Inputs: []*legacy.TxInput{in}}))
+
+	want := Copy(snap)
+	for i, tx := range txs {
+		err := want.ApplyTx(tx)
+		if err != nil {
+			t.Fatalf("ApplyTx(tx %d) = %v", i, err)
+		}
+	}
+
+	got := Copy(snap)
+	p := got.Prefetch(append(txs, txs[1]))
+	for i, tx := range txs {
+		err := got.ApplyPrefetchedTx(tx, p)
+		if err != nil {
+			t.Fatalf("ApplyPrefetchedTx(tx %d) = %v", i, err)
+		}
+	}
+	if got.Tree.RootHash() != want.Tree.RootHash() {
+		t.Errorf("state root after prefetched txs = %x, want %x", got.Tree.RootHash().Bytes(), want.Tree.RootHash().Bytes())
+	}
+
+	// A second spend of an output in the block is rejected, though
+	// the output was in the tree when prefetched.
+	err = got.ApplyPrefetchedTx(txs[1], p)
+	if err == nil {
+		t.Error("ApplyPrefetchedTx of a double spend succeeded, want error")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/chainmint/errors"
+	"github.com/chainmint/errors/errcode"
 	"github.com/chainmint/protocol/bc"
 	"github.com/chainmint/protocol/patricia"
 )
@@ -57,11 +58,13 @@ func Empty() *Snapshot {
 	}
 }
 
-// ApplyBlock updates s in place.
+// ApplyBlock updates s in place. The outputs the block spends are
+// looked up concurrently, with Prefetch, before its txs are applied.
 func (s *Snapshot) ApplyBlock(block *bc.Block) error {
 	s.PruneNonces(block.TimestampMs)
+	p := s.Prefetch(block.Transactions)
 	for i, tx := range block.Transactions {
-		err := s.ApplyTx(tx)
+		err := s.applyTx(tx, nil, p)
 		if err != nil {
 			return errors.Wrapf(err, "applying block transaction %d", i)
 		}
@@ -69,13 +72,60 @@ func (s *Snapshot) ApplyBlock(block *bc.Block) error {
 	return nil
 }
 
-// ApplyTx updates s in place.
+// ApplyTx updates s in place. If tx spends an output not in s, or
+// reuses a nonce in s, the error carries code errcode.DoubleSpend
+// or errcode.NonceConflict; see errors.Code.
 func (s *Snapshot) ApplyTx(tx *bc.Tx) error {
+	return s.applyTx(tx, nil, nil)
+}
+
+// TraceTx updates s in place, as ApplyTx does, and returns the
+// reads and writes of s that applying tx made, in order. If tx
+// cannot be applied, the accesses made up to the failure are
+// returned with the error.
+func (s *Snapshot) TraceTx(tx *bc.Tx) ([]Access, error) {
+	var trace []Access
+	err := s.applyTx(tx, &trace, nil)
+	return trace, err
+}
+
+// Kinds of state, and operations on it, recorded in an Access.
+const (
+	KindNonce  = "nonce"
+	KindOutput = "output"
+
+	OpRead   = "read"
+	OpInsert = "insert"
+	OpDelete = "delete"
+)
+
+// Access is a read or write of a snapshot made applying a
+// transaction. ID is the ID of the nonce or output accessed, and
+// ExpiryMS, for a nonce inserted, the time it expires from the
+// nonce set.
+type Access struct {
+	Kind     string
+	Op       string
+	ID       bc.Hash
+	ExpiryMS uint64
+}
+
+// applyTx updates s in place, appending the accesses it makes to
+// *trace if trace is not nil. If p is not nil, spent outputs are
+// checked against it instead of the state tree.
+func (s *Snapshot) applyTx(tx *bc.Tx, trace *[]Access, p *Prefetched) error {
+	record := func(kind, op string, id bc.Hash, expiryMS uint64) {
+		if trace != nil {
+			*trace = append(*trace, Access{Kind: kind, Op: op, ID: id, ExpiryMS: expiryMS})
+		}
+	}
+
 	for _, n := range tx.NonceIDs {
 		// Add new nonces. They must not conflict with nonces already
 		// present.
+		record(KindNonce, OpRead, n, 0)
 		if _, ok := s.Nonces[n]; ok {
-			return fmt.Errorf("conflicting nonce %x", n.Bytes())
+			return errors.WithCode(fmt.Errorf("conflicting nonce %x", n.Bytes()), errcode.NonceConflict)
 		}
 
 		nonce, err := tx.Nonce(n)
@@ -88,14 +138,17 @@ func (s *Snapshot) ApplyTx(tx *bc.Tx) error {
 		}
 
 		s.Nonces[n] = tr.MaxTimeMs
+		record(KindNonce, OpInsert, n, tr.MaxTimeMs)
 	}
 
 	// Remove spent outputs. Each output must be present.
 	for _, prevout := range tx.SpentOutputIDs {
-		if !s.Tree.Contains(prevout.Bytes()) {
-			return fmt.Errorf("invalid prevout %x", prevout.Bytes())
+		record(KindOutput, OpRead, prevout, 0)
+		if p != nil && !p.spend(prevout) || p == nil && !s.Tree.Contains(prevout.Bytes()) {
+			return errors.WithCode(fmt.Errorf("invalid prevout %x", prevout.Bytes()), errcode.DoubleSpend)
 		}
 		s.Tree.Delete(prevout.Bytes())
+		record(KindOutput, OpDelete, prevout, 0)
 	}
 
 	// Add new outputs. They must not yet be present.
@@ -111,6 +164,10 @@ func (s *Snapshot) ApplyTx(tx *bc.Tx) error {
 		if err != nil {
 			return err
 		}
+		if p != nil {
+			p.created[*id] = true
+		}
+		record(KindOutput, OpInsert, *id, 0)
 	}
 	return nil
 }
@@ -5,6 +5,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/chainmint/errors"
+	"github.com/chainmint/errors/errcode"
 	"github.com/chainmint/protocol/bc"
 	"github.com/chainmint/protocol/bc/bctest"
 	"github.com/chainmint/protocol/bc/legacy"
@@ -48,6 +50,8 @@ func TestApplyTxSpend(t *testing.T) {
 	err = snap.ApplyTx(tx)
 	if err == nil {
 		t.Error("expected error applying spend twice, got nil")
+	} else if got := errors.Code(err); got != errcode.DoubleSpend {
+		t.Errorf("code = %s want %s", got, errcode.DoubleSpend)
 	}
 }
 
@@ -61,6 +65,8 @@ func TestApplyIssuanceTwice(t *testing.T) {
 	err = snap.ApplyTx(issuance)
 	if err == nil {
 		t.Errorf("expected error for duplicate nonce, got %s", err)
+	} else if got := errors.Code(err); got != errcode.NonceConflict {
+		t.Errorf("code = %s want %s", got, errcode.NonceConflict)
 	}
 }
 
@@ -105,3 +111,52 @@ func TestApplyBlock(t *testing.T) {
 		t.Errorf("got %d nonces, want 0", n)
 	}
 }
+
+func TestTraceTx(t *testing.T) {
+	assetID := bc.AssetID{}
+	sourceID := bc.NewHash([32]byte{0x01, 0x02, 0x03})
+	sc := legacy.SpendCommitment{
+		AssetAmount:    bc.AssetAmount{AssetId: &assetID, Amount: 100},
+		SourceID:       sourceID,
+		SourcePosition: 0,
+		VMVersion:      1,
+		RefDataHash:    bc.Hash{},
+	}
+	spentOutputID, err := legacy.ComputeOutputID(&sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap := Empty()
+	snap.Tree.Insert(spentOutputID.Bytes())
+
+	tx := legacy.MapTx(&legacy.TxData{
+		Version: 1,
+		Inputs: []*legacy.TxInput{
+			legacy.NewSpendInput(nil, sourceID, assetID, 100, 0, nil, bc.Hash{}, nil),
+		},
+		Outputs: []*legacy.TxOutput{
+			legacy.NewTxOutput(assetID, 100, []byte{1}, nil),
+		},
+	})
+	got, err := snap.TraceTx(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Access{
+		{Kind: KindOutput, Op: OpRead, ID: spentOutputID},
+		{Kind: KindOutput, Op: OpDelete, ID: spentOutputID},
+		{Kind: KindOutput, Op: OpInsert, ID: *tx.ResultIds[0]},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TraceTx = %+v want %+v", got, want)
+	}
+
+	// Spending the output again fails at its read.
+	got, err = snap.TraceTx(tx)
+	if err == nil {
+		t.Error("expected error tracing spend twice, got nil")
+	}
+	if !reflect.DeepEqual(got, want[:1]) {
+		t.Errorf("TraceTx of double spend = %+v want %+v", got, want[:1])
+	}
+}
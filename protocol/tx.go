@@ -54,6 +54,18 @@ func (c *prevalidatedTxsCache) cache(txID bc.Hash, err error) {
 	c.mu.Unlock()
 }
 
+func (c *prevalidatedTxsCache) setSize(n int) {
+	if n < 1 {
+		n = 1
+	}
+	c.mu.Lock()
+	c.lru.MaxEntries = n
+	for c.lru.Len() > n {
+		c.lru.RemoveOldest()
+	}
+	c.mu.Unlock()
+}
+
 func (c *Chain) checkIssuanceWindow(tx *bc.Tx) error {
 	if c.MaxIssuanceWindow == 0 {
 		return nil
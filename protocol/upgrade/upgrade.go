@@ -0,0 +1,153 @@
+// Package upgrade coordinates changes to the protocol rules.
+//
+// An upgrade is a named change to the rules, made by the release
+// that registers it. A chain schedules upgrades to activate at block
+// heights, in its genesis or by governance; from an upgrade's
+// activation height on, block validation and block building follow
+// its rules. A node whose release lacks an upgrade scheduled on its
+// chain cannot follow the chain to the upgrade's height, and must
+// halt below it until it is upgraded.
+package upgrade
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/chainmint/errors"
+)
+
+var (
+	// ErrMissingUpgrade is returned for a height at or above the
+	// activation height of an upgrade this release does not have.
+	ErrMissingUpgrade = errors.New("scheduled upgrade is not supported by this release")
+
+	// ErrConflict is returned when an upgrade is scheduled at a
+	// height other than the one it is already scheduled at.
+	ErrConflict = errors.New("upgrade is already scheduled at another height")
+)
+
+// BaseBlockVersion is the block version before any upgrade.
+const BaseBlockVersion = 1
+
+// An Upgrade is a change to the protocol rules.
+type Upgrade struct {
+	Name string
+
+	// BlockVersion, if nonzero, is the least version of the
+	// blocks from the upgrade's activation on.
+	BlockVersion uint64
+
+	// Features names the rule changes the upgrade turns on; see
+	// Rules.Enabled.
+	Features []string
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[string]Upgrade)
+)
+
+// Register makes u known to this release, so that chains may
+// activate it. It is meant to be called from init functions, and
+// panics if an upgrade with the same name is already registered.
+func Register(u Upgrade) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[u.Name]; ok {
+		panic("upgrade: Register called twice for " + u.Name)
+	}
+	registry[u.Name] = u
+}
+
+// Known reports whether the upgrade name is registered.
+func Known(name string) bool {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	_, ok := registry[name]
+	return ok
+}
+
+// Rules are the protocol rules in force at a height.
+type Rules struct {
+	BlockVersion uint64
+	features     map[string]bool
+}
+
+// Enabled reports whether an upgrade active under r turned on
+// feature.
+func (r Rules) Enabled(feature string) bool {
+	return r.features[feature]
+}
+
+// An Activation is an upgrade scheduled to activate at a height.
+type Activation struct {
+	Name   string `json:"name"`
+	Height uint64 `json:"height"`
+}
+
+// A Schedule holds the upgrades scheduled on a chain. It is safe
+// for concurrent use. The zero value is an empty schedule.
+type Schedule struct {
+	mu          sync.Mutex
+	activations []Activation // by height
+}
+
+// Add schedules the upgrade name to activate at height. The upgrade
+// need not be registered; a node without it halts below height.
+// Adding an upgrade already scheduled at height has no effect.
+func (s *Schedule) Add(name string, height uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range s.activations {
+		if a.Name != name {
+			continue
+		}
+		if a.Height != height {
+			return errors.WithDetailf(ErrConflict, "upgrade %q is scheduled at height %d, not %d", name, a.Height, height)
+		}
+		return nil
+	}
+	s.activations = append(s.activations, Activation{Name: name, Height: height})
+	sort.SliceStable(s.activations, func(i, j int) bool {
+		return s.activations[i].Height < s.activations[j].Height
+	})
+	return nil
+}
+
+// List returns the scheduled upgrades, by height.
+func (s *Schedule) List() []Activation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Activation(nil), s.activations...)
+}
+
+// Rules returns the rules in force at height, made by the upgrades
+// activated at or below it. It returns ErrMissingUpgrade if any of
+// those upgrades is not registered.
+func (s *Schedule) Rules(height uint64) (Rules, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	r := Rules{BlockVersion: BaseBlockVersion}
+	for _, a := range s.activations {
+		if a.Height > height {
+			break
+		}
+		u, ok := registry[a.Name]
+		if !ok {
+			return Rules{}, errors.WithDetailf(ErrMissingUpgrade, "upgrade %q activates at height %d; install a release that supports it", a.Name, a.Height)
+		}
+		if u.BlockVersion > r.BlockVersion {
+			r.BlockVersion = u.BlockVersion
+		}
+		for _, f := range u.Features {
+			if r.features == nil {
+				r.features = make(map[string]bool)
+			}
+			r.features[f] = true
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,63 @@
+package upgrade
+
+import (
+	"testing"
+
+	"github.com/chainmint/errors"
+)
+
+func init() {
+	Register(Upgrade{Name: "test-v2", BlockVersion: 2, Features: []string{"feature-a"}})
+	Register(Upgrade{Name: "test-feature-b", Features: []string{"feature-b"}})
+}
+
+func TestScheduleRules(t *testing.T) {
+	var s Schedule
+	for _, a := range []Activation{{"test-feature-b", 20}, {"test-v2", 10}} {
+		err := s.Add(a.Name, a.Height)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		height  uint64
+		version uint64
+		a, b    bool
+	}{
+		{9, BaseBlockVersion, false, false},
+		{10, 2, true, false},
+		{19, 2, true, false},
+		{20, 2, true, true},
+	}
+	for _, c := range cases {
+		r, err := s.Rules(c.height)
+		if err != nil {
+			t.Fatalf("Rules(%d) error: %v", c.height, err)
+		}
+		if r.BlockVersion != c.version || r.Enabled("feature-a") != c.a || r.Enabled("feature-b") != c.b {
+			t.Errorf("Rules(%d) = version %d, a %t, b %t; want %d, %t, %t", c.height, r.BlockVersion, r.Enabled("feature-a"), r.Enabled("feature-b"), c.version, c.a, c.b)
+		}
+	}
+
+	if err := s.Add("test-v2", 10); err != nil {
+		t.Errorf("re-adding at the same height: %v", err)
+	}
+	if err := s.Add("test-v2", 11); errors.Root(err) != ErrConflict {
+		t.Errorf("re-adding at another height: err = %v, want %v", err, ErrConflict)
+	}
+}
+
+func TestScheduleMissingUpgrade(t *testing.T) {
+	var s Schedule
+	err := s.Add("test-unknown", 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Rules(29); err != nil {
+		t.Errorf("Rules below the missing upgrade: %v", err)
+	}
+	if _, err := s.Rules(30); errors.Root(err) != ErrMissingUpgrade {
+		t.Errorf("Rules at the missing upgrade: err = %v, want %v", err, ErrMissingUpgrade)
+	}
+}
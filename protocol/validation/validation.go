@@ -2,8 +2,11 @@ package validation
 
 import (
 	"fmt"
+	"runtime"
+	"sync"
 
 	"github.com/chainmint/errors"
+	"github.com/chainmint/errors/errcode"
 	"github.com/chainmint/math/checked"
 	"github.com/chainmint/protocol/bc"
 	"github.com/chainmint/protocol/vm"
@@ -56,6 +59,16 @@ var (
 	errZeroTime              = errors.New("timerange has one or two bounds set to zero")
 )
 
+// txErrorCodes are the codes, from package errcode, of the errors
+// for which ValidateTx rejects a transaction. Errors not listed
+// have code errcode.InvalidTx.
+var txErrorCodes = map[error]string{
+	vm.ErrFalseVMResult: errcode.BadSignature,
+	vm.ErrVerifyFailed:  errcode.BadSignature,
+	errUnbalanced:       errcode.Unbalanced,
+	errWrongBlockchain:  errcode.WrongBlockchain,
+}
+
 func checkValid(vs *validationState, e bc.Entry) (err error) {
 	entryID := bc.EntryID(e)
 	if err, ok := vs.cache[entryID]; ok {
@@ -468,6 +481,10 @@ func ValidateBlock(b, prev *bc.Block, initialBlockID bc.Hash, validateTx func(*b
 		return errors.Wrap(err, "checking block header")
 	}
 
+	// The txs are independent of one another until they are applied
+	// to the state, so they are validated concurrently; the first
+	// error, in block order, is reported.
+	txErrs := ValidateTxs(b.Transactions, validateTx)
 	for i, tx := range b.Transactions {
 		if b.Version == 1 && tx.Version != 1 {
 			return errors.WithDetailf(errTxVersion, "block version %d, transaction version %d", b.Version, tx.Version)
@@ -479,7 +496,7 @@ func ValidateBlock(b, prev *bc.Block, initialBlockID bc.Hash, validateTx func(*b
 			return errors.WithDetailf(errUntimelyTransaction, "block timestamp %d, transaction time range %d-%d", b.TimestampMs, tx.MinTimeMs, tx.MaxTimeMs)
 		}
 
-		err = validateTx(tx)
+		err = txErrs[i]
 		if err != nil {
 			return errors.Wrapf(err, "validity of transaction %d of %d", i, len(b.Transactions))
 		}
@@ -497,6 +514,40 @@ func ValidateBlock(b, prev *bc.Block, initialBlockID bc.Hash, validateTx func(*b
 	return nil
 }
 
+// ValidateBlockHeader validates the header of b against that of
+// prev, its parent, without b's transactions. It does not run the
+// consensus program; for that, see ValidateBlockSig.
+func ValidateBlockHeader(b, prev *bc.Block) error {
+	err := validateBlockAgainstPrev(b, prev)
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(checkValidBlockHeader(b.BlockHeader), "checking block header")
+}
+
+// ValidateTxs calls validateTx for each of txs, concurrently, and
+// returns the results in the order of txs. validateTx must be safe
+// to call from multiple goroutines.
+func ValidateTxs(txs []*bc.Tx, validateTx func(*bc.Tx) error) []error {
+	errs := make([]error, len(txs))
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(txs) {
+		workers = len(txs)
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(txs); i += workers {
+				errs[i] = validateTx(txs[i])
+			}
+		}(w)
+	}
+	wg.Wait()
+	return errs
+}
+
 func validateBlockAgainstPrev(b, prev *bc.Block) error {
 	if b.Version < prev.Version {
 		return errors.WithDetailf(errVersionRegression, "previous block verson %d, current block version %d", prev.Version, b.Version)
@@ -513,7 +564,9 @@ func validateBlockAgainstPrev(b, prev *bc.Block) error {
 	return nil
 }
 
-// ValidateTx validates a transaction.
+// ValidateTx validates a transaction. The error it returns for an
+// invalid transaction carries a code from package errcode; see
+// errors.Code.
 func ValidateTx(tx *bc.Tx, initialBlockID bc.Hash) error {
 	vs := &validationState{
 		blockchainID: initialBlockID,
@@ -522,5 +575,13 @@ func ValidateTx(tx *bc.Tx, initialBlockID bc.Hash) error {
 
 		cache: make(map[bc.Hash]error),
 	}
-	return checkValid(vs, tx.TxHeader)
+	err := checkValid(vs, tx.TxHeader)
+	if err == nil {
+		return nil
+	}
+	code, ok := txErrorCodes[errors.Root(err)]
+	if !ok {
+		code = errcode.InvalidTx
+	}
+	return errors.WithCode(err, code)
 }
@@ -8,6 +8,7 @@ import (
 
 	"github.com/chainmint/crypto/sha3pool"
 	"github.com/chainmint/errors"
+	"github.com/chainmint/errors/errcode"
 	"github.com/chainmint/protocol/bc"
 	"github.com/chainmint/protocol/bc/bctest"
 	"github.com/chainmint/protocol/bc/legacy"
@@ -372,6 +373,9 @@ func TestNoncelessIssuance(t *testing.T) {
 	if errors.Root(err) != bc.ErrMissingEntry {
 		t.Fatalf("got %s, want %s", err, bc.ErrMissingEntry)
 	}
+	if got := errors.Code(err); got != errcode.InvalidTx {
+		t.Errorf("code = %s want %s", got, errcode.InvalidTx)
+	}
 }
 
 func TestBlockHeaderValid(t *testing.T) {
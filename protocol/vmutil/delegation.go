@@ -0,0 +1,87 @@
+package vmutil
+
+import (
+	"bytes"
+
+	"github.com/chainmint/protocol/vm"
+)
+
+// delegationTag marks a delegation program. It is pushed and
+// dropped ahead of the owner's program, so it has no effect on
+// who can spend the output.
+var delegationTag = []byte("delegate")
+
+// DelegationProgram returns a control program that delegates the
+// output's value to the validator with the given public key. The
+// output can be spent by whoever can satisfy ownerProgram:
+//
+//	"delegate" <validator> 2DROP <ownerProgram>
+func DelegationProgram(validator, ownerProgram []byte) []byte {
+	builder := NewBuilder()
+	builder.AddData(delegationTag).AddData(validator).AddOp(vm.OP_2DROP)
+	builder.AddRawBytes(ownerProgram)
+	return builder.Program
+}
+
+// ParseDelegationProgram returns the validator public key and owner
+// program of a program produced by DelegationProgram. The last
+// return value is false if prog is not a delegation program.
+func ParseDelegationProgram(prog []byte) (validator, ownerProgram []byte, ok bool) {
+	var pc uint32
+	tag, err := vm.ParseOp(prog, pc)
+	if err != nil || !bytes.Equal(tag.Data, delegationTag) || tag.Op > vm.OP_PUSHDATA4 {
+		return nil, nil, false
+	}
+	pc += tag.Len
+	val, err := vm.ParseOp(prog, pc)
+	if err != nil || val.Op > vm.OP_PUSHDATA4 || len(val.Data) == 0 {
+		return nil, nil, false
+	}
+	pc += val.Len
+	drop, err := vm.ParseOp(prog, pc)
+	if err != nil || drop.Op != vm.OP_2DROP {
+		return nil, nil, false
+	}
+	pc += drop.Len
+	return val.Data, prog[pc:], true
+}
+
+// TimeLockProgram returns a control program that can be spent by
+// whoever can satisfy ownerProgram, but only in a transaction whose
+// minimum time is at least unlockMS (milliseconds since the epoch):
+//
+//	MINTIME <unlockMS> GREATERTHANOREQUAL VERIFY <ownerProgram>
+func TimeLockProgram(unlockMS uint64, ownerProgram []byte) []byte {
+	builder := NewBuilder()
+	builder.AddOp(vm.OP_MINTIME).AddInt64(int64(unlockMS))
+	builder.AddOp(vm.OP_GREATERTHANOREQUAL).AddOp(vm.OP_VERIFY)
+	builder.AddRawBytes(ownerProgram)
+	return builder.Program
+}
+
+// ParseTimeLockProgram returns the unlock time and owner program of
+// a program produced by TimeLockProgram. The last return value is
+// false if prog is not a time-lock program.
+func ParseTimeLockProgram(prog []byte) (unlockMS uint64, ownerProgram []byte, ok bool) {
+	var pc uint32
+	insts := make([]vm.Instruction, 0, 4)
+	for i := 0; i < 4; i++ {
+		inst, err := vm.ParseOp(prog, pc)
+		if err != nil {
+			return 0, nil, false
+		}
+		insts = append(insts, inst)
+		pc += inst.Len
+	}
+	if insts[0].Op != vm.OP_MINTIME || insts[2].Op != vm.OP_GREATERTHANOREQUAL || insts[3].Op != vm.OP_VERIFY {
+		return 0, nil, false
+	}
+	if insts[1].Op > vm.OP_16 {
+		return 0, nil, false
+	}
+	n, err := vm.AsInt64(insts[1].Data)
+	if err != nil || n < 0 {
+		return 0, nil, false
+	}
+	return uint64(n), prog[pc:], true
+}
@@ -0,0 +1,46 @@
+package vmutil
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/chainmint/protocol/vm"
+)
+
+func TestDelegationProgram(t *testing.T) {
+	owner := []byte{byte(vm.OP_TRUE)}
+	validator := []byte("validator-pubkey")
+
+	prog := DelegationProgram(validator, owner)
+	gotVal, gotOwner, ok := ParseDelegationProgram(prog)
+	if !ok {
+		t.Fatal("ParseDelegationProgram failed on a delegation program")
+	}
+	if !bytes.Equal(gotVal, validator) || !bytes.Equal(gotOwner, owner) {
+		t.Errorf("ParseDelegationProgram = %x, %x, want %x, %x", gotVal, gotOwner, validator, owner)
+	}
+
+	_, _, ok = ParseDelegationProgram(owner)
+	if ok {
+		t.Error("ParseDelegationProgram succeeded on an ordinary program")
+	}
+}
+
+func TestTimeLockProgram(t *testing.T) {
+	owner := []byte{byte(vm.OP_TRUE)}
+	for _, unlock := range []uint64{0, 5, 1494000000000} {
+		prog := TimeLockProgram(unlock, owner)
+		got, gotOwner, ok := ParseTimeLockProgram(prog)
+		if !ok {
+			t.Fatalf("ParseTimeLockProgram failed on TimeLockProgram(%d)", unlock)
+		}
+		if got != unlock || !bytes.Equal(gotOwner, owner) {
+			t.Errorf("ParseTimeLockProgram = %d, %x, want %d, %x", got, gotOwner, unlock, owner)
+		}
+	}
+
+	_, _, ok := ParseTimeLockProgram(owner)
+	if ok {
+		t.Error("ParseTimeLockProgram succeeded on an ordinary program")
+	}
+}
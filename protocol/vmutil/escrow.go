@@ -0,0 +1,95 @@
+package vmutil
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/chainmint/crypto/ed25519"
+	"github.com/chainmint/protocol/vm"
+)
+
+// Escrow program paths, selected by the last witness argument.
+const (
+	EscrowRefund  = 0
+	EscrowRelease = 1
+)
+
+// EscrowProgram returns a control program that holds the output's
+// value until the oracle releases it to beneficiaryProgram, or until
+// refundMS (milliseconds since the epoch), after which it can be
+// refunded to refundProgram. Either way the whole value must be paid
+// to a single output, whose index is the first witness argument.
+//
+// The oracle releases the escrow by signing its output ID. The
+// release path takes the witness arguments <index> <signature> 1:
+//
+//	JUMPIF:$release
+//	MINTIME <refundMS> GREATERTHANOREQUAL VERIFY
+//	0 AMOUNT ASSET 1 <refundProgram> CHECKOUTPUT
+//	JUMP:$end
+//	$release
+//	OUTPUTID <oracle> CHECKSIG VERIFY
+//	0 AMOUNT ASSET 1 <beneficiaryProgram> CHECKOUTPUT
+//	$end
+//
+// and the refund path takes <index> 0.
+func EscrowProgram(oracle ed25519.PublicKey, beneficiaryProgram, refundProgram []byte, refundMS uint64) []byte {
+	refund := NewBuilder()
+	refund.AddOp(vm.OP_MINTIME).AddInt64(int64(refundMS))
+	refund.AddOp(vm.OP_GREATERTHANOREQUAL).AddOp(vm.OP_VERIFY)
+	addPayout(refund, refundProgram)
+
+	release := NewBuilder()
+	release.AddOp(vm.OP_OUTPUTID).AddData(oracle).AddOp(vm.OP_CHECKSIG).AddOp(vm.OP_VERIFY)
+	addPayout(release, beneficiaryProgram)
+
+	const jumpLen = 5
+	releaseAt := jumpLen + len(refund.Program) + jumpLen
+	end := releaseAt + len(release.Program)
+
+	builder := NewBuilder()
+	addJump(builder, vm.OP_JUMPIF, releaseAt)
+	builder.AddRawBytes(refund.Program)
+	addJump(builder, vm.OP_JUMP, end)
+	builder.AddRawBytes(release.Program)
+	return builder.Program
+}
+
+// addPayout adds instructions checking that the output at the index
+// on top of the stack pays the whole value to prog.
+func addPayout(b *Builder, prog []byte) {
+	b.AddData(nil).AddOp(vm.OP_AMOUNT).AddOp(vm.OP_ASSET).AddInt64(1)
+	b.AddData(prog).AddOp(vm.OP_CHECKOUTPUT)
+}
+
+func addJump(b *Builder, op vm.Op, addr int) {
+	var a [4]byte
+	binary.LittleEndian.PutUint32(a[:], uint32(addr))
+	b.AddOp(op).AddRawBytes(a[:])
+}
+
+// ParseEscrowProgram returns the oracle key, beneficiary and refund
+// programs, and refund time of a program produced by EscrowProgram.
+// The last return value is false if prog is not an escrow program.
+func ParseEscrowProgram(prog []byte) (oracle ed25519.PublicKey, beneficiaryProgram, refundProgram []byte, refundMS uint64, ok bool) {
+	insts, err := vm.ParseProgram(prog)
+	if err != nil || len(insts) != 22 {
+		return nil, nil, nil, 0, false
+	}
+	if insts[2].Op > vm.OP_16 || len(insts[13].Data) != ed25519.PublicKeySize {
+		return nil, nil, nil, 0, false
+	}
+	oracle = ed25519.PublicKey(insts[13].Data)
+	n, err := vm.AsInt64(insts[2].Data)
+	if err != nil || n < 0 {
+		return nil, nil, nil, 0, false
+	}
+	refundProgram = insts[9].Data
+	beneficiaryProgram = insts[20].Data
+
+	// Rebuilding the program checks the opcodes and jump addresses.
+	if !bytes.Equal(prog, EscrowProgram(oracle, beneficiaryProgram, refundProgram, uint64(n))) {
+		return nil, nil, nil, 0, false
+	}
+	return oracle, beneficiaryProgram, refundProgram, uint64(n), true
+}
@@ -0,0 +1,67 @@
+package vmutil
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/chainmint/crypto/ed25519"
+	"github.com/chainmint/protocol/vm"
+)
+
+func TestEscrowProgram(t *testing.T) {
+	oracle, oraclePriv, _ := ed25519.GenerateKey(nil)
+	beneficiary := []byte{byte(vm.OP_TRUE)}
+	refund := []byte{byte(vm.OP_FALSE)}
+
+	prog := EscrowProgram(oracle, beneficiary, refund, 1494000000000)
+	gotOracle, gotBen, gotRefund, gotMS, ok := ParseEscrowProgram(prog)
+	if !ok {
+		t.Fatal("ParseEscrowProgram failed on an escrow program")
+	}
+	if !bytes.Equal(gotOracle, oracle) || !bytes.Equal(gotBen, beneficiary) || !bytes.Equal(gotRefund, refund) || gotMS != 1494000000000 {
+		t.Errorf("ParseEscrowProgram = %x, %x, %x, %d", gotOracle, gotBen, gotRefund, gotMS)
+	}
+	if _, _, _, _, ok := ParseEscrowProgram(beneficiary); ok {
+		t.Error("ParseEscrowProgram succeeded on an ordinary program")
+	}
+
+	outputID := bytes.Repeat([]byte{1}, 32)
+	otherID := bytes.Repeat([]byte{2}, 32)
+	cases := []struct {
+		args    [][]byte
+		minTime uint64
+		paidTo  []byte
+		ok      bool
+	}{
+		{[][]byte{{0}, ed25519.Sign(oraclePriv, outputID), {EscrowRelease}}, 0, beneficiary, true},
+		{[][]byte{{0}, ed25519.Sign(oraclePriv, otherID), {EscrowRelease}}, 0, beneficiary, false},
+		{[][]byte{{0}, ed25519.Sign(oraclePriv, outputID), {EscrowRelease}}, 0, refund, false},
+		{[][]byte{{0}, {EscrowRefund}}, 1494000000000, refund, true},
+		{[][]byte{{0}, {EscrowRefund}}, 1493999999999, refund, false},
+		{[][]byte{{0}, {EscrowRefund}}, 1494000000000, beneficiary, false},
+	}
+	for i, c := range cases {
+		var (
+			assetID = bytes.Repeat([]byte{3}, 32)
+			amount  = uint64(10)
+			txVer   = uint64(1)
+			minTime = c.minTime
+		)
+		err := vm.Verify(&vm.Context{
+			VMVersion:     1,
+			Code:          prog,
+			Arguments:     c.args,
+			TxVersion:     &txVer,
+			AssetID:       &assetID,
+			Amount:        &amount,
+			MinTimeMS:     &minTime,
+			SpentOutputID: &outputID,
+			CheckOutput: func(index uint64, data []byte, amt uint64, asset []byte, vmVersion uint64, code []byte, expansion bool) (bool, error) {
+				return index == 0 && amt == amount && bytes.Equal(asset, assetID) && vmVersion == 1 && bytes.Equal(code, c.paidTo), nil
+			},
+		})
+		if (err == nil) != c.ok {
+			t.Errorf("case %d: err = %v, want ok %v", i, err, c.ok)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package strategies
+
+import (
+	"bytes"
+	"math/big"
+
+	cmtTypes "github.com/chainmint/types"
+)
+
+// roundRobin pays all collected fees to one validator, chosen
+// round-robin by block height, so every node picks the same one.
+// ABCI 0.5 does not tell the app who proposed a block, so no
+// strategy can pay the proposer.
+type roundRobin struct{ base }
+
+func newRoundRobin(cfg Config) cmtTypes.Strategy {
+	return &roundRobin{base{cfg: cfg}}
+}
+
+func (s *roundRobin) Distribute(block cmtTypes.BlockInfo) []cmtTypes.Reward {
+	if s.pending == 0 || len(s.validators) == 0 {
+		return nil
+	}
+	v := s.validators[block.Height%uint64(len(s.validators))]
+	rewards := []cmtTypes.Reward{{PubKey: v.PubKey, Amount: s.pending}}
+	s.pending = 0
+	return s.split(rewards)
+}
+
+// equal splits the collected fees evenly among the validators.
+// Any indivisible remainder is carried over to the next block.
+type equal struct{ base }
+
+func newEqual(cfg Config) cmtTypes.Strategy {
+	return &equal{base{cfg: cfg}}
+}
+
+func (s *equal) Distribute(block cmtTypes.BlockInfo) []cmtTypes.Reward {
+	n := uint64(len(s.validators))
+	if n == 0 || s.pending < n {
+		return nil
+	}
+	share := s.pending / n
+	var rewards []cmtTypes.Reward
+	for _, v := range s.validators {
+		rewards = append(rewards, cmtTypes.Reward{PubKey: v.PubKey, Amount: share})
+	}
+	s.pending -= share * n
+	return s.split(rewards)
+}
+
+// stakeProportional splits the collected fees among the validators
+// in proportion to their voting power. Rounding remainders are
+// carried over to the next block.
+type stakeProportional struct{ base }
+
+func newStakeProportional(cfg Config) cmtTypes.Strategy {
+	return &stakeProportional{base{cfg: cfg}}
+}
+
+func (s *stakeProportional) Distribute(block cmtTypes.BlockInfo) []cmtTypes.Reward {
+	total := new(big.Int)
+	for _, v := range s.validators {
+		total.Add(total, new(big.Int).SetUint64(v.Power))
+	}
+	if s.pending == 0 || total.Sign() == 0 {
+		return nil
+	}
+	pending := new(big.Int).SetUint64(s.pending)
+	var (
+		rewards []cmtTypes.Reward
+		paid    uint64
+	)
+	for _, v := range s.validators {
+		amt := new(big.Int).SetUint64(v.Power)
+		amt.Mul(amt, pending).Div(amt, total)
+		if amt.Sign() == 0 {
+			continue
+		}
+		rewards = append(rewards, cmtTypes.Reward{PubKey: v.PubKey, Amount: amt.Uint64()})
+		paid += amt.Uint64()
+	}
+	s.pending -= paid
+	return s.split(rewards)
+}
+
+// burn pays nothing: collected fees are destroyed along with the
+// retired outputs that carried them.
+type burn struct{ base }
+
+func newBurn(cfg Config) cmtTypes.Strategy {
+	return &burn{base{cfg: cfg}}
+}
+
+func (s *burn) Distribute(block cmtTypes.BlockInfo) []cmtTypes.Reward {
+	s.pending = 0
+	return nil
+}
+
+// findValidator returns the index of the validator with the given
+// public key, or -1.
+func (b *base) findValidator(pubkey []byte) int {
+	for i, v := range b.validators {
+		if bytes.Equal(v.PubKey, pubkey) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,220 @@
+// Package strategies implements the built-in validator
+// compensation strategies and a registry to select them by name.
+package strategies
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/math/checked"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/vmutil"
+	cmtTypes "github.com/chainmint/types"
+	abciTypes "github.com/tendermint/abci/types"
+)
+
+// ErrUnknownStrategy is returned by New when no strategy has
+// been registered under the requested name.
+var ErrUnknownStrategy = errors.New("unknown strategy")
+
+// Config holds the parameters shared by all strategies.
+type Config struct {
+	// FeeAsset is the asset in which transaction fees are paid.
+	// Fees are the amounts of this asset retired by a transaction.
+	FeeAsset bc.AssetID
+}
+
+// Constructor creates a strategy from its configuration.
+type Constructor func(Config) cmtTypes.Strategy
+
+var (
+	mu       sync.Mutex
+	registry = make(map[string]Constructor)
+)
+
+func init() {
+	Register("round-robin", newRoundRobin)
+	Register("equal", newEqual)
+	Register("stake-proportional", newStakeProportional)
+	Register("burn", newBurn)
+}
+
+// Register makes a strategy available under the given name.
+// It panics if a strategy with that name is already registered.
+func Register(name string, ctor Constructor) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := registry[name]; ok {
+		panic("strategies: duplicate registration of " + name)
+	}
+	registry[name] = ctor
+}
+
+// New creates the strategy registered under name.
+func New(name string, cfg Config) (cmtTypes.Strategy, error) {
+	mu.Lock()
+	ctor, ok := registry[name]
+	mu.Unlock()
+	if !ok {
+		return nil, errors.WithDetailf(ErrUnknownStrategy, "strategy %q (available: %v)", name, Names())
+	}
+	return ctor(cfg), nil
+}
+
+// Names returns the names of all registered strategies, sorted.
+func Names() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	var names []string
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Fee returns the amount of feeAsset retired by tx.
+func Fee(tx *legacy.Tx, feeAsset bc.AssetID) uint64 {
+	var fee uint64
+	for _, out := range tx.Outputs {
+		if out.AssetId == nil || *out.AssetId != feeAsset {
+			continue
+		}
+		if !vmutil.IsUnspendable(out.ControlProgram) {
+			continue
+		}
+		sum, ok := checked.AddUint64(fee, out.Amount)
+		if !ok {
+			// A single transaction cannot retire more than
+			// the total supply, so this never happens for
+			// valid transactions.
+			return fee
+		}
+		fee = sum
+	}
+	return fee
+}
+
+// MemoSize returns the bytes of reference data, the memo space of a
+// transaction, carried by tx and its inputs and outputs.
+func MemoSize(tx *legacy.Tx) int {
+	n := len(tx.ReferenceData)
+	for _, in := range tx.Inputs {
+		n += len(in.ReferenceData)
+	}
+	for _, out := range tx.Outputs {
+		n += len(out.ReferenceData)
+	}
+	return n
+}
+
+// base holds the bookkeeping common to all built-in strategies.
+// Fees collected but not yet distributed are carried over to the
+// next block.
+type base struct {
+	cfg         Config
+	validators  []*abciTypes.Validator
+	commissions map[string]uint64
+	delegations []cmtTypes.Delegation
+	pending     uint64
+}
+
+func (b *base) SetValidators(validators []*abciTypes.Validator) {
+	b.validators = validators
+}
+
+func (b *base) SetCommission(validator []byte, rate uint64) {
+	if b.commissions == nil {
+		b.commissions = make(map[string]uint64)
+	}
+	if rate > 10000 {
+		rate = 10000
+	}
+	b.commissions[string(validator)] = rate
+}
+
+func (b *base) SetDelegations(delegations []cmtTypes.Delegation) {
+	b.delegations = delegations
+}
+
+// split divides each reward between its validator and the
+// validator's delegators. The validator keeps its commission; the
+// rest is shared in proportion to stake, where the validator's own
+// stake is its power less the stake delegated to it. Rounding
+// remainders go to the validator.
+func (b *base) split(rewards []cmtTypes.Reward) []cmtTypes.Reward {
+	for i := range rewards {
+		r := &rewards[i]
+		var (
+			delegated = new(big.Int)
+			mine      []cmtTypes.Delegation
+		)
+		for _, d := range b.delegations {
+			if bytes.Equal(d.Validator, r.PubKey) && d.Amount > 0 {
+				mine = append(mine, d)
+				delegated.Add(delegated, new(big.Int).SetUint64(d.Amount))
+			}
+		}
+		if len(mine) == 0 {
+			continue
+		}
+		total := delegated
+		if i := b.findValidator(r.PubKey); i >= 0 {
+			if power := new(big.Int).SetUint64(b.validators[i].Power); power.Cmp(total) > 0 {
+				total = power
+			}
+		}
+
+		commission := new(big.Int).SetUint64(r.Amount)
+		commission.Mul(commission, new(big.Int).SetUint64(b.commissions[string(r.PubKey)]))
+		commission.Div(commission, big.NewInt(10000))
+		shared := new(big.Int).SetUint64(r.Amount - commission.Uint64())
+
+		for _, d := range mine {
+			amt := new(big.Int).SetUint64(d.Amount)
+			amt.Mul(amt, shared).Div(amt, total)
+			if amt.Sign() == 0 {
+				continue
+			}
+			r.Delegators = append(r.Delegators, cmtTypes.DelegatorReward{Delegator: d.Delegator, Amount: amt.Uint64()})
+			r.Amount -= amt.Uint64()
+		}
+	}
+	return rewards
+}
+
+func (b *base) CollectFee(tx *legacy.Tx) {
+	sum, ok := checked.AddUint64(b.pending, Fee(tx, b.cfg.FeeAsset))
+	if ok {
+		b.pending = sum
+	}
+}
+
+// ValidatorUpdates returns no updates: the built-in strategies
+// only pay out fees and never change the validator set.
+func (b *base) ValidatorUpdates() []*abciTypes.Validator {
+	return nil
+}
+
+type baseState struct {
+	Pending uint64 `json:"pending"`
+}
+
+func (b *base) MarshalState() ([]byte, error) {
+	return json.Marshal(baseState{Pending: b.pending})
+}
+
+func (b *base) UnmarshalState(data []byte) error {
+	var st baseState
+	err := json.Unmarshal(data, &st)
+	if err != nil {
+		return errors.Wrap(err, "unmarshaling strategy state")
+	}
+	b.pending = st.Pending
+	return nil
+}
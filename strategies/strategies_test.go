@@ -0,0 +1,119 @@
+package strategies
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+	"github.com/chainmint/protocol/vm"
+	cmtTypes "github.com/chainmint/types"
+	abciTypes "github.com/tendermint/abci/types"
+)
+
+var (
+	feeAsset   = bc.NewAssetID([32]byte{1})
+	otherAsset = bc.NewAssetID([32]byte{2})
+	retire     = []byte{byte(vm.OP_FAIL)}
+	spendable  = []byte{byte(vm.OP_TRUE)}
+)
+
+func feeTx(amount uint64) *legacy.Tx {
+	return &legacy.Tx{TxData: legacy.TxData{
+		Outputs: []*legacy.TxOutput{
+			legacy.NewTxOutput(feeAsset, amount, retire, nil),
+			legacy.NewTxOutput(feeAsset, 1000, spendable, nil),
+			legacy.NewTxOutput(otherAsset, 1000, retire, nil),
+		},
+	}}
+}
+
+func TestFee(t *testing.T) {
+	got := Fee(feeTx(7), feeAsset)
+	if got != 7 {
+		t.Errorf("Fee = %d, want 7", got)
+	}
+}
+
+func TestMemoSize(t *testing.T) {
+	tx := feeTx(7)
+	tx.ReferenceData = []byte("memo")
+	tx.Outputs[1].ReferenceData = []byte("out")
+	tx.Inputs = []*legacy.TxInput{legacy.NewIssuanceInput(nil, 1, []byte("in"), bc.Hash{}, nil, nil, nil)}
+	got := MemoSize(tx)
+	if got != 9 {
+		t.Errorf("MemoSize = %d, want 9", got)
+	}
+}
+
+func TestDistribute(t *testing.T) {
+	vals := []*abciTypes.Validator{
+		{PubKey: []byte("a"), Power: 1},
+		{PubKey: []byte("b"), Power: 3},
+	}
+	cases := []struct {
+		name string
+		want []cmtTypes.Reward
+	}{{
+		name: "round-robin",
+		want: []cmtTypes.Reward{{PubKey: []byte("a"), Amount: 101}}, // height 2 % 2
+	}, {
+		name: "equal",
+		want: []cmtTypes.Reward{{PubKey: []byte("a"), Amount: 50}, {PubKey: []byte("b"), Amount: 50}},
+	}, {
+		name: "stake-proportional",
+		want: []cmtTypes.Reward{{PubKey: []byte("a"), Amount: 25}, {PubKey: []byte("b"), Amount: 75}},
+	}, {
+		name: "burn",
+	}}
+	for _, c := range cases {
+		s, err := New(c.name, Config{FeeAsset: feeAsset})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.SetValidators(vals)
+		s.CollectFee(feeTx(100))
+		s.CollectFee(feeTx(1))
+		got := s.Distribute(cmtTypes.BlockInfo{Height: 2})
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: Distribute = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestNewUnknown(t *testing.T) {
+	_, err := New("nonexistent", Config{})
+	if err == nil {
+		t.Error("expected error for unknown strategy")
+	}
+}
+
+func TestCommission(t *testing.T) {
+	s, err := New("round-robin", Config{FeeAsset: feeAsset})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetValidators([]*abciTypes.Validator{{PubKey: []byte("a"), Power: 100}})
+	s.SetCommission([]byte("a"), 1000) // 10%
+	s.SetDelegations([]cmtTypes.Delegation{
+		{Validator: []byte("a"), Delegator: []byte("x"), Amount: 50},
+		{Validator: []byte("a"), Delegator: []byte("y"), Amount: 25},
+		{Validator: []byte("b"), Delegator: []byte("z"), Amount: 25},
+	})
+	s.CollectFee(feeTx(1000))
+	got := s.Distribute(cmtTypes.BlockInfo{})
+
+	// 100 commission; the other 900 is split by stake, with the
+	// validator's own 25 earning 225.
+	want := []cmtTypes.Reward{{
+		PubKey: []byte("a"),
+		Amount: 325,
+		Delegators: []cmtTypes.DelegatorReward{
+			{Delegator: []byte("x"), Amount: 450},
+			{Delegator: []byte("y"), Amount: 225},
+		},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Distribute = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,253 @@
+// Package workqueue runs best-effort work, such as indexing and
+// event delivery, off the goroutine that produces it. When the queue
+// is full, work is dropped according to the queue's policy, so a
+// backlog can never slow the producer, unless the policy is Block,
+// which instead makes the producer wait for room.
+package workqueue
+
+import (
+	"context"
+	"expvar"
+	"sync"
+	"time"
+
+	"github.com/chainmint/log"
+)
+
+// Priority orders queued jobs. Higher priority jobs run first and
+// are dropped last.
+type Priority int
+
+const (
+	Low Priority = iota
+	High
+
+	numPriorities = int(High) + 1
+)
+
+// Policy says which job is dropped when a job is added to a full
+// queue.
+type Policy int
+
+const (
+	// DropOldest drops the oldest queued job of the lowest
+	// priority that is no higher than the new job's. If every
+	// queued job has a higher priority, the new job is dropped.
+	DropOldest Policy = iota
+
+	// DropNewest drops the new job.
+	DropNewest
+
+	// Block drops no job: adding a job to a full queue waits
+	// until a queued job starts running. It suits work that must
+	// not be lost and cannot catch up on work dropped before it.
+	Block
+)
+
+// queuesExpvar holds the stats of every queue, under its name.
+var queuesExpvar = expvar.NewMap("workqueue")
+
+// Job is a unit of work.
+type Job struct {
+	// Name identifies the job in logs.
+	Name string
+
+	// Key, if set, makes the job replace a queued job with the
+	// same key instead of queueing behind it. It suits jobs that
+	// catch up on all outstanding work whenever they run.
+	Key string
+
+	Priority Priority
+	Run      func(context.Context) error
+}
+
+// Queue is a bounded, prioritized queue of jobs, run in order of
+// priority and then of addition by Run.
+type Queue struct {
+	max    int
+	policy Policy
+
+	mu     sync.Mutex
+	ready  chan struct{} // signaled when a job is added
+	room   sync.Cond     // broadcast when a job is removed
+	jobs   [numPriorities][]*Job
+	keyed  map[string]*Job
+	length int
+
+	stats                        *expvar.Map
+	added, dropped, done, failed *expvar.Int
+	depth, highWater             *expvar.Int
+	blocked, blockedMS           *expvar.Int
+}
+
+// New returns a queue holding at most max jobs, publishing its stats
+// as the expvar workqueue.<name>.
+func New(name string, max int, policy Policy) *Queue {
+	q := &Queue{
+		max:     max,
+		policy:  policy,
+		ready:   make(chan struct{}, 1),
+		keyed:   make(map[string]*Job),
+		stats:   new(expvar.Map).Init(),
+		added:   new(expvar.Int),
+		dropped: new(expvar.Int),
+		done:    new(expvar.Int),
+		failed:  new(expvar.Int),
+		depth:   new(expvar.Int),
+
+		highWater: new(expvar.Int),
+		blocked:   new(expvar.Int),
+		blockedMS: new(expvar.Int),
+	}
+	q.room.L = &q.mu
+	q.stats.Set("added", q.added)
+	q.stats.Set("dropped", q.dropped)
+	q.stats.Set("done", q.done)
+	q.stats.Set("failed", q.failed)
+	q.stats.Set("depth", q.depth)
+	q.stats.Set("high_watermark", q.highWater)
+	q.stats.Set("blocked", q.blocked)
+	q.stats.Set("blocked_ms", q.blockedMS)
+	queuesExpvar.Set(name, q.stats)
+	return q
+}
+
+// Add queues job. It reports whether job was queued; it is not if
+// the queue is full and the policy drops it. Add blocks only under
+// the Block policy, while the queue is full; the time it spends
+// waiting is published as the stat blocked_ms.
+func (q *Queue) Add(job *Job) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if job.Key != "" {
+		if old, ok := q.keyed[job.Key]; ok {
+			if job.Priority <= old.Priority {
+				*old = Job{Name: job.Name, Key: job.Key, Priority: old.Priority, Run: job.Run}
+				q.added.Add(1)
+				return true
+			}
+			// Requeue at the higher priority.
+			q.remove(old)
+		}
+	}
+
+	if q.length >= q.max && q.policy == Block {
+		q.wait()
+		if job.Key != "" {
+			if old, ok := q.keyed[job.Key]; ok && job.Priority <= old.Priority {
+				// Queued by another Add while waiting.
+				*old = Job{Name: job.Name, Key: job.Key, Priority: old.Priority, Run: job.Run}
+				q.added.Add(1)
+				return true
+			}
+		}
+	}
+	if q.length >= q.max {
+		if q.policy == DropNewest || !q.evict(job.Priority) {
+			q.dropped.Add(1)
+			return false
+		}
+		q.dropped.Add(1)
+	}
+
+	q.jobs[job.Priority] = append(q.jobs[job.Priority], job)
+	if job.Key != "" {
+		q.keyed[job.Key] = job
+	}
+	q.length++
+	q.depth.Set(int64(q.length))
+	if int64(q.length) > q.highWater.Value() {
+		q.highWater.Set(int64(q.length))
+	}
+	q.added.Add(1)
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// wait waits until the queue has room. The caller must hold q.mu.
+func (q *Queue) wait() {
+	q.blocked.Add(1)
+	t0 := time.Now()
+	for q.length >= q.max {
+		q.room.Wait()
+	}
+	q.blockedMS.Add(int64(time.Since(t0) / time.Millisecond))
+}
+
+// evict drops the oldest job of the lowest priority no higher than
+// p, and reports whether there was one. The caller must hold q.mu.
+func (q *Queue) evict(p Priority) bool {
+	for i := 0; i <= int(p); i++ {
+		if len(q.jobs[i]) > 0 {
+			q.remove(q.jobs[i][0])
+			return true
+		}
+	}
+	return false
+}
+
+// remove removes a queued job. The caller must hold q.mu.
+func (q *Queue) remove(job *Job) {
+	jobs := q.jobs[job.Priority]
+	for i, j := range jobs {
+		if j == job {
+			q.jobs[job.Priority] = append(jobs[:i:i], jobs[i+1:]...)
+			break
+		}
+	}
+	if job.Key != "" && q.keyed[job.Key] == job {
+		delete(q.keyed, job.Key)
+	}
+	q.length--
+	q.depth.Set(int64(q.length))
+	q.room.Broadcast()
+}
+
+// next removes and returns the next job to run, or nil if the
+// queue is empty.
+func (q *Queue) next() *Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i := numPriorities - 1; i >= 0; i-- {
+		if len(q.jobs[i]) > 0 {
+			job := q.jobs[i][0]
+			q.remove(job)
+			return job
+		}
+	}
+	return nil
+}
+
+// Len returns the number of queued jobs.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.length
+}
+
+// Run runs queued jobs one at a time until ctx is canceled. Job
+// errors are logged.
+func (q *Queue) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		job := q.next()
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-q.ready:
+			}
+			continue
+		}
+		err := job.Run(ctx)
+		if err != nil {
+			q.failed.Add(1)
+			log.Error(ctx, err, "running job", job.Name)
+			continue
+		}
+		q.done.Add(1)
+	}
+}
@@ -0,0 +1,124 @@
+package workqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func names(q *Queue) []string {
+	var got []string
+	for job := q.next(); job != nil; job = q.next() {
+		got = append(got, job.Name)
+	}
+	return got
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestOrder(t *testing.T) {
+	q := New("test-order", 10, DropOldest)
+	q.Add(&Job{Name: "a", Priority: Low})
+	q.Add(&Job{Name: "b", Priority: High})
+	q.Add(&Job{Name: "c", Priority: Low})
+	q.Add(&Job{Name: "d", Priority: High})
+	want := []string{"b", "d", "a", "c"}
+	if got := names(q); !equal(got, want) {
+		t.Errorf("ran %v, want %v", got, want)
+	}
+}
+
+func TestDrop(t *testing.T) {
+	q := New("test-drop-oldest", 2, DropOldest)
+	q.Add(&Job{Name: "low1", Priority: Low})
+	q.Add(&Job{Name: "high1", Priority: High})
+	if !q.Add(&Job{Name: "high2", Priority: High}) {
+		t.Error("high2 was not queued")
+	}
+	if q.Add(&Job{Name: "low2", Priority: Low}) {
+		t.Error("low2 was queued over high priority jobs")
+	}
+	want := []string{"high1", "high2"}
+	if got := names(q); !equal(got, want) {
+		t.Errorf("ran %v, want %v", got, want)
+	}
+
+	q = New("test-drop-newest", 1, DropNewest)
+	q.Add(&Job{Name: "low1", Priority: Low})
+	if q.Add(&Job{Name: "high1", Priority: High}) {
+		t.Error("high1 was queued in a full DropNewest queue")
+	}
+	if q.dropped.Value() != 1 {
+		t.Errorf("dropped = %d, want 1", q.dropped.Value())
+	}
+}
+
+func TestBlock(t *testing.T) {
+	q := New("test-block", 1, Block)
+	q.Add(&Job{Name: "a", Priority: High})
+	added := make(chan bool)
+	go func() { added <- q.Add(&Job{Name: "b", Priority: Low}) }()
+	select {
+	case <-added:
+		t.Fatal("Add to a full Block queue did not wait")
+	case <-time.After(10 * time.Millisecond):
+	}
+	if job := q.next(); job.Name != "a" {
+		t.Errorf("ran %s, want a", job.Name)
+	}
+	if !<-added {
+		t.Error("b was not queued")
+	}
+	if want := []string{"b"}; !equal(names(q), want) {
+		t.Errorf("queue did not hold %v", want)
+	}
+	if q.dropped.Value() != 0 || q.blocked.Value() != 1 {
+		t.Errorf("dropped, blocked = %d, %d, want 0, 1", q.dropped.Value(), q.blocked.Value())
+	}
+	if q.highWater.Value() != 1 {
+		t.Errorf("high watermark = %d, want 1", q.highWater.Value())
+	}
+}
+
+func TestKey(t *testing.T) {
+	q := New("test-key", 10, DropOldest)
+	q.Add(&Job{Name: "index1", Key: "index", Priority: Low})
+	q.Add(&Job{Name: "other", Priority: Low})
+	q.Add(&Job{Name: "index2", Key: "index", Priority: Low})
+	if q.Len() != 2 {
+		t.Errorf("Len = %d, want 2", q.Len())
+	}
+	q.Add(&Job{Name: "index3", Key: "index", Priority: High})
+	want := []string{"index3", "other"}
+	if got := names(q); !equal(got, want) {
+		t.Errorf("ran %v, want %v", got, want)
+	}
+}
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	q := New("test-run", 10, DropOldest)
+	done := make(chan struct{})
+	go func() {
+		q.Run(ctx)
+		close(done)
+	}()
+	ran := make(chan string, 2)
+	q.Add(&Job{Name: "a", Run: func(context.Context) error { ran <- "a"; return nil }})
+	q.Add(&Job{Name: "b", Run: func(context.Context) error { ran <- "b"; return nil }})
+	if got := []string{<-ran, <-ran}; !equal(got, []string{"a", "b"}) {
+		t.Errorf("ran %v, want [a b]", got)
+	}
+	cancel()
+	<-done
+}
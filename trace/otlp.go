@@ -0,0 +1,269 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/log"
+	"github.com/chainmint/metrics"
+)
+
+const (
+	// queueSize bounds the spans ended and not yet exported. Spans
+	// ended while the queue is full are dropped, so that a slow or
+	// missing collector never holds up the work being traced.
+	queueSize = 4096
+
+	// batchSize is the most spans exported in one request.
+	batchSize = 512
+
+	// exportPeriod is how long an ended span may wait for a batch
+	// to fill before it is exported.
+	exportPeriod = 5 * time.Second
+
+	exportTimeout = 10 * time.Second
+)
+
+var droppedSpans = metrics.NewCounter("chainmint_trace_spans_dropped", "Spans dropped because the export queue was full.")
+
+var (
+	exporterMu sync.Mutex
+	exp        *exporter
+)
+
+func current() *exporter {
+	exporterMu.Lock()
+	defer exporterMu.Unlock()
+	return exp
+}
+
+// exporter sends ended spans to an OTLP/HTTP collector in batches.
+type exporter struct {
+	url     string
+	service string
+	rate    int // basis points of traces sampled
+	client  *http.Client
+	queue   chan *Span
+}
+
+// Export starts recording spans, and exporting them in the
+// background to the OpenTelemetry collector accepting OTLP/HTTP at
+// endpoint, such as http://localhost:4318, as from service. Of the
+// traces started, it samples rate basis points, from 0 to 10000,
+// recording every span of those and none of the others.
+//
+// When ctx is done, Export stops recording spans, and exports those
+// already ended.
+func Export(ctx context.Context, endpoint, service string, rate int) error {
+	if rate < 0 || rate > 10000 {
+		return errors.New("trace sample rate " + strconv.Itoa(rate) + " is not between 0 and 10000 basis points")
+	}
+	e := &exporter{
+		url:     strings.TrimRight(endpoint, "/") + "/v1/traces",
+		service: service,
+		rate:    rate,
+		client:  &http.Client{Timeout: exportTimeout},
+		queue:   make(chan *Span, queueSize),
+	}
+	exporterMu.Lock()
+	exp = e
+	exporterMu.Unlock()
+	go e.run(ctx)
+	return nil
+}
+
+func (e *exporter) sample() bool {
+	return e.rate >= 10000 || ids.intn(10000) < e.rate
+}
+
+func (e *exporter) enqueue(s *Span) {
+	select {
+	case e.queue <- s:
+	default:
+		droppedSpans.Inc()
+	}
+}
+
+func (e *exporter) run(ctx context.Context) {
+	ticker := time.NewTicker(exportPeriod)
+	defer ticker.Stop()
+	var batch []*Span
+	for {
+		select {
+		case s := <-e.queue:
+			batch = append(batch, s)
+			if len(batch) < batchSize {
+				continue
+			}
+		case <-ticker.C:
+		case <-ctx.Done():
+			exporterMu.Lock()
+			if exp == e {
+				exp = nil
+			}
+			exporterMu.Unlock()
+			for len(e.queue) > 0 {
+				batch = append(batch, <-e.queue)
+			}
+			e.export(context.Background(), batch)
+			return
+		}
+		e.export(ctx, batch)
+		batch = nil
+	}
+}
+
+// export sends spans to the collector. Spans that cannot be sent are
+// dropped, after logging why.
+func (e *exporter) export(ctx context.Context, spans []*Span) {
+	if len(spans) == 0 {
+		return
+	}
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		log.Error(ctx, errors.Wrap(err, "encoding spans"))
+		return
+	}
+	req, err := http.NewRequest("POST", e.url, bytes.NewReader(body))
+	if err != nil {
+		log.Error(ctx, errors.Wrap(err, "exporting spans"))
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		log.Error(ctx, errors.Wrap(err, "exporting spans"))
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		log.Error(ctx, errors.WithDetail(errors.New("exporting spans: "+resp.Status), string(msg)))
+		return
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+}
+
+// The types below are the parts of the OTLP/JSON encoding of an
+// ExportTraceServiceRequest that Export uses.
+
+type exportRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope scope      `json:"scope"`
+	Spans []spanJSON `json:"spans"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+// Span kinds.
+const (
+	kindInternal = 1
+	kindServer   = 2
+)
+
+type spanJSON struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	Status            *status    `json:"status,omitempty"`
+}
+
+// statusError is the code of the status of a failed span.
+const statusError = 2
+
+type status struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"` // int64, in decimal
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+func attribute(k string, v interface{}) keyValue {
+	var a anyValue
+	switch v := v.(type) {
+	case string:
+		a.StringValue = &v
+	case bool:
+		a.BoolValue = &v
+	case int:
+		s := strconv.FormatInt(int64(v), 10)
+		a.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		a.IntValue = &s
+	case uint64:
+		s := strconv.FormatUint(v, 10)
+		a.IntValue = &s
+	default:
+		s := fmt.Sprint(v)
+		a.StringValue = &s
+	}
+	return keyValue{Key: k, Value: a}
+}
+
+func (e *exporter) request(spans []*Span) exportRequest {
+	js := make([]spanJSON, 0, len(spans))
+	var zero [8]byte
+	for _, s := range spans {
+		j := spanJSON{
+			TraceID:           hex.EncodeToString(s.traceID[:]),
+			SpanID:            hex.EncodeToString(s.spanID[:]),
+			Name:              s.name,
+			Kind:              kindServer,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        s.attrs,
+		}
+		if s.parentID != zero {
+			j.ParentSpanID = hex.EncodeToString(s.parentID[:])
+			j.Kind = kindInternal
+		}
+		if s.failed {
+			j.Status = &status{Code: statusError, Message: s.errMsg}
+		}
+		js = append(js, j)
+	}
+	return exportRequest{ResourceSpans: []resourceSpans{{
+		Resource:   resource{Attributes: []keyValue{attribute("service.name", e.service)}},
+		ScopeSpans: []scopeSpans{{Scope: scope{Name: "github.com/chainmint/trace"}, Spans: js}},
+	}}}
+}
@@ -0,0 +1,197 @@
+// Package trace records spans, the timed steps of the work done for
+// a request, and exports them to an OpenTelemetry collector over
+// OTLP/HTTP, to show where the time of each request goes.
+//
+// A span is started with Start, which finds its parent in the
+// Context it is given and returns a Context carrying the new span,
+// for the spans of the steps under it. Until Export is called,
+// Start records nothing and returns a nil *Span, whose methods do
+// nothing, so tracing costs next to nothing when it is off.
+//
+// Under a Context returned by WithTimings, spans are timed whether
+// or not they are exported, for diagnosing slow requests without a
+// collector.
+package trace
+
+import (
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// key is an unexported type for keys defined in this package.
+type key int
+
+// spanKey is the key for the current span in Contexts.
+const spanKey key = 0
+
+// timingsKey is the key for the Timings in Contexts.
+const timingsKey key = 1
+
+// A Span is a timed step of the work done for a request. Its
+// methods may be called on a nil *Span, and do nothing. A Span must
+// not be used concurrently.
+type Span struct {
+	exp      *exporter
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte // zero for a root span
+	sampled  bool
+	timings  *Timings
+
+	name       string
+	start, end time.Time
+	attrs      []keyValue
+	errMsg     string
+	failed     bool
+}
+
+// Start starts a span named name, with the attributes in keyvals,
+// alternating keys and values as for log.Printkv. The span is a
+// child of the span in ctx, if any, or else the root of a new
+// trace. It returns the span and a Context carrying it. The caller
+// must call End when the step is done.
+func Start(ctx context.Context, name string, keyvals ...interface{}) (context.Context, *Span) {
+	exp := current()
+	timings, _ := ctx.Value(timingsKey).(*Timings)
+	if exp == nil && timings == nil {
+		return ctx, nil
+	}
+	s := &Span{exp: exp, name: name, timings: timings}
+	if exp == nil {
+		// timed, not exported
+	} else if parent, _ := ctx.Value(spanKey).(*Span); parent != nil {
+		s.traceID, s.parentID, s.sampled = parent.traceID, parent.spanID, parent.sampled
+	} else {
+		ids.read(s.traceID[:])
+		s.sampled = exp.sample()
+	}
+	if s.sampled {
+		ids.read(s.spanID[:])
+		s.SetAttributes(keyvals...)
+	}
+	if s.sampled || s.timings != nil {
+		s.start = time.Now()
+	}
+	return context.WithValue(ctx, spanKey, s), s
+}
+
+// FromContext returns the span carried by ctx, or nil.
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey).(*Span)
+	return s
+}
+
+// SetAttributes adds the attributes in keyvals, alternating keys and
+// values, to s. Keys are strings; values are strings, integers or
+// bools, or are formatted with fmt.Sprint.
+func (s *Span) SetAttributes(keyvals ...interface{}) {
+	if s == nil || !s.sampled {
+		return
+	}
+	if len(keyvals)%2 != 0 {
+		keyvals = append(keyvals, "MISSING")
+	}
+	for i := 0; i < len(keyvals); i += 2 {
+		s.attrs = append(s.attrs, attribute(fmt.Sprint(keyvals[i]), keyvals[i+1]))
+	}
+}
+
+// SetError marks s as failed with err, if err is not nil.
+func (s *Span) SetError(err error) {
+	if s == nil || !s.sampled || err == nil {
+		return
+	}
+	s.failed = true
+	s.errMsg = err.Error()
+}
+
+// End ends s and queues it to be exported, and adds its duration
+// to the Timings it was started under, if any.
+func (s *Span) End() {
+	if s == nil || (!s.sampled && s.timings == nil) || !s.end.IsZero() {
+		return
+	}
+	s.end = time.Now()
+	if s.timings != nil {
+		s.timings.add(s.name, s.end.Sub(s.start))
+	}
+	if s.sampled {
+		s.exp.enqueue(s)
+	}
+}
+
+// Timings are the durations of the spans started under a Context
+// returned by WithTimings, by span name. They may be used
+// concurrently.
+type Timings struct {
+	mu    sync.Mutex
+	names []string // in the order the spans ended first
+	d     map[string]time.Duration
+}
+
+// A Timing is the total duration of the spans of one name.
+type Timing struct {
+	Name     string
+	Duration time.Duration
+}
+
+// WithTimings returns a Context carrying a new Timings, in which the
+// spans started under it, and under their children, record their
+// durations.
+func WithTimings(ctx context.Context) (context.Context, *Timings) {
+	t := &Timings{d: make(map[string]time.Duration)}
+	return context.WithValue(ctx, timingsKey, t), t
+}
+
+func (t *Timings) add(name string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.d[name]; !ok {
+		t.names = append(t.names, name)
+	}
+	t.d[name] += d
+}
+
+// All returns the total duration of the spans of each name, in the
+// order the first span of each name ended.
+func (t *Timings) All() []Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	all := make([]Timing, 0, len(t.names))
+	for _, name := range t.names {
+		all = append(all, Timing{name, t.d[name]})
+	}
+	return all
+}
+
+// ids generates trace and span IDs. It need not be cryptographically
+// random, only unlikely to repeat.
+var ids = newIDSource()
+
+type idSource struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func newIDSource() *idSource {
+	var seed [8]byte
+	cryptorand.Read(seed[:])
+	return &idSource{rnd: rand.New(rand.NewSource(int64(binary.LittleEndian.Uint64(seed[:]))))}
+}
+
+func (s *idSource) read(b []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rnd.Read(b)
+}
+
+func (s *idSource) intn(n int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rnd.Intn(n)
+}
@@ -0,0 +1,91 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/chainmint/errors"
+)
+
+func TestExport(t *testing.T) {
+	if _, s := Start(context.Background(), "off"); s != nil {
+		t.Fatal("Start recorded a span before Export")
+	}
+
+	reqs := make(chan exportRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/v1/traces" {
+			t.Errorf("path = %s want /v1/traces", req.URL.Path)
+		}
+		var r exportRequest
+		err := json.NewDecoder(req.Body).Decode(&r)
+		if err != nil {
+			t.Error(err)
+		}
+		reqs <- r
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := Export(ctx, srv.URL+"/", "test", 10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootCtx, root := Start(context.Background(), "root", "height", uint64(7))
+	_, child := Start(rootCtx, "child")
+	child.SetError(errors.New("boom"))
+	child.End()
+	root.End()
+	cancel()
+
+	var r exportRequest
+	select {
+	case r = <-reqs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no spans exported")
+	}
+	spans := r.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(spans))
+	}
+	c, p := spans[0], spans[1]
+	if c.Name != "child" || p.Name != "root" {
+		t.Fatalf("exported spans %s, %s, want child, root", c.Name, p.Name)
+	}
+	if c.TraceID != p.TraceID || c.ParentSpanID != p.SpanID || p.ParentSpanID != "" {
+		t.Errorf("child %+v is not under root %+v", c, p)
+	}
+	if c.Status == nil || c.Status.Code != statusError || c.Status.Message != "boom" {
+		t.Errorf("child status = %+v, want error boom", c.Status)
+	}
+	if len(p.Attributes) != 1 || p.Attributes[0].Key != "height" || *p.Attributes[0].Value.IntValue != "7" {
+		t.Errorf("root attributes = %+v, want height 7", p.Attributes)
+	}
+	if got := *r.ResourceSpans[0].Resource.Attributes[0].Value.StringValue; got != "test" {
+		t.Errorf("service.name = %s want test", got)
+	}
+}
+
+func TestTimings(t *testing.T) {
+	ctx, timings := WithTimings(context.Background())
+	ctx, root := Start(ctx, "root")
+	for i := 0; i < 2; i++ {
+		_, s := Start(ctx, "step")
+		time.Sleep(time.Millisecond)
+		s.End()
+	}
+	root.End()
+	root.End() // no-op
+
+	all := timings.All()
+	if len(all) != 2 || all[0].Name != "step" || all[1].Name != "root" {
+		t.Fatalf("timings = %v want step, root", all)
+	}
+	if all[0].Duration < 2*time.Millisecond || all[1].Duration < all[0].Duration {
+		t.Errorf("timings = %v, want step at least 2ms and root at least step", all)
+	}
+}
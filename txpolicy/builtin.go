@@ -0,0 +1,129 @@
+package txpolicy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+// newDenyPrograms creates a policy rejecting transactions that spend
+// from or pay to any of the control programs listed, in hex, in the
+// file named by arg.
+func newDenyPrograms(arg string) (Policy, error) {
+	lines, err := readList(arg)
+	if err != nil {
+		return nil, err
+	}
+	denied := make(map[string]bool)
+	for _, line := range lines {
+		prog, err := hex.DecodeString(line)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing program %q", line)
+		}
+		denied[string(prog)] = true
+	}
+	return PolicyFunc(func(tx *legacy.Tx) error {
+		for i, in := range tx.Inputs {
+			if prog := in.ControlProgram(); denied[string(prog)] {
+				return errors.WithDetailf(ErrRejected, "input %d spends from denied program %x", i, prog)
+			}
+		}
+		for i, out := range tx.Outputs {
+			if denied[string(out.ControlProgram)] {
+				return errors.WithDetailf(ErrRejected, "output %d pays to denied program %x", i, out.ControlProgram)
+			}
+		}
+		return nil
+	}), nil
+}
+
+// newAllowAssets creates a policy rejecting transactions that issue,
+// spend or pay any asset but those whose IDs are listed in the file
+// named by arg.
+func newAllowAssets(arg string) (Policy, error) {
+	lines, err := readList(arg)
+	if err != nil {
+		return nil, err
+	}
+	allowed := make(map[bc.AssetID]bool)
+	for _, line := range lines {
+		var id bc.AssetID
+		err := id.UnmarshalText([]byte(line))
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing asset ID %q", line)
+		}
+		allowed[id] = true
+	}
+	return PolicyFunc(func(tx *legacy.Tx) error {
+		for i, in := range tx.Inputs {
+			if id := in.AssetID(); !allowed[id] {
+				return errors.WithDetailf(ErrRejected, "input %d moves asset %x, which is not allowed", i, id.Bytes())
+			}
+		}
+		for i, out := range tx.Outputs {
+			if out.AssetId == nil || !allowed[*out.AssetId] {
+				return errors.WithDetailf(ErrRejected, "output %d moves an asset that is not allowed", i)
+			}
+		}
+		return nil
+	}), nil
+}
+
+// newDenyReferenceData creates a policy rejecting transactions whose
+// reference data, or the reference data of any of their inputs or
+// outputs, matches the regular expression arg.
+func newDenyReferenceData(arg string) (Policy, error) {
+	if arg == "" {
+		return nil, errors.New("missing regular expression")
+	}
+	re, err := regexp.Compile(arg)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing regular expression")
+	}
+	return PolicyFunc(func(tx *legacy.Tx) error {
+		if re.Match(tx.ReferenceData) {
+			return errors.WithDetail(ErrRejected, "reference data matches a denied pattern")
+		}
+		for i, in := range tx.Inputs {
+			if re.Match(in.ReferenceData) {
+				return errors.WithDetailf(ErrRejected, "input %d reference data matches a denied pattern", i)
+			}
+		}
+		for i, out := range tx.Outputs {
+			if re.Match(out.ReferenceData) {
+				return errors.WithDetailf(ErrRejected, "output %d reference data matches a denied pattern", i)
+			}
+		}
+		return nil
+	}), nil
+}
+
+// readList returns the lines of the file at path, without blank
+// lines and comments, which start with "#".
+func readList(path string) ([]string, error) {
+	if path == "" {
+		return nil, errors.New("missing list file")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening list file")
+	}
+	defer f.Close()
+	var lines []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := string(bytes.TrimSpace(s.Bytes()))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, errors.Wrap(s.Err(), "reading list file")
+}
@@ -0,0 +1,152 @@
+// Package txpolicy implements the local policies CheckTx applies to
+// transactions before admitting them to the mempool, and a registry
+// to select them by name.
+//
+// A policy only decides what this node admits and relays; it is not
+// part of consensus, and a block holding a transaction the policy
+// rejects is still valid. Operators add their own policies by
+// registering them, either from a package compiled into the node or
+// from a Go plugin whose init function calls Register.
+package txpolicy
+
+import (
+	"plugin"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+var (
+	// ErrRejected is returned for a transaction a policy rejects.
+	ErrRejected = errors.New("transaction rejected by policy")
+
+	// ErrUnknownPolicy is returned by New when no policy has been
+	// registered under the requested name.
+	ErrUnknownPolicy = errors.New("unknown transaction policy")
+)
+
+// A Policy decides whether the node admits transactions.
+type Policy interface {
+	// Check returns an error, whose detail says why, if the node
+	// should not admit tx. It must be safe to call concurrently.
+	Check(tx *legacy.Tx) error
+}
+
+// PolicyFunc adapts a function to a Policy.
+type PolicyFunc func(tx *legacy.Tx) error
+
+// Check calls f(tx).
+func (f PolicyFunc) Check(tx *legacy.Tx) error { return f(tx) }
+
+// Constructor creates a policy from its argument, whose meaning is
+// up to the policy, such as the path of a file listing the programs
+// it rejects. The argument is empty if none was given.
+type Constructor func(arg string) (Policy, error)
+
+var (
+	mu       sync.Mutex
+	registry = make(map[string]Constructor)
+)
+
+func init() {
+	Register("deny-programs", newDenyPrograms)
+	Register("allow-assets", newAllowAssets)
+	Register("deny-reference-data", newDenyReferenceData)
+}
+
+// Register makes a policy available under the given name.
+// It panics if a policy with that name is already registered.
+func Register(name string, ctor Constructor) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := registry[name]; ok {
+		panic("txpolicy: duplicate registration of " + name)
+	}
+	registry[name] = ctor
+}
+
+// New creates a policy from spec, the name it is registered under,
+// optionally followed by "=" and its argument.
+func New(spec string) (Policy, error) {
+	name, arg := spec, ""
+	if i := strings.Index(spec, "="); i >= 0 {
+		name, arg = spec[:i], spec[i+1:]
+	}
+	mu.Lock()
+	ctor, ok := registry[name]
+	mu.Unlock()
+	if !ok {
+		return nil, errors.WithDetailf(ErrUnknownPolicy, "policy %q (available: %v)", name, Names())
+	}
+	p, err := ctor(arg)
+	return p, errors.Wrapf(err, "creating policy %q", name)
+}
+
+// Names returns the names of all registered policies, sorted.
+func Names() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	var names []string
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Load opens the Go plugin at path, whose init functions register
+// the policies it provides.
+func Load(path string) error {
+	_, err := plugin.Open(path)
+	return errors.Wrapf(err, "loading policy plugin %s", path)
+}
+
+// A Set is the policies a node applies, in order.
+type Set struct {
+	names    []string
+	policies []Policy
+}
+
+// NewSet creates the policies given by specs; see New.
+func NewSet(specs []string) (*Set, error) {
+	s := new(Set)
+	for _, spec := range specs {
+		p, err := New(spec)
+		if err != nil {
+			return nil, err
+		}
+		name := spec
+		if i := strings.Index(spec, "="); i >= 0 {
+			name = spec[:i]
+		}
+		s.names = append(s.names, name)
+		s.policies = append(s.policies, p)
+	}
+	return s, nil
+}
+
+// Check applies the policies to tx in order, and returns
+// ErrRejected, naming the policy, for the first that rejects it. A
+// nil Set admits every transaction.
+func (s *Set) Check(tx *legacy.Tx) error {
+	if s == nil {
+		return nil
+	}
+	for i, p := range s.policies {
+		err := p.Check(tx)
+		if err != nil {
+			return errors.WithDetailf(ErrRejected, "%s: %s", s.names[i], detail(err))
+		}
+	}
+	return nil
+}
+
+func detail(err error) string {
+	if d := errors.Detail(err); d != "" {
+		return d
+	}
+	return err.Error()
+}
@@ -0,0 +1,99 @@
+package txpolicy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chainmint/errors"
+	"github.com/chainmint/protocol/bc"
+	"github.com/chainmint/protocol/bc/legacy"
+)
+
+var (
+	allowedAsset = bc.NewAssetID([32]byte{1})
+	otherAsset   = bc.NewAssetID([32]byte{2})
+)
+
+func payTx(asset bc.AssetID, prog []byte, ref string) *legacy.Tx {
+	return &legacy.Tx{TxData: legacy.TxData{
+		Outputs: []*legacy.TxOutput{
+			legacy.NewTxOutput(asset, 1, prog, []byte(ref)),
+		},
+	}}
+}
+
+func TestSet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "txpolicy_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	programs := filepath.Join(dir, "programs")
+	assets := filepath.Join(dir, "assets")
+	assetHex, _ := allowedAsset.MarshalText()
+	if err := ioutil.WriteFile(programs, []byte("# sanctioned\nbadbad\n\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(assets, append(assetHex, '\n'), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewSet([]string{
+		"deny-programs=" + programs,
+		"allow-assets=" + assets,
+		`deny-reference-data="ssn":`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		tx     *legacy.Tx
+		policy string // the policy rejecting tx, if any
+	}{
+		{payTx(allowedAsset, []byte{0x51}, ""), ""},
+		{payTx(allowedAsset, []byte{0xba, 0xdb, 0xad}, ""), "deny-programs"},
+		{payTx(otherAsset, []byte{0x51}, ""), "allow-assets"},
+		{payTx(allowedAsset, []byte{0x51}, `{"ssn": "000-00-0000"}`), "deny-reference-data"},
+	}
+	for i, c := range cases {
+		err := s.Check(c.tx)
+		if c.policy == "" {
+			if err != nil {
+				t.Errorf("%d: Check = %v, want nil", i, err)
+			}
+			continue
+		}
+		if errors.Root(err) != ErrRejected || !strings.HasPrefix(errors.Detail(err), c.policy+":") {
+			t.Errorf("%d: Check = %v (%s), want rejection by %s", i, err, errors.Detail(err), c.policy)
+		}
+	}
+
+	var none *Set
+	if err := none.Check(cases[1].tx); err != nil {
+		t.Errorf("nil Set Check = %v, want nil", err)
+	}
+}
+
+func TestNewUnknown(t *testing.T) {
+	_, err := New("no-such-policy=x")
+	if errors.Root(err) != ErrUnknownPolicy {
+		t.Errorf("New(unknown) = %v, want %v", err, ErrUnknownPolicy)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("test-deny-all", func(arg string) (Policy, error) {
+		return PolicyFunc(func(*legacy.Tx) error { return errors.New(arg) }), nil
+	})
+	s, err := NewSet([]string{"test-deny-all=closed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Check(payTx(allowedAsset, nil, ""))
+	if errors.Root(err) != ErrRejected || errors.Detail(err) != "test-deny-all: closed" {
+		t.Errorf("Check = %v (%s), want rejection by test-deny-all: closed", err, errors.Detail(err))
+	}
+}
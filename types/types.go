@@ -5,14 +5,68 @@ import (
 	"github.com/chainmint/protocol/bc/legacy"
 )
 
-// ValidatorsStrategy is a validator strategy
-type ValidatorsStrategy interface {
+// Strategy decides how validators are compensated for the
+// blocks they commit, and how the validator set evolves.
+type Strategy interface {
+	// SetValidators replaces the validator set the strategy
+	// distributes rewards to.
 	SetValidators(validators []*types.Validator)
-	CollectTx(tx *legacy.Tx)
-	GetUpdatedValidators() []*types.Validator
+
+	// SetCommission sets the share of its rewards, in basis
+	// points, that a validator keeps. The rest is split between
+	// the validator and its delegators in proportion to stake.
+	SetCommission(validator []byte, rate uint64)
+
+	// SetDelegations replaces the stake delegated to the
+	// validators.
+	SetDelegations(delegations []Delegation)
+
+	// CollectFee records the fee paid by a transaction
+	// included in the current block.
+	CollectFee(tx *legacy.Tx)
+
+	// Distribute pays out the fees collected since the last
+	// call and returns the reward given to each validator.
+	Distribute(block BlockInfo) []Reward
+
+	// ValidatorUpdates returns the validator set changes to
+	// apply at the end of the current block.
+	ValidatorUpdates() []*types.Validator
+}
+
+// BlockInfo describes the block whose fees are being distributed.
+type BlockInfo struct {
+	Height uint64
+}
+
+// Reward is an amount of the fee asset paid to a validator, and
+// the shares of it accrued to the validator's delegators. Amount
+// is what the validator keeps, after the delegators' shares.
+type Reward struct {
+	PubKey     []byte            `json:"pub_key"`
+	Amount     uint64            `json:"amount"`
+	Delegators []DelegatorReward `json:"delegators,omitempty"`
+}
+
+// DelegatorReward is the share of a reward accrued to a delegator.
+type DelegatorReward struct {
+	Delegator []byte `json:"delegator"`
+	Amount    uint64 `json:"amount"`
+}
+
+// Delegation is stake delegated to a validator. The delegator is
+// identified by the control program that owns the stake.
+type Delegation struct {
+	Validator []byte `json:"validator"`
+	Delegator []byte `json:"delegator"`
+	Amount    uint64 `json:"amount"`
 }
 
-// Strategy encompasses all available strategies
-type Strategy struct {
-	ValidatorsStrategy
+// PersistentStrategy is implemented by strategies whose bookkeeping
+// (collected fees, pending validator updates) must survive a restart.
+// MarshalState is called after every Commit and the result is handed
+// back to UnmarshalState when the application starts up again.
+type PersistentStrategy interface {
+	MarshalState() ([]byte, error)
+	UnmarshalState(data []byte) error
 }